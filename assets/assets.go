@@ -0,0 +1,13 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package assets embeds the body of templates, fields definitions and fields generation configurations in the binary.
+package assets
+
+import "embed"
+
+// Templates holds the `templates` folder, with a `<package>.<dataset>/schema-<schema>` folder for each entry
+//
+//go:embed templates
+var Templates embed.FS
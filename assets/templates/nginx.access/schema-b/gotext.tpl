@@ -0,0 +1,3 @@
+{{- $ts := generate "timestamp" }}
+{{- $referrer := generate "referrer" -}}
+{"@timestamp":"{{ $ts.Format "2006-01-02T15:04:05.999999Z07:00" }}","agent":{"id":"{{generate "agent.id"}}","type":"filebeat"},"data_stream":{"namespace":"default","type":"logs","dataset":"nginx.access"},"event":{"dataset":"nginx.access"},"host":{"name":"{{generate "host.name"}}"},"input":{"type":"log"},"log":{"file":{"path":"/var/log/nginx/access.log"}},"message":"{{generate "source.ip"}} - - [{{$ts | date "02/Jan/2006:15:04:05 -0700"}}] \"{{generate "method"}} {{generate "path"}} HTTP/1.1\" {{generate "status"}} {{generate "bytes"}} \"{{if $referrer}}{{$referrer}}{{else}}-{{end}}\" \"{{generate "user_agent"}}\""}
\ No newline at end of file
@@ -0,0 +1 @@
+{"@timestamp":"{{.timestamp}}","agent":{"id":"{{.agent.id}}","type":"filebeat"},"data_stream":{"namespace":"default","type":"logs","dataset":"nginx.access"},"event":{"dataset":"nginx.access"},"host":{"name":"{{.host.name}}"},"input":{"type":"log"},"log":{"file":{"path":"/var/log/nginx/access.log"}},"message":"{{.source.ip}} - - [{{$.timestamp | date "02/Jan/2006:15:04:05 -0700"}}] \"{{.method}} {{.path}} HTTP/1.1\" {{.status}} {{.bytes}} \"{{if $.referrer}}{{$.referrer}}{{else}}-{{end}}\" \"{{.user_agent}}\""}
\ No newline at end of file
@@ -0,0 +1,211 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/catalog"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var catalogTemplate string
+
+// CatalogCmd returns the command to list and use the templates embedded in the binary.
+func CatalogCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "catalog",
+		Short: "Use the catalog of templates embedded in the tool",
+		Long:  "Use the catalog of ready-made templates, fields definitions and fields generation configurations embedded in the tool, the same of the assets/templates folder",
+	}
+
+	command.AddCommand(catalogListCmd())
+	command.AddCommand(catalogUseCmd())
+
+	return command
+}
+
+func catalogListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the catalog entries",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := catalog.New().List()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSCHEMA\tTEMPLATES")
+			for _, entry := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", entry.Name, entry.Schema, strings.Join(entry.Templates, ", "))
+			}
+
+			return w.Flush()
+		},
+	}
+}
+
+func catalogUseCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:     "use package.dataset",
+		Example: "catalog use aws.billing -t 1000",
+		Short:   "Generate a corpus from a catalog entry",
+		Long:    "Generate a corpus from the template, fields definition and fields generation configuration of a catalog entry",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("you must pass the name of the catalog entry, see `catalog list`")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs := afero.NewOsFs()
+			location := viper.GetString("corpora_location")
+
+			template := catalogTemplate
+			if len(template) == 0 {
+				template = templateType
+			}
+
+			files, err := catalog.New().Load(args[0], flagSchema, template)
+			if err != nil {
+				return err
+			}
+
+			// a local config file replaces the one of the entry
+			var cfg config.Config
+			if len(configFile) > 0 {
+				cfg, err = config.LoadConfig(fs, configFile)
+			} else if len(files.FieldsConfig) > 0 {
+				cfg, err = config.LoadConfigFromYaml(files.FieldsConfig)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			printConfigWarnings(cmd.ErrOrStderr(), cfg)
+
+			fc, err := corpus.NewGeneratorWithTemplate(cfg, fs, location, templateType)
+			if err != nil {
+				return err
+			}
+
+			timeNow, err := getTimeNowFromFlag(timeNowAsString)
+			if err != nil {
+				return err
+			}
+
+			tel, stopTelemetry, err := startTelemetry(cmd.ErrOrStderr())
+			if err != nil {
+				return err
+			}
+			defer stopTelemetry()
+
+			fc = fc.WithMaxWriteMBps(maxWriteMBps).WithStateFile(stateFile).WithReportFile(reportFile).WithTelemetry(tel).WithConfigSeed(!cmd.Flags().Changed("seed"))
+			fc, err = fc.WithMaxEventSize(maxEventBytes, oversizeEvents)
+			if err != nil {
+				return err
+			}
+
+			fc, err = fc.WithRate(rate)
+			if err != nil {
+				return err
+			}
+
+			fc, err = fc.WithMaxDuration(maxDuration)
+			if err != nil {
+				return err
+			}
+
+			fc, err = withCorpusFile(fc)
+			if err != nil {
+				return err
+			}
+
+			name := fmt.Sprintf("%s-%s.tpl", args[0], template)
+
+			es, err := newElasticsearchDocumentSink()
+			if err != nil {
+				return err
+			}
+
+			if es != nil {
+				err = generateToElasticsearch(cmd.Context(), cmd.OutOrStdout(), es, func(w io.Writer, batchSize uint64) error {
+					return fc.WithBatchSize(batchSize).GenerateWithTemplateContentTo(w, name, files.Template, files.FieldsDefinition, totEvents, timeNow, randSeed)
+				})
+				if err != nil {
+					return err
+				}
+
+				printOversizeEvents(cmd.ErrOrStderr(), fc)
+				printTruncation(cmd.ErrOrStderr(), fc)
+				return nil
+			}
+
+			registered, err := newRegisteredSink()
+			if err != nil {
+				return err
+			}
+
+			if registered != nil {
+				err = generateToSink(cmd.Context(), cmd.OutOrStdout(), registered, func(w io.Writer, batchSize uint64) error {
+					return fc.WithBatchSize(batchSize).GenerateWithTemplateContentTo(w, name, files.Template, files.FieldsDefinition, totEvents, timeNow, randSeed)
+				})
+				if err != nil {
+					return err
+				}
+
+				printOversizeEvents(cmd.ErrOrStderr(), fc)
+				printTruncation(cmd.ErrOrStderr(), fc)
+				return nil
+			}
+
+			payloadFilename, err := fc.GenerateWithTemplateContent(name, files.Template, files.FieldsDefinition, totEvents, timeNow, randSeed)
+			if err != nil {
+				return err
+			}
+
+			printOversizeEvents(cmd.ErrOrStderr(), fc)
+			printTruncation(cmd.ErrOrStderr(), fc)
+			recordCorpus(cmd.ErrOrStderr(), fc, payloadFilename, name)
+			fmt.Fprintln(cmd.OutOrStdout(), "File generated:", payloadFilename)
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings, instead of the one of the entry")
+	command.Flags().StringVarP(&templateType, "engine", "e", "gotext", "either 'placeholder' or 'gotext'")
+	command.Flags().StringVar(&catalogTemplate, "template", "", "name of the template of the entry, as in `catalog list`; defaults to the engine")
+	command.Flags().StringVarP(&flagSchema, "schema", "", "b", "schema to generate data for; valid values: a, b")
+	command.Flags().Uint64VarP(&totEvents, "tot-events", "t", 1, "total events of the corpus to generate")
+	command.Flags().StringVarP(&timeNowAsString, "now", "n", "", "time to use for generation based on now (`date` type)")
+	command.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand, overriding the `seed` of the config file")
+	command.Flags().Float64Var(&maxWriteMBps, "max-write-mbps", 0, "maximum MB per second written to the corpus file, 0 for unlimited")
+	command.Flags().StringVar(&rate, "rate", "", "events emitted per unit of time, like '1000/s', '60000/m' or '3600000/h', instead of as fast as possible; with -t 0 the events are emitted until interrupted")
+	command.Flags().DurationVar(&maxDuration, "max-duration", 0, "time budget of the generation, like '10m', after which it stops with the events generated so far, overriding the `max_duration` of the config file; 0 for the one of the config file, if any")
+	command.Flags().StringVar(&stateFile, "state-file", "", "file to load the state of the generation from, if it exists, and to save it to at the end")
+	command.Flags().StringVar(&reportFile, "report-file", "", "file to write an HTML report of the generation to, with charts of the event rate and of the fields")
+	addEventSizeFlags(command)
+	addCorpusFileFlags(command)
+	addTelemetryFlags(command)
+	addElasticsearchFlags(command)
+	addSinkFlags(command)
+	addLatencyFlags(command)
+	addHTTPFlags(command)
+
+	return command
+}
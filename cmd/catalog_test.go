@@ -0,0 +1,91 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogCmd_list(t *testing.T) {
+	command := cmd.CatalogCmd()
+
+	b := new(bytes.Buffer)
+	command.SetOut(b)
+	command.SetArgs([]string{"list"})
+
+	err := command.Execute()
+	require.NoError(t, err)
+	require.Contains(t, b.String(), "aws.vpcflow")
+}
+
+func TestCatalogCmd_useNotFound(t *testing.T) {
+	command := cmd.CatalogCmd()
+
+	command.SetOut(new(bytes.Buffer))
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{"use", "not.existing"})
+
+	err := command.Execute()
+	require.ErrorContains(t, err, "catalog entry not found")
+}
+
+// collectSink keeps the events written to it
+type collectSink struct {
+	options map[string]string
+	events  [][]byte
+	batches uint64
+}
+
+func (s *collectSink) Open(context.Context) error { return nil }
+
+func (s *collectSink) WriteBatch(_ context.Context, events [][]byte) error {
+	for _, event := range events {
+		s.events = append(s.events, append([]byte(nil), event...))
+	}
+
+	s.batches++
+	return nil
+}
+
+func (s *collectSink) Flush(context.Context) error { return nil }
+
+func (s *collectSink) Close() error { return nil }
+
+func (s *collectSink) Stats() sinks.Stats {
+	return sinks.Stats{Events: uint64(len(s.events)), Batches: s.batches}
+}
+
+func TestCatalogCmd_useSink(t *testing.T) {
+	collect := &collectSink{}
+	sinks.Register("test-collect", func(options map[string]string) (sinks.Sink, error) {
+		collect.options = options
+		return collect, nil
+	})
+
+	command := cmd.CatalogCmd()
+
+	b := new(bytes.Buffer)
+	command.SetOut(b)
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{"use", "aws.sqs", "-t", "5", "--sink", "test-collect", "--sink-option", "topic=logs", "--sink-batch-size", "2"})
+
+	err := command.Execute()
+	require.NoError(t, err)
+	require.Contains(t, b.String(), "Events written to sink test-collect: 5")
+	require.Equal(t, map[string]string{"topic": "logs"}, collect.options)
+	require.Len(t, collect.events, 5)
+	require.Equal(t, uint64(3), collect.batches)
+
+	for _, event := range collect.events {
+		require.True(t, json.Valid(event), string(event))
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var pruneMissing bool
+
+// CorpusCmd returns the command to list and remove the corpora generated on the machine.
+func CorpusCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "corpus",
+		Short: "Manage the corpora generated on the machine",
+		Long:  "List and remove the corpus files generated by the generate, generate-with-template, catalog use, downsample and merge commands, recorded in the corpora index",
+	}
+
+	command.AddCommand(corpusListCmd())
+	command.AddCommand(corpusRmCmd())
+
+	return command
+}
+
+func corpusListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the generated corpora",
+		Long:  "List the generated corpora, the most recent first, with what they were generated from; a corpus whose file has been removed is marked as missing",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs := afero.NewOsFs()
+			entries, err := corpus.NewIndex(fs, viper.GetString("corpora_index")).List()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CREATED\tSOURCE\tEVENTS\tSIZE\tFORMAT\tCONFIG\tPATH")
+			for _, entry := range entries {
+				format := entry.Format
+				if entry.Gzip {
+					format += "+gzip"
+				}
+
+				configFile := entry.ConfigFile
+				if len(configFile) == 0 {
+					configFile = "-"
+				}
+
+				filePath := entry.Path
+				if _, err := fs.Stat(entry.Path); errors.Is(err, os.ErrNotExist) {
+					filePath += " (missing)"
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", entry.CreatedAt.Local().Format("2006-01-02 15:04:05"), entry.Source, entry.Events, entry.Size, format, configFile, filePath)
+			}
+
+			return w.Flush()
+		},
+	}
+}
+
+func corpusRmCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:     "rm path...",
+		Example: "corpus rm /path/to/corpora/1649330390-aws-dynamodb-1.14.0.ndjson",
+		Short:   "Remove generated corpora",
+		Long:    "Remove the corpus files, as listed by corpus list, and their entries of the corpora index; with --missing, remove the entries whose corpus file does not exist anymore",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !pruneMissing {
+				return errors.New("you must pass the paths of the corpora to remove, see `corpus list`, or --missing")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index := corpus.NewIndex(afero.NewOsFs(), viper.GetString("corpora_index"))
+			for _, arg := range args {
+				filePath, err := filepath.Abs(arg)
+				if err != nil {
+					return err
+				}
+
+				if err := index.Remove(filePath); err != nil {
+					return err
+				}
+
+				fmt.Fprintln(cmd.OutOrStdout(), "Corpus removed:", filePath)
+			}
+
+			if !pruneMissing {
+				return nil
+			}
+
+			pruned, err := index.Prune()
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range pruned {
+				fmt.Fprintln(cmd.OutOrStdout(), "Missing corpus removed from the index:", entry.Path)
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().BoolVar(&pruneMissing, "missing", false, "remove the entries of the corpora whose file does not exist anymore")
+
+	return command
+}
+
+// recordCorpus records the corpus file generated from source in the corpora index, printing a warning to w when it
+// cannot: the corpus is generated anyway
+func recordCorpus(w io.Writer, fc corpus.GeneratorCorpus, payloadFilename, source string) {
+	recordIndexEntry(w, payloadFilename, func(filePath string) (corpus.IndexEntry, error) {
+		var configPath string
+		if len(configFile) > 0 {
+			var err error
+			if configPath, err = filepath.Abs(configFile); err != nil {
+				return corpus.IndexEntry{}, err
+			}
+		}
+
+		return fc.IndexEntry(filePath, source, configPath, totEvents)
+	})
+}
+
+// recordIndexEntry records the entry returned by entryF for the absolute path of the corpus file in the corpora
+// index, printing a warning to w when it cannot
+func recordIndexEntry(w io.Writer, payloadFilename string, entryF func(filePath string) (corpus.IndexEntry, error)) {
+	indexPath := viper.GetString("corpora_index")
+	if len(indexPath) == 0 {
+		return
+	}
+
+	err := func() error {
+		filePath, err := filepath.Abs(payloadFilename)
+		if err != nil {
+			return err
+		}
+
+		entry, err := entryF(filePath)
+		if err != nil {
+			return err
+		}
+
+		return corpus.NewIndex(afero.NewOsFs(), indexPath).Add(entry)
+	}()
+
+	if err != nil {
+		fmt.Fprintln(w, "Warning: cannot record the corpus in the corpora index:", err)
+	}
+}
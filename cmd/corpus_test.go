@@ -0,0 +1,74 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorpusCmd(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "placeholder.tpl")
+	fieldsPath := filepath.Join(dir, "fields.yml")
+	location := filepath.Join(dir, "corpora")
+
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"host":"{{.host}}"}`), 0644))
+	require.NoError(t, os.WriteFile(fieldsPath, []byte("- name: host\n  type: keyword\n"), 0644))
+
+	viper.Set("corpora_location", location)
+	viper.Set("corpora_index", filepath.Join(dir, "corpora.json"))
+	t.Cleanup(viper.Reset)
+
+	generate := cmd.GenerateWithTemplateCmd()
+	generate.SetOut(new(bytes.Buffer))
+	generate.SetErr(new(bytes.Buffer))
+	generate.SetArgs([]string{templatePath, fieldsPath, "-t", "10"})
+	require.NoError(t, generate.Execute())
+
+	corpora, err := filepath.Glob(filepath.Join(location, "*"))
+	require.NoError(t, err)
+	require.Len(t, corpora, 1)
+
+	list := cmd.CorpusCmd()
+	b := new(bytes.Buffer)
+	list.SetOut(b)
+	list.SetArgs([]string{"list"})
+	require.NoError(t, list.Execute())
+	require.Contains(t, b.String(), "placeholder.tpl")
+	require.Contains(t, b.String(), corpora[0])
+
+	rm := cmd.CorpusCmd()
+	rm.SetOut(new(bytes.Buffer))
+	rm.SetArgs([]string{"rm", corpora[0]})
+	require.NoError(t, rm.Execute())
+	require.NoFileExists(t, corpora[0])
+
+	list = cmd.CorpusCmd()
+	b = new(bytes.Buffer)
+	list.SetOut(b)
+	list.SetArgs([]string{"list"})
+	require.NoError(t, list.Execute())
+	require.NotContains(t, b.String(), corpora[0])
+}
+
+func TestCorpusCmd_rmNotIndexed(t *testing.T) {
+	viper.Set("corpora_index", filepath.Join(t.TempDir(), "corpora.json"))
+	t.Cleanup(viper.Reset)
+
+	command := cmd.CorpusCmd()
+	command.SetOut(new(bytes.Buffer))
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{"rm", "not-existing.ndjson"})
+
+	err := command.Execute()
+	require.ErrorContains(t, err, "corpus not found in the index")
+}
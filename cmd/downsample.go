@@ -0,0 +1,80 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var downsampleRatio float64
+var downsampleOutput string
+var downsampleOptions corpus.DownsampleOptions
+
+func DownsampleCmd() *cobra.Command {
+	downsampleCmd := &cobra.Command{
+		Use:     "downsample corpus-path",
+		Example: "downsample /path/to/corpora/1649330390-aws-dynamodb-1.14.0.ndjson -t 10000 --entity-field host.name",
+		Short:   "Downsample a corpus",
+		Long:    "Write a smaller corpus from an existing one, sampling the events of each time bucket and entity in proportion to their share of the corpus, so that the per-field distributions and the time coverage are preserved",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 || args[0] == "" {
+				return errors.New("you must pass the path of the corpus to downsample")
+			}
+
+			if cmd.Flags().Changed("tot-events") == cmd.Flags().Changed("ratio") {
+				return errors.New("you must pass either -t or --ratio")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inputPath := args[0]
+
+			outputPath := downsampleOutput
+			if len(outputPath) == 0 {
+				outputPath = filepath.Join(viper.GetString("corpora_location"), fmt.Sprintf("%d-downsampled-%s", time.Now().Unix(), filepath.Base(inputPath)))
+			}
+
+			options := downsampleOptions
+			options.TotEvents = totEvents
+			options.Ratio = downsampleRatio
+			options.Seed = randSeed
+
+			fs := afero.NewOsFs()
+			result, err := corpus.Downsample(fs, inputPath, outputPath, options)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Kept %d of %d events from %d strata\n", result.KeptEvents, result.Events, result.Strata)
+			fmt.Fprintln(cmd.OutOrStdout(), "File generated:", outputPath)
+
+			recordIndexEntry(cmd.OutOrStdout(), outputPath, func(filePath string) (corpus.IndexEntry, error) {
+				return corpus.NewIndexEntry(fs, filePath, "downsample of "+filepath.Base(inputPath), "", result.KeptEvents, corpus.FormatText, strings.HasSuffix(outputPath, ".gz"))
+			})
+
+			return nil
+		},
+	}
+
+	downsampleCmd.Flags().Uint64VarP(&totEvents, "tot-events", "t", 0, "total events of the downsampled corpus")
+	downsampleCmd.Flags().Float64Var(&downsampleRatio, "ratio", 0, "ratio of the events of the corpus to keep, between 0 and 1, instead of -t")
+	downsampleCmd.Flags().StringVar(&downsampleOptions.TimestampField, "timestamp-field", "@timestamp", "field with the timestamp of the events, either an RFC 3339 date or milliseconds since the epoch")
+	downsampleCmd.Flags().StringVar(&downsampleOptions.EntityField, "entity-field", "", "field identifying the entity of the events, like 'host.name', to keep the share of the events of each entity")
+	downsampleCmd.Flags().DurationVar(&downsampleOptions.Bucket, "bucket", 0, fmt.Sprintf("duration of the time buckets, like '1h'; 0 to split the time range of the corpus in %d buckets", corpus.DefaultDownsampleBuckets))
+	downsampleCmd.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand")
+	downsampleCmd.Flags().StringVarP(&downsampleOutput, "output", "o", "", "path of the downsampled corpus, compressed with gzip when it ends in '.gz'; by default in the corpora location")
+
+	return downsampleCmd
+}
@@ -0,0 +1,59 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownsampleCmd(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "corpus.ndjson")
+	outputPath := filepath.Join(dir, "downsampled.ndjson")
+
+	var content strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&content, `{"@timestamp":%d,"host":{"name":"host-%d"}}`+"\n", 1700000000000+int64(i)*1000, i%4)
+	}
+
+	require.NoError(t, os.WriteFile(inputPath, []byte(content.String()), 0644))
+
+	viper.Set("corpora_index", filepath.Join(dir, "corpora.json"))
+	t.Cleanup(viper.Reset)
+
+	command := cmd.DownsampleCmd()
+	b := new(bytes.Buffer)
+	command.SetOut(b)
+	command.SetArgs([]string{inputPath, "-t", "10", "--entity-field", "host.name", "-o", outputPath})
+	require.NoError(t, command.Execute())
+	require.Contains(t, b.String(), "Kept 10 of 100 events")
+
+	downsampled, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	require.Equal(t, 10, strings.Count(string(downsampled), "\n"))
+
+	index, err := os.ReadFile(filepath.Join(dir, "corpora.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(index), "downsample of corpus.ndjson")
+}
+
+func TestDownsampleCmd_noBudget(t *testing.T) {
+	command := cmd.DownsampleCmd()
+	command.SetOut(new(bytes.Buffer))
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{"corpus.ndjson"})
+
+	err := command.Execute()
+	require.ErrorContains(t, err, "you must pass either -t or --ratio")
+}
@@ -0,0 +1,151 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/transport"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var sampleEvents uint64
+var indexRatio float64
+
+func EstimateCmd() *cobra.Command {
+	estimateCmd := &cobra.Command{
+		Use:   "estimate {template-path fields-definition-path | integration data_stream version}",
+		Short: "Estimate the cost of a corpus",
+		Long:  "Generate a short calibration sample of a corpus, without writing it, and extrapolate the size of the corpus file, the generation time and the approximate size of the Elasticsearch index",
+		Args: func(cmd *cobra.Command, args []string) error {
+			switch len(args) {
+			case 2:
+				templatePath, fieldsDefinitionPath = args[0], args[1]
+				if templatePath == "" || fieldsDefinitionPath == "" {
+					return errors.New("you must provide a not empty template path and fields definition path")
+				}
+			case 3:
+				integrationPackage, dataStream, packageVersion = args[0], args[1], args[2]
+				if integrationPackage == "" || dataStream == "" || packageVersion == "" {
+					return errors.New("you must provide a not empty integration package, data stream and package version")
+				}
+
+				if packageRegistryBaseURL == "" {
+					return errors.New("you must provide a not empty --package-registry-base-url flag value")
+				}
+			default:
+				return errors.New("you must pass either the template path and the fields definition path, or the integration package the data stream and the package version")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs := afero.NewOsFs()
+			location := viper.GetString("corpora_location")
+
+			cfg, err := config.LoadConfig(fs, configFile)
+			if err != nil {
+				return err
+			}
+
+			printConfigWarnings(cmd.ErrOrStderr(), cfg)
+
+			var fc corpus.GeneratorCorpus
+			if len(args) == 2 {
+				fc, err = corpus.NewGeneratorWithTemplate(cfg, fs, location, templateType)
+			} else {
+				fc, err = corpus.NewGenerator(cfg, fs, location)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			httpClient, err := transport.NewHTTPClient(httpOptions)
+			if err != nil {
+				return err
+			}
+
+			timeNow, err := getTimeNowFromFlag(timeNowAsString)
+			if err != nil {
+				return err
+			}
+
+			fc = fc.WithHTTPClient(httpClient).WithPackageCacheDir(packageCacheDir()).WithMaxWriteMBps(maxWriteMBps).WithConfigSeed(!cmd.Flags().Changed("seed"))
+			fc = fc.WithPackageConfig(len(configFile) == 0 && !noPackageConfig)
+			fc, err = fc.WithMaxEventSize(maxEventBytes, oversizeEvents)
+			if err != nil {
+				return err
+			}
+
+			fc, err = fc.WithRate(rate)
+			if err != nil {
+				return err
+			}
+
+			var estimate corpus.Estimate
+			if len(args) == 2 {
+				estimate, err = fc.EstimateWithTemplate(templatePath, fieldsDefinitionPath, sampleEvents, totEvents, indexRatio, timeNow, randSeed)
+			} else {
+				estimate, err = fc.Estimate(packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, sampleEvents, totEvents, indexRatio, timeNow, randSeed)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			printEstimate(cmd.OutOrStdout(), estimate)
+			return nil
+		},
+	}
+
+	estimateCmd.Flags().StringVarP(&packageRegistryBaseURL, "package-registry-base-url", "r", "https://epr.elastic.co/", "base url of the package registry with schema")
+	addPackageCacheFlags(estimateCmd)
+	estimateCmd.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings")
+	estimateCmd.Flags().BoolVar(&noPackageConfig, "no-package-config", false, "do not apply the default config of the data stream in the package when --config-file is not set")
+	estimateCmd.Flags().StringVarP(&templateType, "template-type", "y", "placeholder", "either 'placeholder' or 'gotext'")
+	estimateCmd.Flags().Uint64VarP(&totEvents, "tot-events", "t", 1, "total events of the corpus to estimate")
+	estimateCmd.Flags().Uint64Var(&sampleEvents, "sample-events", corpus.DefaultSampleEvents, "events of the calibration sample the estimate is extrapolated from")
+	estimateCmd.Flags().Float64Var(&indexRatio, "index-ratio", corpus.DefaultIndexRatio, "ratio of the size of the Elasticsearch index to the size of the events, to measure on a real index of similar events for a better estimate")
+	estimateCmd.Flags().StringVarP(&timeNowAsString, "now", "n", "", "time to use for generation based on now (`date` type)")
+	estimateCmd.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand, overriding the `seed` of the config file")
+	estimateCmd.Flags().Float64Var(&maxWriteMBps, "max-write-mbps", 0, "maximum MB per second the corpus file will be written at, 0 for unlimited")
+	estimateCmd.Flags().StringVar(&rate, "rate", "", "events the corpus will be emitted at per unit of time, like '1000/s', '60000/m' or '3600000/h', instead of as fast as possible")
+	addEventSizeFlags(estimateCmd)
+	addHTTPFlags(estimateCmd)
+
+	return estimateCmd
+}
+
+// printEstimate prints the estimate of a corpus to w
+func printEstimate(w io.Writer, estimate corpus.Estimate) {
+	fmt.Fprintf(w, "Calibration sample: %d events, %s in %s\n", estimate.SampleEvents, formatBytes(estimate.SampleBytes), estimate.SampleDuration.Round(time.Millisecond))
+	fmt.Fprintf(w, "Estimated corpus file size: %s (%s with --gzip)\n", formatBytes(estimate.Bytes), formatBytes(estimate.GzipBytes))
+	fmt.Fprintf(w, "Estimated generation time: %s\n", estimate.Duration.Round(time.Second))
+	fmt.Fprintf(w, "Approximate Elasticsearch index size: %s\n", formatBytes(estimate.IndexBytes))
+}
+
+// formatBytes returns n as a number of bytes with a binary unit, like 1.5GiB
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
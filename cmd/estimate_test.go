@@ -0,0 +1,51 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateCmd(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "placeholder.tpl")
+	fieldsPath := filepath.Join(dir, "fields.yml")
+	configPath := filepath.Join(dir, "config.yml")
+
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"host":{{.host}}}`), 0644))
+	require.NoError(t, os.WriteFile(fieldsPath, []byte("- name: host\n  type: keyword\n"), 0644))
+	require.NoError(t, os.WriteFile(configPath, []byte("fields:\n  - name: host\n    value: web-01\n"), 0644))
+
+	command := cmd.EstimateCmd()
+	b := new(bytes.Buffer)
+	command.SetOut(b)
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{templatePath, fieldsPath, "-c", configPath, "-t", "1048576", "--sample-events", "1024", "--rate", "1024/s"})
+
+	err := command.Execute()
+	require.NoError(t, err)
+
+	// each event is `{"host":"web-01"}` and a new line, 18 bytes
+	require.Contains(t, b.String(), "Calibration sample: 1024 events, 18.0KiB in")
+	require.Contains(t, b.String(), "Estimated corpus file size: 18.0MiB")
+	require.Contains(t, b.String(), "Estimated generation time: 17m4s")
+	require.Contains(t, b.String(), "Approximate Elasticsearch index size: 18.0MiB")
+}
+
+func TestEstimateCmd_noEvents(t *testing.T) {
+	command := cmd.EstimateCmd()
+	command.SetOut(new(bytes.Buffer))
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{"template.tpl", "fields.yml", "-t", "0"})
+
+	err := command.Execute()
+	require.Error(t, err)
+}
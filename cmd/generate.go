@@ -8,16 +8,19 @@ import (
 	"errors"
 	"fmt"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/transport"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/multierr"
+	"io"
 )
 
 var integrationPackage string
 var dataStream string
 var packageVersion string
+var noPackageConfig bool
 
 func GenerateCmd() *cobra.Command {
 	generateCmd := &cobra.Command{
@@ -64,21 +67,101 @@ func GenerateCmd() *cobra.Command {
 				return err
 			}
 
+			printConfigWarnings(cmd.ErrOrStderr(), cfg)
+
 			fc, err := corpus.NewGenerator(cfg, fs, location)
 			if err != nil {
 				return err
 			}
 
+			httpClient, err := transport.NewHTTPClient(httpOptions)
+			if err != nil {
+				return err
+			}
+
+			fc = fc.WithHTTPClient(httpClient).WithPackageCacheDir(packageCacheDir())
+
 			timeNow, err := getTimeNowFromFlag(timeNowAsString)
 			if err != nil {
 				return err
 			}
 
+			reload, stopReload := reloadConfigOnSIGHUP(fs, configFile)
+			defer stopReload()
+
+			tel, stopTelemetry, err := startTelemetry(cmd.ErrOrStderr())
+			if err != nil {
+				return err
+			}
+			defer stopTelemetry()
+
+			fc = fc.WithConfigReload(reload).WithWarnings(cmd.ErrOrStderr()).WithMaxWriteMBps(maxWriteMBps).WithStateFile(stateFile).WithReportFile(reportFile).WithTelemetry(tel).WithConfigSeed(!cmd.Flags().Changed("seed"))
+			fc = fc.WithPackageConfig(len(configFile) == 0 && !noPackageConfig)
+			fc, err = fc.WithMaxEventSize(maxEventBytes, oversizeEvents)
+			if err != nil {
+				return err
+			}
+
+			fc, err = fc.WithRate(rate)
+			if err != nil {
+				return err
+			}
+
+			fc, err = fc.WithMaxDuration(maxDuration)
+			if err != nil {
+				return err
+			}
+
+			fc, err = withCorpusFile(fc)
+			if err != nil {
+				return err
+			}
+
+			// the events are sent with the create actions of the corpus, so that the default data stream of the package is used
+			es, err := newElasticsearchSink("")
+			if err != nil {
+				return err
+			}
+
+			if es != nil {
+				err = generateToElasticsearch(cmd.Context(), cmd.OutOrStdout(), es, func(w io.Writer, batchSize uint64) error {
+					return fc.WithBatchSize(batchSize).WithDataStream(esOptions.DataStream).GenerateTo(w, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, totEvents, timeNow, randSeed)
+				})
+				if err != nil {
+					return err
+				}
+
+				printOversizeEvents(cmd.ErrOrStderr(), fc)
+				printTruncation(cmd.ErrOrStderr(), fc)
+				return nil
+			}
+
+			registered, err := newRegisteredSink()
+			if err != nil {
+				return err
+			}
+
+			if registered != nil {
+				err = generateToSink(cmd.Context(), cmd.OutOrStdout(), registered, func(w io.Writer, batchSize uint64) error {
+					return fc.WithBatchSize(batchSize).WithDataStream(esOptions.DataStream).GenerateTo(w, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, totEvents, timeNow, randSeed)
+				})
+				if err != nil {
+					return err
+				}
+
+				printOversizeEvents(cmd.ErrOrStderr(), fc)
+				printTruncation(cmd.ErrOrStderr(), fc)
+				return nil
+			}
+
 			payloadFilename, err := fc.Generate(packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, totEvents, timeNow, randSeed)
 			if err != nil {
 				return err
 			}
 
+			printOversizeEvents(cmd.ErrOrStderr(), fc)
+			printTruncation(cmd.ErrOrStderr(), fc)
+			recordCorpus(cmd.ErrOrStderr(), fc, payloadFilename, integrationPackage+"."+dataStream+"-"+packageVersion)
 			fmt.Println("File generated:", payloadFilename)
 
 			return nil
@@ -86,10 +169,24 @@ func GenerateCmd() *cobra.Command {
 	}
 
 	generateCmd.Flags().StringVarP(&packageRegistryBaseURL, "package-registry-base-url", "r", "https://epr.elastic.co/", "base url of the package registry with schema")
-	generateCmd.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings")
+	addPackageCacheFlags(generateCmd)
+	generateCmd.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings, instead of the default one of the data stream in the package")
+	generateCmd.Flags().BoolVar(&noPackageConfig, "no-package-config", false, "do not apply the default config of the data stream in the package when --config-file is not set")
 	generateCmd.Flags().Uint64VarP(&totEvents, "tot-events", "t", 1, "total events of the corpus to generate")
 	generateCmd.Flags().StringVarP(&timeNowAsString, "now", "n", "", "time to use for generation based on now (`date` type)")
-	generateCmd.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand")
+	generateCmd.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand, overriding the `seed` of the config file")
+	generateCmd.Flags().Float64Var(&maxWriteMBps, "max-write-mbps", 0, "maximum MB per second written to the corpus file, 0 for unlimited")
+	generateCmd.Flags().StringVar(&rate, "rate", "", "events emitted per unit of time, like '1000/s', '60000/m' or '3600000/h', instead of as fast as possible; with -t 0 the events are emitted until interrupted")
+	generateCmd.Flags().DurationVar(&maxDuration, "max-duration", 0, "time budget of the generation, like '10m', after which it stops with the events generated so far, overriding the `max_duration` of the config file; 0 for the one of the config file, if any")
+	generateCmd.Flags().StringVar(&stateFile, "state-file", "", "file to load the state of the generation from, if it exists, and to save it to at the end")
+	generateCmd.Flags().StringVar(&reportFile, "report-file", "", "file to write an HTML report of the generation to, with charts of the event rate and of the fields")
+	addEventSizeFlags(generateCmd)
+	addCorpusFileFlags(generateCmd)
+	addTelemetryFlags(generateCmd)
+	addElasticsearchFlags(generateCmd)
+	addSinkFlags(generateCmd)
+	addLatencyFlags(generateCmd)
+	addHTTPFlags(generateCmd)
 
 	return generateCmd
 }
@@ -1,17 +1,66 @@
 package cmd
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/sink"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/telemetry"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/transport"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/version"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var packageRegistryBaseURL string
+var noPackageCache bool
 var configFile string
 var totEvents uint64
 var timeNowAsString string
 var randSeed int64
+var maxWriteMBps float64
+var rate string
+var maxDuration time.Duration
+var stateFile string
+var maxEventBytes int
+var oversizeEvents string
+var reportFile string
+var httpOptions transport.HTTPOptions
+var otelEndpoint string
+var otelHeaders []string
+var otelServiceName string
+var otelInterval time.Duration
+var esOptions sink.ElasticsearchOptions
+var esAPIKey string
+var esTLS transport.TLSOptions
+var outputFormat string
+var gzipOutput bool
+var gzipLevel int
+var newline string
+var sinkName string
+var sinkOptions []string
+var sinkBatchSize int
+var sinkBatchTimeout time.Duration
+var sinkSpillDir string
+var latencyField string
+var latencyESURL string
+var latencyIndex string
+var latencyIngestedField string
+var latencyInterval time.Duration
 
 func getTimeNowFromFlag(timeNowAsString string) (time.Time, error) {
 	if len(timeNowAsString) > 0 {
@@ -24,3 +73,526 @@ func getTimeNowFromFlag(timeNowAsString string) (time.Time, error) {
 
 	return time.Now(), nil
 }
+
+// reloadConfigOnSIGHUP loads the config file again each time a SIGHUP is received, and sends it to the
+// returned channel. The returned function stops listening for the signal.
+func reloadConfigOnSIGHUP(fs afero.Fs, configFile string) (<-chan config.Config, func()) {
+	if len(configFile) == 0 {
+		return nil, func() {}
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+
+	reload := make(chan config.Config)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				cfg, err := config.LoadConfig(fs, configFile)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "Cannot reload config, keeping the current one:", err)
+					continue
+				}
+
+				printConfigWarnings(os.Stderr, cfg)
+
+				select {
+				case reload <- cfg:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return reload, func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// printConfigWarnings prints the deprecation warnings of the loaded config to w
+func printConfigWarnings(w io.Writer, cfg config.Config) {
+	for _, warning := range cfg.Warnings() {
+		fmt.Fprintln(w, "Warning:", warning)
+	}
+}
+
+// printOversizeEvents prints to w the number of events dropped or truncated because bigger than the size limit
+func printOversizeEvents(w io.Writer, fc corpus.GeneratorCorpus) {
+	n := fc.OversizeEvents()
+	if n == 0 {
+		return
+	}
+
+	action := "dropped"
+	if oversizeEvents == corpus.OversizeTruncate {
+		action = "truncated"
+	}
+
+	fmt.Fprintf(w, "Warning: %d events bigger than %d bytes have been %s\n", n, maxEventBytes, action)
+}
+
+// printTruncation prints to w the number of events written when the generation stopped at its time budget
+func printTruncation(w io.Writer, fc corpus.GeneratorCorpus) {
+	events, ok := fc.Truncated()
+	if !ok {
+		return
+	}
+
+	fmt.Fprintf(w, "Warning: the generation stopped at its time budget after %d events, the corpus is partial\n", events)
+}
+
+// addEventSizeFlags adds the flags for the size limit of the events of the command
+func addEventSizeFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&maxEventBytes, "max-event-bytes", corpus.DefaultMaxEventBytes, "maximum size in bytes of an event, 0 for unlimited; defaults to the 100mb limit of Elasticsearch")
+	cmd.Flags().StringVar(&oversizeEvents, "oversize-events", corpus.OversizeDrop, "what to do with the events bigger than --max-event-bytes, either 'drop' or 'truncate'")
+}
+
+// addCorpusFileFlags adds the flags for the format and the compression of the corpus file of the command
+func addCorpusFileFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&outputFormat, "output-format", corpus.FormatText, "format of the corpus file, one of 'text', the events as generated one per line, 'parquet', 'otlp-logs' or 'otlp-metrics'")
+	cmd.Flags().BoolVar(&gzipOutput, "gzip", false, "compress the corpus file with gzip, adding '.gz' to its name")
+	cmd.Flags().IntVar(&gzipLevel, "gzip-level", gzip.DefaultCompression, "gzip compression level of --gzip, from 1 for the fastest to 9 for the smallest, -1 for the default")
+	cmd.Flags().StringVar(&newline, "newline", corpus.NewlineLF, "end of the lines of the corpus file with --output-format text, either 'lf' or 'crlf', for Windows log shippers")
+}
+
+// withCorpusFile returns a copy of fc writing the corpus file in the format, and with the compression, set with
+// the flags
+func withCorpusFile(fc corpus.GeneratorCorpus) (corpus.GeneratorCorpus, error) {
+	fc, err := fc.WithFormat(outputFormat)
+	if err != nil {
+		return fc, err
+	}
+
+	if len(newline) > 0 {
+		if newline != corpus.NewlineLF && len(outputFormat) > 0 && outputFormat != corpus.FormatText {
+			return fc, fmt.Errorf("--newline %s cannot be used with --output-format %s", newline, outputFormat)
+		}
+
+		if fc, err = fc.WithNewline(newline); err != nil {
+			return fc, err
+		}
+	}
+
+	if !gzipOutput {
+		return fc, nil
+	}
+
+	return fc.WithGzip(gzipLevel)
+}
+
+// addPackageCacheFlags adds the flags for the cache of the packages downloaded from the package registry
+func addPackageCacheFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&noPackageCache, "no-package-cache", false, "download the package from the package registry, without reading or writing the package cache")
+}
+
+// packageCacheDir returns the folder keeping the packages downloaded from the package registry, or an empty string
+// with --no-package-cache
+func packageCacheDir() string {
+	if noPackageCache {
+		return ""
+	}
+
+	return viper.GetString("package_cache_location")
+}
+
+// addHTTPFlags adds the flags for the settings of the HTTP connections of the command
+func addHTTPFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&httpOptions.TLS.CA, "tls-ca", "", "path to a PEM file with the certificate authorities to trust")
+	cmd.Flags().StringVar(&httpOptions.TLS.Cert, "tls-cert", "", "path to a PEM file with the client certificate for mutual TLS")
+	cmd.Flags().StringVar(&httpOptions.TLS.Key, "tls-key", "", "path to a PEM file with the client key for mutual TLS")
+	cmd.Flags().BoolVar(&httpOptions.TLS.InsecureSkipVerify, "tls-insecure-skip-verify", false, "skip the verification of the server certificate")
+	cmd.Flags().StringVar(&httpOptions.TLS.MinVersion, "tls-min-version", "", "minimum TLS version, one of '1.0', '1.1', '1.2', '1.3'")
+	cmd.Flags().StringVar(&httpOptions.Proxy, "http-proxy", "", "URL of the HTTP proxy, instead of the one from the environment")
+	cmd.Flags().StringArrayVar(&httpOptions.Headers, "http-header", nil, "header to add to each HTTP request, as 'Name: value' (repeatable)")
+	cmd.Flags().StringVar(&httpOptions.Username, "http-username", "", "username for HTTP basic auth")
+	cmd.Flags().StringVar(&httpOptions.Password, "http-password", "", "password for HTTP basic auth")
+	cmd.Flags().StringVar(&httpOptions.BearerToken, "http-bearer-token", "", "token for HTTP bearer auth")
+	cmd.Flags().StringVar(&httpOptions.APIKey, "http-api-key", "", "encoded API key for HTTP 'ApiKey' auth")
+}
+
+// addTelemetryFlags adds the flags for the OpenTelemetry instrumentation of the command, defaulting to the
+// standard OTEL_* environment variables
+func addTelemetryFlags(cmd *cobra.Command) {
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if len(serviceName) == 0 {
+		serviceName = "elastic-integration-corpus-generator-tool"
+	}
+
+	var headers []string
+	for _, header := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if name, value, ok := strings.Cut(header, "="); ok {
+			headers = append(headers, strings.TrimSpace(name)+": "+strings.TrimSpace(value))
+		}
+	}
+
+	cmd.Flags().StringVar(&otelEndpoint, "otel-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "base URL of the OTLP/HTTP receiver to export the traces and the metrics of the generation to, like 'http://localhost:4318'")
+	cmd.Flags().StringArrayVar(&otelHeaders, "otel-header", headers, "header to add to each OTLP export, as 'Name: value' (repeatable)")
+	cmd.Flags().StringVar(&otelServiceName, "otel-service-name", serviceName, "service name of the exported traces and metrics")
+	cmd.Flags().DurationVar(&otelInterval, "otel-interval", telemetry.DefaultInterval, "interval between the OTLP exports")
+}
+
+// startTelemetry returns the telemetry of the generation set with the flags, nil when there is no endpoint.
+// The returned function exports the last traces and metrics, printing a warning to w if the exports failed.
+func startTelemetry(w io.Writer) (*telemetry.Telemetry, func(), error) {
+	if len(otelEndpoint) == 0 {
+		return nil, func() {}, nil
+	}
+
+	client, err := transport.NewHTTPClient(transport.HTTPOptions{Headers: otelHeaders})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serviceVersion := version.Tag
+	if len(serviceVersion) == 0 {
+		serviceVersion = version.CommitHash
+	}
+
+	tel, err := telemetry.New(telemetry.Options{
+		Endpoint:       otelEndpoint,
+		ServiceName:    otelServiceName,
+		ServiceVersion: serviceVersion,
+		Interval:       otelInterval,
+		Client:         client,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tel, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := tel.Shutdown(ctx); err != nil {
+			fmt.Fprintln(w, "Warning:", err)
+		}
+	}, nil
+}
+
+// addElasticsearchFlags adds the flags for the Elasticsearch sink of the command
+func addElasticsearchFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&esOptions.URL, "es-url", "", "base URL of Elasticsearch to send the events to with the _bulk API, instead of writing a corpus file, like 'https://localhost:9200'")
+	cmd.Flags().StringVar(&esOptions.DataStream, "es-data-stream", "", "data stream, or index, the events are created in")
+	cmd.Flags().IntVar(&esOptions.BatchSize, "es-batch-size", sink.DefaultBatchSize, "number of events sent in each bulk request")
+	cmd.Flags().IntVar(&esOptions.Concurrency, "es-concurrency", sink.DefaultConcurrency, "number of bulk requests sent in parallel")
+	cmd.Flags().IntVar(&esOptions.MaxRetries, "es-max-retries", sink.DefaultMaxRetries, "number of retries of the events rejected with a 429 status, with exponential backoff; -1 for none")
+	cmd.Flags().DurationVar(&esOptions.Backoff, "es-backoff", sink.DefaultBackoff, "wait before the first retry, doubled at each following one")
+	cmd.Flags().DurationVar(&esOptions.VerifyInterval, "es-verify-interval", 0, "size of the time buckets, like '1m', the documents of each one are counted in after the generation, to compare them with the events indexed; 0 for no verification")
+	cmd.Flags().StringVar(&esOptions.VerifyField, "es-verify-field", sink.DefaultVerifyField, "date field of the time buckets of --es-verify-interval")
+	addElasticsearchAuthFlags(cmd)
+}
+
+// addElasticsearchAuthFlags adds the flags for the auth and the TLS settings with Elasticsearch of the command
+func addElasticsearchAuthFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&esAPIKey, "es-api-key", os.Getenv("ES_API_KEY"), "encoded API key of Elasticsearch, sent in the 'Authorization: ApiKey' header")
+	cmd.Flags().StringVar(&esTLS.CA, "es-tls-ca", "", "path to a PEM file with the certificate authorities of Elasticsearch to trust")
+	cmd.Flags().StringVar(&esTLS.Cert, "es-tls-cert", "", "path to a PEM file with the client certificate for mutual TLS with Elasticsearch")
+	cmd.Flags().StringVar(&esTLS.Key, "es-tls-key", "", "path to a PEM file with the client key for mutual TLS with Elasticsearch")
+	cmd.Flags().BoolVar(&esTLS.InsecureSkipVerify, "es-tls-insecure-skip-verify", false, "skip the verification of the certificate of Elasticsearch")
+	cmd.Flags().StringVar(&esTLS.MinVersion, "es-tls-min-version", "", "minimum TLS version with Elasticsearch, one of '1.0', '1.1', '1.2', '1.3'")
+}
+
+// newElasticsearchSink returns the Elasticsearch sink set with the flags, creating the events in dataStream,
+// or nil when there is no URL
+func newElasticsearchSink(dataStream string) (*sink.Elasticsearch, error) {
+	if len(esOptions.URL) == 0 {
+		return nil, nil
+	}
+
+	if len(outputFormat) > 0 && outputFormat != corpus.FormatText {
+		return nil, fmt.Errorf("--output-format %s cannot be used with --es-url", outputFormat)
+	}
+
+	if newline == corpus.NewlineCRLF {
+		return nil, errors.New("--newline crlf cannot be used with --es-url")
+	}
+
+	client, err := newElasticsearchClient()
+	if err != nil {
+		return nil, err
+	}
+
+	options := esOptions
+	options.DataStream = dataStream
+	options.Client = client
+	return sink.NewElasticsearch(options)
+}
+
+// newElasticsearchClient returns the HTTP client of Elasticsearch, with the settings of the --http-* and --tls-*
+// flags: the --es-tls-* flags replace the --tls-* ones when set, and --es-api-key replaces the auth of the --http-*
+// flags, that are meant for the package registry too
+func newElasticsearchClient() (*http.Client, error) {
+	options := httpOptions
+	if !esTLS.IsZero() {
+		options.TLS = esTLS
+	}
+
+	if len(esAPIKey) > 0 {
+		options.Username, options.Password, options.BearerToken, options.APIKey = "", "", "", esAPIKey
+	}
+
+	return transport.NewHTTPClient(options)
+}
+
+// newElasticsearchDocumentSink returns the Elasticsearch sink set with the flags for the commands generating
+// documents, that must set the data stream, or nil when there is no URL
+func newElasticsearchDocumentSink() (*sink.Elasticsearch, error) {
+	if len(esOptions.URL) > 0 && len(esOptions.DataStream) == 0 {
+		return nil, errors.New("you must provide a not empty --es-data-stream flag value with --es-url")
+	}
+
+	return newElasticsearchSink(esOptions.DataStream)
+}
+
+// generateToElasticsearch runs generate writing to the Elasticsearch sink es, in batches of the size of its bulk
+// requests passed to it, and prints to w the number of events indexed. The deadline and the spill directory of the
+// sink flags apply to each batch of the bulk requests sent in parallel. With --es-verify-interval the events indexed
+// are then verified.
+func generateToElasticsearch(ctx context.Context, w io.Writer, es *sink.Elasticsearch, generate func(w io.Writer, batchSize uint64) error) error {
+	err := writeToSink(ctx, w, es, es.BatchSize()*es.Concurrency(), func(sw *sinks.Writer) error {
+		return generate(sw, uint64(es.BatchSize()))
+	})
+	if err != nil {
+		return err
+	}
+
+	stats := es.Stats()
+	fmt.Fprintf(w, "Events indexed into %s: %d (%d retried)\n", es, stats.Events, stats.Retries)
+	if esOptions.VerifyInterval > 0 {
+		return verifyElasticsearch(ctx, w, es)
+	}
+
+	return nil
+}
+
+// verifyElasticsearch counts the documents of each time bucket of the events indexed into es, printing to w the
+// buckets whose count differs, and fails when there is any
+func verifyElasticsearch(ctx context.Context, w io.Writer, es *sink.Elasticsearch) error {
+	v, err := es.Verify(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot verify the events indexed: %w", err)
+	}
+
+	mismatches := v.Mismatches()
+	fmt.Fprintf(w, "Buckets of %s verified: %d, %d with a different count of documents; %d events without %s not verified\n", esOptions.VerifyInterval, len(v.Buckets), len(mismatches), v.Unbucketed, esOptions.VerifyField)
+	for _, bucket := range mismatches {
+		fmt.Fprintf(w, "Warning: %s from %s to %s: %d events indexed, %d documents counted\n", bucket.Index, bucket.Start.Format(time.RFC3339), bucket.End.Format(time.RFC3339), bucket.Indexed, bucket.Counted)
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("the documents counted differ from the events indexed in %d buckets", len(mismatches))
+	}
+
+	return nil
+}
+
+// addSinkFlags adds the flags for the registered sinks of the command
+func addSinkFlags(cmd *cobra.Command) {
+	usage := "name of a registered sink to send the events to, instead of writing a corpus file"
+	if names := sinks.Names(); len(names) > 0 {
+		usage += ", one of '" + strings.Join(names, "', '") + "'"
+	}
+
+	cmd.Flags().StringVar(&sinkName, "sink", "", usage)
+	cmd.Flags().StringArrayVar(&sinkOptions, "sink-option", nil, "option of the sink, as 'key=value' (repeatable)")
+	cmd.Flags().IntVar(&sinkBatchSize, "sink-batch-size", sinks.DefaultBatchSize, "number of events written to the sink in each batch")
+	cmd.Flags().DurationVar(&sinkBatchTimeout, "sink-batch-timeout", 0, "deadline of the delivery of each batch to the sink, like '30s'; 0 for none")
+	cmd.Flags().StringVar(&sinkSpillDir, "sink-spill-dir", "", "directory to persist the batches not delivered before --sink-batch-timeout to, retried once at the end, instead of failing")
+}
+
+// newRegisteredSink returns the registered sink set with the flags, or nil when there is no name
+func newRegisteredSink() (sinks.Sink, error) {
+	if len(sinkName) == 0 {
+		return nil, nil
+	}
+
+	if len(esOptions.URL) > 0 {
+		return nil, errors.New("--sink cannot be used with --es-url")
+	}
+
+	if len(outputFormat) > 0 && outputFormat != corpus.FormatText {
+		return nil, fmt.Errorf("--output-format %s cannot be used with --sink", outputFormat)
+	}
+
+	if newline == corpus.NewlineCRLF {
+		return nil, errors.New("--newline crlf cannot be used with --sink")
+	}
+
+	options := make(map[string]string, len(sinkOptions))
+	for _, option := range sinkOptions {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok || len(key) == 0 {
+			return nil, fmt.Errorf("wrong --sink-option flag: %s, it must be 'key=value'", option)
+		}
+
+		options[key] = value
+	}
+
+	return sinks.New(sinkName, options)
+}
+
+// generateToSink runs generate writing to the registered sink s, in batches of the size passed to it, and prints
+// to w the number of events written, and the ones lost when s is a sinks.LossReporter
+func generateToSink(ctx context.Context, w io.Writer, s sinks.Sink, generate func(w io.Writer, batchSize uint64) error) error {
+	err := writeToSink(ctx, w, s, sinkBatchSize, func(sw *sinks.Writer) error {
+		return generate(sw, uint64(sw.BatchSize()))
+	})
+	if err != nil {
+		return err
+	}
+
+	stats := s.Stats()
+	fmt.Fprintf(w, "Events written to sink %s: %d (%d retried)\n", sinkName, stats.Events, stats.Retries)
+	if reporter, ok := s.(sinks.LossReporter); ok {
+		if loss := reporter.Loss(); loss.Intended > 0 {
+			fmt.Fprintf(w, "Datagrams sent to sink %s: %d of %d, %d dropped by the send buffers, estimated loss %.2f%%\n", sinkName, loss.Sent, loss.Intended, loss.BufferDrops, loss.Rate()*100)
+		}
+	}
+
+	return nil
+}
+
+// writeToSink runs generate writing to s through a writer of batches of batchSize events, with the deadline and the
+// spill directory of the flags, and prints to w a warning when batches have been spilled. With --latency-field the
+// events are stamped with the time they are sent, and their latency is printed to w during the generation, when
+// there is an Elasticsearch to query back.
+func writeToSink(ctx context.Context, w io.Writer, s sinks.Sink, batchSize int, generate func(sw *sinks.Writer) error) error {
+	opts := []sinks.WriterOption{sinks.WithBatchTimeout(sinkBatchTimeout)}
+	if len(sinkSpillDir) > 0 {
+		opts = append(opts, sinks.WithSpillDir(sinkSpillDir))
+	}
+
+	if len(latencyField) > 0 {
+		opts = append(opts, sinks.WithSendTimestamp(latencyField))
+	}
+
+	probe, err := newLatencyProbe()
+	if err != nil {
+		return err
+	}
+
+	sw, err := sinks.NewWriter(ctx, s, batchSize, opts...)
+	if err != nil {
+		return err
+	}
+
+	stopLatency := reportLatency(ctx, w, probe)
+	err = generate(sw)
+	if closeErr := sw.Close(); err == nil {
+		err = closeErr
+	}
+
+	stopLatency()
+
+	if spilled := sw.SpillStats(); spilled.Batches > 0 {
+		fmt.Fprintf(w, "Warning: %d batches of %d events not delivered in time have been spilled to %s, %d delivered at the end\n", spilled.Batches, spilled.Events, sinkSpillDir, spilled.Delivered)
+	}
+
+	if probe != nil && err == nil {
+		printLatency(ctx, w, probe)
+	}
+
+	return err
+}
+
+// addLatencyFlags adds the flags for the measure of the end-to-end latency of the events sent to a sink
+func addLatencyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&latencyField, "latency-field", "", "field to embed in each JSON event sent to a sink, with the time it is sent, like 'corpus.sent_at', to measure the end-to-end latency")
+	cmd.Flags().StringVar(&latencyESURL, "latency-es-url", "", "base URL of the Elasticsearch to query back the documents of the events for their latency, the one of --es-url when not set")
+	cmd.Flags().StringVar(&latencyIndex, "latency-index", "", "data stream, index or index pattern to query back the documents of the events from, the one of --es-data-stream when not set")
+	cmd.Flags().StringVar(&latencyIngestedField, "latency-ingested-field", sink.DefaultLatencyIngestedField, "date field of the time each document has been ingested")
+	cmd.Flags().DurationVar(&latencyInterval, "latency-interval", sink.DefaultLatencyInterval, "interval between the measures of the latency printed during the generation")
+}
+
+// newLatencyProbe returns the probe measuring the latency of the events sent with --latency-field, or nil when
+// there is no Elasticsearch to query back
+func newLatencyProbe() (*sink.LatencyProbe, error) {
+	if len(latencyField) == 0 {
+		return nil, nil
+	}
+
+	esURL, index := latencyESURL, latencyIndex
+	if len(esURL) == 0 {
+		esURL = esOptions.URL
+	}
+
+	if len(esURL) == 0 {
+		return nil, nil
+	}
+
+	if len(index) == 0 {
+		index = esOptions.DataStream
+	}
+
+	if len(index) == 0 {
+		return nil, errors.New("you must provide a not empty --latency-index flag value to query back the latency")
+	}
+
+	if latencyInterval <= 0 {
+		return nil, errors.New("--latency-interval must be positive")
+	}
+
+	client, err := newElasticsearchClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return sink.NewLatencyProbe(sink.LatencyOptions{
+		URL:           esURL,
+		Index:         index,
+		SentField:     latencyField,
+		IngestedField: latencyIngestedField,
+		Client:        client,
+	})
+}
+
+// reportLatency prints to w the latency measured by probe every --latency-interval, until the function returned is
+// called; it does nothing when probe is nil
+func reportLatency(ctx context.Context, w io.Writer, probe *sink.LatencyProbe) func() {
+	if probe == nil {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(latencyInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				printLatency(ctx, w, probe)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// printLatency prints to w the latency of the documents ingested so far, or a warning when it cannot be measured
+func printLatency(ctx context.Context, w io.Writer, probe *sink.LatencyProbe) {
+	latency, err := probe.Measure(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Fprintln(w, "Warning: cannot measure the latency:", err)
+		}
+
+		return
+	}
+
+	fmt.Fprintf(w, "Latency of %d documents ingested: p50 %s, p90 %s, p99 %s, max %s\n", latency.Documents, latency.P50, latency.P90, latency.P99, latency.Max)
+}
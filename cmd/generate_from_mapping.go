@@ -0,0 +1,163 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/transport"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/multierr"
+)
+
+var fieldsOutput string
+
+func GenerateFromMappingCmd() *cobra.Command {
+	generateFromMappingCmd := &cobra.Command{
+		Use:     "generate-from-mapping es-url index",
+		Example: "generate-from-mapping https://localhost:9200 logs-nginx.access-default --http-api-key $API_KEY -t 1000",
+		Short:   "Generate a corpus from the mapping of an index",
+		Long:    "Generate a corpus of documents with the fields of the mapping of an existing index, data stream or index pattern, read with the get mapping API of Elasticsearch, for the indices whose fields definition is not available",
+		Args: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			if len(args) != 2 {
+				return errors.New("you must pass the URL of Elasticsearch and the index")
+			}
+
+			if args[0] == "" {
+				errs = append(errs, errors.New("you must provide a not empty Elasticsearch URL argument"))
+			}
+
+			if args[1] == "" {
+				errs = append(errs, errors.New("you must provide a not empty index argument"))
+			}
+
+			if len(errs) > 0 {
+				return multierr.Combine(errs...)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			esURL, index := args[0], args[1]
+
+			fs := afero.NewOsFs()
+			location := viper.GetString("corpora_location")
+
+			cfg, err := config.LoadConfig(fs, configFile)
+			if err != nil {
+				return err
+			}
+
+			printConfigWarnings(cmd.ErrOrStderr(), cfg)
+
+			fc, err := corpus.NewGenerator(cfg, fs, location)
+			if err != nil {
+				return err
+			}
+
+			httpClient, err := transport.NewHTTPClient(httpOptions)
+			if err != nil {
+				return err
+			}
+
+			timeNow, err := getTimeNowFromFlag(timeNowAsString)
+			if err != nil {
+				return err
+			}
+
+			tel, stopTelemetry, err := startTelemetry(cmd.ErrOrStderr())
+			if err != nil {
+				return err
+			}
+			defer stopTelemetry()
+
+			fc = fc.WithHTTPClient(httpClient).WithMaxWriteMBps(maxWriteMBps).WithTelemetry(tel).WithConfigSeed(!cmd.Flags().Changed("seed"))
+			fc, err = fc.WithMaxEventSize(maxEventBytes, oversizeEvents)
+			if err != nil {
+				return err
+			}
+
+			fc, err = fc.WithRate(rate)
+			if err != nil {
+				return err
+			}
+
+			fc, err = withCorpusFile(fc)
+			if err != nil {
+				return err
+			}
+
+			// the documents are sent to the data stream of the flag, never to the index the mapping is read from
+			es, err := newElasticsearchDocumentSink()
+			if err != nil {
+				return err
+			}
+
+			if es != nil {
+				err = generateToElasticsearch(cmd.Context(), cmd.OutOrStdout(), es, func(w io.Writer, batchSize uint64) error {
+					return fc.WithBatchSize(batchSize).GenerateFromMappingTo(w, esURL, index, fieldsOutput, totEvents, timeNow, randSeed)
+				})
+				if err != nil {
+					return err
+				}
+
+				printOversizeEvents(cmd.ErrOrStderr(), fc)
+				return nil
+			}
+
+			registered, err := newRegisteredSink()
+			if err != nil {
+				return err
+			}
+
+			if registered != nil {
+				err = generateToSink(cmd.Context(), cmd.OutOrStdout(), registered, func(w io.Writer, batchSize uint64) error {
+					return fc.WithBatchSize(batchSize).GenerateFromMappingTo(w, esURL, index, fieldsOutput, totEvents, timeNow, randSeed)
+				})
+				if err != nil {
+					return err
+				}
+
+				printOversizeEvents(cmd.ErrOrStderr(), fc)
+				return nil
+			}
+
+			payloadFilename, err := fc.GenerateFromMapping(esURL, index, fieldsOutput, totEvents, timeNow, randSeed)
+			if err != nil {
+				return err
+			}
+
+			printOversizeEvents(cmd.ErrOrStderr(), fc)
+			recordCorpus(cmd.ErrOrStderr(), fc, payloadFilename, index)
+			fmt.Fprintln(cmd.OutOrStdout(), "File generated:", payloadFilename)
+
+			return nil
+		},
+	}
+
+	generateFromMappingCmd.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings")
+	generateFromMappingCmd.Flags().StringVarP(&fieldsOutput, "fields-output", "o", "", "file to write the fields synthesized from the mapping to, as a fields definition for `generate-with-template`")
+	generateFromMappingCmd.Flags().Uint64VarP(&totEvents, "tot-events", "t", 1, "total events of the corpus to generate")
+	generateFromMappingCmd.Flags().StringVarP(&timeNowAsString, "now", "n", "", "time to use for generation based on now (`date` type)")
+	generateFromMappingCmd.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand, overriding the `seed` of the config file")
+	generateFromMappingCmd.Flags().Float64Var(&maxWriteMBps, "max-write-mbps", 0, "maximum MB per second written to the corpus file, 0 for unlimited")
+	generateFromMappingCmd.Flags().StringVar(&rate, "rate", "", "events emitted per unit of time, like '1000/s', '60000/m' or '3600000/h', instead of as fast as possible")
+	addEventSizeFlags(generateFromMappingCmd)
+	addCorpusFileFlags(generateFromMappingCmd)
+	addTelemetryFlags(generateFromMappingCmd)
+	addElasticsearchFlags(generateFromMappingCmd)
+	addSinkFlags(generateFromMappingCmd)
+	addLatencyFlags(generateFromMappingCmd)
+	addHTTPFlags(generateFromMappingCmd)
+
+	return generateFromMappingCmd
+}
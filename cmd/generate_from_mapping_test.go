@@ -0,0 +1,61 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateFromMappingCmd_sink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/metrics-app-default/_mapping", r.URL.Path)
+		require.Equal(t, "ApiKey secret", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"metrics-app-default":{"mappings":{"properties":{"host":{"properties":{"name":{"type":"keyword"}}},"cpu":{"type":"double"}}}}}`))
+	}))
+	defer server.Close()
+
+	collect := &collectSink{}
+	sinks.Register("test-collect-mapping", func(options map[string]string) (sinks.Sink, error) {
+		return collect, nil
+	})
+
+	fieldsPath := filepath.Join(t.TempDir(), "fields.yml")
+	command := cmd.GenerateFromMappingCmd()
+
+	b := new(bytes.Buffer)
+	command.SetOut(b)
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{server.URL, "metrics-app-default", "-t", "3", "-o", fieldsPath, "--http-api-key", "secret", "--sink", "test-collect-mapping"})
+
+	err := command.Execute()
+	require.NoError(t, err)
+	require.Contains(t, b.String(), "Events written to sink test-collect-mapping: 3")
+	require.Len(t, collect.events, 3)
+	require.Contains(t, string(collect.events[0]), `"host.name":`)
+
+	fieldsDefinition, err := os.ReadFile(fieldsPath)
+	require.NoError(t, err)
+	require.Equal(t, "- name: \"cpu\"\n  type: \"double\"\n- name: \"host.name\"\n  type: \"keyword\"\n", string(fieldsDefinition))
+}
+
+func TestGenerateFromMappingCmd_noArgs(t *testing.T) {
+	command := cmd.GenerateFromMappingCmd()
+
+	command.SetOut(new(bytes.Buffer))
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{"http://localhost:9200"})
+
+	err := command.Execute()
+	require.ErrorContains(t, err, "you must pass the URL of Elasticsearch and the index")
+}
@@ -0,0 +1,148 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/transport"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func GenerateScenarioCmd() *cobra.Command {
+	generateScenarioCmd := &cobra.Command{
+		Use:     "generate-scenario scenario-path",
+		Example: "generate-scenario ./scenario.yml -t 100000",
+		Short:   "Generate a corpus of several data streams",
+		Long:    "Generate a corpus interleaving the events of the data streams listed in a scenario file, each with its share of the events, on a shared timeline",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("you must pass the path of the scenario file")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs := afero.NewOsFs()
+			location := viper.GetString("corpora_location")
+
+			scenario, err := corpus.LoadScenario(fs, args[0])
+			if err != nil {
+				return err
+			}
+
+			for _, cfg := range scenario.Configs() {
+				printConfigWarnings(cmd.ErrOrStderr(), cfg)
+			}
+
+			// the -t flag overrides the events of the scenario file
+			if !cmd.Flags().Changed("tot-events") && scenario.TotEvents > 0 {
+				totEvents = scenario.TotEvents
+			}
+
+			fc, err := corpus.NewGenerator(config.Config{}, fs, location)
+			if err != nil {
+				return err
+			}
+
+			httpClient, err := transport.NewHTTPClient(httpOptions)
+			if err != nil {
+				return err
+			}
+
+			timeNow, err := getTimeNowFromFlag(timeNowAsString)
+			if err != nil {
+				return err
+			}
+
+			tel, stopTelemetry, err := startTelemetry(cmd.ErrOrStderr())
+			if err != nil {
+				return err
+			}
+			defer stopTelemetry()
+
+			fc = fc.WithHTTPClient(httpClient).WithPackageCacheDir(packageCacheDir()).WithMaxWriteMBps(maxWriteMBps).WithTelemetry(tel).WithConfigSeed(!cmd.Flags().Changed("seed"))
+			fc = fc.WithPackageConfig(!noPackageConfig)
+			fc, err = fc.WithMaxEventSize(maxEventBytes, oversizeEvents)
+			if err != nil {
+				return err
+			}
+
+			fc, err = fc.WithRate(rate)
+			if err != nil {
+				return err
+			}
+
+			fc, err = withCorpusFile(fc)
+			if err != nil {
+				return err
+			}
+
+			registered, err := newRegisteredSink()
+			if err != nil {
+				return err
+			}
+
+			var result corpus.ScenarioResult
+			if registered != nil {
+				// the streams are generated on their own before being interleaved, so their events don't follow the
+				// batches of the sink, and the whole generation of each stream is one batch
+				err = generateToSink(cmd.Context(), cmd.OutOrStdout(), registered, func(w io.Writer, _ uint64) error {
+					result, err = fc.GenerateScenarioTo(w, packageRegistryBaseURL, scenario, totEvents, timeNow, randSeed)
+					return err
+				})
+				if err != nil {
+					return err
+				}
+
+				printScenarioStreams(cmd.OutOrStdout(), scenario, result)
+				printOversizeEvents(cmd.ErrOrStderr(), fc)
+				return nil
+			}
+
+			payloadFilename, result, err := fc.GenerateScenario(packageRegistryBaseURL, scenario, totEvents, timeNow, randSeed)
+			if err != nil {
+				return err
+			}
+
+			printScenarioStreams(cmd.OutOrStdout(), scenario, result)
+			printOversizeEvents(cmd.ErrOrStderr(), fc)
+			recordCorpus(cmd.ErrOrStderr(), fc, payloadFilename, "scenario "+scenario.Name)
+			fmt.Fprintln(cmd.OutOrStdout(), "File generated:", payloadFilename)
+
+			return nil
+		},
+	}
+
+	generateScenarioCmd.Flags().StringVarP(&packageRegistryBaseURL, "package-registry-base-url", "r", "https://epr.elastic.co/", "base url of the package registry with schema, for the `package` streams")
+	addPackageCacheFlags(generateScenarioCmd)
+	generateScenarioCmd.Flags().BoolVar(&noPackageConfig, "no-package-config", false, "do not apply the default config of the data streams in the packages to the `package` streams without `config_file`")
+	generateScenarioCmd.Flags().Uint64VarP(&totEvents, "tot-events", "t", 1, "total events of the corpus to generate, of all the streams, overriding the `tot_events` of the scenario file")
+	generateScenarioCmd.Flags().StringVarP(&timeNowAsString, "now", "n", "", "time to use for generation based on now (`date` type), the same for all the streams")
+	generateScenarioCmd.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand, plus the index of each stream, overriding the `seed` of the config files")
+	generateScenarioCmd.Flags().Float64Var(&maxWriteMBps, "max-write-mbps", 0, "maximum MB per second written to the corpus file, 0 for unlimited")
+	generateScenarioCmd.Flags().StringVar(&rate, "rate", "", "events emitted per unit of time, like '1000/s', '60000/m' or '3600000/h', instead of as fast as possible")
+	addEventSizeFlags(generateScenarioCmd)
+	addCorpusFileFlags(generateScenarioCmd)
+	addTelemetryFlags(generateScenarioCmd)
+	addSinkFlags(generateScenarioCmd)
+	addLatencyFlags(generateScenarioCmd)
+	addHTTPFlags(generateScenarioCmd)
+
+	return generateScenarioCmd
+}
+
+// printScenarioStreams prints to w the number of events of each stream of the scenario
+func printScenarioStreams(w io.Writer, scenario corpus.Scenario, result corpus.ScenarioResult) {
+	for i, stream := range scenario.Streams {
+		fmt.Fprintf(w, "Stream %s: %d events\n", stream.Name, result.Events[i])
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateScenarioCmd_sink(t *testing.T) {
+	collect := &collectSink{}
+	sinks.Register("test-collect-scenario", func(options map[string]string) (sinks.Sink, error) {
+		return collect, nil
+	})
+
+	scenarioPath := filepath.Join(t.TempDir(), "scenario.yml")
+	scenario := "tot_events: 10\nstreams:\n  - catalog: aws.sqs\n    ratio: 4\n  - catalog: nginx.access\n    ratio: 1\n"
+	require.NoError(t, os.WriteFile(scenarioPath, []byte(scenario), 0644))
+
+	command := cmd.GenerateScenarioCmd()
+
+	b := new(bytes.Buffer)
+	command.SetOut(b)
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{scenarioPath, "--sink", "test-collect-scenario"})
+
+	err := command.Execute()
+	require.NoError(t, err)
+	require.Contains(t, b.String(), "Events written to sink test-collect-scenario: 10")
+	require.Contains(t, b.String(), "Stream aws.sqs-gotext.tpl: 8 events\nStream nginx.access-gotext.tpl: 2 events\n")
+	require.Len(t, collect.events, 10)
+}
+
+func TestGenerateScenarioCmd_notValid(t *testing.T) {
+	scenarioPath := filepath.Join(t.TempDir(), "scenario.yml")
+	require.NoError(t, os.WriteFile(scenarioPath, []byte("streams: []\n"), 0644))
+
+	command := cmd.GenerateScenarioCmd()
+
+	command.SetOut(new(bytes.Buffer))
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{scenarioPath})
+
+	err := command.Execute()
+	require.ErrorContains(t, err, "a scenario must list at least one stream")
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/multierr"
+	"io"
+	"path/filepath"
 )
 
 var templateType string
@@ -56,6 +58,8 @@ func GenerateWithTemplateCmd() *cobra.Command {
 				return err
 			}
 
+			printConfigWarnings(cmd.ErrOrStderr(), cfg)
+
 			fc, err := corpus.NewGeneratorWithTemplate(cfg, fs, location, templateType)
 			if err != nil {
 				return err
@@ -66,11 +70,80 @@ func GenerateWithTemplateCmd() *cobra.Command {
 				return err
 			}
 
+			reload, stopReload := reloadConfigOnSIGHUP(fs, configFile)
+			defer stopReload()
+
+			tel, stopTelemetry, err := startTelemetry(cmd.ErrOrStderr())
+			if err != nil {
+				return err
+			}
+			defer stopTelemetry()
+
+			fc = fc.WithConfigReload(reload).WithWarnings(cmd.ErrOrStderr()).WithMaxWriteMBps(maxWriteMBps).WithStateFile(stateFile).WithReportFile(reportFile).WithTelemetry(tel).WithConfigSeed(!cmd.Flags().Changed("seed"))
+			fc, err = fc.WithMaxEventSize(maxEventBytes, oversizeEvents)
+			if err != nil {
+				return err
+			}
+
+			fc, err = fc.WithRate(rate)
+			if err != nil {
+				return err
+			}
+
+			fc, err = fc.WithMaxDuration(maxDuration)
+			if err != nil {
+				return err
+			}
+
+			fc, err = withCorpusFile(fc)
+			if err != nil {
+				return err
+			}
+
+			es, err := newElasticsearchDocumentSink()
+			if err != nil {
+				return err
+			}
+
+			if es != nil {
+				err = generateToElasticsearch(cmd.Context(), cmd.OutOrStdout(), es, func(w io.Writer, batchSize uint64) error {
+					return fc.WithBatchSize(batchSize).GenerateWithTemplateTo(w, templatePath, fieldsDefinitionPath, totEvents, timeNow, randSeed)
+				})
+				if err != nil {
+					return err
+				}
+
+				printOversizeEvents(cmd.ErrOrStderr(), fc)
+				printTruncation(cmd.ErrOrStderr(), fc)
+				return nil
+			}
+
+			registered, err := newRegisteredSink()
+			if err != nil {
+				return err
+			}
+
+			if registered != nil {
+				err = generateToSink(cmd.Context(), cmd.OutOrStdout(), registered, func(w io.Writer, batchSize uint64) error {
+					return fc.WithBatchSize(batchSize).GenerateWithTemplateTo(w, templatePath, fieldsDefinitionPath, totEvents, timeNow, randSeed)
+				})
+				if err != nil {
+					return err
+				}
+
+				printOversizeEvents(cmd.ErrOrStderr(), fc)
+				printTruncation(cmd.ErrOrStderr(), fc)
+				return nil
+			}
+
 			payloadFilename, err := fc.GenerateWithTemplate(templatePath, fieldsDefinitionPath, totEvents, timeNow, randSeed)
 			if err != nil {
 				return err
 			}
 
+			printOversizeEvents(cmd.ErrOrStderr(), fc)
+			printTruncation(cmd.ErrOrStderr(), fc)
+			recordCorpus(cmd.ErrOrStderr(), fc, payloadFilename, filepath.Base(templatePath))
 			fmt.Println("File generated:", payloadFilename)
 
 			return nil
@@ -81,7 +154,19 @@ func GenerateWithTemplateCmd() *cobra.Command {
 	generateWithTemplateCmd.Flags().StringVarP(&templateType, "template-type", "y", "placeholder", "either 'placeholder' or 'gotext'")
 	generateWithTemplateCmd.Flags().Uint64VarP(&totEvents, "tot-events", "t", 1, "total events of the corpus to generate")
 	generateWithTemplateCmd.Flags().StringVarP(&timeNowAsString, "now", "n", "", "time to use for generation based on now (`date` type)")
-	generateWithTemplateCmd.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand")
+	generateWithTemplateCmd.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand, overriding the `seed` of the config file")
+	generateWithTemplateCmd.Flags().Float64Var(&maxWriteMBps, "max-write-mbps", 0, "maximum MB per second written to the corpus file, 0 for unlimited")
+	generateWithTemplateCmd.Flags().StringVar(&rate, "rate", "", "events emitted per unit of time, like '1000/s', '60000/m' or '3600000/h', instead of as fast as possible; with -t 0 the events are emitted until interrupted")
+	generateWithTemplateCmd.Flags().DurationVar(&maxDuration, "max-duration", 0, "time budget of the generation, like '10m', after which it stops with the events generated so far, overriding the `max_duration` of the config file; 0 for the one of the config file, if any")
+	generateWithTemplateCmd.Flags().StringVar(&stateFile, "state-file", "", "file to load the state of the generation from, if it exists, and to save it to at the end")
+	generateWithTemplateCmd.Flags().StringVar(&reportFile, "report-file", "", "file to write an HTML report of the generation to, with charts of the event rate and of the fields")
+	addEventSizeFlags(generateWithTemplateCmd)
+	addCorpusFileFlags(generateWithTemplateCmd)
+	addTelemetryFlags(generateWithTemplateCmd)
+	addElasticsearchFlags(generateWithTemplateCmd)
+	addSinkFlags(generateWithTemplateCmd)
+	addLatencyFlags(generateWithTemplateCmd)
+	addHTTPFlags(generateWithTemplateCmd)
 
 	return generateWithTemplateCmd
 }
@@ -0,0 +1,186 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateWithTemplateCmd_sinkBatchSize(t *testing.T) {
+	collect := &collectSink{}
+	sinks.Register("test-collect-batch", func(options map[string]string) (sinks.Sink, error) {
+		return collect, nil
+	})
+
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.tpl")
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"path":"{{generate "path"}}","batch":{{batchIndex}}}`), 0600))
+	fieldsPath := filepath.Join(dir, "fields.yml")
+	require.NoError(t, os.WriteFile(fieldsPath, []byte("- name: path\n  type: keyword\n"), 0600))
+	configPath := filepath.Join(dir, "config.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte("fields:\n  - name: path\n    per_batch_constant: true\n"), 0600))
+
+	command := cmd.GenerateWithTemplateCmd()
+
+	b := new(bytes.Buffer)
+	command.SetOut(b)
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{templatePath, fieldsPath, "-c", configPath, "-y", "gotext", "-t", "10", "--sink", "test-collect-batch", "--sink-batch-size", "4"})
+
+	err := command.Execute()
+	require.NoError(t, err)
+	require.Len(t, collect.events, 10)
+	require.Equal(t, uint64(3), collect.batches)
+
+	// the events of each batch written to the sink share the value of the per_batch_constant field
+	paths := make(map[float64]string)
+	for i, raw := range collect.events {
+		var event struct {
+			Path  string  `json:"path"`
+			Batch float64 `json:"batch"`
+		}
+		require.NoError(t, json.Unmarshal(raw, &event))
+		require.Equal(t, float64(i/4), event.Batch)
+		if path, ok := paths[event.Batch]; ok {
+			require.Equal(t, path, event.Path)
+		}
+		paths[event.Batch] = event.Path
+	}
+	require.Len(t, paths, 3)
+}
+
+func TestGenerateWithTemplateCmd_esHTTPFlags(t *testing.T) {
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+		body, _ := io.ReadAll(r.Body)
+		n := strings.Count(string(body), "\n") / 2
+		_, _ = w.Write([]byte(`{"items":[` + strings.TrimSuffix(strings.Repeat(`{"create":{"status":201}},`, n), ",") + `]}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.tpl")
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"path":"{{.path}}"}`), 0600))
+	fieldsPath := filepath.Join(dir, "fields.yml")
+	require.NoError(t, os.WriteFile(fieldsPath, []byte("- name: path\n  type: keyword\n"), 0600))
+
+	testCases := []struct {
+		scenario      string
+		args          []string
+		expectedAuth  string
+		expectedBasic bool
+	}{
+		{scenario: "http flags", args: []string{"--http-username", "elastic", "--http-password", "changeme", "--es-api-key", ""}, expectedBasic: true},
+		{scenario: "es api key", args: []string{"--http-username", "elastic", "--http-password", "changeme", "--es-api-key", "key"}, expectedAuth: "ApiKey key"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			headers = nil
+			command := cmd.GenerateWithTemplateCmd()
+
+			command.SetOut(new(bytes.Buffer))
+			command.SetErr(new(bytes.Buffer))
+			command.SetArgs(append([]string{templatePath, fieldsPath, "-t", "3", "--es-url", server.URL, "--es-data-stream", "logs-test-default", "--http-header", "X-Custom: value"}, testCase.args...))
+
+			require.NoError(t, command.Execute())
+			require.NotEmpty(t, headers)
+
+			// the proxy, the headers and the auth of the --http-* flags apply to Elasticsearch too
+			require.Equal(t, "value", headers[0].Get("X-Custom"))
+			if testCase.expectedBasic {
+				require.True(t, strings.HasPrefix(headers[0].Get("Authorization"), "Basic "))
+			} else {
+				require.Equal(t, testCase.expectedAuth, headers[0].Get("Authorization"))
+			}
+		})
+	}
+}
+
+func TestGenerateWithTemplateCmd_esTLSFlags(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.tpl")
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"path":"{{.path}}"}`), 0600))
+	fieldsPath := filepath.Join(dir, "fields.yml")
+	require.NoError(t, os.WriteFile(fieldsPath, []byte("- name: path\n  type: keyword\n"), 0600))
+
+	testCases := []struct {
+		scenario string
+		args     []string
+		expected string
+	}{
+		{scenario: "min version", args: []string{"--es-tls-min-version", "0.9"}, expected: "TLS min version must be one of '1.0', '1.1', '1.2', '1.3', got '0.9'"},
+		{scenario: "cert without key", args: []string{"--es-tls-cert", filepath.Join(dir, "cert.pem")}, expected: "both a TLS certificate and key must be provided for client authentication"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			command := cmd.GenerateWithTemplateCmd()
+
+			command.SetOut(new(bytes.Buffer))
+			command.SetErr(new(bytes.Buffer))
+			command.SetArgs(append([]string{templatePath, fieldsPath, "-t", "3", "--es-url", "https://localhost:9200", "--es-data-stream", "logs-test-default"}, testCase.args...))
+
+			require.EqualError(t, command.Execute(), testCase.expected)
+		})
+	}
+}
+
+func TestGenerateWithTemplateCmd_latency(t *testing.T) {
+	var documents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/logs-test-default/_bulk":
+			lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+			for i := 1; i < len(lines); i += 2 {
+				documents = append(documents, lines[i])
+			}
+			_, _ = w.Write([]byte(`{"items":[` + strings.TrimSuffix(strings.Repeat(`{"create":{"status":201}},`, len(lines)/2), ",") + `]}`))
+		case "/logs-test-default/_search":
+			_, _ = w.Write([]byte(`{"aggregations":{"percentiles":{"values":{"50.0":100.0,"90.0":200.0,"99.0":300.0}},"max":{"value":400.0},"count":{"value":3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.tpl")
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"path":"{{.path}}"}`), 0600))
+	fieldsPath := filepath.Join(dir, "fields.yml")
+	require.NoError(t, os.WriteFile(fieldsPath, []byte("- name: path\n  type: keyword\n"), 0600))
+
+	command := cmd.GenerateWithTemplateCmd()
+
+	b := new(bytes.Buffer)
+	command.SetOut(b)
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{templatePath, fieldsPath, "-t", "3", "--es-url", server.URL, "--es-data-stream", "logs-test-default", "--es-api-key", "", "--latency-field", "corpus.sent_at"})
+
+	require.NoError(t, command.Execute())
+	require.Len(t, documents, 3)
+	for _, document := range documents {
+		var event map[string]string
+		require.NoError(t, json.Unmarshal([]byte(document), &event))
+		require.Contains(t, event, "corpus.sent_at")
+		require.Contains(t, event, "path")
+	}
+
+	require.Contains(t, b.String(), "Latency of 3 documents ingested: p50 100ms, p90 200ms, p99 300ms, max 400ms\n")
+}
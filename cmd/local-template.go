@@ -48,6 +48,8 @@ func TemplateCmd() *cobra.Command {
 				return err
 			}
 
+			printConfigWarnings(cmd.ErrOrStderr(), cfg)
+
 			var errs []error
 			datasetFolder := fmt.Sprintf("%s.%s", args[0], args[1])
 			schema := fmt.Sprintf("schema-%s", flagSchema)
@@ -80,6 +82,8 @@ func TemplateCmd() *cobra.Command {
 				return err
 			}
 
+			fc = fc.WithConfigSeed(!cmd.Flags().Changed("seed"))
+
 			timeNow, err := getTimeNowFromFlag(timeNowAsString)
 			if err != nil {
 				return err
@@ -102,6 +106,6 @@ func TemplateCmd() *cobra.Command {
 	command.Flags().StringVarP(&flagSchema, "schema", "", "b", "schema to generate data for; valid values: a, b")
 	command.Flags().StringVarP(&timeNowAsString, "now", "n", "", "time to use for generation based on now (`date` type)")
 
-	command.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand")
+	command.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand, overriding the `seed` of the config file")
 	return command
 }
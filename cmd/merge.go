@@ -0,0 +1,70 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var mergeOutput string
+var mergeTimestampField string
+
+func MergeCmd() *cobra.Command {
+	mergeCmd := &cobra.Command{
+		Use:     "merge corpus-path corpus-path...",
+		Example: "merge /path/to/corpora/1649330390-aws-dynamodb-1.14.0.ndjson /path/to/corpora/1684304483-aws.vpcflow-placeholder.tpl",
+		Short:   "Merge corpora",
+		Long:    "Combine several corpora into one, interleaving their events by timestamp, so that separately generated datasets can be replayed as a single mixed feed",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 2 {
+				return errors.New("you must pass the paths of at least two corpora to merge")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			outputPath := mergeOutput
+			if len(outputPath) == 0 {
+				outputPath = filepath.Join(viper.GetString("corpora_location"), fmt.Sprintf("%d-merged.ndjson", time.Now().Unix()))
+			}
+
+			fs := afero.NewOsFs()
+			result, err := corpus.Merge(fs, args, outputPath, mergeTimestampField)
+			if err != nil {
+				return err
+			}
+
+			var totEvents uint64
+			sources := make([]string, 0, len(args))
+			for i, inputPath := range args {
+				totEvents += result.Events[i]
+				sources = append(sources, filepath.Base(inputPath))
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Merged %d events from %d corpora\n", totEvents, len(args))
+			fmt.Fprintln(cmd.OutOrStdout(), "File generated:", outputPath)
+
+			recordIndexEntry(cmd.OutOrStdout(), outputPath, func(filePath string) (corpus.IndexEntry, error) {
+				return corpus.NewIndexEntry(fs, filePath, "merge of "+strings.Join(sources, ", "), "", totEvents, corpus.FormatText, strings.HasSuffix(outputPath, ".gz"))
+			})
+
+			return nil
+		},
+	}
+
+	mergeCmd.Flags().StringVar(&mergeTimestampField, "timestamp-field", "@timestamp", "field with the timestamp of the events, either an RFC 3339 date or milliseconds since the epoch")
+	mergeCmd.Flags().StringVarP(&mergeOutput, "output", "o", "", "path of the merged corpus, compressed with gzip when it ends in '.gz'; by default in the corpora location")
+
+	return mergeCmd
+}
@@ -0,0 +1,54 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeCmd(t *testing.T) {
+	dir := t.TempDir()
+	logsPath := filepath.Join(dir, "logs.ndjson")
+	metricsPath := filepath.Join(dir, "metrics.ndjson")
+	outputPath := filepath.Join(dir, "merged.ndjson")
+
+	require.NoError(t, os.WriteFile(logsPath, []byte(`{"@timestamp":1000,"n":"logs"}`+"\n"), 0644))
+	require.NoError(t, os.WriteFile(metricsPath, []byte(`{"@timestamp":500,"n":"metrics"}`+"\n"), 0644))
+
+	viper.Set("corpora_index", filepath.Join(dir, "corpora.json"))
+	t.Cleanup(viper.Reset)
+
+	command := cmd.MergeCmd()
+	b := new(bytes.Buffer)
+	command.SetOut(b)
+	command.SetArgs([]string{logsPath, metricsPath, "-o", outputPath})
+	require.NoError(t, command.Execute())
+	require.Contains(t, b.String(), "Merged 2 events from 2 corpora")
+
+	merged, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	require.Equal(t, `{"@timestamp":500,"n":"metrics"}`+"\n"+`{"@timestamp":1000,"n":"logs"}`+"\n", string(merged))
+
+	index, err := os.ReadFile(filepath.Join(dir, "corpora.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(index), "merge of logs.ndjson, metrics.ndjson")
+}
+
+func TestMergeCmd_oneCorpus(t *testing.T) {
+	command := cmd.MergeCmd()
+	command.SetOut(new(bytes.Buffer))
+	command.SetErr(new(bytes.Buffer))
+	command.SetArgs([]string{"logs.ndjson"})
+
+	err := command.Execute()
+	require.ErrorContains(t, err, "you must pass the paths of at least two corpora to merge")
+}
@@ -0,0 +1,162 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/dashboard"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/transport"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+	"github.com/spf13/cobra"
+)
+
+// PackageCmd returns the command to inspect the packages of the package registry.
+func PackageCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "package",
+		Short: "Inspect the packages of the package registry",
+		Long:  "Inspect the integration packages downloaded from the package registry, to find the data streams to pass to the `generate` command",
+	}
+
+	command.AddCommand(packageDataStreamsCmd())
+	command.AddCommand(packageCheckDashboardsCmd())
+
+	return command
+}
+
+func packageDataStreamsCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:     "data-streams integration version",
+		Example: "package data-streams nginx 1.17.0",
+		Short:   "List the data streams of a package",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("you must pass the integration package and the package version")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			httpClient, err := transport.NewHTTPClient(httpOptions)
+			if err != nil {
+				return err
+			}
+
+			dataStreams, err := fields.ListDataStreams(cmd.Context(), packageRegistryBaseURL, args[0], args[1],
+				fields.WithHTTPClient(httpClient), fields.WithCacheDir(packageCacheDir()))
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tTYPE\tTITLE")
+			for _, dataStream := range dataStreams {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", dataStream.Name, dataStream.Type, dataStream.Title)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	command.Flags().StringVarP(&packageRegistryBaseURL, "package-registry-base-url", "r", "https://epr.elastic.co/", "base url of the package registry with schema")
+	addPackageCacheFlags(command)
+	addHTTPFlags(command)
+
+	return command
+}
+
+func packageCheckDashboardsCmd() *cobra.Command {
+	var dashboardsESURL string
+
+	command := &cobra.Command{
+		Use:     "check-dashboards integration version",
+		Example: "package check-dashboards nginx 1.17.0 --es-url https://localhost:9200",
+		Short:   "Check that the dashboards of a package have data to show",
+		Long: "Count in Elasticsearch the documents each panel of the dashboards of a package is drawn from: the documents " +
+			"of its data view, matching its query and its filters, with the fields it aggregates. It fails when a panel has no documents.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("you must pass the integration package and the package version")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(dashboardsESURL) == 0 {
+				return errors.New("you must provide a not empty --es-url flag value")
+			}
+
+			httpClient, err := transport.NewHTTPClient(httpOptions)
+			if err != nil {
+				return err
+			}
+
+			assets, err := fields.LoadKibanaAssets(cmd.Context(), packageRegistryBaseURL, args[0], args[1],
+				fields.WithHTTPClient(httpClient), fields.WithCacheDir(packageCacheDir()))
+			if err != nil {
+				return err
+			}
+
+			panels, err := dashboard.Panels(assets)
+			if err != nil {
+				return err
+			}
+
+			if len(panels) == 0 {
+				return fmt.Errorf("package %s %s has no dashboard panels drawn from a data view", args[0], args[1])
+			}
+
+			esClient, err := newElasticsearchClient()
+			if err != nil {
+				return err
+			}
+
+			var empty int
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "DASHBOARD\tPANEL\tINDEX\tDOCUMENTS")
+			for _, panel := range panels {
+				count, err := dashboard.Count(cmd.Context(), esClient, dashboardsESURL, panel)
+				if err != nil {
+					return err
+				}
+
+				if count == 0 {
+					empty++
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", panel.Dashboard, panel.Title, panel.Index, count)
+			}
+
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			for _, panel := range panels {
+				if len(panel.Untranslated) > 0 {
+					fmt.Fprintf(cmd.OutOrStdout(), "Warning: the queries of the panel %s of %s are left out of its count: %s\n",
+						panel.Title, panel.Dashboard, strings.Join(panel.Untranslated, ", "))
+				}
+			}
+
+			if empty > 0 {
+				return fmt.Errorf("%d of the %d dashboard panels have no documents", empty, len(panels))
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVarP(&packageRegistryBaseURL, "package-registry-base-url", "r", "https://epr.elastic.co/", "base url of the package registry with schema")
+	command.Flags().StringVar(&dashboardsESURL, "es-url", "", "base URL of the Elasticsearch the data of the dashboards is in, like 'https://localhost:9200'")
+	addElasticsearchAuthFlags(command)
+	addPackageCacheFlags(command)
+	addHTTPFlags(command)
+
+	return command
+}
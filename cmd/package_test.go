@@ -0,0 +1,190 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func makePackageArchive(t *testing.T, files map[string]string) []byte {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	return archive.Bytes()
+}
+
+func TestPackageCmd_dataStreams(t *testing.T) {
+	archive := makePackageArchive(t, map[string]string{
+		"nginx-1.17.0/manifest.yml":                              "name: nginx\n",
+		"nginx-1.17.0/data_stream/error/manifest.yml":            "title: Nginx error logs\ntype: logs\n",
+		"nginx-1.17.0/data_stream/access/manifest.yml":           "title: Nginx access logs\ntype: logs\n",
+		"nginx-1.17.0/data_stream/stubstatus/manifest.yml":       "title: Nginx stubstatus metrics\ntype: metrics\n",
+		"nginx-1.17.0/data_stream/access/fields/fields.yml":      "- name: http.request.method\n  type: keyword\n",
+		"nginx-1.17.0/data_stream/access/_dev/test/manifest.yml": "vars: {}\n",
+	})
+
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/package/nginx/1.17.0":
+			_, _ = w.Write([]byte(`{"download":"/epr/nginx/nginx-1.17.0.zip"}`))
+		case "/epr/nginx/nginx-1.17.0.zip":
+			downloads++
+			_, _ = w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	viper.Set("package_cache_location", t.TempDir())
+	defer viper.Set("package_cache_location", "")
+
+	expected := "NAME        TYPE     TITLE\n" +
+		"access      logs     Nginx access logs\n" +
+		"error       logs     Nginx error logs\n" +
+		"stubstatus  metrics  Nginx stubstatus metrics\n"
+
+	// the package is downloaded once, then read from the cache
+	for i := 0; i < 2; i++ {
+		command := cmd.PackageCmd()
+
+		b := new(bytes.Buffer)
+		command.SetOut(b)
+		command.SetArgs([]string{"data-streams", "nginx", "1.17.0", "-r", server.URL})
+
+		err := command.Execute()
+		require.NoError(t, err)
+		require.Equal(t, expected, b.String())
+	}
+
+	require.Equal(t, 1, downloads)
+}
+
+func TestPackageCmd_packageCache(t *testing.T) {
+	archive := makePackageArchive(t, map[string]string{
+		"nginx-1.17.0/data_stream/access/manifest.yml": "title: Nginx access logs\ntype: logs\n",
+	})
+
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/package/nginx/1.17.0":
+			_, _ = w.Write([]byte(`{"download":"/epr/nginx/nginx-1.17.0.zip"}`))
+		case "/epr/nginx/nginx-1.17.0.zip":
+			downloads++
+			_, _ = w.Write(archive)
+		case "/epr/nginx/nginx-1.17.0.zip.sha512":
+			checksum := sha512.Sum512(archive)
+			_, _ = w.Write([]byte(hex.EncodeToString(checksum[:]) + "  nginx-1.17.0.zip\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	viper.Set("package_cache_location", t.TempDir())
+	defer viper.Set("package_cache_location", "")
+
+	run := func(args ...string) string {
+		command := cmd.PackageCmd()
+
+		b := new(bytes.Buffer)
+		command.SetOut(b)
+		command.SetArgs(append([]string{"data-streams", "nginx", "1.17.0", "-r", server.URL}, args...))
+
+		require.NoError(t, command.Execute())
+		return b.String()
+	}
+
+	// the cached archive matches the checksum of the registry
+	run()
+	run()
+	require.Equal(t, 1, downloads)
+
+	// the version re-published in the registry is downloaded again
+	archive = makePackageArchive(t, map[string]string{
+		"nginx-1.17.0/data_stream/error/manifest.yml": "title: Nginx error logs\ntype: logs\n",
+	})
+	require.Equal(t, "NAME   TYPE  TITLE\nerror  logs  Nginx error logs\n", run())
+	require.Equal(t, 2, downloads)
+	run()
+	require.Equal(t, 2, downloads)
+
+	// the cache is neither read nor written
+	require.Equal(t, "NAME   TYPE  TITLE\nerror  logs  Nginx error logs\n", run("--no-package-cache"))
+	require.Equal(t, 3, downloads)
+}
+
+func TestPackageCmd_checkDashboards(t *testing.T) {
+	archive := makePackageArchive(t, map[string]string{
+		"nginx-1.17.0/manifest.yml": "name: nginx\n",
+		"nginx-1.17.0/kibana/dashboard/nginx-overview.json": `{"id":"nginx-overview","type":"dashboard","attributes":{"title":"Overview","panelsJSON":"[` +
+			`{\"panelIndex\":\"1\",\"type\":\"lens\",\"embeddableConfig\":{\"attributes\":{\"title\":\"Requests\",\"references\":[{\"id\":\"logs-*\",\"name\":\"layer\",\"type\":\"index-pattern\"}],\"state\":{\"datasourceStates\":{\"formBased\":{\"layers\":{\"1\":{\"columns\":{\"a\":{\"sourceField\":\"url.original\"}}}}}}}}}},` +
+			`{\"panelIndex\":\"2\",\"type\":\"lens\",\"embeddableConfig\":{\"attributes\":{\"title\":\"Errors\",\"references\":[{\"id\":\"logs-*\",\"name\":\"layer\",\"type\":\"index-pattern\"}],\"state\":{\"datasourceStates\":{\"formBased\":{\"layers\":{\"1\":{\"columns\":{\"a\":{\"sourceField\":\"error.message\"}}}}}}}}}}]"}}`,
+	})
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/package/nginx/1.17.0":
+			_, _ = w.Write([]byte(`{"download":"/epr/nginx/nginx-1.17.0.zip"}`))
+		case "/epr/nginx/nginx-1.17.0.zip":
+			_, _ = w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+
+	var authorization string
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "error.message") {
+			_, _ = w.Write([]byte(`{"count":0}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"count":1000}`))
+	}))
+	defer es.Close()
+
+	viper.Set("package_cache_location", t.TempDir())
+	defer viper.Set("package_cache_location", "")
+
+	// as under the root command
+	command := cmd.PackageCmd()
+	command.SilenceUsage = true
+
+	b := new(bytes.Buffer)
+	command.SetOut(b)
+	command.SetArgs([]string{"check-dashboards", "nginx", "1.17.0", "-r", registry.URL, "--es-url", es.URL, "--es-api-key", "c2VjcmV0"})
+
+	err := command.Execute()
+	require.EqualError(t, err, "1 of the 2 dashboard panels have no documents")
+	require.Equal(t, "DASHBOARD  PANEL     INDEX   DOCUMENTS\n"+
+		"Overview   Requests  logs-*  1000\n"+
+		"Overview   Errors    logs-*  0\n", b.String())
+	require.Equal(t, "ApiKey c2VjcmV0", authorization)
+}
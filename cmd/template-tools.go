@@ -0,0 +1,182 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/corpus"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// defaultTemplateTestNow is the time used for generation based on now when --now is not set,
+// so that the `date` fields of the expected file don't depend on when the test is run
+const defaultTemplateTestNow = "2023-01-01T00:00:00Z"
+
+var updateExpected bool
+var scaffoldOutput string
+
+// TemplateToolsCmd returns the command grouping the tools for writing templates.
+func TemplateToolsCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "template",
+		Short: "Tools for writing templates",
+	}
+
+	command.AddCommand(templateTestCmd())
+	command.AddCommand(templateScaffoldCmd())
+
+	return command
+}
+
+func templateTestCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:     "test template-path fields-definition-path expected-path",
+		Example: "template test gotext.tpl fields.yml expected.ndjson -c configs.yml",
+		Short:   "Test a template against an expected corpus",
+		Long:    "Generate a corpus from a template with a fixed seed and time, and compare it to the expected corpus file; use --update to write the expected file",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 3 {
+				return errors.New("you must pass the template path, the fields definition path and the expected path")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs := afero.NewOsFs()
+
+			cfg, err := config.LoadConfig(fs, configFile)
+			if err != nil {
+				return err
+			}
+
+			printConfigWarnings(cmd.ErrOrStderr(), cfg)
+
+			fc, err := corpus.NewGeneratorWithTemplate(cfg, fs, "", templateType)
+			if err != nil {
+				return err
+			}
+
+			fc = fc.WithConfigSeed(!cmd.Flags().Changed("seed"))
+
+			timeNow, err := getTimeNowFromFlag(timeNowAsString)
+			if err != nil {
+				return err
+			}
+
+			var got bytes.Buffer
+			if err := fc.GenerateWithTemplateTo(&got, args[0], args[1], totEvents, timeNow, randSeed); err != nil {
+				return err
+			}
+
+			expectedPath := args[2]
+			if updateExpected {
+				if err := os.WriteFile(expectedPath, got.Bytes(), 0644); err != nil {
+					return err
+				}
+
+				fmt.Fprintln(cmd.OutOrStdout(), "Expected file updated:", expectedPath)
+				return nil
+			}
+
+			expected, err := os.ReadFile(expectedPath)
+			if err != nil {
+				return fmt.Errorf("cannot read expected file, use --update to create it: %w", err)
+			}
+
+			if err := compareCorpus(expected, got.Bytes()); err != nil {
+				return fmt.Errorf("%s: %w", expectedPath, err)
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "PASS", expectedPath)
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVarP(&configFile, "config-file", "c", "", "path to config file for generator settings")
+	command.Flags().StringVarP(&templateType, "template-type", "y", "placeholder", "either 'placeholder' or 'gotext'")
+	command.Flags().Uint64VarP(&totEvents, "tot-events", "t", 10, "total events of the corpus to generate")
+	command.Flags().StringVarP(&timeNowAsString, "now", "n", defaultTemplateTestNow, "time to use for generation based on now (`date` type)")
+	command.Flags().Int64VarP(&randSeed, "seed", "s", 1, "seed to set as source of rand, overriding the `seed` of the config file")
+	command.Flags().BoolVar(&updateExpected, "update", false, "write the generated corpus to the expected file instead of comparing them")
+
+	return command
+}
+
+func templateScaffoldCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:     "scaffold fields-definition-path",
+		Example: "template scaffold fields.yml -o configs.yml",
+		Short:   "Scaffold a config file from a fields definition",
+		Long:    "Write a config file with an entry for each field of the fields definition, with its description and example as comments and the example pre-filling the `enum` of keyword and numeric fields",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("you must pass the fields definition path")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flds, err := fields.LoadFieldsWithTemplate(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			if len(scaffoldOutput) == 0 {
+				return genlib.ScaffoldConfig(cmd.OutOrStdout(), flds)
+			}
+
+			var scaffold bytes.Buffer
+			if err := genlib.ScaffoldConfig(&scaffold, flds); err != nil {
+				return err
+			}
+
+			if err := os.WriteFile(scaffoldOutput, scaffold.Bytes(), 0644); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "Config file scaffolded:", scaffoldOutput)
+			return nil
+		},
+	}
+
+	command.Flags().StringVarP(&scaffoldOutput, "output", "o", "", "path of the config file to write, instead of the standard output")
+
+	return command
+}
+
+// compareCorpus returns an error reporting the first line that differs between the expected and the generated corpus
+func compareCorpus(expected, got []byte) error {
+	if bytes.Equal(expected, got) {
+		return nil
+	}
+
+	expectedLines := bytes.Split(expected, []byte("\n"))
+	gotLines := bytes.Split(got, []byte("\n"))
+	for i := 0; i < len(expectedLines) || i < len(gotLines); i++ {
+		var expectedLine, gotLine []byte
+		if i < len(expectedLines) {
+			expectedLine = expectedLines[i]
+		}
+
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+
+		if !bytes.Equal(expectedLine, gotLine) {
+			return fmt.Errorf("corpus differs at line %d\nexpected: %s\ngot:      %s", i+1, expectedLine, gotLine)
+		}
+	}
+
+	return nil
+}
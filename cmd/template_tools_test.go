@@ -0,0 +1,91 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateTestCmd(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "placeholder.tpl")
+	fieldsPath := filepath.Join(dir, "fields.yml")
+	expectedPath := filepath.Join(dir, "expected.ndjson")
+
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"@timestamp":"{{.timestamp}}","bytes":{{.bytes}},"host":"{{.host}}"}`), 0644))
+	require.NoError(t, os.WriteFile(fieldsPath, []byte(`- name: timestamp
+  type: date
+- name: bytes
+  type: long
+- name: host
+  type: keyword
+`), 0644))
+
+	run := func(args ...string) (string, error) {
+		command := cmd.TemplateToolsCmd()
+		b := new(bytes.Buffer)
+		command.SetOut(b)
+		command.SetArgs(append([]string{"test", templatePath, fieldsPath, expectedPath}, args...))
+
+		err := command.Execute()
+		return b.String(), err
+	}
+
+	_, err := run()
+	require.Error(t, err, "missing expected file")
+
+	_, err = run("--update")
+	require.NoError(t, err)
+
+	out, err := run()
+	require.NoError(t, err)
+	require.Contains(t, out, "PASS")
+
+	_, err = run("--seed", "2")
+	require.Error(t, err, "different seed")
+
+	expected, err := os.ReadFile(expectedPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(expectedPath, append([]byte("{}\n"), expected...), 0644))
+
+	_, err = run()
+	require.ErrorContains(t, err, "line 1")
+}
+
+func TestTemplateScaffoldCmd(t *testing.T) {
+	dir := t.TempDir()
+	fieldsPath := filepath.Join(dir, "fields.yml")
+	outputPath := filepath.Join(dir, "configs.yml")
+
+	require.NoError(t, os.WriteFile(fieldsPath, []byte(`- name: http
+  type: group
+  fields:
+    - name: request.method
+      type: keyword
+      description: HTTP request method.
+      example: POST
+    - name: response.status_code
+      type: long
+      example: 404
+`), 0644))
+
+	command := cmd.TemplateToolsCmd()
+	b := new(bytes.Buffer)
+	command.SetOut(b)
+	command.SetArgs([]string{"scaffold", fieldsPath, "-o", outputPath})
+	require.NoError(t, command.Execute())
+	require.Contains(t, b.String(), outputPath)
+
+	scaffold, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	require.Contains(t, string(scaffold), "  # HTTP request method.\n  # example: POST\n  - name: http.request.method\n    enum: [\"POST\"]\n")
+	require.Contains(t, string(scaffold), "  - name: http.response.status_code\n    enum: [404]\n")
+}
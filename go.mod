@@ -5,9 +5,7 @@ go 1.18
 require (
 	github.com/Masterminds/sprig/v3 v3.2.3
 	github.com/OpenPeeDeeP/xdg v1.0.0
-	github.com/Pallinder/go-randomdata v1.2.0
 	github.com/elastic/go-ucfg v0.8.8
-	github.com/lithammer/shortuuid/v3 v3.0.7
 	github.com/spf13/afero v1.11.0
 	github.com/spf13/cobra v1.8.0
 	github.com/spf13/viper v1.18.2
@@ -15,6 +13,7 @@ require (
 	go.uber.org/multierr v1.11.0
 	golang.org/x/mod v0.16.0
 	golang.org/x/sync v0.7.0
+	golang.org/x/text v0.14.0
 )
 
 require (
@@ -43,7 +42,6 @@ require (
 	golang.org/x/crypto v0.16.0 // indirect
 	golang.org/x/exp v0.0.0-20230905200255-921286631fa9 // indirect
 	golang.org/x/sys v0.15.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
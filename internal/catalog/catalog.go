@@ -0,0 +1,119 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/assets"
+)
+
+const (
+	templatesRoot        = "templates"
+	schemaPrefix         = "schema-"
+	templateExt          = ".tpl"
+	fieldsDefinitionFile = "fields.yml"
+	fieldsConfigFile     = "configs.yml"
+)
+
+var ErrNotFound = errors.New("catalog entry not found")
+
+// Entry is a dataset of the catalog, with the templates available for one of its schemas
+type Entry struct {
+	// Name is the `<package>.<dataset>` name of the entry
+	Name   string
+	Schema string
+	// Templates are the names of the template files, without extension, like `gotext` or `placeholder`
+	Templates []string
+}
+
+// Files holds the content of the files needed to generate a corpus from an entry
+type Files struct {
+	Template         []byte
+	FieldsDefinition []byte
+	// FieldsConfig is empty when the entry has no fields generation configuration
+	FieldsConfig []byte
+}
+
+// Catalog reads the entries from a file system with the layout of the `assets/templates` folder
+type Catalog struct {
+	fsys fs.FS
+}
+
+// New returns the catalog embedded in the binary
+func New() Catalog {
+	fsys, _ := fs.Sub(assets.Templates, templatesRoot)
+	return Catalog{fsys: fsys}
+}
+
+// List returns the entries of the catalog, sorted by name and schema
+func (c Catalog) List() ([]Entry, error) {
+	schemaDirs, err := fs.Glob(c.fsys, path.Join("*", schemaPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(schemaDirs))
+	for _, schemaDir := range schemaDirs {
+		templates, err := fs.Glob(c.fsys, path.Join(schemaDir, "*"+templateExt))
+		if err != nil {
+			return nil, err
+		}
+
+		entry := Entry{
+			Name:   path.Dir(schemaDir),
+			Schema: strings.TrimPrefix(path.Base(schemaDir), schemaPrefix),
+		}
+
+		for _, template := range templates {
+			entry.Templates = append(entry.Templates, strings.TrimSuffix(path.Base(template), templateExt))
+		}
+
+		sort.Strings(entry.Templates)
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+
+		return entries[i].Schema < entries[j].Schema
+	})
+
+	return entries, nil
+}
+
+// Load returns the files of the template of the entry with name and schema
+func (c Catalog) Load(name, schema, template string) (Files, error) {
+	schemaDir := path.Join(name, schemaPrefix+schema)
+	if _, err := fs.Stat(c.fsys, schemaDir); err != nil {
+		return Files{}, fmt.Errorf("%w: %s with schema %s", ErrNotFound, name, schema)
+	}
+
+	var files Files
+	var err error
+	files.Template, err = fs.ReadFile(c.fsys, path.Join(schemaDir, template+templateExt))
+	if err != nil {
+		return Files{}, fmt.Errorf("%w: template %s of %s with schema %s", ErrNotFound, template, name, schema)
+	}
+
+	files.FieldsDefinition, err = fs.ReadFile(c.fsys, path.Join(schemaDir, fieldsDefinitionFile))
+	if err != nil {
+		return Files{}, fmt.Errorf("cannot read fields definition of %s: %w", name, err)
+	}
+
+	files.FieldsConfig, err = fs.ReadFile(c.fsys, path.Join(schemaDir, fieldsConfigFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Files{}, fmt.Errorf("cannot read fields generation configuration of %s: %w", name, err)
+	}
+
+	return files, nil
+}
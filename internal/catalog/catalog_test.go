@@ -0,0 +1,67 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package catalog
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCatalog() Catalog {
+	return Catalog{fsys: fstest.MapFS{
+		"nginx.access/schema-b/fields.yml":      {Data: []byte("- name: message\n  type: keyword\n")},
+		"nginx.access/schema-b/configs.yml":     {Data: []byte("fields: []\n")},
+		"nginx.access/schema-b/gotext.tpl":      {Data: []byte(`{{generate "message"}}`)},
+		"nginx.access/schema-b/placeholder.tpl": {Data: []byte(`{{.message}}`)},
+		"aws.billing/schema-a/fields.yml":       {Data: []byte("- name: message\n  type: keyword\n")},
+		"aws.billing/schema-a/gotext.tpl":       {Data: []byte(`{{generate "message"}}`)},
+	}}
+}
+
+func TestCatalog_List(t *testing.T) {
+	entries, err := testCatalog().List()
+	require.NoError(t, err)
+
+	assert.Equal(t, []Entry{
+		{Name: "aws.billing", Schema: "a", Templates: []string{"gotext"}},
+		{Name: "nginx.access", Schema: "b", Templates: []string{"gotext", "placeholder"}},
+	}, entries)
+}
+
+func TestCatalog_Load(t *testing.T) {
+	c := testCatalog()
+
+	files, err := c.Load("nginx.access", "b", "placeholder")
+	require.NoError(t, err)
+	assert.Equal(t, `{{.message}}`, string(files.Template))
+	assert.NotEmpty(t, files.FieldsDefinition)
+	assert.NotEmpty(t, files.FieldsConfig)
+
+	files, err = c.Load("aws.billing", "a", "gotext")
+	require.NoError(t, err)
+	assert.Empty(t, files.FieldsConfig)
+
+	_, err = c.Load("nginx.error", "b", "gotext")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = c.Load("aws.billing", "a", "placeholder")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestNew(t *testing.T) {
+	entries, err := New().List()
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+
+	for _, entry := range entries {
+		for _, template := range entry.Templates {
+			_, err := New().Load(entry.Name, entry.Schema, template)
+			assert.NoError(t, err, entry.Name)
+		}
+	}
+}
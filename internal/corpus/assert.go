@@ -0,0 +1,130 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"go.uber.org/multierr"
+)
+
+// ErrAssertionFailed is wrapped by the errors of the `assert` of the fields not verified by the generated corpus
+var ErrAssertionFailed = errors.New("assertion failed")
+
+// fieldStats holds the statistics of the values written for a field with `assert`
+type fieldStats struct {
+	assert   *config.Assert
+	distinct map[string]struct{}
+	sum      float64
+	numbers  uint64
+}
+
+// assertions collects the statistics of the fields with `assert` while generating, to verify them at the end
+type assertions struct {
+	names []string
+	stats map[string]*fieldStats
+	// pending holds the values of the event being generated by field, counted once the event is written, so that
+	// the dropped events are not counted; pendingEvent is the index of the event plus one
+	pending      map[string]string
+	pendingEvent uint64
+}
+
+// newAssertions returns the assertions of the fields with `assert`, nil when there are none
+func newAssertions(cfg Config, flds Fields) (*assertions, error) {
+	a := &assertions{stats: make(map[string]*fieldStats), pending: make(map[string]string)}
+	for _, field := range flds {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok || fieldCfg.Assert == nil {
+			continue
+		}
+
+		if err := fieldCfg.ValidAssert(); err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		a.names = append(a.names, field.Name)
+		a.stats[field.Name] = &fieldStats{assert: fieldCfg.Assert, distinct: make(map[string]struct{})}
+	}
+
+	if len(a.names) == 0 {
+		return nil, nil
+	}
+
+	return a, nil
+}
+
+// afterField is the genlib.AfterFieldHook keeping the values of the fields with `assert` until the event is
+// written: it must be the last hook, so that it gets the values as written. A field written more than once in the
+// same event is counted once.
+func (a *assertions) afterField(event uint64, field string, value any) (any, error) {
+	if _, ok := a.stats[field]; !ok {
+		return value, nil
+	}
+
+	if a.pendingEvent != event+1 {
+		a.discard()
+		a.pendingEvent = event + 1
+	}
+
+	if _, ok := a.pending[field]; ok {
+		return value, nil
+	}
+
+	if b, ok := value.([]byte); ok {
+		a.pending[field] = string(b)
+	} else {
+		a.pending[field] = fmt.Sprint(value)
+	}
+
+	return value, nil
+}
+
+// written counts the values of the event just written
+func (a *assertions) written() {
+	for field, s := range a.pending {
+		stats := a.stats[field]
+		stats.distinct[s] = struct{}{}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			stats.sum += f
+			stats.numbers++
+		}
+	}
+
+	a.discard()
+}
+
+// discard drops the values of the event being generated: the ones of an event not written, like a dropped one, are
+// discarded when the next one is generated
+func (a *assertions) discard() {
+	for field := range a.pending {
+		delete(a.pending, field)
+	}
+}
+
+// check returns the errors of the assertions not verified by the values counted
+func (a *assertions) check() error {
+	var errs []error
+	for _, name := range a.names {
+		stats := a.stats[name]
+		if bounds := stats.assert.CardinalityBetween; bounds != nil {
+			if cardinality := float64(len(stats.distinct)); cardinality < bounds[0] || cardinality > bounds[1] {
+				errs = append(errs, fmt.Errorf("field %s: %w: cardinality %g not between %g and %g", name, ErrAssertionFailed, cardinality, bounds[0], bounds[1]))
+			}
+		}
+
+		if bounds := stats.assert.MeanBetween; bounds != nil {
+			if stats.numbers == 0 {
+				errs = append(errs, fmt.Errorf("field %s: %w: no numeric values for the mean", name, ErrAssertionFailed))
+			} else if mean := stats.sum / float64(stats.numbers); mean < bounds[0] || mean > bounds[1] {
+				errs = append(errs, fmt.Errorf("field %s: %w: mean %g not between %g and %g", name, ErrAssertionFailed, mean, bounds[0], bounds[1]))
+			}
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
@@ -0,0 +1,246 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// DefaultDownsampleBuckets is the number of time buckets the time range of a corpus is split into when the duration
+// of the buckets is not set
+const DefaultDownsampleBuckets = 100
+
+// noTimestampBucket is the bucket of the events without a timestamp, or that are not JSON
+const noTimestampBucket = -1
+
+var ErrNotValidDownsample = errors.New("please, pass either a positive -t or a --ratio between 0 and 1")
+
+// DownsampleOptions are the options of Downsample: either TotEvents or Ratio sets the events to keep
+type DownsampleOptions struct {
+	// TotEvents is the number of events to keep
+	TotEvents uint64
+	// Ratio is the ratio of the events to keep, when TotEvents is not set
+	Ratio float64
+	// TimestampField is the dotted name of the field with the timestamp of the events, like `@timestamp`
+	TimestampField string
+	// EntityField is the dotted name of the field identifying the entity of the events, like `host.name`; when
+	// not set the events are stratified by time only
+	EntityField string
+	// Bucket is the duration of the time buckets; when not set the time range of the corpus is split into
+	// DefaultDownsampleBuckets buckets
+	Bucket time.Duration
+	Seed   int64
+}
+
+// DownsampleResult reports the events of the input corpus, the events kept and the strata they were sampled from
+type DownsampleResult struct {
+	Events     uint64
+	KeptEvents uint64
+	Strata     int
+}
+
+// stratum is a time bucket of the events of an entity
+type stratum struct {
+	bucket int64
+	entity string
+}
+
+// sampling is the state of the selection sampling of a stratum: needed of its remaining events are still to be kept
+type sampling struct {
+	remaining uint64
+	needed    uint64
+}
+
+// Downsample writes to outputPath a smaller corpus from the corpus at inputPath, keeping the events of each time
+// bucket and entity in proportion to their share of the corpus, so that the per-field distributions and the time
+// coverage of the corpus are preserved. Every stratum keeps at least an event when the budget allows it, and the
+// events keep their original order. The corpus has an event per line, optionally preceded by its bulk action line,
+// and is read and written compressed with gzip when its name ends in `.gz`.
+func Downsample(fs afero.Fs, inputPath, outputPath string, options DownsampleOptions) (DownsampleResult, error) {
+	if options.TotEvents == 0 && (options.Ratio <= 0 || options.Ratio > 1) {
+		return DownsampleResult{}, ErrNotValidDownsample
+	}
+
+	var minTs, maxTs int64 = math.MaxInt64, math.MinInt64
+	var events uint64
+	err := readCorpusRecords(fs, inputPath, func(_ []byte, event map[string]any) error {
+		events++
+		if ts, ok := eventTimestamp(event, options.TimestampField); ok {
+			if ts < minTs {
+				minTs = ts
+			}
+
+			if ts > maxTs {
+				maxTs = ts
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return DownsampleResult{}, err
+	}
+
+	bucket := options.Bucket.Milliseconds()
+	if bucket <= 0 && minTs <= maxTs {
+		bucket = (maxTs - minTs + DefaultDownsampleBuckets) / DefaultDownsampleBuckets
+	}
+
+	stratumOf := func(event map[string]any) stratum {
+		s := stratum{bucket: noTimestampBucket}
+		if ts, ok := eventTimestamp(event, options.TimestampField); ok {
+			s.bucket = (ts - minTs) / bucket
+		}
+
+		if len(options.EntityField) > 0 {
+			if value, ok := lookupField(event, options.EntityField); ok && value != nil {
+				s.entity = fmt.Sprint(value)
+			}
+		}
+
+		return s
+	}
+
+	counts := make(map[stratum]uint64)
+	err = readCorpusRecords(fs, inputPath, func(_ []byte, event map[string]any) error {
+		counts[stratumOf(event)]++
+		return nil
+	})
+
+	if err != nil {
+		return DownsampleResult{}, err
+	}
+
+	target := options.TotEvents
+	if target == 0 {
+		target = uint64(math.Round(options.Ratio * float64(events)))
+	}
+
+	if target > events {
+		target = events
+	}
+
+	samplings := allocateStrata(counts, target)
+
+	if err := fs.MkdirAll(filepath.Dir(outputPath), corpusLocPerm); err != nil {
+		return DownsampleResult{}, fmt.Errorf("cannot create output folder: %w", err)
+	}
+
+	w, err := createOutputFile(fs, outputPath)
+	if err != nil {
+		return DownsampleResult{}, err
+	}
+
+	r := rand.New(rand.NewSource(options.Seed))
+	var kept uint64
+	err = readCorpusRecords(fs, inputPath, func(record []byte, event map[string]any) error {
+		s := samplings[stratumOf(event)]
+		keep := s.needed > 0 && uint64(r.Float64()*float64(s.remaining)) < s.needed
+		s.remaining--
+		if !keep {
+			return nil
+		}
+
+		s.needed--
+		kept++
+		_, err := w.Write(record)
+		return err
+	})
+
+	if err != nil {
+		w.Close()
+		return DownsampleResult{}, err
+	}
+
+	if err := w.Close(); err != nil {
+		return DownsampleResult{}, err
+	}
+
+	return DownsampleResult{Events: events, KeptEvents: kept, Strata: len(counts)}, nil
+}
+
+// allocateStrata splits the target events among the strata in proportion to their events, rounding with the largest
+// remainders so that exactly target events are kept: when target allows it every stratum keeps at least an event,
+// so that the rare entities and the sparse time buckets are not lost
+func allocateStrata(counts map[stratum]uint64, target uint64) map[stratum]*sampling {
+	strata := make([]stratum, 0, len(counts))
+	var total uint64
+	for s, count := range counts {
+		strata = append(strata, s)
+		total += count
+	}
+
+	sort.Slice(strata, func(i, j int) bool {
+		if strata[i].bucket != strata[j].bucket {
+			return strata[i].bucket < strata[j].bucket
+		}
+
+		return strata[i].entity < strata[j].entity
+	})
+
+	samplings := make(map[stratum]*sampling, len(strata))
+	for _, s := range strata {
+		samplings[s] = &sampling{remaining: counts[s]}
+	}
+
+	if target == 0 || total == 0 {
+		return samplings
+	}
+
+	budget, weights := target, counts
+	if target >= uint64(len(strata)) {
+		// every stratum keeps an event, the rest of the budget is split among the events left
+		budget -= uint64(len(strata))
+		weights = make(map[stratum]uint64, len(strata))
+		total = 0
+		for _, s := range strata {
+			samplings[s].needed = 1
+			weights[s] = counts[s] - 1
+			total += counts[s] - 1
+		}
+	}
+
+	if budget == 0 || total == 0 {
+		return samplings
+	}
+
+	type remainder struct {
+		s    stratum
+		frac float64
+	}
+
+	remainders := make([]remainder, 0, len(strata))
+	allocated := uint64(0)
+	for _, s := range strata {
+		share := float64(weights[s]) * float64(budget) / float64(total)
+		whole := uint64(share)
+		samplings[s].needed += whole
+		allocated += whole
+		remainders = append(remainders, remainder{s: s, frac: share - float64(whole)})
+	}
+
+	sort.SliceStable(remainders, func(i, j int) bool {
+		return remainders[i].frac > remainders[j].frac
+	})
+
+	for i := 0; allocated < budget && i < len(remainders); i++ {
+		s := samplings[remainders[i].s]
+		if s.needed < s.remaining {
+			s.needed++
+			allocated++
+		}
+	}
+
+	return samplings
+}
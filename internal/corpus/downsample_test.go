@@ -0,0 +1,126 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeDownsampleCorpus(t *testing.T, fs afero.Fs, filePath string, withActions bool) {
+	var buf bytes.Buffer
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 1000; i++ {
+		host := "frequent"
+		if i%100 == 0 {
+			host = "rare"
+		}
+
+		if withActions {
+			buf.WriteString(`{"create":{}}` + "\n")
+		}
+
+		fmt.Fprintf(&buf, `{"@timestamp":%q,"host":{"name":%q},"n":%d}`+"\n", start.Add(time.Duration(i)*time.Minute).Format(time.RFC3339), host, i)
+	}
+
+	require.NoError(t, afero.WriteFile(fs, filePath, buf.Bytes(), corpusPerm))
+}
+
+func readDownsampledEvents(t *testing.T, fs afero.Fs, filePath string) []map[string]any {
+	var events []map[string]any
+	require.NoError(t, readCorpusRecords(fs, filePath, func(_ []byte, event map[string]any) error {
+		events = append(events, event)
+		return nil
+	}))
+
+	return events
+}
+
+func TestDownsample(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	writeDownsampleCorpus(t, fs, "/corpora/in.ndjson", false)
+
+	result, err := Downsample(fs, "/corpora/in.ndjson", "/corpora/out.ndjson", DownsampleOptions{
+		TotEvents:      100,
+		TimestampField: "@timestamp",
+		EntityField:    "host.name",
+		Bucket:         100 * time.Minute,
+		Seed:           1,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), result.Events)
+	assert.Equal(t, uint64(100), result.KeptEvents)
+	assert.Equal(t, 20, result.Strata)
+
+	events := readDownsampledEvents(t, fs, "/corpora/out.ndjson")
+	require.Len(t, events, 100)
+
+	buckets := make(map[string]int)
+	previous := int64(-1)
+	for _, event := range events {
+		n, err := event["n"].(interface{ Int64() (int64, error) }).Int64()
+		require.NoError(t, err)
+		assert.Greater(t, n, previous, "the events keep their original order")
+		previous = n
+
+		buckets[fmt.Sprintf("%s-%d", event["host"].(map[string]any)["name"], n/100)]++
+	}
+
+	// every bucket of the rare host is kept, and the frequent host keeps its share of every bucket
+	for bucket := 0; bucket < 10; bucket++ {
+		assert.Equal(t, 1, buckets[fmt.Sprintf("rare-%d", bucket)])
+		assert.Equal(t, 9, buckets[fmt.Sprintf("frequent-%d", bucket)])
+	}
+}
+
+func TestDownsample_bulkGzip(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	writeDownsampleCorpus(t, fs, "/corpora/in.ndjson", true)
+
+	result, err := Downsample(fs, "/corpora/in.ndjson", "/corpora/out.ndjson.gz", DownsampleOptions{
+		Ratio:          0.05,
+		TimestampField: "@timestamp",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), result.Events)
+	assert.Equal(t, uint64(50), result.KeptEvents)
+	assert.Equal(t, DefaultDownsampleBuckets, result.Strata)
+
+	f, err := fs.Open("/corpora/out.ndjson.gz")
+	require.NoError(t, err)
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	require.Len(t, lines, 100)
+	for i := 0; i < len(lines); i += 2 {
+		assert.Equal(t, `{"create":{}}`, lines[i], "the events keep their bulk action line")
+		assert.Contains(t, lines[i+1], `"@timestamp"`)
+	}
+}
+
+func TestDownsample_notValid(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	writeDownsampleCorpus(t, fs, "/corpora/in.ndjson", false)
+
+	_, err := Downsample(fs, "/corpora/in.ndjson", "/corpora/out.ndjson", DownsampleOptions{Ratio: 1.5})
+	assert.ErrorIs(t, err, ErrNotValidDownsample)
+}
@@ -0,0 +1,72 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotValidMaxDuration is returned for a negative time budget of the generation
+var ErrNotValidMaxDuration = errors.New("please, pass --max-duration as a not negative duration, like '10m'")
+
+// truncation records the events written by a generation stopped at its time budget
+type truncation struct {
+	// events is first to be 64-bit aligned for the atomic operations
+	events    uint64
+	truncated uint32
+}
+
+// WithMaxDuration returns a copy of the corpus generator stopping the generation once maxDuration has passed, with
+// the events written so far, instead of the `max_duration` of the config. A zero maxDuration means the one of the
+// config, if any.
+func (gc GeneratorCorpus) WithMaxDuration(maxDuration time.Duration) (GeneratorCorpus, error) {
+	if maxDuration < 0 {
+		return gc, ErrNotValidMaxDuration
+	}
+
+	gc.maxDuration = maxDuration
+	return gc, nil
+}
+
+// Truncated returns the number of events written by the last generation, and whether it was stopped at its time
+// budget before writing all of them
+func (gc GeneratorCorpus) Truncated() (uint64, bool) {
+	if gc.truncation == nil || atomic.LoadUint32(&gc.truncation.truncated) == 0 {
+		return 0, false
+	}
+
+	return atomic.LoadUint64(&gc.truncation.events), true
+}
+
+// startDeadline returns the time the generation started at now must stop at, the zero time when it has no time
+// budget, and clears the truncation of the previous generation
+func (gc GeneratorCorpus) startDeadline(now time.Time) time.Time {
+	if gc.truncation != nil {
+		atomic.StoreUint32(&gc.truncation.truncated, 0)
+	}
+
+	maxDuration := gc.maxDuration
+	if maxDuration == 0 {
+		maxDuration = gc.config.MaxDuration()
+	}
+
+	if maxDuration == 0 {
+		return time.Time{}
+	}
+
+	return now.Add(maxDuration)
+}
+
+// truncate records that the generation was stopped at its time budget after writing events
+func (gc GeneratorCorpus) truncate(events uint64) {
+	if gc.truncation == nil {
+		return
+	}
+
+	atomic.StoreUint64(&gc.truncation.events, events)
+	atomic.StoreUint32(&gc.truncation.truncated, 1)
+}
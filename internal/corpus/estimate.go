@@ -0,0 +1,160 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// DefaultSampleEvents is the number of events of the calibration run of an estimate
+const DefaultSampleEvents = 10000
+
+// DefaultIndexRatio is the ratio of the size of an Elasticsearch index to the size of the events indexed into it,
+// a rough rule of thumb for the default mappings and codec
+const DefaultIndexRatio = 1.0
+
+// ErrNotValidEstimate is returned for an estimate of a corpus without a number of events or without a sample
+var ErrNotValidEstimate = errors.New("please, pass a positive number of events to estimate with -t, and a positive --sample-events")
+
+// Estimate is the cost of a corpus, extrapolated from a calibration run generating a sample of its events
+type Estimate struct {
+	// SampleEvents, SampleBytes and SampleDuration are the events, the bytes and the generation time of the sample
+	SampleEvents   uint64
+	SampleBytes    uint64
+	SampleDuration time.Duration
+	// TotEvents is the number of events of the corpus
+	TotEvents uint64
+	// Bytes is the size of the corpus file, and GzipBytes its size with --gzip at the default level
+	Bytes     uint64
+	GzipBytes uint64
+	// Duration is the generation time of the corpus, taking into account --rate and --max-write-mbps
+	Duration time.Duration
+	// IndexBytes is the approximate size of the corpus indexed into Elasticsearch
+	IndexBytes uint64
+}
+
+// calibrationWriter counts the bytes written to it, before and after gzip, and the time of the first write
+type calibrationWriter struct {
+	bytes      uint64
+	gzipBytes  countingWriter
+	gzip       *gzip.Writer
+	firstWrite time.Time
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter uint64
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
+
+func newCalibrationWriter() *calibrationWriter {
+	w := &calibrationWriter{}
+	w.gzip = gzip.NewWriter(&w.gzipBytes)
+	return w
+}
+
+func (w *calibrationWriter) Write(p []byte) (int, error) {
+	if w.firstWrite.IsZero() {
+		w.firstWrite = time.Now()
+	}
+
+	w.bytes += uint64(len(p))
+	return w.gzip.Write(p)
+}
+
+// estimate runs generate, writing sampleEvents events of the corpus, and extrapolates them to totEvents, with
+// indexRatio the ratio of the size of the index to the size of the events
+func (gc GeneratorCorpus) estimate(sampleEvents, totEvents uint64, indexRatio float64, generate func(GeneratorCorpus, io.Writer, uint64) error) (Estimate, error) {
+	if totEvents == 0 || sampleEvents == 0 {
+		return Estimate{}, ErrNotValidEstimate
+	}
+
+	if sampleEvents > totEvents {
+		sampleEvents = totEvents
+	}
+
+	// the sample is generated as fast as possible, the limits are applied to the extrapolation
+	calibration := gc
+	calibration.reload = nil
+	calibration.eventsPerSecond = 0
+	calibration.maxWriteMBps = 0
+	calibration.maxDuration = 0
+	calibration.truncation = nil
+	calibration.stateFile = ""
+	calibration.reportFile = ""
+	calibration.format = FormatText
+
+	w := newCalibrationWriter()
+	if err := generate(calibration, w, sampleEvents); err != nil {
+		return Estimate{}, err
+	}
+
+	sampleDuration := time.Since(w.firstWrite)
+	if w.firstWrite.IsZero() {
+		sampleDuration = 0
+	}
+
+	if err := w.gzip.Close(); err != nil {
+		return Estimate{}, err
+	}
+
+	scale := float64(totEvents) / float64(sampleEvents)
+	estimate := Estimate{
+		SampleEvents:   sampleEvents,
+		SampleBytes:    w.bytes,
+		SampleDuration: sampleDuration,
+		TotEvents:      totEvents,
+		Bytes:          uint64(math.Round(float64(w.bytes) * scale)),
+		GzipBytes:      uint64(math.Round(float64(w.gzipBytes) * scale)),
+		Duration:       time.Duration(float64(sampleDuration) * scale),
+	}
+
+	estimate.IndexBytes = uint64(math.Round(float64(estimate.Bytes) * indexRatio))
+
+	if gc.eventsPerSecond > 0 {
+		if paced := time.Duration(float64(totEvents) / gc.eventsPerSecond * float64(time.Second)); paced > estimate.Duration {
+			estimate.Duration = paced
+		}
+	}
+
+	if gc.maxWriteMBps > 0 {
+		if throttled := time.Duration(float64(estimate.Bytes) / (gc.maxWriteMBps * 1024 * 1024) * float64(time.Second)); throttled > estimate.Duration {
+			estimate.Duration = throttled
+		}
+	}
+
+	return estimate, nil
+}
+
+// Estimate generates sampleEvents events of the corpus of Generate, without writing them, and extrapolates the size
+// and the generation time of the corpus of totEvents events from them. indexRatio is the ratio of the size of an
+// Elasticsearch index to the size of the events indexed into it, like DefaultIndexRatio.
+func (gc GeneratorCorpus) Estimate(packageRegistryBaseURL, integrationPackage, dataStream, packageVersion string, sampleEvents, totEvents uint64, indexRatio float64, timeNow time.Time, randSeed int64) (Estimate, error) {
+	return gc.estimate(sampleEvents, totEvents, indexRatio, func(gc GeneratorCorpus, w io.Writer, sampleEvents uint64) error {
+		return gc.GenerateTo(w, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, sampleEvents, timeNow, randSeed)
+	})
+}
+
+// EstimateWithTemplate generates sampleEvents events of the corpus of GenerateWithTemplate, without writing them,
+// and extrapolates the size and the generation time of the corpus of totEvents events from them. indexRatio is the
+// ratio of the size of an Elasticsearch index to the size of the events indexed into it, like DefaultIndexRatio.
+func (gc GeneratorCorpus) EstimateWithTemplate(templatePath, fieldsDefinitionPath string, sampleEvents, totEvents uint64, indexRatio float64, timeNow time.Time, randSeed int64) (Estimate, error) {
+	return gc.estimate(sampleEvents, totEvents, indexRatio, func(gc GeneratorCorpus, w io.Writer, sampleEvents uint64) error {
+		return gc.GenerateWithTemplateTo(w, templatePath, fieldsDefinitionPath, sampleEvents, timeNow, randSeed)
+	})
+}
+
+// EstimateWithTemplateContent is EstimateWithTemplate for the content of the template and of the fields definition.
+func (gc GeneratorCorpus) EstimateWithTemplateContent(name string, template, fieldsDefinition []byte, sampleEvents, totEvents uint64, indexRatio float64, timeNow time.Time, randSeed int64) (Estimate, error) {
+	return gc.estimate(sampleEvents, totEvents, indexRatio, func(gc GeneratorCorpus, w io.Writer, sampleEvents uint64) error {
+		return gc.GenerateWithTemplateContentTo(w, name, template, fieldsDefinition, sampleEvents, timeNow, randSeed)
+	})
+}
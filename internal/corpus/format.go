@@ -0,0 +1,70 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"errors"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/otlp"
+)
+
+const (
+	// FormatText writes the events as generated, one per line
+	FormatText = "text"
+	// FormatParquet writes the events as the rows of a Parquet file, with a column for each field
+	FormatParquet = "parquet"
+	// FormatOTLPLogs writes the events as the log records of OTLP export requests, encoded with protobuf
+	FormatOTLPLogs = "otlp-logs"
+	// FormatOTLPMetrics writes the numeric fields of the events as the gauges of OTLP export requests, encoded with
+	// protobuf
+	FormatOTLPMetrics = "otlp-metrics"
+
+	parquetExt = ".parquet"
+	otlpExt    = ".pb"
+)
+
+var ErrNotValidFormat = errors.New("please, pass --output-format as one of 'text', 'parquet', 'otlp-logs' or 'otlp-metrics'")
+
+// WithFormat returns a copy of the corpus generator writing the corpus files in format, one of FormatText,
+// FormatParquet, FormatOTLPLogs or FormatOTLPMetrics. With a format other than FormatText the events must be JSON
+// objects, and the bulk create actions are not written.
+func (gc GeneratorCorpus) WithFormat(format string) (GeneratorCorpus, error) {
+	switch format {
+	case FormatText, FormatParquet, FormatOTLPLogs, FormatOTLPMetrics:
+	default:
+		return gc, ErrNotValidFormat
+	}
+
+	gc.format = format
+	return gc, nil
+}
+
+// corpusExt returns the extension of the corpus files, ext for the text ones
+func (gc GeneratorCorpus) corpusExt(ext string) string {
+	switch gc.format {
+	case FormatParquet:
+		ext = parquetExt
+	case FormatOTLPLogs, FormatOTLPMetrics:
+		ext = otlpExt
+	}
+
+	if gc.gzip {
+		ext += gzipExt
+	}
+
+	return ext
+}
+
+// otlpSignal returns the OTLP signal of the format, empty when it's not an OTLP one
+func otlpSignal(format string) string {
+	switch format {
+	case FormatOTLPLogs:
+		return otlp.SignalLogs
+	case FormatOTLPMetrics:
+		return otlp.SignalMetrics
+	}
+
+	return ""
+}
@@ -10,11 +10,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
-	"path"
+	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/otlp"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/parquet"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/telemetry"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
@@ -42,6 +47,8 @@ func NewGenerator(config Config, fs afero.Fs, location string) (GeneratorCorpus,
 		templateType: templateTypeCustom,
 		location:     location,
 		timestamp:    time.Now().Unix,
+		sizeGuard:    newSizeGuard(),
+		truncation:   new(truncation),
 	}, nil
 }
 
@@ -62,6 +69,8 @@ func NewGeneratorWithTemplate(config Config, fs afero.Fs, location, templateType
 		templateType: templateTypeValue,
 		location:     location,
 		timestamp:    time.Now().Unix,
+		sizeGuard:    newSizeGuard(),
+		truncation:   new(truncation),
 	}, nil
 }
 
@@ -79,6 +88,130 @@ type GeneratorCorpus struct {
 	templateType int
 	// timestamp allow overriding value in tests
 	timestamp timestamp
+	// reload receives the configs to apply to a running generation
+	reload <-chan Config
+	// warnings receives the warnings of a running generation, like the configs that cannot be reloaded; os.Stderr
+	// when nil
+	warnings io.Writer
+	// httpClient fetches from the package registry
+	httpClient *http.Client
+	// packageCacheDir keeps the packages downloaded from the package registry; empty means none
+	packageCacheDir string
+	// maxWriteMBps caps the write rate of the corpus file; zero means unlimited
+	maxWriteMBps float64
+	// stateFile is the path of the file the state of the generator is loaded from and saved to; empty means none
+	stateFile string
+	// sizeGuard drops or truncates the events bigger than the size limit
+	sizeGuard sizeGuard
+	// reportFile is the path of the HTML report written at the end of the generation; empty means none
+	reportFile string
+	// telemetry traces and measures the generation; nil means none
+	telemetry *telemetry.Telemetry
+	// dataStream is the data stream the events of Generate are created in; empty means the default one of the package
+	dataStream string
+	// packageConfig applies the default config of the data stream shipped in the package, if any, instead of config
+	packageConfig bool
+	// configSeed uses the `seed` of the config, if any, instead of the seed passed to the generation
+	configSeed bool
+	// gzip compresses the corpus files with gzip at gzipLevel
+	gzip      bool
+	gzipLevel int
+	// format is the format of the corpus files; empty means FormatText
+	format string
+	// crlf ends the lines of the corpus files with `\r\n` instead of `\n`
+	crlf bool
+	// eventsPerSecond is the rate the events are emitted at; zero means as fast as possible
+	eventsPerSecond float64
+	// maxDuration is the time budget of the generation; zero means the `max_duration` of the config, if any
+	maxDuration time.Duration
+	// batchSize is the number of events of each batch written to the sink, for the `per_batch_constant` fields;
+	// zero means the whole generation is one batch
+	batchSize uint64
+	// truncation is shared by the copies of the corpus generator
+	truncation *truncation
+}
+
+// WithMaxWriteMBps returns a copy of the corpus generator writing the corpus file at most at maxWriteMBps MB per second.
+func (gc GeneratorCorpus) WithMaxWriteMBps(maxWriteMBps float64) GeneratorCorpus {
+	gc.maxWriteMBps = maxWriteMBps
+	return gc
+}
+
+// WithStateFile returns a copy of the corpus generator loading the state of the generator from stateFile, when it
+// exists, and saving it to stateFile at the end of the generation, so that the next generation carries on from it.
+func (gc GeneratorCorpus) WithStateFile(stateFile string) GeneratorCorpus {
+	gc.stateFile = stateFile
+	return gc
+}
+
+// WithReportFile returns a copy of the corpus generator writing an HTML report of the generation to reportFile
+// at the end, with the event rate and the throughput over time, and the distributions of the fields.
+func (gc GeneratorCorpus) WithReportFile(reportFile string) GeneratorCorpus {
+	gc.reportFile = reportFile
+	return gc
+}
+
+// WithTelemetry returns a copy of the corpus generator tracing the steps of the generation with tel, and counting
+// the events and the bytes written.
+func (gc GeneratorCorpus) WithTelemetry(tel *telemetry.Telemetry) GeneratorCorpus {
+	gc.telemetry = tel
+	return gc
+}
+
+// WithHTTPClient returns a copy of the corpus generator fetching from the package registry with client.
+func (gc GeneratorCorpus) WithHTTPClient(client *http.Client) GeneratorCorpus {
+	gc.httpClient = client
+	return gc
+}
+
+// WithPackageCacheDir returns a copy of the corpus generator keeping the packages downloaded from the package
+// registry in dir, so that each version of a package is downloaded once.
+func (gc GeneratorCorpus) WithPackageCacheDir(dir string) GeneratorCorpus {
+	gc.packageCacheDir = dir
+	return gc
+}
+
+// WithDataStream returns a copy of the corpus generator creating the events of Generate in dataStream, instead of
+// the default data stream of the package.
+func (gc GeneratorCorpus) WithDataStream(dataStream string) GeneratorCorpus {
+	gc.dataStream = dataStream
+	return gc
+}
+
+// WithPackageConfig returns a copy of the corpus generator applying, when packageConfig is true, the default
+// fields generation configuration of the data stream shipped in the package, if any, instead of its config.
+func (gc GeneratorCorpus) WithPackageConfig(packageConfig bool) GeneratorCorpus {
+	gc.packageConfig = packageConfig
+	return gc
+}
+
+// WithConfigSeed returns a copy of the corpus generator using, when configSeed is true, the `seed` of the config,
+// if set, instead of the seed passed to the generation, like when the seed is not set by the user.
+func (gc GeneratorCorpus) WithConfigSeed(configSeed bool) GeneratorCorpus {
+	gc.configSeed = configSeed
+	return gc
+}
+
+// WithBatchSize returns a copy of the corpus generator writing the events in batches of batchSize events, like
+// the bulk requests of the Elasticsearch sink, so that the `per_batch_constant` fields and the `batchIndex` helper
+// change value at the start of each batch. A zero size means the whole generation is one batch.
+func (gc GeneratorCorpus) WithBatchSize(batchSize uint64) GeneratorCorpus {
+	gc.batchSize = batchSize
+	return gc
+}
+
+// WithConfigReload returns a copy of the corpus generator applying the configs received from reload
+// to the running generation, without resetting the state of the generated fields.
+func (gc GeneratorCorpus) WithConfigReload(reload <-chan Config) GeneratorCorpus {
+	gc.reload = reload
+	return gc
+}
+
+// WithWarnings returns a copy of the corpus generator writing the warnings of the running generation to w, like the
+// configs that cannot be reloaded, instead of os.Stderr.
+func (gc GeneratorCorpus) WithWarnings(w io.Writer) GeneratorCorpus {
+	gc.warnings = w
+	return gc
 }
 
 func (gc GeneratorCorpus) Location() string {
@@ -89,28 +222,58 @@ func (gc GeneratorCorpus) Location() string {
 // To provide unique names the provided slug is prepended with current timestamp.
 func (gc GeneratorCorpus) bulkPayloadFilename(integrationPackage, dataStream, packageVersion string) string {
 	slug := integrationPackage + "-" + dataStream + "-" + packageVersion
-	filename := fmt.Sprintf("%d-%s.ndjson", gc.timestamp(), sanitizeFilename(slug))
-	return filename
+	return gc.corpusFilename(slug, gc.corpusExt(".ndjson"))
 }
 
 // bulkPayloadFilenameWithTemplate computes the bulkPayloadFilename for the corpus to be generated.
 // To provide unique names the provided slug is prepended with current timestamp.
 func (gc GeneratorCorpus) bulkPayloadFilenameWithTemplate(templatePath string) string {
-	slug := path.Base(templatePath)
-	ext := path.Ext(templatePath)
+	slug := filepath.Base(templatePath)
+	ext := filepath.Ext(templatePath)
 	slug = slug[0 : len(slug)-len(ext)]
-	filename := fmt.Sprintf("%d-%s%s", gc.timestamp(), sanitizeFilename(slug), sanitizeFilename(ext))
-	return filename
+	return gc.corpusFilename(slug, gc.corpusExt(sanitizeFilename(ext)))
+}
+
+// maxFilenameLength is the maximum length in bytes of a file name on the common file systems, like ext4, APFS
+// and NTFS
+const maxFilenameLength = 255
+
+// corpusFilename returns the name of a corpus file with slug and ext, prefixed with the current timestamp, the
+// slug being sanitized and shortened for the name not to be longer than maxFilenameLength
+func (gc GeneratorCorpus) corpusFilename(slug, ext string) string {
+	prefix := fmt.Sprintf("%d-", gc.timestamp())
+	slug = sanitizeFilename(slug)
+	if maxLength := maxFilenameLength - len(prefix) - len(ext); len(slug) > maxLength {
+		// not splitting a multi-byte character
+		for maxLength > 0 && !utf8.RuneStart(slug[maxLength]) {
+			maxLength--
+		}
+
+		slug = slug[:maxLength]
+	}
+
+	return prefix + slug + ext
 }
 
 var corpusLocPerm = os.FileMode(0770)
 var corpusPerm = os.FileMode(0660)
 
-func (gc GeneratorCorpus) eventsPayloadFromFields(template []byte, fields Fields, totEvents uint64, timeNow time.Time, randSeed int64, createPayload []byte, f afero.File) error {
+func (gc GeneratorCorpus) eventsPayloadFromFields(name string, template []byte, fields Fields, totEvents uint64, timeNow time.Time, randSeed int64, createPayload []byte, f io.Writer) (err error) {
+	span := gc.telemetry.StartSpan("corpus.generate", nil, telemetry.String("corpus.name", name), telemetry.Int("corpus.events.total", int64(totEvents)))
+	defer func() {
+		span.End(err)
+	}()
+
+	eventsCounter := gc.telemetry.Counter("corpus.events", "{event}", "events written to the corpus")
+	bytesCounter := gc.telemetry.Counter("corpus.bytes", "By", "bytes written to the corpus")
+
 	genlib.InitGeneratorTimeNow(timeNow)
-	genlib.InitGeneratorRandSeed(randSeed)
 
-	opts := []genlib.Option{genlib.WithRandSeed(randSeed)}
+	if seed, ok := gc.config.Seed(); ok && gc.configSeed {
+		randSeed = seed
+	}
+
+	opts := []genlib.Option{genlib.WithRandSeed(randSeed), genlib.WithBatchSize(gc.batchSize)}
 
 	// Determine template type and set appropriate option
 	switch {
@@ -124,11 +287,53 @@ func (gc GeneratorCorpus) eventsPayloadFromFields(template []byte, fields Fields
 		return ErrNotValidTemplate
 	}
 
+	asserts, err := newAssertions(gc.config, fields)
+	if err != nil {
+		return err
+	}
+
+	var report *runReport
+	if len(gc.reportFile) > 0 {
+		report = newRunReport(randSeed)
+	}
+
+	var afterField []genlib.AfterFieldHook
+	if report != nil {
+		afterField = append(afterField, report.afterField)
+	}
+
+	// the values of the assertions are the ones written
+	if asserts != nil {
+		afterField = append(afterField, asserts.afterField)
+	}
+
+	if len(afterField) > 0 {
+		opts = append(opts, genlib.WithAfterField(chainAfterField(afterField)))
+	}
+
+	if err := gc.checkTimelineRate(gc.config); err != nil {
+		return err
+	}
+
+	var pace *pacer
+	if gc.eventsPerSecond > 0 {
+		pace = newPacer(gc.eventsPerSecond)
+		opts = append(opts, genlib.WithTimelineStep(timelineRate(pace, gc.eventsPerSecond)))
+	}
+
 	evgen, err := genlib.NewGenerator(gc.config, fields, totEvents, opts...)
 	if err != nil {
 		return err
 	}
 
+	// the rows of the Parquet files, and the records of the OTLP ones, are the events only
+	if gc.format == FormatParquet || len(otlpSignal(gc.format)) > 0 {
+		createPayload = nil
+	}
+
+	createPayload = gc.withLineEnd(createPayload)
+	lineEnd := gc.lineEnd()
+
 	var buf *bytes.Buffer
 	if len(template) == 0 {
 		buf = bytes.NewBuffer(createPayload)
@@ -140,18 +345,121 @@ func (gc GeneratorCorpus) eventsPayloadFromFields(template []byte, fields Fields
 		_ = evgen.Close()
 	}()
 
+	loadSpan := gc.telemetry.StartSpan("corpus.load_state", span)
+	err = gc.loadState(evgen)
+	loadSpan.End(err)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = f
+	if gc.maxWriteMBps > 0 {
+		w = newThrottledWriter(f, gc.maxWriteMBps)
+	}
+
+	// fw encodes the events in the format of the corpus file, when not FormatText
+	var fw io.WriteCloser
+	switch {
+	case gc.format == FormatParquet:
+		if fw, err = parquet.NewWriter(w, fields, parquet.Options{}); err != nil {
+			return err
+		}
+	case len(otlpSignal(gc.format)) > 0:
+		if fw, err = otlp.NewWriter(w, otlpSignal(gc.format), otlp.Options{}); err != nil {
+			return err
+		}
+	}
+
+	if fw != nil {
+		w = fw
+	}
+
+	deadline := gc.startDeadline(time.Now())
+	var emitted, written uint64
 	for {
-		buf.Truncate(len(createPayload))
-		err := evgen.Emit(buf)
-		if err == nil {
-			buf.WriteByte('\n')
+		select {
+		case cfg := <-gc.reload:
+			reloadSpan := gc.telemetry.StartSpan("corpus.reload_config", span)
+			err := gc.checkTimelineRate(cfg)
+			if err == nil {
+				err = evgen.Reload(cfg)
+			}
 
-			if _, err = f.Write(buf.Bytes()); err != nil {
-				return err
+			reloadSpan.End(err)
+			// as for the configs that cannot be loaded, the generation goes on with the current one
+			if err != nil {
+				fmt.Fprintln(gc.warningsWriter(), "Cannot reload config, keeping the current one:", err)
+			} else if pace != nil {
+				// the steps of the timeline already due set the rate again
+				pace.setRate(gc.eventsPerSecond)
+			}
+		default:
+		}
+
+		var err error
+		if !deadline.IsZero() && (totEvents == 0 || emitted < totEvents) && !time.Now().Before(deadline) {
+			// the time budget is spent: the corpus is closed as if all the events were generated
+			gc.truncate(written)
+			err = io.EOF
+		} else {
+			if pace != nil {
+				pace.wait()
+			}
+
+			buf.Truncate(len(createPayload))
+			err = evgen.Emit(buf)
+			if err == nil {
+				emitted++
+			}
+
+			if err == nil && gc.sizeGuard.check(buf, len(createPayload)) {
+				buf.WriteString(lineEnd)
+
+				if _, err = w.Write(buf.Bytes()); err != nil {
+					return err
+				}
+
+				if report != nil {
+					report.written(buf.Len())
+				}
+
+				if asserts != nil {
+					asserts.written()
+				}
+
+				written++
+				eventsCounter.Add(1)
+				bytesCounter.Add(int64(buf.Len()))
 			}
 		}
 
 		if err == io.EOF {
+			if fw != nil {
+				if err := fw.Close(); err != nil {
+					return err
+				}
+			}
+
+			saveSpan := gc.telemetry.StartSpan("corpus.save_state", span)
+			err = gc.saveState(evgen)
+			saveSpan.End(err)
+			if err != nil {
+				return err
+			}
+
+			if report != nil {
+				reportSpan := gc.telemetry.StartSpan("corpus.write_report", span)
+				err = report.write(gc, name)
+				reportSpan.End(err)
+				if err != nil {
+					return err
+				}
+			}
+
+			if asserts != nil {
+				return asserts.check()
+			}
+
 			return nil
 		}
 
@@ -161,36 +469,126 @@ func (gc GeneratorCorpus) eventsPayloadFromFields(template []byte, fields Fields
 	}
 }
 
+// warningsWriter returns the writer of the warnings of the running generation
+func (gc GeneratorCorpus) warningsWriter() io.Writer {
+	if gc.warnings == nil {
+		return os.Stderr
+	}
+
+	return gc.warnings
+}
+
+// chainAfterField returns an AfterFieldHook calling the hooks in order, each with the value returned by the previous one
+func chainAfterField(hooks []genlib.AfterFieldHook) genlib.AfterFieldHook {
+	return func(event uint64, field string, value any) (any, error) {
+		var err error
+		for _, hook := range hooks {
+			if value, err = hook(event, field, value); err != nil {
+				return nil, err
+			}
+		}
+
+		return value, nil
+	}
+}
+
+// loadState loads the state of evgen from the state file, if any
+func (gc GeneratorCorpus) loadState(evgen genlib.Generator) error {
+	if len(gc.stateFile) == 0 {
+		return nil
+	}
+
+	f, err := gc.fs.Open(gc.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	if err := evgen.LoadState(f); err != nil {
+		return fmt.Errorf("cannot load state file %s: %w", gc.stateFile, err)
+	}
+
+	return nil
+}
+
+// saveState saves the state of evgen to the state file, if any
+func (gc GeneratorCorpus) saveState(evgen genlib.Generator) error {
+	if len(gc.stateFile) == 0 {
+		return nil
+	}
+
+	f, err := gc.fs.OpenFile(gc.stateFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, corpusPerm)
+	if err != nil {
+		return err
+	}
+
+	if err := evgen.SaveState(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("cannot save state file %s: %w", gc.stateFile, err)
+	}
+
+	return f.Close()
+}
+
 // Generate generates a bulk request corpus and persist it to file.
 func (gc GeneratorCorpus) Generate(packageRegistryBaseURL, integrationPackage, dataStream, packageVersion string, totEvents uint64, timeNow time.Time, randSeed int64) (string, error) {
 	if err := gc.fs.MkdirAll(gc.location, corpusLocPerm); err != nil {
 		return "", fmt.Errorf("cannot generate corpus location folder: %v", err)
 	}
 
-	payloadFilename := path.Join(gc.location, gc.bulkPayloadFilename(integrationPackage, dataStream, packageVersion))
-	f, err := gc.fs.OpenFile(payloadFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, corpusPerm)
+	payloadFilename := filepath.Join(gc.location, gc.bulkPayloadFilename(integrationPackage, dataStream, packageVersion))
+	f, err := gc.createCorpusFile(payloadFilename)
 	if err != nil {
 		return "", err
 	}
 
-	ctx := context.Background()
-	flds, dataStreamType, err := fields.LoadFields(ctx, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion)
-	if err != nil {
+	if err := gc.GenerateTo(f, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, totEvents, timeNow, randSeed); err != nil {
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
 		return "", err
 	}
 
-	createPayload := []byte(`{ "create" : { "_index": "` + dataStreamType + `-` + integrationPackage + `.` + dataStream + `-default" } }` + "\n")
+	return payloadFilename, err
+}
+
+// GenerateTo generates a bulk request corpus and writes it to w, each event with its bulk create action.
+func (gc GeneratorCorpus) GenerateTo(w io.Writer, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion string, totEvents uint64, timeNow time.Time, randSeed int64) error {
+	ctx := context.Background()
+	var loadOpts []fields.LoadOption
+	if gc.httpClient != nil {
+		loadOpts = append(loadOpts, fields.WithHTTPClient(gc.httpClient))
+	}
+
+	if len(gc.packageCacheDir) > 0 {
+		loadOpts = append(loadOpts, fields.WithCacheDir(gc.packageCacheDir))
+	}
 
-	err = gc.eventsPayloadFromFields(nil, flds, totEvents, timeNow, randSeed, createPayload, f)
+	flds, dataStreamType, configFs, err := fields.LoadFieldsWithDefaultConfig(ctx, packageRegistryBaseURL, integrationPackage, dataStream, packageVersion, loadOpts...)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	if err := f.Close(); err != nil {
-		return "", err
+	if gc.packageConfig && configFs != nil {
+		if gc.config, err = config.LoadConfig(configFs, fields.DefaultConfigFile); err != nil {
+			return fmt.Errorf("cannot load the default config of the package: %w", err)
+		}
 	}
 
-	return payloadFilename, err
+	index := gc.dataStream
+	if len(index) == 0 {
+		index = dataStreamType + `-` + integrationPackage + `.` + dataStream + `-default`
+	}
+
+	createPayload := []byte(`{ "create" : { "_index": "` + index + `" } }` + "\n")
+
+	return gc.eventsPayloadFromFields(integrationPackage+"."+dataStream+"-"+packageVersion, nil, flds, totEvents, timeNow, randSeed, createPayload, w)
 }
 
 // GenerateWithTemplate generates a template based corpus and persist it to file.
@@ -199,46 +597,159 @@ func (gc GeneratorCorpus) GenerateWithTemplate(templatePath, fieldsDefinitionPat
 		return "", fmt.Errorf("cannot generate corpus location folder: %v", err)
 	}
 
-	payloadFilename := path.Join(gc.location, gc.bulkPayloadFilenameWithTemplate(templatePath))
-	f, err := gc.fs.OpenFile(payloadFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, corpusPerm)
+	payloadFilename := filepath.Join(gc.location, gc.bulkPayloadFilenameWithTemplate(templatePath))
+	f, err := gc.createCorpusFile(payloadFilename)
 	if err != nil {
 		return "", err
 	}
 
+	if err := gc.GenerateWithTemplateTo(f, templatePath, fieldsDefinitionPath, totEvents, timeNow, randSeed); err != nil {
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	return payloadFilename, err
+}
+
+// GenerateWithTemplateTo generates a template based corpus and writes it to w.
+func (gc GeneratorCorpus) GenerateWithTemplateTo(w io.Writer, templatePath, fieldsDefinitionPath string, totEvents uint64, timeNow time.Time, randSeed int64) error {
 	template, err := os.ReadFile(templatePath)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	if len(template) == 0 {
-		return "", errors.New("you must provide a non empty template content")
+		return errors.New("you must provide a non empty template content")
 	}
 
 	ctx := context.Background()
 	flds, err := fields.LoadFieldsWithTemplate(ctx, fieldsDefinitionPath)
 	if err != nil {
+		return err
+	}
+
+	return gc.eventsPayloadFromFields(filepath.Base(templatePath), template, flds, totEvents, timeNow, randSeed, nil, w)
+}
+
+// GenerateWithTemplateContent generates a template based corpus from the content of the template and of the
+// fields definition, and persist it to file named after name.
+func (gc GeneratorCorpus) GenerateWithTemplateContent(name string, template, fieldsDefinition []byte, totEvents uint64, timeNow time.Time, randSeed int64) (string, error) {
+	if err := gc.fs.MkdirAll(gc.location, corpusLocPerm); err != nil {
+		return "", fmt.Errorf("cannot generate corpus location folder: %v", err)
+	}
+
+	payloadFilename := filepath.Join(gc.location, gc.bulkPayloadFilenameWithTemplate(name))
+	f, err := gc.createCorpusFile(payloadFilename)
+	if err != nil {
+		return "", err
+	}
+
+	if err := gc.GenerateWithTemplateContentTo(f, name, template, fieldsDefinition, totEvents, timeNow, randSeed); err != nil {
 		return "", err
 	}
 
-	err = gc.eventsPayloadFromFields(template, flds, totEvents, timeNow, randSeed, nil, f)
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	return payloadFilename, nil
+}
+
+// GenerateWithTemplateContentTo generates a template based corpus from the content of the template and of the
+// fields definition, named after name, and writes it to w.
+func (gc GeneratorCorpus) GenerateWithTemplateContentTo(w io.Writer, name string, template, fieldsDefinition []byte, totEvents uint64, timeNow time.Time, randSeed int64) error {
+	if len(template) == 0 {
+		return errors.New("you must provide a non empty template content")
+	}
+
+	ctx := context.Background()
+	flds, err := fields.LoadFieldsWithTemplateFromString(ctx, string(fieldsDefinition))
+	if err != nil {
+		return err
+	}
+
+	return gc.eventsPayloadFromFields(name, template, flds, totEvents, timeNow, randSeed, nil, w)
+}
+
+// GenerateFromMapping generates a corpus of documents with the fields of the mapping of index in the Elasticsearch
+// at esURL, and persist it to file. When fieldsOutput is set, the fields are written there too as a fields
+// definition.
+func (gc GeneratorCorpus) GenerateFromMapping(esURL, index, fieldsOutput string, totEvents uint64, timeNow time.Time, randSeed int64) (string, error) {
+	if err := gc.fs.MkdirAll(gc.location, corpusLocPerm); err != nil {
+		return "", fmt.Errorf("cannot generate corpus location folder: %v", err)
+	}
+
+	payloadFilename := filepath.Join(gc.location, gc.corpusFilename(index, gc.corpusExt(".ndjson")))
+	f, err := gc.createCorpusFile(payloadFilename)
 	if err != nil {
 		return "", err
 	}
 
+	if err := gc.GenerateFromMappingTo(f, esURL, index, fieldsOutput, totEvents, timeNow, randSeed); err != nil {
+		return "", err
+	}
+
 	if err := f.Close(); err != nil {
 		return "", err
 	}
 
-	return payloadFilename, err
+	return payloadFilename, nil
+}
+
+// GenerateFromMappingTo generates a corpus of documents with the fields of the mapping of index in the
+// Elasticsearch at esURL, and writes it to w. When fieldsOutput is set, the fields are written there too as a
+// fields definition.
+func (gc GeneratorCorpus) GenerateFromMappingTo(w io.Writer, esURL, index, fieldsOutput string, totEvents uint64, timeNow time.Time, randSeed int64) error {
+	ctx := context.Background()
+	var loadOpts []fields.LoadOption
+	if gc.httpClient != nil {
+		loadOpts = append(loadOpts, fields.WithHTTPClient(gc.httpClient))
+	}
+
+	flds, err := fields.LoadFieldsFromMapping(ctx, esURL, index, loadOpts...)
+	if err != nil {
+		return err
+	}
+
+	if len(fieldsOutput) > 0 {
+		if err := gc.writeFieldsDefinition(fieldsOutput, flds); err != nil {
+			return err
+		}
+	}
+
+	return gc.eventsPayloadFromFields(index, nil, flds, totEvents, timeNow, randSeed, nil, w)
+}
+
+// writeFieldsDefinition writes flds to the file at path as a fields definition
+func (gc GeneratorCorpus) writeFieldsDefinition(path string, flds fields.Fields) error {
+	f, err := gc.fs.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := fields.WriteYaml(f, flds); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("cannot write fields definition %s: %w", path, err)
+	}
+
+	return f.Close()
 }
 
 // sanitizeFilename takes care of removing dangerous elements from a string so it can be safely
-// used as a bulkPayloadFilename.
+// used as a bulkPayloadFilename, on Windows too: the characters reserved by Windows and the control
+// characters are replaced, and the trailing dots, dropped by Windows, are removed.
 // NOTE: does not prevent command injection or ensure complete escaping of input
 func sanitizeFilename(s string) string {
-	s = strings.Replace(s, " ", "-", -1)
-	s = strings.Replace(s, ":", "-", -1)
-	s = strings.Replace(s, "/", "-", -1)
-	s = strings.Replace(s, "\\", "-", -1)
-	return s
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f || strings.ContainsRune(` <>:"/\|?*`, r) {
+			return '-'
+		}
+
+		return r
+	}, s)
+
+	return strings.TrimRight(s, ".")
 }
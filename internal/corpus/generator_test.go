@@ -5,10 +5,29 @@
 package corpus
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/telemetry"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
 )
 
 func TestFilename(t *testing.T) {
@@ -19,6 +38,16 @@ func TestFilename(t *testing.T) {
 	assert.Equal(t, expected, got)
 }
 
+func TestFilename_long(t *testing.T) {
+	fc := TestNewGenerator()
+
+	got := fc.bulkPayloadFilenameWithTemplate(strings.Repeat("é", 200) + ".ndjson")
+	assert.LessOrEqual(t, len(got), maxFilenameLength)
+	assert.True(t, utf8.ValidString(got))
+	assert.True(t, strings.HasPrefix(got, "1647345675-é"))
+	assert.True(t, strings.HasSuffix(got, "é.ndjson"))
+}
+
 func TestSanitizeFilename(t *testing.T) {
 	type test struct {
 		input string
@@ -31,6 +60,9 @@ func TestSanitizeFilename(t *testing.T) {
 		{input: "foo/bar", want: "foo-bar"},
 		{input: "foo\\bar", want: "foo-bar"},
 		{input: "foo bar/foobar\\", want: "foo-bar-foobar-"},
+		{input: `C:\logs\a<b>|"c"?*`, want: "C--logs-a-b---c---"},
+		{input: "foo\tbar...", want: "foo-bar"},
+		{input: "café", want: "café"},
 	}
 
 	for _, tc := range tests {
@@ -40,3 +72,752 @@ func TestSanitizeFilename(t *testing.T) {
 		}
 	}
 }
+
+func TestStateFile(t *testing.T) {
+	cfg, err := config.LoadConfigFromYaml([]byte("fields:\n  - name: count\n    counter: true"))
+	require.NoError(t, err)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(cfg, fs, "corpora", "placeholder")
+	require.NoError(t, err)
+	fc = fc.WithStateFile("state")
+
+	template := []byte(`{"count":{{.count}}}`)
+	fieldsDefinition := []byte("- name: count\n  type: long\n")
+
+	lastCount := func() int64 {
+		payloadFilename, err := fc.GenerateWithTemplateContent("counter.tpl", template, fieldsDefinition, 5, time.Now(), 1)
+		require.NoError(t, err)
+
+		payload, err := afero.ReadFile(fs, payloadFilename)
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(string(payload)), "\n")
+		var event struct{ Count int64 }
+		require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &event))
+		return event.Count
+	}
+
+	first := lastCount()
+	exists, err := afero.Exists(fs, "state")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.Greater(t, lastCount(), first)
+}
+
+func TestGzip(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "corpora", "placeholder")
+	require.NoError(t, err)
+	fc.timestamp = func() int64 { return 1647345675 }
+
+	_, err = fc.WithGzip(10)
+	assert.ErrorIs(t, err, ErrNotValidGzipLevel)
+
+	fc, err = fc.WithGzip(gzip.BestCompression)
+	require.NoError(t, err)
+
+	template := []byte(`{"name":"{{.name}}"}`)
+	fieldsDefinition := []byte("- name: name\n  type: keyword\n")
+
+	payloadFilename, err := fc.GenerateWithTemplateContent("gzip.ndjson", template, fieldsDefinition, 5, time.Now(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "corpora/1647345675-gzip.ndjson.gz", payloadFilename)
+	assert.Equal(t, "1647345675-integration-data_stream-0.0.1.ndjson.gz", fc.bulkPayloadFilename("integration", "data_stream", "0.0.1"))
+
+	f, err := fs.Open(payloadFilename)
+	require.NoError(t, err)
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	payload, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(payload)), "\n")
+	require.Len(t, lines, 5)
+	for _, line := range lines {
+		var event struct{ Name string }
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		assert.NotEmpty(t, event.Name)
+	}
+}
+
+func TestParquet(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "corpora", "placeholder")
+	require.NoError(t, err)
+	fc.timestamp = func() int64 { return 1647345675 }
+
+	_, err = fc.WithFormat("csv")
+	assert.ErrorIs(t, err, ErrNotValidFormat)
+
+	fc, err = fc.WithFormat(FormatParquet)
+	require.NoError(t, err)
+
+	template := []byte(`{"name":"{{.name}}","size":{{.size}}}`)
+	fieldsDefinition := []byte("- name: name\n  type: keyword\n- name: size\n  type: long\n")
+
+	payloadFilename, err := fc.GenerateWithTemplateContent("parquet.ndjson", template, fieldsDefinition, 5, time.Now(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "corpora/1647345675-parquet.parquet", payloadFilename)
+	assert.Equal(t, "1647345675-integration-data_stream-0.0.1.parquet", fc.bulkPayloadFilename("integration", "data_stream", "0.0.1"))
+
+	payload, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(payload, []byte("PAR1")))
+	assert.True(t, bytes.HasSuffix(payload, []byte("PAR1")))
+	assert.Contains(t, string(payload), "size")
+
+	// the events that are not JSON objects cannot be written
+	_, err = fc.GenerateWithTemplateContent("parquet.ndjson", []byte(`{{.name}}`), fieldsDefinition, 5, time.Now(), 1)
+	assert.Error(t, err)
+}
+
+func TestOTLP(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "corpora", "placeholder")
+	require.NoError(t, err)
+	fc.timestamp = func() int64 { return 1647345675 }
+
+	fc, err = fc.WithFormat(FormatOTLPLogs)
+	require.NoError(t, err)
+
+	template := []byte(`{"message":"{{.name}}","size":{{.size}}}`)
+	fieldsDefinition := []byte("- name: name\n  type: keyword\n- name: size\n  type: long\n")
+
+	payloadFilename, err := fc.GenerateWithTemplateContent("otlp.ndjson", template, fieldsDefinition, 5, time.Now(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "corpora/1647345675-otlp.pb", payloadFilename)
+
+	payload, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+	require.Greater(t, len(payload), 4)
+	assert.Equal(t, len(payload)-4, int(binary.BigEndian.Uint32(payload)), "a single export request")
+	assert.Contains(t, string(payload), "size")
+
+	// the events that are not JSON objects cannot be written
+	_, err = fc.GenerateWithTemplateContent("otlp.ndjson", []byte(`{{.name}}`), fieldsDefinition, 5, time.Now(), 1)
+	assert.Error(t, err)
+}
+
+func TestNewline(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "corpora", "placeholder")
+	require.NoError(t, err)
+
+	_, err = fc.WithNewline("cr")
+	assert.ErrorIs(t, err, ErrNotValidNewline)
+
+	fc, err = fc.WithNewline(NewlineCRLF)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	fieldsDefinition := []byte("- name: name\n  type: keyword\n")
+	require.NoError(t, fc.GenerateWithTemplateContentTo(&buf, "crlf.ndjson", []byte(`{"name":"{{.name}}"}`), fieldsDefinition, 3, time.Now(), 1))
+	assert.Equal(t, 3, strings.Count(buf.String(), "\r\n"))
+	assert.Equal(t, 3, strings.Count(buf.String(), "\n"))
+
+	// the bulk create actions end with the same newline as the events
+	assert.Equal(t, []byte("{\"create\":{}}\r\n"), fc.withLineEnd([]byte("{\"create\":{}}\n")))
+
+	// the other formats are not made of lines
+	fc, err = fc.WithFormat(FormatParquet)
+	require.NoError(t, err)
+	assert.Equal(t, "\n", fc.lineEnd())
+}
+
+func TestConfigReload(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "corpora", "placeholder")
+	require.NoError(t, err)
+
+	// the config parses, but its correlation of a field not in the fields definition cannot be bound
+	cfg, err := config.LoadConfigFromYaml([]byte("correlations:\n  - cardinality: 2\n    fields: [name, missing]\n"))
+	require.NoError(t, err)
+
+	reload := make(chan Config, 1)
+	reload <- cfg
+
+	var buf, warnings bytes.Buffer
+	fieldsDefinition := []byte("- name: name\n  type: keyword\n")
+	fc = fc.WithConfigReload(reload).WithWarnings(&warnings)
+	require.NoError(t, fc.GenerateWithTemplateContentTo(&buf, "reload.ndjson", []byte(`{"name":"{{.name}}"}`), fieldsDefinition, 5, time.Now(), 1))
+
+	// the generation goes on with the current config
+	assert.Equal(t, 5, strings.Count(buf.String(), "\n"))
+	assert.Contains(t, warnings.String(), "Cannot reload config, keeping the current one: correlation #0:")
+}
+
+func TestMaxEventSize(t *testing.T) {
+	cfg, err := config.LoadConfigFromYaml([]byte("fields:\n  - name: message\n    enum: [\"short\", \"a much longer message, over the limit\"]"))
+	require.NoError(t, err)
+
+	template := []byte(`{{.message}}`)
+	fieldsDefinition := []byte("- name: message\n  type: keyword\n")
+
+	testCases := []struct {
+		policy   string
+		expected []string
+	}{
+		{policy: OversizeDrop, expected: []string{"short"}},
+		{policy: OversizeTruncate, expected: []string{"short", "a much lon"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.policy, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			fc, err := NewGeneratorWithTemplate(cfg, fs, "corpora", "placeholder")
+			require.NoError(t, err)
+
+			fc, err = fc.WithMaxEventSize(10, testCase.policy)
+			require.NoError(t, err)
+
+			payloadFilename, err := fc.GenerateWithTemplateContent("message.tpl", template, fieldsDefinition, 50, time.Now(), 1)
+			require.NoError(t, err)
+
+			payload, err := afero.ReadFile(fs, payloadFilename)
+			require.NoError(t, err)
+
+			lines := strings.Split(strings.TrimSpace(string(payload)), "\n")
+			var oversize uint64
+			for _, line := range lines {
+				assert.Contains(t, testCase.expected, line)
+				if line != "short" {
+					oversize++
+				}
+			}
+
+			if testCase.policy == OversizeDrop {
+				oversize = 50 - uint64(len(lines))
+			}
+
+			assert.Greater(t, fc.OversizeEvents(), uint64(0))
+			assert.Equal(t, oversize, fc.OversizeEvents())
+		})
+	}
+
+	_, err = TestNewGenerator().WithMaxEventSize(10, "split")
+	assert.ErrorIs(t, err, ErrNotValidOversize)
+}
+
+func TestMaxDuration(t *testing.T) {
+	template := []byte(`{{.message}}`)
+	fieldsDefinition := []byte("- name: message\n  type: keyword\n")
+
+	testCases := []struct {
+		scenario    string
+		config      string
+		maxDuration time.Duration
+		truncated   bool
+	}{
+		{scenario: "no budget", config: "fields:\n  - name: message\n    value: a"},
+		{scenario: "budget of the config spent", config: "max_duration: 1ns\nfields:\n  - name: message\n    value: a", truncated: true},
+		{scenario: "budget of the flag spent", config: "fields:\n  - name: message\n    value: a", maxDuration: time.Nanosecond, truncated: true},
+		{scenario: "budget of the flag overrides the config", config: "max_duration: 1ns\nfields:\n  - name: message\n    value: a", maxDuration: time.Hour},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := config.LoadConfigFromYaml([]byte(testCase.config))
+			require.NoError(t, err)
+
+			fs := afero.NewMemMapFs()
+			fc, err := NewGeneratorWithTemplate(cfg, fs, "corpora", "placeholder")
+			require.NoError(t, err)
+
+			fc, err = fc.WithMaxDuration(testCase.maxDuration)
+			require.NoError(t, err)
+
+			payloadFilename, err := fc.GenerateWithTemplateContent("message.tpl", template, fieldsDefinition, 50, time.Now(), 1)
+			require.NoError(t, err)
+
+			payload, err := afero.ReadFile(fs, payloadFilename)
+			require.NoError(t, err)
+
+			events, truncated := fc.Truncated()
+			assert.Equal(t, testCase.truncated, truncated)
+			if truncated {
+				assert.Equal(t, strings.Repeat("\"a\"\n", int(events)), string(payload))
+				return
+			}
+
+			assert.Equal(t, strings.Repeat("\"a\"\n", 50), string(payload))
+		})
+	}
+
+	_, err := TestNewGenerator().WithMaxDuration(-time.Second)
+	assert.ErrorIs(t, err, ErrNotValidMaxDuration)
+}
+
+func TestEstimate(t *testing.T) {
+	cfg, err := config.LoadConfigFromYaml([]byte("fields:\n  - name: message\n    value: a"))
+	require.NoError(t, err)
+
+	template := []byte(`{{.message}}`)
+	fieldsDefinition := []byte("- name: message\n  type: keyword\n")
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(cfg, fs, "corpora", "placeholder")
+	require.NoError(t, err)
+
+	estimate, err := fc.EstimateWithTemplateContent("message.tpl", template, fieldsDefinition, 100, 1000, 2, time.Now(), 1)
+	require.NoError(t, err)
+
+	// each event is `"a"` and a new line
+	assert.Equal(t, uint64(100), estimate.SampleEvents)
+	assert.Equal(t, uint64(400), estimate.SampleBytes)
+	assert.Equal(t, uint64(1000), estimate.TotEvents)
+	assert.Equal(t, uint64(4000), estimate.Bytes)
+	assert.Equal(t, uint64(8000), estimate.IndexBytes)
+	assert.Greater(t, estimate.GzipBytes, uint64(0))
+	assert.Less(t, estimate.GzipBytes, estimate.Bytes)
+
+	// nothing is written to the corpus location
+	files, err := afero.ReadDir(fs, "corpora")
+	if err == nil {
+		assert.Empty(t, files)
+	}
+
+	fc, err = fc.WithRate("10/s")
+	require.NoError(t, err)
+
+	estimate, err = fc.EstimateWithTemplateContent("message.tpl", template, fieldsDefinition, 2000, 1000, DefaultIndexRatio, time.Now(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), estimate.SampleEvents)
+	assert.Equal(t, 100*time.Second, estimate.Duration)
+
+	_, err = fc.EstimateWithTemplateContent("message.tpl", template, fieldsDefinition, 100, 0, DefaultIndexRatio, time.Now(), 1)
+	assert.ErrorIs(t, err, ErrNotValidEstimate)
+}
+
+func TestAssertions(t *testing.T) {
+	template := []byte(`{"method":"{{.method}}","bytes":{{.bytes}}}`)
+	fieldsDefinition := []byte("- name: method\n  type: keyword\n- name: bytes\n  type: long\n")
+
+	testCases := []struct {
+		scenario string
+		asserts  string
+		failed   int
+	}{
+		{
+			scenario: "verified",
+			asserts:  "cardinality_between: [3, 3]",
+			failed:   0,
+		},
+		{
+			scenario: "cardinality not verified",
+			asserts:  "cardinality_between: [5, 10]",
+			failed:   1,
+		},
+		{
+			scenario: "mean of keywords",
+			asserts:  "mean_between: [0, 10]",
+			failed:   1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := config.LoadConfigFromYaml([]byte(`fields:
+  - name: method
+    enum: ["GET", "POST", "PUT"]
+    assert: {` + testCase.asserts + `}
+  - name: bytes
+    range: {min: 40, max: 60}
+    assert: {mean_between: [40, 60], cardinality_between: [2, 21]}`))
+			require.NoError(t, err)
+
+			fs := afero.NewMemMapFs()
+			fc, err := NewGeneratorWithTemplate(cfg, fs, "corpora", "placeholder")
+			require.NoError(t, err)
+
+			_, err = fc.GenerateWithTemplateContent("asserts.tpl", template, fieldsDefinition, 200, time.Now(), 1)
+			if testCase.failed == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrAssertionFailed)
+			assert.Len(t, multierr.Errors(err), testCase.failed)
+		})
+	}
+}
+
+func TestAssertions_droppedEvents(t *testing.T) {
+	template := []byte(`{"method":"{{.method}}","bytes":{{.bytes}}}`)
+	fieldsDefinition := []byte("- name: method\n  type: keyword\n- name: bytes\n  type: long\n")
+
+	cfg, err := config.LoadConfigFromYaml([]byte(`fields:
+  - name: method
+    enum: ["GET", "POST", "PUT"]
+    assert: {cardinality_between: [2, 2]}
+  - name: bytes
+    range: {min: 40, max: 60}`))
+	require.NoError(t, err)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(cfg, fs, "corpora", "placeholder")
+	require.NoError(t, err)
+
+	// the POST events are one byte too big, and they are dropped: their values are not counted
+	fc, err = fc.WithMaxEventSize(len(`{"method":"GET","bytes":40}`), OversizeDrop)
+	require.NoError(t, err)
+
+	_, err = fc.GenerateWithTemplateContent("asserts.tpl", template, fieldsDefinition, 200, time.Now(), 1)
+	require.NoError(t, err)
+}
+
+func TestReportFile(t *testing.T) {
+	template := []byte(`{"method":"{{.method}}","bytes":{{.bytes}}}`)
+	fieldsDefinition := []byte("- name: method\n  type: keyword\n- name: bytes\n  type: long\n")
+
+	cfg, err := config.LoadConfigFromYaml([]byte(`fields:
+  - name: method
+    enum: ["GET", "POST", "PUT"]
+    assert: {cardinality_between: [3, 3]}
+  - name: bytes
+    range: {min: 40, max: 60}`))
+	require.NoError(t, err)
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(cfg, fs, "corpora", "placeholder")
+	require.NoError(t, err)
+
+	fc = fc.WithReportFile("report.html")
+	_, err = fc.GenerateWithTemplateContent("report.tpl", template, fieldsDefinition, 200, time.Now(), 1)
+	require.NoError(t, err)
+
+	report, err := afero.ReadFile(fs, "report.html")
+	require.NoError(t, err)
+
+	assert.Contains(t, string(report), "Corpus generation report: report.tpl")
+	assert.Contains(t, string(report), "<tr><th>Events</th><td>200</td></tr>")
+	assert.Contains(t, string(report), `<a href="#field-method">method</a></td><td>200</td><td>3</td>`)
+	assert.Contains(t, string(report), "<td>GET</td>")
+	assert.Contains(t, string(report), "Distribution of the values")
+}
+
+func TestTelemetry(t *testing.T) {
+	var mu sync.Mutex
+	bodies := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mu.Lock()
+		bodies[r.URL.Path] += string(body)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	tel, err := telemetry.New(telemetry.Options{Endpoint: server.URL, ServiceName: "corpus-generator", Interval: time.Hour})
+	require.NoError(t, err)
+
+	template := []byte(`{"method":"{{.method}}"}`)
+	fieldsDefinition := []byte("- name: method\n  type: keyword\n")
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGeneratorWithTemplate(Config{}, fs, "corpora", "placeholder")
+	require.NoError(t, err)
+
+	fc = fc.WithTelemetry(tel)
+	_, err = fc.GenerateWithTemplateContent("telemetry.tpl", template, fieldsDefinition, 200, time.Now(), 1)
+	require.NoError(t, err)
+	require.NoError(t, tel.Shutdown(context.Background()))
+
+	assert.Contains(t, bodies["/v1/traces"], `"name":"corpus.generate"`)
+	assert.Contains(t, bodies["/v1/traces"], `"name":"corpus.save_state"`)
+	assert.Contains(t, bodies["/v1/traces"], `{"key":"corpus.name","value":{"stringValue":"telemetry.tpl"}}`)
+	assert.Contains(t, bodies["/v1/metrics"], `"name":"corpus.events"`)
+	assert.Contains(t, bodies["/v1/metrics"], `"asInt":"200"`)
+}
+
+func TestPackageConfig(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	// the layout of a package built for the package registry, without the `_dev` folders
+	for name, content := range map[string]string{
+		"nginx-1.0.0/manifest.yml":                                                 "format_version: 3.0.0\nname: nginx\nversion: 1.0.0\ntype: integration\n",
+		"nginx-1.0.0/docs/README.md":                                               "# Nginx Integration\n",
+		"nginx-1.0.0/kibana/dashboard/nginx-overview.json":                         "{}\n",
+		"nginx-1.0.0/data_stream/access/manifest.yml":                              "title: Nginx access logs\ntype: logs\n",
+		"nginx-1.0.0/data_stream/access/fields/base-fields.yml":                    "- name: data_stream.type\n  type: constant_keyword\n",
+		"nginx-1.0.0/data_stream/access/fields/ecs.yml":                            "- name: http.request.method\n  type: keyword\n- name: agent.type\n  type: keyword\n",
+		"nginx-1.0.0/data_stream/access/agent/stream/stream.yml.hbs":               "paths:\n{{#each paths}}\n  - {{this}}\n{{/each}}\n",
+		"nginx-1.0.0/data_stream/access/elasticsearch/ingest_pipeline/default.yml": "processors: []\n",
+		"nginx-1.0.0/data_stream/access/sample_event.json":                         "{}\n",
+		"nginx-1.0.0/data_stream/access/corpus/config.yml":                         "version: 3\nfields:\n  - name: http.request.method\n    value: GET\n  - name: agent.type\n    sample_file: agents.txt\n",
+		"nginx-1.0.0/data_stream/access/corpus/agents.txt":                         "filebeat\n",
+		"nginx-1.0.0/data_stream/error/manifest.yml":                               "title: Nginx error logs\ntype: logs\n",
+		"nginx-1.0.0/data_stream/error/corpus/config.yml":                          "version: 3\nfields:\n  - name: http.request.method\n    value: POST\n",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/package/nginx/1.0.0":
+			_, _ = w.Write([]byte(`{"download":"/epr/nginx/nginx-1.0.0.zip"}`))
+		case "/epr/nginx/nginx-1.0.0.zip":
+			_, _ = w.Write(archive.Bytes())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		scenario      string
+		packageConfig bool
+	}{
+		{scenario: "package config", packageConfig: true},
+		{scenario: "no package config", packageConfig: false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			fc, err := NewGenerator(Config{}, fs, "corpora")
+			require.NoError(t, err)
+
+			payloadFilename, err := fc.WithPackageConfig(testCase.packageConfig).Generate(server.URL, "nginx", "access", "1.0.0", 5, time.Now(), 1)
+			require.NoError(t, err)
+
+			payload, err := afero.ReadFile(fs, payloadFilename)
+			require.NoError(t, err)
+
+			lines := strings.Split(strings.TrimSpace(string(payload)), "\n")
+			require.Len(t, lines, 10)
+			assert.Equal(t, `{ "create" : { "_index": "logs-nginx.access-default" } }`, lines[0])
+
+			var event map[string]string
+			require.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+
+			if testCase.packageConfig {
+				assert.Equal(t, "GET", event["http.request.method"])
+				assert.Equal(t, "filebeat", event["agent.type"])
+			} else {
+				assert.NotEqual(t, "GET", event["http.request.method"])
+			}
+		})
+	}
+}
+
+func TestPackageCacheDir(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range map[string]string{
+		"nginx-1.0.0/data_stream/access/manifest.yml":      "type: logs\n",
+		"nginx-1.0.0/data_stream/access/fields/fields.yml": "- name: http.request.method\n  type: keyword\n",
+		"nginx-1.0.0/data_stream/error/manifest.yml":       "type: logs\n",
+		"nginx-1.0.0/data_stream/error/fields/fields.yml":  "- name: message\n  type: keyword\n",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/package/nginx/1.0.0":
+			_, _ = w.Write([]byte(`{"download":"/epr/nginx/nginx-1.0.0.zip"}`))
+		case "/epr/nginx/nginx-1.0.0.zip":
+			_, _ = w.Write(archive.Bytes())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGenerator(Config{}, fs, "corpora")
+	require.NoError(t, err)
+
+	fc = fc.WithPackageCacheDir(t.TempDir())
+	_, err = fc.Generate(server.URL, "nginx", "access", "1.0.0", 5, time.Now(), 1)
+	require.NoError(t, err)
+
+	// once cached, the package is not downloaded again
+	serverURL := server.URL
+	server.Close()
+
+	_, err = fc.Generate(serverURL, "nginx", "error", "1.0.0", 5, time.Now(), 1)
+	require.NoError(t, err)
+
+	_, err = fc.Generate(serverURL, "nginx", "stubstatus", "1.0.0", 5, time.Now(), 1)
+	assert.ErrorIs(t, err, fields.ErrNotFound)
+	assert.ErrorContains(t, err, "data stream stubstatus in package nginx 1.0.0, the data streams of the package are: access, error")
+}
+
+func TestSeed(t *testing.T) {
+	cfg, err := config.LoadConfigFromYaml([]byte("seed: 42\nfields:\n  - name: size\n    range:\n      min: 0\n      max: 1000000"))
+	require.NoError(t, err)
+
+	template := []byte(`{"name":"{{.name}}","size":{{.size}}}`)
+	fieldsDefinition := []byte("- name: name\n  type: keyword\n- name: size\n  type: long\n")
+
+	generate := func(configSeed bool, randSeed int64) string {
+		fc, err := NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "corpora", "placeholder")
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, fc.WithConfigSeed(configSeed).GenerateWithTemplateContentTo(&buf, "seed.tpl", template, fieldsDefinition, 100, time.Unix(1647345675, 0), randSeed))
+		return buf.String()
+	}
+
+	// the generators running concurrently don't affect each other's values
+	corpora := make([]string, 4)
+	var wg sync.WaitGroup
+	for i := range corpora {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			corpora[i] = generate(true, int64(i))
+		}(i)
+	}
+	wg.Wait()
+
+	for _, corpus := range corpora[1:] {
+		assert.Equal(t, corpora[0], corpus, "expected the seed of the config to be used")
+	}
+
+	assert.Equal(t, corpora[0], generate(false, 42))
+	assert.NotEqual(t, corpora[0], generate(false, 1), "expected the seed passed to the generation to be used")
+}
+
+func TestBatchSize(t *testing.T) {
+	cfg, err := config.LoadConfigFromYaml([]byte("fields:\n  - name: path\n    per_batch_constant: true"))
+	require.NoError(t, err)
+
+	template := []byte(`{"path":"{{generate "path"}}","batch":{{batchIndex}}}`)
+	fieldsDefinition := []byte("- name: path\n  type: keyword\n")
+
+	generate := func(batchSize uint64) []map[string]any {
+		fc, err := NewGeneratorWithTemplate(cfg, afero.NewMemMapFs(), "corpora", "gotext")
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, fc.WithBatchSize(batchSize).GenerateWithTemplateContentTo(&buf, "batch.tpl", template, fieldsDefinition, 25, time.Now(), 1))
+
+		var events []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var event map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &event))
+			events = append(events, event)
+		}
+
+		return events
+	}
+
+	events := generate(10)
+	require.Len(t, events, 25)
+	paths := make(map[float64]any)
+	for i, event := range events {
+		batch := event["batch"].(float64)
+		assert.Equal(t, float64(i/10), batch)
+		if path, ok := paths[batch]; ok {
+			assert.Equal(t, path, event["path"], "expected the same path in batch %v", batch)
+		}
+		paths[batch] = event["path"]
+	}
+	assert.Len(t, paths, 3)
+
+	// without a batch size the whole generation is one batch
+	for _, event := range generate(0) {
+		assert.Equal(t, float64(0), event["batch"])
+		assert.Equal(t, events[0]["path"], event["path"])
+	}
+}
+
+func TestGenerateFromMapping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/logs-nginx.access-default/_mapping":
+			assert.Equal(t, "ApiKey secret", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{
+				".ds-logs-nginx.access-default-2024.01.01-000001": {"mappings": {"properties": {
+					"@timestamp": {"type": "date_nanos"},
+					"data_stream": {"properties": {"dataset": {"type": "constant_keyword", "value": "nginx.access"}}},
+					"http": {"properties": {"request": {"properties": {"method": {"type": "keyword"}}}}},
+					"message": {"type": "match_only_text", "fields": {"raw": {"type": "keyword"}}},
+					"method": {"type": "alias", "path": "http.request.method"},
+					"cache": {"type": "object", "enabled": false}
+				}}},
+				".ds-logs-nginx.access-default-2024.01.02-000002": {"mappings": {"properties": {
+					"@timestamp": {"type": "date_nanos"},
+					"http": {"properties": {"response": {"properties": {"bytes": {"type": "scaled_float", "scaling_factor": 100}}}}}
+				}}}
+			}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"type":"index_not_found_exception"},"status":404}`))
+		}
+	}))
+	defer server.Close()
+
+	fs := afero.NewMemMapFs()
+	fc, err := NewGenerator(Config{}, fs, "corpora")
+	require.NoError(t, err)
+
+	fc = fc.WithHTTPClient(&http.Client{Transport: apiKeyTransport("secret")})
+	payloadFilename, err := fc.GenerateFromMapping(server.URL, "logs-nginx.access-default", "fields.yml", 5, time.Now(), 1)
+	require.NoError(t, err)
+
+	payload, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(payload)), "\n")
+	require.Len(t, lines, 5)
+
+	var event map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &event))
+
+	assert.Len(t, event, 5)
+	assert.Equal(t, "nginx.access", event["data_stream.dataset"])
+	assert.Contains(t, event, "@timestamp")
+	assert.Contains(t, event, "http.request.method")
+	assert.IsType(t, float64(0), event["http.response.bytes"])
+	assert.IsType(t, "", event["message"])
+
+	fieldsDefinition, err := afero.ReadFile(fs, "fields.yml")
+	require.NoError(t, err)
+	assert.Equal(t, `- name: "@timestamp"
+  type: "date"
+- name: "data_stream.dataset"
+  type: "constant_keyword"
+  value: "nginx.access"
+- name: "http.request.method"
+  type: "keyword"
+- name: "http.response.bytes"
+  type: "scaled_float"
+  scaling_factor: 100
+- name: "message"
+  type: "match_only_text"
+`, string(fieldsDefinition))
+
+	// the fields definition is the same as the mapping
+	flds, err := fields.LoadFieldsWithTemplateFromString(context.Background(), string(fieldsDefinition))
+	require.NoError(t, err)
+	assert.Len(t, flds, 5)
+	assert.Equal(t, fields.Field{Name: "http.response.bytes", Type: "scaled_float", ScalingFactor: 100}, flds[3])
+
+	_, err = fc.GenerateFromMapping(server.URL, "logs-missing-default", "", 5, time.Now(), 1)
+	assert.ErrorIs(t, err, fields.ErrNotFound)
+}
+
+// apiKeyTransport sets the API key of the requests
+type apiKeyTransport string
+
+func (t apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "ApiKey "+string(t))
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,75 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/spf13/afero"
+)
+
+// gzipExt is the extension added to the name of the corpus files compressed with gzip
+const gzipExt = ".gz"
+
+var ErrNotValidGzipLevel = errors.New("please, pass --gzip-level between 1 and 9, or -1 for the default")
+
+// WithGzip returns a copy of the corpus generator compressing the corpus files with gzip at level, between
+// gzip.BestSpeed and gzip.BestCompression, or gzip.DefaultCompression, and adding `.gz` to their names.
+func (gc GeneratorCorpus) WithGzip(level int) (GeneratorCorpus, error) {
+	if level != gzip.DefaultCompression && (level < gzip.BestSpeed || level > gzip.BestCompression) {
+		return gc, ErrNotValidGzipLevel
+	}
+
+	gc.gzip = true
+	gc.gzipLevel = level
+	return gc, nil
+}
+
+// gzipFile is a corpus file compressed with gzip: closing it flushes the compressed data and closes the file
+type gzipFile struct {
+	*gzip.Writer
+	f io.Closer
+}
+
+func (g gzipFile) Close() error {
+	if err := g.Writer.Close(); err != nil {
+		g.f.Close()
+		return err
+	}
+
+	return g.f.Close()
+}
+
+// createCorpusFile creates the corpus file filename, compressed with gzip when enabled
+func (gc GeneratorCorpus) createCorpusFile(filename string) (io.WriteCloser, error) {
+	// on Windows only the absolute paths can be longer than MAX_PATH
+	if _, ok := gc.fs.(*afero.OsFs); ok && runtime.GOOS == "windows" {
+		if abs, err := filepath.Abs(filename); err == nil {
+			filename = abs
+		}
+	}
+
+	f, err := gc.fs.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, corpusPerm)
+	if err != nil {
+		return nil, err
+	}
+
+	if !gc.gzip {
+		return f, nil
+	}
+
+	w, err := gzip.NewWriterLevel(f, gc.gzipLevel)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return gzipFile{Writer: w, f: f}, nil
+}
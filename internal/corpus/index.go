@@ -0,0 +1,192 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// ErrNotIndexed is returned when removing a corpus not recorded in the index
+var ErrNotIndexed = errors.New("corpus not found in the index")
+
+// IndexEntry is a corpus file recorded in the index, with what it was generated from
+type IndexEntry struct {
+	// Path is the absolute path of the corpus file
+	Path string `json:"path"`
+	// Source is what the corpus was generated from, like `aws.dynamodb-1.14.0` or the name of the template
+	Source string `json:"source"`
+	// ConfigFile is the path of the config file of the generation; empty when none
+	ConfigFile string `json:"config_file,omitempty"`
+	Events     uint64 `json:"events"`
+	// Size is the size in bytes of the corpus file when it was generated
+	Size      int64     `json:"size"`
+	Format    string    `json:"format"`
+	Gzip      bool      `json:"gzip,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Index is a JSON file recording the corpora generated on the machine, so that they can be listed and removed
+type Index struct {
+	fs   afero.Fs
+	path string
+}
+
+// NewIndex returns the index stored in the file at path
+func NewIndex(fs afero.Fs, path string) Index {
+	return Index{fs: fs, path: path}
+}
+
+// List returns the corpora of the index, the most recent first; an index not existing yet has none
+func (i Index) List() ([]IndexEntry, error) {
+	content, err := afero.ReadFile(i.fs, i.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot read corpora index %s: %w", i.path, err)
+	}
+
+	var entries []IndexEntry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("cannot parse corpora index %s: %w", i.path, err)
+	}
+
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].CreatedAt.After(entries[b].CreatedAt)
+	})
+
+	return entries, nil
+}
+
+// Add records the corpus in the index, replacing the entry of a previous corpus with the same path
+func (i Index) Add(entry IndexEntry) error {
+	entries, err := i.List()
+	if err != nil {
+		return err
+	}
+
+	kept := make([]IndexEntry, 0, len(entries)+1)
+	for _, e := range entries {
+		if e.Path != entry.Path {
+			kept = append(kept, e)
+		}
+	}
+
+	return i.save(append(kept, entry))
+}
+
+// Remove removes the corpus file at filePath, if it still exists, and its entry of the index
+func (i Index) Remove(filePath string) error {
+	entries, err := i.List()
+	if err != nil {
+		return err
+	}
+
+	kept := make([]IndexEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.Path != filePath {
+			kept = append(kept, e)
+		}
+	}
+
+	if len(kept) == len(entries) {
+		return fmt.Errorf("%w: %s", ErrNotIndexed, filePath)
+	}
+
+	if err := i.fs.Remove(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot remove corpus %s: %w", filePath, err)
+	}
+
+	return i.save(kept)
+}
+
+// Prune removes the entries of the index whose corpus file does not exist anymore, returning them
+func (i Index) Prune() ([]IndexEntry, error) {
+	entries, err := i.List()
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]IndexEntry, 0, len(entries))
+	var pruned []IndexEntry
+	for _, e := range entries {
+		if _, err := i.fs.Stat(e.Path); errors.Is(err, os.ErrNotExist) {
+			pruned = append(pruned, e)
+			continue
+		}
+
+		kept = append(kept, e)
+	}
+
+	if len(pruned) == 0 {
+		return nil, nil
+	}
+
+	return pruned, i.save(kept)
+}
+
+// save writes the entries to a temporary file renamed to the index, so that the index is never partially written
+func (i Index) save(entries []IndexEntry) error {
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := i.fs.MkdirAll(filepath.Dir(i.path), corpusLocPerm); err != nil {
+		return fmt.Errorf("cannot create corpora index folder: %w", err)
+	}
+
+	tmp := i.path + ".tmp"
+	if err := afero.WriteFile(i.fs, tmp, content, corpusPerm); err != nil {
+		return fmt.Errorf("cannot write corpora index %s: %w", i.path, err)
+	}
+
+	return i.fs.Rename(tmp, i.path)
+}
+
+// NewIndexEntry returns the entry of the corpus file at filePath generated from source, with the config file, the
+// events, the format and the compression of the generation
+func NewIndexEntry(fs afero.Fs, filePath, source, configFile string, events uint64, format string, gzip bool) (IndexEntry, error) {
+	info, err := fs.Stat(filePath)
+	if err != nil {
+		return IndexEntry{}, err
+	}
+
+	return IndexEntry{
+		Path:       filePath,
+		Source:     source,
+		ConfigFile: configFile,
+		Events:     events,
+		Size:       info.Size(),
+		Format:     format,
+		Gzip:       gzip,
+		CreatedAt:  info.ModTime().UTC(),
+	}, nil
+}
+
+// IndexEntry returns the entry of the corpus file at filePath generated from source, with the format, the
+// compression and the events of the generation
+func (gc GeneratorCorpus) IndexEntry(filePath, source, configFile string, totEvents uint64) (IndexEntry, error) {
+	events := totEvents
+	if truncated, ok := gc.Truncated(); ok {
+		events = truncated
+	}
+
+	format := gc.format
+	if len(format) == 0 {
+		format = FormatText
+	}
+
+	return NewIndexEntry(gc.fs, filePath, source, configFile, events, format, gc.gzip)
+}
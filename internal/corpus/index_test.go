@@ -0,0 +1,68 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	index := NewIndex(fs, "/data/corpora.json")
+
+	entries, err := index.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	older := IndexEntry{Path: "/data/corpora/1-a.ndjson", Source: "a", Events: 10, CreatedAt: time.Unix(1, 0).UTC()}
+	newer := IndexEntry{Path: "/data/corpora/2-b.ndjson", Source: "b", Events: 20, CreatedAt: time.Unix(2, 0).UTC()}
+	require.NoError(t, afero.WriteFile(fs, older.Path, []byte("a\n"), corpusPerm))
+	require.NoError(t, index.Add(older))
+	require.NoError(t, index.Add(newer))
+
+	// the same path replaces the previous entry
+	older.Events = 15
+	require.NoError(t, index.Add(older))
+
+	entries, err = index.List()
+	require.NoError(t, err)
+	assert.Equal(t, []IndexEntry{newer, older}, entries)
+
+	pruned, err := index.Prune()
+	require.NoError(t, err)
+	assert.Equal(t, []IndexEntry{newer}, pruned)
+
+	require.NoError(t, index.Remove(older.Path))
+	exists, err := afero.Exists(fs, older.Path)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	entries, err = index.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.ErrorIs(t, index.Remove(older.Path), ErrNotIndexed)
+}
+
+func TestIndexEntry(t *testing.T) {
+	fc, err := TestNewGenerator().WithGzip(-1)
+	require.NoError(t, err)
+	require.NoError(t, afero.WriteFile(fc.fs, "/corpora/1-a.ndjson.gz", []byte("abc"), corpusPerm))
+
+	entry, err := fc.IndexEntry("/corpora/1-a.ndjson.gz", "a", "/configs.yml", 100)
+	require.NoError(t, err)
+	assert.Equal(t, "/corpora/1-a.ndjson.gz", entry.Path)
+	assert.Equal(t, "a", entry.Source)
+	assert.Equal(t, "/configs.yml", entry.ConfigFile)
+	assert.Equal(t, uint64(100), entry.Events)
+	assert.Equal(t, int64(3), entry.Size)
+	assert.Equal(t, FormatText, entry.Format)
+	assert.True(t, entry.Gzip)
+}
@@ -0,0 +1,151 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+var ErrNotValidMerge = errors.New("please, pass at least two corpora to merge")
+
+// MergeResult reports the events merged from each corpus
+type MergeResult struct {
+	Events []uint64
+}
+
+// mergeHead is the next record of a corpus to merge, with its timestamp
+type mergeHead struct {
+	input  int
+	ts     int64
+	record []byte
+}
+
+// mergeHeap is a min-heap of the next records of the corpora, by timestamp and then by corpus, so that the records
+// with the same timestamp are merged in the order of the corpora
+type mergeHeap []mergeHead
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].ts != h[j].ts {
+		return h[i].ts < h[j].ts
+	}
+
+	return h[i].input < h[j].input
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(mergeHead)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// Merge writes to outputPath the records of the corpora at inputPaths interleaved by the timestamp of their events,
+// read from timestampField, with a k-way merge: each corpus is read once, and must be sorted by timestamp for the
+// merged corpus to be sorted. An event without a timestamp keeps the one of the previous event of its corpus, so
+// that it stays after it. The corpora are read, and the merged one is written, compressed with gzip when their
+// name ends in `.gz`.
+func Merge(fs afero.Fs, inputPaths []string, outputPath, timestampField string) (MergeResult, error) {
+	if len(inputPaths) < 2 {
+		return MergeResult{}, ErrNotValidMerge
+	}
+
+	readers := make([]*recordReader, 0, len(inputPaths))
+	defer func() {
+		for _, rr := range readers {
+			rr.Close()
+		}
+	}()
+
+	for _, inputPath := range inputPaths {
+		rr, err := openRecordReader(fs, inputPath)
+		if err != nil {
+			return MergeResult{}, err
+		}
+
+		readers = append(readers, rr)
+	}
+
+	lastTs := make([]int64, len(readers))
+	next := func(input int) (mergeHead, bool, error) {
+		record, event, err := readers[input].Next()
+		if errors.Is(err, io.EOF) {
+			return mergeHead{}, false, nil
+		}
+
+		if err != nil {
+			return mergeHead{}, false, err
+		}
+
+		if ts, ok := eventTimestamp(event, timestampField); ok {
+			lastTs[input] = ts
+		}
+
+		return mergeHead{input: input, ts: lastTs[input], record: record}, true, nil
+	}
+
+	h := make(mergeHeap, 0, len(readers))
+	for input := range readers {
+		lastTs[input] = math.MinInt64
+		head, ok, err := next(input)
+		if err != nil {
+			return MergeResult{}, err
+		}
+
+		if ok {
+			h = append(h, head)
+		}
+	}
+
+	heap.Init(&h)
+
+	if err := fs.MkdirAll(filepath.Dir(outputPath), corpusLocPerm); err != nil {
+		return MergeResult{}, fmt.Errorf("cannot create output folder: %w", err)
+	}
+
+	w, err := createOutputFile(fs, outputPath)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	result := MergeResult{Events: make([]uint64, len(readers))}
+	for h.Len() > 0 {
+		head := h[0]
+		if _, err := w.Write(head.record); err != nil {
+			w.Close()
+			return MergeResult{}, err
+		}
+
+		result.Events[head.input]++
+
+		following, ok, err := next(head.input)
+		if err != nil {
+			w.Close()
+			return MergeResult{}, err
+		}
+
+		if ok {
+			h[0] = following
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return MergeResult{}, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,46 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/corpora/logs.ndjson", []byte(
+		`{"@timestamp":"2024-01-01T00:00:01Z","n":"logs-1"}`+"\n"+
+			`{"n":"logs-2"}`+"\n"+
+			`{"@timestamp":"2024-01-01T00:00:04Z","n":"logs-3"}`+"\n"), corpusPerm))
+	require.NoError(t, afero.WriteFile(fs, "/corpora/metrics.ndjson", []byte(
+		`{"create":{}}`+"\n"+
+			`{"@timestamp":1704067200000,"n":"metrics-1"}`+"\n"+
+			`{"create":{}}`+"\n"+
+			`{"@timestamp":1704067201000,"n":"metrics-2"}`+"\n"+
+			`{"create":{}}`+"\n"+
+			`{"@timestamp":1704067203000,"n":"metrics-3"}`), corpusPerm))
+
+	result, err := Merge(fs, []string{"/corpora/logs.ndjson", "/corpora/metrics.ndjson"}, "/corpora/merged.ndjson.gz", "@timestamp")
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3, 3}, result.Events)
+
+	var merged []any
+	require.NoError(t, readCorpusRecords(fs, "/corpora/merged.ndjson.gz", func(record []byte, event map[string]any) error {
+		merged = append(merged, event["n"])
+		return nil
+	}))
+
+	// logs-2 has no timestamp and stays after logs-1, the events with the same timestamp follow the order of the corpora
+	assert.Equal(t, []any{"metrics-1", "logs-1", "logs-2", "metrics-2", "metrics-3", "logs-3"}, merged)
+}
+
+func TestMerge_notValid(t *testing.T) {
+	_, err := Merge(afero.NewMemMapFs(), []string{"/corpora/logs.ndjson"}, "/corpora/merged.ndjson", "@timestamp")
+	assert.ErrorIs(t, err, ErrNotValidMerge)
+}
@@ -0,0 +1,49 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bytes"
+	"errors"
+)
+
+const (
+	// NewlineLF ends the lines of the corpus files with `\n`, like on Linux and macOS
+	NewlineLF = "lf"
+	// NewlineCRLF ends the lines of the corpus files with `\r\n`, like on Windows
+	NewlineCRLF = "crlf"
+)
+
+var ErrNotValidNewline = errors.New("please, pass --newline as one of 'lf' or 'crlf'")
+
+// WithNewline returns a copy of the corpus generator ending the lines of the corpus files, the events and the bulk
+// create actions, with newline, either NewlineLF or NewlineCRLF. It applies to FormatText only: the other formats
+// are not made of lines.
+func (gc GeneratorCorpus) WithNewline(newline string) (GeneratorCorpus, error) {
+	if newline != NewlineLF && newline != NewlineCRLF {
+		return gc, ErrNotValidNewline
+	}
+
+	gc.crlf = newline == NewlineCRLF
+	return gc, nil
+}
+
+// lineEnd returns the end of the lines of the corpus files
+func (gc GeneratorCorpus) lineEnd() string {
+	if gc.crlf && (len(gc.format) == 0 || gc.format == FormatText) {
+		return "\r\n"
+	}
+
+	return "\n"
+}
+
+// withLineEnd returns line, ending with `\n`, ending with the line end of the corpus files instead
+func (gc GeneratorCorpus) withLineEnd(line []byte) []byte {
+	if len(line) == 0 {
+		return line
+	}
+
+	return append(bytes.TrimSuffix(line, []byte("\n")), gc.lineEnd()...)
+}
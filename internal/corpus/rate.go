@@ -0,0 +1,129 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+)
+
+var ErrNotValidRate = errors.New("please, pass --rate as a positive number of events per unit of time, like '1000/s', '60000/m' or '3600000/h'")
+
+// rateUnits are the units of time of --rate
+var rateUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// ParseRate returns the events per second of rate, a number of events per unit of time like `1000/s`, `60000/m` or
+// `3600000/h`
+func ParseRate(rate string) (float64, error) {
+	events, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return 0, ErrNotValidRate
+	}
+
+	perUnit, ok := rateUnits[strings.TrimSpace(unit)]
+	if !ok {
+		return 0, ErrNotValidRate
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(events), 64)
+	if err != nil || n <= 0 {
+		return 0, ErrNotValidRate
+	}
+
+	return n / perUnit.Seconds(), nil
+}
+
+// WithRate returns a copy of the corpus generator emitting the events at rate, like `1000/s`, instead of as fast
+// as possible. An empty rate means unlimited.
+func (gc GeneratorCorpus) WithRate(rate string) (GeneratorCorpus, error) {
+	if len(rate) == 0 {
+		gc.eventsPerSecond = 0
+		return gc, nil
+	}
+
+	eventsPerSecond, err := ParseRate(rate)
+	if err != nil {
+		return gc, err
+	}
+
+	gc.eventsPerSecond = eventsPerSecond
+	return gc, nil
+}
+
+// pacer spaces the events evenly at a rate of events per second: each event has its slot after the previous one,
+// and when the generation falls behind by more than a second the slots start again from now, so that the lost time
+// is not recovered with a burst.
+type pacer struct {
+	// interval between the slots of two events
+	interval time.Duration
+	next     time.Time
+	// now and sleep allow replacing the clock during testing
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newPacer(eventsPerSecond float64) *pacer {
+	return &pacer{
+		interval: time.Duration(float64(time.Second) / eventsPerSecond),
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+}
+
+// wait sleeps until the slot of the next event
+func (p *pacer) wait() {
+	now := p.now()
+	if p.next.IsZero() || now.Sub(p.next) > time.Second {
+		p.next = now
+	}
+
+	if d := p.next.Sub(now); d > 0 {
+		p.sleep(d)
+	}
+
+	p.next = p.next.Add(p.interval)
+}
+
+// setRate changes the rate of the events after the next one to eventsPerSecond
+func (p *pacer) setRate(eventsPerSecond float64) {
+	p.interval = time.Duration(float64(time.Second) / eventsPerSecond)
+}
+
+// checkTimelineRate returns an error when a step of the timeline of cfg sets the rate of the events, as a multiple
+// of the one of the generation, and the generation has none
+func (gc GeneratorCorpus) checkTimelineRate(cfg Config) error {
+	if gc.eventsPerSecond > 0 {
+		return nil
+	}
+
+	for i, step := range cfg.Timeline() {
+		if _, ok := step.Set.EPSFactor(); ok {
+			return fmt.Errorf("timeline step #%d: `set.eps` requires --rate", i)
+		}
+	}
+
+	return nil
+}
+
+// timelineRate returns the hook setting the rate of pace to the `set.eps` of the timeline steps, as a multiple of
+// eventsPerSecond, the rate of the generation
+func timelineRate(pace *pacer, eventsPerSecond float64) genlib.TimelineStepHook {
+	return func(_ uint64, step genlib.TimelineStep) error {
+		if factor, ok := step.Set.EPSFactor(); ok && pace != nil {
+			pace.setRate(eventsPerSecond * factor)
+		}
+
+		return nil
+	}
+}
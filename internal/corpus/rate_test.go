@@ -0,0 +1,85 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	for rate, expected := range map[string]float64{
+		"1000/s":    1000,
+		"60000/m":   1000,
+		"3600000/h": 1000,
+		"0.5/s":     0.5,
+	} {
+		eventsPerSecond, err := ParseRate(rate)
+		require.NoError(t, err, rate)
+		assert.InDelta(t, expected, eventsPerSecond, 0.0001, rate)
+	}
+
+	for _, rate := range []string{"1000", "1000/d", "0/s", "-1/s", "a/s"} {
+		_, err := ParseRate(rate)
+		assert.ErrorIs(t, err, ErrNotValidRate, rate)
+	}
+}
+
+func TestPacer(t *testing.T) {
+	p := newPacer(10)
+
+	// fake clock advancing only when sleeping
+	clock := time.Unix(0, 0)
+	var slept time.Duration
+	p.now = func() time.Time { return clock }
+	p.sleep = func(d time.Duration) {
+		slept += d
+		clock = clock.Add(d)
+	}
+
+	// 10 events per second, emitted instantly, take a second
+	for i := 0; i < 11; i++ {
+		p.wait()
+	}
+
+	assert.Equal(t, time.Second, slept)
+
+	// the time spent generating an event counts towards the interval
+	slept = 0
+	clock = clock.Add(50 * time.Millisecond)
+	p.wait()
+	assert.Equal(t, 50*time.Millisecond, slept)
+
+	// falling behind by more than a second doesn't burst to recover
+	clock = clock.Add(5 * time.Second)
+	slept = 0
+	p.wait()
+	p.wait()
+	assert.Equal(t, 100*time.Millisecond, slept)
+}
+
+func TestTimelineRate(t *testing.T) {
+	p := newPacer(10)
+	hook := timelineRate(p, 10)
+
+	var step genlib.TimelineStep
+	assert.NoError(t, hook(0, step))
+	assert.Equal(t, 100*time.Millisecond, p.interval)
+
+	cfg, err := config.LoadConfigFromYaml([]byte("timeline:\n  - at: 1m\n    set:\n      eps: 2x"))
+	require.NoError(t, err)
+
+	assert.NoError(t, hook(0, cfg.Timeline()[0]))
+	assert.Equal(t, 50*time.Millisecond, p.interval)
+
+	// a rate set by the timeline needs the one of the generation
+	assert.Error(t, GeneratorCorpus{}.checkTimelineRate(cfg))
+	assert.NoError(t, GeneratorCorpus{eventsPerSecond: 10}.checkTimelineRate(cfg))
+}
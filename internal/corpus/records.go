@@ -0,0 +1,226 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// recordReader reads the records of an existing corpus, an event per line optionally preceded by its bulk action
+// line, compressed with gzip when its name ends in `.gz`
+type recordReader struct {
+	filePath string
+	closers  []io.Closer
+	br       *bufio.Reader
+}
+
+// openRecordReader opens the corpus at filePath to read its records
+func openRecordReader(fs afero.Fs, filePath string) (*recordReader, error) {
+	file, err := fs.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open corpus %s: %w", filePath, err)
+	}
+
+	rr := &recordReader{filePath: filePath, closers: []io.Closer{file}}
+
+	var r io.Reader = file
+	if strings.HasSuffix(filePath, gzipExt) {
+		gr, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("cannot read corpus %s: %w", filePath, err)
+		}
+
+		rr.closers = append(rr.closers, gr)
+		r = gr
+	}
+
+	rr.br = bufio.NewReader(r)
+	return rr, nil
+}
+
+// Next returns the next record, an event with its bulk action line if any, and the event parsed from JSON, nil when
+// it's not JSON; it returns io.EOF after the last record
+func (rr *recordReader) Next() ([]byte, map[string]any, error) {
+	var action []byte
+	for {
+		line, err := rr.br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("cannot read corpus %s: %w", rr.filePath, err)
+		}
+
+		if len(line) == 0 {
+			if action != nil {
+				return nil, nil, fmt.Errorf("cannot read corpus %s: bulk action line without an event", rr.filePath)
+			}
+
+			return nil, nil, io.EOF
+		}
+
+		if line[len(line)-1] != '\n' {
+			line = append(line, '\n')
+		}
+
+		event := parseEvent(line)
+		if action == nil && isBulkAction(event) {
+			action = line
+			continue
+		}
+
+		if action != nil {
+			return append(action, line...), event, nil
+		}
+
+		return line, event, nil
+	}
+}
+
+func (rr *recordReader) Close() error {
+	var err error
+	for i := len(rr.closers) - 1; i >= 0; i-- {
+		if closeErr := rr.closers[i].Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// readCorpusRecords calls f with each record of the corpus at filePath and its event, see recordReader.Next
+func readCorpusRecords(fs afero.Fs, filePath string, f func(record []byte, event map[string]any) error) error {
+	rr, err := openRecordReader(fs, filePath)
+	if err != nil {
+		return err
+	}
+
+	defer rr.Close()
+
+	for {
+		record, event, err := rr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := f(record, event); err != nil {
+			return err
+		}
+	}
+}
+
+// parseEvent returns the event parsed from the JSON line, or nil when it's not a JSON object
+func parseEvent(line []byte) map[string]any {
+	d := json.NewDecoder(bytes.NewReader(line))
+	d.UseNumber()
+
+	var event map[string]any
+	if err := d.Decode(&event); err != nil {
+		return nil
+	}
+
+	return event
+}
+
+// isBulkAction reports whether the event is the action line of a bulk request, like `{"create":{}}`
+func isBulkAction(event map[string]any) bool {
+	if len(event) != 1 {
+		return false
+	}
+
+	for _, action := range []string{"create", "index"} {
+		if _, ok := event[action].(map[string]any); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// eventTimestamp returns the timestamp, in milliseconds, of the field of the event: either an RFC 3339 date or
+// milliseconds since the epoch
+func eventTimestamp(event map[string]any, field string) (int64, bool) {
+	value, ok := lookupField(event, field)
+	if !ok {
+		return 0, false
+	}
+
+	switch v := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return 0, false
+		}
+
+		return t.UnixMilli(), true
+	case json.Number:
+		ms, err := v.Float64()
+		if err != nil {
+			return 0, false
+		}
+
+		return int64(ms), true
+	}
+
+	return 0, false
+}
+
+// lookupField returns the value of the dotted field name in the event, either nested or flat
+func lookupField(event map[string]any, name string) (any, bool) {
+	if event == nil {
+		return nil, false
+	}
+
+	if value, ok := event[name]; ok {
+		return value, true
+	}
+
+	for i := strings.Index(name, "."); i >= 0; i = nextDot(name, i) {
+		if nested, ok := event[name[:i]].(map[string]any); ok {
+			if value, ok := lookupField(nested, name[i+1:]); ok {
+				return value, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// nextDot returns the index of the dot after the one at i in name, or -1
+func nextDot(name string, i int) int {
+	j := strings.Index(name[i+1:], ".")
+	if j < 0 {
+		return -1
+	}
+
+	return i + 1 + j
+}
+
+// createOutputFile creates the corpus file at filePath, compressed with gzip when its name ends in `.gz`
+func createOutputFile(fs afero.Fs, filePath string) (io.WriteCloser, error) {
+	f, err := fs.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, corpusPerm)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(filePath, gzipExt) {
+		return f, nil
+	}
+
+	return gzipFile{Writer: gzip.NewWriter(f), f: f}, nil
+}
@@ -0,0 +1,362 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// reportMaxBars is the number of bars the charts over time are reduced to, merging adjacent seconds
+	reportMaxBars = 120
+	// reportMaxDistinct is the number of distinct values counted per field, to bound the memory of long runs
+	reportMaxDistinct = 100000
+	// reportSamples is the number of values per numeric field kept to draw its distribution
+	reportSamples = 10000
+	// reportBins is the number of bins of the distribution of the numeric fields
+	reportBins = 20
+	// reportTopValues is the number of most frequent values shown for the not numeric fields
+	reportTopValues = 10
+)
+
+// fieldReport holds the statistics of the values generated for a field, for the report
+type fieldReport struct {
+	// lastEvent is the index of the last event counted plus one, so that a field written more than once
+	// in the same event is counted once
+	lastEvent uint64
+	values    uint64
+	counts    map[string]uint64
+	// capped is true when more values than reportMaxDistinct have been generated
+	capped  bool
+	numbers uint64
+	min     float64
+	max     float64
+	sum     float64
+	// samples is a uniform reservoir sample of the numeric values
+	samples []float64
+}
+
+// runReport collects the statistics of a generation, to write them as an HTML report at the end
+type runReport struct {
+	start time.Time
+	// events and bytes written in each second from the start
+	events []uint64
+	bytes  []uint64
+	names  []string
+	fields map[string]*fieldReport
+	rand   *rand.Rand
+}
+
+func newRunReport(randSeed int64) *runReport {
+	return &runReport{
+		start:  time.Now(),
+		fields: make(map[string]*fieldReport),
+		rand:   rand.New(rand.NewSource(randSeed)),
+	}
+}
+
+// afterField is the genlib.AfterFieldHook counting the values of the fields
+func (r *runReport) afterField(event uint64, field string, value any) (any, error) {
+	stats, ok := r.fields[field]
+	if !ok {
+		stats = &fieldReport{counts: make(map[string]uint64), min: math.Inf(1), max: math.Inf(-1)}
+		r.fields[field] = stats
+		r.names = append(r.names, field)
+	}
+
+	if stats.lastEvent == event+1 {
+		return value, nil
+	}
+
+	stats.lastEvent = event + 1
+	stats.values++
+
+	var s string
+	if b, ok := value.([]byte); ok {
+		s = string(b)
+	} else {
+		s = fmt.Sprint(value)
+	}
+
+	if _, ok := stats.counts[s]; ok || len(stats.counts) < reportMaxDistinct {
+		stats.counts[s]++
+	} else {
+		stats.capped = true
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return value, nil
+	}
+
+	stats.numbers++
+	stats.sum += f
+	stats.min = math.Min(stats.min, f)
+	stats.max = math.Max(stats.max, f)
+
+	if len(stats.samples) < reportSamples {
+		stats.samples = append(stats.samples, f)
+	} else if i := r.rand.Int63n(int64(stats.numbers)); i < reportSamples {
+		stats.samples[i] = f
+	}
+
+	return value, nil
+}
+
+// written counts an event of n bytes written to the corpus
+func (r *runReport) written(n int) {
+	second := int(time.Since(r.start) / time.Second)
+	for len(r.events) <= second {
+		r.events = append(r.events, 0)
+		r.bytes = append(r.bytes, 0)
+	}
+
+	r.events[second]++
+	r.bytes[second] += uint64(n)
+}
+
+// reportBar is a bar of a chart, with its height or width as percent of the biggest one
+type reportBar struct {
+	Label   string
+	Value   string
+	Percent float64
+}
+
+type reportField struct {
+	Name     string
+	Values   uint64
+	Distinct string
+	Numeric  bool
+	Min      string
+	Max      string
+	Mean     string
+	Bars     []reportBar
+}
+
+type reportData struct {
+	Title           string
+	Generated       string
+	Duration        string
+	Events          uint64
+	Bytes           uint64
+	EventsPerSecond string
+	MBPerSecond     string
+	EventRate       []reportBar
+	Throughput      []reportBar
+	Fields          []reportField
+}
+
+// data returns the statistics of the run to render in the report
+func (r *runReport) data(title string) reportData {
+	duration := time.Since(r.start)
+	d := reportData{
+		Title:     title,
+		Generated: time.Now().UTC().Format(time.RFC3339),
+		Duration:  duration.Round(time.Millisecond).String(),
+	}
+
+	for i := range r.events {
+		d.Events += r.events[i]
+		d.Bytes += r.bytes[i]
+	}
+
+	seconds := math.Max(duration.Seconds(), 1e-3)
+	d.EventsPerSecond = strconv.FormatFloat(float64(d.Events)/seconds, 'f', 1, 64)
+	d.MBPerSecond = strconv.FormatFloat(float64(d.Bytes)/bytesPerMB/seconds, 'f', 3, 64)
+	d.EventRate = secondBars(r.events, 1)
+	d.Throughput = secondBars(r.bytes, bytesPerMB)
+
+	for _, name := range r.names {
+		d.Fields = append(d.Fields, r.fields[name].data(name))
+	}
+
+	return d
+}
+
+// secondBars returns the bars of the per second values divided by unit, merging adjacent seconds
+// so that there are at most reportMaxBars bars
+func secondBars(perSecond []uint64, unit float64) []reportBar {
+	step := (len(perSecond) + reportMaxBars - 1) / reportMaxBars
+	if step == 0 {
+		return nil
+	}
+
+	var values []float64
+	var labels []string
+	for from := 0; from < len(perSecond); from += step {
+		to := from + step
+		if to > len(perSecond) {
+			to = len(perSecond)
+		}
+
+		var sum uint64
+		for _, v := range perSecond[from:to] {
+			sum += v
+		}
+
+		values = append(values, float64(sum)/unit/float64(to-from))
+		labels = append(labels, fmt.Sprintf("%ds", from))
+	}
+
+	return bars(labels, values)
+}
+
+// bars returns the bars of values, sized in percent of the biggest one
+func bars(labels []string, values []float64) []reportBar {
+	var biggest float64
+	for _, v := range values {
+		biggest = math.Max(biggest, v)
+	}
+
+	out := make([]reportBar, 0, len(values))
+	for i, v := range values {
+		percent := 0.0
+		if biggest > 0 {
+			percent = v / biggest * 100
+		}
+
+		out = append(out, reportBar{Label: labels[i], Value: strconv.FormatFloat(v, 'g', 6, 64), Percent: percent})
+	}
+
+	return out
+}
+
+func (f *fieldReport) data(name string) reportField {
+	d := reportField{
+		Name:     name,
+		Values:   f.values,
+		Distinct: strconv.Itoa(len(f.counts)),
+		Numeric:  f.values > 0 && f.numbers == f.values,
+	}
+
+	if f.capped {
+		d.Distinct = "more than " + d.Distinct
+	}
+
+	if d.Numeric {
+		d.Min = strconv.FormatFloat(f.min, 'g', 6, 64)
+		d.Max = strconv.FormatFloat(f.max, 'g', 6, 64)
+		d.Mean = strconv.FormatFloat(f.sum/float64(f.numbers), 'g', 6, 64)
+		d.Bars = f.histogram()
+		return d
+	}
+
+	values := make([]string, 0, len(f.counts))
+	for value := range f.counts {
+		values = append(values, value)
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		if f.counts[values[i]] != f.counts[values[j]] {
+			return f.counts[values[i]] > f.counts[values[j]]
+		}
+
+		return values[i] < values[j]
+	})
+
+	if len(values) > reportTopValues {
+		values = values[:reportTopValues]
+	}
+
+	counts := make([]float64, 0, len(values))
+	for _, value := range values {
+		counts = append(counts, float64(f.counts[value]))
+	}
+
+	d.Bars = bars(values, counts)
+
+	return d
+}
+
+// histogram returns the bars of the distribution of the sampled numeric values, in reportBins bins between
+// the minimum and the maximum
+func (f *fieldReport) histogram() []reportBar {
+	width := (f.max - f.min) / reportBins
+	if width == 0 {
+		return bars([]string{strconv.FormatFloat(f.min, 'g', 6, 64)}, []float64{float64(len(f.samples))})
+	}
+
+	counts := make([]float64, reportBins)
+	for _, v := range f.samples {
+		bin := int((v - f.min) / width)
+		if bin >= reportBins {
+			bin = reportBins - 1
+		}
+
+		counts[bin]++
+	}
+
+	labels := make([]string, 0, reportBins)
+	for i := 0; i < reportBins; i++ {
+		labels = append(labels, strconv.FormatFloat(f.min+float64(i)*width, 'g', 4, 64))
+	}
+
+	return bars(labels, counts)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Corpus generation report: {{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #333; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { padding: 0.2em 0.8em; text-align: left; border-bottom: 1px solid #ddd; }
+.columns { display: flex; align-items: flex-end; height: 150px; border-bottom: 1px solid #999; margin-bottom: 2em; }
+.columns div { flex: 1; background: #0077cc; margin-right: 1px; }
+.row { background: #0077cc; height: 1em; }
+</style>
+</head>
+<body>
+<h1>Corpus generation report: {{.Title}}</h1>
+<table>
+<tr><th>Generated</th><td>{{.Generated}}</td></tr>
+<tr><th>Duration</th><td>{{.Duration}}</td></tr>
+<tr><th>Events</th><td>{{.Events}}</td></tr>
+<tr><th>Bytes</th><td>{{.Bytes}}</td></tr>
+<tr><th>Events per second</th><td>{{.EventsPerSecond}}</td></tr>
+<tr><th>MB per second</th><td>{{.MBPerSecond}}</td></tr>
+</table>
+<h2>Event rate over time (events per second)</h2>
+<div class="columns">{{range .EventRate}}<div style="height: {{printf "%.2f" .Percent}}%" title="{{.Label}}: {{.Value}}"></div>{{end}}</div>
+<h2>Sink throughput over time (MB per second)</h2>
+<div class="columns">{{range .Throughput}}<div style="height: {{printf "%.2f" .Percent}}%" title="{{.Label}}: {{.Value}}"></div>{{end}}</div>
+<h2>Fields</h2>
+<table>
+<tr><th>Field</th><th>Values</th><th>Distinct values (entities)</th><th>Min</th><th>Mean</th><th>Max</th></tr>
+{{range .Fields}}<tr><td><a href="#field-{{.Name}}">{{.Name}}</a></td><td>{{.Values}}</td><td>{{.Distinct}}</td><td>{{.Min}}</td><td>{{.Mean}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{range .Fields}}<h3 id="field-{{.Name}}">{{.Name}}</h3>
+{{if .Numeric}}<p>Distribution of the values</p>
+<div class="columns">{{range .Bars}}<div style="height: {{printf "%.2f" .Percent}}%" title="from {{.Label}}: {{.Value}}"></div>{{end}}</div>
+{{else}}<p>Most frequent values</p>
+<table>{{range .Bars}}<tr><td>{{.Label}}</td><td>{{.Value}}</td><td style="width: 300px"><div class="row" style="width: {{printf "%.2f" .Percent}}%"></div></td></tr>
+{{end}}</table>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// write writes the report, titled title, to the report file
+func (r *runReport) write(gc GeneratorCorpus, title string) error {
+	f, err := gc.fs.OpenFile(gc.reportFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, corpusPerm)
+	if err != nil {
+		return err
+	}
+
+	if err := reportTemplate.Execute(f, r.data(title)); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("cannot write report file %s: %w", gc.reportFile, err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,411 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/catalog"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/elastic/go-ucfg/yaml"
+	"github.com/spf13/afero"
+)
+
+// scenarioCatalogSchema is the schema of the catalog entries of the scenarios
+const scenarioCatalogSchema = "b"
+
+var ErrNotValidScenario = errors.New("a scenario must list at least one stream in `streams`")
+var ErrNotValidScenarioEvents = errors.New("a scenario must generate a number of events greater than 0")
+var ErrNotValidScenarioFormat = errors.New("a scenario can only be generated with the text format")
+
+// Scenario is a set of data streams whose events are generated in a single corpus, each stream with its share of
+// the events, interleaved on a shared timeline
+type Scenario struct {
+	// Name is the name of the scenario file, without extension
+	Name string `config:",ignore"`
+	// TotEvents is the number of events of the corpus, of all the streams; 0 when not set
+	TotEvents uint64           `config:"tot_events"`
+	Streams   []ScenarioStream `config:"streams"`
+}
+
+// ScenarioStream is a data stream of a scenario: either a data stream of an integration package of the package
+// registry, with Package, DataStream and Version, an entry of the catalog, with Catalog, or a template with its
+// fields definition, with Template and Fields
+type ScenarioStream struct {
+	// Name is the name of the stream in the output; by default the one of the corpus of the stream
+	Name       string `config:"name"`
+	Package    string `config:"package"`
+	DataStream string `config:"data_stream"`
+	Version    string `config:"version"`
+	// Catalog is the `<package>.<dataset>` name of an entry of the catalog, with schema b
+	Catalog  string `config:"catalog"`
+	Template string `config:"template"`
+	Fields   string `config:"fields"`
+	// Engine is the type of the template, either `placeholder` or `gotext`, the default; for a catalog entry it's
+	// the name of its template too
+	Engine string `config:"engine"`
+	// ConfigFile is the fields generation configuration of the stream, instead of the default one of the data
+	// stream of the package or of the catalog entry
+	ConfigFile string `config:"config_file"`
+	// Ratio is the share of the events of the stream, relative to the ones of the other streams
+	Ratio float64 `config:"ratio"`
+
+	config           Config
+	templateType     string
+	template         []byte
+	fieldsDefinition []byte
+}
+
+// ScenarioResult reports the events generated for each stream of a scenario
+type ScenarioResult struct {
+	Events []uint64
+}
+
+// LoadScenario loads the scenario file at scenarioFile, with the paths of its streams relative to its folder, and
+// the fields generation configurations, templates and fields definitions of the streams
+func LoadScenario(fs afero.Fs, scenarioFile string) (Scenario, error) {
+	data, err := afero.ReadFile(fs, scenarioFile)
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	cfg, err := yaml.NewConfig(data)
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	var scenario Scenario
+	if err := cfg.Unpack(&scenario); err != nil {
+		return Scenario{}, err
+	}
+
+	if len(scenario.Streams) == 0 {
+		return Scenario{}, ErrNotValidScenario
+	}
+
+	scenario.Name = strings.TrimSuffix(filepath.Base(scenarioFile), filepath.Ext(scenarioFile))
+
+	dir := filepath.Dir(scenarioFile)
+	for i := range scenario.Streams {
+		if err := scenario.Streams[i].load(fs, dir); err != nil {
+			return Scenario{}, fmt.Errorf("stream #%d: %w", i, err)
+		}
+	}
+
+	return scenario, nil
+}
+
+// load validates the stream and loads its files, relative to dir
+func (s *ScenarioStream) load(fs afero.Fs, dir string) error {
+	sources := 0
+	for _, source := range []string{s.Package, s.Catalog, s.Template} {
+		if len(source) > 0 {
+			sources++
+		}
+	}
+
+	if sources != 1 {
+		return errors.New("a stream must have one of `package`, `catalog` or `template`")
+	}
+
+	if s.Ratio <= 0 || math.IsInf(s.Ratio, 0) {
+		return errors.New("`ratio` must be greater than 0")
+	}
+
+	s.templateType = s.Engine
+	if len(s.templateType) == 0 {
+		s.templateType = "gotext"
+	}
+
+	if s.templateType != "gotext" && s.templateType != "placeholder" {
+		return fmt.Errorf("`engine` must be either 'placeholder' or 'gotext', got '%s'", s.Engine)
+	}
+
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+
+		return filepath.Join(dir, path)
+	}
+
+	var err error
+	if len(s.ConfigFile) > 0 {
+		if s.config, err = config.LoadConfig(fs, resolve(s.ConfigFile)); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case len(s.Package) > 0:
+		if len(s.DataStream) == 0 || len(s.Version) == 0 {
+			return errors.New("a `package` stream must have `data_stream` and `version`")
+		}
+
+		if len(s.Name) == 0 {
+			s.Name = s.Package + "." + s.DataStream + "-" + s.Version
+		}
+	case len(s.Catalog) > 0:
+		files, err := catalog.New().Load(s.Catalog, scenarioCatalogSchema, s.templateType)
+		if err != nil {
+			return err
+		}
+
+		if len(s.ConfigFile) == 0 && len(files.FieldsConfig) > 0 {
+			if s.config, err = config.LoadConfigFromYaml(files.FieldsConfig); err != nil {
+				return err
+			}
+		}
+
+		s.template, s.fieldsDefinition = files.Template, files.FieldsDefinition
+		if len(s.Name) == 0 {
+			s.Name = fmt.Sprintf("%s-%s.tpl", s.Catalog, s.templateType)
+		}
+	default:
+		if len(s.Fields) == 0 {
+			return errors.New("a `template` stream must have `fields`")
+		}
+
+		if s.template, err = afero.ReadFile(fs, resolve(s.Template)); err != nil {
+			return err
+		}
+
+		if s.fieldsDefinition, err = afero.ReadFile(fs, resolve(s.Fields)); err != nil {
+			return err
+		}
+
+		if len(s.Name) == 0 {
+			s.Name = filepath.Base(s.Template)
+		}
+	}
+
+	// the streams are generated as fast as possible before being interleaved: the time of the generation of an
+	// event is not the one it's written at
+	for i, step := range s.config.Timeline() {
+		if _, ok := step.Set.EPSFactor(); ok || step.At > 0 {
+			return fmt.Errorf("timeline step #%d: `at` and `set.eps` are not supported in the streams of a scenario", i)
+		}
+	}
+
+	return nil
+}
+
+// Configs returns the fields generation configurations of the streams
+func (s Scenario) Configs() []Config {
+	configs := make([]Config, 0, len(s.Streams))
+	for _, stream := range s.Streams {
+		configs = append(configs, stream.config)
+	}
+
+	return configs
+}
+
+// apportion splits totEvents among the streams by their ratio, with the largest remainder method, so that the
+// events of the streams add up to totEvents
+func (s Scenario) apportion(totEvents uint64) []uint64 {
+	var sum float64
+	for _, stream := range s.Streams {
+		sum += stream.Ratio
+	}
+
+	events := make([]uint64, len(s.Streams))
+	remainders := make([]float64, len(s.Streams))
+	var assigned uint64
+	for i, stream := range s.Streams {
+		share := float64(totEvents) * stream.Ratio / sum
+		events[i] = uint64(math.Floor(share))
+		remainders[i] = share - math.Floor(share)
+		assigned += events[i]
+	}
+
+	order := make([]int, len(s.Streams))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return remainders[order[i]] > remainders[order[j]] })
+	for i := 0; assigned < totEvents; i++ {
+		events[order[i%len(order)]]++
+		assigned++
+	}
+
+	return events
+}
+
+// GenerateScenario generates the corpus of the scenario, with totEvents events, and persist it to file.
+func (gc GeneratorCorpus) GenerateScenario(packageRegistryBaseURL string, scenario Scenario, totEvents uint64, timeNow time.Time, randSeed int64) (string, ScenarioResult, error) {
+	if err := gc.fs.MkdirAll(gc.location, corpusLocPerm); err != nil {
+		return "", ScenarioResult{}, fmt.Errorf("cannot generate corpus location folder: %v", err)
+	}
+
+	payloadFilename := filepath.Join(gc.location, gc.corpusFilename(scenario.Name, gc.corpusExt(".ndjson")))
+	f, err := gc.createCorpusFile(payloadFilename)
+	if err != nil {
+		return "", ScenarioResult{}, err
+	}
+
+	result, err := gc.GenerateScenarioTo(f, packageRegistryBaseURL, scenario, totEvents, timeNow, randSeed)
+	if err != nil {
+		_ = f.Close()
+		return "", ScenarioResult{}, err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", ScenarioResult{}, err
+	}
+
+	return payloadFilename, result, nil
+}
+
+// GenerateScenarioTo generates the corpus of the scenario, with totEvents events, and writes it to w. Each stream
+// is generated with its share of the events, the same timeNow and randSeed plus its index, to a temporary corpus;
+// then the events of the streams are interleaved in proportion, so that the event i of a stream of n events is at
+// i/n of the corpus: the streams whose dates are spread over the same period share the same timeline.
+func (gc GeneratorCorpus) GenerateScenarioTo(w io.Writer, packageRegistryBaseURL string, scenario Scenario, totEvents uint64, timeNow time.Time, randSeed int64) (ScenarioResult, error) {
+	if totEvents == 0 {
+		return ScenarioResult{}, ErrNotValidScenarioEvents
+	}
+
+	if len(gc.format) > 0 && gc.format != FormatText {
+		return ScenarioResult{}, ErrNotValidScenarioFormat
+	}
+
+	if len(scenario.Streams) == 0 {
+		return ScenarioResult{}, ErrNotValidScenario
+	}
+
+	tmpDir, err := afero.TempDir(gc.fs, "", "scenario-")
+	if err != nil {
+		return ScenarioResult{}, err
+	}
+
+	defer func() {
+		_ = gc.fs.RemoveAll(tmpDir)
+	}()
+
+	events := scenario.apportion(totEvents)
+	paths := make([]string, len(scenario.Streams))
+	for i, stream := range scenario.Streams {
+		paths[i] = filepath.Join(tmpDir, fmt.Sprintf("%d.ndjson", i))
+		if err := gc.generateStream(paths[i], packageRegistryBaseURL, stream, events[i], timeNow, randSeed+int64(i)); err != nil {
+			return ScenarioResult{}, fmt.Errorf("stream %s: %w", stream.Name, err)
+		}
+	}
+
+	return gc.interleave(w, paths, events)
+}
+
+// generateStream generates the events of the stream to a corpus file at filePath, as fast as possible
+func (gc GeneratorCorpus) generateStream(filePath, packageRegistryBaseURL string, stream ScenarioStream, totEvents uint64, timeNow time.Time, randSeed int64) error {
+	f, err := gc.fs.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	sgc := gc
+	sgc.config = stream.config
+	sgc.templateType = templateTypeGoText
+	if stream.templateType == "placeholder" {
+		sgc.templateType = templateTypeCustom
+	}
+
+	sgc.packageConfig = gc.packageConfig && len(stream.ConfigFile) == 0
+	sgc.reload = nil
+	sgc.eventsPerSecond = 0
+	sgc.maxWriteMBps = 0
+	sgc.maxDuration = 0
+	sgc.truncation = nil
+	sgc.stateFile = ""
+	sgc.reportFile = ""
+
+	if totEvents > 0 {
+		if len(stream.Package) > 0 {
+			err = sgc.GenerateTo(f, packageRegistryBaseURL, stream.Package, stream.DataStream, stream.Version, totEvents, timeNow, randSeed)
+		} else {
+			err = sgc.GenerateWithTemplateContentTo(f, stream.Name, stream.template, stream.fieldsDefinition, totEvents, timeNow, randSeed)
+		}
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// interleave writes to w the records of the corpora at paths, of the planned number of events, in proportion, at
+// the rate and with the write limit of the corpus generator
+func (gc GeneratorCorpus) interleave(w io.Writer, paths []string, events []uint64) (ScenarioResult, error) {
+	readers := make([]*recordReader, 0, len(paths))
+	defer func() {
+		for _, rr := range readers {
+			rr.Close()
+		}
+	}()
+
+	heads := make([][]byte, len(paths))
+	for i, filePath := range paths {
+		rr, err := openRecordReader(gc.fs, filePath)
+		if err != nil {
+			return ScenarioResult{}, err
+		}
+
+		readers = append(readers, rr)
+		if heads[i], _, err = rr.Next(); err != nil && !errors.Is(err, io.EOF) {
+			return ScenarioResult{}, err
+		}
+	}
+
+	if gc.maxWriteMBps > 0 {
+		w = newThrottledWriter(w, gc.maxWriteMBps)
+	}
+
+	var pace *pacer
+	if gc.eventsPerSecond > 0 {
+		pace = newPacer(gc.eventsPerSecond)
+	}
+
+	result := ScenarioResult{Events: make([]uint64, len(paths))}
+	for {
+		// the next record is the one at the earliest point of the timeline, i/n for the record i of n events, the
+		// one of the first stream in case of a tie
+		next := -1
+		for i, head := range heads {
+			if head == nil {
+				continue
+			}
+
+			if next < 0 || result.Events[i]*events[next] < result.Events[next]*events[i] {
+				next = i
+			}
+		}
+
+		if next < 0 {
+			return result, nil
+		}
+
+		if pace != nil {
+			pace.wait()
+		}
+
+		if _, err := w.Write(heads[next]); err != nil {
+			return ScenarioResult{}, err
+		}
+
+		result.Events[next]++
+
+		var err error
+		if heads[next], _, err = readers[next].Next(); err != nil && !errors.Is(err, io.EOF) {
+			return ScenarioResult{}, err
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScenario(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for name, content := range map[string]string{
+		"scenarios/mixed.yml": "tot_events: 8\nstreams:\n" +
+			"  - template: templates/a.tpl\n    fields: templates/fields.yml\n    ratio: 3\n" +
+			"  - name: nginx\n    catalog: nginx.access\n    ratio: 1\n",
+		"scenarios/templates/a.tpl":      `{"stream":"a","name":"{{generate "name"}}"}`,
+		"scenarios/templates/fields.yml": "- name: name\n  type: keyword\n",
+	} {
+		require.NoError(t, afero.WriteFile(fs, name, []byte(content), 0644))
+	}
+
+	scenario, err := LoadScenario(fs, "scenarios/mixed.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "mixed", scenario.Name)
+	assert.Equal(t, uint64(8), scenario.TotEvents)
+	require.Len(t, scenario.Streams, 2)
+	assert.Equal(t, "a.tpl", scenario.Streams[0].Name)
+	assert.Equal(t, "nginx", scenario.Streams[1].Name)
+
+	fc, err := NewGenerator(Config{}, fs, "corpora")
+	require.NoError(t, err)
+	fc.timestamp = func() int64 { return 1647345675 }
+
+	payloadFilename, result, err := fc.GenerateScenario("", scenario, scenario.TotEvents, time.Now(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "corpora/1647345675-mixed.ndjson", payloadFilename)
+	assert.Equal(t, []uint64{6, 2}, result.Events)
+
+	payload, err := afero.ReadFile(fs, payloadFilename)
+	require.NoError(t, err)
+
+	var streams []string
+	for _, line := range strings.Split(strings.TrimSpace(string(payload)), "\n") {
+		if strings.HasPrefix(line, `{"stream":"a"`) {
+			streams = append(streams, "a")
+		} else {
+			assert.Contains(t, line, `"dataset":"nginx.access"`)
+			streams = append(streams, "nginx")
+		}
+	}
+
+	// the events of each stream are spread over the corpus in proportion
+	assert.Equal(t, []string{"a", "nginx", "a", "a", "a", "nginx", "a", "a"}, streams)
+
+	_, err = fc.GenerateScenarioTo(&strings.Builder{}, "", scenario, 0, time.Now(), 1)
+	assert.ErrorIs(t, err, ErrNotValidScenarioEvents)
+
+	fc, err = fc.WithFormat(FormatParquet)
+	require.NoError(t, err)
+	_, err = fc.GenerateScenarioTo(&strings.Builder{}, "", scenario, 8, time.Now(), 1)
+	assert.ErrorIs(t, err, ErrNotValidScenarioFormat)
+}
+
+func TestScenario_apportion(t *testing.T) {
+	scenario := Scenario{Streams: []ScenarioStream{{Ratio: 70}, {Ratio: 20}, {Ratio: 10}}}
+	assert.Equal(t, []uint64{71, 20, 10}, scenario.apportion(101))
+	assert.Equal(t, []uint64{1, 0, 0}, scenario.apportion(1))
+
+	scenario = Scenario{Streams: []ScenarioStream{{Ratio: 1}, {Ratio: 1}, {Ratio: 1}}}
+	assert.Equal(t, []uint64{34, 33, 33}, scenario.apportion(100))
+}
+
+func TestLoadScenario_notValid(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		content  string
+		expected string
+	}{
+		{scenario: "no streams", content: "tot_events: 10\n", expected: ErrNotValidScenario.Error()},
+		{scenario: "no source", content: "streams:\n  - ratio: 1\n", expected: "stream #0: a stream must have one of `package`, `catalog` or `template`"},
+		{scenario: "two sources", content: "streams:\n  - catalog: nginx.access\n    package: nginx\n    ratio: 1\n", expected: "stream #0: a stream must have one of `package`, `catalog` or `template`"},
+		{scenario: "no ratio", content: "streams:\n  - catalog: nginx.access\n", expected: "stream #0: `ratio` must be greater than 0"},
+		{scenario: "engine", content: "streams:\n  - catalog: nginx.access\n    engine: jinja\n    ratio: 1\n", expected: "stream #0: `engine` must be either 'placeholder' or 'gotext', got 'jinja'"},
+		{scenario: "package without version", content: "streams:\n  - package: nginx\n    data_stream: access\n    ratio: 1\n", expected: "stream #0: a `package` stream must have `data_stream` and `version`"},
+		{scenario: "template without fields", content: "streams:\n  - template: a.tpl\n    ratio: 1\n", expected: "stream #0: a `template` stream must have `fields`"},
+		{scenario: "unknown catalog entry", content: "streams:\n  - catalog: nginx.error\n    ratio: 1\n", expected: "stream #0: catalog entry not found: nginx.error with schema b"},
+		{scenario: "time step", content: "streams:\n  - catalog: nginx.access\n    config_file: at.yml\n    ratio: 1\n", expected: "stream #0: timeline step #0: `at` and `set.eps` are not supported in the streams of a scenario"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "scenario.yml", []byte(testCase.content), 0644))
+			require.NoError(t, afero.WriteFile(fs, "at.yml", []byte("timeline:\n  - at: 1m\n    set:\n      eps: 2x\n"), 0644))
+
+			_, err := LoadScenario(fs, "scenario.yml")
+			assert.EqualError(t, err, testCase.expected)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// DefaultMaxEventBytes is the default size limit of an event, the 100mb `http.max_content_length` of Elasticsearch
+const DefaultMaxEventBytes = 100 * 1024 * 1024
+
+const (
+	// OversizeDrop drops the events bigger than the size limit
+	OversizeDrop = "drop"
+	// OversizeTruncate truncates the events bigger than the size limit to the limit
+	OversizeTruncate = "truncate"
+)
+
+// ErrNotValidOversize is returned for an unknown policy for the events bigger than the size limit
+var ErrNotValidOversize = errors.New("please, pass --oversize-events as one of 'drop' or 'truncate'")
+
+// sizeGuard drops or truncates the events bigger than maxBytes, counting them
+type sizeGuard struct {
+	maxBytes int
+	policy   string
+	// oversize is shared by the copies of the corpus generator
+	oversize *uint64
+}
+
+func newSizeGuard() sizeGuard {
+	return sizeGuard{maxBytes: DefaultMaxEventBytes, policy: OversizeDrop, oversize: new(uint64)}
+}
+
+// check drops or truncates the event written in buf after offset, returning false when it is dropped
+func (g sizeGuard) check(buf *bytes.Buffer, offset int) bool {
+	if g.maxBytes <= 0 || buf.Len()-offset <= g.maxBytes {
+		return true
+	}
+
+	atomic.AddUint64(g.oversize, 1)
+	if g.policy == OversizeDrop {
+		return false
+	}
+
+	// don't split a multi-byte character
+	end := offset + g.maxBytes
+	for end > offset && !utf8.RuneStart(buf.Bytes()[end]) {
+		end--
+	}
+
+	buf.Truncate(end)
+	return true
+}
+
+// WithMaxEventSize returns a copy of the corpus generator handling the events bigger than maxBytes according to
+// policy, either OversizeDrop or OversizeTruncate. A maxBytes not greater than zero means no limit.
+func (gc GeneratorCorpus) WithMaxEventSize(maxBytes int, policy string) (GeneratorCorpus, error) {
+	if policy != OversizeDrop && policy != OversizeTruncate {
+		return gc, ErrNotValidOversize
+	}
+
+	gc.sizeGuard.maxBytes = maxBytes
+	gc.sizeGuard.policy = policy
+	return gc, nil
+}
+
+// OversizeEvents returns the number of events dropped or truncated because bigger than the size limit
+func (gc GeneratorCorpus) OversizeEvents() uint64 {
+	if gc.sizeGuard.oversize == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(gc.sizeGuard.oversize)
+}
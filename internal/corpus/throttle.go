@@ -0,0 +1,86 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"io"
+	"time"
+)
+
+// bytesPerMB is the number of bytes in a MB of --max-write-mbps
+const bytesPerMB = 1000 * 1000
+
+// throttledWriter limits the bytes written per second with a token bucket, holding up to a second of writes,
+// so that short bursts are allowed while the average rate is capped.
+type throttledWriter struct {
+	w io.Writer
+	// bytes per second
+	rate float64
+	// the bucket holds up to a second of writes
+	tokens float64
+	last   time.Time
+	// now and sleep allow replacing the clock during testing
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newThrottledWriter(w io.Writer, maxWriteMBps float64) *throttledWriter {
+	return &throttledWriter{
+		w:     w,
+		rate:  maxWriteMBps * bytesPerMB,
+		now:   time.Now,
+		sleep: time.Sleep,
+	}
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		// writes bigger than the bucket are split in chunks
+		chunk := p
+		if float64(len(chunk)) > t.rate {
+			size := int(t.rate)
+			if size < 1 {
+				size = 1
+			}
+
+			chunk = chunk[:size]
+		}
+
+		t.wait(float64(len(chunk)))
+
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		p = p[len(chunk):]
+	}
+
+	return written, nil
+}
+
+// wait refills the bucket for the time elapsed since the last write and sleeps until it holds n tokens
+func (t *throttledWriter) wait(n float64) {
+	now := t.now()
+	if t.last.IsZero() {
+		t.tokens = t.rate
+	} else {
+		t.tokens += now.Sub(t.last).Seconds() * t.rate
+		if t.tokens > t.rate {
+			t.tokens = t.rate
+		}
+	}
+
+	t.last = now
+	t.tokens -= n
+	if t.tokens < 0 {
+		missing := time.Duration(-t.tokens / t.rate * float64(time.Second))
+		t.sleep(missing)
+		t.last = t.last.Add(missing)
+		t.tokens = 0
+	}
+}
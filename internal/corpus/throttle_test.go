@@ -0,0 +1,48 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package corpus
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottledWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := newThrottledWriter(&buf, 1)
+
+	// fake clock advancing only when sleeping
+	clock := time.Unix(0, 0)
+	var slept time.Duration
+	w.now = func() time.Time { return clock }
+	w.sleep = func(d time.Duration) {
+		slept += d
+		clock = clock.Add(d)
+	}
+
+	// the first second of writes fills the bucket without waiting
+	n, err := w.Write(make([]byte, bytesPerMB))
+	require.NoError(t, err)
+	assert.Equal(t, bytesPerMB, n)
+	assert.Equal(t, time.Duration(0), slept)
+
+	// 2.5 more MB at 1 MB per second take 2.5 seconds, even if written at once
+	n, err = w.Write(make([]byte, 5*bytesPerMB/2))
+	require.NoError(t, err)
+	assert.Equal(t, 5*bytesPerMB/2, n)
+	assert.InDelta(t, 2.5, slept.Seconds(), 0.001)
+	assert.Equal(t, 7*bytesPerMB/2, buf.Len())
+
+	// idle time refills the bucket, up to a second of writes
+	clock = clock.Add(10 * time.Second)
+	slept = 0
+	_, err = w.Write(make([]byte, 3*bytesPerMB/2))
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, slept.Seconds(), 0.001)
+}
@@ -0,0 +1,415 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package dashboard checks that the dashboards of a package light up with the generated data, by counting the
+// documents each of their panels is drawn from.
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrNotValidURL = errors.New("the Elasticsearch URL must be an http or https URL")
+
+const (
+	// recordsField is the pseudo field of the Lens columns counting the documents
+	recordsField = "___records___"
+	// indexRefSuffix is the suffix of the name of the reference to the data view of a visualization embedded in a
+	// dashboard
+	indexRefSuffix = "kibanaSavedObjectMeta.searchSourceJSON.index"
+)
+
+// Panel is a panel of a dashboard drawn from the documents of a data view
+type Panel struct {
+	// Dashboard is the title of the dashboard of the panel
+	Dashboard string
+	// Title is the title of the panel, or its position in the dashboard when it has none
+	Title string
+	// Index is the index pattern of the data views of the panel, comma separated when more than one
+	Index string
+	// Fields are the fields the panel aggregates, sorted: a document without one of them doesn't show in the panel
+	Fields []string
+	// Filters and Exclusions are the queries the documents of the panel must, or must not, match: the ones of the
+	// panel and of the dashboard, and its filters
+	Filters    []any
+	Exclusions []any
+	// Untranslated are the queries of the panel, or of the dashboard, that cannot be run with Elasticsearch, like
+	// the KQL ones not supported, that are left out of the count
+	Untranslated []string
+}
+
+// savedObject is a Kibana saved object, as found in the `kibana` folder of a package
+type savedObject struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Attributes map[string]any `json:"attributes"`
+	References []reference    `json:"references"`
+}
+
+type reference struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Panels returns the panels drawn from a data view of the dashboards of the Kibana assets of a package, by their path
+// in the `kibana` folder of the package, sorted by dashboard. The panels without a data view, like the markdown
+// ones, are left out.
+func Panels(assets map[string][]byte) ([]Panel, error) {
+	names := make([]string, 0, len(assets))
+	for name := range assets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	objects := make(map[string]savedObject, len(assets))
+	var dashboards []savedObject
+	for _, name := range names {
+		var object savedObject
+		if err := json.Unmarshal(assets[name], &object); err != nil {
+			return nil, fmt.Errorf("cannot decode Kibana asset %s: %w", name, err)
+		}
+
+		if len(object.Type) == 0 {
+			object.Type = path.Dir(name)
+		}
+
+		objects[object.ID] = object
+		if object.Type == "dashboard" {
+			dashboards = append(dashboards, object)
+		}
+	}
+
+	var panels []Panel
+	for _, dashboard := range dashboards {
+		title, _ := dashboard.Attributes["title"].(string)
+		dashboardFilters, dashboardExclusions, dashboardUntranslated := searchSourceQueries(attribute(dashboard.Attributes, "kibanaSavedObjectMeta", "searchSourceJSON"))
+
+		embedded, _ := decodeJSONValue(dashboard.Attributes["panelsJSON"]).([]any)
+		for i, e := range embedded {
+			config, _ := e.(map[string]any)
+			object, ok := panelObject(config, dashboard, objects)
+			if !ok {
+				continue
+			}
+
+			panel := objectPanel(object, objects)
+			if len(panel.Index) == 0 {
+				continue
+			}
+
+			panel.Dashboard = title
+			panel.Title = panelTitle(config, object, i)
+			panel.Filters = append(panel.Filters, dashboardFilters...)
+			panel.Exclusions = append(panel.Exclusions, dashboardExclusions...)
+			panel.Untranslated = append(panel.Untranslated, dashboardUntranslated...)
+			panels = append(panels, panel)
+		}
+	}
+
+	return panels, nil
+}
+
+// panelObject returns the saved object drawn by the panel of the dashboard with config: the one embedded in it, or
+// the one it references
+func panelObject(config map[string]any, dashboard savedObject, objects map[string]savedObject) (savedObject, bool) {
+	embeddableConfig, _ := config["embeddableConfig"].(map[string]any)
+	panelType, _ := config["type"].(string)
+
+	// a Lens visualization by value
+	if attributes, ok := embeddableConfig["attributes"].(map[string]any); ok {
+		object := savedObject{Type: panelType, Attributes: attributes}
+		if references, err := json.Marshal(attributes["references"]); err == nil {
+			_ = json.Unmarshal(references, &object.References)
+		}
+
+		return object, true
+	}
+
+	// a visualization by value, whose data view is referenced by the dashboard
+	if savedVis, ok := embeddableConfig["savedVis"].(map[string]any); ok {
+		panelIndex, _ := config["panelIndex"].(string)
+		data, _ := savedVis["data"].(map[string]any)
+		object := savedObject{Type: "visualization", Attributes: map[string]any{
+			"visState":              map[string]any{"aggs": data["aggs"]},
+			"kibanaSavedObjectMeta": map[string]any{"searchSourceJSON": data["searchSource"]},
+		}}
+
+		for _, ref := range dashboard.References {
+			if ref.Name == panelIndex+":"+indexRefSuffix {
+				object.References = append(object.References, reference{ID: ref.ID, Name: indexRefSuffix, Type: ref.Type})
+			}
+		}
+
+		return object, true
+	}
+
+	// a saved object by reference
+	id, _ := config["id"].(string)
+	if refName, ok := config["panelRefName"].(string); ok {
+		for _, ref := range dashboard.References {
+			if ref.Name == refName {
+				id = ref.ID
+			}
+		}
+	}
+
+	object, ok := objects[id]
+	return object, ok && len(id) > 0
+}
+
+// objectPanel returns the panel of the data view, the fields and the queries of a saved object
+func objectPanel(object savedObject, objects map[string]savedObject) Panel {
+	var panel Panel
+	fields := make(map[string]bool)
+	var indexRefs []string
+
+	switch object.Type {
+	case "lens":
+		state, _ := object.Attributes["state"].(map[string]any)
+		datasourceStates, _ := state["datasourceStates"].(map[string]any)
+		for _, datasource := range datasourceStates {
+			layers, _ := attribute(datasource, "layers").(map[string]any)
+			for _, layer := range layers {
+				columns, _ := attribute(layer, "columns").(map[string]any)
+				for _, column := range columns {
+					if field, _ := attribute(column, "sourceField").(string); len(field) > 0 && field != recordsField {
+						fields[field] = true
+					}
+				}
+			}
+		}
+
+		panel.Filters, panel.Exclusions, panel.Untranslated = queries(state["query"], state["filters"])
+		for _, ref := range object.References {
+			if ref.Type == "index-pattern" {
+				indexRefs = append(indexRefs, ref.ID)
+			}
+		}
+	case "visualization", "search":
+		visState, _ := decodeJSONValue(object.Attributes["visState"]).(map[string]any)
+		aggs, _ := visState["aggs"].([]any)
+		for _, agg := range aggs {
+			if field, _ := attribute(agg, "params", "field").(string); len(field) > 0 {
+				fields[field] = true
+			}
+		}
+
+		searchSource := attribute(object.Attributes, "kibanaSavedObjectMeta", "searchSourceJSON")
+		panel.Filters, panel.Exclusions, panel.Untranslated = searchSourceQueries(searchSource)
+
+		indexRefName, _ := attribute(decodeJSONValue(searchSource), "indexRefName").(string)
+		for _, ref := range object.References {
+			if ref.Type == "index-pattern" && (ref.Name == indexRefName || ref.Name == indexRefSuffix) {
+				indexRefs = append(indexRefs, ref.ID)
+			}
+		}
+	}
+
+	patterns := make(map[string]bool)
+	for _, id := range indexRefs {
+		// the data views managed by Fleet, like `logs-*`, are not in the package, and their id is their pattern
+		pattern := id
+		if dataView, ok := objects[id]; ok && dataView.Type == "index-pattern" {
+			if title, _ := dataView.Attributes["title"].(string); len(title) > 0 {
+				pattern = title
+			}
+		}
+
+		patterns[pattern] = true
+	}
+
+	panel.Index = strings.Join(sortedKeys(patterns), ",")
+	panel.Fields = sortedKeys(fields)
+
+	return panel
+}
+
+// searchSourceQueries returns the queries of the search source of a visualization, or of a dashboard
+func searchSourceQueries(searchSource any) ([]any, []any, []string) {
+	source, _ := decodeJSONValue(searchSource).(map[string]any)
+	return queries(source["query"], source["filter"])
+}
+
+// queries returns the Elasticsearch queries the documents must, or must not, match for the query and the filters of
+// a saved object, and the queries that cannot be translated
+func queries(query, filters any) ([]any, []any, []string) {
+	var must, mustNot []any
+	var untranslated []string
+
+	if q, ok := query.(map[string]any); ok {
+		text, _ := q["query"].(string)
+		language, _ := q["language"].(string)
+		switch {
+		case len(strings.TrimSpace(text)) == 0:
+		case language == "lucene":
+			must = append(must, map[string]any{"query_string": map[string]any{"query": text}})
+		default:
+			translated, err := translateKQL(text)
+			if err != nil {
+				untranslated = append(untranslated, text)
+			} else if translated != nil {
+				must = append(must, translated)
+			}
+		}
+	}
+
+	list, _ := filters.([]any)
+	for _, f := range list {
+		filter, _ := f.(map[string]any)
+		meta, _ := filter["meta"].(map[string]any)
+		if disabled, _ := meta["disabled"].(bool); disabled {
+			continue
+		}
+
+		// the filters have their query in `query`, or inline next to `meta` in the older saved objects
+		q, ok := filter["query"].(map[string]any)
+		if !ok {
+			q = make(map[string]any)
+			for key, value := range filter {
+				if key != "meta" && key != "$state" {
+					q[key] = value
+				}
+			}
+		}
+
+		if len(q) == 0 {
+			continue
+		}
+
+		if negate, _ := meta["negate"].(bool); negate {
+			mustNot = append(mustNot, q)
+		} else {
+			must = append(must, q)
+		}
+	}
+
+	return must, mustNot, untranslated
+}
+
+// panelTitle returns the title of the panel with config, drawn from object, or its position in the dashboard
+func panelTitle(config map[string]any, object savedObject, i int) string {
+	for _, title := range []any{config["title"], attribute(config, "embeddableConfig", "title"), object.Attributes["title"]} {
+		if t, _ := title.(string); len(t) > 0 {
+			return t
+		}
+	}
+
+	return "panel " + strconv.Itoa(i+1)
+}
+
+// decodeJSONValue returns the value of a saved object attribute encoded as a JSON string, like the `panelsJSON` of the
+// dashboards exported by Kibana, or the value itself when not a string, like in the packages built by elastic-package
+func decodeJSONValue(value any) any {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+
+	var decoded any
+	if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+		return nil
+	}
+
+	return decoded
+}
+
+// attribute returns the value at the path of keys of the nested objects of value, or nil
+func attribute(value any, keys ...string) any {
+	for _, key := range keys {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		value = m[key]
+	}
+
+	return value
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Count returns the number of the documents the panel is drawn from in the Elasticsearch at esURL: the documents of
+// its data views, matching its queries, with all of its fields
+func Count(ctx context.Context, client *http.Client, esURL string, panel Panel) (uint64, error) {
+	u, err := url.Parse(esURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return 0, ErrNotValidURL
+	}
+
+	filters := append([]any(nil), panel.Filters...)
+	for _, field := range panel.Fields {
+		filters = append(filters, map[string]any{"exists": map[string]any{"field": field}})
+	}
+
+	boolQuery := map[string]any{"filter": filters}
+	if len(panel.Exclusions) > 0 {
+		boolQuery["must_not"] = panel.Exclusions
+	}
+
+	body, err := json.Marshal(map[string]any{"query": map[string]any{"bool": boolQuery}})
+	if err != nil {
+		return 0, err
+	}
+
+	indices := strings.Split(panel.Index, ",")
+	for i, index := range indices {
+		indices[i] = url.PathEscape(index)
+	}
+
+	countURL := strings.TrimSuffix(u.String(), "/") + "/" + strings.Join(indices, ",") + "/_count?ignore_unavailable=true&allow_no_indices=true"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, countURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("count of %s failed with status %d: %s", panel.Index, resp.StatusCode, bytes.TrimSpace(response))
+	}
+
+	var count struct {
+		Count uint64 `json:"count"`
+	}
+
+	if err := json.Unmarshal(response, &count); err != nil {
+		return 0, fmt.Errorf("cannot decode count response: %w", err)
+	}
+
+	return count.Count, nil
+}
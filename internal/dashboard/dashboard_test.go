@@ -0,0 +1,115 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assets are Kibana assets of a package, as exported by Kibana, with the attributes encoded as JSON strings
+var assets = map[string][]byte{
+	"dashboard/nginx-overview.json": []byte(`{
+  "id": "nginx-overview",
+  "type": "dashboard",
+  "attributes": {
+    "title": "[Nginx] Overview",
+    "kibanaSavedObjectMeta": {"searchSourceJSON": "{\"query\":{\"query\":\"data_stream.dataset : nginx.access\",\"language\":\"kuery\"},\"filter\":[{\"meta\":{\"negate\":true},\"query\":{\"match_phrase\":{\"http.request.method\":\"OPTIONS\"}}},{\"meta\":{\"disabled\":true},\"query\":{\"match_phrase\":{\"host.name\":\"web-1\"}}}]}"},
+    "panelsJSON": "[{\"panelIndex\":\"1\",\"type\":\"lens\",\"embeddableConfig\":{\"attributes\":{\"title\":\"Response codes\",\"references\":[{\"id\":\"logs-*\",\"name\":\"indexpattern-datasource-layer-1\",\"type\":\"index-pattern\"}],\"state\":{\"query\":{\"query\":\"url.path : *\",\"language\":\"kuery\"},\"filters\":[],\"datasourceStates\":{\"formBased\":{\"layers\":{\"1\":{\"columns\":{\"a\":{\"sourceField\":\"http.response.status_code\"},\"b\":{\"sourceField\":\"___records___\"}}}}}}}}}},{\"panelIndex\":\"2\",\"panelRefName\":\"panel_2\"},{\"panelIndex\":\"3\",\"type\":\"visualization\",\"embeddableConfig\":{\"savedVis\":{\"type\":\"markdown\",\"data\":{\"aggs\":[],\"searchSource\":{}}}}}]"
+  },
+  "references": [{"id": "nginx-top-urls", "name": "panel_2", "type": "visualization"}]
+}`),
+	"visualization/nginx-top-urls.json": []byte(`{
+  "id": "nginx-top-urls",
+  "type": "visualization",
+  "attributes": {
+    "title": "Top URLs",
+    "visState": "{\"aggs\":[{\"type\":\"count\",\"params\":{}},{\"type\":\"terms\",\"params\":{\"field\":\"url.original\"}}]}",
+    "kibanaSavedObjectMeta": {"searchSourceJSON": "{\"indexRefName\":\"kibanaSavedObjectMeta.searchSourceJSON.index\",\"query\":{\"query\":\"items:{ name : x }\",\"language\":\"kuery\"},\"filter\":[]}"}
+  },
+  "references": [{"id": "nginx-logs", "name": "kibanaSavedObjectMeta.searchSourceJSON.index", "type": "index-pattern"}]
+}`),
+	"index_pattern/nginx-logs.json": []byte(`{"id": "nginx-logs", "type": "index-pattern", "attributes": {"title": "logs-nginx.*-*"}}`),
+}
+
+func TestPanels(t *testing.T) {
+	panels, err := Panels(assets)
+	require.NoError(t, err)
+
+	dashboardQuery := map[string]any{"match": map[string]any{"data_stream.dataset": map[string]any{"query": "nginx.access", "lenient": true}}}
+	dashboardExclusion := map[string]any{"match_phrase": map[string]any{"http.request.method": "OPTIONS"}}
+
+	// the markdown panel has no data view, and the disabled filter is left out
+	assert.Equal(t, []Panel{
+		{
+			Dashboard:  "[Nginx] Overview",
+			Title:      "Response codes",
+			Index:      "logs-*",
+			Fields:     []string{"http.response.status_code"},
+			Filters:    []any{map[string]any{"exists": map[string]any{"field": "url.path"}}, dashboardQuery},
+			Exclusions: []any{dashboardExclusion},
+		},
+		{
+			Dashboard:    "[Nginx] Overview",
+			Title:        "Top URLs",
+			Index:        "logs-nginx.*-*",
+			Fields:       []string{"url.original"},
+			Filters:      []any{dashboardQuery},
+			Exclusions:   []any{dashboardExclusion},
+			Untranslated: []string{"items:{ name : x }"},
+		},
+	}, panels)
+
+	_, err = Panels(map[string][]byte{"dashboard/broken.json": []byte("{")})
+	assert.ErrorContains(t, err, "cannot decode Kibana asset dashboard/broken.json")
+}
+
+func TestCount(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/logs-nginx.*-*,logs-*/_count" || r.URL.Query().Get("ignore_unavailable") != "true" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &request)
+		_, _ = w.Write([]byte(`{"count":42}`))
+	}))
+	defer server.Close()
+
+	panel := Panel{
+		Index:      "logs-nginx.*-*,logs-*",
+		Fields:     []string{"url.original"},
+		Filters:    []any{map[string]any{"match": map[string]any{"event.dataset": "nginx.access"}}},
+		Exclusions: []any{map[string]any{"match_phrase": map[string]any{"http.request.method": "OPTIONS"}}},
+	}
+
+	count, err := Count(context.Background(), http.DefaultClient, server.URL, panel)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), count)
+
+	// the documents of the panel have all of its fields
+	actual, err := json.Marshal(request)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"query":{"bool":{
+		"filter":[{"match":{"event.dataset":"nginx.access"}},{"exists":{"field":"url.original"}}],
+		"must_not":[{"match_phrase":{"http.request.method":"OPTIONS"}}]
+	}}}`, string(actual))
+
+	_, err = Count(context.Background(), http.DefaultClient, server.URL, Panel{Index: "metrics-*"})
+	assert.ErrorContains(t, err, "count of metrics-* failed with status 404")
+
+	_, err = Count(context.Background(), http.DefaultClient, "localhost:9200", panel)
+	assert.ErrorIs(t, err, ErrNotValidURL)
+}
@@ -0,0 +1,266 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrKQLNotSupported = errors.New("KQL query not supported")
+
+// kqlToken is a token of a KQL query: an operator, a parenthesis, or a value, quoted or not
+type kqlToken struct {
+	text   string
+	quoted bool
+	// op is set for the operators and the parentheses, like `:`, `>=` or `(`
+	op bool
+}
+
+// translateKQL returns the Elasticsearch query of a KQL query, for the subset of KQL used by the panels of the
+// dashboards: `field : value`, with a quoted phrase, a wildcard or `*` for the field to exist, `field : (a or b)`,
+// the range operators, free text, `and`, `or`, `not` and the parentheses. It returns nil for an empty query.
+func translateKQL(query string) (map[string]any, error) {
+	tokens, err := tokenizeKQL(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &kqlParser{tokens: tokens}
+	q, err := p.or(p.clause)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %s in %s", ErrKQLNotSupported, p.tokens[p.pos].text, query)
+	}
+
+	return q, nil
+}
+
+func tokenizeKQL(query string) ([]kqlToken, error) {
+	var tokens []kqlToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == ':':
+			tokens = append(tokens, kqlToken{text: string(c), op: true})
+			i++
+		case c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(query) && query[i+1] == '=' {
+				op += "="
+			}
+
+			tokens = append(tokens, kqlToken{text: op, op: true})
+			i += len(op)
+		case c == '{' || c == '}':
+			return nil, fmt.Errorf("%w: nested field queries in %s", ErrKQLNotSupported, query)
+		case c == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' && i+1 < len(query) {
+					i++
+				}
+
+				b.WriteByte(query[i])
+			}
+
+			if i == len(query) {
+				return nil, fmt.Errorf("%w: unterminated phrase in %s", ErrKQLNotSupported, query)
+			}
+
+			tokens = append(tokens, kqlToken{text: b.String(), quoted: true})
+			i++
+		default:
+			var b strings.Builder
+			for ; i < len(query) && !strings.ContainsRune(" \t\n\r():<>{}\"", rune(query[i])); i++ {
+				if query[i] == '\\' && i+1 < len(query) {
+					i++
+				}
+
+				b.WriteByte(query[i])
+			}
+
+			tokens = append(tokens, kqlToken{text: b.String()})
+		}
+	}
+
+	return tokens, nil
+}
+
+// kqlParser parses the tokens of a KQL query, by recursive descent
+type kqlParser struct {
+	tokens []kqlToken
+	pos    int
+}
+
+// keyword reports whether the next token is the keyword, like `and`, consuming it
+func (p *kqlParser) keyword(keyword string) bool {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].op && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, keyword) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+// operator reports whether the next token is the operator, like `(`, consuming it
+func (p *kqlParser) operator(op string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].op && p.tokens[p.pos].text == op {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+// or parses the operands joined by `or`, each one parsed by operand
+func (p *kqlParser) or(operand func() (map[string]any, error)) (map[string]any, error) {
+	return p.join("or", func() (map[string]any, error) {
+		return p.join("and", func() (map[string]any, error) {
+			return p.not(operand)
+		})
+	})
+}
+
+// join parses the operands joined by the keyword, and or or, into a bool query
+func (p *kqlParser) join(keyword string, operand func() (map[string]any, error)) (map[string]any, error) {
+	q, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	queries := []any{q}
+	for p.keyword(keyword) {
+		q, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		queries = append(queries, q)
+	}
+
+	if len(queries) == 1 {
+		return q, nil
+	}
+
+	if keyword == "and" {
+		return map[string]any{"bool": map[string]any{"filter": queries}}, nil
+	}
+
+	return map[string]any{"bool": map[string]any{"should": queries, "minimum_should_match": 1}}, nil
+}
+
+func (p *kqlParser) not(operand func() (map[string]any, error)) (map[string]any, error) {
+	if !p.keyword("not") {
+		return operand()
+	}
+
+	q, err := p.not(operand)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{"bool": map[string]any{"must_not": []any{q}}}, nil
+}
+
+// clause parses a parenthesized query, a field query or free text
+func (p *kqlParser) clause() (map[string]any, error) {
+	if p.operator("(") {
+		q, err := p.or(p.clause)
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.operator(")") {
+			return nil, fmt.Errorf("%w: missing closing parenthesis", ErrKQLNotSupported)
+		}
+
+		return q, nil
+	}
+
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.operator(":") {
+		return p.fieldValue(value.text)
+	}
+
+	for _, op := range []struct{ kql, es string }{{"<=", "lte"}, {">=", "gte"}, {"<", "lt"}, {">", "gt"}} {
+		if p.operator(op.kql) {
+			bound, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]any{"range": map[string]any{value.text: map[string]any{op.es: bound.text}}}, nil
+		}
+	}
+
+	if value.quoted {
+		return map[string]any{"multi_match": map[string]any{"query": value.text, "type": "phrase", "lenient": true}}, nil
+	}
+
+	return map[string]any{"multi_match": map[string]any{"query": value.text, "type": "best_fields", "lenient": true}}, nil
+}
+
+// fieldValue parses a value of field, a parenthesized group of them, or `not` one
+func (p *kqlParser) fieldValue(field string) (map[string]any, error) {
+	if p.operator("(") {
+		q, err := p.or(func() (map[string]any, error) { return p.fieldValue(field) })
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.operator(")") {
+			return nil, fmt.Errorf("%w: missing closing parenthesis", ErrKQLNotSupported)
+		}
+
+		return q, nil
+	}
+
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case value.quoted:
+		return map[string]any{"match_phrase": map[string]any{field: value.text}}, nil
+	case value.text == "*":
+		return map[string]any{"exists": map[string]any{"field": field}}, nil
+	case strings.Contains(value.text, "*"):
+		return map[string]any{"wildcard": map[string]any{field: map[string]any{"value": value.text}}}, nil
+	default:
+		return map[string]any{"match": map[string]any{field: map[string]any{"query": value.text, "lenient": true}}}, nil
+	}
+}
+
+// value returns the next token, that must be a value
+func (p *kqlParser) value() (kqlToken, error) {
+	if p.pos == len(p.tokens) {
+		return kqlToken{}, fmt.Errorf("%w: unexpected end of the query", ErrKQLNotSupported)
+	}
+
+	token := p.tokens[p.pos]
+	if token.op {
+		return kqlToken{}, fmt.Errorf("%w: unexpected %s", ErrKQLNotSupported, token.text)
+	}
+
+	p.pos++
+	return token, nil
+}
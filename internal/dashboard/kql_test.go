@@ -0,0 +1,54 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package dashboard
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateKQL(t *testing.T) {
+	testCases := []struct {
+		kql      string
+		expected string
+	}{
+		{kql: "", expected: "null"},
+		{kql: "event.dataset : nginx.access", expected: `{"match":{"event.dataset":{"lenient":true,"query":"nginx.access"}}}`},
+		{kql: `message: "GET /index.html"`, expected: `{"match_phrase":{"message":"GET /index.html"}}`},
+		{kql: "url.path : *", expected: `{"exists":{"field":"url.path"}}`},
+		{kql: "host.name : web-*", expected: `{"wildcard":{"host.name":{"value":"web-*"}}}`},
+		{kql: "http.response.status_code >= 500", expected: `{"range":{"http.response.status_code":{"gte":"500"}}}`},
+		{kql: "error", expected: `{"multi_match":{"lenient":true,"query":"error","type":"best_fields"}}`},
+		{
+			kql:      "data_stream.dataset : nginx.access and not http.response.status_code : (200 or 304)",
+			expected: `{"bool":{"filter":[{"match":{"data_stream.dataset":{"lenient":true,"query":"nginx.access"}}},{"bool":{"must_not":[{"bool":{"minimum_should_match":1,"should":[{"match":{"http.response.status_code":{"lenient":true,"query":"200"}}},{"match":{"http.response.status_code":{"lenient":true,"query":"304"}}}]}}]}}]}}`,
+		},
+		{
+			kql:      "(a : 1 or b : 2) and c : 3",
+			expected: `{"bool":{"filter":[{"bool":{"minimum_should_match":1,"should":[{"match":{"a":{"lenient":true,"query":"1"}}},{"match":{"b":{"lenient":true,"query":"2"}}}]}},{"match":{"c":{"lenient":true,"query":"3"}}}]}}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.kql, func(t *testing.T) {
+			q, err := translateKQL(testCase.kql)
+			require.NoError(t, err)
+
+			actual, err := json.Marshal(q)
+			require.NoError(t, err)
+			assert.JSONEq(t, testCase.expected, string(actual))
+		})
+	}
+}
+
+func TestTranslateKQL_notSupported(t *testing.T) {
+	for _, kql := range []string{"a : (1 or 2", `a : "b`, "items:{ name : x }", "a : ", "a : 1 b : )"} {
+		_, err := translateKQL(kql)
+		assert.ErrorIs(t, err, ErrKQLNotSupported, kql)
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package otlp encodes the generated events as OpenTelemetry OTLP export requests of logs or of metrics, with the
+// protobuf encoding, so that the OTel ingest paths can be benchmarked with the same field configs as the corpora.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// SignalLogs encodes each event as a log record
+	SignalLogs = "logs"
+	// SignalMetrics encodes each numeric field of an event as a gauge metric
+	SignalMetrics = "metrics"
+
+	scopeName = "elastic-integration-corpus-generator-tool"
+
+	timestampField = "@timestamp"
+	messageField   = "message"
+	levelField     = "log.level"
+)
+
+var ErrNotValidSignal = errors.New("the OTLP signal must be one of 'logs' or 'metrics'")
+
+// resourcePrefixes are the namespaces of the fields that are resource attributes rather than attributes of the
+// log records or of the data points, after the OpenTelemetry semantic conventions
+var resourcePrefixes = []string{"cloud.", "container.", "deployment.", "device.", "faas.", "host.", "k8s.", "os.", "process.", "service.", "telemetry."}
+
+// severities are the severity numbers of the values of `log.level`
+var severities = map[string]int64{
+	"trace":       1,
+	"debug":       5,
+	"info":        9,
+	"information": 9,
+	"notice":      10,
+	"warn":        13,
+	"warning":     13,
+	"error":       17,
+	"err":         17,
+	"critical":    21,
+	"crit":        21,
+	"alert":       22,
+	"fatal":       21,
+	"emergency":   23,
+	"emerg":       23,
+}
+
+// ValidSignal returns an error if signal is not one of SignalLogs or SignalMetrics
+func ValidSignal(signal string) error {
+	if signal != SignalLogs && signal != SignalMetrics {
+		return ErrNotValidSignal
+	}
+
+	return nil
+}
+
+// Path returns the path of the OTLP/HTTP endpoint of signal, relative to the base URL of the receiver
+func Path(signal string) string {
+	return "v1/" + signal
+}
+
+// keyValue is an attribute, the dotted name of a field and its value
+type keyValue struct {
+	key   string
+	value any
+}
+
+// event is an event split into the attributes of its resource and its other fields
+type event struct {
+	timeUnixNano uint64
+	resource     []keyValue
+	fields       []keyValue
+}
+
+// Request returns the export request of signal, `ExportLogsServiceRequest` or `ExportMetricsServiceRequest`, of
+// the events, each one a JSON object. The fields in the namespaces of the resources, like `host.*` or `service.*`,
+// are the attributes of the resource, and the events with the same ones share it. `@timestamp`, an RFC 3339 date
+// or milliseconds since the epoch, is the time of the log record or of the data points.
+func Request(signal string, events [][]byte) ([]byte, error) {
+	if err := ValidSignal(signal); err != nil {
+		return nil, err
+	}
+
+	var groups []*resourceGroup
+	byResource := make(map[string]*resourceGroup)
+	for _, p := range events {
+		e, err := parseEvent(p)
+		if err != nil {
+			return nil, err
+		}
+
+		var resource protoWriter
+		for _, kv := range e.resource {
+			resource.message(1, func(m *protoWriter) { writeKeyValue(m, kv) })
+		}
+
+		group, ok := byResource[resource.buf.String()]
+		if !ok {
+			group = &resourceGroup{resource: resource.buf.Bytes()}
+			byResource[resource.buf.String()] = group
+			groups = append(groups, group)
+		}
+
+		group.events = append(group.events, e)
+	}
+
+	var request protoWriter
+	for _, group := range groups {
+		request.message(1, func(m *protoWriter) {
+			m.bytes(1, group.resource)
+			m.message(2, func(scope *protoWriter) {
+				scope.message(1, func(s *protoWriter) { s.string(1, scopeName) })
+				for _, e := range group.events {
+					if signal == SignalLogs {
+						scope.message(2, func(r *protoWriter) { writeLogRecord(r, e) })
+						continue
+					}
+
+					writeMetrics(scope, e)
+				}
+			})
+		})
+	}
+
+	return request.buf.Bytes(), nil
+}
+
+// resourceGroup holds the events sharing a resource, and the encoded resource
+type resourceGroup struct {
+	resource []byte
+	events   []event
+}
+
+// parseEvent returns the event of the JSON object p, with its fields flattened to dotted names sorted by name
+func parseEvent(p []byte) (event, error) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+
+	var object map[string]any
+	if err := decoder.Decode(&object); err != nil {
+		return event{}, fmt.Errorf("cannot decode event as a JSON object: %w", err)
+	}
+
+	var flat []keyValue
+	flatten("", object, &flat)
+	sort.Slice(flat, func(i, j int) bool { return flat[i].key < flat[j].key })
+
+	var e event
+	for _, kv := range flat {
+		switch {
+		case kv.key == timestampField:
+			e.timeUnixNano = timeUnixNano(kv.value)
+		case isResourceField(kv.key):
+			e.resource = append(e.resource, kv)
+		default:
+			e.fields = append(e.fields, kv)
+		}
+	}
+
+	return e, nil
+}
+
+// flatten adds to flat the leaf values of object, with their dotted names
+func flatten(prefix string, object map[string]any, flat *[]keyValue) {
+	for key, value := range object {
+		switch v := value.(type) {
+		case nil:
+		case map[string]any:
+			flatten(prefix+key+".", v, flat)
+		default:
+			*flat = append(*flat, keyValue{key: prefix + key, value: v})
+		}
+	}
+}
+
+func isResourceField(name string) bool {
+	for _, prefix := range resourcePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// timeUnixNano returns the time of the value of `@timestamp`, zero when it's not a date
+func timeUnixNano(value any) uint64 {
+	switch v := value.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil && t.UnixNano() > 0 {
+			return uint64(t.UnixNano())
+		}
+	case json.Number:
+		if ms, err := v.Float64(); err == nil && ms > 0 {
+			return uint64(ms * float64(time.Millisecond))
+		}
+	}
+
+	return 0
+}
+
+// writeLogRecord writes the `LogRecord` of e: `message` is its body, `log.level` its severity and the other fields
+// its attributes
+func writeLogRecord(r *protoWriter, e event) {
+	if e.timeUnixNano > 0 {
+		r.fixed64(1, e.timeUnixNano)
+	}
+
+	var body any
+	for _, kv := range e.fields {
+		switch kv.key {
+		case messageField:
+			body = kv.value
+		case levelField:
+			level := fmt.Sprint(kv.value)
+			if severity, ok := severities[strings.ToLower(level)]; ok {
+				r.int64(2, severity)
+			}
+
+			r.string(3, level)
+		}
+	}
+
+	if body != nil {
+		r.message(5, func(m *protoWriter) { writeAnyValue(m, body) })
+	}
+
+	for _, kv := range e.fields {
+		if kv.key == messageField || kv.key == levelField {
+			continue
+		}
+
+		r.message(6, func(m *protoWriter) { writeKeyValue(m, kv) })
+	}
+
+	if e.timeUnixNano > 0 {
+		r.fixed64(11, e.timeUnixNano)
+	}
+}
+
+// writeMetrics writes a gauge `Metric` for each numeric field of e, named after the field, with a single data point
+// whose attributes are the other fields of e that are not numbers
+func writeMetrics(scope *protoWriter, e event) {
+	var attributes []keyValue
+	for _, kv := range e.fields {
+		if _, ok := kv.value.(json.Number); !ok {
+			attributes = append(attributes, kv)
+		}
+	}
+
+	for _, kv := range e.fields {
+		n, ok := kv.value.(json.Number)
+		if !ok {
+			continue
+		}
+
+		scope.message(2, func(metric *protoWriter) {
+			metric.string(1, kv.key)
+			metric.message(5, func(gauge *protoWriter) {
+				gauge.message(1, func(point *protoWriter) {
+					if e.timeUnixNano > 0 {
+						point.fixed64(3, e.timeUnixNano)
+					}
+
+					if i, err := n.Int64(); err == nil {
+						point.fixed64(6, uint64(i))
+					} else {
+						f, _ := n.Float64()
+						point.double(4, f)
+					}
+
+					for _, attribute := range attributes {
+						point.message(7, func(m *protoWriter) { writeKeyValue(m, attribute) })
+					}
+				})
+			})
+		})
+	}
+}
+
+// writeKeyValue writes the `KeyValue` of kv
+func writeKeyValue(m *protoWriter, kv keyValue) {
+	m.string(1, kv.key)
+	m.message(2, func(v *protoWriter) { writeAnyValue(v, kv.value) })
+}
+
+// writeAnyValue writes the `AnyValue` of value, a value decoded from JSON
+func writeAnyValue(m *protoWriter, value any) {
+	switch v := value.(type) {
+	case string:
+		m.string(1, v)
+	case bool:
+		m.bool(2, v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			m.int64(3, i)
+		} else {
+			f, _ := v.Float64()
+			m.double(4, f)
+		}
+	case []any:
+		m.message(5, func(array *protoWriter) {
+			for _, item := range v {
+				array.message(1, func(a *protoWriter) { writeAnyValue(a, item) })
+			}
+		})
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		m.message(6, func(list *protoWriter) {
+			for _, key := range keys {
+				list.message(1, func(kv *protoWriter) { writeKeyValue(kv, keyValue{key: key, value: v[key]}) })
+			}
+		})
+	}
+}
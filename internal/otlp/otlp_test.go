@@ -0,0 +1,186 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package otlp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// protoMessage is a decoded protobuf message, the values of each field by field number: uint64 for the varint and
+// fixed64 ones, []byte for the length-delimited ones
+type protoMessage map[int][]any
+
+func decode(t *testing.T, b []byte) protoMessage {
+	m := make(protoMessage)
+	varint := func() uint64 {
+		v, n := binary.Uvarint(b)
+		require.Positive(t, n)
+		b = b[n:]
+		return v
+	}
+
+	for len(b) > 0 {
+		tag := varint()
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case wireVarint:
+			m[field] = append(m[field], varint())
+		case wireFixed64:
+			m[field] = append(m[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case wireBytes:
+			n := int(varint())
+			m[field] = append(m[field], b[:n])
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+
+	return m
+}
+
+// messages returns the embedded messages of the field
+func (m protoMessage) messages(t *testing.T, field int) []protoMessage {
+	var messages []protoMessage
+	for _, value := range m[field] {
+		messages = append(messages, decode(t, value.([]byte)))
+	}
+
+	return messages
+}
+
+func (m protoMessage) message(t *testing.T, field int) protoMessage {
+	messages := m.messages(t, field)
+	require.Len(t, messages, 1)
+	return messages[0]
+}
+
+func (m protoMessage) string(field int) string {
+	if len(m[field]) == 0 {
+		return ""
+	}
+
+	return string(m[field][0].([]byte))
+}
+
+// attributes returns the `KeyValue` of the field as a map, the values being their decoded `AnyValue`
+func (m protoMessage) attributes(t *testing.T, field int) map[string]protoMessage {
+	attributes := make(map[string]protoMessage)
+	for _, kv := range m.messages(t, field) {
+		attributes[kv.string(1)] = kv.message(t, 2)
+	}
+
+	return attributes
+}
+
+func TestRequest_logs(t *testing.T) {
+	events := [][]byte{
+		[]byte(`{"@timestamp":"2024-01-01T00:00:01Z","host":{"name":"h1"},"message":"started","log.level":"INFO","status":200}`),
+		[]byte(`{"@timestamp":1704067202000,"host.name":"h2","message":"failed","log":{"level":"error"},"tags":["a","b"]}`),
+		[]byte(`{"@timestamp":"2024-01-01T00:00:03Z","host":{"name":"h1"},"ratio":0.5,"ok":true}`),
+	}
+
+	request, err := Request(SignalLogs, events)
+	require.NoError(t, err)
+
+	resourceLogs := decode(t, request).messages(t, 1)
+	require.Len(t, resourceLogs, 2, "the events of the same host share the resource")
+
+	resource := resourceLogs[0].message(t, 1).attributes(t, 1)
+	assert.Equal(t, "h1", resource["host.name"].string(1))
+
+	scopeLogs := resourceLogs[0].message(t, 2)
+	assert.Equal(t, scopeName, scopeLogs.message(t, 1).string(1))
+
+	records := scopeLogs.messages(t, 2)
+	require.Len(t, records, 2)
+
+	first := records[0]
+	assert.Equal(t, []any{uint64(time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC).UnixNano())}, first[1])
+	assert.Equal(t, []any{uint64(9)}, first[2])
+	assert.Equal(t, "INFO", first.string(3))
+	assert.Equal(t, "started", first.message(t, 5).string(1))
+	attributes := first.attributes(t, 6)
+	assert.Len(t, attributes, 1)
+	assert.Equal(t, []any{uint64(200)}, attributes["status"][3])
+
+	third := records[1]
+	assert.Empty(t, third[5], "an event without message has no body")
+	attributes = third.attributes(t, 6)
+	assert.Equal(t, []any{uint64(1)}, attributes["ok"][2])
+	assert.Equal(t, []any{math.Float64bits(0.5)}, attributes["ratio"][4])
+
+	second := resourceLogs[1].message(t, 2).message(t, 2)
+	assert.Equal(t, []any{uint64(time.Date(2024, 1, 1, 0, 0, 2, 0, time.UTC).UnixNano())}, second[1])
+	assert.Equal(t, []any{uint64(17)}, second[2])
+	tags := second.attributes(t, 6)["tags"].message(t, 5).messages(t, 1)
+	require.Len(t, tags, 2)
+	assert.Equal(t, "b", tags[1].string(1))
+}
+
+func TestRequest_metrics(t *testing.T) {
+	events := [][]byte{
+		[]byte(`{"@timestamp":"2024-01-01T00:00:01Z","service":{"name":"api"},"http.route":"/users","requests":12,"latency":0.25}`),
+	}
+
+	request, err := Request(SignalMetrics, events)
+	require.NoError(t, err)
+
+	resourceMetrics := decode(t, request).message(t, 1)
+	assert.Equal(t, "api", resourceMetrics.message(t, 1).attributes(t, 1)["service.name"].string(1))
+
+	metrics := resourceMetrics.message(t, 2).messages(t, 2)
+	require.Len(t, metrics, 2, "a metric for each numeric field")
+
+	assert.Equal(t, "latency", metrics[0].string(1))
+	point := metrics[0].message(t, 5).message(t, 1)
+	assert.Equal(t, []any{math.Float64bits(0.25)}, point[4])
+	assert.Equal(t, []any{uint64(time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC).UnixNano())}, point[3])
+	assert.Equal(t, "/users", point.attributes(t, 7)["http.route"].string(1))
+
+	assert.Equal(t, "requests", metrics[1].string(1))
+	assert.Equal(t, []any{uint64(12)}, metrics[1].message(t, 5).message(t, 1)[6])
+}
+
+func TestRequest_notValid(t *testing.T) {
+	_, err := Request("traces", [][]byte{[]byte(`{}`)})
+	assert.ErrorIs(t, err, ErrNotValidSignal)
+
+	_, err = Request(SignalLogs, [][]byte{[]byte(`not json`)})
+	assert.ErrorContains(t, err, "cannot decode event as a JSON object")
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, SignalLogs, Options{BatchSize: 2})
+	require.NoError(t, err)
+
+	for _, event := range []string{`{"message":"a"}` + "\n", `{"message":"b"}` + "\n", "\n", `{"message":"c"}` + "\n"} {
+		n, err := w.Write([]byte(event))
+		require.NoError(t, err)
+		assert.Equal(t, len(event), n)
+	}
+
+	require.NoError(t, w.Close())
+
+	var records []int
+	b := buf.Bytes()
+	for len(b) > 0 {
+		size := binary.BigEndian.Uint32(b)
+		request := decode(t, b[4:4+size])
+		records = append(records, len(request.message(t, 1).message(t, 2).messages(t, 2)))
+		b = b[4+size:]
+	}
+
+	assert.Equal(t, []int{2, 1}, records)
+}
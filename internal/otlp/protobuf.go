@@ -0,0 +1,81 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package otlp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// wire types of the protobuf encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// protoWriter encodes the OTLP messages with the protobuf binary encoding
+type protoWriter struct {
+	buf bytes.Buffer
+}
+
+func (p *protoWriter) varint(v uint64) {
+	for v >= 0x80 {
+		p.buf.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+
+	p.buf.WriteByte(byte(v))
+}
+
+func (p *protoWriter) tag(field int, wireType int) {
+	p.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (p *protoWriter) int64(field int, v int64) {
+	p.tag(field, wireVarint)
+	p.varint(uint64(v))
+}
+
+func (p *protoWriter) bool(field int, v bool) {
+	p.tag(field, wireVarint)
+	if v {
+		p.buf.WriteByte(1)
+	} else {
+		p.buf.WriteByte(0)
+	}
+}
+
+func (p *protoWriter) fixed64(field int, v uint64) {
+	p.tag(field, wireFixed64)
+
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	p.buf.Write(b[:])
+}
+
+func (p *protoWriter) double(field int, v float64) {
+	p.fixed64(field, math.Float64bits(v))
+}
+
+func (p *protoWriter) bytes(field int, b []byte) {
+	p.tag(field, wireBytes)
+	p.varint(uint64(len(b)))
+	p.buf.Write(b)
+}
+
+func (p *protoWriter) string(field int, s string) {
+	p.tag(field, wireBytes)
+	p.varint(uint64(len(s)))
+	p.buf.WriteString(s)
+}
+
+// message writes the embedded message encoded by f as the field
+func (p *protoWriter) message(field int, f func(m *protoWriter)) {
+	var m protoWriter
+	f(&m)
+	p.bytes(field, m.buf.Bytes())
+}
@@ -0,0 +1,87 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package otlp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// DefaultBatchSize is the default number of events of each export request
+const DefaultBatchSize = 1000
+
+// Options holds the settings of the OTLP writer
+type Options struct {
+	// BatchSize is the number of events of each export request, kept in memory until written; DefaultBatchSize
+	// when not set
+	BatchSize int
+}
+
+// Writer is an io.WriteCloser writing each written event, a JSON object, to export requests of a signal, see
+// Request. Each request is written prefixed by its size as a 4 bytes big-endian integer, so that the file can be
+// split back into requests: Close writes the last one, without closing the underlying writer.
+type Writer struct {
+	w       io.Writer
+	signal  string
+	options Options
+	batch   [][]byte
+}
+
+// NewWriter returns a Writer writing to w the export requests of signal
+func NewWriter(w io.Writer, signal string, options Options) (*Writer, error) {
+	if err := ValidSignal(signal); err != nil {
+		return nil, err
+	}
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+
+	return &Writer{w: w, signal: signal, options: options}, nil
+}
+
+// Write adds the event p to the current request, writing the request when it's full
+func (ow *Writer) Write(p []byte) (int, error) {
+	event := bytes.TrimRight(p, "\n")
+	if len(event) == 0 {
+		return len(p), nil
+	}
+
+	ow.batch = append(ow.batch, append([]byte(nil), event...))
+	if len(ow.batch) >= ow.options.BatchSize {
+		if err := ow.flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close writes the last request
+func (ow *Writer) Close() error {
+	return ow.flush()
+}
+
+func (ow *Writer) flush() error {
+	if len(ow.batch) == 0 {
+		return nil
+	}
+
+	request, err := Request(ow.signal, ow.batch)
+	ow.batch = ow.batch[:0]
+	if err != nil {
+		return err
+	}
+
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(request)))
+	if _, err := ow.w.Write(size); err != nil {
+		return err
+	}
+
+	_, err = ow.w.Write(request)
+	return err
+}
@@ -0,0 +1,335 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+)
+
+// physical types of the Parquet columns
+const (
+	typeBoolean   = 0
+	typeInt32     = 1
+	typeInt64     = 2
+	typeFloat     = 4
+	typeDouble    = 5
+	typeByteArray = 6
+)
+
+// converted types of the Parquet columns, their logical types for the readers
+const (
+	convertedNone            = -1
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+	convertedUint64          = 14
+	convertedInt8            = 15
+	convertedInt16           = 16
+	convertedInt32           = 17
+	convertedInt64           = 18
+	convertedJSON            = 19
+)
+
+var (
+	errNotBoolean = errors.New("not a boolean")
+	errNotInteger = errors.New("not an integer")
+	errNotNumber  = errors.New("not a number")
+	errNotDate    = errors.New("not a date")
+)
+
+// column is an optional column of the flat schema of the file, holding the values of a field for the row group
+// being written
+type column struct {
+	name      string
+	fieldType string
+	typ       int32
+	converted int32
+
+	// defined tells, for each row, if the field has a value
+	defined []bool
+	// values are the values of the rows with one, PLAIN encoded but the booleans
+	values bytes.Buffer
+	bools  []bool
+	// lastOffset is the offset in values of the value of the last row
+	lastOffset int
+}
+
+// columnType returns the physical and the converted type of the column of a field of type fieldType: the objects,
+// and the types without a Parquet counterpart, are written as their JSON encoding
+func columnType(fieldType string) (int32, int32) {
+	switch fieldType {
+	case genlib.FieldTypeBool:
+		return typeBoolean, convertedNone
+	case genlib.FieldTypeByte:
+		return typeInt32, convertedInt8
+	case genlib.FieldTypeShort:
+		return typeInt32, convertedInt16
+	case genlib.FieldTypeInteger:
+		return typeInt32, convertedInt32
+	case genlib.FieldTypeLong:
+		return typeInt64, convertedInt64
+	case genlib.FieldTypeUnsignedLong:
+		return typeInt64, convertedUint64
+	case genlib.FieldTypeFloat, genlib.FieldTypeHalfFloat:
+		return typeFloat, convertedNone
+	case genlib.FieldTypeDouble, genlib.FieldTypeScaledFloat:
+		return typeDouble, convertedNone
+	case genlib.FieldTypeDate:
+		return typeInt64, convertedTimestampMillis
+	case genlib.FieldTypeObject, genlib.FieldTypeNested, genlib.FieldTypeFlattened, genlib.FieldTypeGeoPoint, genlib.FieldTypeHistogram:
+		return typeByteArray, convertedJSON
+	default:
+		return typeByteArray, convertedUTF8
+	}
+}
+
+// newColumns returns the columns of flds, one per field: the fields with a wildcard in the name are skipped, since
+// their names are only known when generated
+func newColumns(flds fields.Fields) []*column {
+	var columns []*column
+	seen := make(map[string]struct{})
+	for _, field := range flds {
+		if strings.Contains(field.Name, "*") {
+			continue
+		}
+
+		if _, ok := seen[field.Name]; ok {
+			continue
+		}
+
+		seen[field.Name] = struct{}{}
+
+		typ, converted := columnType(field.Type)
+		columns = append(columns, &column{name: field.Name, fieldType: field.Type, typ: typ, converted: converted})
+	}
+
+	return columns
+}
+
+// lookup returns the value of the dotted name in the event, either as a key with dots or within nested objects
+func lookup(event map[string]any, name string) (any, bool) {
+	if value, ok := event[name]; ok {
+		return value, true
+	}
+
+	for i := 0; i < len(name); i++ {
+		if name[i] != '.' {
+			continue
+		}
+
+		if object, ok := event[name[:i]].(map[string]any); ok {
+			if value, ok := lookup(object, name[i+1:]); ok {
+				return value, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// add adds the value of the field in a row, nil when the field has no value
+func (c *column) add(value any) error {
+	if value == nil {
+		c.defined = append(c.defined, false)
+		return nil
+	}
+
+	c.lastOffset = c.values.Len()
+
+	var err error
+	switch c.typ {
+	case typeBoolean:
+		var b bool
+		if b, err = toBool(value); err == nil {
+			c.bools = append(c.bools, b)
+		}
+	case typeInt32:
+		var n int64
+		if n, err = toInt64(value); err == nil {
+			err = binary.Write(&c.values, binary.LittleEndian, int32(n))
+		}
+	case typeInt64:
+		var n int64
+		switch c.converted {
+		case convertedTimestampMillis:
+			n, err = toMillis(value)
+		case convertedUint64:
+			var u uint64
+			u, err = strconv.ParseUint(fmt.Sprint(value), 10, 64)
+			n = int64(u)
+		default:
+			n, err = toInt64(value)
+		}
+
+		if err == nil {
+			err = binary.Write(&c.values, binary.LittleEndian, n)
+		}
+	case typeFloat:
+		var f float64
+		if f, err = toFloat64(value); err == nil {
+			err = binary.Write(&c.values, binary.LittleEndian, math.Float32bits(float32(f)))
+		}
+	case typeDouble:
+		var f float64
+		if f, err = toFloat64(value); err == nil {
+			err = binary.Write(&c.values, binary.LittleEndian, math.Float64bits(f))
+		}
+	default:
+		var s []byte
+		if c.converted == convertedJSON {
+			s, err = json.Marshal(value)
+		} else {
+			s, err = toBytes(value)
+		}
+
+		if err == nil {
+			err = binary.Write(&c.values, binary.LittleEndian, uint32(len(s)))
+			c.values.Write(s)
+		}
+	}
+
+	if err != nil {
+		c.values.Truncate(c.lastOffset)
+		return fmt.Errorf("field %s: cannot write %v as %s: %w", c.name, value, c.fieldType, err)
+	}
+
+	c.defined = append(c.defined, true)
+	return nil
+}
+
+// removeLast removes the value of the last row, when the other values of the event cannot be added
+func (c *column) removeLast() {
+	last := len(c.defined) - 1
+	if c.defined[last] {
+		if c.typ == typeBoolean {
+			c.bools = c.bools[:len(c.bools)-1]
+		} else {
+			c.values.Truncate(c.lastOffset)
+		}
+	}
+
+	c.defined = c.defined[:last]
+}
+
+// reset empties the column for the next row group
+func (c *column) reset() {
+	c.defined = c.defined[:0]
+	c.values.Reset()
+	c.bools = c.bools[:0]
+}
+
+// page returns the body of the data page of the values of the row group: the definition levels, bit-packed with
+// the RLE hybrid encoding, followed by the values
+func (c *column) page() []byte {
+	levels := packBits(c.defined)
+
+	var header bytes.Buffer
+	groups := uint64(len(levels))
+	for v := groups<<1 | 1; ; v >>= 7 {
+		if v < 0x80 {
+			header.WriteByte(byte(v))
+			break
+		}
+
+		header.WriteByte(byte(v) | 0x80)
+	}
+
+	var page bytes.Buffer
+	_ = binary.Write(&page, binary.LittleEndian, uint32(header.Len()+len(levels)))
+	page.Write(header.Bytes())
+	page.Write(levels)
+
+	if c.typ == typeBoolean {
+		page.Write(packBits(c.bools))
+	} else {
+		page.Write(c.values.Bytes())
+	}
+
+	return page.Bytes()
+}
+
+// packBits packs the bits least significant first, padding the last byte with zeros
+func packBits(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	return packed
+}
+
+func toBool(value any) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	default:
+		return false, errNotBoolean
+	}
+}
+
+func toInt64(value any) (int64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Int64()
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, errNotInteger
+	}
+}
+
+func toFloat64(value any) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		return 0, errNotNumber
+	}
+}
+
+// toMillis returns the milliseconds since the epoch of a date, either as RFC 3339 text or as milliseconds
+func toMillis(value any) (int64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Int64()
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return 0, err
+		}
+
+		return t.UnixNano() / int64(time.Millisecond), nil
+	default:
+		return 0, errNotDate
+	}
+}
+
+// toBytes returns the text of a value, or the JSON encoding of the values that are not text
+func toBytes(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case json.Number:
+		return []byte(v), nil
+	default:
+		return json.Marshal(v)
+	}
+}
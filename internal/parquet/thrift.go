@@ -0,0 +1,113 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package parquet
+
+import (
+	"bytes"
+)
+
+// types of the thrift compact protocol
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the metadata of the Parquet file with the thrift compact protocol
+type thriftWriter struct {
+	buf bytes.Buffer
+	// lastIDs are the ids of the last fields written of the structs being written, innermost last
+	lastIDs []int16
+}
+
+// newThriftWriter returns a thriftWriter writing the fields of a struct
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastIDs: []int16{0}}
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	for v >= 0x80 {
+		t.buf.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+
+	t.buf.WriteByte(byte(v))
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &t.lastIDs[len(t.lastIDs)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) string(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.stringElem(s)
+}
+
+// list writes the header of a list field of n elements of type elemType, to be followed by the elements
+func (t *thriftWriter) list(id int16, elemType byte, n int) {
+	t.fieldHeader(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elemType)
+		return
+	}
+
+	t.buf.WriteByte(0xf0 | elemType)
+	t.varint(uint64(n))
+}
+
+// structField writes the header of a struct field, to be followed by its fields and by structEnd
+func (t *thriftWriter) structField(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.structBegin()
+}
+
+// structBegin starts a struct element of a list, to be followed by its fields and by structEnd
+func (t *thriftWriter) structBegin() {
+	t.lastIDs = append(t.lastIDs, 0)
+}
+
+func (t *thriftWriter) structEnd() {
+	t.buf.WriteByte(0)
+	t.lastIDs = t.lastIDs[:len(t.lastIDs)-1]
+}
+
+func (t *thriftWriter) i32Elem(v int32) {
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) stringElem(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+// bytes ends the struct being written, returning its encoding
+func (t *thriftWriter) bytes() []byte {
+	t.structEnd()
+	return t.buf.Bytes()
+}
@@ -0,0 +1,246 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package parquet writes the generated events as a Parquet file, with a column for each field, so that the corpora
+// can be loaded directly into analytics tools.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+)
+
+const (
+	// DefaultRowGroupSize is the default number of events of each row group
+	DefaultRowGroupSize = 100000
+
+	magic     = "PAR1"
+	createdBy = "elastic-integration-corpus-generator-tool"
+
+	encodingPlain = 0
+	encodingRLE   = 3
+	pageTypeData  = 0
+	codecNone     = 0
+	repetitionOpt = 1
+)
+
+// Options holds the settings of the Parquet writer
+type Options struct {
+	// RowGroupSize is the number of events of each row group, kept in memory until written; DefaultRowGroupSize
+	// when not set
+	RowGroupSize int
+}
+
+// columnChunk is the metadata of the values of a column in a row group
+type columnChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+// rowGroup is the metadata of a row group written
+type rowGroup struct {
+	columns []columnChunk
+	size    int64
+	rows    int64
+}
+
+// Writer is an io.WriteCloser writing each written event, a JSON object, as a row of a Parquet file with a column
+// for each field. The events are written in row groups: Close writes the last one and the metadata of the file,
+// without closing the underlying writer.
+type Writer struct {
+	w       io.Writer
+	options Options
+	columns []*column
+
+	offset    int64
+	rows      int
+	rowGroups []rowGroup
+}
+
+// NewWriter returns a Writer writing to w a Parquet file with the columns of flds
+func NewWriter(w io.Writer, flds fields.Fields, options Options) (*Writer, error) {
+	if options.RowGroupSize <= 0 {
+		options.RowGroupSize = DefaultRowGroupSize
+	}
+
+	pw := &Writer{
+		w:       w,
+		options: options,
+		columns: newColumns(flds),
+	}
+
+	if err := pw.write([]byte(magic)); err != nil {
+		return nil, err
+	}
+
+	return pw, nil
+}
+
+// Write adds the event p as a row, writing the row group when it's full
+func (pw *Writer) Write(p []byte) (int, error) {
+	event := bytes.TrimRight(p, "\n")
+	if len(event) == 0 {
+		return len(p), nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+
+	var row map[string]any
+	if err := decoder.Decode(&row); err != nil {
+		return 0, fmt.Errorf("cannot decode event as a JSON object: %w", err)
+	}
+
+	for i, c := range pw.columns {
+		value, _ := lookup(row, c.name)
+		if err := c.add(value); err != nil {
+			for _, added := range pw.columns[:i] {
+				added.removeLast()
+			}
+
+			return 0, err
+		}
+	}
+
+	pw.rows++
+	if pw.rows >= pw.options.RowGroupSize {
+		if err := pw.flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close writes the last row group and the metadata of the file
+func (pw *Writer) Close() error {
+	if pw.rows > 0 {
+		if err := pw.flush(); err != nil {
+			return err
+		}
+	}
+
+	footer := pw.footer()
+	if err := pw.write(footer); err != nil {
+		return err
+	}
+
+	length := make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(len(footer)))
+	if err := pw.write(length); err != nil {
+		return err
+	}
+
+	return pw.write([]byte(magic))
+}
+
+func (pw *Writer) write(p []byte) error {
+	n, err := pw.w.Write(p)
+	pw.offset += int64(n)
+	return err
+}
+
+// flush writes the rows kept in memory as a row group, with a data page for each column
+func (pw *Writer) flush() error {
+	group := rowGroup{rows: int64(pw.rows)}
+	for _, c := range pw.columns {
+		page := c.page()
+
+		t := newThriftWriter()
+		t.i32(1, pageTypeData)
+		t.i32(2, int32(len(page)))
+		t.i32(3, int32(len(page)))
+		t.structField(5)
+		t.i32(1, int32(pw.rows))
+		t.i32(2, encodingPlain)
+		t.i32(3, encodingRLE)
+		t.i32(4, encodingRLE)
+		t.structEnd()
+		header := t.bytes()
+
+		chunk := columnChunk{offset: pw.offset, size: int64(len(header) + len(page)), values: int64(pw.rows)}
+		if err := pw.write(header); err != nil {
+			return err
+		}
+
+		if err := pw.write(page); err != nil {
+			return err
+		}
+
+		group.columns = append(group.columns, chunk)
+		group.size += chunk.size
+		c.reset()
+	}
+
+	pw.rowGroups = append(pw.rowGroups, group)
+	pw.rows = 0
+	return nil
+}
+
+// footer returns the metadata of the file: the schema and the row groups
+func (pw *Writer) footer() []byte {
+	var rows int64
+	for _, group := range pw.rowGroups {
+		rows += group.rows
+	}
+
+	t := newThriftWriter()
+	t.i32(1, 1)
+
+	t.list(2, thriftStruct, len(pw.columns)+1)
+	t.structBegin()
+	t.string(4, "schema")
+	t.i32(5, int32(len(pw.columns)))
+	t.structEnd()
+	for _, c := range pw.columns {
+		t.structBegin()
+		t.i32(1, c.typ)
+		t.i32(3, repetitionOpt)
+		t.string(4, c.name)
+		if c.converted != convertedNone {
+			t.i32(6, c.converted)
+		}
+		t.structEnd()
+	}
+
+	t.i64(3, rows)
+
+	t.list(4, thriftStruct, len(pw.rowGroups))
+	for _, group := range pw.rowGroups {
+		t.structBegin()
+		t.list(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			c := pw.columns[i]
+
+			t.structBegin()
+			t.i64(2, chunk.offset)
+			t.structField(3)
+			t.i32(1, c.typ)
+			t.list(2, thriftI32, 2)
+			t.i32Elem(encodingPlain)
+			t.i32Elem(encodingRLE)
+			t.list(3, thriftBinary, 1)
+			t.stringElem(c.name)
+			t.i32(4, codecNone)
+			t.i64(5, chunk.values)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.i64(2, group.size)
+		t.i64(3, group.rows)
+		t.structEnd()
+	}
+
+	t.string(6, createdBy)
+	return t.bytes()
+}
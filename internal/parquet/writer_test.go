@@ -0,0 +1,197 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// thriftReader decodes the thrift compact protocol, the structs as maps by field id
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) varint() uint64 {
+	var v uint64
+	for shift := 0; ; shift += 7 {
+		b := r.b[r.pos]
+		r.pos++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v
+		}
+	}
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.varint())
+		r.pos += n
+		return string(r.b[r.pos-n : r.pos])
+	case thriftList:
+		header := r.b[r.pos]
+		r.pos++
+		n := int(header >> 4)
+		if n == 15 {
+			n = int(r.varint())
+		}
+
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+
+		return list
+	case thriftStruct:
+		return r.structure()
+	default:
+		panic("unexpected thrift type")
+	}
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	m := make(map[int16]any)
+	var id int16
+	for {
+		header := r.b[r.pos]
+		r.pos++
+		if header == 0 {
+			return m
+		}
+
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+
+		m[id] = r.value(header & 0x0f)
+	}
+}
+
+// readColumn returns the values of the column of the row group of the file, nil for the rows without one
+func readColumn(t *testing.T, file []byte, group map[int16]any, column int) []any {
+	meta := group[1].([]any)[column].(map[int16]any)[3].(map[int16]any)
+
+	r := &thriftReader{b: file, pos: int(meta[9].(int64))}
+	header := r.structure()
+	rows := int(header[5].(map[int16]any)[1].(int64))
+
+	levelsLen := int(binary.LittleEndian.Uint32(file[r.pos:]))
+	levels := file[r.pos+4+1 : r.pos+4+levelsLen]
+	require.Len(t, levels, (rows+7)/8)
+	values := file[r.pos+4+levelsLen : r.pos+int(header[2].(int64))]
+
+	var read []any
+	var n int
+	for i := 0; i < rows; i++ {
+		if levels[i/8]&(1<<(i%8)) == 0 {
+			read = append(read, nil)
+			continue
+		}
+
+		switch meta[1].(int64) {
+		case typeBoolean:
+			read = append(read, values[n/8]&(1<<(n%8)) != 0)
+		case typeInt64:
+			read = append(read, int64(binary.LittleEndian.Uint64(values)))
+			values = values[8:]
+		case typeByteArray:
+			length := int(binary.LittleEndian.Uint32(values))
+			read = append(read, string(values[4:4+length]))
+			values = values[4+length:]
+		default:
+			t.Fatalf("unexpected column type %d", meta[1])
+		}
+
+		n++
+	}
+
+	return read
+}
+
+func TestWriter(t *testing.T) {
+	flds := fields.Fields{
+		{Name: "host.name", Type: "keyword"},
+		{Name: "event.duration", Type: "long"},
+		{Name: "@timestamp", Type: "date"},
+		{Name: "error", Type: "boolean"},
+		{Name: "labels.*", Type: "keyword"},
+		{Name: "location", Type: "geo_point"},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, flds, Options{RowGroupSize: 2})
+	require.NoError(t, err)
+
+	events := []string{
+		`{"host":{"name":"a"},"event.duration":1,"@timestamp":"2023-01-02T03:04:05.678Z","error":true,"location":"1.5,2.5"}`,
+		`{"host.name":"b","event":{"duration":2},"error":false}`,
+		`{"event.duration":3,"@timestamp":"1970-01-01T00:00:01Z","location":{"lat":1,"lon":2}}`,
+	}
+
+	for _, event := range events {
+		_, err := w.Write([]byte(event + "\n"))
+		require.NoError(t, err)
+	}
+
+	_, err = w.Write([]byte(`{"host.name":"c","event.duration":"long"}`))
+	assert.Error(t, err)
+
+	require.NoError(t, w.Close())
+
+	file := buf.Bytes()
+	assert.Equal(t, magic, string(file[:4]))
+	assert.Equal(t, magic, string(file[len(file)-4:]))
+
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	r := &thriftReader{b: file[len(file)-8-footerLen : len(file)-8]}
+	footer := r.structure()
+
+	assert.Equal(t, int64(3), footer[3])
+
+	schema := footer[2].([]any)
+	require.Len(t, schema, 6)
+	assert.Equal(t, int64(5), schema[0].(map[int16]any)[5])
+	for i, name := range []string{"host.name", "event.duration", "@timestamp", "error", "location"} {
+		assert.Equal(t, name, schema[i+1].(map[int16]any)[4])
+	}
+
+	assert.Equal(t, int64(convertedTimestampMillis), schema[3].(map[int16]any)[6])
+	assert.Equal(t, int64(convertedJSON), schema[5].(map[int16]any)[6])
+
+	groups := footer[4].([]any)
+	require.Len(t, groups, 2)
+
+	first, second := groups[0].(map[int16]any), groups[1].(map[int16]any)
+	assert.Equal(t, int64(2), first[3])
+	assert.Equal(t, int64(1), second[3])
+
+	assert.Equal(t, []any{"a", "b"}, readColumn(t, file, first, 0))
+	assert.Equal(t, []any{int64(1), int64(2)}, readColumn(t, file, first, 1))
+	assert.Equal(t, []any{int64(1672628645678), nil}, readColumn(t, file, first, 2))
+	assert.Equal(t, []any{true, false}, readColumn(t, file, first, 3))
+	assert.Equal(t, []any{`"1.5,2.5"`, nil}, readColumn(t, file, first, 4))
+
+	assert.Equal(t, []any{nil}, readColumn(t, file, second, 0))
+	assert.Equal(t, []any{int64(3)}, readColumn(t, file, second, 1))
+	assert.Equal(t, []any{int64(1000)}, readColumn(t, file, second, 2))
+	assert.Equal(t, []any{`{"lat":1,"lon":2}`}, readColumn(t, file, second, 4))
+}
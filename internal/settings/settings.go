@@ -36,6 +36,10 @@ func setDefaults() {
 	viper.SetDefault("corpora_location", path.Join(
 		os.ExpandEnv(viper.GetString("corpora_root")),
 		viper.GetString("corpora_path")))
+	// package_cache_location keeps the packages downloaded from the package registry
+	viper.SetDefault("package_cache_location", path.Join(viper.GetString("cache_dir"), "elastic-integration-corpus-generator-tool", "packages"))
+	// corpora_index records the corpora generated on the machine, for the `corpus` commands
+	viper.SetDefault("corpora_index", path.Join(os.ExpandEnv(viper.GetString("corpora_root")), "corpora.json"))
 }
 
 func setConstants() {
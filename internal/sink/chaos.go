@@ -0,0 +1,99 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrChaosDrop and ErrChaosPartialWrite are the failures injected by the chaos options
+	ErrChaosDrop         = errors.New("chaos: connection dropped")
+	ErrChaosPartialWrite = errors.New("chaos: partial write")
+)
+
+// chaos injects faults into the connections of a sink, to test the resilience of the inputs and the retries of the
+// agents alongside the load: `chaos_drop_interval`, the time after which each connection is dropped on its next
+// write, `chaos_latency`, the wait before each write, and `chaos_partial_write_every`, the number of writes after
+// which one is cut in half before the connection is dropped
+type chaos struct {
+	dropInterval      time.Duration
+	latency           time.Duration
+	partialWriteEvery uint64
+
+	writes uint64
+}
+
+// newChaos returns the chaos set with the options of the sink of name, or nil when none is set
+func newChaos(name string, options map[string]string) (*chaos, error) {
+	c := &chaos{}
+	for key, value := range map[string]*time.Duration{"chaos_drop_interval": &c.dropInterval, "chaos_latency": &c.latency} {
+		if len(options[key]) == 0 {
+			continue
+		}
+
+		d, err := time.ParseDuration(options[key])
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("the %s `%s` must be a positive duration, like '10s', got '%s'", name, key, options[key])
+		}
+
+		*value = d
+	}
+
+	if value := options["chaos_partial_write_every"]; len(value) > 0 {
+		every, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || every == 0 {
+			return nil, fmt.Errorf("the %s `chaos_partial_write_every` must be a positive number of writes, got '%s'", name, value)
+		}
+
+		c.partialWriteEvery = every
+	}
+
+	if c.dropInterval == 0 && c.latency == 0 && c.partialWriteEvery == 0 {
+		return nil, nil
+	}
+
+	return c, nil
+}
+
+// wrap returns conn with the faults of c injected into its writes, or conn itself when c is nil
+func (c *chaos) wrap(conn net.Conn) net.Conn {
+	if c == nil {
+		return conn
+	}
+
+	return &chaosConn{Conn: conn, chaos: c, opened: time.Now()}
+}
+
+// chaosConn is a connection with the faults of a chaos injected into its writes
+type chaosConn struct {
+	net.Conn
+	chaos  *chaos
+	opened time.Time
+}
+
+func (c *chaosConn) Write(b []byte) (int, error) {
+	if c.chaos.latency > 0 {
+		time.Sleep(c.chaos.latency)
+	}
+
+	if c.chaos.dropInterval > 0 && time.Since(c.opened) >= c.chaos.dropInterval {
+		_ = c.Conn.Close()
+		return 0, ErrChaosDrop
+	}
+
+	if every := c.chaos.partialWriteEvery; every > 0 && atomic.AddUint64(&c.chaos.writes, 1)%every == 0 {
+		n, _ := c.Conn.Write(b[:len(b)/2])
+		_ = c.Conn.Close()
+		return n, ErrChaosPartialWrite
+	}
+
+	return c.Conn.Write(b)
+}
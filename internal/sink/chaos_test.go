@@ -0,0 +1,96 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChaos(t *testing.T) {
+	c, err := newChaos("syslog", map[string]string{"address": "localhost:514"})
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c, err = newChaos("syslog", map[string]string{"chaos_drop_interval": "10s", "chaos_latency": "5ms", "chaos_partial_write_every": "100"})
+	require.NoError(t, err)
+	assert.Equal(t, &chaos{dropInterval: 10 * time.Second, latency: 5 * time.Millisecond, partialWriteEvery: 100}, c)
+
+	_, err = newChaos("syslog", map[string]string{"chaos_latency": "-1s"})
+	assert.EqualError(t, err, "the syslog `chaos_latency` must be a positive duration, like '10s', got '-1s'")
+
+	_, err = newChaos("kafka", map[string]string{"chaos_partial_write_every": "0"})
+	assert.EqualError(t, err, "the kafka `chaos_partial_write_every` must be a positive number of writes, got '0'")
+}
+
+func TestChaos_wrap(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			b, _ := io.ReadAll(conn)
+			received <- string(b)
+		}
+	}()
+
+	c := &chaos{partialWriteEvery: 2}
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	conn = c.wrap(conn)
+
+	// the second write is cut in half, and the connection dropped
+	_, err = conn.Write([]byte("abcd"))
+	require.NoError(t, err)
+	n, err := conn.Write([]byte("efgh"))
+	assert.ErrorIs(t, err, ErrChaosPartialWrite)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "abcdef", <-received)
+
+	c = &chaos{dropInterval: 10 * time.Millisecond}
+	conn, err = net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	conn = c.wrap(conn)
+
+	_, err = conn.Write([]byte("abcd"))
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	_, err = conn.Write([]byte("efgh"))
+	assert.ErrorIs(t, err, ErrChaosDrop)
+	assert.Equal(t, "abcd", <-received)
+
+	assert.Same(t, conn, (*chaos)(nil).wrap(conn))
+}
+
+func TestChaos_client(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	s, err := sinks.New(OTLPSinkName, map[string]string{"endpoint": server.URL, "chaos_latency": "50ms"})
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte(`{"message":"a"}`)}))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	_, err = sinks.New(OTLPSinkName, map[string]string{"endpoint": server.URL, "chaos_drop_interval": "soon"})
+	assert.ErrorContains(t, err, "the otlp `chaos_drop_interval` must be a positive duration, like '10s', got 'soon'")
+}
@@ -0,0 +1,558 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package sink writes the generated events to the systems they are meant for, instead of a corpus file.
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+)
+
+const (
+	// DefaultBatchSize is the default number of events sent in each bulk request
+	DefaultBatchSize = 1000
+	// DefaultConcurrency is the default number of bulk requests sent in parallel
+	DefaultConcurrency = 1
+	// DefaultMaxRetries is the default number of times a bulk request, or its events, rejected with a 429 status
+	// are retried
+	DefaultMaxRetries = 5
+	// DefaultBackoff is the default wait before the first retry, doubled at each following one
+	DefaultBackoff = 500 * time.Millisecond
+	// maxBackoff caps the wait between the retries
+	maxBackoff = 30 * time.Second
+	// DefaultVerifyField is the default date field of the time buckets of the verification
+	DefaultVerifyField = "@timestamp"
+)
+
+var ErrNotValidURL = errors.New("the Elasticsearch URL must be an http or https URL")
+
+// ElasticsearchOptions holds the settings of the Elasticsearch sink
+type ElasticsearchOptions struct {
+	// URL is the base URL of Elasticsearch, like `https://localhost:9200`
+	URL string
+	// DataStream is the data stream, or the index, the events are created in. When set each event is a document;
+	// when not set each event must already be bulk action and document lines, like the corpora of `generate`.
+	DataStream string
+	// BatchSize is the number of events sent in each bulk request; DefaultBatchSize when not set
+	BatchSize int
+	// Concurrency is the number of bulk requests sent in parallel; DefaultConcurrency when not set
+	Concurrency int
+	// MaxRetries is the number of retries of the requests, or of their events, rejected with a 429 status;
+	// DefaultMaxRetries when not set, none when negative
+	MaxRetries int
+	// Backoff is the wait before the first retry, doubled at each following one; DefaultBackoff when not set
+	Backoff time.Duration
+	// Client sends the bulk requests, with the auth of the cluster; http.DefaultClient when not set
+	Client *http.Client
+	// VerifyInterval is the size of the time buckets the events indexed are counted in, for Verify to compare them
+	// with the count of the documents of each bucket; none are counted when not set
+	VerifyInterval time.Duration
+	// VerifyField is the date field of the events the time buckets are of; DefaultVerifyField when not set
+	VerifyField string
+}
+
+// Elasticsearch is a sink sending the events to Elasticsearch with the `_bulk` API: each batch is split in bulk
+// requests of BatchSize events, Concurrency of them sent in parallel, so that a Writer drives it with batches of
+// BatchSize times Concurrency events.
+type Elasticsearch struct {
+	options ElasticsearchOptions
+	baseURL string
+	bulkURL string
+
+	// sent are the events indexed by time bucket, when VerifyInterval is set, and unbucketed the ones without a
+	// date in VerifyField
+	mu         sync.Mutex
+	sent       map[bucketKey]uint64
+	unbucketed uint64
+
+	indexed uint64
+	retries uint64
+	batches uint64
+	bytes   uint64
+}
+
+// NewElasticsearch returns an Elasticsearch sink with the options
+func NewElasticsearch(options ElasticsearchOptions) (*Elasticsearch, error) {
+	u, err := url.Parse(options.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, ErrNotValidURL
+	}
+
+	baseURL := strings.TrimSuffix(u.String(), "/")
+	if len(options.DataStream) > 0 {
+		u.Path = path.Join(u.Path, url.PathEscape(options.DataStream))
+	}
+
+	u.Path = path.Join(u.Path, "_bulk")
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+
+	if options.Concurrency <= 0 {
+		options.Concurrency = DefaultConcurrency
+	}
+
+	if options.MaxRetries == 0 {
+		options.MaxRetries = DefaultMaxRetries
+	}
+
+	if options.Backoff <= 0 {
+		options.Backoff = DefaultBackoff
+	}
+
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+
+	if len(options.VerifyField) == 0 {
+		options.VerifyField = DefaultVerifyField
+	}
+
+	return &Elasticsearch{
+		options: options,
+		baseURL: baseURL,
+		bulkURL: u.String(),
+		sent:    make(map[bucketKey]uint64),
+	}, nil
+}
+
+func (e *Elasticsearch) Open(context.Context) error {
+	return nil
+}
+
+// WriteBatch sends the events of the batch in bulk requests of BatchSize events, Concurrency of them in parallel.
+// When some requests fail, the events not indexed are returned in a *sinks.PartialError.
+func (e *Elasticsearch) WriteBatch(ctx context.Context, events [][]byte) error {
+	items, err := e.bulkItems(events)
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu          sync.Mutex
+		firstErr    error
+		undelivered [][]byte
+		wg          sync.WaitGroup
+	)
+
+	requests := make(chan [][]byte)
+	for i := 0; i < e.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for request := range requests {
+				rejected, err := e.send(ctx, request)
+				if err == nil {
+					continue
+				}
+
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				undelivered = append(undelivered, rejected...)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for start := 0; start < len(items); start += e.options.BatchSize {
+		end := start + e.options.BatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		requests <- items[start:end]
+	}
+
+	close(requests)
+	wg.Wait()
+
+	if firstErr != nil {
+		return &sinks.PartialError{Undelivered: undelivered, Err: firstErr}
+	}
+
+	atomic.AddUint64(&e.batches, 1)
+	return nil
+}
+
+// Flush returns at once, the events are indexed when WriteBatch returns
+func (e *Elasticsearch) Flush(context.Context) error {
+	return nil
+}
+
+func (e *Elasticsearch) Close() error {
+	return nil
+}
+
+// Stats returns the statistics of the sink, the events being the ones indexed
+func (e *Elasticsearch) Stats() sinks.Stats {
+	return sinks.Stats{
+		Events:  atomic.LoadUint64(&e.indexed),
+		Bytes:   atomic.LoadUint64(&e.bytes),
+		Batches: atomic.LoadUint64(&e.batches),
+		Retries: atomic.LoadUint64(&e.retries),
+	}
+}
+
+// BatchSize returns the number of events sent in each bulk request
+func (e *Elasticsearch) BatchSize() int {
+	return e.options.BatchSize
+}
+
+// Concurrency returns the number of bulk requests sent in parallel
+func (e *Elasticsearch) Concurrency() int {
+	return e.options.Concurrency
+}
+
+// bulkItems returns the items of the bulk requests of the events. Without a data stream the events are already bulk
+// action and document lines, like the corpora of `generate`: they are paired again, an action with its document,
+// so that the events split by line, like the ones spilled by a Writer, are sent as they were generated.
+func (e *Elasticsearch) bulkItems(events [][]byte) ([][]byte, error) {
+	if len(e.options.DataStream) > 0 {
+		return events, nil
+	}
+
+	var lines [][]byte
+	for _, event := range events {
+		lines = append(lines, bytes.Split(event, []byte("\n"))...)
+	}
+
+	if len(lines)%2 != 0 {
+		return nil, fmt.Errorf("bulk events must be pairs of action and document lines, got %d lines", len(lines))
+	}
+
+	items := make([][]byte, 0, len(lines)/2)
+	for i := 0; i < len(lines); i += 2 {
+		items = append(items, bytes.Join(lines[i:i+2], []byte("\n")))
+	}
+
+	return items, nil
+}
+
+// bulkResponse is the part of the response of the `_bulk` API telling which events failed
+type bulkResponse struct {
+	Items []map[string]bulkResponseItem `json:"items"`
+}
+
+type bulkResponseItem struct {
+	Status int `json:"status"`
+	Error  struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// send sends the events of batch with a bulk request, retrying the request, or only its events, rejected with
+// a 429 status, with exponential backoff. On failure it returns the events not indexed.
+func (e *Elasticsearch) send(ctx context.Context, batch [][]byte) ([][]byte, error) {
+	backoff := e.options.Backoff
+	for retry := 0; ; retry++ {
+		status, response, err := e.post(ctx, batch)
+		if err != nil {
+			return batch, err
+		}
+
+		var rejected [][]byte
+		switch {
+		case status == http.StatusTooManyRequests:
+			rejected = batch
+		case status != http.StatusOK:
+			return batch, fmt.Errorf("bulk request failed with status %d: %s", status, response)
+		default:
+			var resp bulkResponse
+			if err := json.Unmarshal(response, &resp); err != nil {
+				return batch, fmt.Errorf("cannot decode bulk response: %w", err)
+			}
+
+			if len(resp.Items) != len(batch) {
+				return batch, fmt.Errorf("bulk response has %d items for %d events", len(resp.Items), len(batch))
+			}
+
+			var failed error
+			for i, item := range resp.Items {
+				for _, result := range item {
+					switch {
+					case result.Status == http.StatusTooManyRequests:
+						rejected = append(rejected, batch[i])
+					case result.Status >= 300:
+						rejected = append(rejected, batch[i])
+						if failed == nil {
+							failed = fmt.Errorf("cannot index event: status %d: %s: %s", result.Status, result.Error.Type, result.Error.Reason)
+						}
+					default:
+						atomic.AddUint64(&e.indexed, 1)
+						atomic.AddUint64(&e.bytes, uint64(len(batch[i])))
+						if e.options.VerifyInterval > 0 {
+							e.countSent(batch[i])
+						}
+					}
+				}
+			}
+
+			if failed != nil {
+				return rejected, failed
+			}
+		}
+
+		if len(rejected) == 0 {
+			return nil, nil
+		}
+
+		if retry >= e.options.MaxRetries {
+			return rejected, fmt.Errorf("%d events still rejected with status 429 after %d retries", len(rejected), retry)
+		}
+
+		atomic.AddUint64(&e.retries, uint64(len(rejected)))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return rejected, ctx.Err()
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+
+		batch = rejected
+	}
+}
+
+// post sends the bulk request of the events of batch, returning the status and the body of the response
+func (e *Elasticsearch) post(ctx context.Context, batch [][]byte) (int, []byte, error) {
+	var body bytes.Buffer
+	for _, event := range batch {
+		if len(e.options.DataStream) > 0 {
+			body.WriteString(`{"create":{}}` + "\n")
+		}
+
+		body.Write(event)
+		body.WriteByte('\n')
+	}
+
+	return e.request(ctx, e.bulkURL, "application/x-ndjson", &body)
+}
+
+// request posts body to u, returning the status and the body of the response
+func (e *Elasticsearch) request(ctx context.Context, u, contentType string, body io.Reader) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := e.options.Client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer resp.Body.Close()
+
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, bytes.TrimSpace(response), nil
+}
+
+// bucketKey is a time bucket of the events of an index, starting at start, in milliseconds since the epoch
+type bucketKey struct {
+	index string
+	start int64
+}
+
+// BucketCount is the number of the events of an index in a time bucket sent and indexed, and of the documents
+// counted in the bucket after the generation
+type BucketCount struct {
+	Index   string
+	Start   time.Time
+	End     time.Time
+	Indexed uint64
+	Counted uint64
+}
+
+// Verification is the comparison of the events indexed with the documents counted by time bucket
+type Verification struct {
+	// Buckets are the time buckets of the events indexed, sorted by index and time
+	Buckets []BucketCount
+	// Unbucketed is the number of the events indexed without a date in VerifyField, or without an index
+	Unbucketed uint64
+}
+
+// Mismatches returns the buckets whose documents counted differ from the events indexed
+func (v Verification) Mismatches() []BucketCount {
+	var mismatches []BucketCount
+	for _, bucket := range v.Buckets {
+		if bucket.Counted != bucket.Indexed {
+			mismatches = append(mismatches, bucket)
+		}
+	}
+
+	return mismatches
+}
+
+// countSent counts the event indexed in its time bucket
+func (e *Elasticsearch) countSent(event []byte) {
+	index, document := e.options.DataStream, event
+	if len(index) == 0 {
+		action, rest, _ := bytes.Cut(event, []byte("\n"))
+		index, document = bulkIndex(action), rest
+	}
+
+	key, ok := e.bucketOf(index, document)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !ok {
+		e.unbucketed++
+		return
+	}
+
+	e.sent[key]++
+}
+
+// bucketOf returns the time bucket of the document of index, if it has a date in VerifyField
+func (e *Elasticsearch) bucketOf(index string, document []byte) (bucketKey, bool) {
+	value, ok := eventKey(document, e.options.VerifyField)
+	if !ok || len(index) == 0 {
+		return bucketKey{}, false
+	}
+
+	timestamp, ok := parseTimestamp(value)
+	if !ok {
+		return bucketKey{}, false
+	}
+
+	return bucketKey{index: index, start: timestamp.Truncate(e.options.VerifyInterval).UnixMilli()}, true
+}
+
+// bulkIndex returns the `_index` of the bulk action, or an empty string when not set
+func bulkIndex(action []byte) string {
+	var actions map[string]struct {
+		Index string `json:"_index"`
+	}
+
+	if err := json.Unmarshal(action, &actions); err != nil {
+		return ""
+	}
+
+	for _, a := range actions {
+		return a.Index
+	}
+
+	return ""
+}
+
+// parseTimestamp returns the time of a date value, either in RFC 3339 or in milliseconds since the epoch
+func parseTimestamp(value []byte) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, string(value)); err == nil {
+		return t, true
+	}
+
+	if millis, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+		return time.UnixMilli(millis), true
+	}
+
+	return time.Time{}, false
+}
+
+// Verify refreshes the indices the events have been indexed into, and counts the documents of each time bucket of
+// VerifyField with the `_count` API, to compare them with the events indexed: fewer documents tell that events
+// have been silently dropped, like by an ingest pipeline, and more that the index already had documents.
+func (e *Elasticsearch) Verify(ctx context.Context) (Verification, error) {
+	e.mu.Lock()
+	v := Verification{Unbucketed: e.unbucketed}
+	refreshed := make(map[string]bool)
+	for key, indexed := range e.sent {
+		start := time.UnixMilli(key.start).UTC()
+		v.Buckets = append(v.Buckets, BucketCount{Index: key.index, Start: start, End: start.Add(e.options.VerifyInterval), Indexed: indexed})
+	}
+	e.mu.Unlock()
+
+	sort.Slice(v.Buckets, func(i, j int) bool {
+		if v.Buckets[i].Index != v.Buckets[j].Index {
+			return v.Buckets[i].Index < v.Buckets[j].Index
+		}
+
+		return v.Buckets[i].Start.Before(v.Buckets[j].Start)
+	})
+
+	for i, bucket := range v.Buckets {
+		indexURL := e.baseURL + "/" + url.PathEscape(bucket.Index)
+		if !refreshed[bucket.Index] {
+			status, response, err := e.request(ctx, indexURL+"/_refresh", "application/json", nil)
+			if err != nil {
+				return v, err
+			}
+
+			if status != http.StatusOK {
+				return v, fmt.Errorf("refresh of %s failed with status %d: %s", bucket.Index, status, response)
+			}
+
+			refreshed[bucket.Index] = true
+		}
+
+		query, err := json.Marshal(map[string]any{
+			"query": map[string]any{
+				"range": map[string]any{
+					e.options.VerifyField: map[string]any{
+						"gte":    bucket.Start.UnixMilli(),
+						"lt":     bucket.End.UnixMilli(),
+						"format": "epoch_millis",
+					},
+				},
+			},
+		})
+		if err != nil {
+			return v, err
+		}
+
+		status, response, err := e.request(ctx, indexURL+"/_count", "application/json", bytes.NewReader(query))
+		if err != nil {
+			return v, err
+		}
+
+		if status != http.StatusOK {
+			return v, fmt.Errorf("count of %s failed with status %d: %s", bucket.Index, status, response)
+		}
+
+		var count struct {
+			Count uint64 `json:"count"`
+		}
+
+		if err := json.Unmarshal(response, &count); err != nil {
+			return v, fmt.Errorf("cannot decode count response: %w", err)
+		}
+
+		v.Buckets[i].Counted = count.Count
+	}
+
+	return v, nil
+}
+
+// String describes the target of the sink, for the messages to the user
+func (e *Elasticsearch) String() string {
+	return strings.TrimSuffix(e.bulkURL, "/_bulk")
+}
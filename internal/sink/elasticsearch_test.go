@@ -0,0 +1,270 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bulkServer is a fake `_bulk` API rejecting with a 429 status the first requests, and the events listed in reject
+// the first time they are sent
+type bulkServer struct {
+	mu       sync.Mutex
+	paths    []string
+	events   []string
+	throttle int
+	reject   map[string]bool
+	fail     string
+}
+
+func (s *bulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.paths = append(s.paths, r.URL.Path)
+	if s.throttle > 0 {
+		s.throttle--
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	var items []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		action := scanner.Text()
+		if !scanner.Scan() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		event := action + "\n" + scanner.Text()
+		switch {
+		case s.reject[event]:
+			delete(s.reject, event)
+			items = append(items, `{"create":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}`)
+		case event == s.fail:
+			items = append(items, `{"create":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}`)
+		default:
+			s.events = append(s.events, event)
+			items = append(items, `{"create":{"status":201}}`)
+		}
+	}
+
+	fmt.Fprintf(w, `{"errors":false,"items":[%s]}`, strings.Join(items, ","))
+}
+
+func TestElasticsearch(t *testing.T) {
+	server := &bulkServer{
+		throttle: 1,
+		reject:   map[string]bool{"{\"create\":{}}\n{\"n\":3}": true},
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	es, err := NewElasticsearch(ElasticsearchOptions{URL: ts.URL + "/", DataStream: "logs-test-default", BatchSize: 2, Concurrency: 2, Backoff: time.Millisecond})
+	require.NoError(t, err)
+
+	var events [][]byte
+	for i := 0; i < 5; i++ {
+		events = append(events, []byte(fmt.Sprintf("{\"n\":%d}", i)))
+	}
+
+	require.NoError(t, es.WriteBatch(context.Background(), events[:4]))
+	require.NoError(t, es.WriteBatch(context.Background(), events[4:]))
+
+	stats := es.Stats()
+	assert.Equal(t, uint64(5), stats.Events)
+	assert.Equal(t, uint64(3), stats.Retries)
+	assert.Equal(t, uint64(2), stats.Batches)
+	assert.Len(t, server.events, 5)
+	assert.Contains(t, server.events, "{\"create\":{}}\n{\"n\":3}")
+	for _, path := range server.paths {
+		assert.Equal(t, "/logs-test-default/_bulk", path)
+	}
+}
+
+func TestElasticsearch_Bulk(t *testing.T) {
+	server := &bulkServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	es, err := NewElasticsearch(ElasticsearchOptions{URL: ts.URL})
+	require.NoError(t, err)
+
+	event := "{ \"create\" : { \"_index\": \"logs-nginx.access-default\" } }\n{\"message\":\"GET /\"}"
+	require.NoError(t, es.WriteBatch(context.Background(), [][]byte{[]byte(event)}))
+
+	// the lines of the events spilled are paired again
+	lines := strings.Split(event, "\n")
+	require.NoError(t, es.WriteBatch(context.Background(), [][]byte{[]byte(lines[0]), []byte(lines[1])}))
+
+	assert.Equal(t, []string{"/_bulk", "/_bulk"}, server.paths)
+	assert.Equal(t, []string{event, event}, server.events)
+	assert.Equal(t, uint64(2), es.Stats().Events)
+
+	err = es.WriteBatch(context.Background(), [][]byte{[]byte(lines[0])})
+	assert.ErrorContains(t, err, "bulk events must be pairs of action and document lines, got 1 lines")
+}
+
+func TestElasticsearch_Errors(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		server   *bulkServer
+		expected string
+	}{
+		{
+			scenario: "event not indexed",
+			server:   &bulkServer{fail: "{\"create\":{}}\n{\"n\":1}"},
+			expected: "mapper_parsing_exception",
+		},
+		{
+			scenario: "retries exhausted",
+			server:   &bulkServer{throttle: 10},
+			expected: "still rejected with status 429 after 2 retries",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			ts := httptest.NewServer(testCase.server)
+			defer ts.Close()
+
+			es, err := NewElasticsearch(ElasticsearchOptions{URL: ts.URL, DataStream: "test", BatchSize: 1, MaxRetries: 2, Backoff: time.Millisecond})
+			require.NoError(t, err)
+
+			err = es.WriteBatch(context.Background(), [][]byte{[]byte(`{"n":0}`), []byte(`{"n":1}`)})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.expected)
+		})
+	}
+}
+
+func TestNewElasticsearch_URL(t *testing.T) {
+	for _, u := range []string{"", "localhost:9200", "ftp://localhost", "http://"} {
+		_, err := NewElasticsearch(ElasticsearchOptions{URL: u})
+		assert.ErrorIs(t, err, ErrNotValidURL, u)
+	}
+}
+
+func TestElasticsearch_PartialFailure(t *testing.T) {
+	server := &bulkServer{fail: "{\"create\":{}}\n{\"n\":2}"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	es, err := NewElasticsearch(ElasticsearchOptions{URL: ts.URL, DataStream: "test", BatchSize: 2, Concurrency: 2})
+	require.NoError(t, err)
+
+	err = es.WriteBatch(context.Background(), [][]byte{[]byte(`{"n":0}`), []byte(`{"n":1}`), []byte(`{"n":2}`), []byte(`{"n":3}`)})
+
+	// only the event not indexed is returned, the others are not sent twice
+	var partial *sinks.PartialError
+	require.ErrorAs(t, err, &partial)
+	assert.Equal(t, [][]byte{[]byte(`{"n":2}`)}, partial.Undelivered)
+	assert.Equal(t, uint64(3), es.Stats().Events)
+}
+
+func TestElasticsearch_Deadline(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	es, err := NewElasticsearch(ElasticsearchOptions{URL: ts.URL, DataStream: "test"})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	w, err := sinks.NewWriter(context.Background(), es, 2, sinks.WithBatchTimeout(50*time.Millisecond), sinks.WithSpillDir(dir))
+	require.NoError(t, err)
+
+	for _, event := range []string{`{"n":0}`, `{"n":1}`} {
+		_, err = w.Write([]byte(event + "\n"))
+		require.NoError(t, err)
+	}
+
+	// the batch not indexed in time is spilled, and left in the spill directory when still not indexed at the end
+	assert.ErrorIs(t, w.Close(), sinks.ErrUndelivered)
+	assert.Equal(t, sinks.SpillStats{Batches: 1, Events: 2}, w.SpillStats())
+
+	spilled, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, spilled, 1)
+}
+
+func TestElasticsearch_Verify(t *testing.T) {
+	server := &bulkServer{}
+	var (
+		mu        sync.Mutex
+		refreshed []string
+		queries   []string
+	)
+
+	// the ingest pipeline of the fake drops an event of the bucket of 00:01
+	counts := map[string]string{"1704067200000": `{"count":2}`, "1704067260000": `{"count":0}`}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_refresh"):
+			refreshed = append(refreshed, r.URL.Path)
+			fmt.Fprint(w, `{}`)
+		case strings.HasSuffix(r.URL.Path, "/_count"):
+			var query struct {
+				Query struct {
+					Range map[string]struct {
+						Gte json.Number `json:"gte"`
+					} `json:"range"`
+				} `json:"query"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&query))
+			queries = append(queries, r.URL.Path+" "+query.Query.Range["@timestamp"].Gte.String())
+			fmt.Fprint(w, counts[query.Query.Range["@timestamp"].Gte.String()])
+		default:
+			server.ServeHTTP(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	es, err := NewElasticsearch(ElasticsearchOptions{URL: ts.URL, VerifyInterval: time.Minute})
+	require.NoError(t, err)
+
+	action := `{"create":{"_index":"logs-test-default"}}` + "\n"
+	require.NoError(t, es.WriteBatch(context.Background(), [][]byte{
+		[]byte(action + `{"@timestamp":"2024-01-01T00:00:01.000Z"}`),
+		[]byte(action + `{"@timestamp":"2024-01-01T00:00:59.999Z"}`),
+		[]byte(action + `{"@timestamp":"2024-01-01T00:01:00Z"}`),
+		[]byte(action + `{"message":"no date"}`),
+	}))
+
+	v, err := es.Verify(context.Background())
+	require.NoError(t, err)
+
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Minute)
+	assert.Equal(t, []BucketCount{
+		{Index: "logs-test-default", Start: first, End: second, Indexed: 2, Counted: 2},
+		{Index: "logs-test-default", Start: second, End: second.Add(time.Minute), Indexed: 1, Counted: 0},
+	}, v.Buckets)
+	assert.Equal(t, uint64(1), v.Unbucketed)
+	assert.Equal(t, v.Buckets[1:], v.Mismatches())
+	assert.Equal(t, []string{"/logs-test-default/_refresh"}, refreshed)
+	assert.Equal(t, []string{"/logs-test-default/_count 1704067200000", "/logs-test-default/_count 1704067260000"}, queries)
+}
@@ -0,0 +1,690 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+)
+
+const (
+	// KafkaSinkName is the name the Kafka sink is registered with
+	KafkaSinkName = "kafka"
+
+	// KafkaCompressionNone and KafkaCompressionGzip are the compressions of the record batches
+	KafkaCompressionNone = "none"
+	KafkaCompressionGzip = "gzip"
+
+	// KafkaSASLPlain is the SASL mechanism of the authentication with a username and a password
+	KafkaSASLPlain = "PLAIN"
+
+	// DefaultKafkaClientID is the default client id of the requests
+	DefaultKafkaClientID = "corpus-generator"
+	// kafkaDialTimeout caps the time to connect to a broker
+	kafkaDialTimeout = 30 * time.Second
+	// kafkaProduceTimeout is the time the brokers wait for the acknowledgements of the replicas with `acks=all`
+	kafkaProduceTimeout = 30 * time.Second
+	// kafkaMaxResponseSize caps the size of the responses, to not allocate a broken one
+	kafkaMaxResponseSize = 64 << 20
+)
+
+var ErrNotValidKafkaBrokers = errors.New("the kafka sink requires the `brokers` option, a comma-separated list of 'host:port'")
+var ErrNotValidKafkaTopic = errors.New("the kafka sink requires the `topic` option")
+
+// kafkaAcks are the acknowledgements required from the brokers by name
+var kafkaAcks = map[string]int16{"all": -1, "-1": -1, "1": 1, "0": 0}
+
+func init() {
+	sinks.Register(KafkaSinkName, func(options map[string]string) (sinks.Sink, error) {
+		return NewKafka(options)
+	})
+}
+
+// Kafka is a sink producing each event as a record of a Kafka topic, like the one consumed by the kafka input of
+// Elastic Agent, with the records of each batch sent to the leaders of their partitions
+type Kafka struct {
+	brokers        []string
+	topic          string
+	partitionField string
+	// hotPartition, when not negative, receives hotRatio of the records whatever their key, to simulate the skew of
+	// the partitions
+	hotPartition int32
+	hotRatio     float64
+	compress     bool
+	acks         int16
+	clientID     string
+	username     string
+	password     string
+	tls          *tls.Config
+	// chaos injects faults into the connections the records are produced with, nil for none
+	chaos *chaos
+	// now returns the timestamp of the records
+	now func() time.Time
+
+	// leaders are the ids of the leaders of the partitions of the topic, by partition, and addresses the addresses
+	// of the brokers by id
+	leaders   []int32
+	addresses map[int32]string
+	// stale is set when a broker is no longer the leader of a partition, to refresh the leaders before the next batch
+	stale bool
+	conns map[int32]*kafkaConn
+	// sticky is the partition of the records without a key of the next batch
+	sticky int32
+	// hotCredit accumulates hotRatio for each record, a record going to the hot partition each time it reaches 1
+	hotCredit float64
+	stats     sinks.Stats
+}
+
+// NewKafka returns a Kafka sink configured with options: `brokers`, the comma-separated `host:port` of the
+// bootstrap brokers, `topic`, `partition_field`, the dotted name of the field whose value is the key of the records,
+// `hot_partition` and `hot_partition_ratio`, the partition receiving the ratio of the records whatever their key,
+// `compression`, either `none`, the default, or `gzip`, `acks`, one of `all`, the default, `1` or `0`,
+// `client_id`, DefaultKafkaClientID by default, `sasl_mechanism`, `PLAIN` when `sasl_username` is set,
+// `sasl_username` and `sasl_password`, `tls`, `true` to connect with TLS, the TLS options `tls_ca`, `tls_cert`,
+// `tls_key`, `tls_insecure_skip_verify` and `tls_min_version`, and the chaos options of the produce connections
+func NewKafka(options map[string]string) (*Kafka, error) {
+	var brokers []string
+	for _, broker := range strings.Split(options["brokers"], ",") {
+		broker = strings.TrimSpace(broker)
+		if len(broker) == 0 {
+			continue
+		}
+
+		if _, _, err := net.SplitHostPort(broker); err != nil {
+			return nil, ErrNotValidKafkaBrokers
+		}
+
+		brokers = append(brokers, broker)
+	}
+
+	if len(brokers) == 0 {
+		return nil, ErrNotValidKafkaBrokers
+	}
+
+	if len(options["topic"]) == 0 {
+		return nil, ErrNotValidKafkaTopic
+	}
+
+	k := &Kafka{
+		brokers:        brokers,
+		topic:          options["topic"],
+		partitionField: options["partition_field"],
+		hotPartition:   -1,
+		clientID:       optionOrDefault(options, "client_id", DefaultKafkaClientID),
+		username:       options["sasl_username"],
+		password:       options["sasl_password"],
+		now:            time.Now,
+	}
+
+	var err error
+	if k.chaos, err = newChaos("kafka", options); err != nil {
+		return nil, err
+	}
+
+	if len(options["hot_partition"]) > 0 {
+		hotPartition, err := strconv.ParseInt(options["hot_partition"], 10, 32)
+		if err != nil || hotPartition < 0 {
+			return nil, fmt.Errorf("the kafka `hot_partition` must be a partition number, got '%s'", options["hot_partition"])
+		}
+
+		hotRatio, err := strconv.ParseFloat(optionOrDefault(options, "hot_partition_ratio", "0"), 64)
+		if err != nil || hotRatio <= 0 || hotRatio > 1 {
+			return nil, fmt.Errorf("the kafka `hot_partition_ratio` must be greater than 0 and up to 1, got '%s'", options["hot_partition_ratio"])
+		}
+
+		k.hotPartition = int32(hotPartition)
+		k.hotRatio = hotRatio
+	} else if len(options["hot_partition_ratio"]) > 0 {
+		return nil, errors.New("the kafka `hot_partition_ratio` requires the `hot_partition` option")
+	}
+
+	switch compression := optionOrDefault(options, "compression", KafkaCompressionNone); compression {
+	case KafkaCompressionNone:
+	case KafkaCompressionGzip:
+		k.compress = true
+	default:
+		return nil, fmt.Errorf("the kafka `compression` must be one of '%s' or '%s', got '%s'", KafkaCompressionNone, KafkaCompressionGzip, compression)
+	}
+
+	acks, ok := kafkaAcks[optionOrDefault(options, "acks", "all")]
+	if !ok {
+		return nil, fmt.Errorf("the kafka `acks` must be one of 'all', '1' or '0', got '%s'", options["acks"])
+	}
+
+	k.acks = acks
+
+	mechanism := options["sasl_mechanism"]
+	if len(mechanism) > 0 && !strings.EqualFold(mechanism, KafkaSASLPlain) {
+		return nil, fmt.Errorf("the kafka `sasl_mechanism` must be '%s', got '%s'", KafkaSASLPlain, mechanism)
+	}
+
+	if len(mechanism) > 0 && len(k.username) == 0 {
+		return nil, errors.New("the kafka `sasl_mechanism` requires the `sasl_username` option")
+	}
+
+	useTLS, err := boolOption("kafka", "tls", options)
+	if err != nil {
+		return nil, err
+	}
+
+	if useTLS {
+		tlsOptions, err := sinkTLSOptions("kafka", options)
+		if err != nil {
+			return nil, err
+		}
+
+		if k.tls, err = tlsOptions.Config(); err != nil {
+			return nil, err
+		}
+	}
+
+	return k, nil
+}
+
+// Open gets the leaders of the partitions of the topic from the first bootstrap broker available
+func (k *Kafka) Open(ctx context.Context) error {
+	k.conns = make(map[int32]*kafkaConn)
+	if err := k.refreshMetadata(ctx); err != nil {
+		return err
+	}
+
+	if int(k.hotPartition) >= len(k.leaders) {
+		return fmt.Errorf("the kafka `hot_partition` %d is not a partition of topic %s, with %d partitions", k.hotPartition, k.topic, len(k.leaders))
+	}
+
+	return nil
+}
+
+// refreshMetadata gets the leaders of the partitions of the topic, and the addresses of the brokers
+func (k *Kafka) refreshMetadata(ctx context.Context) error {
+	var errs []string
+	for _, broker := range k.brokers {
+		err := k.metadata(ctx, broker)
+		if err == nil {
+			k.stale = false
+			return nil
+		}
+
+		errs = append(errs, err.Error())
+	}
+
+	return fmt.Errorf("cannot get the metadata of kafka topic %s: %s", k.topic, strings.Join(errs, "; "))
+}
+
+func (k *Kafka) metadata(ctx context.Context, broker string) error {
+	conn, err := k.dial(ctx, broker)
+	if err != nil {
+		return err
+	}
+
+	defer conn.close()
+
+	var body kafkaEncoder
+	body.int32(1)
+	body.string(k.topic)
+
+	d, err := conn.roundTrip(ctx, kafkaAPIMetadata, kafkaMetadataVersion, body.buf.Bytes(), true)
+	if err != nil {
+		return err
+	}
+
+	addresses := make(map[int32]string)
+	for i := d.arrayLen(); i > 0; i-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string()
+		addresses[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+
+	d.int32()
+
+	var leaders []int32
+	for i := d.arrayLen(); i > 0; i-- {
+		code := d.int16()
+		name := d.string()
+		d.int8()
+
+		partitions := d.arrayLen()
+		topicLeaders := make([]int32, partitions)
+		for j := 0; j < partitions; j++ {
+			partitionCode := d.int16()
+			partition := d.int32()
+			leader := d.int32()
+			for n := d.arrayLen(); n > 0; n-- {
+				d.int32()
+			}
+
+			for n := d.arrayLen(); n > 0; n-- {
+				d.int32()
+			}
+
+			if partitionCode != 0 && partitionCode != 9 {
+				// a replica not available doesn't prevent producing to the leader
+				leader = -1
+			}
+
+			if partition >= 0 && int(partition) < partitions {
+				topicLeaders[partition] = leader
+			}
+		}
+
+		if name != k.topic {
+			continue
+		}
+
+		if code != 0 {
+			return kafkaError(code)
+		}
+
+		leaders = topicLeaders
+	}
+
+	if d.err != nil {
+		return d.err
+	}
+
+	if len(leaders) == 0 {
+		return errors.New("the topic has no partitions")
+	}
+
+	k.leaders = leaders
+	k.addresses = addresses
+	return nil
+}
+
+// dial connects to the broker, authenticating with SASL when a username is set
+func (k *Kafka) dial(ctx context.Context, address string) (*kafkaConn, error) {
+	dialer := &net.Dialer{Timeout: kafkaDialTimeout}
+
+	var conn net.Conn
+	var err error
+	if k.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: k.tls}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to kafka broker %s: %w", address, err)
+	}
+
+	c := &kafkaConn{conn: conn, clientID: k.clientID}
+	if len(k.username) > 0 {
+		if err := c.authenticate(ctx, k.username, k.password); err != nil {
+			c.close()
+			return nil, fmt.Errorf("kafka broker %s: %w", address, err)
+		}
+	}
+
+	return c, nil
+}
+
+// WriteBatch produces a record for each event: the records with a key go to the partition of the hash of the key,
+// like with the default partitioner of the Kafka producers, and the ones without to the same partition, a different
+// one for each batch, unless they are moved to the hot partition. The records of each leader are produced in a request: when some of them fail, the events of
+// the partitions not produced are returned in a *sinks.PartialError, so that the ones produced are not sent twice.
+func (k *Kafka) WriteBatch(ctx context.Context, events [][]byte) error {
+	if k.stale {
+		if err := k.refreshMetadata(ctx); err != nil {
+			return err
+		}
+	}
+
+	sticky := k.sticky % int32(len(k.leaders))
+	k.sticky++
+
+	records := make(map[int32][]kafkaRecord)
+	partitionOf := make([]int32, len(events))
+	for i, event := range events {
+		record := kafkaRecord{value: event}
+		partition := sticky
+		if len(k.partitionField) > 0 {
+			if key, ok := eventKey(event, k.partitionField); ok {
+				record.key = key
+				partition = kafkaPartition(key, len(k.leaders))
+			}
+		}
+
+		if k.hotPartition >= 0 {
+			k.hotCredit += k.hotRatio
+			if k.hotCredit >= 1 {
+				k.hotCredit--
+				partition = k.hotPartition
+			}
+		}
+
+		records[partition] = append(records[partition], record)
+		partitionOf[i] = partition
+	}
+
+	partitionsByLeader := make(map[int32][]int32)
+	var leaders []int32
+	for partition := range records {
+		leader := k.leaders[partition]
+		if leader < 0 {
+			k.stale = true
+			return fmt.Errorf("partition %d of kafka topic %s has no leader", partition, k.topic)
+		}
+
+		if _, ok := partitionsByLeader[leader]; !ok {
+			leaders = append(leaders, leader)
+		}
+
+		partitionsByLeader[leader] = append(partitionsByLeader[leader], partition)
+	}
+
+	sort.Slice(leaders, func(i, j int) bool { return leaders[i] < leaders[j] })
+
+	var produceErr error
+	failed := make(map[int32]bool)
+	for _, leader := range leaders {
+		partitions := partitionsByLeader[leader]
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+		failedPartitions, err := k.produce(ctx, leader, partitions, records)
+		if err == nil {
+			continue
+		}
+
+		if produceErr == nil {
+			produceErr = err
+		}
+
+		for _, partition := range failedPartitions {
+			failed[partition] = true
+		}
+	}
+
+	var undelivered [][]byte
+	for i, event := range events {
+		if failed[partitionOf[i]] {
+			undelivered = append(undelivered, event)
+			continue
+		}
+
+		k.stats.Events++
+		k.stats.Bytes += uint64(len(event))
+	}
+
+	if produceErr != nil {
+		return &sinks.PartialError{Undelivered: undelivered, Err: produceErr}
+	}
+
+	k.stats.Batches++
+	return nil
+}
+
+// produce sends the records of the partitions to their leader in a produce request, returning the partitions
+// not produced on failure
+func (k *Kafka) produce(ctx context.Context, leader int32, partitions []int32, records map[int32][]kafkaRecord) ([]int32, error) {
+	conn, ok := k.conns[leader]
+	if !ok {
+		address, ok := k.addresses[leader]
+		if !ok {
+			k.stale = true
+			return partitions, fmt.Errorf("unknown kafka broker %d", leader)
+		}
+
+		var err error
+		if conn, err = k.dial(ctx, address); err != nil {
+			return partitions, err
+		}
+
+		conn.conn = k.chaos.wrap(conn.conn)
+		k.conns[leader] = conn
+	}
+
+	now := k.now()
+
+	var body kafkaEncoder
+	body.nullableString("")
+	body.int16(k.acks)
+	body.int32(int32(kafkaProduceTimeout / time.Millisecond))
+	body.int32(1)
+	body.string(k.topic)
+	body.int32(int32(len(partitions)))
+	for _, partition := range partitions {
+		batch, err := encodeRecordBatch(records[partition], now, k.compress)
+		if err != nil {
+			return partitions, err
+		}
+
+		body.int32(partition)
+		body.bytes(batch)
+	}
+
+	d, err := conn.roundTrip(ctx, kafkaAPIProduce, kafkaProduceVersion, body.buf.Bytes(), k.acks != 0)
+	if err != nil {
+		// the connection is in an unknown state: the next batch connects again
+		conn.close()
+		delete(k.conns, leader)
+		return partitions, err
+	}
+
+	if k.acks == 0 {
+		return nil, nil
+	}
+
+	var produceErr error
+	var failed []int32
+	for i := d.arrayLen(); i > 0; i-- {
+		d.string()
+		for j := d.arrayLen(); j > 0; j-- {
+			partition := d.int32()
+			code := d.int16()
+			d.int64()
+			d.int64()
+
+			if code == 0 {
+				continue
+			}
+
+			if code == 5 || code == 6 {
+				k.stale = true
+			}
+
+			failed = append(failed, partition)
+			if produceErr == nil {
+				produceErr = fmt.Errorf("cannot produce to partition %d of kafka topic %s: %w", partition, k.topic, kafkaError(code))
+			}
+		}
+	}
+
+	d.int32()
+	if d.err != nil {
+		return partitions, d.err
+	}
+
+	return failed, produceErr
+}
+
+// eventKey returns the value of the dotted field name of the event, a JSON object, as the key of its record:
+// strings as they are, the other values as JSON
+func eventKey(event []byte, name string) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(event))
+	dec.UseNumber()
+
+	var object map[string]any
+	if err := dec.Decode(&object); err != nil {
+		return nil, false
+	}
+
+	value, ok := lookupKey(object, name)
+	if !ok || value == nil {
+		return nil, false
+	}
+
+	if s, ok := value.(string); ok {
+		return []byte(s), true
+	}
+
+	key, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+
+	return key, true
+}
+
+// lookupKey returns the value of the dotted name in the event, either as a key with dots or within nested objects
+func lookupKey(event map[string]any, name string) (any, bool) {
+	if value, ok := event[name]; ok {
+		return value, true
+	}
+
+	for i := 0; i < len(name); i++ {
+		if name[i] != '.' {
+			continue
+		}
+
+		if object, ok := event[name[:i]].(map[string]any); ok {
+			if value, ok := lookupKey(object, name[i+1:]); ok {
+				return value, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Flush returns at once: each batch is delivered by WriteBatch
+func (k *Kafka) Flush(context.Context) error {
+	return nil
+}
+
+// Close closes the connections to the brokers
+func (k *Kafka) Close() error {
+	var err error
+	for leader, conn := range k.conns {
+		if closeErr := conn.close(); err == nil {
+			err = closeErr
+		}
+
+		delete(k.conns, leader)
+	}
+
+	return err
+}
+
+func (k *Kafka) Stats() sinks.Stats {
+	return k.stats
+}
+
+// kafkaConn is a connection to a broker, sending a request at a time
+type kafkaConn struct {
+	conn          net.Conn
+	clientID      string
+	correlationID int32
+}
+
+// roundTrip sends the request of the API, and reads its response when response is true, returning a decoder of
+// the response body
+func (c *kafkaConn) roundTrip(ctx context.Context, apiKey, apiVersion int16, body []byte, response bool) (*kafkaDecoder, error) {
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	c.correlationID++
+
+	var header kafkaEncoder
+	header.int16(apiKey)
+	header.int16(apiVersion)
+	header.int32(c.correlationID)
+	header.nullableString(c.clientID)
+
+	var request kafkaEncoder
+	request.int32(int32(header.buf.Len() + len(body)))
+	request.buf.Write(header.buf.Bytes())
+	request.buf.Write(body)
+
+	if _, err := c.conn.Write(request.buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	if !response {
+		return nil, nil
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.conn, size[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(size[:])
+	if n > kafkaMaxResponseSize {
+		return nil, fmt.Errorf("kafka response too large: %d bytes", n)
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c.conn, b); err != nil {
+		return nil, err
+	}
+
+	d := &kafkaDecoder{b: b}
+	if correlationID := d.int32(); correlationID != c.correlationID {
+		return nil, fmt.Errorf("unexpected kafka response %d to request %d", correlationID, c.correlationID)
+	}
+
+	return d, nil
+}
+
+// authenticate authenticates with the PLAIN SASL mechanism
+func (c *kafkaConn) authenticate(ctx context.Context, username, password string) error {
+	var body kafkaEncoder
+	body.string(KafkaSASLPlain)
+
+	d, err := c.roundTrip(ctx, kafkaAPISaslHandshake, kafkaSaslHandshakeVersion, body.buf.Bytes(), true)
+	if err != nil {
+		return err
+	}
+
+	if code := d.int16(); code != 0 {
+		var mechanisms []string
+		for i := d.arrayLen(); i > 0; i-- {
+			mechanisms = append(mechanisms, d.string())
+		}
+
+		return fmt.Errorf("SASL mechanism %s not enabled, only %s: %w", KafkaSASLPlain, strings.Join(mechanisms, ", "), kafkaError(code))
+	}
+
+	body = kafkaEncoder{}
+	body.bytes([]byte("\x00" + username + "\x00" + password))
+
+	d, err = c.roundTrip(ctx, kafkaAPISaslAuthenticate, kafkaSaslAuthenticateVersion, body.buf.Bytes(), true)
+	if err != nil {
+		return err
+	}
+
+	code := d.int16()
+	message := d.string()
+	if d.err != nil {
+		return d.err
+	}
+
+	if code != 0 {
+		return fmt.Errorf("SASL authentication failed: %s: %w", message, kafkaError(code))
+	}
+
+	return nil
+}
+
+func (c *kafkaConn) close() error {
+	return c.conn.Close()
+}
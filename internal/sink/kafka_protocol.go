@@ -0,0 +1,303 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+// the keys and the versions of the Kafka APIs used by the producer, see https://kafka.apache.org/protocol
+const (
+	kafkaAPIProduce          = 0
+	kafkaAPIMetadata         = 3
+	kafkaAPISaslHandshake    = 17
+	kafkaAPISaslAuthenticate = 36
+
+	kafkaProduceVersion          = 3
+	kafkaMetadataVersion         = 1
+	kafkaSaslHandshakeVersion    = 1
+	kafkaSaslAuthenticateVersion = 0
+
+	// kafkaRecordBatchMagic is the version of the record batch format
+	kafkaRecordBatchMagic = 2
+	// kafkaCompressionGzip is the compression codec of the attributes of a record batch
+	kafkaCompressionGzip = 1
+)
+
+var errKafkaShortResponse = errors.New("kafka response too short")
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaErrors are the names of the common error codes of the Kafka responses
+var kafkaErrors = map[int16]string{
+	1:  "OFFSET_OUT_OF_RANGE",
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	33: "UNSUPPORTED_SASL_MECHANISM",
+	34: "ILLEGAL_SASL_STATE",
+	35: "UNSUPPORTED_VERSION",
+	58: "SASL_AUTHENTICATION_FAILED",
+	76: "UNSUPPORTED_COMPRESSION_TYPE",
+	87: "INVALID_RECORD",
+}
+
+// kafkaError returns the error of a non-zero error code of a Kafka response
+func kafkaError(code int16) error {
+	if name, ok := kafkaErrors[code]; ok {
+		return fmt.Errorf("kafka error %d: %s", code, name)
+	}
+
+	return fmt.Errorf("kafka error %d", code)
+}
+
+// kafkaEncoder encodes the requests of the Kafka protocol, big-endian
+type kafkaEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *kafkaEncoder) int8(v int8) {
+	e.buf.WriteByte(byte(v))
+}
+
+func (e *kafkaEncoder) int16(v int16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(v))
+	e.buf.Write(b[:])
+}
+
+func (e *kafkaEncoder) int32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	e.buf.Write(b[:])
+}
+
+func (e *kafkaEncoder) int64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	e.buf.Write(b[:])
+}
+
+// varint writes v as a zigzag varint, like the lengths of the records
+func (e *kafkaEncoder) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutVarint(b[:], v)])
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf.WriteString(s)
+}
+
+// nullableString writes s, or null when empty
+func (e *kafkaEncoder) nullableString(s string) {
+	if len(s) == 0 {
+		e.int16(-1)
+		return
+	}
+
+	e.string(s)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf.Write(b)
+}
+
+// kafkaDecoder decodes the responses of the Kafka protocol: after the first value out of the response, the
+// following ones are zero and err is errKafkaShortResponse
+type kafkaDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil || n < 0 || len(d.b) < n {
+		d.err = errKafkaShortResponse
+		if n < 0 || n > 8 {
+			return nil
+		}
+
+		// zeros for the fixed-size values
+		return make([]byte, n)
+	}
+
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	return int8(d.next(1)[0])
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	return int16(binary.BigEndian.Uint16(d.next(2)))
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	return int32(binary.BigEndian.Uint32(d.next(4)))
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	return int64(binary.BigEndian.Uint64(d.next(8)))
+}
+
+func (d *kafkaDecoder) string() string {
+	n := int(d.int16())
+	if n < 0 {
+		return ""
+	}
+
+	return string(d.next(n))
+}
+
+func (d *kafkaDecoder) bytes() []byte {
+	n := int(d.int32())
+	if n < 0 {
+		return nil
+	}
+
+	return d.next(n)
+}
+
+// arrayLen returns the length of an array, zero when null
+func (d *kafkaDecoder) arrayLen() int {
+	n := int(d.int32())
+	if n < 0 || d.err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// kafkaRecord is a record produced to a partition
+type kafkaRecord struct {
+	// key is nil for the records without a key
+	key   []byte
+	value []byte
+}
+
+// encodeRecordBatch returns the record batch, version 2, of the records, all with the timestamp now, with the
+// records compressed with gzip when compress is true
+func encodeRecordBatch(records []kafkaRecord, now time.Time, compress bool) ([]byte, error) {
+	var recs kafkaEncoder
+	for i, record := range records {
+		var rec kafkaEncoder
+		rec.int8(0)
+		rec.varint(0)
+		rec.varint(int64(i))
+		if record.key == nil {
+			rec.varint(-1)
+		} else {
+			rec.varint(int64(len(record.key)))
+			rec.buf.Write(record.key)
+		}
+
+		rec.varint(int64(len(record.value)))
+		rec.buf.Write(record.value)
+		rec.varint(0)
+
+		recs.varint(int64(rec.buf.Len()))
+		recs.buf.Write(rec.buf.Bytes())
+	}
+
+	var attributes int16
+	payload := recs.buf.Bytes()
+	if compress {
+		var compressed bytes.Buffer
+		gw := gzip.NewWriter(&compressed)
+		if _, err := gw.Write(payload); err != nil {
+			return nil, err
+		}
+
+		if err := gw.Close(); err != nil {
+			return nil, err
+		}
+
+		attributes = kafkaCompressionGzip
+		payload = compressed.Bytes()
+	}
+
+	// the part of the batch covered by the CRC, from the attributes to the end
+	var crcPart kafkaEncoder
+	crcPart.int16(attributes)
+	crcPart.int32(int32(len(records) - 1))
+	crcPart.int64(now.UnixMilli())
+	crcPart.int64(now.UnixMilli())
+	crcPart.int64(-1)
+	crcPart.int16(-1)
+	crcPart.int32(-1)
+	crcPart.int32(int32(len(records)))
+	crcPart.buf.Write(payload)
+
+	var batch kafkaEncoder
+	batch.int64(0)
+	// the length of the batch after this field: partition leader epoch, magic, CRC and the rest
+	batch.int32(int32(4 + 1 + 4 + crcPart.buf.Len()))
+	batch.int32(-1)
+	batch.int8(kafkaRecordBatchMagic)
+	batch.int32(int32(crc32.Checksum(crcPart.buf.Bytes(), crc32c)))
+	batch.buf.Write(crcPart.buf.Bytes())
+
+	return batch.buf.Bytes(), nil
+}
+
+// murmur2 is the hash of the keys of the default partitioner of the Kafka producers, so that the records with the
+// same key go to the same partition as with the other producers
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	length := len(data)
+	h := seed ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+
+	return int32(h)
+}
+
+// kafkaPartition returns the partition of the key, out of n, like the default partitioner of the Kafka producers
+func kafkaPartition(key []byte, n int) int32 {
+	return (murmur2(key) & 0x7fffffff) % int32(n)
+}
@@ -0,0 +1,473 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKafka is a broker leader of all the partitions of its topics, storing the records produced
+type fakeKafka struct {
+	t          *testing.T
+	l          net.Listener
+	nodeID     int32
+	partitions int
+	// peer, when set, is the leader of the odd partitions
+	peer *fakeKafka
+	// errorCode is returned for all the partitions produced, not storing their records
+	errorCode int16
+	// username and password are required with SASL PLAIN when username is set
+	username string
+	password string
+
+	mu         sync.Mutex
+	records    map[int32][]kafkaRecord
+	compressed bool
+}
+
+func newFakeKafka(t *testing.T, partitions int, username, password string) *fakeKafka {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	f := &fakeKafka{t: t, l: l, nodeID: 7, partitions: partitions, username: username, password: password, records: make(map[int32][]kafkaRecord)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go f.serve(conn)
+		}
+	}()
+
+	return f
+}
+
+func (f *fakeKafka) serve(conn net.Conn) {
+	defer conn.Close()
+
+	authenticated := len(f.username) == 0
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+
+		b := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return
+		}
+
+		d := &kafkaDecoder{b: b}
+		apiKey := d.int16()
+		d.int16()
+		correlationID := d.int32()
+		d.string()
+
+		var resp kafkaEncoder
+		switch {
+		case apiKey == kafkaAPISaslHandshake:
+			resp.int16(0)
+			resp.int32(1)
+			resp.string(KafkaSASLPlain)
+		case apiKey == kafkaAPISaslAuthenticate:
+			if string(d.bytes()) == "\x00"+f.username+"\x00"+f.password {
+				authenticated = true
+				resp.int16(0)
+				resp.nullableString("")
+			} else {
+				resp.int16(58)
+				resp.string("invalid credentials")
+			}
+
+			resp.bytes(nil)
+		case !authenticated:
+			return
+		case apiKey == kafkaAPIMetadata:
+			brokers := []*fakeKafka{f}
+			if f.peer != nil {
+				brokers = append(brokers, f.peer)
+			}
+
+			resp.int32(int32(len(brokers)))
+			for _, broker := range brokers {
+				host, port, _ := net.SplitHostPort(broker.l.Addr().String())
+				portNumber, _ := strconv.Atoi(port)
+				resp.int32(broker.nodeID)
+				resp.string(host)
+				resp.int32(int32(portNumber))
+				resp.nullableString("")
+			}
+
+			resp.int32(f.nodeID)
+			resp.int32(int32(d.arrayLen()))
+			topic := d.string()
+			resp.int16(0)
+			resp.string(topic)
+			resp.int8(0)
+			resp.int32(int32(f.partitions))
+			for partition := 0; partition < f.partitions; partition++ {
+				leader := f.nodeID
+				if f.peer != nil && partition%2 == 1 {
+					leader = f.peer.nodeID
+				}
+
+				resp.int16(0)
+				resp.int32(int32(partition))
+				resp.int32(leader)
+				resp.int32(1)
+				resp.int32(leader)
+				resp.int32(1)
+				resp.int32(leader)
+			}
+		case apiKey == kafkaAPIProduce:
+			d.string()
+			acks := d.int16()
+			d.int32()
+			d.arrayLen()
+			topic := d.string()
+			resp.int32(1)
+			resp.string(topic)
+			partitions := d.arrayLen()
+			resp.int32(int32(partitions))
+			for i := 0; i < partitions; i++ {
+				partition := d.int32()
+				batch := d.bytes()
+				if f.errorCode == 0 {
+					f.store(partition, batch)
+				}
+
+				resp.int32(partition)
+				resp.int16(f.errorCode)
+				resp.int64(0)
+				resp.int64(-1)
+			}
+
+			resp.int32(0)
+			if acks == 0 {
+				continue
+			}
+		default:
+			f.t.Errorf("unexpected kafka API %d", apiKey)
+			return
+		}
+
+		var frame kafkaEncoder
+		frame.int32(int32(4 + resp.buf.Len()))
+		frame.int32(correlationID)
+		frame.buf.Write(resp.buf.Bytes())
+		if _, err := conn.Write(frame.buf.Bytes()); err != nil {
+			return
+		}
+	}
+}
+
+// store decodes the record batch and stores its records
+func (f *fakeKafka) store(partition int32, batch []byte) {
+	d := &kafkaDecoder{b: batch}
+	d.int64()
+	assert.Equal(f.t, len(d.b)-4, int(d.int32()), "batch length")
+	d.int32()
+	assert.Equal(f.t, int8(kafkaRecordBatchMagic), d.int8())
+	crc := uint32(d.int32())
+	assert.Equal(f.t, crc32.Checksum(d.b, crc32c), crc, "batch CRC")
+	attributes := d.int16()
+	d.next(4 + 8 + 8 + 8 + 2 + 4)
+	count := int(d.int32())
+	require.NoError(f.t, d.err)
+
+	payload := d.b
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if attributes&7 == kafkaCompressionGzip {
+		f.compressed = true
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		require.NoError(f.t, err)
+		payload, err = io.ReadAll(r)
+		require.NoError(f.t, err)
+	}
+
+	varint := func() int64 {
+		v, n := binary.Varint(payload)
+		require.Positive(f.t, n)
+		payload = payload[n:]
+		return v
+	}
+
+	for i := 0; i < count; i++ {
+		varint()
+		payload = payload[1:]
+		varint()
+		assert.Equal(f.t, int64(i), varint(), "offset delta")
+
+		var record kafkaRecord
+		if n := varint(); n >= 0 {
+			record.key, payload = payload[:n], payload[n:]
+		}
+
+		n := varint()
+		record.value, payload = payload[:n], payload[n:]
+		assert.Equal(f.t, int64(0), varint(), "headers")
+
+		f.records[partition] = append(f.records[partition], record)
+	}
+
+	assert.Empty(f.t, payload)
+}
+
+func (f *fakeKafka) values(partition int32) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var values []string
+	for _, record := range f.records[partition] {
+		values = append(values, string(record.value))
+	}
+
+	return values
+}
+
+func TestKafka_partitionField(t *testing.T) {
+	f := newFakeKafka(t, 3, "producer", "secret")
+	defer f.l.Close()
+
+	s, err := sinks.New(KafkaSinkName, map[string]string{
+		"brokers":         "127.0.0.1:1," + f.l.Addr().String(),
+		"topic":           "logs",
+		"partition_field": "host.name",
+		"compression":     "gzip",
+		"sasl_username":   "producer",
+		"sasl_password":   "secret",
+	})
+	require.NoError(t, err)
+
+	events := [][]byte{
+		[]byte(`{"host":{"name":"h1"},"message":"a"}`),
+		[]byte(`{"host.name":"h2","message":"b"}`),
+		[]byte(`{"host":{"name":"h1"},"message":"c"}`),
+		[]byte(`{"message":"d"}`),
+	}
+
+	require.NoError(t, s.Open(context.Background()))
+	require.NoError(t, s.WriteBatch(context.Background(), events[:2]))
+	require.NoError(t, s.WriteBatch(context.Background(), events[2:]))
+	require.NoError(t, s.Flush(context.Background()))
+	require.NoError(t, s.Close())
+
+	h1, h2 := kafkaPartition([]byte("h1"), 3), kafkaPartition([]byte("h2"), 3)
+	assert.Contains(t, f.values(h1), `{"host":{"name":"h1"},"message":"a"}`)
+	assert.Contains(t, f.values(h1), `{"host":{"name":"h1"},"message":"c"}`)
+	assert.Contains(t, f.values(h2), `{"host.name":"h2","message":"b"}`)
+	assert.Equal(t, []byte("h1"), f.records[h1][0].key)
+
+	// the events without the field go to the partition of their batch, the second one
+	assert.Contains(t, f.values(1), `{"message":"d"}`)
+	assert.True(t, f.compressed)
+	assert.Equal(t, sinks.Stats{Events: 4, Bytes: 119, Batches: 2}, s.Stats())
+}
+
+func TestKafka_sticky(t *testing.T) {
+	f := newFakeKafka(t, 2, "", "")
+	defer f.l.Close()
+
+	s, err := sinks.New(KafkaSinkName, map[string]string{"brokers": f.l.Addr().String(), "topic": "logs"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Open(context.Background()))
+	for _, batch := range []string{"a", "b", "c"} {
+		require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte(batch + "1"), []byte(batch + "2")}))
+	}
+
+	require.NoError(t, s.Close())
+
+	assert.Equal(t, []string{"a1", "a2", "c1", "c2"}, f.values(0))
+	assert.Equal(t, []string{"b1", "b2"}, f.values(1))
+	assert.Nil(t, f.records[0][0].key)
+	assert.False(t, f.compressed)
+}
+
+func TestKafka_hotPartition(t *testing.T) {
+	f := newFakeKafka(t, 3, "", "")
+	defer f.l.Close()
+
+	h1 := kafkaPartition([]byte("h1"), 3)
+	hot := (h1 + 1) % 3
+	s, err := sinks.New(KafkaSinkName, map[string]string{
+		"brokers":             f.l.Addr().String(),
+		"topic":               "logs",
+		"partition_field":     "host.name",
+		"hot_partition":       strconv.Itoa(int(hot)),
+		"hot_partition_ratio": "0.5",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Open(context.Background()))
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{
+		[]byte(`{"host":{"name":"h1"},"message":"a"}`),
+		[]byte(`{"host":{"name":"h1"},"message":"b"}`),
+		[]byte(`{"host":{"name":"h1"},"message":"c"}`),
+		[]byte(`{"host":{"name":"h1"},"message":"d"}`),
+	}))
+	require.NoError(t, s.Close())
+
+	// every second record goes to the hot partition, keeping its key
+	assert.Equal(t, []string{`{"host":{"name":"h1"},"message":"a"}`, `{"host":{"name":"h1"},"message":"c"}`}, f.values(h1))
+	assert.Equal(t, []string{`{"host":{"name":"h1"},"message":"b"}`, `{"host":{"name":"h1"},"message":"d"}`}, f.values(hot))
+	assert.Equal(t, []byte("h1"), f.records[hot][0].key)
+
+	s, err = sinks.New(KafkaSinkName, map[string]string{"brokers": f.l.Addr().String(), "topic": "logs", "hot_partition": "3", "hot_partition_ratio": "1"})
+	require.NoError(t, err)
+	assert.EqualError(t, s.Open(context.Background()), "the kafka `hot_partition` 3 is not a partition of topic logs, with 3 partitions")
+	require.NoError(t, s.Close())
+}
+
+func TestKafka_partialFailure(t *testing.T) {
+	f := newFakeKafka(t, 2, "", "")
+	defer f.l.Close()
+
+	peer := newFakeKafka(t, 2, "", "")
+	defer peer.l.Close()
+
+	// the peer leads the partition 1 and rejects all the records
+	peer.nodeID = 8
+	peer.errorCode = 7
+	f.peer = peer
+
+	s, err := sinks.New(KafkaSinkName, map[string]string{"brokers": f.l.Addr().String(), "topic": "logs", "partition_field": "host.name"})
+	require.NoError(t, err)
+
+	var events, delivered, undelivered [][]byte
+	for i := 0; i < 6; i++ {
+		host := "h" + strconv.Itoa(i)
+		event := []byte(`{"host":{"name":"` + host + `"}}`)
+		events = append(events, event)
+		if kafkaPartition([]byte(host), 2) == 0 {
+			delivered = append(delivered, event)
+		} else {
+			undelivered = append(undelivered, event)
+		}
+	}
+
+	require.NotEmpty(t, delivered)
+	require.NotEmpty(t, undelivered)
+
+	require.NoError(t, s.Open(context.Background()))
+	err = s.WriteBatch(context.Background(), events)
+	require.NoError(t, s.Close())
+
+	var partial *sinks.PartialError
+	require.ErrorAs(t, err, &partial)
+	assert.ErrorContains(t, err, "kafka error 7")
+	assert.Equal(t, undelivered, partial.Undelivered)
+
+	// the records of the partition 0 are produced once, and not returned to be spilled
+	assert.Len(t, f.values(0), len(delivered))
+	assert.Empty(t, peer.values(1))
+	assert.Equal(t, uint64(len(delivered)), s.Stats().Events)
+	assert.Zero(t, s.Stats().Batches)
+}
+
+func TestKafka_chaos(t *testing.T) {
+	f := newFakeKafka(t, 1, "", "")
+	defer f.l.Close()
+
+	s, err := sinks.New(KafkaSinkName, map[string]string{"brokers": f.l.Addr().String(), "topic": "logs", "chaos_partial_write_every": "2"})
+	require.NoError(t, err)
+
+	// the metadata is not affected, the second produce request is cut in half and the third one connects again
+	require.NoError(t, s.Open(context.Background()))
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte("a")}))
+
+	var partial *sinks.PartialError
+	require.ErrorAs(t, s.WriteBatch(context.Background(), [][]byte{[]byte("b")}), &partial)
+	assert.ErrorIs(t, partial, ErrChaosPartialWrite)
+	assert.Equal(t, [][]byte{[]byte("b")}, partial.Undelivered)
+
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte("c")}))
+	require.NoError(t, s.Close())
+
+	assert.Equal(t, []string{"a", "c"}, f.values(0))
+}
+
+func TestKafka_acksNone(t *testing.T) {
+	f := newFakeKafka(t, 1, "", "")
+	defer f.l.Close()
+
+	s, err := sinks.New(KafkaSinkName, map[string]string{"brokers": f.l.Addr().String(), "topic": "logs", "acks": "0"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Open(context.Background()))
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte("a")}))
+	require.NoError(t, s.Close())
+
+	assert.Eventually(t, func() bool { return len(f.values(0)) == 1 }, time.Second*5, time.Millisecond*10)
+}
+
+func TestKafka_authenticationFailed(t *testing.T) {
+	f := newFakeKafka(t, 1, "producer", "secret")
+	defer f.l.Close()
+
+	s, err := sinks.New(KafkaSinkName, map[string]string{"brokers": f.l.Addr().String(), "topic": "logs", "sasl_username": "producer", "sasl_password": "wrong"})
+	require.NoError(t, err)
+
+	err = s.Open(context.Background())
+	assert.ErrorContains(t, err, "SASL authentication failed: invalid credentials: kafka error 58: SASL_AUTHENTICATION_FAILED")
+	require.NoError(t, s.Close())
+}
+
+func TestKafka_notValid(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		options  map[string]string
+		expected string
+	}{
+		{scenario: "no brokers", options: map[string]string{"topic": "logs"}, expected: ErrNotValidKafkaBrokers.Error()},
+		{scenario: "broker without port", options: map[string]string{"brokers": "localhost", "topic": "logs"}, expected: ErrNotValidKafkaBrokers.Error()},
+		{scenario: "no topic", options: map[string]string{"brokers": "localhost:9092"}, expected: ErrNotValidKafkaTopic.Error()},
+		{scenario: "compression", options: map[string]string{"brokers": "localhost:9092", "topic": "logs", "compression": "zstd"}, expected: "the kafka `compression` must be one of 'none' or 'gzip', got 'zstd'"},
+		{scenario: "acks", options: map[string]string{"brokers": "localhost:9092", "topic": "logs", "acks": "2"}, expected: "the kafka `acks` must be one of 'all', '1' or '0', got '2'"},
+		{scenario: "sasl mechanism", options: map[string]string{"brokers": "localhost:9092", "topic": "logs", "sasl_mechanism": "SCRAM-SHA-512", "sasl_username": "u"}, expected: "the kafka `sasl_mechanism` must be 'PLAIN', got 'SCRAM-SHA-512'"},
+		{scenario: "hot partition", options: map[string]string{"brokers": "localhost:9092", "topic": "logs", "hot_partition": "-1"}, expected: "the kafka `hot_partition` must be a partition number, got '-1'"},
+		{scenario: "hot partition ratio", options: map[string]string{"brokers": "localhost:9092", "topic": "logs", "hot_partition": "0", "hot_partition_ratio": "1.5"}, expected: "the kafka `hot_partition_ratio` must be greater than 0 and up to 1, got '1.5'"},
+		{scenario: "hot partition ratio without partition", options: map[string]string{"brokers": "localhost:9092", "topic": "logs", "hot_partition_ratio": "0.5"}, expected: "the kafka `hot_partition_ratio` requires the `hot_partition` option"},
+		{scenario: "sasl without username", options: map[string]string{"brokers": "localhost:9092", "topic": "logs", "sasl_mechanism": "PLAIN"}, expected: "the kafka `sasl_mechanism` requires the `sasl_username` option"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			_, err := NewKafka(testCase.options)
+			assert.EqualError(t, err, testCase.expected)
+		})
+	}
+}
+
+func TestMurmur2(t *testing.T) {
+	// the hashes of the Java client of Kafka
+	for key, expected := range map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	} {
+		assert.Equal(t, expected, murmur2([]byte(key)), key)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLatencyIngestedField is the default date field of the time the documents are ingested, the one set by
+	// the final pipeline of the data streams managed by Fleet
+	DefaultLatencyIngestedField = "event.ingested"
+	// DefaultLatencyInterval is the default interval between the measures of the latency
+	DefaultLatencyInterval = 10 * time.Second
+	// latencyField is the runtime field of the latency of each document, in milliseconds
+	latencyField = "corpus_latency"
+)
+
+// LatencyOptions holds the settings of a LatencyProbe
+type LatencyOptions struct {
+	// URL is the base URL of Elasticsearch the documents are counted in, like `https://localhost:9200`
+	URL string
+	// Index is the data stream, the index, or the index pattern the documents are ingested into
+	Index string
+	// SentField is the field of the time each event has been sent, embedded by the sinks.WithSendTimestamp writer
+	SentField string
+	// IngestedField is the date field of the time each document has been ingested; DefaultLatencyIngestedField when
+	// not set
+	IngestedField string
+	// Client sends the search requests, with the auth of the cluster; http.DefaultClient when not set
+	Client *http.Client
+}
+
+// Latency are the percentiles of the latency from the time the events are sent to the time their documents are
+// ingested, over the documents sent since the probe was created
+type Latency struct {
+	Documents uint64
+	P50       time.Duration
+	P90       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+}
+
+// LatencyProbe measures the end-to-end latency of the events sent to any sink, by querying back the documents they
+// end up in, stamped with the time they have been sent: the latency of each document is computed by Elasticsearch
+// with a runtime field, so that the percentiles are of all the documents, without fetching them.
+type LatencyProbe struct {
+	options   LatencyOptions
+	searchURL string
+	since     time.Time
+}
+
+// NewLatencyProbe returns a LatencyProbe with the options, measuring the documents sent from now on
+func NewLatencyProbe(options LatencyOptions) (*LatencyProbe, error) {
+	u, err := url.Parse(options.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, ErrNotValidURL
+	}
+
+	if len(options.Index) == 0 || len(options.SentField) == 0 {
+		return nil, errors.New("the index and the sent field of the latency must be set")
+	}
+
+	if len(options.IngestedField) == 0 {
+		options.IngestedField = DefaultLatencyIngestedField
+	}
+
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+
+	return &LatencyProbe{
+		options:   options,
+		searchURL: strings.TrimSuffix(u.String(), "/") + "/" + url.PathEscape(options.Index) + "/_search",
+		since:     time.Now(),
+	}, nil
+}
+
+// Measure returns the latency of the documents ingested so far
+func (p *LatencyProbe) Measure(ctx context.Context) (Latency, error) {
+	// the dates are read from the source, whatever their mapping, like when the sent field is not mapped
+	sent, ingested := fmt.Sprintf("%q", p.options.SentField), fmt.Sprintf("%q", p.options.IngestedField)
+	query, err := json.Marshal(map[string]any{
+		"size":             0,
+		"track_total_hits": false,
+		"runtime_mappings": map[string]any{
+			p.options.SentField:     map[string]any{"type": "date"},
+			p.options.IngestedField: map[string]any{"type": "date"},
+			latencyField: map[string]any{
+				"type": "long",
+				"script": map[string]any{
+					"source": fmt.Sprintf("if (doc[%s].size() > 0 && doc[%s].size() > 0) { emit(doc[%s].value.toInstant().toEpochMilli() - doc[%s].value.toInstant().toEpochMilli()) }", sent, ingested, ingested, sent),
+				},
+			},
+		},
+		"query": map[string]any{
+			"range": map[string]any{
+				p.options.SentField: map[string]any{
+					"gte":    p.since.UnixMilli(),
+					"format": "epoch_millis",
+				},
+			},
+		},
+		"aggs": map[string]any{
+			"percentiles": map[string]any{"percentiles": map[string]any{"field": latencyField, "percents": []float64{50, 90, 99}}},
+			"max":         map[string]any{"max": map[string]any{"field": latencyField}},
+			"count":       map[string]any{"value_count": map[string]any{"field": latencyField}},
+		},
+	})
+	if err != nil {
+		return Latency{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.searchURL, bytes.NewReader(query))
+	if err != nil {
+		return Latency{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.options.Client.Do(req)
+	if err != nil {
+		return Latency{}, err
+	}
+
+	defer resp.Body.Close()
+
+	var response struct {
+		Aggregations struct {
+			Percentiles struct {
+				Values map[string]*float64 `json:"values"`
+			} `json:"percentiles"`
+			Max struct {
+				Value *float64 `json:"value"`
+			} `json:"max"`
+			Count struct {
+				Value uint64 `json:"value"`
+			} `json:"count"`
+		} `json:"aggregations"`
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var body bytes.Buffer
+		_, _ = body.ReadFrom(resp.Body)
+		return Latency{}, fmt.Errorf("search of %s failed with status %d: %s", p.options.Index, resp.StatusCode, bytes.TrimSpace(body.Bytes()))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return Latency{}, fmt.Errorf("cannot decode search response: %w", err)
+	}
+
+	aggs := response.Aggregations
+	return Latency{
+		Documents: aggs.Count.Value,
+		P50:       millis(aggs.Percentiles.Values["50.0"]),
+		P90:       millis(aggs.Percentiles.Values["90.0"]),
+		P99:       millis(aggs.Percentiles.Values["99.0"]),
+		Max:       millis(aggs.Max.Value),
+	}, nil
+}
+
+// millis returns the duration of a number of milliseconds, zero when not set, like the aggregations of no documents
+func millis(value *float64) time.Duration {
+	if value == nil {
+		return 0
+	}
+
+	return time.Duration(*value * float64(time.Millisecond))
+}
@@ -0,0 +1,79 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyProbe_Measure(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/logs-test-default/_search" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &request)
+		_, _ = w.Write([]byte(`{"aggregations":{"percentiles":{"values":{"50.0":120.0,"90.0":450.5,"99.0":1200.0}},"max":{"value":2500.0},"count":{"value":1000}}}`))
+	}))
+	defer server.Close()
+
+	before := time.Now()
+	probe, err := NewLatencyProbe(LatencyOptions{URL: server.URL, Index: "logs-test-default", SentField: "corpus.sent_at"})
+	require.NoError(t, err)
+
+	latency, err := probe.Measure(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Latency{
+		Documents: 1000,
+		P50:       120 * time.Millisecond,
+		P90:       450500 * time.Microsecond,
+		P99:       1200 * time.Millisecond,
+		Max:       2500 * time.Millisecond,
+	}, latency)
+
+	// the documents sent before the probe are not measured, and the dates are read from the source
+	since := request["query"].(map[string]any)["range"].(map[string]any)["corpus.sent_at"].(map[string]any)["gte"].(float64)
+	assert.GreaterOrEqual(t, int64(since), before.UnixMilli())
+
+	runtimeMappings := request["runtime_mappings"].(map[string]any)
+	assert.Contains(t, runtimeMappings, "corpus.sent_at")
+	assert.Contains(t, runtimeMappings, DefaultLatencyIngestedField)
+	assert.Contains(t, runtimeMappings, latencyField)
+
+	probe, err = NewLatencyProbe(LatencyOptions{URL: server.URL, Index: "logs-other-default", SentField: "corpus.sent_at"})
+	require.NoError(t, err)
+
+	_, err = probe.Measure(context.Background())
+	assert.ErrorContains(t, err, "search of logs-other-default failed with status 404")
+}
+
+func TestLatencyProbe_noDocuments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"aggregations":{"percentiles":{"values":{"50.0":null,"90.0":null,"99.0":null}},"max":{"value":null},"count":{"value":0}}}`))
+	}))
+	defer server.Close()
+
+	probe, err := NewLatencyProbe(LatencyOptions{URL: server.URL, Index: "logs-*", SentField: "corpus.sent_at"})
+	require.NoError(t, err)
+
+	latency, err := probe.Measure(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Latency{}, latency)
+
+	_, err = NewLatencyProbe(LatencyOptions{URL: "localhost:9200", Index: "logs-*", SentField: "corpus.sent_at"})
+	assert.ErrorIs(t, err, ErrNotValidURL)
+}
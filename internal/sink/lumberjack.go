@@ -0,0 +1,294 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+)
+
+const (
+	// LumberjackSinkName is the name the lumberjack sink is registered with
+	LumberjackSinkName = "lumberjack"
+
+	// DefaultLumberjackCompressionLevel is the default zlib compression level of the windows, the same as Beats
+	DefaultLumberjackCompressionLevel = 3
+	// DefaultLumberjackTimeout is the default time waited for an ACK of the server before failing
+	DefaultLumberjackTimeout = 30 * time.Second
+	// lumberjackDialTimeout caps the time to connect to the server
+	lumberjackDialTimeout = 30 * time.Second
+
+	// the version and the types of the frames of the lumberjack protocol, version 2
+	lumberjackVersion    = '2'
+	lumberjackWindow     = 'W'
+	lumberjackCompressed = 'C'
+	lumberjackJSON       = 'J'
+	lumberjackACK        = 'A'
+)
+
+var ErrNotValidLumberjackAddress = errors.New("the lumberjack sink requires the `address` option, as 'host:port'")
+
+func init() {
+	sinks.Register(LumberjackSinkName, func(options map[string]string) (sinks.Sink, error) {
+		return NewLumberjack(options)
+	})
+}
+
+// Lumberjack is a sink sending the events with the lumberjack protocol, version 2, the one of Beats, like to the
+// beats input of Logstash or of Elastic Agent. Each window of events is sent when the previous one is acknowledged,
+// so that the generation goes at the pace of the server.
+type Lumberjack struct {
+	address          string
+	compressionLevel int
+	windowSize       int
+	timeout          time.Duration
+	tls              *tls.Config
+	// chaos injects faults into the connections, nil for none
+	chaos *chaos
+
+	conn  net.Conn
+	stats sinks.Stats
+}
+
+// NewLumberjack returns a lumberjack sink configured with options: `address`, the `host:port` of the server,
+// `compression_level`, the zlib level of the windows from 0, for none, to 9, DefaultLumberjackCompressionLevel by
+// default, `window_size`, the max number of events of a window, the whole batch by default, `timeout`, the time
+// waited for an ACK of the server, DefaultLumberjackTimeout by default, `tls`, `true` to connect with TLS, the TLS
+// options `tls_ca`, `tls_cert`, `tls_key`, `tls_insecure_skip_verify` and `tls_min_version`, and the chaos options
+func NewLumberjack(options map[string]string) (*Lumberjack, error) {
+	if _, _, err := net.SplitHostPort(options["address"]); err != nil {
+		return nil, ErrNotValidLumberjackAddress
+	}
+
+	l := &Lumberjack{address: options["address"], compressionLevel: DefaultLumberjackCompressionLevel, timeout: DefaultLumberjackTimeout}
+
+	var err error
+	if value, ok := options["compression_level"]; ok {
+		if l.compressionLevel, err = strconv.Atoi(value); err != nil || l.compressionLevel < 0 || l.compressionLevel > 9 {
+			return nil, fmt.Errorf("the lumberjack `compression_level` must be between 0 and 9, got '%s'", value)
+		}
+	}
+
+	if value, ok := options["window_size"]; ok {
+		if l.windowSize, err = strconv.Atoi(value); err != nil || l.windowSize <= 0 {
+			return nil, fmt.Errorf("the lumberjack `window_size` must be a positive number, got '%s'", value)
+		}
+	}
+
+	if value, ok := options["timeout"]; ok {
+		if l.timeout, err = time.ParseDuration(value); err != nil || l.timeout <= 0 {
+			return nil, fmt.Errorf("the lumberjack `timeout` must be a positive duration, like '30s', got '%s'", value)
+		}
+	}
+
+	if l.chaos, err = newChaos("lumberjack", options); err != nil {
+		return nil, err
+	}
+
+	useTLS, err := boolOption("lumberjack", "tls", options)
+	if err != nil {
+		return nil, err
+	}
+
+	if useTLS {
+		tlsOptions, err := sinkTLSOptions("lumberjack", options)
+		if err != nil {
+			return nil, err
+		}
+
+		if l.tls, err = tlsOptions.Config(); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// Open connects to the server
+func (l *Lumberjack) Open(ctx context.Context) error {
+	return l.dial(ctx)
+}
+
+func (l *Lumberjack) dial(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: lumberjackDialTimeout}
+
+	var err error
+	if l.tls != nil {
+		l.conn, err = (&tls.Dialer{NetDialer: dialer, Config: l.tls}).DialContext(ctx, "tcp", l.address)
+	} else {
+		l.conn, err = dialer.DialContext(ctx, "tcp", l.address)
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot connect to lumberjack server %s: %w", l.address, err)
+	}
+
+	l.conn = l.chaos.wrap(l.conn)
+	return nil
+}
+
+// WriteBatch sends the events in windows of at most `window_size` events, each one after the previous one is
+// acknowledged. If a window fails the connection is closed, and the next batch connects again.
+func (l *Lumberjack) WriteBatch(ctx context.Context, events [][]byte) error {
+	if l.conn == nil {
+		if err := l.dial(ctx); err != nil {
+			return err
+		}
+	}
+
+	for len(events) > 0 {
+		n := len(events)
+		if l.windowSize > 0 && n > l.windowSize {
+			n = l.windowSize
+		}
+
+		if err := l.sendWindow(ctx, events[:n]); err != nil {
+			_ = l.conn.Close()
+			l.conn = nil
+			return err
+		}
+
+		events = events[n:]
+	}
+
+	l.stats.Batches++
+	return nil
+}
+
+// sendWindow sends the events in a window, compressed unless the level is 0, and waits for the ACK of the last one
+func (l *Lumberjack) sendWindow(ctx context.Context, events [][]byte) error {
+	var frames bytes.Buffer
+	for i, event := range events {
+		payload, err := lumberjackPayload(event)
+		if err != nil {
+			return err
+		}
+
+		frames.Write([]byte{lumberjackVersion, lumberjackJSON})
+		writeUint32(&frames, uint32(i+1))
+		writeUint32(&frames, uint32(len(payload)))
+		frames.Write(payload)
+	}
+
+	var window bytes.Buffer
+	window.Write([]byte{lumberjackVersion, lumberjackWindow})
+	writeUint32(&window, uint32(len(events)))
+	if l.compressionLevel > 0 {
+		var compressed bytes.Buffer
+		zw, err := zlib.NewWriterLevel(&compressed, l.compressionLevel)
+		if err != nil {
+			return err
+		}
+
+		if _, err := zw.Write(frames.Bytes()); err != nil {
+			return err
+		}
+
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		window.Write([]byte{lumberjackVersion, lumberjackCompressed})
+		writeUint32(&window, uint32(compressed.Len()))
+		window.Write(compressed.Bytes())
+	} else {
+		window.Write(frames.Bytes())
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := l.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+
+	if _, err := l.conn.Write(window.Bytes()); err != nil {
+		return err
+	}
+
+	for {
+		if err := l.conn.SetReadDeadline(l.ackDeadline(ctx)); err != nil {
+			return err
+		}
+
+		var ack [6]byte
+		if _, err := io.ReadFull(l.conn, ack[:]); err != nil {
+			return fmt.Errorf("cannot read lumberjack ACK: %w", err)
+		}
+
+		if ack[0] != lumberjackVersion || ack[1] != lumberjackACK {
+			return fmt.Errorf("unexpected lumberjack frame %q", ack[:2])
+		}
+
+		// the server sends partial ACKs, and ACKs of sequence 0 to keep the connection alive while its pipeline is
+		// blocked: the window is sent when the last event is acknowledged
+		if int(binary.BigEndian.Uint32(ack[2:])) >= len(events) {
+			break
+		}
+	}
+
+	for _, event := range events {
+		l.stats.Events++
+		l.stats.Bytes += uint64(len(event))
+	}
+
+	return nil
+}
+
+// ackDeadline returns the deadline of the next ACK: the timeout from now, or the deadline of ctx if earlier
+func (l *Lumberjack) ackDeadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(l.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+
+	return deadline
+}
+
+// lumberjackPayload returns the event when it's a JSON object, or else a JSON object with the event as `message`,
+// like the lines read by Filebeat
+func lumberjackPayload(event []byte) ([]byte, error) {
+	if trimmed := bytes.TrimSpace(event); len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+		return event, nil
+	}
+
+	return json.Marshal(map[string]string{"message": string(event)})
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+// Flush returns at once: each batch is acknowledged by WriteBatch
+func (l *Lumberjack) Flush(context.Context) error {
+	return nil
+}
+
+// Close closes the connection to the server
+func (l *Lumberjack) Close() error {
+	if l.conn == nil {
+		return nil
+	}
+
+	err := l.conn.Close()
+	l.conn = nil
+	return err
+}
+
+func (l *Lumberjack) Stats() sinks.Stats {
+	return l.stats
+}
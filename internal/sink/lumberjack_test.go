@@ -0,0 +1,189 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readLumberjackWindow reads a window of frames, returning the JSON payloads and whether they were compressed
+func readLumberjackWindow(t *testing.T, r *bufio.Reader) ([]string, bool) {
+	header := make([]byte, 6)
+	_, err := io.ReadFull(r, header)
+	require.NoError(t, err)
+	require.Equal(t, "2W", string(header[:2]))
+	size := int(binary.BigEndian.Uint32(header[2:]))
+
+	frames := r
+	compressed := false
+	kind, err := r.Peek(2)
+	require.NoError(t, err)
+	if string(kind) == "2C" {
+		compressed = true
+		_, err := io.ReadFull(r, header)
+		require.NoError(t, err)
+		payload := make([]byte, binary.BigEndian.Uint32(header[2:]))
+		_, err = io.ReadFull(r, payload)
+		require.NoError(t, err)
+		zr, err := zlib.NewReader(bytes.NewReader(payload))
+		require.NoError(t, err)
+		frames = bufio.NewReader(zr)
+	}
+
+	var events []string
+	for i := 1; i <= size; i++ {
+		frame := make([]byte, 10)
+		_, err := io.ReadFull(frames, frame)
+		require.NoError(t, err)
+		require.Equal(t, "2J", string(frame[:2]))
+		require.Equal(t, uint32(i), binary.BigEndian.Uint32(frame[2:]))
+		payload := make([]byte, binary.BigEndian.Uint32(frame[6:]))
+		_, err = io.ReadFull(frames, payload)
+		require.NoError(t, err)
+		events = append(events, string(payload))
+	}
+
+	return events, compressed
+}
+
+func lumberjackAck(seq uint32) []byte {
+	ack := []byte{'2', 'A', 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(ack[2:], seq)
+	return ack
+}
+
+func TestLumberjack(t *testing.T) {
+	testCases := []struct {
+		scenario   string
+		options    map[string]string
+		compressed bool
+	}{
+		{scenario: "compressed", options: map[string]string{"window_size": "2"}, compressed: true},
+		{scenario: "not compressed", options: map[string]string{"window_size": "2", "compression_level": "0"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer l.Close()
+
+			received := make(chan []string, 1)
+			go func() {
+				conn, err := l.Accept()
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				defer conn.Close()
+
+				var events []string
+				r := bufio.NewReader(conn)
+				for _, size := range []uint32{2, 1} {
+					window, compressed := readLumberjackWindow(t, r)
+					assert.Equal(t, testCase.compressed, compressed)
+					events = append(events, window...)
+
+					// a keepalive and a partial ACK before the last one, like the beats input with a busy pipeline
+					_, _ = conn.Write(lumberjackAck(0))
+					_, _ = conn.Write(lumberjackAck(size - 1))
+					_, _ = conn.Write(lumberjackAck(size))
+				}
+
+				received <- events
+			}()
+
+			options := map[string]string{"address": l.Addr().String()}
+			for key, value := range testCase.options {
+				options[key] = value
+			}
+
+			s, err := sinks.New(LumberjackSinkName, options)
+			require.NoError(t, err)
+
+			require.NoError(t, s.Open(context.Background()))
+			require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte(`{"message":"a"}`), []byte(`b "quoted"`), []byte(`{"message":"c"}`)}))
+			require.NoError(t, s.Close())
+
+			assert.Equal(t, []string{`{"message":"a"}`, `{"message":"b \"quoted\""}`, `{"message":"c"}`}, <-received)
+			assert.Equal(t, sinks.Stats{Events: 3, Bytes: 40, Batches: 1}, s.Stats())
+		})
+	}
+}
+
+func TestLumberjack_timeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			accepted <- conn
+		}
+	}()
+
+	s, err := NewLumberjack(map[string]string{"address": l.Addr().String(), "timeout": "50ms"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Open(context.Background()))
+	err = s.WriteBatch(context.Background(), [][]byte{[]byte(`{"message":"a"}`)})
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded, "a window not acknowledged fails as a deadline, to be spilled")
+	assert.Equal(t, sinks.Stats{}, s.Stats())
+
+	// the next batch connects again
+	go func() {
+		conn := <-accepted
+		_ = conn.Close()
+		conn = <-accepted
+		defer conn.Close()
+		readLumberjackWindow(t, bufio.NewReader(conn))
+		_, _ = conn.Write(lumberjackAck(1))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, s.WriteBatch(ctx, [][]byte{[]byte(`{"message":"b"}`)}))
+	require.NoError(t, s.Close())
+	assert.Equal(t, sinks.Stats{Events: 1, Bytes: 15, Batches: 1}, s.Stats())
+}
+
+func TestLumberjack_notValid(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		options  map[string]string
+		expected string
+	}{
+		{scenario: "no address", options: map[string]string{}, expected: ErrNotValidLumberjackAddress.Error()},
+		{scenario: "compression level", options: map[string]string{"address": "localhost:5044", "compression_level": "10"}, expected: "the lumberjack `compression_level` must be between 0 and 9, got '10'"},
+		{scenario: "window size", options: map[string]string{"address": "localhost:5044", "window_size": "0"}, expected: "the lumberjack `window_size` must be a positive number, got '0'"},
+		{scenario: "timeout", options: map[string]string{"address": "localhost:5044", "timeout": "soon"}, expected: "the lumberjack `timeout` must be a positive duration, like '30s', got 'soon'"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			_, err := NewLumberjack(testCase.options)
+			assert.EqualError(t, err, testCase.expected)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/transport"
+)
+
+// boolOption returns the option of key of the sink of name, false when not set
+func boolOption(name, key string, options map[string]string) (bool, error) {
+	value := options[key]
+	if len(value) == 0 {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("the %s `%s` must be 'true' or 'false', got '%s'", name, key, value)
+	}
+
+	return b, nil
+}
+
+// sinkTLSOptions returns the TLS settings of the sink of name, set with its `tls_ca`, `tls_cert`, `tls_key`,
+// `tls_insecure_skip_verify` and `tls_min_version` options
+func sinkTLSOptions(name string, options map[string]string) (transport.TLSOptions, error) {
+	insecureSkipVerify, err := boolOption(name, "tls_insecure_skip_verify", options)
+	if err != nil {
+		return transport.TLSOptions{}, err
+	}
+
+	return transport.TLSOptions{
+		CA:                 options["tls_ca"],
+		Cert:               options["tls_cert"],
+		Key:                options["tls_key"],
+		InsecureSkipVerify: insecureSkipVerify,
+		MinVersion:         options["tls_min_version"],
+	}, nil
+}
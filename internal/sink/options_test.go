@@ -0,0 +1,38 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/transport"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSinkTLSOptions(t *testing.T) {
+	options, err := sinkTLSOptions("syslog", map[string]string{"tls_ca": "ca.pem", "tls_insecure_skip_verify": "1", "tls_min_version": "1.2"})
+	require.NoError(t, err)
+	assert.Equal(t, transport.TLSOptions{CA: "ca.pem", InsecureSkipVerify: true, MinVersion: "1.2"}, options)
+
+	options, err = sinkTLSOptions("syslog", map[string]string{})
+	require.NoError(t, err)
+	assert.True(t, options.IsZero())
+
+	// an invalid value is rejected by all the sinks, instead of being read as false
+	for name, options := range map[string]map[string]string{
+		SyslogSinkName:     {"address": "localhost:6514", "network": "tls"},
+		KafkaSinkName:      {"brokers": "localhost:9092", "topic": "logs", "tls": "true"},
+		LumberjackSinkName: {"address": "localhost:5044", "tls": "true"},
+	} {
+		options["tls_insecure_skip_verify"] = "yes"
+		_, err := sinks.New(name, options)
+		assert.EqualError(t, err, "sink "+name+": the "+name+" `tls_insecure_skip_verify` must be 'true' or 'false', got 'yes'")
+	}
+
+	_, err = sinks.New(SyslogSinkName, map[string]string{"address": "localhost:514", "fire_and_forget": "yes"})
+	assert.EqualError(t, err, "sink syslog: the syslog `fire_and_forget` must be 'true' or 'false', got 'yes'")
+}
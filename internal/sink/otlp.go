@@ -0,0 +1,170 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/otlp"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/transport"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+)
+
+// OTLPSinkName is the name the OTLP sink is registered with
+const OTLPSinkName = "otlp"
+
+const (
+	// otlpHeaderOption is the prefix of the options setting a header of the export requests
+	otlpHeaderOption = "header."
+	// otlpProtocolHTTP is the only transport of the export requests, OTLP/HTTP with protobuf
+	otlpProtocolHTTP = "http/protobuf"
+)
+
+var ErrNotValidOTLPEndpoint = errors.New("the OTLP sink requires the `endpoint` option, an http or https URL")
+
+func init() {
+	sinks.Register(OTLPSinkName, func(options map[string]string) (sinks.Sink, error) {
+		return NewOTLP(options)
+	})
+}
+
+// OTLP is a sink sending the events to an OTLP/HTTP receiver, like the OpenTelemetry Collector, as export requests
+// of logs or of metrics encoded with protobuf, a request per batch
+type OTLP struct {
+	signal   string
+	endpoint string
+	client   *http.Client
+	stats    sinks.Stats
+}
+
+// NewOTLP returns an OTLP sink configured with options: `endpoint`, the base URL of the receiver, like
+// `http://localhost:4318`, `signal`, either `logs`, the default, or `metrics`, `protocol`, only `http/protobuf`,
+// `header.<name>`, the headers of the requests, like `header.Authorization`, `proxy`, the URL of the HTTP proxy,
+// `username` and `password`, `bearer_token` or `api_key`, the auth of the requests, the TLS options `tls_ca`,
+// `tls_cert`, `tls_key`, `tls_insecure_skip_verify` and `tls_min_version`, and the chaos options
+func NewOTLP(options map[string]string) (*OTLP, error) {
+	u, err := url.Parse(options["endpoint"])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, ErrNotValidOTLPEndpoint
+	}
+
+	signal := options["signal"]
+	if len(signal) == 0 {
+		signal = otlp.SignalLogs
+	}
+
+	if err := otlp.ValidSignal(signal); err != nil {
+		return nil, err
+	}
+
+	// the gRPC transport needs HTTP/2 without TLS, that the standard library doesn't support
+	if protocol := optionOrDefault(options, "protocol", otlpProtocolHTTP); protocol != otlpProtocolHTTP {
+		return nil, fmt.Errorf("the otlp `protocol` must be '%s', the gRPC transport is not supported, got '%s'", otlpProtocolHTTP, protocol)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + otlp.Path(signal)
+
+	tlsOptions, err := sinkTLSOptions("otlp", options)
+	if err != nil {
+		return nil, err
+	}
+
+	httpOptions := transport.HTTPOptions{
+		TLS:         tlsOptions,
+		Proxy:       options["proxy"],
+		Username:    options["username"],
+		Password:    options["password"],
+		BearerToken: options["bearer_token"],
+		APIKey:      options["api_key"],
+	}
+
+	for key, value := range options {
+		if name := strings.TrimPrefix(key, otlpHeaderOption); name != key && len(name) > 0 {
+			httpOptions.Headers = append(httpOptions.Headers, name+": "+value)
+		}
+	}
+
+	sort.Strings(httpOptions.Headers)
+
+	chaos, err := newChaos("otlp", options)
+	if err != nil {
+		return nil, err
+	}
+
+	if chaos != nil {
+		httpOptions.WrapConn = chaos.wrap
+	}
+
+	client, err := transport.NewHTTPClient(httpOptions)
+	if err != nil {
+		return nil, fmt.Errorf("the otlp HTTP options are not valid: %w", err)
+	}
+
+	return &OTLP{signal: signal, endpoint: u.String(), client: client}, nil
+}
+
+func (o *OTLP) Open(context.Context) error {
+	return nil
+}
+
+// WriteBatch sends the events of the batch, JSON objects, in an export request
+func (o *OTLP) WriteBatch(ctx context.Context, events [][]byte) error {
+	request, err := otlp.Request(o.signal, events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint, bytes.NewReader(request))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("export request failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(response))
+	}
+
+	o.stats.Batches++
+	for _, event := range events {
+		o.stats.Events++
+		o.stats.Bytes += uint64(len(event))
+	}
+
+	return nil
+}
+
+// Flush returns at once: each batch is delivered by WriteBatch
+func (o *OTLP) Flush(context.Context) error {
+	return nil
+}
+
+func (o *OTLP) Close() error {
+	return nil
+}
+
+func (o *OTLP) Stats() sinks.Stats {
+	return o.stats
+}
@@ -0,0 +1,127 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/otlp"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/internal/transport"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLP(t *testing.T) {
+	var paths, contentTypes, authorizations []string
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		paths = append(paths, r.URL.Path)
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	s, err := sinks.New(OTLPSinkName, map[string]string{"endpoint": server.URL + "/otlp/", "signal": "metrics", "header.Authorization": "ApiKey secret"})
+	require.NoError(t, err)
+
+	w, err := sinks.NewWriter(context.Background(), s, 2)
+	require.NoError(t, err)
+
+	for _, event := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		_, err := w.Write([]byte(event + "\n"))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{"/otlp/v1/metrics", "/otlp/v1/metrics"}, paths)
+	assert.Equal(t, []string{"application/x-protobuf", "application/x-protobuf"}, contentTypes)
+	assert.Equal(t, []string{"ApiKey secret", "ApiKey secret"}, authorizations)
+	assert.Equal(t, sinks.Stats{Events: 3, Bytes: 21, Batches: 2}, s.Stats())
+
+	expected, err := otlp.Request(otlp.SignalMetrics, [][]byte{[]byte(`{"n":3}`)})
+	require.NoError(t, err)
+	assert.Equal(t, expected, bodies[1])
+}
+
+func TestOTLP_failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("bad request\n"))
+	}))
+	defer server.Close()
+
+	s, err := NewOTLP(map[string]string{"endpoint": server.URL})
+	require.NoError(t, err)
+
+	err = s.WriteBatch(context.Background(), [][]byte{[]byte(`{"message":"a"}`)})
+	assert.EqualError(t, err, "export request failed with status 400: bad request")
+	assert.Equal(t, sinks.Stats{}, s.Stats())
+}
+
+func TestNewOTLP_notValid(t *testing.T) {
+	_, err := NewOTLP(map[string]string{})
+	assert.ErrorIs(t, err, ErrNotValidOTLPEndpoint)
+
+	_, err = NewOTLP(map[string]string{"endpoint": "localhost:4318"})
+	assert.ErrorIs(t, err, ErrNotValidOTLPEndpoint)
+
+	_, err = NewOTLP(map[string]string{"endpoint": "http://localhost:4318", "signal": "traces"})
+	assert.ErrorIs(t, err, otlp.ErrNotValidSignal)
+}
+
+func TestOTLP_httpOptions(t *testing.T) {
+	var username, password, custom string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ = r.BasicAuth()
+		custom = r.Header.Get("X-Custom")
+	}))
+	defer server.Close()
+
+	s, err := NewOTLP(map[string]string{
+		"endpoint":                 server.URL,
+		"username":                 "elastic",
+		"password":                 "changeme",
+		"header.X-Custom":          "value",
+		"tls_insecure_skip_verify": "true",
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte(`{"message":"a"}`)}))
+	assert.Equal(t, "elastic", username)
+	assert.Equal(t, "changeme", password)
+	assert.Equal(t, "value", custom)
+
+	// the certificate of the receiver is verified by default
+	s, err = NewOTLP(map[string]string{"endpoint": server.URL})
+	require.NoError(t, err)
+	assert.ErrorContains(t, s.WriteBatch(context.Background(), [][]byte{[]byte(`{"message":"a"}`)}), "certificate")
+
+	var proxied bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.Host == "collector.example:4318"
+	}))
+	defer proxy.Close()
+
+	s, err = NewOTLP(map[string]string{"endpoint": "http://collector.example:4318", "proxy": proxy.URL})
+	require.NoError(t, err)
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte(`{"message":"a"}`)}))
+	assert.True(t, proxied)
+
+	_, err = NewOTLP(map[string]string{"endpoint": server.URL, "bearer_token": "token", "api_key": "key"})
+	assert.ErrorIs(t, err, transport.ErrHTTPAuth)
+
+	_, err = NewOTLP(map[string]string{"endpoint": server.URL, "tls_min_version": "0.9"})
+	assert.ErrorContains(t, err, "TLS min version must be one of")
+
+	_, err = NewOTLP(map[string]string{"endpoint": "http://localhost:4317", "protocol": "grpc"})
+	assert.EqualError(t, err, "the otlp `protocol` must be 'http/protobuf', the gRPC transport is not supported, got 'grpc'")
+}
@@ -0,0 +1,400 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+)
+
+const (
+	// SyslogSinkName is the name the syslog sink is registered with
+	SyslogSinkName = "syslog"
+
+	// SyslogRFC5424 and SyslogRFC3164 are the formats of the syslog messages
+	SyslogRFC5424 = "rfc5424"
+	SyslogRFC3164 = "rfc3164"
+
+	// SyslogFramingNewline and SyslogFramingOctetCounting are the framings of the messages over TCP and TLS, see
+	// RFC 6587
+	SyslogFramingNewline       = "newline"
+	SyslogFramingOctetCounting = "octet-counting"
+
+	// DefaultSyslogAppName is the default app-name of the messages
+	DefaultSyslogAppName = "corpus-generator"
+	// syslogDialTimeout caps the time to connect to the syslog server
+	syslogDialTimeout = 30 * time.Second
+	// procNetSNMP are the counters of the network stack of Linux, with the send buffer errors of UDP
+	procNetSNMP = "/proc/net/snmp"
+)
+
+var ErrNotValidSyslogAddress = errors.New("the syslog sink requires the `address` option, as 'host:port'")
+
+// syslogFacilities are the codes of the facilities by name
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "ntp": 12, "security": 13, "console": 14, "clock": 15,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverities are the codes of the severities by name
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3, "error": 3, "warning": 4, "warn": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+func init() {
+	sinks.Register(SyslogSinkName, func(options map[string]string) (sinks.Sink, error) {
+		return NewSyslog(options)
+	})
+}
+
+// Syslog is a sink sending each event as the message of a syslog message, RFC 5424 or RFC 3164, over UDP, TCP or
+// TLS, like to the syslog input of Filebeat or Logstash
+type Syslog struct {
+	address  string
+	network  string
+	format   string
+	framing  string
+	priority int
+	hostname string
+	appName  string
+	tls      *tls.Config
+	// chaos injects faults into the connections, nil for none
+	chaos *chaos
+	// fireAndForget drops the datagrams that cannot be sent instead of failing the batch
+	fireAndForget bool
+	// now returns the time of the messages
+	now func() time.Time
+	// snmpPath is the file of the UDP counters, procNetSNMP
+	snmpPath string
+
+	conn  net.Conn
+	w     *bufio.Writer
+	stats sinks.Stats
+	loss  sinks.Loss
+	// sndbufErrors is the UDP send buffer errors counter when opened, if read
+	sndbufErrors   uint64
+	sndbufErrorsOK bool
+}
+
+// NewSyslog returns a syslog sink configured with options: `address`, the `host:port` of the server, `network`,
+// one of `udp`, the default, `tcp` or `tls`, `format`, either `rfc5424`, the default, or `rfc3164`, `framing` over
+// TCP and TLS, either `newline`, the default, or `octet-counting`, `facility` and `severity`, by name or code,
+// `user` and `info` by default, `hostname`, the host name by default, `app_name`, DefaultSyslogAppName by default,
+// `fire_and_forget` over UDP, the TLS options `tls_ca`, `tls_cert`, `tls_key`, `tls_insecure_skip_verify` and
+// `tls_min_version`, and the chaos options
+func NewSyslog(options map[string]string) (*Syslog, error) {
+	if _, _, err := net.SplitHostPort(options["address"]); err != nil {
+		return nil, ErrNotValidSyslogAddress
+	}
+
+	s := &Syslog{
+		address:  options["address"],
+		network:  optionOrDefault(options, "network", "udp"),
+		format:   optionOrDefault(options, "format", SyslogRFC5424),
+		framing:  optionOrDefault(options, "framing", SyslogFramingNewline),
+		hostname: options["hostname"],
+		appName:  optionOrDefault(options, "app_name", DefaultSyslogAppName),
+		now:      time.Now,
+		snmpPath: procNetSNMP,
+	}
+
+	if s.network != "udp" && s.network != "tcp" && s.network != "tls" {
+		return nil, fmt.Errorf("the syslog `network` must be one of 'udp', 'tcp' or 'tls', got '%s'", s.network)
+	}
+
+	if s.format != SyslogRFC5424 && s.format != SyslogRFC3164 {
+		return nil, fmt.Errorf("the syslog `format` must be one of '%s' or '%s', got '%s'", SyslogRFC5424, SyslogRFC3164, s.format)
+	}
+
+	if s.framing != SyslogFramingNewline && s.framing != SyslogFramingOctetCounting {
+		return nil, fmt.Errorf("the syslog `framing` must be one of '%s' or '%s', got '%s'", SyslogFramingNewline, SyslogFramingOctetCounting, s.framing)
+	}
+
+	fireAndForget, err := boolOption("syslog", "fire_and_forget", options)
+	if err != nil {
+		return nil, err
+	}
+
+	if fireAndForget && s.network != "udp" {
+		return nil, fmt.Errorf("the syslog `fire_and_forget` requires the 'udp' network, got '%s'", s.network)
+	}
+
+	s.fireAndForget = fireAndForget
+
+	chaos, err := newChaos("syslog", options)
+	if err != nil {
+		return nil, err
+	}
+
+	s.chaos = chaos
+
+	facility, err := syslogCode(options, "facility", "user", syslogFacilities, 23)
+	if err != nil {
+		return nil, err
+	}
+
+	severity, err := syslogCode(options, "severity", "info", syslogSeverities, 7)
+	if err != nil {
+		return nil, err
+	}
+
+	s.priority = facility*8 + severity
+
+	if len(s.hostname) == 0 {
+		if s.hostname, err = os.Hostname(); err != nil {
+			s.hostname = "-"
+		}
+	}
+
+	if s.network == "tls" {
+		tlsOptions, err := sinkTLSOptions("syslog", options)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.tls, err = tlsOptions.Config(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// optionOrDefault returns the option of key, or defaultValue when not set
+func optionOrDefault(options map[string]string, key, defaultValue string) string {
+	if value, ok := options[key]; ok && len(value) > 0 {
+		return value
+	}
+
+	return defaultValue
+}
+
+// syslogCode returns the code of the option of key, either a name of codes or a number up to maxCode
+func syslogCode(options map[string]string, key, defaultName string, codes map[string]int, maxCode int) (int, error) {
+	value := strings.ToLower(optionOrDefault(options, key, defaultName))
+	if code, ok := codes[value]; ok {
+		return code, nil
+	}
+
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 0 || code > maxCode {
+		return 0, fmt.Errorf("the syslog `%s` must be a name or a code between 0 and %d, got '%s'", key, maxCode, value)
+	}
+
+	return code, nil
+}
+
+// Open connects to the syslog server
+func (s *Syslog) Open(ctx context.Context) error {
+	if err := s.dial(ctx); err != nil {
+		return err
+	}
+
+	if s.network == "udp" {
+		s.sndbufErrors, s.sndbufErrorsOK = udpSndbufErrors(s.snmpPath)
+	}
+
+	return nil
+}
+
+func (s *Syslog) dial(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+
+	var err error
+	if s.network == "tls" {
+		s.conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tls}).DialContext(ctx, "tcp", s.address)
+	} else {
+		s.conn, err = dialer.DialContext(ctx, s.network, s.address)
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot connect to syslog server %s: %w", s.address, err)
+	}
+
+	s.conn = s.chaos.wrap(s.conn)
+	s.w = bufio.NewWriter(s.conn)
+	return nil
+}
+
+// WriteBatch sends a syslog message for each event: a datagram each over UDP, a frame each over TCP and TLS. If
+// the batch fails the connection is closed, and the next batch connects again.
+func (s *Syslog) WriteBatch(ctx context.Context, events [][]byte) error {
+	if s.conn == nil {
+		if err := s.dial(ctx); err != nil {
+			return err
+		}
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+
+	if s.network == "udp" {
+		return s.writeDatagrams(ctx, events)
+	}
+
+	var size uint64
+	for _, event := range events {
+		message := s.message(event)
+		if s.framing == SyslogFramingOctetCounting {
+			s.w.WriteString(strconv.Itoa(len(message)))
+			s.w.WriteByte(' ')
+			s.w.Write(message)
+		} else {
+			s.w.Write(message)
+			s.w.WriteByte('\n')
+		}
+
+		size += uint64(len(event))
+	}
+
+	if err := s.w.Flush(); err != nil {
+		s.disconnect()
+		return err
+	}
+
+	s.stats.Events += uint64(len(events))
+	s.stats.Bytes += size
+	s.stats.Batches++
+	return nil
+}
+
+// writeDatagrams sends a datagram for each event. In fire-and-forget mode the datagrams that cannot be sent are
+// dropped, and accounted for in Loss; a connection dropped by the chaos options is connected again for the next one.
+func (s *Syslog) writeDatagrams(ctx context.Context, events [][]byte) error {
+	deadline, _ := ctx.Deadline()
+	for _, event := range events {
+		s.loss.Intended++
+		if s.conn == nil {
+			err := s.dial(ctx)
+			if err == nil {
+				err = s.conn.SetWriteDeadline(deadline)
+			}
+
+			if err != nil {
+				if s.fireAndForget {
+					continue
+				}
+
+				return err
+			}
+		}
+
+		if _, err := s.conn.Write(s.message(event)); err != nil {
+			if errors.Is(err, ErrChaosDrop) || errors.Is(err, ErrChaosPartialWrite) {
+				s.disconnect()
+			}
+
+			if s.fireAndForget {
+				continue
+			}
+
+			return err
+		}
+
+		s.loss.Sent++
+		s.stats.Events++
+		s.stats.Bytes += uint64(len(event))
+	}
+
+	s.stats.Batches++
+	return nil
+}
+
+// disconnect closes the connection, for the next batch to connect again
+func (s *Syslog) disconnect() {
+	_ = s.conn.Close()
+	s.conn = nil
+}
+
+// message returns the syslog message of the event, in the format of the sink
+func (s *Syslog) message(event []byte) []byte {
+	now := s.now()
+	if s.format == SyslogRFC3164 {
+		// the day of the month is padded with a space, like `Jan  2`
+		header := fmt.Sprintf("<%d>%s %s %s: ", s.priority, now.Format(time.Stamp), s.hostname, s.appName)
+		return append([]byte(header), event...)
+	}
+
+	header := fmt.Sprintf("<%d>1 %s %s %s - - - ", s.priority, now.Format("2006-01-02T15:04:05.000000Z07:00"), s.hostname, s.appName)
+	return append([]byte(header), event...)
+}
+
+// Flush returns at once: each batch is sent by WriteBatch
+func (s *Syslog) Flush(context.Context) error {
+	return nil
+}
+
+// Close closes the connection to the syslog server
+func (s *Syslog) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	return s.conn.Close()
+}
+
+func (s *Syslog) Stats() sinks.Stats {
+	return s.stats
+}
+
+// Loss returns the datagrams intended and sent over UDP, and the ones dropped by the send buffers. The drops are
+// read from the counters of the network stack of Linux, shared by all the UDP sockets of the host, so they are an
+// estimate, and zero on other systems.
+func (s *Syslog) Loss() sinks.Loss {
+	loss := s.loss
+	if s.sndbufErrorsOK {
+		if sndbufErrors, ok := udpSndbufErrors(s.snmpPath); ok && sndbufErrors > s.sndbufErrors {
+			loss.BufferDrops = sndbufErrors - s.sndbufErrors
+		}
+	}
+
+	return loss
+}
+
+// udpSndbufErrors returns the SndbufErrors counter of UDP in the snmp file at path, if it can be read
+func udpSndbufErrors(path string) (uint64, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+
+	// the counters are a line of names followed by a line of values, each one prefixed by the protocol
+	var names []string
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "Udp:" {
+			continue
+		}
+
+		if names == nil {
+			names = fields
+			continue
+		}
+
+		for i, name := range names {
+			if name == "SndbufErrors" && i < len(fields) {
+				value, err := strconv.ParseUint(fields[i], 10, 64)
+				return value, err == nil
+			}
+		}
+
+		break
+	}
+
+	return 0, false
+}
@@ -0,0 +1,199 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var syslogNow = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC) }
+
+func TestSyslog_udp(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	s, err := sinks.New(SyslogSinkName, map[string]string{"address": pc.LocalAddr().String(), "facility": "local0", "severity": "warning", "hostname": "web-1", "app_name": "nginx"})
+	require.NoError(t, err)
+	s.(*Syslog).now = syslogNow
+
+	require.NoError(t, s.Open(context.Background()))
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte(`{"message":"a"}`), []byte(`{"message":"b"}`)}))
+	require.NoError(t, s.Close())
+
+	buf := make([]byte, 1024)
+	var datagrams []string
+	for i := 0; i < 2; i++ {
+		require.NoError(t, pc.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := pc.ReadFrom(buf)
+		require.NoError(t, err)
+		datagrams = append(datagrams, string(buf[:n]))
+	}
+
+	assert.Equal(t, []string{
+		`<132>1 2024-01-02T03:04:05.000006Z web-1 nginx - - - {"message":"a"}`,
+		`<132>1 2024-01-02T03:04:05.000006Z web-1 nginx - - - {"message":"b"}`,
+	}, datagrams)
+	assert.Equal(t, sinks.Stats{Events: 2, Bytes: 30, Batches: 1}, s.Stats())
+}
+
+func TestSyslog_fireAndForget(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	snmp := filepath.Join(t.TempDir(), "snmp")
+	writeSNMP := func(sndbufErrors string) {
+		counters := "Ip: Forwarding DefaultTTL\nIp: 1 64\n" +
+			"Udp: InDatagrams NoPorts InErrors OutDatagrams RcvbufErrors SndbufErrors InCsumErrors\n" +
+			"Udp: 10 0 0 10 0 " + sndbufErrors + " 0\n"
+		require.NoError(t, os.WriteFile(snmp, []byte(counters), 0644))
+	}
+
+	s, err := sinks.New(SyslogSinkName, map[string]string{"address": pc.LocalAddr().String(), "fire_and_forget": "true"})
+	require.NoError(t, err)
+	s.(*Syslog).snmpPath = snmp
+
+	writeSNMP("5")
+	require.NoError(t, s.Open(context.Background()))
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte("a"), []byte("b"), []byte("c")}))
+
+	// the datagrams that cannot be sent, like the ones too large, are dropped without failing the batch
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{bytes.Repeat([]byte("d"), 70000)}))
+	writeSNMP("6")
+	require.NoError(t, s.Close())
+
+	loss := s.(sinks.LossReporter).Loss()
+	assert.Equal(t, sinks.Loss{Intended: 4, Sent: 3, BufferDrops: 1}, loss)
+	assert.Equal(t, 0.5, loss.Rate())
+	assert.Equal(t, uint64(3), s.Stats().Events)
+}
+
+func TestSyslog_tcp(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		options  map[string]string
+		expected string
+	}{
+		{
+			scenario: "rfc3164 with newline framing",
+			options:  map[string]string{"format": "rfc3164", "hostname": "web-1"},
+			expected: "<14>Jan  2 03:04:05 web-1 corpus-generator: a\n<14>Jan  2 03:04:05 web-1 corpus-generator: b\n",
+		},
+		{
+			scenario: "rfc5424 with octet-counting framing",
+			options:  map[string]string{"framing": "octet-counting", "hostname": "web-1", "facility": "3", "severity": "err"},
+			expected: "64 <27>1 2024-01-02T03:04:05.000006Z web-1 corpus-generator - - - a" +
+				"64 <27>1 2024-01-02T03:04:05.000006Z web-1 corpus-generator - - - b",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer l.Close()
+
+			received := make(chan string, 1)
+			go func() {
+				conn, err := l.Accept()
+				if err != nil {
+					received <- err.Error()
+					return
+				}
+
+				defer conn.Close()
+				data, _ := io.ReadAll(conn)
+				received <- string(data)
+			}()
+
+			options := map[string]string{"address": l.Addr().String(), "network": "tcp"}
+			for key, value := range testCase.options {
+				options[key] = value
+			}
+
+			s, err := NewSyslog(options)
+			require.NoError(t, err)
+			s.now = syslogNow
+
+			require.NoError(t, s.Open(context.Background()))
+			require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte("a"), []byte("b")}))
+			require.NoError(t, s.Flush(context.Background()))
+			require.NoError(t, s.Close())
+
+			assert.Equal(t, testCase.expected, <-received)
+		})
+	}
+}
+
+func TestSyslog_chaos(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			b, _ := io.ReadAll(conn)
+			received <- string(b)
+		}
+	}()
+
+	s, err := sinks.New(SyslogSinkName, map[string]string{"address": l.Addr().String(), "network": "tcp", "format": "rfc3164", "hostname": "web-1", "chaos_partial_write_every": "2"})
+	require.NoError(t, err)
+	s.(*Syslog).now = syslogNow
+
+	require.NoError(t, s.Open(context.Background()))
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte("a")}))
+
+	// the second batch is cut in half and fails, the third one connects again
+	assert.ErrorIs(t, s.WriteBatch(context.Background(), [][]byte{[]byte("bbbbbbbbbb")}), ErrChaosPartialWrite)
+	require.NoError(t, s.WriteBatch(context.Background(), [][]byte{[]byte("c")}))
+	require.NoError(t, s.Close())
+
+	assert.Equal(t, "<14>Jan  2 03:04:05 web-1 corpus-generator: a\n<14>Jan  2 03:04:05 web-1 c", <-received)
+	assert.Equal(t, "<14>Jan  2 03:04:05 web-1 corpus-generator: c\n", <-received)
+	assert.Equal(t, sinks.Stats{Events: 2, Bytes: 2, Batches: 2}, s.Stats())
+}
+
+func TestNewSyslog_notValid(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		options  map[string]string
+		expected string
+	}{
+		{scenario: "no address", options: map[string]string{}, expected: ErrNotValidSyslogAddress.Error()},
+		{scenario: "address without port", options: map[string]string{"address": "localhost"}, expected: ErrNotValidSyslogAddress.Error()},
+		{scenario: "network", options: map[string]string{"address": "localhost:514", "network": "http"}, expected: "the syslog `network` must be one of 'udp', 'tcp' or 'tls', got 'http'"},
+		{scenario: "format", options: map[string]string{"address": "localhost:514", "format": "cef"}, expected: "the syslog `format` must be one of 'rfc5424' or 'rfc3164', got 'cef'"},
+		{scenario: "framing", options: map[string]string{"address": "localhost:514", "framing": "none"}, expected: "the syslog `framing` must be one of 'newline' or 'octet-counting', got 'none'"},
+		{scenario: "fire and forget over tcp", options: map[string]string{"address": "localhost:514", "network": "tcp", "fire_and_forget": "true"}, expected: "the syslog `fire_and_forget` requires the 'udp' network, got 'tcp'"},
+		{scenario: "facility", options: map[string]string{"address": "localhost:514", "facility": "24"}, expected: "the syslog `facility` must be a name or a code between 0 and 23, got '24'"},
+		{scenario: "severity", options: map[string]string{"address": "localhost:514", "severity": "loud"}, expected: "the syslog `severity` must be a name or a code between 0 and 7, got 'loud'"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			_, err := NewSyslog(testCase.options)
+			assert.EqualError(t, err, testCase.expected)
+		})
+	}
+}
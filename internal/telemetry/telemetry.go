@@ -0,0 +1,452 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package telemetry instruments the tool with OpenTelemetry spans and metrics, exported with the
+// OTLP/HTTP JSON protocol, so that long generation jobs can be monitored.
+// A nil *Telemetry, and the spans and counters it returns, are valid and do nothing.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultInterval is the default interval between the exports of the metrics and of the ended spans
+const DefaultInterval = 10 * time.Second
+
+const scopeName = "github.com/elastic/elastic-integration-corpus-generator-tool"
+
+var ErrNotValidEndpoint = errors.New("the OTLP endpoint must be an http or https URL")
+
+// Options holds the settings of the exporter
+type Options struct {
+	// Endpoint is the base URL of the OTLP/HTTP receiver, like `http://localhost:4318`
+	Endpoint string
+	// ServiceName is the `service.name` of the resource, and ServiceVersion its `service.version`
+	ServiceName    string
+	ServiceVersion string
+	// Interval is the interval between the exports; DefaultInterval when not set
+	Interval time.Duration
+	// Client sends the exports; http.DefaultClient when not set
+	Client *http.Client
+}
+
+// Telemetry collects the spans and the metrics of the tool, and exports them periodically
+type Telemetry struct {
+	options  Options
+	endpoint *url.URL
+	start    time.Time
+
+	mu       sync.Mutex
+	spans    []*Span
+	counters []*Counter
+	// err is the last error of an export
+	err error
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// Attribute is a key and a value of a span or of the resource
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span is an operation of the tool
+type Span struct {
+	t        *Telemetry
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    []Attribute
+	err      error
+}
+
+// Counter is a cumulative monotonic sum, like the number of events generated
+type Counter struct {
+	name        string
+	unit        string
+	description string
+	value       int64
+}
+
+// New returns the telemetry exporting to the endpoint of options, every interval until Shutdown is called
+func New(options Options) (*Telemetry, error) {
+	endpoint, err := url.Parse(options.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || len(endpoint.Host) == 0 {
+		return nil, ErrNotValidEndpoint
+	}
+
+	if options.Interval <= 0 {
+		options.Interval = DefaultInterval
+	}
+
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+
+	t := &Telemetry{
+		options:  options,
+		endpoint: endpoint,
+		start:    time.Now(),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	go t.run()
+
+	return t, nil
+}
+
+func (t *Telemetry) run() {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(t.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.export(context.Background())
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// Shutdown stops the periodic exports and exports the remaining spans and the metrics a last time.
+// It returns the last error of the exports, if any.
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	close(t.done)
+	<-t.stopped
+
+	t.export(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.err
+}
+
+// StartSpan starts a span named name, child of parent when not nil
+func (t *Telemetry) StartSpan(name string, parent *Span, attrs ...Attribute) *Span {
+	if t == nil {
+		return nil
+	}
+
+	s := &Span{t: t, spanID: randomID(8), name: name, start: time.Now(), attrs: attrs}
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		s.traceID = randomID(16)
+	}
+
+	return s
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// End ends the span, with an error status when err is not nil; the span is exported at the next export
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	s.end = time.Now()
+	s.err = err
+
+	s.t.mu.Lock()
+	s.t.spans = append(s.t.spans, s)
+	s.t.mu.Unlock()
+}
+
+// Counter returns a new cumulative counter named name, exported with the metrics
+func (t *Telemetry) Counter(name, unit, description string) *Counter {
+	if t == nil {
+		return nil
+	}
+
+	c := &Counter{name: name, unit: unit, description: description}
+
+	t.mu.Lock()
+	t.counters = append(t.counters, c)
+	t.mu.Unlock()
+
+	return c
+}
+
+// Add adds n to the counter; it's safe for concurrent use
+func (c *Counter) Add(n int64) {
+	if c == nil {
+		return
+	}
+
+	atomic.AddInt64(&c.value, n)
+}
+
+// export sends the ended spans and the metrics, keeping the error if any
+func (t *Telemetry) export(ctx context.Context) {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	counters := append([]*Counter(nil), t.counters...)
+	t.mu.Unlock()
+
+	var err error
+	if len(spans) > 0 {
+		err = t.post(ctx, "v1/traces", t.tracesRequest(spans))
+	}
+
+	if len(counters) > 0 {
+		if metricsErr := t.post(ctx, "v1/metrics", t.metricsRequest(counters, time.Now())); metricsErr != nil {
+			err = metricsErr
+		}
+	}
+
+	if err != nil {
+		t.mu.Lock()
+		t.err = err
+		t.mu.Unlock()
+	}
+}
+
+func (t *Telemetry) post(ctx context.Context, path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	u := *t.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.options.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot export telemetry: %w", err)
+	}
+
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("cannot export telemetry to %s: %s", u.String(), resp.Status)
+	}
+
+	return nil
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// the types below are the OTLP/HTTP JSON encoding of the requests, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpStatus struct {
+	// Code is 1 for ok and 2 for error
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpDataPoint struct {
+	StartTimeUnixNano string `json:"startTimeUnixNano"`
+	TimeUnixNano      string `json:"timeUnixNano"`
+	AsInt             string `json:"asInt"`
+}
+
+type otlpSum struct {
+	// AggregationTemporality is 2 for cumulative
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpMetric struct {
+	Name        string  `json:"name"`
+	Unit        string  `json:"unit,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Sum         otlpSum `json:"sum"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+func otlpAttributes(attrs []Attribute) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value map[string]any
+		switch v := attr.Value.(type) {
+		case int64:
+			// 64 bits integers are strings in the JSON encoding
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+
+		kvs = append(kvs, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+
+	return kvs
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func (t *Telemetry) resource() otlpResource {
+	attrs := []Attribute{String("service.name", t.options.ServiceName)}
+	if len(t.options.ServiceVersion) > 0 {
+		attrs = append(attrs, String("service.version", t.options.ServiceVersion))
+	}
+
+	return otlpResource{Attributes: otlpAttributes(attrs)}
+}
+
+func (t *Telemetry) tracesRequest(spans []*Span) otlpTracesRequest {
+	scopeSpans := otlpScopeSpans{Scope: otlpScope{Name: scopeName, Version: t.options.ServiceVersion}}
+	for _, s := range spans {
+		// spans are internal operations of the tool
+		span := otlpSpan{
+			TraceID:           s.traceID,
+			SpanID:            s.spanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              1,
+			StartTimeUnixNano: unixNano(s.start),
+			EndTimeUnixNano:   unixNano(s.end),
+			Attributes:        otlpAttributes(s.attrs),
+			Status:            otlpStatus{Code: 1},
+		}
+
+		if s.err != nil {
+			span.Status = otlpStatus{Code: 2, Message: s.err.Error()}
+		}
+
+		scopeSpans.Spans = append(scopeSpans.Spans, span)
+	}
+
+	return otlpTracesRequest{ResourceSpans: []otlpResourceSpans{{Resource: t.resource(), ScopeSpans: []otlpScopeSpans{scopeSpans}}}}
+}
+
+func (t *Telemetry) metricsRequest(counters []*Counter, now time.Time) otlpMetricsRequest {
+	scopeMetrics := otlpScopeMetrics{Scope: otlpScope{Name: scopeName, Version: t.options.ServiceVersion}}
+	for _, c := range counters {
+		scopeMetrics.Metrics = append(scopeMetrics.Metrics, otlpMetric{
+			Name:        c.name,
+			Unit:        c.unit,
+			Description: c.description,
+			Sum: otlpSum{
+				AggregationTemporality: 2,
+				IsMonotonic:            true,
+				DataPoints: []otlpDataPoint{{
+					StartTimeUnixNano: unixNano(t.start),
+					TimeUnixNano:      unixNano(now),
+					AsInt:             strconv.FormatInt(atomic.LoadInt64(&c.value), 10),
+				}},
+			},
+		})
+	}
+
+	return otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{Resource: t.resource(), ScopeMetrics: []otlpScopeMetrics{scopeMetrics}}}}
+}
@@ -0,0 +1,113 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelemetry(t *testing.T) {
+	var mu sync.Mutex
+	var traces []otlpTracesRequest
+	var metrics []otlpMetricsRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		switch r.URL.Path {
+		case "/otlp/v1/traces":
+			var req otlpTracesRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			traces = append(traces, req)
+		case "/otlp/v1/metrics":
+			var req otlpMetricsRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			metrics = append(metrics, req)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tel, err := New(Options{Endpoint: server.URL + "/otlp/", ServiceName: "corpus-generator", ServiceVersion: "1.0.0", Interval: time.Hour})
+	require.NoError(t, err)
+
+	events := tel.Counter("corpus.events", "{event}", "events generated")
+	events.Add(3)
+	events.Add(2)
+
+	parent := tel.StartSpan("corpus.generate", nil, String("corpus.name", "test"), Int("corpus.events.total", 5))
+	child := tel.StartSpan("corpus.save_state", parent)
+	child.End(errors.New("disk full"))
+	parent.End(nil)
+
+	require.NoError(t, tel.Shutdown(context.Background()))
+
+	require.Len(t, traces, 1)
+	resourceSpans := traces[0].ResourceSpans[0]
+	assert.Equal(t, "service.name", resourceSpans.Resource.Attributes[0].Key)
+	assert.Equal(t, "corpus-generator", resourceSpans.Resource.Attributes[0].Value["stringValue"])
+
+	spans := resourceSpans.ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+	assert.Equal(t, "corpus.save_state", spans[0].Name)
+	assert.Equal(t, spans[1].TraceID, spans[0].TraceID)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Len(t, spans[0].TraceID, 32)
+	assert.Len(t, spans[0].SpanID, 16)
+	assert.Equal(t, otlpStatus{Code: 2, Message: "disk full"}, spans[0].Status)
+	assert.Equal(t, otlpStatus{Code: 1}, spans[1].Status)
+	assert.Equal(t, "5", spans[1].Attributes[1].Value["intValue"])
+
+	require.Len(t, metrics, 1)
+	metric := metrics[0].ResourceMetrics[0].ScopeMetrics[0].Metrics[0]
+	assert.Equal(t, "corpus.events", metric.Name)
+	assert.True(t, metric.Sum.IsMonotonic)
+	assert.Equal(t, 2, metric.Sum.AggregationTemporality)
+	assert.Equal(t, "5", metric.Sum.DataPoints[0].AsInt)
+}
+
+func TestTelemetry_ExportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	tel, err := New(Options{Endpoint: server.URL, ServiceName: "corpus-generator"})
+	require.NoError(t, err)
+
+	tel.Counter("corpus.events", "{event}", "events generated").Add(1)
+	assert.Error(t, tel.Shutdown(context.Background()))
+}
+
+func TestTelemetry_Disabled(t *testing.T) {
+	var tel *Telemetry
+
+	span := tel.StartSpan("corpus.generate", nil)
+	span.SetAttributes(String("corpus.name", "test"))
+	span.End(nil)
+	tel.Counter("corpus.events", "{event}", "events generated").Add(1)
+	assert.NoError(t, tel.Shutdown(context.Background()))
+}
+
+func TestNew_Endpoint(t *testing.T) {
+	for _, endpoint := range []string{"", "localhost:4318", "grpc://localhost:4317", "http://"} {
+		_, err := New(Options{Endpoint: endpoint})
+		assert.ErrorIs(t, err, ErrNotValidEndpoint, endpoint)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var ErrHTTPAuth = errors.New("only one between basic auth, bearer token and API key can be set")
+
+// HTTPOptions holds the settings shared by all the HTTP connections of the tool
+type HTTPOptions struct {
+	TLS TLSOptions
+	// Proxy is the URL of the proxy to use; when not set the proxy is taken from the environment,
+	// as with the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	Proxy string
+	// Headers are added to each request, in the `Name: value` format
+	Headers []string
+	// Username and Password are sent with basic auth
+	Username string
+	Password string
+	// BearerToken is sent in the `Authorization: Bearer` header
+	BearerToken string
+	// APIKey is sent in the `Authorization: ApiKey` header, already encoded as expected by the server
+	APIKey string
+	// WrapConn wraps each connection of the client when set, like to inject faults into it
+	WrapConn func(net.Conn) net.Conn
+}
+
+// IsZero reports whether no HTTP setting is set, so that the default client can be used
+func (o HTTPOptions) IsZero() bool {
+	return o.TLS.IsZero() && len(o.Proxy) == 0 && len(o.Headers) == 0 &&
+		len(o.Username) == 0 && len(o.Password) == 0 && len(o.BearerToken) == 0 && len(o.APIKey) == 0 && o.WrapConn == nil
+}
+
+// header returns the headers to add to each request, including the auth one
+func (o HTTPOptions) header() (http.Header, error) {
+	header := make(http.Header)
+	for _, h := range o.Headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || len(strings.TrimSpace(name)) == 0 {
+			return nil, fmt.Errorf("HTTP header must be in the 'Name: value' format, got '%s'", h)
+		}
+
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	var nAuth int
+	if len(o.Username) > 0 || len(o.Password) > 0 {
+		nAuth++
+	}
+
+	if len(o.BearerToken) > 0 {
+		nAuth++
+		header.Set("Authorization", "Bearer "+o.BearerToken)
+	}
+
+	if len(o.APIKey) > 0 {
+		nAuth++
+		header.Set("Authorization", "ApiKey "+o.APIKey)
+	}
+
+	if nAuth > 1 {
+		return nil, ErrHTTPAuth
+	}
+
+	return header, nil
+}
+
+// NewHTTPClient returns an HTTP client for the options, or the default client when no option is set
+func NewHTTPClient(options HTTPOptions) (*http.Client, error) {
+	if options.IsZero() {
+		return http.DefaultClient, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if !options.TLS.IsZero() {
+		tlsConfig, err := options.TLS.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	if len(options.Proxy) > 0 {
+		proxyURL, err := url.Parse(options.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP proxy: %w", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if options.WrapConn != nil {
+		dial := transport.DialContext
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := dial(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+
+			return options.WrapConn(conn), nil
+		}
+	}
+
+	header, err := options.header()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: &headerRoundTripper{
+		next:     transport,
+		header:   header,
+		username: options.Username,
+		password: options.Password,
+	}}, nil
+}
+
+// headerRoundTripper adds the headers and the basic auth to each request
+type headerRoundTripper struct {
+	next     http.RoundTripper
+	header   http.Header
+	username string
+	password string
+}
+
+func (rt *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	for name, values := range rt.header {
+		req.Header[name] = values
+	}
+
+	if len(rt.username) > 0 || len(rt.password) > 0 {
+		req.SetBasicAuth(rt.username, rt.password)
+	}
+
+	return rt.next.RoundTrip(req)
+}
@@ -0,0 +1,135 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package transport
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	client, err := NewHTTPClient(HTTPOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, http.DefaultClient, client)
+
+	client, err = NewHTTPClient(HTTPOptions{TLS: TLSOptions{InsecureSkipVerify: true}})
+	require.NoError(t, err)
+	assert.True(t, client.Transport.(*headerRoundTripper).next.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+}
+
+func TestNewHTTPClient_Headers(t *testing.T) {
+	testCases := []struct {
+		scenario      string
+		options       HTTPOptions
+		expectedAuth  string
+		expectedBasic bool
+		hasError      bool
+	}{
+		{
+			scenario: "custom headers",
+			options:  HTTPOptions{Headers: []string{"X-Custom: value"}},
+		},
+		{
+			scenario:      "basic auth",
+			options:       HTTPOptions{Username: "elastic", Password: "changeme"},
+			expectedBasic: true,
+		},
+		{
+			scenario:     "bearer token",
+			options:      HTTPOptions{BearerToken: "token"},
+			expectedAuth: "Bearer token",
+		},
+		{
+			scenario:     "API key",
+			options:      HTTPOptions{APIKey: "key"},
+			expectedAuth: "ApiKey key",
+		},
+		{
+			scenario: "bearer token and API key",
+			options:  HTTPOptions{BearerToken: "token", APIKey: "key"},
+			hasError: true,
+		},
+		{
+			scenario: "invalid header",
+			options:  HTTPOptions{Headers: []string{"X-Custom"}},
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			client, err := NewHTTPClient(testCase.options)
+			if testCase.hasError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			var received *http.Request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r
+			}))
+			defer server.Close()
+
+			resp, err := client.Get(server.URL)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			if len(testCase.options.Headers) > 0 {
+				assert.Equal(t, "value", received.Header.Get("X-Custom"))
+			}
+
+			username, password, ok := received.BasicAuth()
+			assert.Equal(t, testCase.expectedBasic, ok)
+			if ok {
+				assert.Equal(t, testCase.options.Username, username)
+				assert.Equal(t, testCase.options.Password, password)
+			} else {
+				assert.Equal(t, testCase.expectedAuth, received.Header.Get("Authorization"))
+			}
+		})
+	}
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	var proxied bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.Host == "registry.example"
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(HTTPOptions{Proxy: proxy.URL})
+	require.NoError(t, err)
+
+	resp, err := client.Get("http://registry.example/search")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.True(t, proxied)
+}
+
+func TestNewHTTPClient_WrapConn(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var wrapped int
+	client, err := NewHTTPClient(HTTPOptions{WrapConn: func(conn net.Conn) net.Conn {
+		wrapped++
+		return conn
+	}})
+	require.NoError(t, err)
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, 1, wrapped)
+}
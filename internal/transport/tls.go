@@ -0,0 +1,90 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrTLSKeyPair = errors.New("both a TLS certificate and key must be provided for client authentication")
+
+// tlsVersions maps the accepted min versions to their TLS constant
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSOptions holds the TLS settings shared by all the network connections of the tool
+type TLSOptions struct {
+	// CA is the path of a PEM file with the certificate authorities to trust, in addition to the system ones
+	CA string
+	// Cert and Key are the paths of the PEM files of the client certificate and key, for mutual TLS
+	Cert string
+	Key  string
+	// InsecureSkipVerify disables the verification of the server certificate
+	InsecureSkipVerify bool
+	// MinVersion is the minimum TLS version accepted, one of "1.0", "1.1", "1.2", "1.3"
+	MinVersion string
+}
+
+// IsZero reports whether no TLS setting is set, so that the defaults of the standard library apply
+func (o TLSOptions) IsZero() bool {
+	return o == TLSOptions{}
+}
+
+// Config returns the TLS config for the options
+func (o TLSOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if len(o.MinVersion) > 0 {
+		minVersion, ok := tlsVersions[o.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("TLS min version must be one of '1.0', '1.1', '1.2', '1.3', got '%s'", o.MinVersion)
+		}
+
+		cfg.MinVersion = minVersion
+	}
+
+	if len(o.CA) > 0 {
+		caContent, err := os.ReadFile(o.CA)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read TLS CA: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(caContent) {
+			return nil, fmt.Errorf("no PEM certificate found in TLS CA %s", o.CA)
+		}
+
+		cfg.RootCAs = pool
+	}
+
+	if len(o.Cert) > 0 || len(o.Key) > 0 {
+		if len(o.Cert) == 0 || len(o.Key) == 0 {
+			return nil, ErrTLSKeyPair
+		}
+
+		cert, err := tls.LoadX509KeyPair(o.Cert, o.Key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load TLS certificate and key: %w", err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
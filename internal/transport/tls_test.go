@@ -0,0 +1,120 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSelfSignedCert writes a self-signed certificate and its key as PEM files in a temp dir
+func writeSelfSignedCert(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return certPath, keyPath
+}
+
+func TestTLSOptions_Config(t *testing.T) {
+	certPath, keyPath := writeSelfSignedCert(t)
+
+	testCases := []struct {
+		scenario string
+		options  TLSOptions
+		hasError bool
+	}{
+		{
+			scenario: "no options",
+			options:  TLSOptions{},
+		},
+		{
+			scenario: "min version",
+			options:  TLSOptions{MinVersion: "1.2"},
+		},
+		{
+			scenario: "invalid min version",
+			options:  TLSOptions{MinVersion: "2.0"},
+			hasError: true,
+		},
+		{
+			scenario: "CA",
+			options:  TLSOptions{CA: certPath},
+		},
+		{
+			scenario: "CA without certificates",
+			options:  TLSOptions{CA: keyPath},
+			hasError: true,
+		},
+		{
+			scenario: "client certificate",
+			options:  TLSOptions{Cert: certPath, Key: keyPath},
+		},
+		{
+			scenario: "client certificate without key",
+			options:  TLSOptions{Cert: certPath},
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := testCase.options.Config()
+			if testCase.hasError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.options.InsecureSkipVerify, cfg.InsecureSkipVerify)
+			if testCase.options.MinVersion == "1.2" {
+				assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+			}
+
+			if len(testCase.options.CA) > 0 {
+				assert.NotNil(t, cfg.RootCAs)
+			}
+
+			if len(testCase.options.Cert) > 0 {
+				assert.Len(t, cfg.Certificates, 1)
+			}
+		})
+	}
+}
@@ -17,7 +17,16 @@ func main() {
 	rootCmd := cmd.RootCmd()
 	rootCmd.AddCommand(cmd.GenerateCmd())
 	rootCmd.AddCommand(cmd.GenerateWithTemplateCmd())
+	rootCmd.AddCommand(cmd.GenerateScenarioCmd())
+	rootCmd.AddCommand(cmd.GenerateFromMappingCmd())
+	rootCmd.AddCommand(cmd.EstimateCmd())
 	rootCmd.AddCommand(cmd.TemplateCmd())
+	rootCmd.AddCommand(cmd.TemplateToolsCmd())
+	rootCmd.AddCommand(cmd.CatalogCmd())
+	rootCmd.AddCommand(cmd.PackageCmd())
+	rootCmd.AddCommand(cmd.CorpusCmd())
+	rootCmd.AddCommand(cmd.DownsampleCmd())
+	rootCmd.AddCommand(cmd.MergeCmd())
 	rootCmd.AddCommand(cmd.VersionCmd())
 
 	err := rootCmd.Execute()
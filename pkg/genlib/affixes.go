@@ -0,0 +1,65 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// makeAffixesFunc returns the function wrapping the text of a value with the `prefix`, the `format` and the `suffix`
+// of the field
+func makeAffixesFunc(fieldCfg ConfigField) func(text string) string {
+	return func(text string) string {
+		if len(fieldCfg.Format) > 0 {
+			text = fmt.Sprintf(fieldCfg.Format, text)
+		}
+
+		return fieldCfg.Prefix + text + fieldCfg.Suffix
+	}
+}
+
+// bindAffixes wraps the emit function already bound for the field, so that the generated values are wrapped with
+// static text, like `i-%s` for instance ids or `sha256:` for hashes, without changing the template. The value becomes
+// text, and with `array_length` each value of the array is wrapped.
+func bindAffixes(fieldCfg ConfigField, field Field, fieldMap map[string]any, withReturn bool) error {
+	if err := fieldCfg.ValidAffixes(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if field.Type == FieldTypeDate {
+		return fmt.Errorf("field %s: `prefix`, `suffix` and `format` are not supported for field type %s", field.Name, field.Type)
+	}
+
+	affixesF := makeAffixesFunc(fieldCfg)
+
+	if withReturn {
+		boundF := fieldMap[field.Name].(emitF)
+
+		var emitF emitF
+		emitF = func(state *genState) any {
+			return affixesF(fmt.Sprint(boundF(state)))
+		}
+
+		fieldMap[field.Name] = emitF
+		return nil
+	}
+
+	boundF := fieldMap[field.Name].(emitFNotReturn)
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		var tmp bytes.Buffer
+		if err := boundF(state, &tmp); err != nil {
+			return err
+		}
+
+		buf.WriteString(affixesF(tmp.String()))
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
@@ -0,0 +1,278 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var arrayNotJSON = errors.New("array field value is not a JSON array")
+
+// isJSONLiteralFieldType reports whether the values of the field type are written as JSON literals rather than strings
+func isJSONLiteralFieldType(fieldType string) bool {
+	switch fieldType {
+	case FieldTypeBool, FieldTypeByte, FieldTypeShort, FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong:
+		return true
+	default:
+		return isFloatFieldType(fieldType)
+	}
+}
+
+// arrayLength returns the number of values to generate for an array field in the current event
+func arrayLength(fieldCfg ConfigField, state *genState) int {
+	n := fieldCfg.ArrayLength.Min
+	if fieldCfg.ArrayLength.Max > n {
+		n += state.rand.Intn(fieldCfg.ArrayLength.Max - n + 1)
+	}
+
+	return n
+}
+
+// arrayDistinctMaxMisses is the number of duplicate values in a row after which an array with `distinct` is
+// left shorter than its length, as the field has not enough distinct values
+const arrayDistinctMaxMisses = 1000
+
+// arrayDistinctLength returns the number of values an array with `distinct` can have at most: the distinct
+// values of the `enum` and of `values_from`, when the field has an `enum`.
+func arrayDistinctLength(fieldCfg ConfigField) int {
+	if len(fieldCfg.Enum) == 0 {
+		return math.MaxInt
+	}
+
+	return len(fieldCfg.Enum) + len(fieldCfg.ArrayLength.ValuesFrom)
+}
+
+// bindArray wraps the emit function already bound for the field, so that it generates an array of values
+// with a length in the `array_length` range: with the custom template the array is written as JSON.
+// The array starts with the values of the current event returned by valuesFromF, the fields of `values_from`.
+func bindArray(fieldCfg ConfigField, field Field, fieldMap map[string]any, valuesFromF []func(state *genState) (any, error), withReturn bool) error {
+	if err := fieldCfg.ValidArrayLength(); err != nil {
+		return err
+	}
+
+	// the values are drawn until they are distinct: the `enum` values are then picked with their weights
+	// without replacement
+	distinct := fieldCfg.ArrayLength.Distinct || len(valuesFromF) > 0
+	maxLength := arrayDistinctLength(fieldCfg)
+
+	if withReturn {
+		boundF := fieldMap[field.Name].(emitF)
+
+		var emitF emitF
+		emitF = func(state *genState) any {
+			n := arrayLength(fieldCfg, state)
+			if !distinct {
+				values := make([]any, n)
+				for i := range values {
+					values[i] = boundF(state)
+				}
+
+				return values
+			}
+
+			if n > maxLength {
+				n = maxLength
+			}
+
+			values := make([]any, 0, n)
+			seen := make(map[string]struct{}, n)
+			add := func(value any) bool {
+				key := fmt.Sprint(value)
+				if _, ok := seen[key]; ok {
+					return false
+				}
+
+				seen[key] = struct{}{}
+				values = append(values, value)
+				return true
+			}
+
+			for _, valueF := range valuesFromF {
+				value, err := valueF(state)
+				if err != nil {
+					panic(err)
+				}
+
+				add(value)
+			}
+
+			for misses := 0; len(values) < n && misses < arrayDistinctMaxMisses; {
+				if add(boundF(state)) {
+					misses = 0
+				} else {
+					misses++
+				}
+			}
+
+			return values
+		}
+
+		fieldMap[field.Name] = emitF
+		return nil
+	}
+
+	boundF := fieldMap[field.Name].(emitFNotReturn)
+	literal := isJSONLiteralFieldType(field.Type)
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		v := state.pool.Get()
+		tmp := v.(*bytes.Buffer)
+		defer state.pool.Put(tmp)
+
+		n := arrayLength(fieldCfg, state)
+		written := 0
+		write := func(value []byte) error {
+			if written > 0 {
+				buf.WriteByte(',')
+			}
+
+			written++
+			if literal {
+				buf.Write(value)
+				return nil
+			}
+
+			quoted, err := json.Marshal(string(value))
+			if err != nil {
+				return err
+			}
+
+			buf.Write(quoted)
+			return nil
+		}
+
+		buf.WriteByte('[')
+		if !distinct {
+			for i := 0; i < n; i++ {
+				tmp.Reset()
+				if err := boundF(state, tmp); err != nil {
+					return err
+				}
+
+				if err := write(tmp.Bytes()); err != nil {
+					return err
+				}
+			}
+
+			buf.WriteByte(']')
+			return nil
+		}
+
+		if n > maxLength {
+			n = maxLength
+		}
+
+		seen := make(map[string]struct{}, n)
+		add := func(value []byte) (bool, error) {
+			if _, ok := seen[string(value)]; ok {
+				return false, nil
+			}
+
+			seen[string(value)] = struct{}{}
+			return true, write(value)
+		}
+
+		for _, valueF := range valuesFromF {
+			value, err := valueF(state)
+			if err != nil {
+				return err
+			}
+
+			if _, err := add([]byte(fmt.Sprint(value))); err != nil {
+				return err
+			}
+		}
+
+		for misses := 0; written < n && misses < arrayDistinctMaxMisses; {
+			tmp.Reset()
+			if err := boundF(state, tmp); err != nil {
+				return err
+			}
+
+			added, err := add(tmp.Bytes())
+			if err != nil {
+				return err
+			}
+
+			if added {
+				misses = 0
+			} else {
+				misses++
+			}
+		}
+
+		buf.WriteByte(']')
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
+
+// bindArrayValuesFromFields wraps the emit functions of the fields with `array_length.values_from` in arrays
+// starting with the values of the listed fields in the same event, like `related.ip` holding `source.ip` and
+// `destination.ip`, completed with distinct values of the field up to the array length.
+func bindArrayValuesFromFields(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok || fieldCfg.ArrayLength == nil || len(fieldCfg.ArrayLength.ValuesFrom) == 0 {
+			continue
+		}
+
+		if _, ok := fieldMap[field.Name]; !ok {
+			continue
+		}
+
+		valuesFromF := make([]func(state *genState) (any, error), 0, len(fieldCfg.ArrayLength.ValuesFrom))
+		for _, fromField := range fieldCfg.ArrayLength.ValuesFrom {
+			if _, ok := fieldMap[fromField]; !ok {
+				return fmt.Errorf("field %s: field %s of `array_length.values_from` not present in fields definition", field.Name, fromField)
+			}
+
+			valueF, err := bindEventRawValue(fromField, fieldMap, withReturn)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+
+			valuesFromF = append(valuesFromF, valueF)
+		}
+
+		if err := bindArray(fieldCfg, field, fieldMap, valuesFromF, withReturn); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// decodeArray returns the values of an array written by an array field, as they would be written by the field
+func decodeArray(value []byte) ([][]byte, error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(value, &elements); err != nil {
+		return nil, arrayNotJSON
+	}
+
+	values := make([][]byte, 0, len(elements))
+	for _, element := range elements {
+		if len(element) > 0 && element[0] == '"' {
+			var s string
+			if err := json.Unmarshal(element, &s); err != nil {
+				return nil, err
+			}
+
+			values = append(values, []byte(s))
+			continue
+		}
+
+		values = append(values, element)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,184 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strconv"
+)
+
+// autonomousSystem is an entry of the built-in table of the `asn_of` fields: its number, the name of its
+// organization as in the GeoLite2 ASN database, and some of the networks it announces
+type autonomousSystem struct {
+	number       int64
+	organization string
+	networks     []string
+}
+
+// autonomousSystems are well known autonomous systems of cloud providers, CDNs and ISPs
+var autonomousSystems = []autonomousSystem{
+	{number: 15169, organization: "GOOGLE", networks: []string{"8.8.4.0/24", "8.8.8.0/24", "142.250.0.0/15", "172.217.0.0/16", "2001:4860::/32"}},
+	{number: 16509, organization: "AMAZON-02", networks: []string{"13.32.0.0/15", "54.230.0.0/16", "2600:9000::/28"}},
+	{number: 8075, organization: "MICROSOFT-CORP-MSN-AS-BLOCK", networks: []string{"13.64.0.0/11", "40.76.0.0/14"}},
+	{number: 13335, organization: "CLOUDFLARENET", networks: []string{"1.1.1.0/24", "104.16.0.0/13", "172.64.0.0/13", "2606:4700::/32"}},
+	{number: 32934, organization: "FACEBOOK", networks: []string{"31.13.24.0/21", "157.240.0.0/16", "2a03:2880::/32"}},
+	{number: 20940, organization: "Akamai International B.V.", networks: []string{"2.16.0.0/13"}},
+	{number: 54113, organization: "FASTLY", networks: []string{"151.101.0.0/16", "2a04:4e40::/32"}},
+	{number: 14061, organization: "DIGITALOCEAN-ASN", networks: []string{"104.131.0.0/16", "159.203.0.0/16", "2604:a880::/32"}},
+	{number: 16276, organization: "OVH SAS", networks: []string{"51.68.0.0/16", "2001:41d0::/32"}},
+	{number: 24940, organization: "Hetzner Online GmbH", networks: []string{"88.198.0.0/16", "136.243.0.0/16", "2a01:4f8::/29"}},
+	{number: 7922, organization: "COMCAST-7922", networks: []string{"73.0.0.0/8", "2601::/20"}},
+	{number: 7018, organization: "ATT-INTERNET4", networks: []string{"12.0.0.0/8"}},
+	{number: 3320, organization: "Deutsche Telekom AG", networks: []string{"79.192.0.0/10", "2003::/19"}},
+	{number: 3215, organization: "Orange", networks: []string{"90.0.0.0/9", "2a01:c000::/19"}},
+	{number: 2856, organization: "British Telecommunications PLC", networks: []string{"86.128.0.0/10"}},
+	{number: 4134, organization: "Chinanet", networks: []string{"116.224.0.0/12", "240e::/20"}},
+	{number: 4837, organization: "CHINA UNICOM China169 Backbone", networks: []string{"123.112.0.0/12"}},
+	{number: 17676, organization: "SoftBank Corp.", networks: []string{"126.0.0.0/8"}},
+}
+
+// autonomousSystemNetworks are the parsed networks of autonomousSystems, with the index of their entry
+var autonomousSystemNetworks = parseAutonomousSystemNetworks()
+
+type autonomousSystemNetwork struct {
+	network *net.IPNet
+	index   int
+}
+
+func parseAutonomousSystemNetworks() []autonomousSystemNetwork {
+	var networks []autonomousSystemNetwork
+	for i, as := range autonomousSystems {
+		for _, cidr := range as.networks {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				panic(err)
+			}
+
+			networks = append(networks, autonomousSystemNetwork{network: network, index: i})
+		}
+	}
+
+	return networks
+}
+
+// autonomousSystemOf returns the autonomous system of the ip: the one announcing the most specific network of the
+// table containing it, or else the one the hash of the /16 network of an IPv4 address, or of the /32 network of an
+// IPv6 one, picks, so that the addresses of the same network always belong to the same autonomous system.
+func autonomousSystemOf(ip net.IP) autonomousSystem {
+	best, bestOnes := -1, -1
+	for _, candidate := range autonomousSystemNetworks {
+		if ones, _ := candidate.network.Mask.Size(); candidate.network.Contains(ip) && ones > bestOnes {
+			best, bestOnes = candidate.index, ones
+		}
+	}
+
+	if best >= 0 {
+		return autonomousSystems[best]
+	}
+
+	network := ip.Mask(net.CIDRMask(32, 128))
+	if ip4 := ip.To4(); ip4 != nil {
+		network = ip4.Mask(net.CIDRMask(16, 32))
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(network)
+	return autonomousSystems[h.Sum64()%uint64(len(autonomousSystems))]
+}
+
+// bindASNFields replaces the emit functions of the fields with `asn_of`, so that they are the number, for the
+// numeric fields, or the organization name, for the keyword ones, of the autonomous system of the ip field of the
+// event, like `source.as.number` and `source.as.organization.name` of `source.ip`.
+func bindASNFields(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	fieldsByName := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		fieldsByName[field.Name] = field
+	}
+
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok || len(fieldCfg.ASNOf) == 0 {
+			continue
+		}
+
+		if err := fieldCfg.ValidASN(); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		isNumber := isIntegerFieldType(field.Type)
+		if !isNumber && field.Type != FieldTypeKeyword && field.Type != FieldTypeWildcard {
+			return fmt.Errorf("field %s: `asn_of` is not supported for field type %s", field.Name, field.Type)
+		}
+
+		ipField, ok := fieldsByName[fieldCfg.ASNOf]
+		if _, bound := fieldMap[fieldCfg.ASNOf]; !ok || !bound {
+			return fmt.Errorf("field %s: ip field %s not present in fields definition", field.Name, fieldCfg.ASNOf)
+		}
+
+		if ipField.Type != FieldTypeIP {
+			return fmt.Errorf("field %s: ip field %s must have the %s type", field.Name, ipField.Name, FieldTypeIP)
+		}
+
+		ipF, err := bindEventRawValue(fieldCfg.ASNOf, fieldMap, withReturn)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		asF := func(state *genState) (autonomousSystem, error) {
+			value, err := ipF(state)
+			if err != nil {
+				return autonomousSystem{}, err
+			}
+
+			ip := net.ParseIP(fmt.Sprint(value))
+			if ip == nil {
+				return autonomousSystem{}, fmt.Errorf("cannot use %v as ip", value)
+			}
+
+			return autonomousSystemOf(ip), nil
+		}
+
+		if withReturn {
+			var emitF emitF
+			emitF = func(state *genState) any {
+				as, err := asF(state)
+				if err != nil {
+					panic(err)
+				}
+
+				if isNumber {
+					return as.number
+				}
+
+				return as.organization
+			}
+
+			fieldMap[field.Name] = emitF
+		} else {
+			var emitFNotReturn emitFNotReturn
+			emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+				as, err := asF(state)
+				if err != nil {
+					return err
+				}
+
+				if isNumber {
+					buf.Write(strconv.AppendInt(make([]byte, 0, 16), as.number, 10))
+				} else {
+					buf.WriteString(as.organization)
+				}
+
+				return nil
+			}
+
+			fieldMap[field.Name] = emitFNotReturn
+		}
+	}
+
+	return nil
+}
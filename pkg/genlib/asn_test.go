@@ -0,0 +1,34 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAutonomousSystemOf(t *testing.T) {
+	testCases := []struct {
+		ip       string
+		expected int64
+	}{
+		{ip: "8.8.8.8", expected: 15169},
+		{ip: "142.251.36.14", expected: 15169},
+		{ip: "1.1.1.1", expected: 13335},
+		{ip: "2a03:2880:f10c:83:face:b00c:0:25de", expected: 32934},
+		{ip: "151.101.1.69", expected: 54113},
+	}
+
+	for _, testCase := range testCases {
+		if got := autonomousSystemOf(net.ParseIP(testCase.ip)); got.number != testCase.expected {
+			t.Errorf("Expected %s to belong to AS%d, got AS%d", testCase.ip, testCase.expected, got.number)
+		}
+	}
+
+	// an address out of the table belongs to the autonomous system of its network
+	if a, b := autonomousSystemOf(net.ParseIP("192.168.1.1")), autonomousSystemOf(net.ParseIP("192.168.200.7")); a.number != b.number {
+		t.Errorf("Expected the addresses of 192.168.0.0/16 to belong to the same autonomous system, got AS%d and AS%d", a.number, b.number)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"io"
+)
+
+// emitBatch renders up to n events with emit, one after the other in buf, and returns them as slices of buf.
+// The slices are taken once all the events are rendered, since buf can grow, and so move, while rendering.
+func emitBatch(buf *bytes.Buffer, n int, emit func(buf *bytes.Buffer) error) ([][]byte, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	offsets := make([]int, 1, n+1)
+	offsets[0] = buf.Len()
+
+	var err error
+	for len(offsets) <= n {
+		if err = emit(buf); err != nil {
+			buf.Truncate(offsets[len(offsets)-1])
+			break
+		}
+
+		offsets = append(offsets, buf.Len())
+	}
+
+	events := make([][]byte, 0, len(offsets)-1)
+	rendered := buf.Bytes()
+	for i := 1; i < len(offsets); i++ {
+		events = append(events, rendered[offsets[i-1]:offsets[i]:offsets[i]])
+	}
+
+	if err == io.EOF && len(events) > 0 {
+		err = nil
+	}
+
+	return events, err
+}
@@ -0,0 +1,93 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"fmt"
+	"math"
+)
+
+// halfFloatMax is the largest value a half_float field can hold
+const halfFloatMax = 65504
+
+// TypeBoundsError reports a bound of the `range` of a field config outside the values its type can hold
+type TypeBoundsError struct {
+	Field string
+	Type  string
+	// Bound is the bound of the range, `min` or `max`
+	Bound string
+	Value float64
+	// TypeMin and TypeMax are the bounds of the field type
+	TypeMin float64
+	TypeMax float64
+}
+
+func (e TypeBoundsError) Error() string {
+	return fmt.Sprintf("field %s: range %s %v is outside the bounds of the %s type [%v, %v]", e.Field, e.Bound, e.Value, e.Type, e.TypeMin, e.TypeMax)
+}
+
+// getFloatTypeBounds returns the min and max values for a given float field type: the values of a scaled_float
+// are stored as a long, multiplied by the scaling factor
+func getFloatTypeBounds(field Field) (min float64, max float64) {
+	switch field.Type {
+	case FieldTypeHalfFloat:
+		return -halfFloatMax, halfFloatMax
+	case FieldTypeFloat:
+		return -math.MaxFloat32, math.MaxFloat32
+	case FieldTypeScaledFloat:
+		if field.ScalingFactor > 0 {
+			return math.MinInt64 / field.ScalingFactor, math.MaxInt64 / field.ScalingFactor
+		}
+
+		return math.MinInt64, math.MaxInt64
+	default:
+		return -math.MaxFloat64, math.MaxFloat64
+	}
+}
+
+// getTypeBounds returns the min and max values the field type can hold, and whether it's a numeric type
+func getTypeBounds(field Field) (min float64, max float64, ok bool) {
+	switch {
+	case field.Type == FieldTypeUnsignedLong:
+		// the generated values are capped by getIntTypeBounds, but the type can hold them all
+		return 0, math.MaxUint64, true
+	case isIntegerFieldType(field.Type):
+		typeMin, typeMax := getIntTypeBounds(field.Type)
+		return float64(typeMin), float64(typeMax), true
+	case isFloatFieldType(field.Type):
+		typeMin, typeMax := getFloatTypeBounds(field)
+		return typeMin, typeMax, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// ValidateTypeBounds checks the `range` of the config of the numeric fields against the values their type can hold,
+// returning a TypeBoundsError for each bound outside them. The generator clamps such bounds to the ones of the type,
+// so that the generated values are always valid, but the data generated is not what the config asks for.
+func ValidateTypeBounds(cfg Config, fields Fields) []error {
+	var errs []error
+	for _, field := range fields {
+		typeMin, typeMax, ok := getTypeBounds(field)
+		if !ok {
+			continue
+		}
+
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok {
+			continue
+		}
+
+		if minValue, err := fieldCfg.Range.MinAsFloat64(); err == nil && (minValue < typeMin || minValue > typeMax) {
+			errs = append(errs, TypeBoundsError{Field: field.Name, Type: field.Type, Bound: "min", Value: minValue, TypeMin: typeMin, TypeMax: typeMax})
+		}
+
+		if maxValue, err := fieldCfg.Range.MaxAsFloat64(); err == nil && (maxValue < typeMin || maxValue > typeMax) {
+			errs = append(errs, TypeBoundsError{Field: field.Name, Type: field.Type, Bound: "max", Value: maxValue, TypeMin: typeMin, TypeMax: typeMax})
+		}
+	}
+
+	return errs
+}
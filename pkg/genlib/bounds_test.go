@@ -0,0 +1,109 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+func Test_ValidateTypeBounds(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		field    Field
+		config   string
+		bounds   []string
+	}{
+		{
+			scenario: "byte in bounds",
+			field:    Field{Name: "field", Type: FieldTypeByte},
+			config:   "fields:\n  - name: field\n    range:\n      min: -128\n      max: 127",
+		},
+		{
+			scenario: "byte out of bounds",
+			field:    Field{Name: "field", Type: FieldTypeByte},
+			config:   "fields:\n  - name: field\n    range:\n      min: -129\n      max: 128",
+			bounds:   []string{"min", "max"},
+		},
+		{
+			scenario: "unsigned_long negative",
+			field:    Field{Name: "field", Type: FieldTypeUnsignedLong},
+			config:   "fields:\n  - name: field\n    range:\n      min: -1\n      max: 18446744073709551615",
+			bounds:   []string{"min"},
+		},
+		{
+			scenario: "half_float out of bounds",
+			field:    Field{Name: "field", Type: FieldTypeHalfFloat},
+			config:   "fields:\n  - name: field\n    range:\n      min: 0\n      max: 70000",
+			bounds:   []string{"max"},
+		},
+		{
+			scenario: "scaled_float out of bounds",
+			field:    Field{Name: "field", Type: FieldTypeScaledFloat, ScalingFactor: 1000},
+			config:   "fields:\n  - name: field\n    range:\n      min: -1e16\n      max: 1e15",
+			bounds:   []string{"min"},
+		},
+		{
+			scenario: "keyword not checked",
+			field:    Field{Name: "field", Type: FieldTypeKeyword},
+			config:   "fields:\n  - name: field\n    range:\n      min: -1e300",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := config.LoadConfigFromYaml([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			errs := ValidateTypeBounds(cfg, Fields{testCase.field})
+			if len(errs) != len(testCase.bounds) {
+				t.Fatalf("Expected %d errors, got %v", len(testCase.bounds), errs)
+			}
+
+			for i, err := range errs {
+				var boundsErr TypeBoundsError
+				if !errors.As(err, &boundsErr) {
+					t.Fatalf("Expected a TypeBoundsError, got %v", err)
+				}
+
+				if boundsErr.Bound != testCase.bounds[i] {
+					t.Errorf("Expected error on %s, got %v", testCase.bounds[i], err)
+				}
+			}
+		})
+	}
+}
+
+func Test_FieldHalfFloatClampedToTypeBounds(t *testing.T) {
+	fld := Field{Name: "alpha", Type: FieldTypeHalfFloat}
+
+	template := []byte(`{"alpha":{{.alpha}}}`)
+	cfg, err := config.LoadConfigFromYaml([]byte("fields:\n  - name: alpha\n    range:\n      min: 60000\n      max: 1e9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithCustomTemplate(t, cfg, []Field{fld}, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		buf.Reset()
+
+		if m["alpha"] < 60000 || m["alpha"] > halfFloatMax {
+			t.Errorf("Expected value between 60000 and %d, got %v", halfFloatMax, m["alpha"])
+		}
+	}
+}
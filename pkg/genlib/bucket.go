@@ -0,0 +1,285 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// bucketCursor holds the slot of the last event generated for a `bucket` field
+type bucketCursor struct {
+	initialised bool
+	counter     uint64
+	slot        uint64
+	// the interval each offline entity is back online at
+	offlineUntil map[uint64]uint64
+}
+
+// bucketLayout lays out the documents of the intervals of a `bucket` field in slots: each interval holds the
+// documents of all the entities, cycling through them, and the slot of an event is the index of its document
+type bucketLayout struct {
+	fieldName string
+	entities  uint64
+	docs      uint64
+	gaps      *config.Gaps
+}
+
+func (l bucketLayout) perBucket() uint64 {
+	return l.entities * l.docs
+}
+
+// slot returns the slot of the current event, skipping the slots of the entities offline
+func (l bucketLayout) slot(state *genState) uint64 {
+	cursor, ok := state.prevCacheBucket[l.fieldName]
+	if !ok {
+		cursor = &bucketCursor{offlineUntil: make(map[uint64]uint64)}
+		state.prevCacheBucket[l.fieldName] = cursor
+	}
+
+	if cursor.initialised && cursor.counter == state.counter {
+		return cursor.slot
+	}
+
+	var next uint64
+	if cursor.initialised {
+		next = cursor.slot + 1
+	}
+
+	for !l.online(cursor, next, state) {
+		next++
+	}
+
+	cursor.initialised = true
+	cursor.counter = state.counter
+	cursor.slot = next
+	return next
+}
+
+// online reports whether the entity of the slot is online: the gap of an entity is rolled at its first document
+// of an interval, and lasts a random number of intervals
+func (l bucketLayout) online(cursor *bucketCursor, slot uint64, state *genState) bool {
+	if l.gaps == nil {
+		return true
+	}
+
+	bucket := slot / l.perBucket()
+	entity := slot % l.entities
+	if until, ok := cursor.offlineUntil[entity]; ok && bucket <= until {
+		// an entity back online stays online for at least an interval
+		return bucket == until
+	}
+
+	if (slot%l.perBucket())/l.entities != 0 || state.rand.Float64() >= l.gaps.Probability {
+		return true
+	}
+
+	minGap, maxGap := l.gaps.MinOrDefault(), l.gaps.MaxOrDefault()
+	cursor.offlineUntil[entity] = bucket + uint64(minGap+state.rand.Intn(maxGap-minGap+1))
+	return false
+}
+
+// bindBucketFields replaces the emit functions of the date fields with `bucket`, so that each interval gets exactly
+// the configured number of documents, for each entity when set, but for the gaps: the entity field is bound to cycle
+// through its values, and the timestamps of the documents of an entity in the same interval are evenly spread over it.
+func bindBucketFields(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	fieldsByName := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		fieldsByName[field.Name] = field
+	}
+
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok || fieldCfg.Bucket == nil {
+			continue
+		}
+
+		if err := fieldCfg.ValidBucket(); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		if field.Type != FieldTypeDate {
+			return fmt.Errorf("field %s: `bucket` requires the %s field type", field.Name, FieldTypeDate)
+		}
+
+		layout := bucketLayout{
+			fieldName: field.Name,
+			entities:  1,
+			docs:      uint64(fieldCfg.Bucket.DocsOrDefault()),
+			gaps:      fieldCfg.Bucket.Gaps,
+		}
+
+		if len(fieldCfg.Bucket.Entity) > 0 {
+			entityField, ok := fieldsByName[fieldCfg.Bucket.Entity]
+			if _, bound := fieldMap[fieldCfg.Bucket.Entity]; !ok || !bound {
+				return fmt.Errorf("field %s: entity field %s not present in fields definition", field.Name, fieldCfg.Bucket.Entity)
+			}
+
+			var err error
+			layout.entities, err = bindBucketEntity(cfg, entityField, layout, fieldMap, withReturn)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+
+		from, err := fieldCfg.Range.FromAsTime()
+		hasFrom := err == nil
+
+		interval := fieldCfg.Bucket.Interval
+		bucketTime := func(state *genState) time.Time {
+			start := timeNowToBind
+			if hasFrom {
+				start = from
+			}
+
+			slot := layout.slot(state)
+			bucket := slot / layout.perBucket()
+			// the index of the document of the entity in the interval
+			doc := (slot % layout.perBucket()) / layout.entities
+
+			return start.Add(time.Duration(bucket)*interval + interval*time.Duration(doc)/time.Duration(layout.docs))
+		}
+
+		if withReturn {
+			var emitF emitF
+			emitF = func(state *genState) any {
+				return bucketTime(state)
+			}
+
+			fieldMap[field.Name] = emitF
+			continue
+		}
+
+		var emitFNotReturn emitFNotReturn
+		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+			buf.WriteString(bucketTime(state).Format(FieldTypeTimeLayout))
+			return nil
+		}
+
+		fieldMap[field.Name] = emitFNotReturn
+	}
+
+	return nil
+}
+
+// bindBucketEntity binds the entity field again to take the value of the entity of the slot of the event, and
+// returns the number of entities: the values of fields with `enum` are its entries, the ones of fields with
+// `cardinality` are generated the first time their slot comes, and kept.
+func bindBucketEntity(cfg Config, entityField Field, layout bucketLayout, fieldMap map[string]any, withReturn bool) (uint64, error) {
+	entityCfg, _ := cfg.GetField(entityField.Name)
+
+	var entities uint64
+	var valueF func(state *genState, idx uint64) (any, error)
+	switch {
+	case len(entityCfg.Enum) > 0:
+		entities = uint64(len(entityCfg.Enum))
+		valueF = func(_ *genState, idx uint64) (any, error) {
+			return entityCfg.Enum[idx].Value, nil
+		}
+	case entityCfg.Cardinality > 0:
+		entities = uint64(entityCfg.Cardinality)
+
+		boundMap := make(map[string]any)
+		var err error
+		if withReturn {
+			err = bindByTypeWithReturn(cfg, entityField, boundMap)
+		} else {
+			err = bindByType(cfg, entityField, boundMap)
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		valueF = makeBucketEntityValueFunc(entityField.Name, boundMap[entityField.Name])
+	default:
+		return 0, fmt.Errorf("entity field %s must define `cardinality` or `enum`", entityField.Name)
+	}
+
+	layout.entities = entities
+	entityValueF := func(state *genState) (any, error) {
+		return valueF(state, layout.slot(state)%entities)
+	}
+
+	if withReturn {
+		var emitF emitF
+		emitF = func(state *genState) any {
+			value, err := entityValueF(state)
+			if err != nil {
+				panic(err)
+			}
+
+			return value
+		}
+
+		fieldMap[entityField.Name] = emitF
+		return entities, nil
+	}
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		value, err := entityValueF(state)
+		if err != nil {
+			return err
+		}
+
+		switch v := value.(type) {
+		case []byte:
+			buf.Write(v)
+		default:
+			buf.WriteString(fmt.Sprint(v))
+		}
+
+		return nil
+	}
+
+	fieldMap[entityField.Name] = emitFNotReturn
+	return entities, nil
+}
+
+// makeBucketEntityValueFunc returns the value of the entity at an index, generating the values up to it if needed,
+// as `cardinality` does: a few tries are made to generate a value not generated yet
+func makeBucketEntityValueFunc(fieldName string, boundF any) func(state *genState, idx uint64) (any, error) {
+	generate := func(state *genState) (any, any, error) {
+		if emitF, ok := boundF.(emitF); ok {
+			value := emitF(state)
+			return value, value, nil
+		}
+
+		var tmp bytes.Buffer
+		if err := boundF.(emitFNotReturn)(state, &tmp); err != nil {
+			return nil, nil, err
+		}
+
+		// the custom template generator checks the dupes by the string of the value
+		return tmp.Bytes(), tmp.String(), nil
+	}
+
+	return func(state *genState, idx uint64) (any, error) {
+		for uint64(len(state.prevCacheCardinality[fieldName])) <= idx {
+			nTries := 11
+			var value, key any
+			for i := 0; i < nTries; i++ {
+				var err error
+				value, key, err = generate(state)
+				if err != nil {
+					return nil, err
+				}
+
+				if !isDupeAny(state.prevCacheForDup[fieldName], key) {
+					break
+				}
+			}
+
+			state.prevCacheForDup[fieldName][dupKey(key)] = struct{}{}
+			state.prevCacheCardinality[fieldName] = append(state.prevCacheCardinality[fieldName], value)
+		}
+
+		return state.prevCacheCardinality[fieldName][idx], nil
+	}
+}
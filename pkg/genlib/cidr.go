@@ -0,0 +1,211 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"net"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// defaultIPv4Network and defaultIPv6Network are the networks of the `ip` values without `cidr`: the whole IPv4
+// space and the global unicast IPv6 space
+const defaultIPv4Network = "0.0.0.0/0"
+const defaultIPv6Network = "2000::/3"
+
+// reservedNetworks are the special-purpose IP ranges of the IANA registries, like loopback, link-local, private
+// or multicast ones, not overlapping each other
+var reservedNetworks = mustParseCIDRs(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.0.0.0/24",
+	"192.0.2.0/24", "192.88.99.0/24", "192.168.0.0/16", "198.18.0.0/15", "198.51.100.0/24", "203.0.113.0/24",
+	"224.0.0.0/4", "240.0.0.0/4",
+	"::/127", "::ffff:0:0/96", "64:ff9b::/96", "100::/64", "2001::/23", "2001:db8::/32", "2002::/16", "fc00::/7",
+	"fe80::/10", "ff00::/8",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+// ipNetwork is a network the IPs are generated within, avoiding the excluded networks inside it
+type ipNetwork struct {
+	network  *net.IPNet
+	excluded []*net.IPNet
+}
+
+// isCIDRField reports whether the `ip` values are shaped by `cidr`, `exclude_reserved` or `ipv6_fraction`
+func isCIDRField(fieldCfg ConfigField) bool {
+	return len(fieldCfg.CIDR) > 0 || fieldCfg.ExcludeReserved || fieldCfg.IPv6Fraction != nil
+}
+
+// makeIPFunc returns the function generating the `ip` values: within the networks of `cidr`, each picked with
+// probability proportional to its weight, or within the IPv4 and IPv6 spaces, with `ipv6_fraction` of IPv6 ones
+func makeIPFunc(fieldCfg ConfigField) (func(r *rand.Rand) string, error) {
+	if !isCIDRField(fieldCfg) {
+		return func(r *rand.Rand) string {
+			i0, i1, i2, i3 := randIP(r)
+			return fmt.Sprintf("%d.%d.%d.%d", i0, i1, i2, i3)
+		}, nil
+	}
+
+	if err := fieldCfg.ValidCIDR(); err != nil {
+		return nil, err
+	}
+
+	var ipv4, ipv6 config.Enum
+	for _, network := range fieldCfg.CIDR {
+		_, ipNet, _ := net.ParseCIDR(network.Value)
+		if len(ipNet.IP) == net.IPv4len {
+			ipv4 = append(ipv4, network)
+		} else {
+			ipv6 = append(ipv6, network)
+		}
+	}
+
+	if len(fieldCfg.CIDR) == 0 {
+		ipv4 = config.Enum{{Value: defaultIPv4Network, Weight: 1}}
+		ipv6 = config.Enum{{Value: defaultIPv6Network, Weight: 1}}
+		if fieldCfg.IPv6Fraction == nil {
+			ipv6 = nil
+		}
+	}
+
+	if fieldCfg.IPv6Fraction == nil {
+		// the networks of `cidr` are picked by their weights only
+		networkF, err := makeNetworksFunc(append(ipv4, ipv6...), fieldCfg.ExcludeReserved)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(r *rand.Rand) string {
+			return networkF(r).String()
+		}, nil
+	}
+
+	fraction := *fieldCfg.IPv6Fraction
+	if fraction > 0 && len(ipv6) == 0 {
+		return nil, fmt.Errorf("`ipv6_fraction` is greater than 0 but `cidr` has no IPv6 network")
+	}
+
+	if fraction < 1 && len(ipv4) == 0 {
+		return nil, fmt.Errorf("`ipv6_fraction` is less than 1 but `cidr` has no IPv4 network")
+	}
+
+	var ipv4F, ipv6F func(r *rand.Rand) net.IP
+	var err error
+	if len(ipv4) > 0 {
+		if ipv4F, err = makeNetworksFunc(ipv4, fieldCfg.ExcludeReserved); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ipv6) > 0 {
+		if ipv6F, err = makeNetworksFunc(ipv6, fieldCfg.ExcludeReserved); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(r *rand.Rand) string {
+		if fraction >= 1 || fraction > 0 && r.Float64() < fraction {
+			return ipv6F(r).String()
+		}
+
+		return ipv4F(r).String()
+	}, nil
+}
+
+// makeNetworksFunc returns the function generating IPs within the networks, each picked with probability
+// proportional to its weight: with excludeReserved the reserved networks inside them are avoided, while the
+// reserved networks containing them are not, so that `10.0.0.0/8` still generates private IPs
+func makeNetworksFunc(networks config.Enum, excludeReserved bool) (func(r *rand.Rand) net.IP, error) {
+	ipNetworks := make([]ipNetwork, 0, len(networks))
+	for _, network := range networks {
+		_, ipNet, _ := net.ParseCIDR(network.Value)
+
+		n := ipNetwork{network: ipNet}
+		if excludeReserved {
+			n.excluded = reservedNetworksWithin(ipNet)
+			if coversNetwork(ipNet, n.excluded) {
+				return nil, fmt.Errorf("`cidr` network %s has only reserved IPs, with `exclude_reserved`", network.Value)
+			}
+		}
+
+		ipNetworks = append(ipNetworks, n)
+	}
+
+	networkF := makeEnumFunc(networks)
+	return func(r *rand.Rand) net.IP {
+		n := ipNetworks[networkF(r)]
+		for {
+			ip := randIPWithin(r, n.network)
+			if !containedInAny(ip, n.excluded) {
+				return ip
+			}
+		}
+	}, nil
+}
+
+// randIPWithin returns a random IP within the network
+func randIPWithin(r *rand.Rand, network *net.IPNet) net.IP {
+	ip := make(net.IP, len(network.IP))
+	for i := range ip {
+		ip[i] = network.IP[i]&network.Mask[i] | byte(r.Intn(256))&^network.Mask[i]
+	}
+
+	return ip
+}
+
+// reservedNetworksWithin returns the reserved networks strictly inside the network, of the same IP version
+func reservedNetworksWithin(network *net.IPNet) []*net.IPNet {
+	ones, bits := network.Mask.Size()
+
+	var within []*net.IPNet
+	for _, reserved := range reservedNetworks {
+		reservedOnes, reservedBits := reserved.Mask.Size()
+		if reservedBits == bits && reservedOnes > ones && network.Contains(reserved.IP) {
+			within = append(within, reserved)
+		}
+	}
+
+	return within
+}
+
+// coversNetwork reports whether the excluded networks, not overlapping each other, cover the whole network
+func coversNetwork(network *net.IPNet, excluded []*net.IPNet) bool {
+	networkSize := func(n *net.IPNet) *big.Int {
+		ones, bits := n.Mask.Size()
+		return new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	}
+
+	covered := new(big.Int)
+	for _, e := range excluded {
+		covered.Add(covered, networkSize(e))
+	}
+
+	return covered.Cmp(networkSize(network)) >= 0
+}
+
+func containedInAny(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,10 +2,16 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"math"
+	"net"
 	"os"
+	"path/filepath"
 
 	"github.com/elastic/go-ucfg/yaml"
 	"github.com/spf13/afero"
@@ -15,6 +21,58 @@ var rangeBoundNotSet = errors.New("range bound not set")
 var rangeTimeNotSet = errors.New("range time not set")
 var rangeInvalidConfig = errors.New("range defining both `period` and `from`/`to`")
 var counterInvalidConfig = errors.New("both `range` and `counter` defined")
+var counterRateInvalidConfig = errors.New("`counter_rate` must have a not negative `min` not greater than `max`, and can only be defined together with `counter` and without `fuzziness`")
+var constantInvalidConfig = errors.New("both `per_run_constant` and `per_batch_constant` defined")
+var cumulativeInvalidConfig = errors.New("`cumulative_of` defined together with `counter`, `value` or `enum`")
+var cumulativeEntityInvalidConfig = errors.New("`cumulative_entity` defined without `cumulative_of`")
+var hashInvalidConfig = errors.New("`hash_of` defined together with `value`, `cardinality`, `counter`, `fuzziness`, `cumulative_of`, `array_length` or a constant")
+var asnInvalidConfig = errors.New("`asn_of` defined together with `value`, `enum`, `cardinality`, `counter`, `fuzziness`, `cumulative_of`, `hash_of`, `array_length` or a constant")
+var orderInvalidConfig = errors.New("`order` must be one of 'strictly_increasing', 'increasing_per_entity', 'unordered'")
+var orderEntityInvalidConfig = errors.New("`order_entity` must be defined only with `order: increasing_per_entity`")
+var orderWithInvalidConfig = errors.New("`order` defined together with `value`, `cardinality`, `array_length` or a constant")
+var unicodeSaltInvalidConfig = errors.New("`unicode_salt` must be between 0 and 1")
+var bucketInvalidConfig = errors.New("`bucket` must have a positive `interval` and `docs` not negative")
+var bucketGapsInvalidConfig = errors.New("`bucket.gaps` must have `probability` between 0 and 1 (excluded), and `min` and `max` intervals with `max` not less than `min`")
+var bucketWithInvalidConfig = errors.New("`bucket` defined together with `period`, `range.to`, `value`, `cardinality`, `order`, `array_length` or a constant")
+var assertInvalidConfig = errors.New("`assert` bounds must be two values, the minimum and the maximum")
+var quantilesInvalidConfig = errors.New("`quantiles` must have at least two quantiles, as `p` followed by a number between 0 and 100 (excluded), with values not decreasing")
+var quantilesWithInvalidConfig = errors.New("`quantiles` defined together with `counter`, `fuzziness`, `enum` or `cumulative_of`")
+var distributionInvalidConfig = errors.New("`distribution` must be one of 'uniform', 'normal': 'normal' requires `mean` and a positive `stddev`, that are only allowed with it")
+var distributionWithInvalidConfig = errors.New("`distribution: normal` defined together with `counter`, `fuzziness`, `enum`, `quantiles`, `samples` or `cumulative_of`")
+var patternInvalidConfig = errors.New("`pattern` must have `peak_hours` from 0 to 23, not negative `peak_factor` and `weekend_factor`, and `seasonality` with an `amplitude` greater than 0 and less than 1, a positive `period` and a `peak` less than the `period`, and it cannot be defined together with `value`")
+var interArrivalInvalidConfig = errors.New("`inter_arrival` must have a `distribution` among 'exponential', 'fixed', 'lognormal' and a positive `mean`, with a positive `stddev` only for 'lognormal'")
+var interArrivalWithInvalidConfig = errors.New("`inter_arrival` defined together with `period`, `range.to`, `value`, `cardinality`, `order`, `bucket`, `array_length` or a constant")
+var quantilesRangeInvalidConfig = errors.New("`quantiles` values must be within `range`")
+var samplesWithInvalidConfig = errors.New("`samples` or `sample_file` defined together with `value`, `enum`, `counter`, `quantiles` or `cumulative_of`")
+var smoothingInvalidConfig = errors.New("`smoothing` must be not negative, and defined only with `samples` or `sample_file`")
+var timeOfDayInvalidConfig = errors.New("`time_of_day` must have a `timestamp` field and `windows`, each with `from` and `to` times of the day, as `15:04`, that differ")
+var timeOfDayNestedInvalidConfig = errors.New("`time_of_day` windows cannot have a `time_of_day`")
+var geoBBoxInvalidConfig = errors.New("`geo_bbox` must have `top_left` and `bottom_right` latitudes between -90 and 90, with the top one not below the bottom one, and longitudes between -180 and 180")
+var geoTrajectoryInvalidConfig = errors.New("`geo_trajectory` must have a positive `max_speed`, and `max_turn` between 0 and 180")
+var geoTrajectoryWithInvalidConfig = errors.New("`geo_trajectory` defined together with `value`, `cardinality`, `array_length` or a constant")
+var geoFormatInvalidConfig = errors.New("`geo_format` must be one of 'string', 'object', 'geohash'")
+var histogramInvalidConfig = errors.New("`histogram` must have not negative `buckets` and `observations`, and `range.min` less than `range.max`")
+var tenantInvalidConfig = errors.New("`tenants` must have unique and not empty `name`s, and not negative `weight`s")
+var enumInvalidConfig = errors.New("`enum` entries must be values, or objects with a `value` and an optional positive `weight`")
+var includeInvalidConfig = errors.New("included files can only define `version`, `include` and `fields`")
+var maxDurationInvalidConfig = errors.New("`max_duration` cannot be negative")
+var arrayLengthInvalidConfig = errors.New("`array_length` must have `max` greater than 0 and `min` between 0 and `max`")
+var reuseInvalidConfig = errors.New("`reuse` must have `probability` between 0 (excluded) and 1, and `size` not negative")
+var reuseWithInvalidConfig = errors.New("`reuse` defined together with `value`, `cardinality`, `per_run_constant` or `per_batch_constant`")
+var strictCardinalityInvalidConfig = errors.New("`strict_cardinality` requires `cardinality`")
+var cardinalityDistributionInvalidConfig = errors.New("`cardinality_distribution` must be `uniform` or `zipf`, requires `cardinality`, and `zipf` cannot be defined together with `strict_cardinality`")
+var excludeValuesInvalidConfig = errors.New("`exclude_values` must list strings, numbers or booleans, and cannot be defined together with `value`")
+var formatInvalidConfig = errors.New("`format` must have a single `%s` verb, like `i-%s`, with `%%` for a literal `%`")
+var affixesWithInvalidConfig = errors.New("`prefix`, `suffix` or `format` defined together with `value`")
+var normalizeInvalidConfig = errors.New("`normalize.case` must be `lower` or `upper`, and `normalize` cannot be defined together with `value`")
+var dynamicKeysInvalidConfig = errors.New("`dynamic_keys` must have `max` greater than 0, `min` between 0 and `max` and `cardinality` not less than `max`, and cannot be defined together with `object_keys`")
+var sourceForInvalidConfig = errors.New("`source_for` entries must have a `field` other than the field itself, and a `format` with a single `%s` verb, like `status=%s`, and `source_for` cannot be defined together with `value` or `array_length`")
+var generatorInvalidConfig = errors.New("`generator` must be one of 'user_agent', 'url' or 'tls'")
+var userAgentInvalidConfig = errors.New("`user_agent` must have not negative `browsers` and `os` weights, with at least a positive one each, and can only be defined together with `generator: user_agent`")
+var urlInvalidConfig = errors.New("`url` must have not empty `schemes` and `domains`, `path_depth` and `query_params` with 0 <= min <= max, a `part` among 'original', 'domain', 'path' or 'query', and can only be defined together with `generator: url`")
+var tlsInvalidConfig = errors.New("`tls` must have a `part` among 'version', 'version_protocol', 'cipher', 'curve', 'client.ja3', 'client.server_name', 'server.ja3s', 'server.subject', 'server.issuer', 'server.not_before', 'server.not_after' or 'server.hash.sha256', not negative `clients` weights with at least a positive one, not empty `server_names`, and can only be defined together with `generator: tls`")
+var cidrInvalidConfig = errors.New("`cidr` must list IPv4 or IPv6 networks, like `10.0.0.0/8`, `ipv6_fraction` must be between 0 and 1, and `cidr`, `exclude_reserved` and `ipv6_fraction` cannot be defined together with `value`")
+var arrayValuesFromInvalidConfig = errors.New("`array_length.values_from` must list other fields, not more than `max`, and cannot be defined together with `per_run_constant` or `per_batch_constant`")
 
 type TimeRange struct {
 	time.Time
@@ -26,6 +84,89 @@ func (ct *TimeRange) Unpack(t string) error {
 	return err
 }
 
+// Enum are the entries of an `enum`, each either a plain value or an object with a `value` and its `weight`
+type Enum []EnumValue
+
+// EnumValue is an entry of an `enum`: its value is picked with probability proportional to its weight,
+// 1 when not set, among the weights of the entries.
+type EnumValue struct {
+	Value  string
+	Weight float64
+}
+
+func (e *EnumValue) Unpack(v any) error {
+	e.Weight = 1
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		if _, ok := v.([]any); ok {
+			return enumInvalidConfig
+		}
+
+		e.Value = fmt.Sprint(v)
+		return nil
+	}
+
+	value, ok := m["value"]
+	if !ok || len(m) > 2 {
+		return enumInvalidConfig
+	}
+
+	switch value.(type) {
+	case []any, map[string]any:
+		return enumInvalidConfig
+	}
+
+	e.Value = fmt.Sprint(value)
+
+	weight, ok := m["weight"]
+	if !ok {
+		if len(m) > 1 {
+			return enumInvalidConfig
+		}
+
+		return nil
+	}
+
+	switch w := weight.(type) {
+	case int64:
+		e.Weight = float64(w)
+	case uint64:
+		e.Weight = float64(w)
+	case float64:
+		e.Weight = w
+	default:
+		return enumInvalidConfig
+	}
+
+	if e.Weight <= 0 || math.IsInf(e.Weight, 0) || math.IsNaN(e.Weight) {
+		return enumInvalidConfig
+	}
+
+	return nil
+}
+
+// Values returns the values of the entries
+func (e Enum) Values() []string {
+	values := make([]string, 0, len(e))
+	for _, v := range e {
+		values = append(values, v.Value)
+	}
+
+	return values
+}
+
+// Weighted returns true if the entries have different weights
+func (e Enum) Weighted() bool {
+	for _, v := range e {
+		if v.Weight != e[0].Weight {
+			return true
+		}
+	}
+
+	return false
+}
+
 type Range struct {
 	// NOTE: we want to distinguish when Min/Max/From/To are explicitly set to zero value or are not set at all. We use a pointer, such that when not set will be `nil`.
 	Min  *float64   `config:"min"`
@@ -34,78 +175,1568 @@ type Range struct {
 	To   *TimeRange `config:"to"`
 }
 
-type Config struct {
-	m map[string]ConfigField
+type Config struct {
+	m            map[string]ConfigField
+	constraints  []Constraint
+	correlations []Correlation
+	sessions     []Session
+	timeline     []TimelineStep
+	tenants      []Tenant
+	// seed is the seed of the rand the values are generated with, when set in the config file
+	seed *int64
+	// maxDuration is the time budget of the generation, when set in the config file
+	maxDuration time.Duration
+	// deprecation warnings of the loaded config, as a result of its migration to the current version
+	warnings []string
+}
+
+type ConfigField struct {
+	Name                    string        `config:"name"`
+	Fuzziness               float64       `config:"fuzziness"`
+	Range                   Range         `config:"range"`
+	Cardinality             int           `config:"cardinality"`
+	StrictCardinality       bool          `config:"strict_cardinality"`
+	CardinalityDistribution string        `config:"cardinality_distribution"`
+	Period                  time.Duration `config:"period"`
+	Enum                    Enum          `config:"enum"`
+	ObjectKeys              []string      `config:"object_keys"`
+	DynamicKeys             *DynamicKeys  `config:"dynamic_keys"`
+	Value                   any           `config:"value"`
+	Counter                 bool          `config:"counter"`
+	CounterReset            *CounterReset `config:"counter_reset"`
+	CounterRate             *CounterRate  `config:"counter_rate"`
+	PerRunConstant          bool          `config:"per_run_constant"`
+	PerBatchConstant        bool          `config:"per_batch_constant"`
+	Precision               *int          `config:"precision"`
+	Rounding                string        `config:"rounding"`
+	Unit                    string        `config:"unit"`
+	CumulativeOf            string        `config:"cumulative_of"`
+	CumulativeEntity        string        `config:"cumulative_entity"`
+	ArrayLength             *ArrayLength  `config:"array_length"`
+	HashOf                  string        `config:"hash_of"`
+	ASNOf                   string        `config:"asn_of"`
+	Order                   string        `config:"order"`
+	OrderEntity             string        `config:"order_entity"`
+	Bucket                  *Bucket       `config:"bucket"`
+	InterArrival            *InterArrival `config:"inter_arrival"`
+	// AllowNaNInf lets NaN and infinite values through, instead of replacing them
+	AllowNaNInf           bool    `config:"allow_nan_inf"`
+	NormalizeNegativeZero bool    `config:"normalize_negative_zero"`
+	Locale                string  `config:"locale"`
+	Content               string  `config:"content"`
+	UnicodeSalt           float64 `config:"unicode_salt"`
+	Assert                *Assert `config:"assert"`
+	// Quantiles maps quantiles, as `p50` or `p99.9`, to the values the generated values must have at them
+	Quantiles map[string]float64 `config:"quantiles"`
+	// SampleFile is the path of a file with a sample value per line, relative to the config file
+	SampleFile string   `config:"sample_file"`
+	Samples    []string `config:"samples"`
+	// Smoothing is the bandwidth of the noise added to the numeric samples, as a fraction of their standard deviation
+	Smoothing float64    `config:"smoothing"`
+	TimeOfDay *TimeOfDay `config:"time_of_day"`
+	// GeoBBox is the bounding box the `geo_point` values are generated within
+	GeoBBox   *GeoBBox `config:"geo_bbox"`
+	GeoFormat string   `config:"geo_format"`
+	// GeoTrajectory moves the `geo_point` of an entity from its previous position, instead of drawing a new one
+	GeoTrajectory *GeoTrajectory `config:"geo_trajectory"`
+	// Histogram defines the buckets and the counts of the `histogram` values
+	Histogram *Histogram `config:"histogram"`
+	Reuse     *Reuse     `config:"reuse"`
+	// Distribution is the distribution of the numeric values within `range`, uniform when not set
+	Distribution string   `config:"distribution"`
+	Mean         *float64 `config:"mean"`
+	Stddev       float64  `config:"stddev"`
+	// ExcludeValues are the values the field never gets
+	ExcludeValues []any `config:"exclude_values"`
+	// Prefix, Format and Suffix wrap the text of the generated values, as prefix + fmt.Sprintf(format, value) + suffix
+	Prefix string `config:"prefix"`
+	Format string `config:"format"`
+	Suffix string `config:"suffix"`
+	// Normalize normalizes the generated keywords, like the normalizer of their mapping
+	Normalize *Normalize `config:"normalize"`
+	// Pattern shapes the volume of the generated timestamps over time, like the traffic curves of production
+	Pattern *Pattern `config:"pattern"`
+	// Generator is a semantic generator of the values, like GeneratorUserAgent
+	Generator string `config:"generator"`
+	// UserAgent are the market-share weights of the `user_agent` generator, the default ones when not set
+	UserAgent *UserAgent `config:"user_agent"`
+	// URL is the shape of the URLs of the `url` generator, the default one when not set
+	URL *URL `config:"url"`
+	// TLS is the part of the handshake of the `tls` generator, and the clients and the servers of the handshakes
+	TLS *TLS `config:"tls"`
+	// SourceFor are the runtime fields whose values are embedded in the generated values, for their scripts to parse
+	SourceFor []SourceFor `config:"source_for"`
+	// CIDR are the networks the `ip` values are generated within, each picked with probability proportional to its weight
+	CIDR Enum `config:"cidr"`
+	// ExcludeReserved avoids the reserved IP ranges, like loopback or multicast, other than the ones containing a network of CIDR
+	ExcludeReserved bool `config:"exclude_reserved"`
+	// IPv6Fraction is the fraction of the `ip` values that are IPv6
+	IPv6Fraction *float64 `config:"ipv6_fraction"`
+}
+
+// UserAgent are the weights the browsers and the operating systems of the user agents are picked with, by name:
+// a browser or an operating system not listed is never picked
+type UserAgent struct {
+	Browsers map[string]float64 `config:"browsers"`
+	OS       map[string]float64 `config:"os"`
+}
+
+// URL is the shape of the URLs of the `url` generator: each setting not set has a default
+type URL struct {
+	// Schemes and Domains are picked uniformly for each URL
+	Schemes []string `config:"schemes"`
+	Domains []string `config:"domains"`
+	// PathDepth is the range of the number of segments of the path
+	PathDepth *CountRange `config:"path_depth"`
+	// QueryParams is the range of the number of parameters of the query
+	QueryParams *CountRange `config:"query_params"`
+	// Part is the part of the URL generated, one of the URLPart values, by default the one of the ECS field with
+	// the same suffix, like `path` for `url.path`, or the whole URL
+	Part string `config:"part"`
+}
+
+// TLS is the part of the TLS handshake the `tls` generator generates, and how the handshakes are drawn: each
+// setting not set has a default
+type TLS struct {
+	// Part is the field of the handshake generated, one of the TLSPart values, by default the one of the ECS field
+	// with the same name, like `client.ja3` for `tls.client.ja3`
+	Part string `config:"part"`
+	// Clients are the weights the client profiles are picked with, by name: a client not listed is never picked
+	Clients map[string]float64 `config:"clients"`
+	// ServerNames are the names of the servers, picked uniformly for each handshake
+	ServerNames []string `config:"server_names"`
+	// Timestamp is the date field the certificates of the servers are valid at, the time of the generation when not set
+	Timestamp string `config:"timestamp"`
+}
+
+// CountRange is a range of counts, from Min to Max both included
+type CountRange struct {
+	Min int `config:"min"`
+	Max int `config:"max"`
+}
+
+// SourceFor is a runtime field the field is the source of: the value of the runtime field in the event, formatted
+// with Format, is appended to the value of the field, separated by a space
+type SourceFor struct {
+	Field string `config:"field"`
+	// Format is a printf-style format with a single `%s` verb, `%s` when not set
+	Format string `config:"format"`
+}
+
+// Pattern is the relative volume of the timestamps over time, in UTC: the product of the factor of the peak hours,
+// the factor of the weekends and the sine curves of the seasonality
+type Pattern struct {
+	// PeakHours are the hours of the day, from 0 to 23, with PeakFactor times the volume of the other hours
+	PeakHours  []int   `config:"peak_hours"`
+	PeakFactor float64 `config:"peak_factor"`
+	// WeekendFactor is the volume of Saturdays and Sundays relative to the other days
+	WeekendFactor float64       `config:"weekend_factor"`
+	Seasonality   []Seasonality `config:"seasonality"`
+}
+
+// Seasonality is a sine curve of the volume, multiplying it by 1 + amplitude at its peak and by 1 - amplitude
+// half a period later
+type Seasonality struct {
+	Amplitude float64       `config:"amplitude"`
+	Period    time.Duration `config:"period"`
+	// Peak is the time of the peak from the start of each period, the periods being aligned to the Unix epoch
+	Peak time.Duration `config:"peak"`
+}
+
+// Normalize is the normalization of the generated keywords, applied after `prefix`, `format` and `suffix`
+type Normalize struct {
+	// Case forces the case of the keywords, either NormalizeCaseLower or NormalizeCaseUpper
+	Case string `config:"case"`
+	// ReplaceSpaces replaces each space character with its value, when set
+	ReplaceSpaces *string `config:"replace_spaces"`
+	// StripDiacritics removes the diacritics, like the accents of `é`
+	StripDiacritics bool `config:"strip_diacritics"`
+}
+
+const (
+	CounterResetStrategyRandom        string = "random"
+	CounterResetStrategyProbabilistic string = "probabilistic"
+	CounterResetStrategyAfterN        string = "after_n"
+	CounterResetStrategyNever         string = "never"
+)
+
+const (
+	RoundingRound    string = "round"
+	RoundingFloor    string = "floor"
+	RoundingCeil     string = "ceil"
+	RoundingHalfEven string = "half_even"
+)
+
+// MaxPrecision is the max `precision`: a float64 has up to 15 significant decimal digits, and 10 to a larger
+// power scales the values out of its range
+const MaxPrecision = 15
+
+const (
+	ContentPersonName string = "person_name"
+	ContentAddress    string = "address"
+	ContentText       string = "text"
+)
+
+const (
+	GeneratorUserAgent string = "user_agent"
+	GeneratorURL       string = "url"
+	GeneratorTLS       string = "tls"
+)
+
+const (
+	URLPartOriginal string = "original"
+	URLPartDomain   string = "domain"
+	URLPartPath     string = "path"
+	URLPartQuery    string = "query"
+)
+
+const (
+	TLSPartVersion          string = "version"
+	TLSPartVersionProtocol  string = "version_protocol"
+	TLSPartCipher           string = "cipher"
+	TLSPartCurve            string = "curve"
+	TLSPartClientJA3        string = "client.ja3"
+	TLSPartClientServerName string = "client.server_name"
+	TLSPartServerJA3S       string = "server.ja3s"
+	TLSPartServerSubject    string = "server.subject"
+	TLSPartServerIssuer     string = "server.issuer"
+	TLSPartServerNotBefore  string = "server.not_before"
+	TLSPartServerNotAfter   string = "server.not_after"
+	TLSPartServerSHA256     string = "server.hash.sha256"
+)
+
+// TLSParts are the parts of the handshake the `tls` generator generates
+var TLSParts = []string{
+	TLSPartVersion, TLSPartVersionProtocol, TLSPartCipher, TLSPartCurve, TLSPartClientJA3, TLSPartClientServerName,
+	TLSPartServerJA3S, TLSPartServerSubject, TLSPartServerIssuer, TLSPartServerNotBefore, TLSPartServerNotAfter,
+	TLSPartServerSHA256,
+}
+
+const (
+	GeoFormatString  string = "string"
+	GeoFormatObject  string = "object"
+	GeoFormatGeohash string = "geohash"
+)
+
+const (
+	DistributionUniform string = "uniform"
+	DistributionNormal  string = "normal"
+)
+
+const (
+	NormalizeCaseLower string = "lower"
+	NormalizeCaseUpper string = "upper"
+)
+
+const (
+	CardinalityDistributionUniform string = "uniform"
+	CardinalityDistributionZipf    string = "zipf"
+)
+
+const (
+	InterArrivalExponential string = "exponential"
+	InterArrivalFixed       string = "fixed"
+	InterArrivalLognormal   string = "lognormal"
+)
+
+const (
+	OrderStrictlyIncreasing  string = "strictly_increasing"
+	OrderIncreasingPerEntity string = "increasing_per_entity"
+	OrderUnordered           string = "unordered"
+)
+
+const (
+	UnitBytes   string = "bytes"
+	UnitPercent string = "percent"
+	UnitNanos   string = "nanos"
+)
+
+// unitDefaults holds the settings applied to fields declaring a `unit`, unless explicitly set
+var unitDefaults = map[string]struct {
+	min, max  float64
+	precision int
+}{
+	UnitBytes:   {min: 0, max: 1 << 30, precision: 0},
+	UnitPercent: {min: 0, max: 100, precision: 1},
+	UnitNanos:   {min: 0, max: float64(10 * time.Second), precision: 0},
+}
+
+const (
+	ConstraintTypeSum       string = "sum"
+	ConstraintTypeAggregate string = "aggregate"
+	ConstraintTypeHistogram string = "histogram"
+)
+
+const (
+	AggregationMin   string = "min"
+	AggregationMax   string = "max"
+	AggregationAvg   string = "avg"
+	AggregationSum   string = "sum"
+	AggregationCount string = "count"
+)
+
+// Constraint defines a relation the values of a group of fields must satisfy within the same event
+type Constraint struct {
+	Type       string    `config:"type"`
+	Fields     []string  `config:"fields"`
+	Total      *float64  `config:"total"`
+	TotalField string    `config:"total_field"`
+	Range      Range     `config:"range"`
+	Samples    int       `config:"samples"`
+	Buckets    []float64 `config:"buckets"`
+	Cumulative bool      `config:"cumulative"`
+}
+
+func (c Constraint) Validate() error {
+	if c.Type != ConstraintTypeSum && c.Type != ConstraintTypeAggregate && c.Type != ConstraintTypeHistogram {
+		return errors.New("constraint type must be one of 'sum', 'aggregate', 'histogram'")
+	}
+
+	if c.Type == ConstraintTypeSum && len(c.Fields) < 2 {
+		return errors.New("constraint requires at least 2 fields")
+	}
+
+	if c.Total != nil && len(c.TotalField) > 0 {
+		return errors.New("constraint defining both `total` and `total_field`")
+	}
+
+	if c.Type == ConstraintTypeAggregate {
+		if len(c.Fields) == 0 {
+			return errors.New("constraint requires at least 1 field")
+		}
+
+		for _, field := range c.Fields {
+			switch c.Aggregation(field) {
+			case AggregationMin, AggregationMax, AggregationAvg, AggregationSum, AggregationCount:
+			default:
+				return fmt.Errorf("aggregate constraint field %s must end with one of 'min', 'max', 'avg', 'sum', 'count'", field)
+			}
+		}
+
+		if c.Samples < 0 {
+			return errors.New("constraint samples must be greater than 0")
+		}
+	}
+
+	if c.Type == ConstraintTypeHistogram {
+		if len(c.Buckets) == 0 {
+			return errors.New("histogram constraint requires `buckets`")
+		}
+
+		for i := 1; i < len(c.Buckets); i++ {
+			if c.Buckets[i] <= c.Buckets[i-1] {
+				return errors.New("histogram constraint `buckets` must be in increasing order")
+			}
+		}
+
+		if len(c.Fields) != len(c.Buckets) && len(c.Fields) != len(c.Buckets)+1 {
+			return errors.New("histogram constraint requires a field for each bucket, plus an optional one for values above the last bucket")
+		}
+
+		if c.Samples < 0 {
+			return errors.New("constraint samples must be greater than 0")
+		}
+	}
+
+	return nil
+}
+
+// Aggregation returns the aggregation a field of an `aggregate` constraint is computed with, from its last path segment
+func (c Constraint) Aggregation(field string) string {
+	return field[strings.LastIndex(field, ".")+1:]
+}
+
+// SamplesOrDefault returns the size of the sample set of an `aggregate` constraint, defaulting to 10
+func (c Constraint) SamplesOrDefault() int {
+	if c.Samples == 0 {
+		return 10
+	}
+
+	return c.Samples
+}
+
+// TotalAsFloat64 returns the total the fields of a `sum` constraint add up to, defaulting to 100
+func (c Constraint) TotalAsFloat64() float64 {
+	if c.Total == nil {
+		return 100
+	}
+
+	return *c.Total
+}
+
+// Correlation defines a group of fields whose values are generated together, from a pool of `cardinality` entities,
+// so that in an event they always come from the same entity
+type Correlation struct {
+	Name        string   `config:"name"`
+	Fields      []string `config:"fields"`
+	Cardinality int      `config:"cardinality"`
+}
+
+func (c Correlation) Validate() error {
+	if len(c.Fields) < 2 {
+		return errors.New("correlation requires at least 2 fields")
+	}
+
+	if c.Cardinality <= 0 {
+		return errors.New("correlation `cardinality` must be greater than 0")
+	}
+
+	seen := make(map[string]struct{}, len(c.Fields))
+	for _, field := range c.Fields {
+		if _, ok := seen[field]; ok {
+			return fmt.Errorf("correlation field %s is repeated", field)
+		}
+
+		seen[field] = struct{}{}
+	}
+
+	return nil
+}
+
+// Session defines a group of fields whose values are shared by consecutive events, like the `session.id`,
+// `source.ip` and `user.name` of a user session, for a number of events drawn from `length`
+type Session struct {
+	Name   string        `config:"name"`
+	Fields []string      `config:"fields"`
+	Length SessionLength `config:"length"`
+}
+
+// SessionLength defines the distribution of the number of events of a session: uniform between `min` and `max`,
+// or geometric with the given `mean` when it's set, capped at `max`
+type SessionLength struct {
+	Min  int     `config:"min"`
+	Max  int     `config:"max"`
+	Mean float64 `config:"mean"`
+}
+
+func (c Session) Validate() error {
+	if len(c.Fields) == 0 {
+		return errors.New("session requires at least 1 field")
+	}
+
+	if c.Length.Min < 0 {
+		return errors.New("session `length.min` must be greater than or equal to 0")
+	}
+
+	if c.Length.Max < c.Length.MinOrDefault() {
+		return errors.New("session `length.max` must be greater than 0 and not less than `length.min`")
+	}
+
+	if c.Length.Mean != 0 && (c.Length.Mean < float64(c.Length.MinOrDefault()) || c.Length.Mean > float64(c.Length.Max)) {
+		return errors.New("session `length.mean` must be between `length.min` and `length.max`")
+	}
+
+	seen := make(map[string]struct{}, len(c.Fields))
+	for _, field := range c.Fields {
+		if _, ok := seen[field]; ok {
+			return fmt.Errorf("session field %s is repeated", field)
+		}
+
+		seen[field] = struct{}{}
+	}
+
+	return nil
+}
+
+// MinOrDefault returns the minimum number of events of a session, defaulting to 1
+func (l SessionLength) MinOrDefault() int {
+	if l.Min == 0 {
+		return 1
+	}
+
+	return l.Min
+}
+
+// TimelineStep defines the config entries applied from an event, or from a time of the generation, on, replacing
+// the ones of the same fields, and the settings of the generation it changes, like the rate of the events
+type TimelineStep struct {
+	AtEvent uint64 `config:"at_event"`
+	// At is the time since the first event the step is applied from, instead of AtEvent when set
+	At     time.Duration `config:"at"`
+	Fields []ConfigField `config:"fields"`
+	Set    TimelineSet   `config:"set"`
+}
+
+// Due reports whether the step is due before the event of index counter, generated elapsed after the first one
+func (s TimelineStep) Due(counter uint64, elapsed time.Duration) bool {
+	if s.At > 0 {
+		return elapsed >= s.At
+	}
+
+	return counter >= s.AtEvent
+}
+
+// TimelineSet defines the settings of the generation changed by a timeline step
+type TimelineSet struct {
+	// EPS is the rate of the events from the step on, as a multiple of the rate of the generation, like `2x`
+	EPS string `config:"eps"`
+	// epsFactor is the multiple of EPS, zero when not set
+	epsFactor float64
+}
+
+// EPSFactor returns the multiple of the rate of the generation set by the step, and whether it's set
+func (s TimelineSet) EPSFactor() (float64, bool) {
+	return s.epsFactor, s.epsFactor > 0
+}
+
+// parseEPSFactor returns the multiple of an `eps` setting, like `2x` or `0.5x`
+func parseEPSFactor(eps string) (float64, error) {
+	factor, err := strconv.ParseFloat(strings.TrimSuffix(eps, "x"), 64)
+	if err != nil || !strings.HasSuffix(eps, "x") || factor <= 0 || math.IsInf(factor, 0) {
+		return 0, fmt.Errorf("`set.eps` must be a positive multiple of the rate, like '2x', got '%s'", eps)
+	}
+
+	return factor, nil
+}
+
+const (
+	// DefaultHistogramBuckets is the number of buckets of a `histogram` when `buckets` is not set
+	DefaultHistogramBuckets = 10
+	// DefaultHistogramObservations is the number of values counted in a `histogram` when `observations` is not set
+	DefaultHistogramObservations = 100
+)
+
+// Histogram defines the values of a `histogram` field: the `range` of the field is split into `buckets` of the
+// same width, and `observations` values, drawn with the distribution of the field, are counted into them
+type Histogram struct {
+	Buckets      int `config:"buckets"`
+	Observations int `config:"observations"`
+}
+
+// BucketsOrDefault returns the number of buckets, DefaultHistogramBuckets when not set
+func (h Histogram) BucketsOrDefault() int {
+	if h.Buckets == 0 {
+		return DefaultHistogramBuckets
+	}
+
+	return h.Buckets
+}
+
+// ObservationsOrDefault returns the number of values counted, DefaultHistogramObservations when not set
+func (h Histogram) ObservationsOrDefault() int {
+	if h.Observations == 0 {
+		return DefaultHistogramObservations
+	}
+
+	return h.Observations
+}
+
+// GeoPoint is a latitude and a longitude, in degrees
+type GeoPoint struct {
+	Lat float64 `config:"lat"`
+	Lon float64 `config:"lon"`
+}
+
+// GeoBBox is a bounding box, as in the `geo_bounding_box` query of Elasticsearch: when the longitude of
+// `top_left` is greater than the one of `bottom_right`, the box crosses the antimeridian.
+type GeoBBox struct {
+	TopLeft     GeoPoint `config:"top_left"`
+	BottomRight GeoPoint `config:"bottom_right"`
+}
+
+// DefaultGeoTrajectoryMaxTurn is the maximum change of heading of a `geo_trajectory`, in degrees, when `max_turn` is not set
+const DefaultGeoTrajectoryMaxTurn = 30.0
+
+// GeoTrajectory makes the `geo_point` values of an entity, or of the run, a path: each point is reached from the
+// previous one at a speed up to `max_speed`, in km/h, after turning by up to `max_turn` degrees.
+type GeoTrajectory struct {
+	MaxSpeed float64  `config:"max_speed"`
+	MaxTurn  *float64 `config:"max_turn"`
+	Entity   string   `config:"entity"`
+	// Timestamp is the `date` field with the time of the points; they are a minute apart when not set
+	Timestamp string `config:"timestamp"`
+}
+
+// MaxTurnOrDefault returns the maximum change of heading between consecutive points, in degrees
+func (t GeoTrajectory) MaxTurnOrDefault() float64 {
+	if t.MaxTurn == nil {
+		return DefaultGeoTrajectoryMaxTurn
+	}
+
+	return *t.MaxTurn
+}
+
+// Tenant defines a namespace or organization the events are split among, with its own share of the events and
+// config entries, replacing the ones of the same fields. The values generated for each tenant are kept apart.
+type Tenant struct {
+	Name string `config:"name"`
+	// Weight is the share of the events of the tenant, relative to the other tenants; it's 1 when not set
+	Weight float64       `config:"weight"`
+	Fields []ConfigField `config:"fields"`
+}
+
+// InterArrival sets the distribution of the time between consecutive events, of the run or of an entity when set
+type InterArrival struct {
+	Distribution string        `config:"distribution"`
+	Mean         time.Duration `config:"mean"`
+	Stddev       time.Duration `config:"stddev"`
+	Entity       string        `config:"entity"`
+}
+
+// Bucket sets the exact number of documents generated per interval, and per entity when set
+type Bucket struct {
+	Interval time.Duration `config:"interval"`
+	Docs     int           `config:"docs"`
+	Entity   string        `config:"entity"`
+	Gaps     *Gaps         `config:"gaps"`
+}
+
+// Gaps sets the random windows an entity is offline for, in intervals
+type Gaps struct {
+	Probability float64 `config:"probability"`
+	Min         int     `config:"min"`
+	Max         int     `config:"max"`
+}
+
+// MinOrDefault returns the minimum number of intervals of a gap, 1 when not set
+func (g Gaps) MinOrDefault() int {
+	if g.Min == 0 {
+		return 1
+	}
+
+	return g.Min
+}
+
+// MaxOrDefault returns the maximum number of intervals of a gap, the minimum when not set
+func (g Gaps) MaxOrDefault() int {
+	if g.Max == 0 {
+		return g.MinOrDefault()
+	}
+
+	return g.Max
+}
+
+// DocsOrDefault returns the documents per interval and entity, 1 when not set
+func (b Bucket) DocsOrDefault() int {
+	if b.Docs == 0 {
+		return 1
+	}
+
+	return b.Docs
+}
+
+// Assert declares the statistical properties the generated values of a field are expected to have,
+// as the bounds, both included, of the number of distinct values and of their mean
+type Assert struct {
+	CardinalityBetween []float64 `config:"cardinality_between"`
+	MeanBetween        []float64 `config:"mean_between"`
+}
+
+// QuantilePoint is the value a field must have at a quantile, between 0 and 1
+type QuantilePoint struct {
+	Quantile float64
+	Value    float64
+}
+
+// TimeOfDay replaces the config of a field for the events whose timestamp falls within a window of the day
+type TimeOfDay struct {
+	Timestamp string            `config:"timestamp"`
+	Windows   []TimeOfDayWindow `config:"windows"`
+}
+
+// TimeOfDayWindow holds the config of a field from a time of the day, included, to another, excluded, as `15:04`
+// in UTC; when `to` is before `from` the window spans midnight
+type TimeOfDayWindow struct {
+	From  string      `config:"from"`
+	To    string      `config:"to"`
+	Field ConfigField `config:"field"`
+}
+
+// Minutes returns the minutes of the day the window goes from and to; `24:00` is the end of the day
+func (w TimeOfDayWindow) Minutes() (int, int, error) {
+	from, err := parseTimeOfDay(w.From)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	to, err := parseTimeOfDay(w.To)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if from == to%(24*60) {
+		return 0, 0, timeOfDayInvalidConfig
+	}
+
+	return from, to, nil
+}
+
+func parseTimeOfDay(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, timeOfDayInvalidConfig
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ArrayLength defines the range of the number of values generated for an array field
+type ArrayLength struct {
+	Min int `config:"min"`
+	Max int `config:"max"`
+	// Distinct avoids duplicate values in the array
+	Distinct bool `config:"distinct"`
+	// ValuesFrom are the fields whose values in the event the array starts with, implying Distinct
+	ValuesFrom []string `config:"values_from"`
+}
+
+// DynamicKeys defines the keys of an object field generated in each event, like the metrics of `prometheus.metrics.*`:
+// between Min and Max keys are picked among Cardinality key names, Max when not set
+type DynamicKeys struct {
+	Min         int `config:"min"`
+	Max         int `config:"max"`
+	Cardinality int `config:"cardinality"`
+}
+
+// CardinalityOrDefault returns the number of key names the keys are picked among, Max when not set
+func (d DynamicKeys) CardinalityOrDefault() int {
+	if d.Cardinality == 0 {
+		return d.Max
+	}
+
+	return d.Cardinality
+}
+
+// DefaultReuseSize is the number of values kept for `reuse` when `size` is not set
+const DefaultReuseSize = 1000
+
+// Reuse defines the probability of a field to get a value it already generated in the run, picked among the
+// `size` values kept of the ones generated so far, instead of a new one
+type Reuse struct {
+	Probability float64 `config:"probability"`
+	Size        int     `config:"size"`
+}
+
+// SizeOrDefault returns the number of values kept, DefaultReuseSize when not set
+func (r Reuse) SizeOrDefault() int {
+	if r.Size == 0 {
+		return DefaultReuseSize
+	}
+
+	return r.Size
+}
+
+type CounterReset struct {
+	Strategy    string  `config:"strategy"`
+	Probability *uint64 `config:"probability"`
+	ResetAfterN *uint64 `config:"reset_after_n"`
+}
+
+// CounterRate defines the range of the increment of a counter at each event
+type CounterRate struct {
+	Min float64 `config:"min"`
+	Max float64 `config:"max"`
+}
+
+func (cf ConfigField) ValidateCounterResetStrategy() error {
+	if cf.Counter && cf.CounterReset != nil &&
+		cf.CounterReset.Strategy != CounterResetStrategyRandom &&
+		cf.CounterReset.Strategy != CounterResetStrategyProbabilistic &&
+		cf.CounterReset.Strategy != CounterResetStrategyAfterN &&
+		cf.CounterReset.Strategy != CounterResetStrategyNever {
+		return errors.New("counter_reset strategy must be one of 'random', 'probabilistic', 'after_n', 'never'")
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidateCounterResetAfterN() error {
+	if cf.Counter && cf.CounterReset != nil && cf.CounterReset.Strategy == CounterResetStrategyAfterN && cf.CounterReset.ResetAfterN == nil {
+		return errors.New("counter_reset after_n requires 'reset_after_n' value to be set")
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidateCounterResetProbabilistic() error {
+	if cf.Counter && cf.CounterReset != nil && cf.CounterReset.Strategy == CounterResetStrategyProbabilistic && cf.CounterReset.Probability == nil {
+		return errors.New("counter_reset probabilistic requires 'probability' value to be set")
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidatePrecision() error {
+	if cf.Precision != nil && (*cf.Precision < 0 || *cf.Precision > MaxPrecision) {
+		return fmt.Errorf("precision must be between 0 and %d", MaxPrecision)
+	}
+
+	if len(cf.Rounding) > 0 &&
+		cf.Rounding != RoundingRound &&
+		cf.Rounding != RoundingFloor &&
+		cf.Rounding != RoundingCeil &&
+		cf.Rounding != RoundingHalfEven {
+		return errors.New("rounding must be one of 'round', 'floor', 'ceil', 'half_even'")
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidGeoPoint() error {
+	switch cf.GeoFormat {
+	case "", GeoFormatString, GeoFormatObject, GeoFormatGeohash:
+	default:
+		return geoFormatInvalidConfig
+	}
+
+	if cf.GeoBBox != nil {
+		top, bottom := cf.GeoBBox.TopLeft, cf.GeoBBox.BottomRight
+		if top.Lat > 90 || bottom.Lat < -90 || top.Lat < bottom.Lat ||
+			math.Abs(top.Lon) > 180 || math.Abs(bottom.Lon) > 180 {
+			return geoBBoxInvalidConfig
+		}
+	}
+
+	if cf.GeoTrajectory == nil {
+		return nil
+	}
+
+	if maxTurn := cf.GeoTrajectory.MaxTurnOrDefault(); cf.GeoTrajectory.MaxSpeed <= 0 || maxTurn < 0 || maxTurn > 180 {
+		return geoTrajectoryInvalidConfig
+	}
+
+	if cf.Value != nil || cf.Cardinality > 0 || cf.ArrayLength != nil || cf.PerRunConstant || cf.PerBatchConstant {
+		return geoTrajectoryWithInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidHistogram() error {
+	if cf.Histogram != nil && (cf.Histogram.Buckets < 0 || cf.Histogram.Observations < 0) {
+		return histogramInvalidConfig
+	}
+
+	min, minErr := cf.Range.MinAsFloat64()
+	max, maxErr := cf.Range.MaxAsFloat64()
+	if minErr == nil && maxErr == nil && min >= max {
+		return histogramInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidateUnit() error {
+	if _, ok := unitDefaults[cf.Unit]; len(cf.Unit) > 0 && !ok {
+		return errors.New("unit must be one of 'bytes', 'percent', 'nanos'")
+	}
+
+	return nil
+}
+
+// withUnitDefaults fills the range and precision settings not explicitly set according to the `unit`
+func (cf ConfigField) withUnitDefaults() ConfigField {
+	defaults, ok := unitDefaults[cf.Unit]
+	if !ok {
+		return cf
+	}
+
+	// a counter cannot have a range
+	if !cf.Counter && cf.Range.Min == nil && cf.Range.Max == nil {
+		min, max := defaults.min, defaults.max
+		cf.Range.Min = &min
+		cf.Range.Max = &max
+	}
+
+	if cf.Precision == nil {
+		precision := defaults.precision
+		cf.Precision = &precision
+	}
+
+	return cf
+}
+
+func (cf ConfigField) ValidForDateField() error {
+	if cf.Period.Abs() > 0 && (cf.Range.From != nil || cf.Range.To != nil) {
+		return rangeInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidCounter() error {
+	if cf.Counter && (cf.Range.Min != nil || cf.Range.Max != nil) {
+		return counterInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidCounterRate() error {
+	if cf.CounterRate == nil {
+		return nil
+	}
+
+	if !cf.Counter || cf.Fuzziness > 0 || cf.CounterRate.Min < 0 || cf.CounterRate.Min > cf.CounterRate.Max {
+		return counterRateInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidConstant() error {
+	if cf.PerRunConstant && cf.PerBatchConstant {
+		return constantInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidCumulative() error {
+	if len(cf.CumulativeOf) == 0 {
+		if len(cf.CumulativeEntity) > 0 {
+			return cumulativeEntityInvalidConfig
+		}
+
+		return nil
+	}
+
+	if cf.Counter || cf.Value != nil || len(cf.Enum) > 0 {
+		return cumulativeInvalidConfig
+	}
+
+	if cf.CumulativeOf == cf.Name {
+		return errors.New("`cumulative_of` must reference another field")
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidHash() error {
+	if len(cf.HashOf) == 0 {
+		return nil
+	}
+
+	if cf.Value != nil || cf.Cardinality > 0 || cf.Counter || cf.Fuzziness > 0 || len(cf.CumulativeOf) > 0 ||
+		cf.ArrayLength != nil || cf.PerRunConstant || cf.PerBatchConstant {
+		return hashInvalidConfig
+	}
+
+	if cf.HashOf == cf.Name {
+		return errors.New("`hash_of` must reference another field")
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidASN() error {
+	if len(cf.ASNOf) == 0 {
+		return nil
+	}
+
+	if cf.Value != nil || len(cf.Enum) > 0 || cf.Cardinality > 0 || cf.Counter || cf.Fuzziness > 0 || len(cf.CumulativeOf) > 0 ||
+		len(cf.HashOf) > 0 || cf.ArrayLength != nil || cf.PerRunConstant || cf.PerBatchConstant {
+		return asnInvalidConfig
+	}
+
+	if cf.ASNOf == cf.Name {
+		return errors.New("`asn_of` must reference another field")
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidOrder() error {
+	switch cf.Order {
+	case "", OrderUnordered:
+	case OrderStrictlyIncreasing, OrderIncreasingPerEntity:
+		if cf.Value != nil || cf.Cardinality > 0 || cf.ArrayLength != nil || cf.PerRunConstant || cf.PerBatchConstant {
+			return orderWithInvalidConfig
+		}
+	default:
+		return orderInvalidConfig
+	}
+
+	if (cf.Order == OrderIncreasingPerEntity) != (len(cf.OrderEntity) > 0) {
+		return orderEntityInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidUnicodeSalt() error {
+	if cf.UnicodeSalt < 0 || cf.UnicodeSalt > 1 {
+		return unicodeSaltInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidAssert() error {
+	if cf.Assert == nil {
+		return nil
+	}
+
+	for _, bounds := range [][]float64{cf.Assert.CardinalityBetween, cf.Assert.MeanBetween} {
+		if bounds != nil && (len(bounds) != 2 || bounds[0] > bounds[1]) {
+			return assertInvalidConfig
+		}
+	}
+
+	return nil
+}
+
+// QuantilePoints returns the points of `quantiles` sorted by quantile
+func (cf ConfigField) QuantilePoints() ([]QuantilePoint, error) {
+	if len(cf.Quantiles) < 2 {
+		return nil, quantilesInvalidConfig
+	}
+
+	points := make([]QuantilePoint, 0, len(cf.Quantiles))
+	for key, value := range cf.Quantiles {
+		if !strings.HasPrefix(key, "p") {
+			return nil, quantilesInvalidConfig
+		}
+
+		percentile, err := strconv.ParseFloat(strings.TrimPrefix(key, "p"), 64)
+		if err != nil || percentile <= 0 || percentile >= 100 {
+			return nil, quantilesInvalidConfig
+		}
+
+		points = append(points, QuantilePoint{Quantile: percentile / 100, Value: value})
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Quantile < points[j].Quantile
+	})
+
+	for i := 1; i < len(points); i++ {
+		if points[i].Value < points[i-1].Value {
+			return nil, quantilesInvalidConfig
+		}
+	}
+
+	return points, nil
+}
+
+func (cf ConfigField) ValidQuantiles() error {
+	if cf.Quantiles == nil {
+		return nil
+	}
+
+	if cf.Counter || cf.Fuzziness > 0 || len(cf.Enum) > 0 || len(cf.CumulativeOf) > 0 {
+		return quantilesWithInvalidConfig
+	}
+
+	points, err := cf.QuantilePoints()
+	if err != nil {
+		return err
+	}
+
+	if minValue, err := cf.Range.MinAsFloat64(); err == nil && minValue > points[0].Value {
+		return quantilesRangeInvalidConfig
+	}
+
+	if maxValue, err := cf.Range.MaxAsFloat64(); err == nil && maxValue < points[len(points)-1].Value {
+		return quantilesRangeInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidDistribution() error {
+	switch cf.Distribution {
+	case "", DistributionUniform:
+		if cf.Mean != nil || cf.Stddev != 0 {
+			return distributionInvalidConfig
+		}
+	case DistributionNormal:
+		if cf.Mean == nil || cf.Stddev <= 0 {
+			return distributionInvalidConfig
+		}
+
+		if cf.Counter || cf.Fuzziness > 0 || len(cf.Enum) > 0 || cf.Quantiles != nil || len(cf.Samples) > 0 || len(cf.CumulativeOf) > 0 {
+			return distributionWithInvalidConfig
+		}
+	default:
+		return distributionInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidSamples() error {
+	if cf.Smoothing < 0 || (cf.Smoothing > 0 && len(cf.Samples) == 0) {
+		return smoothingInvalidConfig
+	}
+
+	if len(cf.Samples) == 0 {
+		return nil
+	}
+
+	if cf.Value != nil || len(cf.Enum) > 0 || cf.Counter || cf.Quantiles != nil || len(cf.CumulativeOf) > 0 {
+		return samplesWithInvalidConfig
+	}
+
+	return nil
+}
+
+// loadSampleFile appends the values of the sample file of the field, if any, to its samples: one value per line,
+// skipping the empty ones. A relative path is relative to dir.
+func (cf ConfigField) loadSampleFile(fs afero.Fs, dir string) (ConfigField, error) {
+	if len(cf.SampleFile) == 0 {
+		return cf, nil
+	}
+
+	sampleFile := os.ExpandEnv(cf.SampleFile)
+	if !filepath.IsAbs(sampleFile) {
+		sampleFile = filepath.Join(dir, sampleFile)
+	}
+
+	data, err := afero.ReadFile(fs, sampleFile)
+	if err != nil {
+		return cf, fmt.Errorf("cannot read sample file: %w", err)
+	}
+
+	samples := make([]string, 0, len(cf.Samples))
+	samples = append(samples, cf.Samples...)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			samples = append(samples, line)
+		}
+	}
+
+	if len(samples) == 0 {
+		return cf, fmt.Errorf("sample file %s has no values", sampleFile)
+	}
+
+	cf.Samples = samples
+	// the sample file is loaded only once, by the file defining the field when it's included
+	cf.SampleFile = ""
+	return cf, nil
+}
+
+func (cf ConfigField) ValidTimeOfDay() error {
+	if cf.TimeOfDay == nil {
+		return nil
+	}
+
+	if len(cf.TimeOfDay.Timestamp) == 0 || cf.TimeOfDay.Timestamp == cf.Name || len(cf.TimeOfDay.Windows) == 0 {
+		return timeOfDayInvalidConfig
+	}
+
+	for _, window := range cf.TimeOfDay.Windows {
+		if _, _, err := window.Minutes(); err != nil {
+			return err
+		}
+
+		if window.Field.TimeOfDay != nil {
+			return timeOfDayNestedInvalidConfig
+		}
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidBucket() error {
+	if cf.Bucket == nil {
+		return nil
+	}
+
+	if cf.Bucket.Interval <= 0 || cf.Bucket.Docs < 0 {
+		return bucketInvalidConfig
+	}
+
+	if gaps := cf.Bucket.Gaps; gaps != nil {
+		if gaps.Probability <= 0 || gaps.Probability >= 1 || gaps.Min < 0 || gaps.Max < 0 || gaps.MaxOrDefault() < gaps.MinOrDefault() {
+			return bucketGapsInvalidConfig
+		}
+	}
+
+	if cf.Period != 0 || cf.Range.To != nil || cf.Value != nil || cf.Cardinality > 0 || len(cf.Order) > 0 ||
+		cf.ArrayLength != nil || cf.PerRunConstant || cf.PerBatchConstant {
+		return bucketWithInvalidConfig
+	}
+
+	return nil
 }
 
-type ConfigField struct {
-	Name         string        `config:"name"`
-	Fuzziness    float64       `config:"fuzziness"`
-	Range        Range         `config:"range"`
-	Cardinality  int           `config:"cardinality"`
-	Period       time.Duration `config:"period"`
-	Enum         []string      `config:"enum"`
-	ObjectKeys   []string      `config:"object_keys"`
-	Value        any           `config:"value"`
-	Counter      bool          `config:"counter"`
-	CounterReset *CounterReset `config:"counter_reset"`
+func (cf ConfigField) ValidInterArrival() error {
+	if cf.InterArrival == nil {
+		return nil
+	}
+
+	switch cf.InterArrival.Distribution {
+	case InterArrivalExponential, InterArrivalFixed:
+		if cf.InterArrival.Stddev != 0 {
+			return interArrivalInvalidConfig
+		}
+	case InterArrivalLognormal:
+		if cf.InterArrival.Stddev <= 0 {
+			return interArrivalInvalidConfig
+		}
+	default:
+		return interArrivalInvalidConfig
+	}
+
+	if cf.InterArrival.Mean <= 0 {
+		return interArrivalInvalidConfig
+	}
+
+	if cf.Period != 0 || cf.Range.To != nil || cf.Value != nil || cf.Cardinality > 0 || len(cf.Order) > 0 || cf.Bucket != nil ||
+		cf.ArrayLength != nil || cf.PerRunConstant || cf.PerBatchConstant {
+		return interArrivalWithInvalidConfig
+	}
+
+	return nil
 }
 
 const (
-	CounterResetStrategyRandom        string = "random"
-	CounterResetStrategyProbabilistic string = "probabilistic"
-	CounterResetStrategyAfterN        string = "after_n"
+	// DefaultPeakFactor is the volume of the peak hours of a pattern, relative to the other hours, when not set
+	DefaultPeakFactor = 2
+	// DefaultWeekendFactor is the volume of the weekends of a pattern, relative to the other days, when not set
+	DefaultWeekendFactor = 1
 )
 
-type CounterReset struct {
-	Strategy    string  `config:"strategy"`
-	Probability *uint64 `config:"probability"`
-	ResetAfterN *uint64 `config:"reset_after_n"`
+func (cf ConfigField) ValidPattern() error {
+	if cf.Pattern == nil {
+		return nil
+	}
+
+	if cf.Value != nil || cf.Pattern.PeakFactor < 0 || cf.Pattern.WeekendFactor < 0 {
+		return patternInvalidConfig
+	}
+
+	for _, hour := range cf.Pattern.PeakHours {
+		if hour < 0 || hour > 23 {
+			return patternInvalidConfig
+		}
+	}
+
+	for _, seasonality := range cf.Pattern.Seasonality {
+		if seasonality.Amplitude <= 0 || seasonality.Amplitude >= 1 || seasonality.Period <= 0 ||
+			seasonality.Peak < 0 || seasonality.Peak >= seasonality.Period {
+			return patternInvalidConfig
+		}
+	}
+
+	return nil
 }
 
-func (cf ConfigField) ValidateCounterResetStrategy() error {
-	if cf.Counter && cf.CounterReset != nil &&
-		cf.CounterReset.Strategy != CounterResetStrategyRandom &&
-		cf.CounterReset.Strategy != CounterResetStrategyProbabilistic &&
-		cf.CounterReset.Strategy != CounterResetStrategyAfterN {
-		return errors.New("counter_reset strategy must be one of 'random', 'probabilistic', 'after_n'")
+func (cf ConfigField) ValidArrayLength() error {
+	if cf.ArrayLength == nil {
+		return nil
+	}
+
+	if cf.ArrayLength.Max <= 0 || cf.ArrayLength.Min < 0 || cf.ArrayLength.Min > cf.ArrayLength.Max {
+		return arrayLengthInvalidConfig
+	}
+
+	if len(cf.ArrayLength.ValuesFrom) == 0 {
+		return nil
+	}
+
+	if len(cf.ArrayLength.ValuesFrom) > cf.ArrayLength.Max || cf.PerRunConstant || cf.PerBatchConstant {
+		return arrayValuesFromInvalidConfig
+	}
+
+	for _, field := range cf.ArrayLength.ValuesFrom {
+		if len(field) == 0 || field == cf.Name {
+			return arrayValuesFromInvalidConfig
+		}
 	}
 
 	return nil
 }
 
-func (cf ConfigField) ValidateCounterResetAfterN() error {
-	if cf.Counter && cf.CounterReset != nil && cf.CounterReset.Strategy == CounterResetStrategyAfterN && cf.CounterReset.ResetAfterN == nil {
-		return errors.New("counter_reset after_n requires 'reset_after_n' value to be set")
+func (cf ConfigField) ValidDynamicKeys() error {
+	if cf.DynamicKeys == nil {
+		return nil
+	}
+
+	if cf.DynamicKeys.Max <= 0 || cf.DynamicKeys.Min < 0 || cf.DynamicKeys.Min > cf.DynamicKeys.Max {
+		return dynamicKeysInvalidConfig
+	}
+
+	if cf.DynamicKeys.Cardinality != 0 && cf.DynamicKeys.Cardinality < cf.DynamicKeys.Max {
+		return dynamicKeysInvalidConfig
+	}
+
+	if len(cf.ObjectKeys) > 0 {
+		return dynamicKeysInvalidConfig
 	}
 
 	return nil
 }
 
-func (cf ConfigField) ValidateCounterResetProbabilistic() error {
-	if cf.Counter && cf.CounterReset != nil && cf.CounterReset.Strategy == CounterResetStrategyProbabilistic && cf.CounterReset.Probability == nil {
-		return errors.New("counter_reset probabilistic requires 'probability' value to be set")
+func (cf ConfigField) ValidStrictCardinality() error {
+	if cf.StrictCardinality && cf.Cardinality <= 0 {
+		return strictCardinalityInvalidConfig
 	}
 
 	return nil
 }
 
-func (cf ConfigField) ValidForDateField() error {
-	if cf.Period.Abs() > 0 && (cf.Range.From != nil || cf.Range.To != nil) {
-		return rangeInvalidConfig
+func (cf ConfigField) ValidCardinalityDistribution() error {
+	switch cf.CardinalityDistribution {
+	case "":
+		return nil
+	case CardinalityDistributionUniform:
+	case CardinalityDistributionZipf:
+		if cf.StrictCardinality {
+			return cardinalityDistributionInvalidConfig
+		}
+	default:
+		return cardinalityDistributionInvalidConfig
+	}
+
+	if cf.Cardinality <= 0 {
+		return cardinalityDistributionInvalidConfig
 	}
 
 	return nil
 }
 
-func (cf ConfigField) ValidCounter() error {
-	if cf.Counter && (cf.Range.Min != nil || cf.Range.Max != nil) {
-		return counterInvalidConfig
+func (cf ConfigField) ValidReuse() error {
+	if cf.Reuse == nil {
+		return nil
+	}
+
+	if cf.Reuse.Probability <= 0 || cf.Reuse.Probability > 1 || cf.Reuse.Size < 0 {
+		return reuseInvalidConfig
+	}
+
+	if cf.Value != nil || cf.Cardinality > 0 || cf.PerRunConstant || cf.PerBatchConstant {
+		return reuseWithInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidExcludeValues() error {
+	if len(cf.ExcludeValues) == 0 {
+		return nil
+	}
+
+	if cf.Value != nil {
+		return excludeValuesInvalidConfig
+	}
+
+	for _, value := range cf.ExcludeValues {
+		switch value.(type) {
+		case string, bool, int64, uint64, float64:
+		default:
+			return excludeValuesInvalidConfig
+		}
+	}
+
+	return nil
+}
+
+// HasAffixes returns true when the generated values are wrapped with `prefix`, `suffix` or `format`
+func (cf ConfigField) HasAffixes() bool {
+	return len(cf.Prefix) > 0 || len(cf.Suffix) > 0 || len(cf.Format) > 0
+}
+
+func (cf ConfigField) ValidAffixes() error {
+	if !cf.HasAffixes() {
+		return nil
+	}
+
+	if cf.Value != nil {
+		return affixesWithInvalidConfig
+	}
+
+	// a wrong verb, or a wrong number of them, is reported in the formatted text
+	if len(cf.Format) > 0 && strings.Contains(fmt.Sprintf(cf.Format, "x"), "%!") {
+		return formatInvalidConfig
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidSourceFor() error {
+	if len(cf.SourceFor) == 0 {
+		return nil
+	}
+
+	if cf.Value != nil || cf.ArrayLength != nil {
+		return sourceForInvalidConfig
+	}
+
+	for _, sourceFor := range cf.SourceFor {
+		if len(sourceFor.Field) == 0 || sourceFor.Field == cf.Name {
+			return sourceForInvalidConfig
+		}
+
+		// a wrong verb, or a wrong number of them, is reported in the formatted text
+		if len(sourceFor.Format) > 0 && strings.Contains(fmt.Sprintf(sourceFor.Format, "x"), "%!") {
+			return sourceForInvalidConfig
+		}
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidGenerator() error {
+	if len(cf.Generator) > 0 && cf.Generator != GeneratorUserAgent && cf.Generator != GeneratorURL && cf.Generator != GeneratorTLS {
+		return generatorInvalidConfig
+	}
+
+	if err := cf.validURL(); err != nil {
+		return err
+	}
+
+	if err := cf.validTLS(); err != nil {
+		return err
+	}
+
+	if cf.UserAgent == nil {
+		return nil
+	}
+
+	if cf.Generator != GeneratorUserAgent {
+		return userAgentInvalidConfig
+	}
+
+	for _, weights := range []map[string]float64{cf.UserAgent.Browsers, cf.UserAgent.OS} {
+		if weights == nil {
+			continue
+		}
+
+		var total float64
+		for _, weight := range weights {
+			if weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+				return userAgentInvalidConfig
+			}
+
+			total += weight
+		}
+
+		if total == 0 {
+			return userAgentInvalidConfig
+		}
+	}
+
+	return nil
+}
+
+func (cf ConfigField) validURL() error {
+	if cf.URL == nil {
+		return nil
+	}
+
+	if cf.Generator != GeneratorURL {
+		return urlInvalidConfig
+	}
+
+	for _, values := range [][]string{cf.URL.Schemes, cf.URL.Domains} {
+		if values != nil && len(values) == 0 {
+			return urlInvalidConfig
+		}
+
+		for _, value := range values {
+			if len(value) == 0 {
+				return urlInvalidConfig
+			}
+		}
+	}
+
+	for _, countRange := range []*CountRange{cf.URL.PathDepth, cf.URL.QueryParams} {
+		if countRange != nil && (countRange.Min < 0 || countRange.Max < countRange.Min) {
+			return urlInvalidConfig
+		}
+	}
+
+	switch cf.URL.Part {
+	case "", URLPartOriginal, URLPartDomain, URLPartPath, URLPartQuery:
+		return nil
+	default:
+		return urlInvalidConfig
+	}
+}
+
+func (cf ConfigField) validTLS() error {
+	if cf.TLS == nil {
+		return nil
+	}
+
+	if cf.Generator != GeneratorTLS {
+		return tlsInvalidConfig
+	}
+
+	if len(cf.TLS.Part) > 0 {
+		known := false
+		for _, part := range TLSParts {
+			known = known || part == cf.TLS.Part
+		}
+
+		if !known {
+			return tlsInvalidConfig
+		}
+	}
+
+	if cf.TLS.Clients != nil {
+		var total float64
+		for _, weight := range cf.TLS.Clients {
+			if weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+				return tlsInvalidConfig
+			}
+
+			total += weight
+		}
+
+		if total == 0 {
+			return tlsInvalidConfig
+		}
+	}
+
+	if cf.TLS.ServerNames != nil && len(cf.TLS.ServerNames) == 0 {
+		return tlsInvalidConfig
+	}
+
+	for _, serverName := range cf.TLS.ServerNames {
+		if len(serverName) == 0 {
+			return tlsInvalidConfig
+		}
+	}
+
+	return nil
+}
+
+func (cf ConfigField) ValidCIDR() error {
+	if len(cf.CIDR) == 0 && !cf.ExcludeReserved && cf.IPv6Fraction == nil {
+		return nil
+	}
+
+	if cf.Value != nil {
+		return cidrInvalidConfig
+	}
+
+	for _, network := range cf.CIDR {
+		if _, _, err := net.ParseCIDR(network.Value); err != nil {
+			return cidrInvalidConfig
+		}
+	}
+
+	if cf.IPv6Fraction != nil && (*cf.IPv6Fraction < 0 || *cf.IPv6Fraction > 1 || math.IsNaN(*cf.IPv6Fraction)) {
+		return cidrInvalidConfig
 	}
 
 	return nil
 }
 
+func (cf ConfigField) ValidNormalize() error {
+	if cf.Normalize == nil {
+		return nil
+	}
+
+	if cf.Value != nil {
+		return normalizeInvalidConfig
+	}
+
+	switch cf.Normalize.Case {
+	case "", NormalizeCaseLower, NormalizeCaseUpper:
+		return nil
+	default:
+		return normalizeInvalidConfig
+	}
+}
+
 func (r Range) FromAsTime() (time.Time, error) {
 	if r.From == nil {
 		return time.Time{}, rangeTimeNotSet
@@ -155,7 +1786,19 @@ func (r Range) MaxAsFloat64() (float64, error) {
 }
 
 type ConfigFile struct {
-	Fields []ConfigField `config:"fields"`
+	Version int `config:"version"`
+	// Include are the paths of config files whose fields are included, relative to the including file
+	Include      []string       `config:"include"`
+	Fields       []ConfigField  `config:"fields"`
+	Constraints  []Constraint   `config:"constraints"`
+	Correlations []Correlation  `config:"correlations"`
+	Sessions     []Session      `config:"sessions"`
+	Timeline     []TimelineStep `config:"timeline"`
+	Tenants      []Tenant       `config:"tenants"`
+	// Seed is the seed of the rand the values are generated with, so that the corpus can be reproduced
+	Seed *int64 `config:"seed"`
+	// MaxDuration is the time budget of the generation, after which it stops with the events generated so far
+	MaxDuration time.Duration `config:"max_duration"`
 }
 
 func LoadConfig(fs afero.Fs, configFile string) (Config, error) {
@@ -173,39 +1816,323 @@ func LoadConfig(fs afero.Fs, configFile string) (Config, error) {
 		return Config{}, err
 	}
 
-	return LoadConfigFromYaml(data)
+	return loadConfigFromYaml(data, fs, filepath.Dir(configFile))
 }
 
+// LoadConfigFromYaml loads the config from its content, reading the sample files relative to the working directory
 func LoadConfigFromYaml(c []byte) (Config, error) {
+	return loadConfigFromYaml(c, afero.NewOsFs(), "")
+}
+
+// loadConfigFromYaml loads the config from its content, reading the sample files from fs relative to dir
+func loadConfigFromYaml(c []byte, fs afero.Fs, dir string) (Config, error) {
 
 	cfg, err := yaml.NewConfig(c)
 	if err != nil {
 		return Config{}, err
 	}
 
+	cfg, warnings, err := migrate(cfg)
+	if err != nil {
+		return Config{}, err
+	}
+
 	var cfgfile ConfigFile
 	err = cfg.Unpack(&cfgfile)
 	if err != nil {
 		return Config{}, err
 	}
 
+	if cfgfile.MaxDuration < 0 {
+		return Config{}, maxDurationInvalidConfig
+	}
+
+	included, includeWarnings, err := includeFields(fs, dir, cfgfile.Include, nil)
+	if err != nil {
+		return Config{}, err
+	}
+
 	outCfg := Config{
-		m: make(map[string]ConfigField),
+		m:            make(map[string]ConfigField),
+		constraints:  cfgfile.Constraints,
+		correlations: cfgfile.Correlations,
+		sessions:     cfgfile.Sessions,
+		seed:         cfgfile.Seed,
+		maxDuration:  cfgfile.MaxDuration,
+		warnings:     append(warnings, includeWarnings...),
+	}
+
+	for _, c := range append(included, cfgfile.Fields...) {
+		if err := c.ValidateUnit(); err != nil {
+			return Config{}, fmt.Errorf("field %s: %w", c.Name, err)
+		}
+
+		if c, err = c.loadSampleFile(fs, dir); err != nil {
+			return Config{}, fmt.Errorf("field %s: %w", c.Name, err)
+		}
+
+		if c.TimeOfDay != nil {
+			for k, window := range c.TimeOfDay.Windows {
+				if c.TimeOfDay.Windows[k].Field, err = window.Field.loadSampleFile(fs, dir); err != nil {
+					return Config{}, fmt.Errorf("field %s: time_of_day window #%d: %w", c.Name, k, err)
+				}
+			}
+		}
+
+		outCfg.m[c.Name] = c.withUnitDefaults()
+	}
+
+	// the steps are applied in order, and each kind of trigger must be increasing
+	var lastAtEvent *uint64
+	var lastAt time.Duration
+	for i, step := range cfgfile.Timeline {
+		switch {
+		case step.At < 0:
+			return Config{}, fmt.Errorf("timeline step #%d: `at` must be a positive duration", i)
+		case step.At > 0 && step.AtEvent > 0:
+			return Config{}, fmt.Errorf("timeline step #%d: only one of `at_event` and `at` can be set", i)
+		case step.At > 0 && step.At <= lastAt:
+			return Config{}, fmt.Errorf("timeline step #%d: `at` must be greater than the one of the previous step", i)
+		case step.At == 0 && lastAtEvent != nil && step.AtEvent <= *lastAtEvent:
+			return Config{}, fmt.Errorf("timeline step #%d: `at_event` must be greater than the one of the previous step", i)
+		case step.At > 0:
+			lastAt = step.At
+		default:
+			atEvent := step.AtEvent
+			lastAtEvent = &atEvent
+		}
+
+		if len(step.Set.EPS) > 0 {
+			factor, err := parseEPSFactor(step.Set.EPS)
+			if err != nil {
+				return Config{}, fmt.Errorf("timeline step #%d: %w", i, err)
+			}
+
+			cfgfile.Timeline[i].Set.epsFactor = factor
+		}
+
+		for j, c := range step.Fields {
+			if err := c.ValidateUnit(); err != nil {
+				return Config{}, fmt.Errorf("timeline step #%d: field %s: %w", i, c.Name, err)
+			}
+
+			if c, err = c.loadSampleFile(fs, dir); err != nil {
+				return Config{}, fmt.Errorf("timeline step #%d: field %s: %w", i, c.Name, err)
+			}
+
+			step.Fields[j] = c.withUnitDefaults()
+		}
 	}
 
-	for _, c := range cfgfile.Fields {
-		outCfg.m[c.Name] = c
+	outCfg.timeline = cfgfile.Timeline
+
+	tenantNames := make(map[string]struct{}, len(cfgfile.Tenants))
+	for i, tenant := range cfgfile.Tenants {
+		if _, ok := tenantNames[tenant.Name]; ok || len(tenant.Name) == 0 || tenant.Weight < 0 {
+			return Config{}, fmt.Errorf("tenant #%d: %w", i, tenantInvalidConfig)
+		}
+
+		tenantNames[tenant.Name] = struct{}{}
+		if tenant.Weight == 0 {
+			cfgfile.Tenants[i].Weight = 1
+		}
+
+		for j, c := range tenant.Fields {
+			if err := c.ValidateUnit(); err != nil {
+				return Config{}, fmt.Errorf("tenant %s: field %s: %w", tenant.Name, c.Name, err)
+			}
+
+			if c, err = c.loadSampleFile(fs, dir); err != nil {
+				return Config{}, fmt.Errorf("tenant %s: field %s: %w", tenant.Name, c.Name, err)
+			}
+
+			tenant.Fields[j] = c.withUnitDefaults()
+		}
 	}
 
+	outCfg.tenants = cfgfile.Tenants
+
 	return outCfg, nil
 }
 
+// includeFields returns the fields of the included files, and of the files they include in turn, in order, so that
+// a later entry replaces an earlier one of the same field. A relative path is relative to dir, and the sample files
+// of the fields are loaded relative to the file defining them. including are the files being included, to detect cycles.
+func includeFields(fs afero.Fs, dir string, includes []string, including []string) ([]ConfigField, []string, error) {
+	var fields []ConfigField
+	var warnings []string
+	for _, include := range includes {
+		includeFile := os.ExpandEnv(include)
+		if !filepath.IsAbs(includeFile) {
+			includeFile = filepath.Join(dir, includeFile)
+		}
+
+		includeFile = filepath.Clean(includeFile)
+		for i, file := range including {
+			if file == includeFile {
+				return nil, nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(including[i:], " -> "), includeFile)
+			}
+		}
+
+		data, err := afero.ReadFile(fs, includeFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot read included file: %w", err)
+		}
+
+		cfg, err := yaml.NewConfig(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("included file %s: %w", includeFile, err)
+		}
+
+		cfg, migrateWarnings, err := migrate(cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("included file %s: %w", includeFile, err)
+		}
+
+		for _, warning := range migrateWarnings {
+			warnings = append(warnings, fmt.Sprintf("included file %s: %s", includeFile, warning))
+		}
+
+		var cfgfile ConfigFile
+		if err := cfg.Unpack(&cfgfile); err != nil {
+			return nil, nil, fmt.Errorf("included file %s: %w", includeFile, err)
+		}
+
+		if len(cfgfile.Constraints) > 0 || len(cfgfile.Correlations) > 0 || len(cfgfile.Sessions) > 0 || len(cfgfile.Timeline) > 0 || len(cfgfile.Tenants) > 0 || cfgfile.Seed != nil || cfgfile.MaxDuration != 0 {
+			return nil, nil, fmt.Errorf("included file %s: %w", includeFile, includeInvalidConfig)
+		}
+
+		includeDir := filepath.Dir(includeFile)
+		nested, nestedWarnings, err := includeFields(fs, includeDir, cfgfile.Include, append(including[:len(including):len(including)], includeFile))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		fields = append(fields, nested...)
+		warnings = append(warnings, nestedWarnings...)
+
+		for _, c := range cfgfile.Fields {
+			if c, err = c.loadSampleFile(fs, includeDir); err != nil {
+				return nil, nil, fmt.Errorf("included file %s: field %s: %w", includeFile, c.Name, err)
+			}
+
+			if c.TimeOfDay != nil {
+				for k, window := range c.TimeOfDay.Windows {
+					if c.TimeOfDay.Windows[k].Field, err = window.Field.loadSampleFile(fs, includeDir); err != nil {
+						return nil, nil, fmt.Errorf("included file %s: field %s: time_of_day window #%d: %w", includeFile, c.Name, k, err)
+					}
+				}
+			}
+
+			fields = append(fields, c)
+		}
+	}
+
+	return fields, warnings, nil
+}
+
+// Warnings returns the deprecation warnings of the config, to report to the user
+func (c Config) Warnings() []string {
+	return c.warnings
+}
+
 func (c Config) GetField(fieldName string) (ConfigField, bool) {
 	v, ok := c.m[fieldName]
 	return v, ok
 }
 
+func (c Config) Constraints() []Constraint {
+	return c.constraints
+}
+
+func (c Config) Correlations() []Correlation {
+	return c.correlations
+}
+
+func (c Config) Sessions() []Session {
+	return c.sessions
+}
+
+func (c Config) Timeline() []TimelineStep {
+	return c.timeline
+}
+
+func (c Config) Tenants() []Tenant {
+	return c.tenants
+}
+
+// Seed returns the seed of the rand the values are generated with, and whether it's set in the config
+func (c Config) Seed() (int64, bool) {
+	if c.seed == nil {
+		return 0, false
+	}
+
+	return *c.seed, true
+}
+
+// MaxDuration returns the time budget of the generation set in the config, zero when not set
+func (c Config) MaxDuration() time.Duration {
+	return c.maxDuration
+}
+
+// WithTenant returns the config of the i-th tenant: its config entries replace the ones of the same fields,
+// and it has no tenants.
+func (c Config) WithTenant(i int) Config {
+	outCfg := Config{
+		m:            make(map[string]ConfigField, len(c.m)),
+		constraints:  c.constraints,
+		correlations: c.correlations,
+		sessions:     c.sessions,
+		seed:         c.seed,
+		maxDuration:  c.maxDuration,
+	}
+
+	for name, field := range c.m {
+		outCfg.m[name] = field
+	}
+
+	for _, field := range c.tenants[i].Fields {
+		outCfg.m[field.Name] = field
+	}
+
+	return outCfg
+}
+
+// WithTimelineSteps returns the config in effect after the first n steps of the timeline are applied
+func (c Config) WithTimelineSteps(n int) Config {
+	outCfg := Config{
+		m:            make(map[string]ConfigField, len(c.m)),
+		constraints:  c.constraints,
+		correlations: c.correlations,
+		sessions:     c.sessions,
+		timeline:     c.timeline,
+		tenants:      c.tenants,
+		seed:         c.seed,
+		maxDuration:  c.maxDuration,
+	}
+
+	for name, field := range c.m {
+		outCfg.m[name] = field
+	}
+
+	for _, step := range c.timeline[:n] {
+		for _, field := range step.Fields {
+			outCfg.m[field.Name] = field
+		}
+	}
+
+	return outCfg
+}
+
+// WithField returns a copy of the config with the config of the field replaced by configField
+func (c Config) WithField(fieldName string, configField ConfigField) Config {
+	outCfg := c.WithTimelineSteps(0)
+	outCfg.SetField(fieldName, configField)
+
+	return outCfg
+}
+
 func (c Config) SetField(fieldName string, configField ConfigField) {
 	configField.Name = fieldName
-	c.m[fieldName] = configField
+	c.m[fieldName] = configField.withUnitDefaults()
 }
@@ -48,6 +48,64 @@ func TestSetField(t *testing.T) {
 	assert.Equal(t, "foobaz", f.Value.(string))
 }
 
+func TestLoadConfig_SampleFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	configFile := "/configs/cfg.yml"
+
+	afero.WriteFile(fs, configFile, []byte("fields:\n  - name: field\n    samples: [a]\n    sample_file: samples.txt\n"), 0666)
+	afero.WriteFile(fs, "/configs/samples.txt", []byte("b\n\n  c  \nb\n"), 0666)
+
+	cfg, err := LoadConfig(fs, configFile)
+	assert.Nil(t, err)
+
+	f, ok := cfg.GetField("field")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b", "c", "b"}, f.Samples)
+
+	afero.WriteFile(fs, configFile, []byte("fields:\n  - name: field\n    sample_file: missing.txt\n"), 0666)
+	_, err = LoadConfig(fs, configFile)
+	assert.NotNil(t, err)
+}
+
+func TestLoadConfig_Include(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	configFile := "/configs/cfg.yml"
+
+	afero.WriteFile(fs, configFile, []byte("version: 3\ninclude: [blocks/agent.yml]\nfields:\n  - name: host.name\n    value: override\n"), 0666)
+	afero.WriteFile(fs, "/configs/blocks/agent.yml", []byte("version: 3\ninclude: [common/host.yml]\nfields:\n  - name: agent.type\n    sample_file: agents.txt\n"), 0666)
+	afero.WriteFile(fs, "/configs/blocks/agents.txt", []byte("filebeat\nmetricbeat\n"), 0666)
+	afero.WriteFile(fs, "/configs/blocks/common/host.yml", []byte("version: 3\nfields:\n  - name: host.name\n    cardinality: 10\n  - name: host.os.name\n    value: linux\n"), 0666)
+
+	cfg, err := LoadConfig(fs, configFile)
+	assert.Nil(t, err)
+	assert.Empty(t, cfg.Warnings())
+
+	f, ok := cfg.GetField("agent.type")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"filebeat", "metricbeat"}, f.Samples)
+
+	f, ok = cfg.GetField("host.os.name")
+	assert.True(t, ok)
+	assert.Equal(t, "linux", f.Value)
+
+	f, ok = cfg.GetField("host.name")
+	assert.True(t, ok)
+	assert.Equal(t, "override", f.Value)
+	assert.Equal(t, 0, f.Cardinality)
+
+	afero.WriteFile(fs, "/configs/blocks/common/host.yml", []byte("version: 3\ninclude: [../agent.yml]\n"), 0666)
+	_, err = LoadConfig(fs, configFile)
+	assert.ErrorContains(t, err, "include cycle: /configs/blocks/agent.yml -> /configs/blocks/common/host.yml -> /configs/blocks/agent.yml")
+
+	afero.WriteFile(fs, "/configs/blocks/common/host.yml", []byte("version: 3\ntimeline:\n  - at_event: 10\n"), 0666)
+	_, err = LoadConfig(fs, configFile)
+	assert.ErrorIs(t, err, includeInvalidConfig)
+
+	afero.WriteFile(fs, configFile, []byte("include: [missing.yml]\n"), 0666)
+	_, err = LoadConfig(fs, configFile)
+	assert.NotNil(t, err)
+}
+
 func TestIsValidForDateField(t *testing.T) {
 	testCases := []struct {
 		scenario string
@@ -55,86 +113,2458 @@ func TestIsValidForDateField(t *testing.T) {
 		hasError bool
 	}{
 		{
-			scenario: "no range",
-			config:   "name: field",
+			scenario: "no range",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "zero period",
+			config:   "name: field\nrange:\n  period: 0",
+			hasError: false,
+		},
+		{
+			scenario: "positive period",
+			config:   "name: field\nrange:\n  period: 1",
+			hasError: false,
+		},
+		{
+			scenario: "negative period",
+			config:   "name: field\nrange:\n  period: -1",
+			hasError: false,
+		},
+		{
+			scenario: "form only",
+			config:   "name: field\nrange:\n  from: \"2006-01-02T15:04:05+07:00\"",
+			hasError: false,
+		},
+		{
+			scenario: "form and zero period",
+			config:   "name: field\nrange:\n  period: 0\n  from: \"2006-01-02T15:04:05+07:00\"",
+			hasError: false,
+		},
+		{
+			scenario: "form and positive period",
+			config:   "name: field\nrange:\n  period: 1\n  from: \"2006-01-02T15:04:05+07:00\"",
+			hasError: true,
+		},
+		{
+			scenario: "form and negative period",
+			config:   "name: field\nrange:\n  period: -1\n  from: \"2006-01-02T15:04:05+07:00\"",
+			hasError: true,
+		},
+		{
+			scenario: "to only",
+			config:   "name: field\nrange:\n  to: \"2006-01-02T15:04:05-07:00\"",
+			hasError: false,
+		},
+		{
+			scenario: "to and zero period",
+			config:   "name: field\nrange:\n  period: 0\n  to: \"2006-01-02T15:04:05-07:00\"",
+			hasError: false,
+		},
+		{
+			scenario: "to and positive period",
+			config:   "name: field\nrange:\n  period: 1\n  to: \"2006-01-02T15:04:05-07:00\"",
+			hasError: true,
+		},
+		{
+			scenario: "to and negative period",
+			config:   "name: field\nrange:\n  period: -1\n  to: \"2006-01-02T15:04:05-07:00\"",
+			hasError: true,
+		},
+		{
+			scenario: "from and to only",
+			config:   "name: field\nrange:\n  from: \"2006-01-02T15:04:05-07:00\"\n  to: \"2006-01-02T15:04:05+07:00\"",
+			hasError: false,
+		},
+		{
+			scenario: "from and to and zero period",
+			config:   "name: field\nrange:\n  period: 0\n  from: \"2006-01-02T15:04:05-07:00\"\n\n  to: \"2006-01-02T15:04:05+07:00\"",
+			hasError: false,
+		},
+		{
+			scenario: "from and to and positive period",
+			config:   "name: field\nrange:\n  period: 1\n  from: \"2006-01-02T15:04:05-07:00\"\n  to: \"2006-01-02T15:04:05+07:00\"",
+			hasError: true,
+		},
+		{
+			scenario: "from and to and negative period",
+			config:   "name: field\nrange:\n  period: -1\n  from: \"2006-01-02T15:04:05-07:00\"\n  to: \"2006-01-02T15:04:05+07:00\"",
+			hasError: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var config ConfigField
+			err = cfg.Unpack(&config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = config.ValidForDateField()
+			if testCase.hasError && err == nil {
+
+			}
+
+			if !testCase.hasError && err != nil {
+
+			}
+		})
+	}
+}
+
+func TestIsValidCounter(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no range, no counter",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "range with both min and max, no counter",
+			config:   "name: field\nrange:\n  min: 1\n  max: 10",
+			hasError: false,
+		},
+		{
+			scenario: "range with min and no max, no counter",
+			config:   "name: field\nrange:\n  min: 1",
+			hasError: false,
+		},
+		{
+			scenario: "range with max and no min, no counter",
+			config:   "name: field\nrange:\n  max: 10",
+			hasError: false,
+		},
+		{
+			scenario: "with counter, no range",
+			config:   "name: field\ncounter: true",
+			hasError: false,
+		},
+		{
+			scenario: "range with both min and max, with counter",
+			config:   "name: field\nrange:\n  min: 1\n  max: 10\ncounter: true",
+			hasError: true,
+		},
+		{
+			scenario: "range with min and no max, with counter",
+			config:   "name: field\nrange:\n  min: 1\ncounter: true",
+			hasError: true,
+		},
+		{
+			scenario: "range with max and no min, with counter",
+			config:   "name: field\nrange:\n  max: 10\ncounter: true",
+			hasError: true,
+		},
+		{
+			scenario: "range with both min and max, with counter:false",
+			config:   "name: field\nrange:\n  min: 1\n  max: 10\ncounter: false",
+			hasError: false,
+		},
+		{
+			scenario: "range with min and no max, with counter:false",
+			config:   "name: field\nrange:\n  min: 1\ncounter: false",
+			hasError: false,
+		},
+		{
+			scenario: "range with max and no min, with counter:false",
+			config:   "name: field\nrange:\n  max: 10\ncounter: false",
+			hasError: false,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var config ConfigField
+			err = cfg.Unpack(&config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = config.ValidCounter()
+			if testCase.hasError && err == nil {
+
+			}
+
+			if !testCase.hasError && err != nil {
+
+			}
+		})
+	}
+}
+
+func TestIsValidCounterRate(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no counter_rate",
+			config:   "name: field\ncounter: true",
+			hasError: false,
+		},
+		{
+			scenario: "counter_rate with counter",
+			config:   "name: field\ncounter: true\ncounter_rate:\n  min: 10\n  max: 20",
+			hasError: false,
+		},
+		{
+			scenario: "counter_rate with equal min and max",
+			config:   "name: field\ncounter: true\ncounter_rate:\n  min: 10\n  max: 10",
+			hasError: false,
+		},
+		{
+			scenario: "counter_rate without counter",
+			config:   "name: field\ncounter_rate:\n  min: 10\n  max: 20",
+			hasError: true,
+		},
+		{
+			scenario: "counter_rate with fuzziness",
+			config:   "name: field\ncounter: true\nfuzziness: 0.1\ncounter_rate:\n  min: 10\n  max: 20",
+			hasError: true,
+		},
+		{
+			scenario: "counter_rate with negative min",
+			config:   "name: field\ncounter: true\ncounter_rate:\n  min: -10\n  max: 20",
+			hasError: true,
+		},
+		{
+			scenario: "counter_rate with min greater than max",
+			config:   "name: field\ncounter: true\ncounter_rate:\n  min: 20\n  max: 10",
+			hasError: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var config ConfigField
+			err = cfg.Unpack(&config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = config.ValidCounterRate()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidConstant(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no constant",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "per run constant",
+			config:   "name: field\nper_run_constant: true",
+			hasError: false,
+		},
+		{
+			scenario: "per batch constant",
+			config:   "name: field\nper_batch_constant: true",
+			hasError: false,
+		},
+		{
+			scenario: "both per run and per batch constant",
+			config:   "name: field\nper_run_constant: true\nper_batch_constant: true",
+			hasError: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var config ConfigField
+			err = cfg.Unpack(&config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = config.ValidConstant()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatal("expected no error but got one")
+			}
+		})
+	}
+}
+
+func TestUnitDefaults(t *testing.T) {
+	testCases := []struct {
+		scenario  string
+		config    string
+		min       float64
+		max       float64
+		precision int
+		hasError  bool
+	}{
+		{
+			scenario:  "percent",
+			config:    "fields:\n  - name: field\n    unit: percent",
+			min:       0,
+			max:       100,
+			precision: 1,
+		},
+		{
+			scenario:  "bytes",
+			config:    "fields:\n  - name: field\n    unit: bytes",
+			min:       0,
+			max:       1 << 30,
+			precision: 0,
+		},
+		{
+			scenario:  "percent with explicit range and precision",
+			config:    "fields:\n  - name: field\n    unit: percent\n    precision: 3\n    range:\n      min: 10\n      max: 20",
+			min:       10,
+			max:       20,
+			precision: 3,
+		},
+		{
+			scenario: "unknown unit",
+			config:   "fields:\n  - name: field\n    unit: parsecs",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := LoadConfigFromYaml([]byte(testCase.config))
+			if testCase.hasError {
+				if err == nil {
+					t.Fatal("expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, ok := cfg.GetField("field")
+			assert.True(t, ok)
+
+			min, err := f.Range.MinAsFloat64()
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.min, min)
+
+			max, err := f.Range.MaxAsFloat64()
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.max, max)
+
+			assert.Equal(t, testCase.precision, *f.Precision)
+		})
+	}
+}
+
+func TestIsValidCumulative(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no cumulative_of",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "cumulative_of",
+			config:   "name: field\ncumulative_of: delta",
+			hasError: false,
+		},
+		{
+			scenario: "cumulative_of with cumulative_entity",
+			config:   "name: field\ncumulative_of: delta\ncumulative_entity: host.name",
+			hasError: false,
+		},
+		{
+			scenario: "cumulative_entity without cumulative_of",
+			config:   "name: field\ncumulative_entity: host.name",
+			hasError: true,
+		},
+		{
+			scenario: "cumulative_of with counter",
+			config:   "name: field\ncumulative_of: delta\ncounter: true",
+			hasError: true,
+		},
+		{
+			scenario: "cumulative_of itself",
+			config:   "name: field\ncumulative_of: field",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidCumulative()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidHash(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no hash_of",
+			config:   "name: field\ncardinality: 10",
+			hasError: false,
+		},
+		{
+			scenario: "hash_of",
+			config:   "name: field\nhash_of: host.name\nrange:\n  min: 1\n  max: 10",
+			hasError: false,
+		},
+		{
+			scenario: "hash_of with cardinality",
+			config:   "name: field\nhash_of: host.name\ncardinality: 10",
+			hasError: true,
+		},
+		{
+			scenario: "hash_of with counter",
+			config:   "name: field\nhash_of: host.name\ncounter: true",
+			hasError: true,
+		},
+		{
+			scenario: "hash_of with per_run_constant",
+			config:   "name: field\nhash_of: host.name\nper_run_constant: true",
+			hasError: true,
+		},
+		{
+			scenario: "hash_of itself",
+			config:   "name: field\nhash_of: field",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidHash()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidASN(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no asn_of",
+			config:   "name: field\ncardinality: 10",
+			hasError: false,
+		},
+		{
+			scenario: "asn_of",
+			config:   "name: source.as.number\nasn_of: source.ip",
+			hasError: false,
+		},
+		{
+			scenario: "asn_of with enum",
+			config:   "name: source.as.number\nasn_of: source.ip\nenum: [1, 2]",
+			hasError: true,
+		},
+		{
+			scenario: "asn_of with hash_of",
+			config:   "name: source.as.number\nasn_of: source.ip\nhash_of: source.ip",
+			hasError: true,
+		},
+		{
+			scenario: "asn_of itself",
+			config:   "name: source.ip\nasn_of: source.ip",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidASN()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidOrder(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no order",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "unordered",
+			config:   "name: field\norder: unordered",
+			hasError: false,
+		},
+		{
+			scenario: "strictly increasing",
+			config:   "name: field\norder: strictly_increasing",
+			hasError: false,
+		},
+		{
+			scenario: "increasing per entity",
+			config:   "name: field\norder: increasing_per_entity\norder_entity: host.name",
+			hasError: false,
+		},
+		{
+			scenario: "increasing per entity without entity",
+			config:   "name: field\norder: increasing_per_entity",
+			hasError: true,
+		},
+		{
+			scenario: "entity without increasing per entity",
+			config:   "name: field\norder: strictly_increasing\norder_entity: host.name",
+			hasError: true,
+		},
+		{
+			scenario: "strictly increasing with cardinality",
+			config:   "name: field\norder: strictly_increasing\ncardinality: 10",
+			hasError: true,
+		},
+		{
+			scenario: "unknown order",
+			config:   "name: field\norder: decreasing",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidOrder()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidBucket(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no bucket",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "bucket",
+			config:   "name: field\nbucket:\n  interval: 10s\n  entity: host.name",
+			hasError: false,
+		},
+		{
+			scenario: "bucket without interval",
+			config:   "name: field\nbucket:\n  docs: 2",
+			hasError: true,
+		},
+		{
+			scenario: "bucket with negative docs",
+			config:   "name: field\nbucket:\n  interval: 10s\n  docs: -1",
+			hasError: true,
+		},
+		{
+			scenario: "bucket with gaps",
+			config:   "name: field\nbucket:\n  interval: 10s\n  gaps:\n    probability: 0.1\n    min: 2\n    max: 5",
+			hasError: false,
+		},
+		{
+			scenario: "bucket with gaps always",
+			config:   "name: field\nbucket:\n  interval: 10s\n  gaps:\n    probability: 1",
+			hasError: true,
+		},
+		{
+			scenario: "bucket with gaps max less than min",
+			config:   "name: field\nbucket:\n  interval: 10s\n  gaps:\n    probability: 0.1\n    min: 5\n    max: 2",
+			hasError: true,
+		},
+		{
+			scenario: "bucket with period",
+			config:   "name: field\nperiod: 1h\nbucket:\n  interval: 10s",
+			hasError: true,
+		},
+		{
+			scenario: "bucket with order",
+			config:   "name: field\norder: strictly_increasing\nbucket:\n  interval: 10s",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidBucket()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidUnicodeSalt(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no unicode salt",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "fraction",
+			config:   "name: field\nunicode_salt: 0.1",
+			hasError: false,
+		},
+		{
+			scenario: "all values",
+			config:   "name: field\nunicode_salt: 1",
+			hasError: false,
+		},
+		{
+			scenario: "negative",
+			config:   "name: field\nunicode_salt: -0.1",
+			hasError: true,
+		},
+		{
+			scenario: "greater than one",
+			config:   "name: field\nunicode_salt: 1.5",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidUnicodeSalt()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidAssert(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no assert",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "cardinality and mean",
+			config:   "name: field\nassert:\n  cardinality_between: [900, 1100]\n  mean_between: [40, 60]",
+			hasError: false,
+		},
+		{
+			scenario: "single value",
+			config:   "name: field\nassert:\n  cardinality_between: [900]",
+			hasError: true,
+		},
+		{
+			scenario: "minimum greater than maximum",
+			config:   "name: field\nassert:\n  mean_between: [60, 40]",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidAssert()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidQuantiles(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no quantiles",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "quantiles",
+			config:   "name: field\nquantiles:\n  p50: 120\n  p90: 300\n  p99.9: 900",
+			hasError: false,
+		},
+		{
+			scenario: "single quantile",
+			config:   "name: field\nquantiles:\n  p50: 120",
+			hasError: true,
+		},
+		{
+			scenario: "not a percentile",
+			config:   "name: field\nquantiles:\n  p50: 120\n  median: 300",
+			hasError: true,
+		},
+		{
+			scenario: "percentile out of bounds",
+			config:   "name: field\nquantiles:\n  p50: 120\n  p100: 300",
+			hasError: true,
+		},
+		{
+			scenario: "decreasing values",
+			config:   "name: field\nquantiles:\n  p50: 300\n  p90: 120",
+			hasError: true,
+		},
+		{
+			scenario: "values out of range",
+			config:   "name: field\nrange:\n  max: 200\nquantiles:\n  p50: 120\n  p90: 300",
+			hasError: true,
+		},
+		{
+			scenario: "with counter",
+			config:   "name: field\ncounter: true\nquantiles:\n  p50: 120\n  p90: 300",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidQuantiles()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidDistribution(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no distribution",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "uniform distribution",
+			config:   "name: field\ndistribution: uniform",
+			hasError: false,
+		},
+		{
+			scenario: "normal distribution",
+			config:   "name: field\ndistribution: normal\nmean: 0\nstddev: 1.5",
+			hasError: false,
+		},
+		{
+			scenario: "normal distribution without mean",
+			config:   "name: field\ndistribution: normal\nstddev: 1.5",
+			hasError: true,
+		},
+		{
+			scenario: "normal distribution with stddev 0",
+			config:   "name: field\ndistribution: normal\nmean: 10\nstddev: 0",
+			hasError: true,
+		},
+		{
+			scenario: "stddev without normal distribution",
+			config:   "name: field\nstddev: 1.5",
+			hasError: true,
+		},
+		{
+			scenario: "unknown distribution",
+			config:   "name: field\ndistribution: poisson",
+			hasError: true,
+		},
+		{
+			scenario: "normal distribution with counter",
+			config:   "name: field\ndistribution: normal\nmean: 10\nstddev: 1\ncounter: true",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidDistribution()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidSamples(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no samples",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "samples with smoothing",
+			config:   "name: field\nsamples: [\"1\", \"2\"]\nsmoothing: 0.1",
+			hasError: false,
+		},
+		{
+			scenario: "smoothing without samples",
+			config:   "name: field\nsmoothing: 0.1",
+			hasError: true,
+		},
+		{
+			scenario: "negative smoothing",
+			config:   "name: field\nsamples: [\"1\", \"2\"]\nsmoothing: -0.1",
+			hasError: true,
+		},
+		{
+			scenario: "samples with enum",
+			config:   "name: field\nsamples: [a]\nenum: [b]",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidSamples()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidTimeOfDay(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no time_of_day",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "valid windows",
+			config:   "name: field\ntime_of_day:\n  timestamp: ts\n  windows:\n    - from: \"09:00\"\n      to: \"17:00\"\n    - from: \"22:00\"\n      to: \"24:00\"",
+			hasError: false,
+		},
+		{
+			scenario: "window across midnight",
+			config:   "name: field\ntime_of_day:\n  timestamp: ts\n  windows:\n    - from: \"22:00\"\n      to: \"02:00\"",
+			hasError: false,
+		},
+		{
+			scenario: "missing timestamp",
+			config:   "name: field\ntime_of_day:\n  windows:\n    - from: \"09:00\"\n      to: \"17:00\"",
+			hasError: true,
+		},
+		{
+			scenario: "timestamp is the field itself",
+			config:   "name: field\ntime_of_day:\n  timestamp: field\n  windows:\n    - from: \"09:00\"\n      to: \"17:00\"",
+			hasError: true,
+		},
+		{
+			scenario: "no windows",
+			config:   "name: field\ntime_of_day:\n  timestamp: ts",
+			hasError: true,
+		},
+		{
+			scenario: "invalid time of the day",
+			config:   "name: field\ntime_of_day:\n  timestamp: ts\n  windows:\n    - from: \"9am\"\n      to: \"17:00\"",
+			hasError: true,
+		},
+		{
+			scenario: "empty window",
+			config:   "name: field\ntime_of_day:\n  timestamp: ts\n  windows:\n    - from: \"09:00\"\n      to: \"09:00\"",
+			hasError: true,
+		},
+		{
+			scenario: "nested time_of_day",
+			config:   "name: field\ntime_of_day:\n  timestamp: ts\n  windows:\n    - from: \"09:00\"\n      to: \"17:00\"\n      field:\n        time_of_day:\n          timestamp: ts",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidTimeOfDay()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidGeoPoint(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no geo settings",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "valid bbox and format",
+			config:   "name: field\ngeo_format: geohash\ngeo_bbox:\n  top_left: {lat: 48.9, lon: 2.2}\n  bottom_right: {lat: 48.8, lon: 2.5}",
+			hasError: false,
+		},
+		{
+			scenario: "bbox across the antimeridian",
+			config:   "name: field\ngeo_bbox:\n  top_left: {lat: 10, lon: 170}\n  bottom_right: {lat: -10, lon: -170}",
+			hasError: false,
+		},
+		{
+			scenario: "unknown format",
+			config:   "name: field\ngeo_format: wkt",
+			hasError: true,
+		},
+		{
+			scenario: "top below bottom",
+			config:   "name: field\ngeo_bbox:\n  top_left: {lat: 48.8, lon: 2.2}\n  bottom_right: {lat: 48.9, lon: 2.5}",
+			hasError: true,
+		},
+		{
+			scenario: "latitude out of range",
+			config:   "name: field\ngeo_bbox:\n  top_left: {lat: 91, lon: 2.2}\n  bottom_right: {lat: 48.9, lon: 2.5}",
+			hasError: true,
+		},
+		{
+			scenario: "longitude out of range",
+			config:   "name: field\ngeo_bbox:\n  top_left: {lat: 48.9, lon: 2.2}\n  bottom_right: {lat: 48.8, lon: 181}",
+			hasError: true,
+		},
+		{
+			scenario: "valid trajectory",
+			config:   "name: field\ngeo_trajectory: {max_speed: 50, max_turn: 0, entity: host.name, timestamp: '@timestamp'}",
+			hasError: false,
+		},
+		{
+			scenario: "trajectory without max_speed",
+			config:   "name: field\ngeo_trajectory: {entity: host.name}",
+			hasError: true,
+		},
+		{
+			scenario: "trajectory turning more than half a circle",
+			config:   "name: field\ngeo_trajectory: {max_speed: 50, max_turn: 270}",
+			hasError: true,
+		},
+		{
+			scenario: "trajectory with cardinality",
+			config:   "name: field\ncardinality: 10\ngeo_trajectory: {max_speed: 50}",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidGeoPoint()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidHistogram(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no histogram settings",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "valid histogram",
+			config:   "name: field\nrange:\n  min: 0\n  max: 100\nhistogram:\n  buckets: 20\n  observations: 1000",
+			hasError: false,
+		},
+		{
+			scenario: "negative buckets",
+			config:   "name: field\nhistogram:\n  buckets: -1",
+			hasError: true,
+		},
+		{
+			scenario: "negative observations",
+			config:   "name: field\nhistogram:\n  observations: -1",
+			hasError: true,
+		},
+		{
+			scenario: "empty range",
+			config:   "name: field\nrange:\n  min: 10\n  max: 10\nhistogram:\n  buckets: 20",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidHistogram()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestEnum_Unpack(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		expected Enum
+		hasError bool
+	}{
+		{
+			scenario: "plain values",
+			config:   "enum: [GET, 200, 1.5, true]",
+			expected: Enum{{Value: "GET", Weight: 1}, {Value: "200", Weight: 1}, {Value: "1.5", Weight: 1}, {Value: "true", Weight: 1}},
+		},
+		{
+			scenario: "weighted values",
+			config:   "enum:\n  - value: GET\n    weight: 70\n  - {value: 404, weight: 0.5}\n  - PUT",
+			expected: Enum{{Value: "GET", Weight: 70}, {Value: "404", Weight: 0.5}, {Value: "PUT", Weight: 1}},
+		},
+		{
+			scenario: "missing value",
+			config:   "enum:\n  - weight: 70",
+			hasError: true,
+		},
+		{
+			scenario: "zero weight",
+			config:   "enum:\n  - {value: GET, weight: 0}",
+			hasError: true,
+		},
+		{
+			scenario: "negative weight",
+			config:   "enum:\n  - {value: GET, weight: -1}",
+			hasError: true,
+		},
+		{
+			scenario: "unknown key",
+			config:   "enum:\n  - {value: GET, probability: 0.5}",
+			hasError: true,
+		},
+		{
+			scenario: "nested value",
+			config:   "enum:\n  - {value: [GET], weight: 1}",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if testCase.hasError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, configField.Enum)
+		})
+	}
+}
+
+func TestIsValidInterArrival(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no inter_arrival",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "exponential inter_arrival",
+			config:   "name: field\ninter_arrival:\n  distribution: exponential\n  mean: 1s\n  entity: host.name",
+			hasError: false,
+		},
+		{
+			scenario: "lognormal inter_arrival",
+			config:   "name: field\ninter_arrival:\n  distribution: lognormal\n  mean: 1s\n  stddev: 500ms",
+			hasError: false,
+		},
+		{
+			scenario: "lognormal inter_arrival without stddev",
+			config:   "name: field\ninter_arrival:\n  distribution: lognormal\n  mean: 1s",
+			hasError: true,
+		},
+		{
+			scenario: "fixed inter_arrival with stddev",
+			config:   "name: field\ninter_arrival:\n  distribution: fixed\n  mean: 1s\n  stddev: 500ms",
+			hasError: true,
+		},
+		{
+			scenario: "inter_arrival without mean",
+			config:   "name: field\ninter_arrival:\n  distribution: exponential",
+			hasError: true,
+		},
+		{
+			scenario: "inter_arrival with unknown distribution",
+			config:   "name: field\ninter_arrival:\n  distribution: uniform\n  mean: 1s",
+			hasError: true,
+		},
+		{
+			scenario: "inter_arrival with period",
+			config:   "name: field\nperiod: 1h\ninter_arrival:\n  distribution: fixed\n  mean: 1s",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidInterArrival()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidStrictCardinality(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no strict_cardinality",
+			config:   "name: field\ncardinality: 10",
+			hasError: false,
+		},
+		{
+			scenario: "strict_cardinality",
+			config:   "name: field\ncardinality: 10\nstrict_cardinality: true",
+			hasError: false,
+		},
+		{
+			scenario: "strict_cardinality without cardinality",
+			config:   "name: field\nstrict_cardinality: true",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidStrictCardinality()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidCardinalityDistribution(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no cardinality_distribution",
+			config:   "name: field\ncardinality: 10",
+			hasError: false,
+		},
+		{
+			scenario: "uniform",
+			config:   "name: field\ncardinality: 10\ncardinality_distribution: uniform",
+			hasError: false,
+		},
+		{
+			scenario: "zipf",
+			config:   "name: field\ncardinality: 10\ncardinality_distribution: zipf",
+			hasError: false,
+		},
+		{
+			scenario: "unknown distribution",
+			config:   "name: field\ncardinality: 10\ncardinality_distribution: pareto",
+			hasError: true,
+		},
+		{
+			scenario: "zipf without cardinality",
+			config:   "name: field\ncardinality_distribution: zipf",
+			hasError: true,
+		},
+		{
+			scenario: "zipf with strict_cardinality",
+			config:   "name: field\ncardinality: 10\nstrict_cardinality: true\ncardinality_distribution: zipf",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidCardinalityDistribution()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidExcludeValues(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no exclude_values",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "exclude_values",
+			config:   "name: field\nexclude_values: [example.com, 127.0.0.1, 0, 1.5, true]",
+			hasError: false,
+		},
+		{
+			scenario: "exclude_values with object",
+			config:   "name: field\nexclude_values: [{value: example.com}]",
+			hasError: true,
+		},
+		{
+			scenario: "exclude_values with value",
+			config:   "name: field\nvalue: example.org\nexclude_values: [example.com]",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidExcludeValues()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidAffixes(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no affixes",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "prefix and suffix",
+			config:   "name: field\nprefix: sha256-\nsuffix: .log",
+			hasError: false,
+		},
+		{
+			scenario: "format",
+			config:   "name: field\nformat: 'i-%s'",
+			hasError: false,
+		},
+		{
+			scenario: "format with width and literal percent",
+			config:   "name: field\nformat: '%10s%%'",
+			hasError: false,
+		},
+		{
+			scenario: "format without verb",
+			config:   "name: field\nformat: 'i-'",
+			hasError: true,
+		},
+		{
+			scenario: "format with two verbs",
+			config:   "name: field\nformat: '%s-%s'",
+			hasError: true,
+		},
+		{
+			scenario: "format with wrong verb",
+			config:   "name: field\nformat: '%d'",
+			hasError: true,
+		},
+		{
+			scenario: "prefix with value",
+			config:   "name: field\nvalue: a\nprefix: i-",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidAffixes()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidCIDR(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no cidr",
+			config:   "name: source.ip",
+			hasError: false,
+		},
+		{
+			scenario: "cidr",
+			config:   "name: source.ip\ncidr: [10.0.0.0/8, {value: 192.168.0.0/16, weight: 3}, fd00::/8]",
+			hasError: false,
+		},
+		{
+			scenario: "exclude reserved and ipv6 fraction",
+			config:   "name: source.ip\nexclude_reserved: true\nipv6_fraction: 0.2",
+			hasError: false,
+		},
+		{
+			scenario: "not a network",
+			config:   "name: source.ip\ncidr: [10.0.0.1]",
+			hasError: true,
+		},
+		{
+			scenario: "ipv6 fraction greater than 1",
+			config:   "name: source.ip\nipv6_fraction: 1.5",
+			hasError: true,
+		},
+		{
+			scenario: "cidr with value",
+			config:   "name: source.ip\ncidr: [10.0.0.0/8]\nvalue: 10.0.0.1",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidCIDR()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidGenerator(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no generator",
+			config:   "name: user_agent.original",
+			hasError: false,
+		},
+		{
+			scenario: "user_agent generator",
+			config:   "name: user_agent.original\ngenerator: user_agent",
+			hasError: false,
+		},
+		{
+			scenario: "user_agent weights",
+			config:   "name: user_agent.original\ngenerator: user_agent\nuser_agent:\n  browsers: {chrome: 70, firefox: 30}\n  os: {windows: 1}",
+			hasError: false,
+		},
+		{
+			scenario: "unknown generator",
+			config:   "name: user_agent.original\ngenerator: unknown",
+			hasError: true,
+		},
+		{
+			scenario: "user_agent weights without generator",
+			config:   "name: user_agent.original\nuser_agent:\n  browsers: {chrome: 1}",
+			hasError: true,
+		},
+		{
+			scenario: "negative weight",
+			config:   "name: user_agent.original\ngenerator: user_agent\nuser_agent:\n  browsers: {chrome: -1, firefox: 2}",
+			hasError: true,
+		},
+		{
+			scenario: "zero weights",
+			config:   "name: user_agent.original\ngenerator: user_agent\nuser_agent:\n  os: {linux: 0}",
+			hasError: true,
+		},
+		{
+			scenario: "url generator",
+			config:   "name: url.original\ngenerator: url\nurl:\n  schemes: [https]\n  domains: [example.com]\n  path_depth: {min: 1, max: 3}\n  query_params: {min: 0, max: 2}",
+			hasError: false,
+		},
+		{
+			scenario: "url part",
+			config:   "name: url.path\ngenerator: url\nurl:\n  part: path",
+			hasError: false,
+		},
+		{
+			scenario: "url without generator",
+			config:   "name: url.original\nurl:\n  schemes: [https]",
+			hasError: true,
+		},
+		{
+			scenario: "url empty domains",
+			config:   "name: url.original\ngenerator: url\nurl:\n  domains: []",
+			hasError: true,
+		},
+		{
+			scenario: "url path depth max below min",
+			config:   "name: url.original\ngenerator: url\nurl:\n  path_depth: {min: 3, max: 1}",
+			hasError: true,
+		},
+		{
+			scenario: "url unknown part",
+			config:   "name: url.original\ngenerator: url\nurl:\n  part: fragment",
+			hasError: true,
+		},
+		{
+			scenario: "tls generator",
+			config:   "name: tls.client.ja3\ngenerator: tls",
+			hasError: false,
+		},
+		{
+			scenario: "tls settings",
+			config:   "name: tls.client.ja3\ngenerator: tls\ntls:\n  part: client.ja3\n  clients: {chrome: 70, curl: 30}\n  server_names: [example.com]\n  timestamp: '@timestamp'",
+			hasError: false,
+		},
+		{
+			scenario: "tls without generator",
+			config:   "name: tls.client.ja3\ntls:\n  part: client.ja3",
+			hasError: true,
+		},
+		{
+			scenario: "tls unknown part",
+			config:   "name: tls.client.ja3\ngenerator: tls\ntls:\n  part: client.ja4",
+			hasError: true,
+		},
+		{
+			scenario: "tls zero weights",
+			config:   "name: tls.client.ja3\ngenerator: tls\ntls:\n  clients: {chrome: 0}",
+			hasError: true,
+		},
+		{
+			scenario: "tls empty server names",
+			config:   "name: tls.client.ja3\ngenerator: tls\ntls:\n  server_names: []",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidGenerator()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidSourceFor(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no source_for",
+			config:   "name: message",
+			hasError: false,
+		},
+		{
+			scenario: "field without format",
+			config:   "name: message\nsource_for:\n  - field: http.response.status_code",
+			hasError: false,
+		},
+		{
+			scenario: "fields with format",
+			config:   "name: message\nsource_for:\n  - field: http.response.status_code\n    format: 'status=%s'\n  - field: url.path\n    format: 'path=%s'",
+			hasError: false,
+		},
+		{
+			scenario: "missing field",
+			config:   "name: message\nsource_for:\n  - format: 'status=%s'",
+			hasError: true,
+		},
+		{
+			scenario: "field itself",
+			config:   "name: message\nsource_for:\n  - field: message",
+			hasError: true,
+		},
+		{
+			scenario: "format without verb",
+			config:   "name: message\nsource_for:\n  - field: http.response.status_code\n    format: 'status='",
+			hasError: true,
+		},
+		{
+			scenario: "with value",
+			config:   "name: message\nvalue: a\nsource_for:\n  - field: http.response.status_code",
+			hasError: true,
+		},
+		{
+			scenario: "with array_length",
+			config:   "name: message\narray_length:\n  max: 2\nsource_for:\n  - field: http.response.status_code",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidSourceFor()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidNormalize(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no normalize",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "lower case",
+			config:   "name: field\nnormalize:\n  case: lower",
+			hasError: false,
+		},
+		{
+			scenario: "upper case, spaces and diacritics",
+			config:   "name: field\nnormalize:\n  case: upper\n  replace_spaces: _\n  strip_diacritics: true",
+			hasError: false,
+		},
+		{
+			scenario: "invalid case",
+			config:   "name: field\nnormalize:\n  case: title",
+			hasError: true,
+		},
+		{
+			scenario: "normalize with value",
+			config:   "name: field\nvalue: a\nnormalize:\n  case: lower",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidNormalize()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidPattern(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no pattern",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "peak hours and weekend",
+			config:   "name: field\npattern:\n  peak_hours: [9, 10, 17]\n  peak_factor: 3\n  weekend_factor: 0.2",
+			hasError: false,
+		},
+		{
+			scenario: "seasonality",
+			config:   "name: field\npattern:\n  seasonality:\n    - amplitude: 0.5\n      period: 24h\n      peak: 14h",
+			hasError: false,
+		},
+		{
+			scenario: "peak hour out of the day",
+			config:   "name: field\npattern:\n  peak_hours: [24]",
+			hasError: true,
+		},
+		{
+			scenario: "negative weekend factor",
+			config:   "name: field\npattern:\n  weekend_factor: -1",
+			hasError: true,
+		},
+		{
+			scenario: "seasonality amplitude of 1",
+			config:   "name: field\npattern:\n  seasonality:\n    - amplitude: 1\n      period: 24h",
+			hasError: true,
+		},
+		{
+			scenario: "seasonality without period",
+			config:   "name: field\npattern:\n  seasonality:\n    - amplitude: 0.5",
+			hasError: true,
+		},
+		{
+			scenario: "seasonality peak after the period",
+			config:   "name: field\npattern:\n  seasonality:\n    - amplitude: 0.5\n      period: 24h\n      peak: 25h",
+			hasError: true,
+		},
+		{
+			scenario: "pattern with value",
+			config:   "name: field\nvalue: 2023-01-01T00:00:00Z\npattern:\n  peak_hours: [9]",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidPattern()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidReuse(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no reuse",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "reuse",
+			config:   "name: field\nreuse:\n  probability: 0.3\n  size: 100",
+			hasError: false,
+		},
+		{
+			scenario: "reuse without size",
+			config:   "name: field\nreuse:\n  probability: 1",
+			hasError: false,
+		},
+		{
+			scenario: "reuse without probability",
+			config:   "name: field\nreuse:\n  size: 100",
+			hasError: true,
+		},
+		{
+			scenario: "reuse with probability greater than 1",
+			config:   "name: field\nreuse:\n  probability: 1.5",
+			hasError: true,
+		},
+		{
+			scenario: "reuse with negative size",
+			config:   "name: field\nreuse:\n  probability: 0.3\n  size: -1",
+			hasError: true,
+		},
+		{
+			scenario: "reuse with cardinality",
+			config:   "name: field\ncardinality: 10\nreuse:\n  probability: 0.3",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidReuse()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidDynamicKeys(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no dynamic_keys",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "dynamic_keys",
+			config:   "name: field\ndynamic_keys:\n  min: 1\n  max: 5\n  cardinality: 20",
+			hasError: false,
+		},
+		{
+			scenario: "dynamic_keys with max only",
+			config:   "name: field\ndynamic_keys:\n  max: 5",
+			hasError: false,
+		},
+		{
+			scenario: "dynamic_keys without max",
+			config:   "name: field\ndynamic_keys:\n  min: 1",
+			hasError: true,
+		},
+		{
+			scenario: "min greater than max",
+			config:   "name: field\ndynamic_keys:\n  min: 6\n  max: 5",
+			hasError: true,
+		},
+		{
+			scenario: "cardinality less than max",
+			config:   "name: field\ndynamic_keys:\n  max: 5\n  cardinality: 4",
+			hasError: true,
+		},
+		{
+			scenario: "dynamic_keys with object_keys",
+			config:   "name: field\nobject_keys: [a, b]\ndynamic_keys:\n  max: 5",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidDynamicKeys()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidArrayLength(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "no array_length",
+			config:   "name: field",
+			hasError: false,
+		},
+		{
+			scenario: "array_length",
+			config:   "name: field\narray_length:\n  min: 1\n  max: 5",
+			hasError: false,
+		},
+		{
+			scenario: "array_length with max only",
+			config:   "name: field\narray_length:\n  max: 5",
+			hasError: false,
+		},
+		{
+			scenario: "array_length with max 0",
+			config:   "name: field\narray_length:\n  max: 0",
+			hasError: true,
+		},
+		{
+			scenario: "array_length with min greater than max",
+			config:   "name: field\narray_length:\n  min: 5\n  max: 1",
+			hasError: true,
+		},
+		{
+			scenario: "array_length with distinct",
+			config:   "name: field\narray_length:\n  max: 5\n  distinct: true",
+			hasError: false,
+		},
+		{
+			scenario: "array_length with values_from",
+			config:   "name: related.ip\narray_length:\n  min: 2\n  max: 4\n  values_from: [source.ip, destination.ip]",
+			hasError: false,
+		},
+		{
+			scenario: "array_length with more values_from than max",
+			config:   "name: related.ip\narray_length:\n  max: 1\n  values_from: [source.ip, destination.ip]",
+			hasError: true,
+		},
+		{
+			scenario: "array_length with values_from the field itself",
+			config:   "name: related.ip\narray_length:\n  max: 4\n  values_from: [related.ip]",
+			hasError: true,
+		},
+		{
+			scenario: "array_length with values_from and per_run_constant",
+			config:   "name: related.ip\nper_run_constant: true\narray_length:\n  max: 4\n  values_from: [source.ip]",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var configField ConfigField
+			err = cfg.Unpack(&configField)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = configField.ValidArrayLength()
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFromYaml_Timeline(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "ordered steps",
+			config:   "timeline:\n  - at_event: 10\n    fields:\n      - name: a\n        value: 1\n  - at_event: 20\n    fields:\n      - name: a\n        value: 2",
+			hasError: false,
+		},
+		{
+			scenario: "unordered steps",
+			config:   "timeline:\n  - at_event: 20\n    fields:\n      - name: a\n        value: 1\n  - at_event: 10\n    fields:\n      - name: a\n        value: 2",
+			hasError: true,
+		},
+		{
+			scenario: "step with invalid unit",
+			config:   "timeline:\n  - at_event: 10\n    fields:\n      - name: a\n        unit: meters",
+			hasError: true,
+		},
+		{
+			scenario: "ordered time steps",
+			config:   "timeline:\n  - at: 1m\n    fields:\n      - name: a\n        value: 1\n  - at_event: 10\n    set:\n      eps: 0.5x\n  - at: 2m\n    set:\n      eps: 2x\n    fields:\n      - name: a\n        value: 2",
+			hasError: false,
+		},
+		{
+			scenario: "unordered time steps",
+			config:   "timeline:\n  - at: 2m\n    fields:\n      - name: a\n        value: 1\n  - at: 1m\n    fields:\n      - name: a\n        value: 2",
+			hasError: true,
+		},
+		{
+			scenario: "step with both at_event and at",
+			config:   "timeline:\n  - at_event: 10\n    at: 1m\n    fields:\n      - name: a\n        value: 2",
+			hasError: true,
+		},
+		{
+			scenario: "step with eps not a multiple",
+			config:   "timeline:\n  - at: 1m\n    set:\n      eps: 100/s",
+			hasError: true,
+		},
+		{
+			scenario: "step with zero eps",
+			config:   "timeline:\n  - at: 1m\n    set:\n      eps: 0x",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := LoadConfigFromYaml([]byte(testCase.config))
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+
+			if !testCase.hasError {
+				field, _ := cfg.WithTimelineSteps(len(cfg.Timeline())).GetField("a")
+				if field.Value != uint64(2) {
+					t.Errorf("expected the value of the last step, got %v", field.Value)
+				}
+
+				if _, ok := cfg.GetField("a"); ok {
+					t.Errorf("expected base config not changed by the timeline")
+				}
+
+				for _, step := range cfg.Timeline() {
+					if factor, ok := step.Set.EPSFactor(); ok != (len(step.Set.EPS) > 0) || (ok && factor <= 0) {
+						t.Errorf("expected the eps %q parsed, got %v", step.Set.EPS, factor)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTimelineStep_Due(t *testing.T) {
+	atEvent := TimelineStep{AtEvent: 10}
+	assert.False(t, atEvent.Due(9, time.Hour))
+	assert.True(t, atEvent.Due(10, 0))
+
+	at := TimelineStep{AtEvent: 0, At: time.Minute}
+	assert.False(t, at.Due(100, 59*time.Second))
+	assert.True(t, at.Due(0, time.Minute))
+}
+
+func TestLoadConfigFromYaml_Tenants(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "tenants",
+			config:   "fields:\n  - name: a\n    value: base\ntenants:\n  - name: acme\n    weight: 2\n    fields:\n      - name: a\n        value: acme\n  - name: globex",
 			hasError: false,
 		},
 		{
-			scenario: "zero period",
-			config:   "name: field\nrange:\n  period: 0",
-			hasError: false,
+			scenario: "tenant without name",
+			config:   "tenants:\n  - weight: 2",
+			hasError: true,
 		},
 		{
-			scenario: "positive period",
-			config:   "name: field\nrange:\n  period: 1",
-			hasError: false,
+			scenario: "tenants with the same name",
+			config:   "tenants:\n  - name: acme\n  - name: acme",
+			hasError: true,
 		},
 		{
-			scenario: "negative period",
-			config:   "name: field\nrange:\n  period: -1",
-			hasError: false,
+			scenario: "tenant with negative weight",
+			config:   "tenants:\n  - name: acme\n    weight: -1",
+			hasError: true,
 		},
 		{
-			scenario: "form only",
-			config:   "name: field\nrange:\n  from: \"2006-01-02T15:04:05+07:00\"",
-			hasError: false,
+			scenario: "tenant field with invalid unit",
+			config:   "tenants:\n  - name: acme\n    fields:\n      - name: a\n        unit: meters",
+			hasError: true,
 		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := LoadConfigFromYaml([]byte(testCase.config))
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+
+			if !testCase.hasError {
+				tenants := cfg.Tenants()
+				assert.Equal(t, 2.0, tenants[0].Weight)
+				assert.Equal(t, 1.0, tenants[1].Weight, "expected the default weight")
+
+				field, _ := cfg.WithTenant(0).GetField("a")
+				assert.Equal(t, "acme", field.Value)
+
+				field, _ = cfg.WithTenant(1).GetField("a")
+				assert.Equal(t, "base", field.Value)
+				assert.Empty(t, cfg.WithTenant(0).Tenants())
+			}
+		})
+	}
+}
+
+func TestConstraint_Validate(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
 		{
-			scenario: "form and zero period",
-			config:   "name: field\nrange:\n  period: 0\n  from: \"2006-01-02T15:04:05+07:00\"",
+			scenario: "sum",
+			config:   "type: sum\nfields: [a, b]",
 			hasError: false,
 		},
 		{
-			scenario: "form and positive period",
-			config:   "name: field\nrange:\n  period: 1\n  from: \"2006-01-02T15:04:05+07:00\"",
+			scenario: "sum with a single field",
+			config:   "type: sum\nfields: [a]",
 			hasError: true,
 		},
 		{
-			scenario: "form and negative period",
-			config:   "name: field\nrange:\n  period: -1\n  from: \"2006-01-02T15:04:05+07:00\"",
+			scenario: "sum with total and total_field",
+			config:   "type: sum\nfields: [a, b]\ntotal: 10\ntotal_field: c",
 			hasError: true,
 		},
 		{
-			scenario: "to only",
-			config:   "name: field\nrange:\n  to: \"2006-01-02T15:04:05-07:00\"",
-			hasError: false,
-		},
-		{
-			scenario: "to and zero period",
-			config:   "name: field\nrange:\n  period: 0\n  to: \"2006-01-02T15:04:05-07:00\"",
+			scenario: "aggregate",
+			config:   "type: aggregate\nfields: [a.min, a.avg, a.max]",
 			hasError: false,
 		},
 		{
-			scenario: "to and positive period",
-			config:   "name: field\nrange:\n  period: 1\n  to: \"2006-01-02T15:04:05-07:00\"",
+			scenario: "aggregate with unknown aggregation",
+			config:   "type: aggregate\nfields: [a.min, a.p99]",
 			hasError: true,
 		},
 		{
-			scenario: "to and negative period",
-			config:   "name: field\nrange:\n  period: -1\n  to: \"2006-01-02T15:04:05-07:00\"",
-			hasError: true,
+			scenario: "histogram",
+			config:   "type: histogram\nbuckets: [1, 5, 10]\nfields: [a, b, c, d]",
+			hasError: false,
 		},
 		{
-			scenario: "from and to only",
-			config:   "name: field\nrange:\n  from: \"2006-01-02T15:04:05-07:00\"\n  to: \"2006-01-02T15:04:05+07:00\"",
-			hasError: false,
+			scenario: "histogram without buckets",
+			config:   "type: histogram\nfields: [a, b]",
+			hasError: true,
 		},
 		{
-			scenario: "from and to and zero period",
-			config:   "name: field\nrange:\n  period: 0\n  from: \"2006-01-02T15:04:05-07:00\"\n\n  to: \"2006-01-02T15:04:05+07:00\"",
-			hasError: false,
+			scenario: "histogram with unordered buckets",
+			config:   "type: histogram\nbuckets: [5, 1]\nfields: [a, b]",
+			hasError: true,
 		},
 		{
-			scenario: "from and to and positive period",
-			config:   "name: field\nrange:\n  period: 1\n  from: \"2006-01-02T15:04:05-07:00\"\n  to: \"2006-01-02T15:04:05+07:00\"",
+			scenario: "histogram with missing bucket fields",
+			config:   "type: histogram\nbuckets: [1, 5, 10]\nfields: [a, b]",
 			hasError: true,
 		},
 		{
-			scenario: "from and to and negative period",
-			config:   "name: field\nrange:\n  period: -1\n  from: \"2006-01-02T15:04:05-07:00\"\n  to: \"2006-01-02T15:04:05+07:00\"",
+			scenario: "unknown type",
+			config:   "type: product\nfields: [a, b]",
 			hasError: true,
 		},
 	}
+
 	for _, testCase := range testCases {
 		t.Run(testCase.scenario, func(t *testing.T) {
 			cfg, err := yaml.NewConfig([]byte(testCase.config))
@@ -142,86 +2572,115 @@ func TestIsValidForDateField(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			var config ConfigField
-			err = cfg.Unpack(&config)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			err = config.ValidForDateField()
+			var constraint Constraint
+			err = cfg.Unpack(&constraint)
 			if testCase.hasError && err == nil {
-
+				t.Fatal("expected error but got nil")
 			}
 
 			if !testCase.hasError && err != nil {
-
+				t.Fatalf("expected no error but got one: %v", err)
 			}
 		})
 	}
 }
 
-func TestIsValidCounter(t *testing.T) {
+func TestCorrelation_Validate(t *testing.T) {
 	testCases := []struct {
 		scenario string
 		config   string
 		hasError bool
 	}{
 		{
-			scenario: "no range, no counter",
-			config:   "name: field",
+			scenario: "correlation",
+			config:   "name: host\nfields: [host.name, host.ip]\ncardinality: 10",
 			hasError: false,
 		},
 		{
-			scenario: "range with both min and max, no counter",
-			config:   "name: field\nrange:\n  min: 1\n  max: 10",
-			hasError: false,
+			scenario: "correlation with a single field",
+			config:   "fields: [host.name]\ncardinality: 10",
+			hasError: true,
 		},
 		{
-			scenario: "range with min and no max, no counter",
-			config:   "name: field\nrange:\n  min: 1",
-			hasError: false,
+			scenario: "correlation without cardinality",
+			config:   "fields: [host.name, host.ip]",
+			hasError: true,
 		},
 		{
-			scenario: "range with max and no min, no counter",
-			config:   "name: field\nrange:\n  max: 10",
+			scenario: "correlation with repeated field",
+			config:   "fields: [host.name, host.name]\ncardinality: 10",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := yaml.NewConfig([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var correlation Correlation
+			err = cfg.Unpack(&correlation)
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+		})
+	}
+}
+
+func TestSession_Validate(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+	}{
+		{
+			scenario: "session",
+			config:   "name: user\nfields: [session.id, user.name]\nlength:\n  min: 2\n  max: 20",
 			hasError: false,
 		},
 		{
-			scenario: "with counter, no range",
-			config:   "name: field\ncounter: true",
+			scenario: "session with a mean length",
+			config:   "fields: [session.id]\nlength:\n  max: 100\n  mean: 10",
 			hasError: false,
 		},
 		{
-			scenario: "range with both min and max, with counter",
-			config:   "name: field\nrange:\n  min: 1\n  max: 10\ncounter: true",
+			scenario: "session without fields",
+			config:   "length:\n  max: 10",
 			hasError: true,
 		},
 		{
-			scenario: "range with min and no max, with counter",
-			config:   "name: field\nrange:\n  min: 1\ncounter: true",
+			scenario: "session without length",
+			config:   "fields: [session.id]",
 			hasError: true,
 		},
 		{
-			scenario: "range with max and no min, with counter",
-			config:   "name: field\nrange:\n  max: 10\ncounter: true",
+			scenario: "session with max less than min",
+			config:   "fields: [session.id]\nlength:\n  min: 10\n  max: 5",
 			hasError: true,
 		},
 		{
-			scenario: "range with both min and max, with counter:false",
-			config:   "name: field\nrange:\n  min: 1\n  max: 10\ncounter: false",
-			hasError: false,
+			scenario: "session with negative min",
+			config:   "fields: [session.id]\nlength:\n  min: -1\n  max: 5",
+			hasError: true,
 		},
 		{
-			scenario: "range with min and no max, with counter:false",
-			config:   "name: field\nrange:\n  min: 1\ncounter: false",
-			hasError: false,
+			scenario: "session with mean out of range",
+			config:   "fields: [session.id]\nlength:\n  min: 2\n  max: 5\n  mean: 10",
+			hasError: true,
 		},
 		{
-			scenario: "range with max and no min, with counter:false",
-			config:   "name: field\nrange:\n  max: 10\ncounter: false",
-			hasError: false,
+			scenario: "session with repeated field",
+			config:   "fields: [session.id, session.id]\nlength:\n  max: 10",
+			hasError: true,
 		},
 	}
+
 	for _, testCase := range testCases {
 		t.Run(testCase.scenario, func(t *testing.T) {
 			cfg, err := yaml.NewConfig([]byte(testCase.config))
@@ -229,19 +2688,14 @@ func TestIsValidCounter(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			var config ConfigField
-			err = cfg.Unpack(&config)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			err = config.ValidCounter()
+			var session Session
+			err = cfg.Unpack(&session)
 			if testCase.hasError && err == nil {
-
+				t.Fatal("expected error but got nil")
 			}
 
 			if !testCase.hasError && err != nil {
-
+				t.Fatalf("expected no error but got one: %v", err)
 			}
 		})
 	}
@@ -639,3 +3093,134 @@ func TestPeriod(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadConfigFromYaml_Seed(t *testing.T) {
+	cfg, err := LoadConfigFromYaml([]byte("seed: 42\nfields:\n  - name: a\n    value: b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed, ok := cfg.Seed()
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), seed)
+
+	seed, ok = cfg.WithTimelineSteps(0).Seed()
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), seed)
+
+	cfg, err = LoadConfigFromYaml([]byte("fields:\n  - name: a\n    value: b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok = cfg.Seed()
+	assert.False(t, ok)
+}
+
+func TestLoadConfigFromYaml_MaxDuration(t *testing.T) {
+	cfg, err := LoadConfigFromYaml([]byte("max_duration: 10m\nfields:\n  - name: a\n    value: b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 10*time.Minute, cfg.MaxDuration())
+	assert.Equal(t, 10*time.Minute, cfg.WithTimelineSteps(0).MaxDuration())
+
+	_, err = LoadConfigFromYaml([]byte("max_duration: -10m\nfields:\n  - name: a\n    value: b"))
+	assert.ErrorIs(t, err, maxDurationInvalidConfig)
+}
+
+func TestLoadConfigFromYaml_Migrations(t *testing.T) {
+	cfg, err := LoadConfigFromYaml([]byte("version: 1\nfields:\n  - name: a\n    value: 1\n  - name: b\n    allow_nan_inf: false\ntimeline:\n  - at_event: 1\n    fields:\n      - name: a\n        value: 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// version 1 kept the NaN and infinite values, and drew the values from the global rand
+	assert.Len(t, cfg.Warnings(), 3)
+	a, _ := cfg.GetField("a")
+	assert.True(t, a.AllowNaNInf)
+	b, _ := cfg.GetField("b")
+	assert.False(t, b.AllowNaNInf)
+	a, _ = cfg.WithTimelineSteps(1).GetField("a")
+	assert.True(t, a.AllowNaNInf)
+
+	cfg, err = LoadConfigFromYaml([]byte("version: 2\nfields:\n  - name: a\n    value: 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, cfg.Warnings(), 2)
+	a, _ = cfg.GetField("a")
+	assert.False(t, a.AllowNaNInf)
+}
+
+func TestLoadConfigFromYaml_Version(t *testing.T) {
+	// version 2 renames the `range` of the fields to `bounds`, only to test the migration
+	defer func(current []migration) { migrations = current }(migrations)
+	migrations = []migration{
+		func(cfg map[string]any) ([]string, error) {
+			fields, _ := cfg["fields"].([]any)
+			for _, f := range fields {
+				field := f.(map[string]any)
+				if r, ok := field["range"]; ok {
+					field["bounds"] = r
+					delete(field, "range")
+				}
+			}
+
+			return []string{"`range` is renamed to `bounds`"}, nil
+		},
+	}
+
+	testCases := []struct {
+		scenario string
+		config   string
+		hasError bool
+		warnings int
+	}{
+		{
+			scenario: "current version",
+			config:   "version: 2\nfields:\n  - name: a\n    value: 1",
+			hasError: false,
+			warnings: 0,
+		},
+		{
+			scenario: "older version",
+			config:   "version: 1\nfields:\n  - name: a\n    range:\n      min: 1",
+			hasError: false,
+			warnings: 2,
+		},
+		{
+			scenario: "no version",
+			config:   "fields:\n  - name: a\n    value: 1",
+			hasError: false,
+			warnings: 3,
+		},
+		{
+			scenario: "future version",
+			config:   "version: 3\nfields:\n  - name: a\n    value: 1",
+			hasError: true,
+		},
+		{
+			scenario: "invalid version",
+			config:   "version: 0\nfields:\n  - name: a\n    value: 1",
+			hasError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := LoadConfigFromYaml([]byte(testCase.config))
+			if testCase.hasError && err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			if !testCase.hasError && err != nil {
+				t.Fatalf("expected no error but got one: %v", err)
+			}
+
+			assert.Len(t, cfg.Warnings(), testCase.warnings)
+		})
+	}
+}
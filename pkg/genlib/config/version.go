@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/elastic/go-ucfg"
+)
+
+// migration upgrades a config to the next version, returning the deprecation warnings for the settings it changed
+type migration func(cfg map[string]any) ([]string, error)
+
+// migrations[i] upgrades a config from version i+1 to version i+2: append a migration, and document it,
+// for any change of the config format that would alter the corpora generated with existing configs.
+var migrations = []migration{
+	migrateAllowNaNInf,
+	migrateGeneratorRand,
+}
+
+// migrateAllowNaNInf upgrades a config from version 1, where the NaN and infinite float values were written as they
+// are, to version 2, where they are replaced by default: `allow_nan_inf` is set on the fields not setting it.
+func migrateAllowNaNInf(cfg map[string]any) ([]string, error) {
+	entries := configEntries(cfg["fields"])
+	for _, key := range []string{"timeline", "tenants"} {
+		items, _ := cfg[key].([]any)
+		for _, item := range items {
+			if item, ok := item.(map[string]any); ok {
+				entries = append(entries, configEntries(item["fields"])...)
+			}
+		}
+	}
+
+	migrated := false
+	for _, entry := range entries {
+		if _, ok := entry["allow_nan_inf"]; !ok {
+			entry["allow_nan_inf"] = true
+			migrated = true
+		}
+	}
+
+	if !migrated {
+		return nil, nil
+	}
+
+	return []string{"NaN and infinite float values are replaced by default: `allow_nan_inf: true` is set on the config entries to keep them, set it only on the float fields expecting them"}, nil
+}
+
+// migrateGeneratorRand upgrades a config from version 2 to version 3, where all the values are drawn from the rand
+// of the generator: the corpora generated with the same seed differ, and there is no setting to restore them.
+func migrateGeneratorRand(map[string]any) ([]string, error) {
+	return []string{"all the values are drawn from the rand of the generator: the corpora generated with the same seed differ from the ones of the older versions"}, nil
+}
+
+// configEntries returns the config entries of a `fields` list
+func configEntries(fields any) []map[string]any {
+	items, _ := fields.([]any)
+	entries := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		if entry, ok := item.(map[string]any); ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// CurrentVersion returns the version of the config format: configs with an older `version` are migrated when loaded
+func CurrentVersion() int {
+	return len(migrations) + 1
+}
+
+// migrate upgrades cfg to the current version, returning the deprecation warnings
+func migrate(cfg *ucfg.Config) (*ucfg.Config, []string, error) {
+	var versioned struct {
+		Version *int `config:"version"`
+	}
+
+	if err := cfg.Unpack(&versioned); err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	version := 1
+	if versioned.Version == nil {
+		warnings = append(warnings, fmt.Sprintf("config has no `version`, assuming version 1: add `version: %d` to the config after checking the changes of the config format since version 1", CurrentVersion()))
+	} else {
+		version = *versioned.Version
+	}
+
+	if version < 1 || version > CurrentVersion() {
+		return nil, nil, fmt.Errorf("config version %d is not supported, must be between 1 and %d", version, CurrentVersion())
+	}
+
+	if version == CurrentVersion() {
+		return cfg, warnings, nil
+	}
+
+	var m map[string]any
+	if err := cfg.Unpack(&m); err != nil {
+		return nil, nil, err
+	}
+
+	for i, migrate := range migrations[version-1:] {
+		migrationWarnings, err := migrate(m)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot migrate config from version %d: %w", version+i, err)
+		}
+
+		for _, warning := range migrationWarnings {
+			warnings = append(warnings, fmt.Sprintf("version %d: %s", version+i, warning))
+		}
+	}
+
+	warnings = append(warnings, fmt.Sprintf("config migrated from version %d to version %d: update the config to remove these warnings", version, CurrentVersion()))
+	m["version"] = CurrentVersion()
+
+	migrated, err := ucfg.NewFrom(m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return migrated, warnings, nil
+}
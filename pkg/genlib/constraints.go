@@ -0,0 +1,449 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// constraintSample holds the values generated for the fields of a constraint in the event they were generated in
+type constraintSample struct {
+	counter uint64
+	values  []float64
+}
+
+// eventValue holds the value generated for a field in the event it was generated in
+type eventValue struct {
+	counter uint64
+	value   any
+}
+
+// sampleF generates the values for all the fields of a constraint at once
+type sampleF func(state *genState) ([]float64, error)
+
+func isIntegerFieldType(fieldType string) bool {
+	switch fieldType {
+	case FieldTypeByte, FieldTypeShort, FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong:
+		return true
+	default:
+		return false
+	}
+}
+
+// bindConstraints replaces the emit functions of the fields involved in a constraint,
+// so that their values are generated together once per event.
+func bindConstraints(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	fieldsByName := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		fieldsByName[field.Name] = field
+	}
+
+	for i, constraint := range cfg.Constraints() {
+		if err := constraint.Validate(); err != nil {
+			return fmt.Errorf("constraint #%d: %w", i, err)
+		}
+
+		roundFs := make([]func(float64) float64, 0, len(constraint.Fields))
+		decimals := make([]int, 0, len(constraint.Fields))
+		for _, fieldName := range constraint.Fields {
+			field, ok := fieldsByName[fieldName]
+			if _, bound := fieldMap[fieldName]; !ok || !bound {
+				return fmt.Errorf("constraint #%d: field %s not present in fields definition", i, fieldName)
+			}
+
+			if isIntegerFieldType(field.Type) {
+				roundFs = append(roundFs, math.Round)
+				decimals = append(decimals, 0)
+				continue
+			}
+
+			fieldCfg, _ := cfg.GetField(fieldName)
+			roundF, d := makeRoundFloatFunc(fieldCfg, field)
+			roundFs = append(roundFs, roundF)
+			decimals = append(decimals, d)
+		}
+
+		var sample sampleF
+		switch constraint.Type {
+		case config.ConstraintTypeSum:
+			totalF, err := makeConstraintTotalFunc(constraint, fieldMap, withReturn)
+			if err != nil {
+				return fmt.Errorf("constraint #%d: %w", i, err)
+			}
+
+			sample = makeSumSampleFunc(totalF, roundFs, decimals)
+		case config.ConstraintTypeAggregate:
+			var err error
+			if sample, err = makeAggregateSampleFunc(constraint, roundFs, decimals); err != nil {
+				return fmt.Errorf("constraint #%d: %w", i, err)
+			}
+		case config.ConstraintTypeHistogram:
+			var err error
+			if sample, err = makeHistogramSampleFunc(constraint); err != nil {
+				return fmt.Errorf("constraint #%d: %w", i, err)
+			}
+		}
+
+		cachedSample := makeCachedSampleFunc(i, sample)
+		for j, fieldName := range constraint.Fields {
+			isInteger := isIntegerFieldType(fieldsByName[fieldName].Type)
+			if withReturn {
+				fieldMap[fieldName] = makeConstraintEmitFWithReturn(cachedSample, j, isInteger)
+			} else {
+				fieldMap[fieldName] = makeConstraintEmitF(cachedSample, j, isInteger, decimals[j])
+			}
+		}
+	}
+
+	return nil
+}
+
+// makeCachedSampleFunc generates the constraint values once per event, no matter how many fields are emitted
+func makeCachedSampleFunc(constraintIdx int, sample sampleF) sampleF {
+	return func(state *genState) ([]float64, error) {
+		cached, ok := state.prevCacheConstraint[constraintIdx]
+		if ok && cached.counter == state.counter {
+			return cached.values, nil
+		}
+
+		values, err := sample(state)
+		if err != nil {
+			return nil, err
+		}
+
+		state.prevCacheConstraint[constraintIdx] = constraintSample{counter: state.counter, values: values}
+		return values, nil
+	}
+}
+
+func makeConstraintEmitF(sample sampleF, idx int, isInteger bool, decimals int) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		values, err := sample(state)
+		if err != nil {
+			return err
+		}
+
+		if isInteger {
+			v := make([]byte, 0, 32)
+			v = strconv.AppendInt(v, int64(values[idx]), 10)
+			buf.Write(v)
+			return nil
+		}
+
+		return writeFloat(buf, values[idx], decimals)
+	}
+}
+
+func makeConstraintEmitFWithReturn(sample sampleF, idx int, isInteger bool) emitF {
+	return func(state *genState) any {
+		values, err := sample(state)
+		if err != nil {
+			panic(err)
+		}
+
+		if isInteger {
+			return int64(values[idx])
+		}
+
+		return values[idx]
+	}
+}
+
+// makeConstraintTotalFunc returns the function providing the total the fields of a `sum` constraint add up to,
+// either static or the value of another field in the same event.
+func makeConstraintTotalFunc(constraint config.Constraint, fieldMap map[string]any, withReturn bool) (func(state *genState) (float64, error), error) {
+	if len(constraint.TotalField) == 0 {
+		total := constraint.TotalAsFloat64()
+		return func(state *genState) (float64, error) {
+			return total, nil
+		}, nil
+	}
+
+	if _, ok := fieldMap[constraint.TotalField]; !ok {
+		return nil, fmt.Errorf("total field %s not present in fields definition", constraint.TotalField)
+	}
+
+	return bindEventValue(constraint.TotalField, fieldMap, withReturn)
+}
+
+// bindEventValue wraps the emit function of the field so that its value is generated only once per event,
+// and returns a function to access such value, as a number, from other fields.
+func bindEventValue(fieldName string, fieldMap map[string]any, withReturn bool) (func(state *genState) (float64, error), error) {
+	valueF, err := bindEventRawValue(fieldName, fieldMap, withReturn)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(state *genState) (float64, error) {
+		value, err := valueF(state)
+		if err != nil {
+			return 0, err
+		}
+
+		return toFloat64(value)
+	}, nil
+}
+
+// bindEventRawValue is like bindEventValue, but the value is returned as generated, or as a string
+// without the surrounding quotes when the field is bound for the custom template engine.
+func bindEventRawValue(fieldName string, fieldMap map[string]any, withReturn bool) (func(state *genState) (any, error), error) {
+	if withReturn {
+		boundFWithReturn, ok := fieldMap[fieldName].(emitF)
+		if !ok {
+			return nil, errors.New("cannot bind event value")
+		}
+
+		var emitF emitF
+		emitF = func(state *genState) any {
+			cached, ok := state.prevCacheEventValue[fieldName]
+			if !ok || cached.counter != state.counter {
+				cached = eventValue{counter: state.counter, value: boundFWithReturn(state)}
+				state.prevCacheEventValue[fieldName] = cached
+			}
+
+			return cached.value
+		}
+
+		fieldMap[fieldName] = emitF
+
+		return func(state *genState) (any, error) {
+			return emitF(state), nil
+		}, nil
+	}
+
+	boundF, ok := fieldMap[fieldName].(emitFNotReturn)
+	if !ok {
+		return nil, errors.New("cannot bind event value")
+	}
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		cached, ok := state.prevCacheEventValue[fieldName]
+		if !ok || cached.counter != state.counter {
+			var tmp bytes.Buffer
+			if err := boundF(state, &tmp); err != nil {
+				return err
+			}
+
+			cached = eventValue{counter: state.counter, value: tmp.Bytes()}
+			state.prevCacheEventValue[fieldName] = cached
+		}
+
+		buf.Write(cached.value.([]byte))
+		return nil
+	}
+
+	fieldMap[fieldName] = emitFNotReturn
+
+	return func(state *genState) (any, error) {
+		var tmp bytes.Buffer
+		if err := emitFNotReturn(state, &tmp); err != nil {
+			return nil, err
+		}
+
+		return strings.Trim(tmp.String(), `"`), nil
+	}, nil
+}
+
+func toFloat64(v any) (float64, error) {
+	switch value := v.(type) {
+	case float64:
+		return value, nil
+	case float32:
+		return float64(value), nil
+	case int64:
+		return float64(value), nil
+	case int:
+		return float64(value), nil
+	case uint64:
+		return float64(value), nil
+	case string:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return 0, fmt.Errorf("cannot convert %v to a number", v)
+	}
+}
+
+// makeSumSampleFunc splits the total among the fields as in a Dirichlet distribution with all concentrations at 1.
+// Rounding is applied to the cumulative sums, so that the values are never negative and always add up to the total.
+func makeSumSampleFunc(totalF func(state *genState) (float64, error), roundFs []func(float64) float64, decimals []int) sampleF {
+	return func(state *genState) ([]float64, error) {
+		total, err := totalF(state)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]float64, len(roundFs))
+		var sum float64
+		for i := range values {
+			values[i] = state.rand.ExpFloat64()
+			sum += values[i]
+		}
+
+		var cumulative, previous float64
+		for i := range values {
+			cumulative += values[i]
+			rounded := roundFs[i](total * cumulative / sum)
+			if i == len(values)-1 {
+				rounded = roundFs[i](total)
+			}
+
+			values[i] = roundToDecimals(rounded-previous, decimals[i])
+			previous = rounded
+		}
+
+		return values, nil
+	}
+}
+
+// constraintRange returns the bounds of the samples of an `aggregate` or `histogram` constraint, 0 and 100 by default
+func constraintRange(constraint config.Constraint) (float64, float64, error) {
+	minValue, _ := constraint.Range.MinAsFloat64()
+	maxValue, err := constraint.Range.MaxAsFloat64()
+	if err != nil {
+		maxValue = 100
+	}
+
+	if minValue > maxValue {
+		return 0, 0, fmt.Errorf("constraint `range` must have `min` not greater than `max`, got %g and %g", minValue, maxValue)
+	}
+
+	return minValue, maxValue, nil
+}
+
+// makeAggregateSampleFunc draws a sample set uniformly in the constraint range and computes the value of each field
+// as an aggregation over it, so that, for example, `min`, `avg` and `max` fields are always consistent. The values
+// are rounded as their fields: the `avg` is the rounded `sum` over the `count`, when there are such fields, and it's
+// kept between the rounded `min` and `max`, so that the rounding of the fields doesn't break their consistency,
+// unless no value with the decimals of the `avg` is between them.
+func makeAggregateSampleFunc(constraint config.Constraint, roundFs []func(float64) float64, decimals []int) (sampleF, error) {
+	minValue, maxValue, err := constraintRange(constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	nSamples := constraint.SamplesOrDefault()
+	aggregations := make([]string, 0, len(constraint.Fields))
+	for _, fieldName := range constraint.Fields {
+		aggregations = append(aggregations, constraint.Aggregation(fieldName))
+	}
+
+	return func(state *genState) ([]float64, error) {
+		min, max, sum := math.Inf(1), math.Inf(-1), float64(0)
+		for i := 0; i < nSamples; i++ {
+			sample := minValue + state.rand.Float64()*(maxValue-minValue)
+			min = math.Min(min, sample)
+			max = math.Max(max, sample)
+			sum += sample
+		}
+
+		count := float64(nSamples)
+		// the bounds of the avg are the rounded min and max, when written
+		lower, upper := math.Inf(-1), math.Inf(1)
+		values := make([]float64, len(aggregations))
+		for i, aggregation := range aggregations {
+			switch aggregation {
+			case config.AggregationMin:
+				values[i] = roundFs[i](min)
+				lower = math.Max(lower, values[i])
+			case config.AggregationMax:
+				values[i] = roundFs[i](max)
+				upper = math.Min(upper, values[i])
+			case config.AggregationSum:
+				values[i] = roundFs[i](sum)
+				sum = values[i]
+			case config.AggregationCount:
+				values[i] = roundFs[i](count)
+				count = values[i]
+			}
+		}
+
+		for i, aggregation := range aggregations {
+			if aggregation != config.AggregationAvg {
+				continue
+			}
+
+			values[i] = clampToDecimals(roundFs[i](sum/count), lower, upper, decimals[i])
+		}
+
+		return values, nil
+	}, nil
+}
+
+// makeHistogramSampleFunc draws a sample set uniformly in the constraint range and counts the samples falling in
+// each bucket, so that the bucket counts are consistent with each other instead of random per bucket.
+// A bucket counts the samples less than or equal to its upper bound and greater than the previous one, or all the
+// samples less than or equal to its upper bound when the histogram is cumulative, so that counts are monotonic.
+func makeHistogramSampleFunc(constraint config.Constraint) (sampleF, error) {
+	minValue, maxValue, err := constraintRange(constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	nSamples := constraint.SamplesOrDefault()
+	buckets := constraint.Buckets
+	nFields := len(constraint.Fields)
+	cumulative := constraint.Cumulative
+
+	return func(state *genState) ([]float64, error) {
+		values := make([]float64, nFields)
+		for i := 0; i < nSamples; i++ {
+			sample := minValue + state.rand.Float64()*(maxValue-minValue)
+			idx := sort.SearchFloat64s(buckets, sample)
+			// samples above the last bucket are counted only if there's a field for them
+			if idx < nFields {
+				values[idx]++
+			}
+		}
+
+		if cumulative {
+			for i := 1; i < nFields; i++ {
+				values[i] += values[i-1]
+			}
+		}
+
+		return values, nil
+	}, nil
+}
+
+// clampToDecimals returns v within lower and upper, moved to the closest value with the decimals within them, or
+// v itself if there is none
+func clampToDecimals(v, lower, upper float64, decimals int) float64 {
+	if decimals >= 0 {
+		// the bounds are already rounded: the margin keeps their floating point error from moving them a step
+		scale := math.Pow10(decimals)
+		lower = math.Ceil(lower*scale-1e-6) / scale
+		upper = math.Floor(upper*scale+1e-6) / scale
+		if lower > upper {
+			return v
+		}
+	}
+
+	return math.Min(math.Max(v, lower), upper)
+}
+
+// roundToDecimals removes the floating point error of an arithmetic operation on already rounded values
+func roundToDecimals(v float64, decimals int) float64 {
+	switch decimals {
+	case unroundedDecimals:
+		return v
+	case shortestDecimals:
+		// the multiples of a scaling factor have no fixed number of decimals: the error is past the 15
+		// significant digits a float64 always represents exactly
+		rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+		return rounded
+	}
+
+	scale := math.Pow10(decimals)
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,129 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// correlationPool holds the entities of a correlation generated so far, and the one selected for the current event
+type correlationPool struct {
+	// entities are the values of the fields of each entity, in the order of the fields of the correlation,
+	// nil for the entities not generated yet
+	entities [][]any
+	selected bool
+	counter  uint64
+	index    int
+}
+
+// bindCorrelations replaces the emit functions of the fields of each correlation, so that in an event their values
+// come from the same entity: an entity is randomly selected once per event, out of `cardinality` ones, and the values
+// of its fields are generated with their own config the first time it's selected.
+func bindCorrelations(cfg Config, fieldMap map[string]any, withReturn bool) error {
+	correlated := make(map[string]int)
+	for i, correlation := range cfg.Correlations() {
+		if err := correlation.Validate(); err != nil {
+			return fmt.Errorf("correlation #%d: %w", i, err)
+		}
+
+		boundFs := make([]any, 0, len(correlation.Fields))
+		for _, fieldName := range correlation.Fields {
+			boundF, ok := fieldMap[fieldName]
+			if !ok {
+				return fmt.Errorf("correlation #%d: field %s not present in fields definition", i, fieldName)
+			}
+
+			if j, ok := correlated[fieldName]; ok {
+				return fmt.Errorf("correlation #%d: field %s already in correlation #%d", i, fieldName, j)
+			}
+
+			correlated[fieldName] = i
+			boundFs = append(boundFs, boundF)
+		}
+
+		entity := makeCorrelationEntityFunc(i, correlation.Cardinality, boundFs, withReturn)
+		for j, fieldName := range correlation.Fields {
+			if withReturn {
+				fieldMap[fieldName] = makeCorrelationEmitFWithReturn(entity, j)
+			} else {
+				fieldMap[fieldName] = makeCorrelationEmitF(entity, j)
+			}
+		}
+	}
+
+	return nil
+}
+
+// makeCorrelationEntityFunc returns the function providing the values of the entity selected for the event,
+// generating them with boundFs when the entity is selected for the first time
+func makeCorrelationEntityFunc(correlationIdx, cardinality int, boundFs []any, withReturn bool) func(state *genState) ([]any, error) {
+	return func(state *genState) ([]any, error) {
+		pool, ok := state.prevCacheCorrelation[correlationIdx]
+		if !ok {
+			pool = &correlationPool{}
+			state.prevCacheCorrelation[correlationIdx] = pool
+		}
+
+		// the entities of a state saved with another cardinality are kept up to the current one
+		if len(pool.entities) != cardinality {
+			entities := make([][]any, cardinality)
+			copy(entities, pool.entities)
+			pool.entities = entities
+		}
+
+		if !pool.selected || pool.counter != state.counter {
+			pool.index = state.rand.Intn(cardinality)
+			pool.counter = state.counter
+			pool.selected = true
+		}
+
+		// an entity of a state saved with other fields in the correlation is generated again
+		if entity := pool.entities[pool.index]; len(entity) == len(boundFs) {
+			return entity, nil
+		}
+
+		entity := make([]any, 0, len(boundFs))
+		for _, boundF := range boundFs {
+			if withReturn {
+				entity = append(entity, boundF.(emitF)(state))
+				continue
+			}
+
+			var tmp bytes.Buffer
+			if err := boundF.(emitFNotReturn)(state, &tmp); err != nil {
+				return nil, err
+			}
+
+			entity = append(entity, tmp.Bytes())
+		}
+
+		pool.entities[pool.index] = entity
+		return entity, nil
+	}
+}
+
+func makeCorrelationEmitF(entity func(state *genState) ([]any, error), idx int) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		values, err := entity(state)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(values[idx].([]byte))
+		return nil
+	}
+}
+
+func makeCorrelationEmitFWithReturn(entity func(state *genState) ([]any, error), idx int) emitF {
+	return func(state *genState) any {
+		values, err := entity(state)
+		if err != nil {
+			panic(err)
+		}
+
+		return values[idx]
+	}
+}
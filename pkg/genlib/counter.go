@@ -0,0 +1,58 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math/rand"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// validCounter checks the settings of a `counter` field
+func validCounter(fieldCfg ConfigField) error {
+	if err := fieldCfg.ValidCounter(); err != nil {
+		return err
+	}
+
+	if err := fieldCfg.ValidCounterRate(); err != nil {
+		return err
+	}
+
+	if err := fieldCfg.ValidateCounterResetStrategy(); err != nil {
+		return err
+	}
+
+	if err := fieldCfg.ValidateCounterResetAfterN(); err != nil {
+		return err
+	}
+
+	return fieldCfg.ValidateCounterResetProbabilistic()
+}
+
+// counterRateIncrement returns the increment of a counter at an event, between the `min` and the `max` of its `counter_rate`
+func counterRateIncrement(r *rand.Rand, counterRate *config.CounterRate) float64 {
+	return counterRate.Min + r.Float64()*(counterRate.Max-counterRate.Min)
+}
+
+// counterResets returns whether a counter resets to zero at the current event, according to its `counter_reset`
+func counterResets(state *genState, counterReset *config.CounterReset) bool {
+	if counterReset == nil {
+		return false
+	}
+
+	switch counterReset.Strategy {
+	case config.CounterResetStrategyRandom:
+		// 50% chance to reset
+		return state.rand.Intn(2) == 0
+	case config.CounterResetStrategyProbabilistic:
+		// Probability% chance to reset
+		return state.rand.Intn(100) < int(*counterReset.Probability)
+	case config.CounterResetStrategyAfterN:
+		// Reset after N
+		return state.counter%*counterReset.ResetAfterN == 0
+	}
+
+	return false
+}
@@ -0,0 +1,165 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// cumulativeAccumulators holds the running totals of a `cumulative_of` field, by entity
+type cumulativeAccumulators struct {
+	totals map[string]float64
+	// the running total of the entity of the last event the field was generated in
+	initialised bool
+	counter     uint64
+	current     float64
+}
+
+func isFloatFieldType(fieldType string) bool {
+	switch fieldType {
+	case FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat:
+		return true
+	default:
+		return false
+	}
+}
+
+// bindCumulativeFields replaces the emit functions of the fields with `cumulative_of`, so that their value is
+// the running total of the delta field, kept per entity when `cumulative_entity` is set.
+func bindCumulativeFields(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	fieldsByName := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		fieldsByName[field.Name] = field
+	}
+
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok || len(fieldCfg.CumulativeOf) == 0 {
+			continue
+		}
+
+		if err := fieldCfg.ValidCumulative(); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		isInteger := isIntegerFieldType(field.Type)
+		if !isInteger && !isFloatFieldType(field.Type) {
+			return fmt.Errorf("field %s: `cumulative_of` requires a numeric field type", field.Name)
+		}
+
+		deltaField, ok := fieldsByName[fieldCfg.CumulativeOf]
+		if _, bound := fieldMap[fieldCfg.CumulativeOf]; !ok || !bound {
+			return fmt.Errorf("field %s: delta field %s not present in fields definition", field.Name, fieldCfg.CumulativeOf)
+		}
+
+		if !isIntegerFieldType(deltaField.Type) && !isFloatFieldType(deltaField.Type) {
+			return fmt.Errorf("field %s: delta field %s must have a numeric field type", field.Name, fieldCfg.CumulativeOf)
+		}
+
+		deltaF, err := bindEventValue(fieldCfg.CumulativeOf, fieldMap, withReturn)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		entityF := func(state *genState) (any, error) {
+			return "", nil
+		}
+
+		if len(fieldCfg.CumulativeEntity) > 0 {
+			if _, ok := fieldMap[fieldCfg.CumulativeEntity]; !ok {
+				return fmt.Errorf("field %s: entity field %s not present in fields definition", field.Name, fieldCfg.CumulativeEntity)
+			}
+
+			entityF, err = bindEventRawValue(fieldCfg.CumulativeEntity, fieldMap, withReturn)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+
+		decimals := 0
+		if !isInteger {
+			_, decimals = makeRoundFloatFunc(fieldCfg, field)
+		}
+
+		accumulateF := makeAccumulateFunc(field.Name, deltaF, entityF, decimals)
+		if withReturn {
+			fieldMap[field.Name] = makeCumulativeEmitFWithReturn(accumulateF, isInteger)
+		} else {
+			fieldMap[field.Name] = makeCumulativeEmitF(accumulateF, isInteger, decimals)
+		}
+	}
+
+	return nil
+}
+
+// makeAccumulateFunc adds the delta of the current event to the accumulator of the entity of the current event,
+// once per event, and returns the running total.
+func makeAccumulateFunc(fieldName string, deltaF func(state *genState) (float64, error), entityF func(state *genState) (any, error), decimals int) func(state *genState) (float64, error) {
+	return func(state *genState) (float64, error) {
+		accumulators, ok := state.prevCacheCumulative[fieldName]
+		if !ok {
+			accumulators = &cumulativeAccumulators{totals: make(map[string]float64)}
+			state.prevCacheCumulative[fieldName] = accumulators
+		}
+
+		if accumulators.initialised && accumulators.counter == state.counter {
+			return accumulators.current, nil
+		}
+
+		entity, err := entityF(state)
+		if err != nil {
+			return 0, err
+		}
+
+		delta, err := deltaF(state)
+		if err != nil {
+			return 0, err
+		}
+
+		key := fmt.Sprint(entity)
+		total := roundToDecimals(accumulators.totals[key]+delta, decimals)
+		accumulators.totals[key] = total
+
+		accumulators.initialised = true
+		accumulators.counter = state.counter
+		accumulators.current = total
+		return total, nil
+	}
+}
+
+func makeCumulativeEmitF(accumulateF func(state *genState) (float64, error), isInteger bool, decimals int) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		total, err := accumulateF(state)
+		if err != nil {
+			return err
+		}
+
+		if isInteger {
+			v := make([]byte, 0, 32)
+			v = strconv.AppendInt(v, int64(total), 10)
+			buf.Write(v)
+			return nil
+		}
+
+		return writeFloat(buf, total, decimals)
+	}
+}
+
+func makeCumulativeEmitFWithReturn(accumulateF func(state *genState) (float64, error), isInteger bool) emitF {
+	return func(state *genState) any {
+		total, err := accumulateF(state)
+		if err != nil {
+			panic(err)
+		}
+
+		if isInteger {
+			return int64(total)
+		}
+
+		return total
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// normalMaxTries is the number of values drawn out of `range` after which a normal value is clamped to it
+const normalMaxTries = 100
+
+// makeNormalFunc returns the function generating values with the normal distribution of the field, when it has
+// `distribution: normal`. The values out of `range` are drawn again, so that the distribution is truncated
+// rather than piled up at the bounds, and the ones still out of it, or out of the bounds of the type, are clamped.
+func makeNormalFunc(r *rand.Rand, fieldCfg ConfigField, typeMin, typeMax float64) (func() float64, bool) {
+	if fieldCfg.Distribution != config.DistributionNormal || fieldCfg.Mean == nil {
+		return nil, false
+	}
+
+	mean, stddev := *fieldCfg.Mean, fieldCfg.Stddev
+
+	minValue, err := fieldCfg.Range.MinAsFloat64()
+	if err != nil || minValue < typeMin {
+		minValue = typeMin
+	}
+
+	maxValue, err := fieldCfg.Range.MaxAsFloat64()
+	if err != nil || maxValue > typeMax {
+		maxValue = typeMax
+	}
+
+	return func() float64 {
+		var v float64
+		for try := 0; try < normalMaxTries; try++ {
+			v = mean + r.NormFloat64()*stddev
+			if v >= minValue && v <= maxValue {
+				return v
+			}
+		}
+
+		return math.Min(math.Max(v, minValue), maxValue)
+	}, true
+}
@@ -0,0 +1,111 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var documentNotJSONObject = errors.New("event is not a JSON object")
+
+// emitDocument renders the event with emit and decodes it as a typed document
+func emitDocument(buf *bytes.Buffer, emit func(buf *bytes.Buffer) error) (map[string]any, error) {
+	offset := buf.Len()
+	if err := emit(buf); err != nil {
+		return nil, err
+	}
+
+	return decodeDocument(buf.Bytes()[offset:])
+}
+
+// decodeDocument decodes a rendered event to a document, with integer numbers as `int64`, other numbers as
+// `float64`, and dotted keys expanded to nested objects.
+func decodeDocument(doc []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+
+	var raw any
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, documentNotJSONObject
+	}
+
+	return typedObject(m), nil
+}
+
+func typedObject(m map[string]any) map[string]any {
+	document := make(map[string]any, len(m))
+	for key, value := range m {
+		setDotted(document, key, typedValue(value))
+	}
+
+	return document
+}
+
+func typedValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		return typedObject(v)
+	case []any:
+		for i := range v {
+			v[i] = typedValue(v[i])
+		}
+
+		return v
+	default:
+		return v
+	}
+}
+
+// setDotted sets the value at the path of the dotted key, creating the intermediate objects.
+// When an intermediate path already holds a value other than an object the dotted key is kept as is.
+func setDotted(document map[string]any, key string, value any) {
+	path := strings.Split(key, ".")
+	current := document
+	for i, segment := range path[:len(path)-1] {
+		next, ok := current[segment]
+		if !ok {
+			child := make(map[string]any)
+			current[segment] = child
+			current = child
+			continue
+		}
+
+		child, ok := next.(map[string]any)
+		if !ok {
+			current[strings.Join(path[i:], ".")] = value
+			return
+		}
+
+		current = child
+	}
+
+	last := path[len(path)-1]
+	if existing, ok := current[last].(map[string]any); ok {
+		if object, ok := value.(map[string]any); ok {
+			for k, v := range object {
+				setDotted(existing, k, v)
+			}
+
+			return
+		}
+	}
+
+	current[last] = value
+}
@@ -0,0 +1,147 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// dynamicKeyNameTries is the number of random nouns drawn for a key name before falling back to a numbered one
+const dynamicKeyNameTries = 10
+
+// isObjectFieldType reports whether the values of the field type are objects with keys generated for them
+func isObjectFieldType(fieldType string) bool {
+	switch fieldType {
+	case FieldTypeObject, FieldTypeNested, FieldTypeFlattened:
+		return true
+	default:
+		return false
+	}
+}
+
+// dynamicKeyNames returns the names of the keys of the field with `dynamic_keys`: they are drawn from a random
+// number generator seeded with the field name, so that the family of keys is the same whatever the seed and
+// across runs, like the metric names of a Prometheus endpoint.
+func dynamicKeyNames(fieldName string, cardinality int) []string {
+	r := rand.New(rand.NewSource(int64(hashOf(fieldName, ""))))
+
+	names := make([]string, 0, cardinality)
+	seen := make(map[string]struct{}, cardinality)
+	for i := 0; i < cardinality; i++ {
+		name := ""
+		for try := 0; try < dynamicKeyNameTries; try++ {
+			noun := randomNoun(r)
+			if _, ok := seen[noun]; !ok {
+				name = noun
+				break
+			}
+		}
+
+		if len(name) == 0 {
+			name = randomNoun(r) + "_" + strconv.Itoa(i)
+		}
+
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// pickDynamicKeys returns the indexes of the keys of the current event, in the order of the key names
+func pickDynamicKeys(fieldCfg ConfigField, state *genState) []int {
+	n := fieldCfg.DynamicKeys.Min
+	if fieldCfg.DynamicKeys.Max > n {
+		n += state.rand.Intn(fieldCfg.DynamicKeys.Max - n + 1)
+	}
+
+	picked := state.rand.Perm(fieldCfg.DynamicKeys.CardinalityOrDefault())[:n]
+	sort.Ints(picked)
+
+	return picked
+}
+
+// bindDynamicKeys binds the object field with `dynamic_keys`, like `prometheus.metrics.*`, so that each event has
+// an object with a number of keys in the `dynamic_keys` range, each with a value generated according to the
+// `object_type` and the rest of the field config. The object is bound at the name of the field without `.*`.
+func bindDynamicKeys(cfg Config, fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	if err := fieldCfg.ValidDynamicKeys(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	objectRootFieldName := replacer.Replace(field.Name)
+	names := dynamicKeyNames(objectRootFieldName, fieldCfg.DynamicKeys.CardinalityOrDefault())
+
+	valueMap := make(map[string]any)
+	if err := bindField(cfg, field, valueMap, false); err != nil {
+		return err
+	}
+
+	valueF := valueMap[field.Name].(emitFNotReturn)
+	wrap := fieldValueWrapByType(field)
+
+	prefixes := make([][]byte, 0, len(names))
+	for _, name := range names {
+		prefixes = append(prefixes, []byte(fmt.Sprintf(`%q:%s`, name, wrap)))
+	}
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		buf.WriteByte('{')
+		for i, key := range pickDynamicKeys(fieldCfg, state) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			buf.Write(prefixes[key])
+			if err := valueF(state, buf); err != nil {
+				return err
+			}
+
+			buf.WriteString(wrap)
+		}
+
+		buf.WriteByte('}')
+		return nil
+	}
+
+	fieldMap[objectRootFieldName] = emitFNotReturn
+	return nil
+}
+
+func bindDynamicKeysWithReturn(cfg Config, fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	if err := fieldCfg.ValidDynamicKeys(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	objectRootFieldName := replacer.Replace(field.Name)
+	names := dynamicKeyNames(objectRootFieldName, fieldCfg.DynamicKeys.CardinalityOrDefault())
+
+	valueMap := make(map[string]any)
+	if err := bindField(cfg, field, valueMap, true); err != nil {
+		return err
+	}
+
+	valueF := valueMap[field.Name].(emitF)
+
+	var emitF emitF
+	emitF = func(state *genState) any {
+		keys := pickDynamicKeys(fieldCfg, state)
+
+		object := make(map[string]any, len(keys))
+		for _, key := range keys {
+			object[names[key]] = valueF(state)
+		}
+
+		return object
+	}
+
+	fieldMap[objectRootFieldName] = emitF
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	EscapeJSON      = "json"
+	EscapeCSV       = "csv"
+	EscapeCEF       = "cef"
+	EscapeCEFHeader = "cef_header"
+	EscapeSyslog    = "syslog"
+	EscapeXML       = "xml"
+)
+
+// escaper writes value to buf escaped for an output format
+type escaper func(value string, buf *bytes.Buffer)
+
+var escapers = map[string]escaper{
+	EscapeJSON:      escapeJSON,
+	EscapeCSV:       escapeCSV,
+	EscapeCEF:       escapeCEF,
+	EscapeCEFHeader: escapeCEFHeader,
+	EscapeSyslog:    escapeSyslog,
+	EscapeXML:       escapeXML,
+}
+
+// EscapeProfiles returns the names of the escaping profiles of the `escape` template function, sorted
+func EscapeProfiles() []string {
+	names := make([]string, 0, len(escapers))
+	for name := range escapers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func getEscaper(profile string) (escaper, error) {
+	e, ok := escapers[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown escaping profile %s, must be one of %s", profile, strings.Join(EscapeProfiles(), ", "))
+	}
+
+	return e, nil
+}
+
+// escapeJSON writes the content of a JSON string, without the surrounding quotes
+func escapeJSON(value string, buf *bytes.Buffer) {
+	var tmp bytes.Buffer
+	enc := json.NewEncoder(&tmp)
+	enc.SetEscapeHTML(false)
+	// encoding a string never fails
+	_ = enc.Encode(value)
+
+	// strip the quotes and the newline added by the encoder
+	buf.Write(tmp.Bytes()[1 : tmp.Len()-2])
+}
+
+// escapeCSV writes a CSV field as in RFC 4180, quoted when it contains a delimiter, a quote or a line break
+func escapeCSV(value string, buf *bytes.Buffer) {
+	if !strings.ContainsAny(value, ",\"\r\n") && !strings.HasPrefix(value, " ") {
+		buf.WriteString(value)
+		return
+	}
+
+	buf.WriteByte('"')
+	buf.WriteString(strings.ReplaceAll(value, `"`, `""`))
+	buf.WriteByte('"')
+}
+
+var cefReplacer = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+
+// escapeCEF writes a CEF extension value: backslashes, equal signs and line breaks are escaped
+func escapeCEF(value string, buf *bytes.Buffer) {
+	_, _ = cefReplacer.WriteString(buf, value)
+}
+
+var cefHeaderReplacer = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ")
+
+// escapeCEFHeader writes a CEF header field: backslashes and pipes are escaped, and line breaks,
+// not allowed in the header, are replaced by spaces
+func escapeCEFHeader(value string, buf *bytes.Buffer) {
+	_, _ = cefHeaderReplacer.WriteString(buf, value)
+}
+
+var syslogReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+
+// escapeSyslog writes a RFC 5424 structured data parameter value: backslashes, quotes and closing brackets
+// are escaped, and line breaks are written as `\n` and `\r`, so that they cannot break the message framing
+func escapeSyslog(value string, buf *bytes.Buffer) {
+	_, _ = syslogReplacer.WriteString(buf, value)
+}
+
+// escapeXML writes XML character data, also valid as an attribute value
+func escapeXML(value string, buf *bytes.Buffer) {
+	// writing to a bytes.Buffer never fails
+	_ = xml.EscapeText(buf, []byte(value))
+}
+
+// makeEscapeFormat escapes the rendered value with an escaping profile
+func makeEscapeFormat(profile string) (placeholderFormat, error) {
+	e, err := getEscaper(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(value []byte, buf *bytes.Buffer) error {
+		e(string(value), buf)
+		return nil
+	}, nil
+}
+
+// makeEscapeTemplateFunc returns the `escape` function of the text template, to be used in a pipeline
+// as in `{{generate "message" | escape "json"}}`
+func makeEscapeTemplateFunc() func(profile string, value any) (string, error) {
+	return func(profile string, value any) (string, error) {
+		e, err := getEscaper(profile)
+		if err != nil {
+			return "", err
+		}
+
+		var buf bytes.Buffer
+		e(fmt.Sprint(value), &buf)
+
+		return buf.String(), nil
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// escapeTestValues are the values injecting the delimiters of the output formats
+var escapeTestValues = []string{
+	"plain",
+	"",
+	`a "quoted" value`,
+	`back\slash`,
+	"comma, separated",
+	"pipe|separated",
+	"key=value",
+	"closing]bracket",
+	"<tag attr='1'>&amp;</tag>",
+	"line\nbreak\r\nand\rcarriage return",
+	" leading space",
+	"tab\tand   separator",
+	"unicode \U0001f600 ‏",
+}
+
+func escapeString(t *testing.T, profile, value string) string {
+	t.Helper()
+
+	e, err := getEscaper(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	e(value, &buf)
+
+	return buf.String()
+}
+
+func TestEscapeJSON(t *testing.T) {
+	for _, value := range escapeTestValues {
+		escaped := escapeString(t, EscapeJSON, value)
+
+		var got string
+		if err := json.Unmarshal([]byte(`"`+escaped+`"`), &got); err != nil {
+			t.Fatalf("invalid JSON string for %q: %s: %v", value, escaped, err)
+		}
+
+		if got != value {
+			t.Errorf("expected %q, got %q", value, got)
+		}
+	}
+}
+
+func TestEscapeCSV(t *testing.T) {
+	for _, value := range escapeTestValues {
+		escaped := escapeString(t, EscapeCSV, value)
+
+		records, err := csv.NewReader(strings.NewReader(escaped + ",last\n")).ReadAll()
+		if err != nil {
+			t.Fatalf("invalid CSV field for %q: %s: %v", value, escaped, err)
+		}
+
+		if len(records) != 1 || len(records[0]) != 2 || records[0][1] != "last" {
+			t.Fatalf("expected one record with two fields for %q, got %q", value, records)
+		}
+
+		// the CSV reader normalizes \r\n to \n in quoted fields
+		if expected := strings.ReplaceAll(value, "\r\n", "\n"); records[0][0] != expected {
+			t.Errorf("expected %q, got %q", expected, records[0][0])
+		}
+	}
+}
+
+func TestEscapeXML(t *testing.T) {
+	for _, value := range escapeTestValues {
+		escaped := escapeString(t, EscapeXML, value)
+
+		var got struct {
+			Attr string `xml:"attr,attr"`
+			Text string `xml:",chardata"`
+		}
+
+		if err := xml.Unmarshal([]byte(`<e attr="`+escaped+`">`+escaped+`</e>`), &got); err != nil {
+			t.Fatalf("invalid XML for %q: %s: %v", value, escaped, err)
+		}
+
+		if got.Text != value || got.Attr != value {
+			t.Errorf("expected %q, got %q and %q", value, got.Text, got.Attr)
+		}
+	}
+}
+
+// unescapeBackslash reverts the backslash escaping of the CEF extension values and of the syslog
+// structured data parameter values, failing on unexpected escape sequences
+func unescapeBackslash(t *testing.T, escaped, specials string) string {
+	t.Helper()
+
+	var sb strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i == len(escaped) {
+			t.Fatalf("trailing backslash in %s", escaped)
+		}
+
+		switch c = escaped[i]; {
+		case c == 'n':
+			sb.WriteByte('\n')
+		case c == 'r':
+			sb.WriteByte('\r')
+		case c == '\\' || strings.IndexByte(specials, c) >= 0:
+			sb.WriteByte(c)
+		default:
+			t.Fatalf("unexpected escape sequence \\%c in %s", c, escaped)
+		}
+	}
+
+	return sb.String()
+}
+
+func TestEscapeCEF(t *testing.T) {
+	for _, value := range escapeTestValues {
+		escaped := escapeString(t, EscapeCEF, value)
+
+		if strings.ContainsAny(escaped, "\r\n") {
+			t.Errorf("expected no line breaks for %q, got %q", value, escaped)
+		}
+
+		// an unescaped equal sign would start a new key in the extension
+		for i := 0; i < len(escaped); i++ {
+			switch escaped[i] {
+			case '\\':
+				i++
+			case '=':
+				t.Fatalf("expected escaped equal signs for %q, got %q", value, escaped)
+			}
+		}
+
+		// line breaks are written as \n, so \r\n becomes \n
+		expected := strings.ReplaceAll(value, "\r\n", "\n")
+		if got := unescapeBackslash(t, escaped, "="); got != expected {
+			t.Errorf("expected %q, got %q", expected, got)
+		}
+	}
+}
+
+func TestEscapeCEFHeader(t *testing.T) {
+	for _, value := range escapeTestValues {
+		escaped := escapeString(t, EscapeCEFHeader, value)
+
+		if strings.ContainsAny(escaped, "\r\n") {
+			t.Errorf("expected no line breaks for %q, got %q", value, escaped)
+		}
+
+		// splitting on the unescaped pipes, as a CEF parser does, must give a single header field
+		fields := 1
+		for i := 0; i < len(escaped); i++ {
+			switch escaped[i] {
+			case '\\':
+				i++
+			case '|':
+				fields++
+			}
+		}
+
+		if fields != 1 {
+			t.Errorf("expected a single header field for %q, got %d in %q", value, fields, escaped)
+		}
+	}
+}
+
+func TestEscapeSyslog(t *testing.T) {
+	for _, value := range escapeTestValues {
+		escaped := escapeString(t, EscapeSyslog, value)
+
+		if strings.ContainsAny(escaped, "\r\n") {
+			t.Errorf("expected no line breaks for %q, got %q", value, escaped)
+		}
+
+		expected := strings.ReplaceAll(value, "\r\n", "\n")
+		if got := unescapeBackslash(t, escaped, `"]`); got != expected {
+			t.Errorf("expected %q, got %q", expected, got)
+		}
+	}
+}
+
+func TestEscapeUnknownProfile(t *testing.T) {
+	if _, err := getEscaper("yaml"); err == nil {
+		t.Errorf("expected an error for an unknown escaping profile")
+	}
+
+	if _, err := parsePlaceholder(`message | escape "yaml"`); err == nil {
+		t.Errorf("expected an error for an unknown escaping profile in a placeholder")
+	}
+}
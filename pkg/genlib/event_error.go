@@ -0,0 +1,69 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// EventError is the failure of the generation of an event, like a template failure or a constraint violation,
+// with the context of the event
+type EventError struct {
+	// Event is the index of the event in the run
+	Event uint64
+	// Field is the name of the field being generated when the failure happened, empty when not related to a field
+	Field string
+	Err   error
+}
+
+func (e *EventError) Error() string {
+	if len(e.Field) == 0 {
+		return fmt.Sprintf("event %d: %v", e.Event, e.Err)
+	}
+
+	return fmt.Sprintf("event %d, field %s: %v", e.Event, e.Field, e.Err)
+}
+
+func (e *EventError) Unwrap() error {
+	return e.Err
+}
+
+// EventErrorHandler is called when the generation of an event fails, instead of Emit returning the error.
+// Returning nil skips the event: Emit discards what was written of it and generates the next event.
+// Returning an error is returned by Emit.
+type EventErrorHandler func(err *EventError) error
+
+// EventErrorsTo returns an EventErrorHandler sending the failures to ch and skipping the failed events.
+// The sending blocks Emit until ch is received from, unless buffered.
+func EventErrorsTo(ch chan<- *EventError) EventErrorHandler {
+	return func(err *EventError) error {
+		ch <- err
+		return nil
+	}
+}
+
+// emitHandlingErrors calls emit until an event is generated, passing the failures to the EventErrorHandler of h,
+// if any; io.EOF and the errors not related to an event are returned as they are
+func emitHandlingErrors(state *genState, h hooks, buf *bytes.Buffer, emit func(buf *bytes.Buffer) error) error {
+	for {
+		offset := buf.Len()
+		err := emit(buf)
+		if err == nil || err == io.EOF || err == generateOnFieldNotInFieldsYaml || h.eventError == nil {
+			return err
+		}
+
+		buf.Truncate(offset)
+
+		eventErr := &EventError{Event: state.counter, Field: state.emitting, Err: err}
+		state.emitting = ""
+		if err := h.eventError(eventErr); err != nil {
+			return err
+		}
+
+		state.counter += 1
+	}
+}
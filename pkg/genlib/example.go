@@ -0,0 +1,189 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math"
+	"math/rand"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// exampleEnumSize is the number of values of a field whose example is a categorical value, the example included
+const exampleEnumSize = 5
+
+// exampleMaxMagnitude caps the magnitude of the values of the numeric fields with an example, within the int64 range
+const exampleMaxMagnitude = 1e18
+
+// exampleEnumMaxLength is the maximum length of an example to be taken as a categorical value, like `GET` or `error`
+const exampleEnumMaxLength = 16
+
+var exampleUUIDRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+var exampleMACRegex = regexp.MustCompile(`^[0-9a-fA-F]{2}([:-])[0-9a-fA-F]{2}(?:[:-][0-9a-fA-F]{2}){4}$`)
+var exampleHexRegex = regexp.MustCompile(`^[0-9a-f]{8,}$|^[0-9A-F]{8,}$`)
+var exampleDigitsRegex = regexp.MustCompile(`^[0-9]+$`)
+var exampleEmailRegex = regexp.MustCompile(`^[^@\s]+@([^@\s]+\.[a-zA-Z]+)$`)
+var exampleWordRegex = regexp.MustCompile(`^[a-zA-Z]+$`)
+
+// exampleValue returns the example of the field without the spaces and the quotes around it
+func exampleValue(field Field) string {
+	example := strings.TrimSpace(field.Example)
+	if unquoted, err := strconv.Unquote(example); err == nil {
+		return unquoted
+	}
+
+	return example
+}
+
+// makeExampleFunc returns the function generating the keywords of a field with the format of its example, when
+// the example has a known one: an IP, a UUID, a MAC address, a hexadecimal or a numeric id, a URL, an email, or a
+// categorical value, like `GET`, the values being then the example and a few other words of the same case. An
+// example without a known format is not taken.
+func makeExampleFunc(field Field) (func(r *rand.Rand) string, bool) {
+	example := exampleValue(field)
+	if len(example) == 0 || strings.ContainsAny(example, "\n[]{}") {
+		return nil, false
+	}
+
+	if ip := net.ParseIP(example); ip != nil {
+		if ip.To4() != nil {
+			return func(r *rand.Rand) string {
+				i0, i1, i2, i3 := randIP(r)
+				return net.IPv4(byte(i0), byte(i1), byte(i2), byte(i3)).String()
+			}, true
+		}
+
+		_, network, _ := net.ParseCIDR(defaultIPv6Network)
+		return func(r *rand.Rand) string {
+			return randIPWithin(r, network).String()
+		}, true
+	}
+
+	if exampleUUIDRegex.MatchString(example) || exampleMACRegex.MatchString(example) || exampleDigitsRegex.MatchString(example) ||
+		exampleHexRegex.MatchString(example) && strings.ContainsAny(example, "0123456789") {
+		return makeExampleShapeFunc(example), true
+	}
+
+	if u, err := url.Parse(example); err == nil && len(u.Scheme) > 0 && len(u.Host) > 0 {
+		depth := len(strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' }))
+		if depth == 0 {
+			depth = 1
+		}
+
+		return func(r *rand.Rand) string {
+			segments := make([]string, depth)
+			for i := range segments {
+				segments[i] = randomNoun(r)
+			}
+
+			return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/" + strings.Join(segments, "/")}).String()
+		}, true
+	}
+
+	if m := exampleEmailRegex.FindStringSubmatch(example); m != nil {
+		domain := m[1]
+		return func(r *rand.Rand) string {
+			return randomNoun(r) + "." + randomNoun(r) + "@" + domain
+		}, true
+	}
+
+	if len(example) <= exampleEnumMaxLength && exampleWordRegex.MatchString(example) {
+		values := exampleEnumValues(field.Name, example)
+		return func(r *rand.Rand) string {
+			return values[r.Intn(len(values))]
+		}, true
+	}
+
+	return nil, false
+}
+
+// makeExampleShapeFunc returns the function replacing each digit of the example with a random digit, or each
+// hexadecimal digit with a random one of the same case when the example is not only digits, so that the ids keep
+// their format
+func makeExampleShapeFunc(example string) func(r *rand.Rand) string {
+	if exampleDigitsRegex.MatchString(example) {
+		return func(r *rand.Rand) string {
+			value := make([]byte, len(example))
+			for i := range value {
+				value[i] = "0123456789"[r.Intn(10)]
+			}
+
+			if len(value) > 1 {
+				// no leading zero, so that the numeric ids keep their length
+				value[0] = "123456789"[r.Intn(9)]
+			}
+
+			return string(value)
+		}
+	}
+
+	hexDigits := "0123456789abcdef"
+	if strings.ContainsAny(example, "ABCDEF") {
+		hexDigits = "0123456789ABCDEF"
+	}
+
+	return func(r *rand.Rand) string {
+		value := []byte(example)
+		for i, c := range value {
+			if c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F' {
+				value[i] = hexDigits[r.Intn(16)]
+			}
+		}
+
+		return string(value)
+	}
+}
+
+// exampleEnumValues returns the values of a field whose example is a categorical value: the example and other
+// nouns with the same case, drawn from a random number generator seeded with the field name, so that they are the
+// same whatever the seed
+func exampleEnumValues(fieldName, example string) []string {
+	caseF := func(s string) string { return s }
+	switch {
+	case strings.ToUpper(example) == example:
+		caseF = strings.ToUpper
+	case strings.ToLower(example) == example:
+		caseF = strings.ToLower
+	case unicode.IsUpper(rune(example[0])):
+		caseF = func(s string) string { return strings.ToUpper(s[:1]) + s[1:] }
+	}
+
+	r := rand.New(rand.NewSource(int64(hashOf(fieldName, example))))
+	values := []string{example}
+	seen := map[string]struct{}{example: {}}
+	for try := 0; len(values) < exampleEnumSize && try < exampleEnumSize*dynamicKeyNameTries; try++ {
+		value := caseF(randomNoun(r))
+		if _, ok := seen[value]; ok {
+			continue
+		}
+
+		seen[value] = struct{}{}
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// exampleMagnitude returns the power of 10 above the absolute value of the numeric example of the field, so that
+// the values have its magnitude, like up to `1000` for `180` or up to `1` for `0.35`; when the example is not a
+// number, the power of 10 of its length
+func exampleMagnitude(field Field) float64 {
+	example := exampleValue(field)
+	value, err := strconv.ParseFloat(example, 64)
+	if err != nil || math.IsInf(value, 0) || math.IsNaN(value) {
+		return math.Min(math.Pow10(len(field.Example)), exampleMaxMagnitude)
+	}
+
+	value = math.Abs(value)
+	if value < 1 && isFloatFieldType(field.Type) {
+		return 1
+	}
+
+	return math.Min(math.Pow10(len(strconv.FormatFloat(math.Floor(value), 'f', -1, 64))), exampleMaxMagnitude)
+}
@@ -0,0 +1,97 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math/rand"
+	"regexp"
+	"testing"
+)
+
+func TestMakeExampleFunc(t *testing.T) {
+	testCases := []struct {
+		example  string
+		expected *regexp.Regexp
+	}{
+		{example: "10.0.0.1", expected: regexp.MustCompile(`^\d+\.\d+\.\d+\.\d+$`)},
+		{example: "2001:db8::1", expected: regexp.MustCompile(`^[23][0-9a-f]{3}:[0-9a-f:]+$`)},
+		{example: "f47ac10b-58cc-4372-a567-0e02b2c3d479", expected: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)},
+		{example: "00-B0-D0-63-C2-26", expected: regexp.MustCompile(`^[0-9A-F]{2}(-[0-9A-F]{2}){5}$`)},
+		{example: "8a4f500d", expected: regexp.MustCompile(`^[0-9a-f]{8}$`)},
+		{example: `"4567"`, expected: regexp.MustCompile(`^[1-9]\d{3}$`)},
+		{example: "https://www.elastic.co/guide/index.html", expected: regexp.MustCompile(`^https://www\.elastic\.co/[a-z]+/[a-z]+$`)},
+		{example: "john.doe@example.com", expected: regexp.MustCompile(`^[a-z]+\.[a-z]+@example\.com$`)},
+		{example: "GET", expected: regexp.MustCompile(`^[A-Z]+$`)},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.example, func(t *testing.T) {
+			exampleF, ok := makeExampleFunc(Field{Name: "field", Type: FieldTypeKeyword, Example: testCase.example})
+			if !ok {
+				t.Fatal("expected the format of the example to be known")
+			}
+
+			r := rand.New(rand.NewSource(1))
+			values := make(map[string]struct{})
+			for i := 0; i < 100; i++ {
+				value := exampleF(r)
+				if !testCase.expected.MatchString(value) {
+					t.Errorf("expected a value matching %s, got %s", testCase.expected, value)
+				}
+
+				values[value] = struct{}{}
+			}
+
+			if len(values) < 5 {
+				t.Errorf("expected at least 5 different values, got %d", len(values))
+			}
+		})
+	}
+}
+
+func TestMakeExampleFunc_enum(t *testing.T) {
+	exampleF, ok := makeExampleFunc(Field{Name: "http.request.method", Type: FieldTypeKeyword, Example: "GET"})
+	if !ok {
+		t.Fatal("expected the example to be a categorical value")
+	}
+
+	r := rand.New(rand.NewSource(1))
+	values := make(map[string]struct{})
+	for i := 0; i < 1000; i++ {
+		values[exampleF(r)] = struct{}{}
+	}
+
+	if _, ok := values["GET"]; !ok || len(values) != exampleEnumSize {
+		t.Errorf("expected %d values with the example, got %v", exampleEnumSize, values)
+	}
+}
+
+func TestMakeExampleFunc_unknown(t *testing.T) {
+	for _, example := range []string{"", "eni-1235b8ca123456789", "Mozilla/5.0", `"my host"`, `["production", "env2"]`} {
+		if _, ok := makeExampleFunc(Field{Name: "field", Type: FieldTypeKeyword, Example: example}); ok {
+			t.Errorf("expected the format of %q not to be known", example)
+		}
+	}
+}
+
+func TestExampleMagnitude(t *testing.T) {
+	testCases := []struct {
+		example   string
+		fieldType string
+		expected  float64
+	}{
+		{example: "180", fieldType: FieldTypeLong, expected: 1000},
+		{example: "-42", fieldType: FieldTypeLong, expected: 100},
+		{example: "0.35", fieldType: FieldTypeDouble, expected: 1},
+		{example: "12.5", fieldType: FieldTypeDouble, expected: 100},
+		{example: "not a number", fieldType: FieldTypeDouble, expected: 1e12},
+	}
+
+	for _, testCase := range testCases {
+		if got := exampleMagnitude(Field{Type: testCase.fieldType, Example: testCase.example}); got != testCase.expected {
+			t.Errorf("expected magnitude %v for %s, got %v", testCase.expected, testCase.example, got)
+		}
+	}
+}
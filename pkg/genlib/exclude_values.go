@@ -0,0 +1,126 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// excludeValuesTries is the number of values generated to find one not in `exclude_values`, before failing
+const excludeValuesTries = 1000
+
+// excludedValues are the values of `exclude_values`, as they are written in the events: the numbers are compared
+// by value, so that `1.5` excludes `1.50`, everything else as text.
+type excludedValues struct {
+	texts   map[string]struct{}
+	numbers map[float64]struct{}
+}
+
+func newExcludedValues(values []any) excludedValues {
+	excluded := excludedValues{texts: make(map[string]struct{}), numbers: make(map[float64]struct{})}
+	for _, value := range values {
+		text := fmt.Sprint(value)
+		excluded.texts[text] = struct{}{}
+		if number, err := strconv.ParseFloat(text, 64); err == nil {
+			excluded.numbers[number] = struct{}{}
+		}
+	}
+
+	return excluded
+}
+
+func (e excludedValues) has(text string) bool {
+	if _, ok := e.texts[text]; ok {
+		return true
+	}
+
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return false
+	}
+
+	_, ok := e.numbers[number]
+	return ok
+}
+
+func validExcludeValues(fieldCfg ConfigField, field Field) error {
+	if err := fieldCfg.ValidExcludeValues(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	switch field.Type {
+	case FieldTypeKeyword, FieldTypeWildcard, FieldTypeConstantKeyword, FieldTypeIP, FieldTypeBool:
+	default:
+		if !isIntegerFieldType(field.Type) && !isFloatFieldType(field.Type) {
+			return fmt.Errorf("field %s: `exclude_values` is not supported for field type %s", field.Name, field.Type)
+		}
+	}
+
+	return nil
+}
+
+// bindExcludeValues wraps the emit function just bound for the field by its type, so that the values in
+// `exclude_values`, like real domain names or `127.0.0.1`, are generated again. It's bound before `cardinality`,
+// so that the excluded values are never cached either.
+func bindExcludeValues(fieldCfg ConfigField, field Field, fieldMap map[string]any, withReturn bool) error {
+	if len(fieldCfg.ExcludeValues) == 0 {
+		return nil
+	}
+
+	if err := validExcludeValues(fieldCfg, field); err != nil {
+		return err
+	}
+
+	// Fields with `object_keys` are bound per key, nothing to wrap
+	if _, ok := fieldMap[field.Name]; !ok {
+		return nil
+	}
+
+	excluded := newExcludedValues(fieldCfg.ExcludeValues)
+
+	if withReturn {
+		boundF := fieldMap[field.Name].(emitF)
+
+		var emitF emitF
+		emitF = func(state *genState) any {
+			for i := 0; i < excludeValuesTries; i++ {
+				value := boundF(state)
+				if !excluded.has(fmt.Sprint(value)) {
+					return value
+				}
+			}
+
+			panic(fmt.Errorf("field %s: cannot generate a value not in `exclude_values`", field.Name))
+		}
+
+		fieldMap[field.Name] = emitF
+		return nil
+	}
+
+	boundF := fieldMap[field.Name].(emitFNotReturn)
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		var tmp bytes.Buffer
+		for i := 0; i < excludeValuesTries; i++ {
+			tmp.Reset()
+			if err := boundF(state, &tmp); err != nil {
+				return err
+			}
+
+			if !excluded.has(tmp.String()) {
+				buf.Write(tmp.Bytes())
+				return nil
+			}
+		}
+
+		return fmt.Errorf("field %s: cannot generate a value not in `exclude_values`", field.Name)
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
@@ -13,16 +13,18 @@ func (f Fields) Less(i, j int) bool { return f[i].Name < f[j].Name }
 func (f Fields) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
 
 type Field struct {
-	Name       string
-	Type       string
-	ObjectType string
-	Example    string
-	Value      string
+	Name          string
+	Type          string
+	ObjectType    string
+	Description   string
+	Example       string
+	Value         string
+	ScalingFactor float64
 }
 
 func (fields Fields) merge(fieldsToMerge ...Field) Fields {
-	merged := false
 	for _, field := range fieldsToMerge {
+		merged := false
 		for _, currentField := range fields {
 			if currentField.Name != field.Name {
 				continue
@@ -4,10 +4,13 @@ import (
 	"archive/zip"
 	"bytes"
 	"context"
+	"crypto/sha512"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/elastic/go-ucfg/yaml"
+	"github.com/spf13/afero"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -15,6 +18,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -27,32 +31,109 @@ const (
 	searchSlug        = "search"
 	kibanaVersionSlug = "kibana.version"
 	manifestSlug      = "manifest.yml"
+	kibanaSlug        = "kibana"
+	// defaultConfigSlug is the folder of the data stream with its default fields generation configuration: it's not
+	// in `_dev`, since the folders in `_dev` are dropped from the packages built for the package registry
+	defaultConfigSlug = "corpus"
+	// DefaultConfigFile is the fields generation configuration file in the default config filesystem
+	DefaultConfigFile = "/config.yml"
 )
 
 type yamlManifest struct {
 	Type string `config:"type"`
 }
 
-func LoadFields(ctx context.Context, baseURL, integration, dataStream, version string) (Fields, string, error) {
+// LoadOption configures how the fields are fetched from the package registry
+type LoadOption func(*loadOptions)
 
-	fieldsContent, dataStreamType, err := getFieldsFilesAndDataStreamType(ctx, baseURL, integration, dataStream, version)
+type loadOptions struct {
+	client   *http.Client
+	cacheDir string
+}
+
+// WithHTTPClient sets the HTTP client used to fetch from the package registry
+func WithHTTPClient(client *http.Client) LoadOption {
+	return func(o *loadOptions) {
+		o.client = client
+	}
+}
+
+// WithCacheDir keeps the packages downloaded from the package registry in dir, so that they are downloaded once:
+// the content of a version of a package never changes once published.
+func WithCacheDir(dir string) LoadOption {
+	return func(o *loadOptions) {
+		o.cacheDir = dir
+	}
+}
+
+func applyLoadOptions(opts []LoadOption) loadOptions {
+	o := loadOptions{
+		client: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func LoadFields(ctx context.Context, baseURL, integration, dataStream, version string, opts ...LoadOption) (Fields, string, error) {
+	fields, dataStreamType, _, err := LoadFieldsWithDefaultConfig(ctx, baseURL, integration, dataStream, version, opts...)
+	return fields, dataStreamType, err
+}
+
+// LoadFieldsWithDefaultConfig loads the fields like LoadFields, and the default fields generation configuration
+// shipped in the `corpus` folder of the data stream, if any: the returned filesystem has the files of
+// the folder, with the configuration in DefaultConfigFile, or it's nil when the package has none.
+func LoadFieldsWithDefaultConfig(ctx context.Context, baseURL, integration, dataStream, version string, opts ...LoadOption) (Fields, string, afero.Fs, error) {
+
+	archive, err := getPackageArchive(ctx, applyLoadOptions(opts), baseURL, integration, version)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	fieldsContent, dataStreamType, configFiles, err := getFieldsFilesAndDataStreamType(archive, integration, dataStream, version)
 	if err != nil {
-		return nil, dataStreamType, err
+		return nil, dataStreamType, nil, err
 	}
 
 	if len(fieldsContent) == 0 {
-		return nil, dataStreamType, ErrNotFound
+		dataStreams, err := dataStreamsOf(archive, integration, version)
+		if err != nil {
+			return nil, dataStreamType, nil, err
+		}
+
+		names := make([]string, 0, len(dataStreams))
+		for _, ds := range dataStreams {
+			names = append(names, ds.Name)
+		}
+
+		return nil, dataStreamType, nil, fmt.Errorf("%w: data stream %s in package %s %s, the data streams of the package are: %s", ErrNotFound, dataStream, integration, version, strings.Join(names, ", "))
 	}
 
 	fieldsFromYaml, err := loadFieldsFromYaml(fieldsContent)
 	if err != nil {
-		return nil, dataStreamType, err
+		return nil, dataStreamType, nil, err
 	}
 
 	fields := collectFields(fieldsFromYaml, "")
 
 	fields, err = normaliseFields(fields)
-	return fields, dataStreamType, err
+	if err != nil {
+		return nil, dataStreamType, nil, err
+	}
+
+	if _, ok := configFiles[DefaultConfigFile]; !ok {
+		return fields, dataStreamType, nil, nil
+	}
+
+	configFs := afero.NewMemMapFs()
+	for name, content := range configFiles {
+		if err := afero.WriteFile(configFs, name, content, 0644); err != nil {
+			return nil, dataStreamType, nil, err
+		}
+	}
+
+	return fields, dataStreamType, configFs, nil
 }
 
 func LoadFieldsWithTemplateFromString(ctx context.Context, fieldsContent string) (Fields, error) {
@@ -126,59 +207,255 @@ func makeDownloadURL(baseURL, donwloadPath string) (*url.URL, error) {
 	return u, nil
 }
 
-func getFieldsFilesAndDataStreamType(ctx context.Context, baseURL, integration, dataStream, version string) ([]byte, string, error) {
-	packageURL, err := makePackageURL(baseURL, integration, version)
+// DataStream is a data stream of a package, as described by its manifest
+type DataStream struct {
+	Name  string
+	Title string
+	Type  string
+}
+
+// ListDataStreams returns the data streams of the version of the package in the package registry, sorted by name
+func ListDataStreams(ctx context.Context, baseURL, integration, version string, opts ...LoadOption) ([]DataStream, error) {
+	archive, err := getPackageArchive(ctx, applyLoadOptions(opts), baseURL, integration, version)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	r, err := getFromURL(ctx, packageURL.String())
+	return dataStreamsOf(archive, integration, version)
+}
+
+// LoadKibanaAssets returns the Kibana saved objects of the version of the package in the package registry, like its
+// dashboards and visualizations, by their path in the `kibana` folder of the package, like
+// `dashboard/nginx-overview.json`
+func LoadKibanaAssets(ctx context.Context, baseURL, integration, version string, opts ...LoadOption) (map[string][]byte, error) {
+	archive, err := getPackageArchive(ctx, applyLoadOptions(opts), baseURL, integration, version)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	var downloadPayload struct {
-		Download string `json:"download"`
-	}
+	prefix := path.Join(fmt.Sprintf("%s-%s", integration, version), kibanaSlug) + "/"
+	assets := make(map[string][]byte)
+	for _, z := range archive.File {
+		name := strings.TrimPrefix(z.Name, prefix)
+		if name == z.Name || z.FileInfo().IsDir() || path.Ext(name) != ".json" {
+			continue
+		}
 
-	body, err := ioutil.ReadAll(r)
-	if err = json.Unmarshal(body, &downloadPayload); err != nil {
-		return nil, "", err
+		content, err := readZipFile(z)
+		if err != nil {
+			return nil, err
+		}
+
+		assets[name] = content
 	}
 
-	downloadURL, err := makeDownloadURL(baseURL, downloadPayload.Download)
-	r, err = getFromURL(ctx, downloadURL.String())
-	defer func(r io.ReadCloser) {
-		if r != nil {
-			_ = r.Close()
+	return assets, nil
+}
+
+// dataStreamsOf returns the data streams with a manifest in the archive of the package, sorted by name
+func dataStreamsOf(archive *zip.Reader, integration, version string) ([]DataStream, error) {
+	prefix := path.Join(fmt.Sprintf("%s-%s", integration, version), dataStreamSlug) + "/"
+
+	var dataStreams []DataStream
+	for _, z := range archive.File {
+		name := strings.TrimPrefix(z.Name, prefix)
+		if name == z.Name || strings.Count(name, "/") != 1 || path.Base(name) != manifestSlug {
+			continue
 		}
-	}(r)
 
+		content, err := readZipFile(z)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg, err := yaml.NewConfig(content)
+		if err != nil {
+			return nil, err
+		}
+
+		var manifest Manifest
+		if err := cfg.Unpack(&manifest); err != nil {
+			return nil, err
+		}
+
+		dataStreams = append(dataStreams, DataStream{Name: path.Dir(name), Title: manifest.Title, Type: manifest.Type})
+	}
+
+	sort.Slice(dataStreams, func(i, j int) bool {
+		return dataStreams[i].Name < dataStreams[j].Name
+	})
+
+	return dataStreams, nil
+}
+
+func readZipFile(z *zip.File) ([]byte, error) {
+	zr, err := z.Open()
 	if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
+
+// getPackageArchive returns the archive of the version of the package, from the cache dir of the options when it
+// has it, or else downloaded from the package registry, and then kept in the cache dir, if any. A cached archive is
+// verified against the checksum published by the registry, when it is reachable, so that one corrupted or
+// re-published is downloaded again.
+func getPackageArchive(ctx context.Context, o loadOptions, baseURL, integration, version string) (*zip.Reader, error) {
+	var cachePath string
+	if len(o.cacheDir) > 0 {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, err
+		}
+
+		// the packages of different registries are kept apart; a port is not valid in a path on Windows
+		cachePath = filepath.Join(o.cacheDir, strings.ReplaceAll(u.Host, ":", "_"), fmt.Sprintf("%s-%s.zip", integration, version))
+		if zipContent, err := os.ReadFile(cachePath); err == nil {
+			// an archive not valid, like one truncated, is downloaded again
+			if archive, err := zip.NewReader(bytes.NewReader(zipContent), int64(len(zipContent))); err == nil && !isStale(ctx, o.client, baseURL, integration, version, zipContent) {
+				return archive, nil
+			}
+		}
 	}
 
-	zipContent, err := ioutil.ReadAll(r)
+	zipContent, err := downloadPackage(ctx, o.client, baseURL, integration, version)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
 	archive, err := zip.NewReader(bytes.NewReader(zipContent), int64(len(zipContent)))
 	if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+
+	if len(cachePath) > 0 {
+		if err := writeCacheFile(cachePath, zipContent); err != nil {
+			return nil, err
+		}
+	}
+
+	return archive, nil
+}
+
+// writeCacheFile writes content to a temporary file renamed to path, so that a concurrent load never reads a
+// partial archive
+func writeCacheFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// isStale tells if the cached content of the archive of the version of the package differs from the one in the
+// package registry, by the SHA-512 checksum published next to the archive. The cached archive is kept when the
+// registry cannot be reached, or has no checksum for it.
+func isStale(ctx context.Context, client *http.Client, baseURL, integration, version string, zipContent []byte) bool {
+	downloadURL, err := getDownloadURL(ctx, client, baseURL, integration, version)
+	if err != nil {
+		return false
 	}
 
+	r, err := getFromURL(ctx, client, downloadURL.String()+".sha512")
+	if err != nil {
+		return false
+	}
+
+	body, err := ioutil.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		return false
+	}
+
+	// the checksum file has the checksum followed by the name of the archive, like the output of sha512sum
+	published := strings.Fields(string(body))
+	if len(published) == 0 {
+		return false
+	}
+
+	checksum := sha512.Sum512(zipContent)
+	return !strings.EqualFold(published[0], hex.EncodeToString(checksum[:]))
+}
+
+// downloadPackage returns the content of the archive of the version of the package in the package registry
+func downloadPackage(ctx context.Context, client *http.Client, baseURL, integration, version string) ([]byte, error) {
+	downloadURL, err := getDownloadURL(ctx, client, baseURL, integration, version)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := getFromURL(ctx, client, downloadURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// getDownloadURL returns the URL of the archive of the version of the package in the package registry
+func getDownloadURL(ctx context.Context, client *http.Client, baseURL, integration, version string) (*url.URL, error) {
+	packageURL, err := makePackageURL(baseURL, integration, version)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := getFromURL(ctx, client, packageURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var downloadPayload struct {
+		Download string `json:"download"`
+	}
+
+	body, err := ioutil.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(body, &downloadPayload); err != nil {
+		return nil, err
+	}
+
+	return makeDownloadURL(baseURL, downloadPayload.Download)
+}
+
+// getFieldsFilesAndDataStreamType returns the content of the fields files of the data stream in the archive of the
+// package, its type, and the files of its default config folder, by their path in the folder.
+func getFieldsFilesAndDataStreamType(archive *zip.Reader, integration, dataStream, version string) ([]byte, string, map[string][]byte, error) {
 	prefixFieldsPath := path.Join(fmt.Sprintf("%s-%s", integration, version), dataStreamSlug, dataStream, fieldsSlug)
 	manifestPath := path.Join(fmt.Sprintf("%s-%s", integration, version), dataStreamSlug, dataStream, manifestSlug)
+	configPath := path.Join(fmt.Sprintf("%s-%s", integration, version), dataStreamSlug, dataStream, defaultConfigSlug) + "/"
 
 	var dataStreamType string
 	var fieldsContent string
+	configFiles := make(map[string][]byte)
 	for _, z := range archive.File {
 		if z.FileInfo().IsDir() {
 			continue
 		}
 
-		if !strings.HasPrefix(z.Name, prefixFieldsPath) && !strings.HasPrefix(z.Name, manifestPath) {
+		if !strings.HasPrefix(z.Name, prefixFieldsPath) && !strings.HasPrefix(z.Name, manifestPath) && !strings.HasPrefix(z.Name, configPath) {
 			continue
 		}
 
@@ -188,7 +465,7 @@ func getFieldsFilesAndDataStreamType(ctx context.Context, baseURL, integration,
 			if zr != nil {
 				_ = zr.Close()
 			}
-			return nil, "", err
+			return nil, "", nil, err
 		}
 
 		fieldsFileContent, err := ioutil.ReadAll(zr)
@@ -196,7 +473,7 @@ func getFieldsFilesAndDataStreamType(ctx context.Context, baseURL, integration,
 			if zr != nil {
 				_ = zr.Close()
 			}
-			return nil, "", err
+			return nil, "", nil, err
 		}
 
 		_ = zr.Close()
@@ -216,21 +493,25 @@ func getFieldsFilesAndDataStreamType(ctx context.Context, baseURL, integration,
 
 			cfg, err := yaml.NewConfig(fieldsFileContent)
 			if err != nil {
-				return nil, "", err
+				return nil, "", nil, err
 			}
 			err = cfg.Unpack(&manifest)
 			if err != nil {
-				return nil, "", err
+				return nil, "", nil, err
 			}
 
 			dataStreamType = manifest.Type
 		}
+
+		if strings.HasPrefix(z.Name, configPath) {
+			configFiles["/"+strings.TrimPrefix(z.Name, configPath)] = fieldsFileContent
+		}
 	}
 
-	return []byte(fieldsContent), dataStreamType, nil
+	return []byte(fieldsContent), dataStreamType, configFiles, nil
 }
 
-func getFromURL(ctx context.Context, srcURL string) (io.ReadCloser, error) {
+func getFromURL(ctx context.Context, client *http.Client, srcURL string) (io.ReadCloser, error) {
 
 	req, err := http.NewRequestWithContext(ctx, "GET", srcURL, nil)
 
@@ -238,12 +519,8 @@ func getFromURL(ctx context.Context, srcURL string) (io.ReadCloser, error) {
 		return nil, err
 	}
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		if resp.Body != nil {
-			_ = resp.Body.Close()
-		}
 		return nil, err
 	}
 
@@ -0,0 +1,187 @@
+package fields
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const mappingSlug = "_mapping"
+
+// mappingProperty is a field of the properties of an Elasticsearch mapping
+type mappingProperty struct {
+	Type          string                     `json:"type"`
+	Properties    map[string]mappingProperty `json:"properties"`
+	Value         any                        `json:"value"`
+	ScalingFactor float64                    `json:"scaling_factor"`
+}
+
+type indexMapping struct {
+	Mappings struct {
+		Properties map[string]mappingProperty `json:"properties"`
+	} `json:"mappings"`
+}
+
+// LoadFieldsFromMapping synthesizes the fields from the mapping of index, an index, a data stream or an index
+// pattern, got with the get mapping API of the Elasticsearch at esURL. The mappings of all the indices, like the
+// backing indices of a data stream, are merged.
+func LoadFieldsFromMapping(ctx context.Context, esURL, index string, opts ...LoadOption) (Fields, error) {
+	if len(index) == 0 {
+		return nil, fmt.Errorf("%w: empty index", ErrNotFound)
+	}
+
+	u, err := url.Parse(esURL)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = path.Join(u.Path, index, mappingSlug)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := applyLoadOptions(opts).client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: index %s", ErrNotFound, index)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("cannot get the mapping of %s: %s: %s", index, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return fieldsFromMapping(body)
+}
+
+// fieldsFromMapping returns the fields of the mappings of the response of the get mapping API
+func fieldsFromMapping(body []byte) (Fields, error) {
+	var mappings map[string]indexMapping
+	if err := json.Unmarshal(body, &mappings); err != nil {
+		return nil, fmt.Errorf("cannot parse the mapping: %w", err)
+	}
+
+	if len(mappings) == 0 {
+		return nil, fmt.Errorf("%w: no index in the mapping", ErrNotFound)
+	}
+
+	// the indices are sorted for the merge of the fields not to depend on the order of the map
+	indices := make([]string, 0, len(mappings))
+	for index := range mappings {
+		indices = append(indices, index)
+	}
+
+	sort.Strings(indices)
+
+	var fields Fields
+	for _, index := range indices {
+		fields = fields.merge(collectMappingFields(mappings[index].Mappings.Properties, "")...)
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: no field in the mapping of %s", ErrNotFound, strings.Join(indices, ", "))
+	}
+
+	return normaliseFields(fields)
+}
+
+// collectMappingFields returns the fields of the properties of a mapping, like collectFields does for the fields
+// of a package. The objects are flattened into their properties, the nested fields are kept with their properties,
+// and the multi-fields and the aliases are dropped, since their values are not in the documents.
+func collectMappingFields(properties map[string]mappingProperty, namePrefix string) Fields {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fields := make(Fields, 0, len(properties))
+	for _, name := range names {
+		property := properties[name]
+		if len(namePrefix) > 0 {
+			name = namePrefix + "." + name
+		}
+
+		switch {
+		case property.Type == "alias":
+			continue
+		case len(property.Properties) > 0:
+			// nested fields are kept, so that the generator can write them as arrays of objects
+			if property.Type == "nested" {
+				fields = fields.merge(Field{Name: name, Type: property.Type})
+			}
+
+			fields = fields.merge(collectMappingFields(property.Properties, name)...)
+		case len(property.Type) == 0, property.Type == "object":
+			// objects without properties, like the disabled ones, have no field to generate
+			continue
+		default:
+			field := Field{
+				Name:          name,
+				Type:          property.Type,
+				ScalingFactor: property.ScalingFactor,
+			}
+
+			// the date_nanos values are generated like the date ones, that they accept
+			if field.Type == "date_nanos" {
+				field.Type = "date"
+			}
+
+			if property.Value != nil {
+				field.Value = fmt.Sprint(property.Value)
+			}
+
+			fields = fields.merge(field)
+		}
+	}
+
+	return fields
+}
+
+// WriteYaml writes the fields to w as a fields definition, like the one passed to `generate-with-template`
+func WriteYaml(w io.Writer, fields Fields) error {
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString("- name: " + yamlString(field.Name) + "\n")
+		b.WriteString("  type: " + yamlString(field.Type) + "\n")
+
+		if len(field.ObjectType) > 0 {
+			b.WriteString("  object_type: " + yamlString(field.ObjectType) + "\n")
+		}
+
+		if len(field.Value) > 0 {
+			b.WriteString("  value: " + yamlString(field.Value) + "\n")
+		}
+
+		if field.ScalingFactor != 0 {
+			b.WriteString("  scaling_factor: " + strconv.FormatFloat(field.ScalingFactor, 'g', -1, 64) + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// yamlString quotes s as a JSON string, that is a valid YAML scalar too
+func yamlString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
@@ -12,13 +12,13 @@ import (
 	"golang.org/x/mod/semver"
 )
 
-func MapVersion(ctx context.Context, baseUrl, integration, kibanaVersion string) (string, error) {
+func MapVersion(ctx context.Context, baseUrl, integration, kibanaVersion string, opts ...LoadOption) (string, error) {
 	searchUrl, err := makeSearchURL(baseUrl, integration, kibanaVersion)
 	if err != nil {
 		return "", err
 	}
 
-	r, err := getFromURL(ctx, searchUrl.String())
+	r, err := getFromURL(ctx, applyLoadOptions(opts).client, searchUrl.String())
 	if err != nil {
 		return "", err
 	}
@@ -7,12 +7,14 @@ import (
 type yamlFields []yamlField
 
 type yamlField struct {
-	Name       string     `config:"name"`
-	Type       string     `config:"type"`
-	ObjectType string     `config:"object_type"`
-	Value      string     `config:"value"`
-	Example    string     `config:"example"`
-	Fields     yamlFields `config:"fields"`
+	Name          string     `config:"name"`
+	Type          string     `config:"type"`
+	ObjectType    string     `config:"object_type"`
+	Description   string     `config:"description"`
+	Value         string     `config:"value"`
+	Example       string     `config:"example"`
+	ScalingFactor float64    `config:"scaling_factor"`
+	Fields        yamlFields `config:"fields"`
 }
 
 func loadFieldsFromYaml(f []byte) (yamlFields, error) {
@@ -38,10 +40,12 @@ func collectFields(fieldsFromYaml yamlFields, namePrefix string) Fields {
 	fields := make(Fields, 0, len(fieldsFromYaml))
 	for _, fieldFromYaml := range fieldsFromYaml {
 		field := Field{
-			Type:       fieldFromYaml.Type,
-			ObjectType: fieldFromYaml.ObjectType,
-			Example:    fieldFromYaml.Example,
-			Value:      fieldFromYaml.Value,
+			Type:          fieldFromYaml.Type,
+			ObjectType:    fieldFromYaml.ObjectType,
+			Description:   fieldFromYaml.Description,
+			Example:       fieldFromYaml.Example,
+			Value:         fieldFromYaml.Value,
+			ScalingFactor: fieldFromYaml.ScalingFactor,
 		}
 
 		if len(namePrefix) == 0 {
@@ -56,6 +60,11 @@ func collectFields(fieldsFromYaml yamlFields, namePrefix string) Fields {
 				fields = fields.merge(field)
 			}
 		} else {
+			// nested groups are kept, so that the generator can write them as arrays of objects
+			if field.Type == "nested" {
+				fields = fields.merge(field)
+			}
+
 			subFields := collectFields(fieldFromYaml.Fields, field.Name)
 			fields = fields.merge(subFields...)
 		}
@@ -8,11 +8,11 @@ import (
 	"bytes"
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/Pallinder/go-randomdata"
-	"github.com/lithammer/shortuuid/v3"
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
 )
 
 const (
@@ -34,7 +34,7 @@ func fieldValueWrapByType(field Field) string {
 		return ""
 	case FieldTypeConstantKeyword:
 		return "\""
-	case FieldTypeKeyword:
+	case FieldTypeKeyword, FieldTypeWildcard:
 		return "\""
 	case FieldTypeBool:
 		return ""
@@ -47,11 +47,24 @@ func fieldValueWrapByType(field Field) string {
 		return fieldValueWrapByType(field)
 	case FieldTypeGeoPoint:
 		return "\""
+	case FieldTypeHistogram:
+		return ""
 	default:
 		return "\""
 	}
 }
 
+// templateFieldWrap returns the string wrapping the value of the field in the template generated from the fields
+func templateFieldWrap(cfg Config, field Field) string {
+	if fieldCfg, ok := cfg.GetField(field.Name); ok {
+		if fieldCfg.Value != nil || (field.Type == FieldTypeGeoPoint && fieldCfg.GeoFormat == config.GeoFormatObject) {
+			return ""
+		}
+	}
+
+	return fieldValueWrapByType(field)
+}
+
 func generateCustomTemplateFromField(cfg Config, fields Fields, r *rand.Rand) ([]byte, []Field) {
 	return generateTemplateFromField(cfg, fields, customTemplateEngine, r)
 }
@@ -65,25 +78,38 @@ func generateTemplateFromField(cfg Config, fields Fields, templateEngine int, r
 		return nil, nil
 	}
 
+	// the groups of fields with `array_length` are written as arrays of objects
+	names := groupNames(fields)
+	fields = eventFields(cfg, fields, names)
+
 	dupes := make(map[string]struct{})
 	objectKeysField := make([]Field, 0, len(fields))
 
 	templatePrefix := "{ "
 	templateBuffer := bytes.NewBufferString(templatePrefix)
 	for i, field := range fields {
-		fieldWrap := fieldValueWrapByType(field)
-		if fieldCfg, ok := cfg.GetField(field.Name); ok {
-			if fieldCfg.Value != nil {
-				fieldWrap = ""
-			}
-		}
+		fieldWrap := templateFieldWrap(cfg, field)
 
 		fieldTrailer := []byte(",")
 		if i == len(fields)-1 {
 			fieldTrailer = []byte(" }")
 		}
 
-		if strings.HasSuffix(field.Name, ".*") || field.Type == FieldTypeObject || field.Type == FieldTypeNested || field.Type == FieldTypeFlattened {
+		if isArrayGroup(cfg, field.Name, names) {
+			if templateEngine == textTemplateEngine {
+				templateBuffer.WriteString(fmt.Sprintf(`"%s": {{generate "%s" | toJson}}%s`, field.Name, field.Name, fieldTrailer))
+			} else if templateEngine == customTemplateEngine {
+				templateBuffer.WriteString(fmt.Sprintf(`"%s": {{.%s}}%s`, field.Name, field.Name, fieldTrailer))
+			}
+		} else if fieldCfg, ok := cfg.GetField(field.Name); ok && fieldCfg.DynamicKeys != nil && isObjectFieldType(field.Type) {
+			// the keys are generated for each event, the object is written as JSON
+			objectRootFieldName := replacer.Replace(field.Name)
+			if templateEngine == textTemplateEngine {
+				templateBuffer.WriteString(fmt.Sprintf(`"%s": {{generate "%s" | toJson}}%s`, objectRootFieldName, objectRootFieldName, fieldTrailer))
+			} else if templateEngine == customTemplateEngine {
+				templateBuffer.WriteString(fmt.Sprintf(`"%s": {{.%s}}%s`, objectRootFieldName, objectRootFieldName, fieldTrailer))
+			}
+		} else if strings.HasSuffix(field.Name, ".*") || field.Type == FieldTypeObject || field.Type == FieldTypeNested || field.Type == FieldTypeFlattened {
 			// This is a special case.  We are randomly generating keys on the fly
 			// Will set the json field name as "field.Name.N"
 			N := 5
@@ -99,17 +125,16 @@ func generateTemplateFromField(cfg Config, fields Fields, templateEngine int, r
 
 				var try int
 				const maxTries = 10
-				rNoun := randomdata.Noun()
+				rNoun := randomNoun(r)
 				_, ok := dupes[rNoun]
 				for ; ok && try < maxTries; try++ {
-					rNoun = randomdata.Noun()
+					rNoun = randomNoun(r)
 					_, ok = dupes[rNoun]
 				}
 
-				// If all else fails, use a shortuuid.
-				// Try to avoid this as it is alloc expensive
+				// If all else fails, use a random id, drawn from r as well.
 				if try >= maxTries {
-					rNoun = shortuuid.New()
+					rNoun = strconv.FormatUint(r.Uint64(), 36)
 				}
 
 				dupes[rNoun] = struct{}{}
@@ -143,7 +168,14 @@ func generateTemplateFromField(cfg Config, fields Fields, templateEngine int, r
 			var fieldTemplate string
 			fieldVariableName := fieldNormalizerRegex.ReplaceAllString(field.Name, "")
 			fieldVariableName += "Var"
-			if field.Type == FieldTypeDate {
+			if fieldCfg, ok := cfg.GetField(field.Name); ok && fieldCfg.ArrayLength != nil {
+				// array fields are written as JSON arrays
+				if templateEngine == textTemplateEngine {
+					fieldTemplate = fmt.Sprintf(`"%s": {{generate "%s" | toJson}}%s`, field.Name, field.Name, fieldTrailer)
+				} else if templateEngine == customTemplateEngine {
+					fieldTemplate = fmt.Sprintf(`"%s": {{.%s}}%s`, field.Name, field.Name, fieldTrailer)
+				}
+			} else if field.Type == FieldTypeDate {
 				if templateEngine == textTemplateEngine {
 					fieldTemplate = fmt.Sprintf(`{{ $%s := generate "%s" }}"%s": %s{{$%s.Format "2006-01-02T15:04:05.999999999Z07:00"}}%s%s`, fieldVariableName, field.Name, field.Name, fieldWrap, fieldVariableName, fieldWrap, fieldTrailer)
 				} else if templateEngine == customTemplateEngine {
@@ -166,7 +198,7 @@ func generateTemplateFromField(cfg Config, fields Fields, templateEngine int, r
 
 // NewGenerator creates a new generator that auto-generates a custom template from fields.
 func NewGenerator(cfg Config, flds Fields, totEvents uint64, opts ...Option) (Generator, error) {
-	options := applyOptions(opts)
+	options := applyOptions(cfg, opts)
 	return options.make(cfg, flds, totEvents, options)
 }
 
@@ -176,8 +208,7 @@ func InitGeneratorTimeNow(timeNow time.Time) {
 	timeNowToBind = timeNow
 }
 
-// InitGeneratorRandSeed sets rand seed
-func InitGeneratorRandSeed(randSeed int64) {
-	// set randomdata seed to --seed flag (custom or 1)
-	randomdata.CustomRand(rand.New(rand.NewSource(randSeed)))
-}
+// InitGeneratorRandSeed has no effect.
+//
+// Deprecated: the generators draw from their own rand, seeded with WithRandSeed or with the `seed` of the config.
+func InitGeneratorRandSeed(randSeed int64) {}
@@ -9,16 +9,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
-	"github.com/Pallinder/go-randomdata"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
 	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/fields"
 )
@@ -40,15 +42,17 @@ func saveTimeState(t *testing.T) {
 }
 
 type (
-	Fields      = fields.Fields
-	Field       = fields.Field
-	Config      = config.Config
-	ConfigField = config.ConfigField
+	Fields       = fields.Fields
+	Field        = fields.Field
+	Config       = config.Config
+	ConfigField  = config.ConfigField
+	TimelineStep = config.TimelineStep
 )
 
 const (
 	FieldTypeBool            = "boolean"
 	FieldTypeKeyword         = "keyword"
+	FieldTypeWildcard        = "wildcard"
 	FieldTypeConstantKeyword = "constant_keyword"
 	FieldTypeDate            = "date"
 	FieldTypeIP              = "ip"
@@ -65,9 +69,17 @@ const (
 	FieldTypeNested          = "nested"
 	FieldTypeFlattened       = "flattened"
 	FieldTypeGeoPoint        = "geo_point"
+	FieldTypeHistogram       = "histogram"
 
 	FieldTypeDurationSpan = 1000 // milliseconds
 	FieldTypeTimeLayout   = "2006-01-02T15:04:05.999999Z07:00"
+
+	// strictCardinalityTries is the number of values generated to find one not generated yet for a field
+	// with `strict_cardinality`, before failing
+	strictCardinalityTries = 1000
+	// cardinalityZipfExponent is the exponent of the zipf distribution of the values with
+	// `cardinality_distribution: zipf`: the i-th value is chosen with probability proportional to 1/(i+1)^1.1
+	cardinalityZipfExponent = 1.1
 )
 
 var (
@@ -103,6 +115,26 @@ type emitF func(state *genState) any
 
 type Generator interface {
 	Emit(buf *bytes.Buffer) error
+	// EmitDocument emits the event like Emit, and returns it decoded as a typed document,
+	// so that it doesn't have to be parsed again. The event must be a JSON object.
+	EmitDocument(buf *bytes.Buffer) (map[string]any, error)
+	// EmitBatch emits up to n events like Emit, one after the other in buf, and returns them as slices of buf, so
+	// that the events can be batched without a call per event. Less than n events are returned at the end of the
+	// generation, and io.EOF when there are none; on any other error the events emitted before it are returned
+	// with the error. The slices are valid until buf is modified.
+	EmitBatch(buf *bytes.Buffer, n int) ([][]byte, error)
+	// Clone returns a generator sharing the compiled fields and template, but with its own state
+	// seeded with randSeed: a Generator is not safe for concurrent use, its clones are.
+	Clone(randSeed int64) Generator
+	// Reload applies a new config to the fields, without resetting the state of the generator.
+	Reload(cfg Config) error
+	// SaveState writes the state of the generated fields to w: the last values of counters, the values of the
+	// fields with cardinality or per_run_constant, and the running totals of cumulative fields.
+	SaveState(w io.Writer) error
+	// LoadState replaces the state of the generated fields with the one written by SaveState, so that the
+	// generation carries on from a previous run instead of restarting counters and re-rolling entities.
+	// It must be called before the first event is emitted.
+	LoadState(r io.Reader) error
 	Close() error
 }
 
@@ -111,23 +143,97 @@ type genState struct {
 	rand *rand.Rand
 	// event counter
 	counter uint64
+	// name of the field being emitted, for the context of the event errors
+	emitting string
 	// total events
 	totEvents uint64
+	// events per output batch; zero means a single batch
+	batchSize uint64
+	// tenants of the config; zero means none
+	tenantCount int
 	// previous value cache; necessary for fuzziness, cardinality, etc.
 	prevCache map[string]any
 	// previous value cache for dup check; necessary for cardinality
 	prevCacheForDup map[string]map[any]struct{}
 	// previous cardinality value cache; necessary for cardinality
 	prevCacheCardinality map[string][]any
+	// per-run constant value cache; necessary for per_run_constant
+	prevCacheRunConstant map[string]any
+	// per-batch constant value cache; necessary for per_batch_constant
+	prevCacheBatchConstant map[string]batchConstant
+	// per-event constraint values cache; necessary for constraints
+	prevCacheConstraint map[int]constraintSample
+	// per-event field value cache; necessary for constraints referencing other fields
+	prevCacheEventValue map[string]eventValue
+	// per-entity accumulators; necessary for cumulative_of
+	prevCacheCumulative map[string]*cumulativeAccumulators
+	// last positions by entity; necessary for geo_trajectory
+	prevCacheGeoTrajectory map[string]*geoTrajectories
+	// per-entity values cache; necessary for hash_of
+	prevCacheHash map[string]map[string]any
+	// last timestamps by entity; necessary for order
+	prevCacheOrder map[string]map[string]time.Time
+	// slots of the last events; necessary for bucket
+	prevCacheBucket map[string]*bucketCursor
+	// last timestamps by entity; necessary for inter_arrival
+	prevCacheInterArrival map[string]map[string]time.Time
+	// samples of the values generated so far; necessary for reuse
+	prevCacheReuse map[string]*reservoir
+	// zipf generators drawing from rand, by field name; necessary for cardinality_distribution
+	prevCacheZipf map[string]*rand.Zipf
+	// entities generated so far, by correlation index; necessary for correlations
+	prevCacheCorrelation map[int]*correlationPool
+	// handshake of the current event; necessary for the `tls` generator
+	prevCacheTLS tlsHandshakeCache
+	// current session, by session index; necessary for sessions
+	prevCacheSession map[int]*sessionState
+	// timelines of the timestamps by field name; necessary for pattern
+	prevCachePattern map[string]*patternTimeline
+	// caches of the tenants not selected for the current event, by index; necessary for tenants
+	tenants []*tenantCaches
+	// tenant of the current event; necessary for tenants
+	tenant eventTenant
+	// values of the array fields iterated by the enclosing `range` blocks; necessary for custom template loops
+	rangeValues [][]byte
 	// internal buffer pool to decrease load on GC
 	pool sync.Pool
 }
 
+// batchConstant holds the value of a `per_batch_constant` field for the batch it was generated in
+type batchConstant struct {
+	batch uint64
+	value any
+}
+
+// batch returns the index of the output batch the current event belongs to
+func (s *genState) batch() uint64 {
+	if s.batchSize == 0 {
+		return 0
+	}
+
+	return s.counter / s.batchSize
+}
+
 func newGenState(randSeed int64) *genState {
 	return &genState{
-		prevCache:            make(map[string]any),
-		prevCacheForDup:      make(map[string]map[any]struct{}),
-		prevCacheCardinality: make(map[string][]any, 0),
+		prevCache:              make(map[string]any),
+		prevCacheForDup:        make(map[string]map[any]struct{}),
+		prevCacheCardinality:   make(map[string][]any, 0),
+		prevCacheRunConstant:   make(map[string]any),
+		prevCacheBatchConstant: make(map[string]batchConstant),
+		prevCacheConstraint:    make(map[int]constraintSample),
+		prevCacheEventValue:    make(map[string]eventValue),
+		prevCacheCumulative:    make(map[string]*cumulativeAccumulators),
+		prevCacheGeoTrajectory: make(map[string]*geoTrajectories),
+		prevCacheHash:          make(map[string]map[string]any),
+		prevCacheOrder:         make(map[string]map[string]time.Time),
+		prevCacheBucket:        make(map[string]*bucketCursor),
+		prevCacheInterArrival:  make(map[string]map[string]time.Time),
+		prevCacheReuse:         make(map[string]*reservoir),
+		prevCacheZipf:          make(map[string]*rand.Zipf),
+		prevCacheCorrelation:   make(map[int]*correlationPool),
+		prevCacheSession:       make(map[int]*sessionState),
+		prevCachePattern:       make(map[string]*patternTimeline),
 		pool: sync.Pool{
 			New: func() any {
 				return new(bytes.Buffer)
@@ -137,6 +243,113 @@ func newGenState(randSeed int64) *genState {
 	}
 }
 
+// clone returns a new state for the same run settings, seeded with randSeed, with the caches
+// initialised for the same fields and the event counter starting from zero.
+func (s *genState) clone(randSeed int64) *genState {
+	state := newGenState(randSeed)
+	state.totEvents = s.totEvents
+	state.batchSize = s.batchSize
+	state.tenantCount = s.tenantCount
+	for fieldName := range s.prevCacheForDup {
+		state.prevCacheForDup[fieldName] = make(map[any]struct{})
+		state.prevCacheCardinality[fieldName] = make([]any, 0)
+	}
+
+	return state
+}
+
+// bindFields binds all the fields, their constraints and the hooks, returning the emit functions by field name
+func bindFields(cfg Config, fields Fields, h hooks, withReturn bool) (map[string]any, error) {
+	if len(cfg.Tenants()) > 0 {
+		return bindTenants(cfg, fields, h, withReturn)
+	}
+
+	// the groups of fields are bound once their fields are, by bindArrayGroups
+	names := groupNames(fields)
+
+	fieldMap := make(map[string]any)
+	for _, field := range fields {
+		if isGroupField(field, names) {
+			continue
+		}
+
+		if err := bindField(cfg, field, fieldMap, withReturn); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := bindCorrelations(cfg, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindSessions(cfg, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindBucketFields(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindInterArrivalFields(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindHashedFields(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindASNFields(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindOrderedFields(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindTimeOfDayFields(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindConstraints(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindCumulativeFields(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindGeoTrajectoryFields(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindTLSFields(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindArrayValuesFromFields(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	if err := bindUnicodeSalt(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	// the runtime fields are embedded after the salt, so that their scripts can still parse them
+	if err := bindRuntimeSources(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	bindFloatSpecialValues(cfg, fields, fieldMap, withReturn)
+
+	if err := bindArrayGroups(cfg, fields, fieldMap, withReturn); err != nil {
+		return nil, err
+	}
+
+	bindHooks(h, fieldMap, withReturn)
+
+	return fieldMap, nil
+}
+
 func bindField(cfg Config, field Field, fieldMap map[string]any, withReturn bool) error {
 	// Check for hardcoded field value
 	if len(field.Value) > 0 {
@@ -149,6 +362,62 @@ func bindField(cfg Config, field Field, fieldMap map[string]any, withReturn bool
 
 	// Check config override of value
 	fieldCfg, _ := cfg.GetField(field.Name)
+	if err := fieldCfg.ValidQuantiles(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if fieldCfg.Quantiles != nil && !isIntegerFieldType(field.Type) && !isFloatFieldType(field.Type) && field.Type != FieldTypeHistogram {
+		return fmt.Errorf("field %s: `quantiles` is not supported for field type %s", field.Name, field.Type)
+	}
+
+	if err := fieldCfg.ValidDistribution(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if len(fieldCfg.Distribution) > 0 && !isIntegerFieldType(field.Type) && !isFloatFieldType(field.Type) && field.Type != FieldTypeHistogram {
+		return fmt.Errorf("field %s: `distribution` is not supported for field type %s", field.Name, field.Type)
+	}
+
+	if len(fieldCfg.Unit) > 0 && !isIntegerFieldType(field.Type) && !isFloatFieldType(field.Type) {
+		return fmt.Errorf("field %s: `unit` is not supported for field type %s", field.Name, field.Type)
+	}
+
+	if err := fieldCfg.ValidExcludeValues(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if err := fieldCfg.ValidAffixes(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if err := fieldCfg.ValidNormalize(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if err := fieldCfg.ValidGenerator(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if err := fieldCfg.ValidCIDR(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if isCIDRField(fieldCfg) && field.Type != FieldTypeIP {
+		return fmt.Errorf("field %s: `cidr`, `exclude_reserved` and `ipv6_fraction` require the %s field type", field.Name, FieldTypeIP)
+	}
+
+	if err := fieldCfg.ValidPattern(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if fieldCfg.Pattern != nil && field.Type != FieldTypeDate {
+		return fmt.Errorf("field %s: `pattern` requires the %s field type", field.Name, FieldTypeDate)
+	}
+
+	if fieldCfg.Histogram != nil && field.Type != FieldTypeHistogram {
+		return fmt.Errorf("field %s: `histogram` requires the %s field type", field.Name, FieldTypeHistogram)
+	}
+
 	if fieldCfg.Value != nil {
 		if withReturn {
 			return bindStaticWithReturn(field, fieldCfg.Value, fieldMap)
@@ -157,19 +426,105 @@ func bindField(cfg Config, field Field, fieldMap map[string]any, withReturn bool
 		}
 	}
 
+	if err := fieldCfg.ValidStrictCardinality(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if err := fieldCfg.ValidCardinalityDistribution(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	var err error
 	if fieldCfg.Cardinality > 0 {
 		if withReturn {
-			return bindCardinalityWithReturn(cfg, field, fieldMap)
+			err = bindCardinalityWithReturn(cfg, field, fieldMap)
+		} else {
+			err = bindCardinality(cfg, field, fieldMap)
+		}
+	} else {
+		if withReturn {
+			err = bindByTypeWithReturn(cfg, field, fieldMap)
 		} else {
-			return bindCardinality(cfg, field, fieldMap)
+			err = bindByType(cfg, field, fieldMap)
+		}
+
+		if err == nil {
+			err = bindExcludeValues(fieldCfg, field, fieldMap, withReturn)
 		}
 	}
 
-	if withReturn {
-		return bindByTypeWithReturn(cfg, field, fieldMap)
-	} else {
-		return bindByType(cfg, field, fieldMap)
+	if err != nil {
+		return err
 	}
+
+	return bindModifiers(fieldCfg, field, fieldMap, withReturn)
+}
+
+// bindModifiers wraps the emit function already bound for the field with the
+// config settings that alter the generated value regardless of the field type.
+func bindModifiers(fieldCfg ConfigField, field Field, fieldMap map[string]any, withReturn bool) error {
+	if strings.HasSuffix(field.Name, ".*") {
+		if _, ok := fieldMap[field.Name]; !ok {
+			field.Name = replacer.Replace(field.Name)
+		}
+	}
+
+	// Fields with `object_keys` are bound per key, nothing to wrap at the root
+	if _, ok := fieldMap[field.Name]; !ok {
+		return nil
+	}
+
+	// Fields with `dynamic_keys` have the values of their keys already wrapped
+	if fieldCfg.DynamicKeys != nil && isObjectFieldType(field.Type) {
+		return nil
+	}
+
+	if err := fieldCfg.ValidConstant(); err != nil {
+		return err
+	}
+
+	if fieldCfg.HasAffixes() {
+		if err := bindAffixes(fieldCfg, field, fieldMap, withReturn); err != nil {
+			return err
+		}
+	}
+
+	if fieldCfg.Normalize != nil {
+		if err := bindNormalize(fieldCfg, field, fieldMap, withReturn); err != nil {
+			return err
+		}
+	}
+
+	// arrays with `values_from` are bound once the fields they take the values from are
+	if fieldCfg.ArrayLength != nil && len(fieldCfg.ArrayLength.ValuesFrom) == 0 {
+		if err := bindArray(fieldCfg, field, fieldMap, nil, withReturn); err != nil {
+			return err
+		}
+	}
+
+	if fieldCfg.Reuse != nil {
+		if err := bindReuse(fieldCfg, field, fieldMap, withReturn); err != nil {
+			return err
+		}
+	}
+
+	if fieldCfg.PerRunConstant {
+		if withReturn {
+			return bindPerRunConstantWithReturn(field, fieldMap)
+		} else {
+			return bindPerRunConstant(field, fieldMap)
+		}
+	}
+
+	if fieldCfg.PerBatchConstant {
+		if withReturn {
+			return bindPerBatchConstantWithReturn(field, fieldMap)
+		} else {
+			return bindPerBatchConstant(field, fieldMap)
+		}
+	}
+
+	return nil
 }
 
 // Check for dupes O(n)
@@ -185,10 +540,25 @@ func isDupeByteSlice(va []bytes.Buffer, dst []byte) bool {
 }
 
 func isDupeAny(va map[any]struct{}, dst any) bool {
-	_, ok := va[dst]
+	_, ok := va[dupKey(dst)]
 	return ok
 }
 
+// dupKey returns the key of value in the maps of the values already generated: the value itself when it can be
+// a map key, its string form otherwise, like the bytes written by the custom template generator and the arrays,
+// objects and histograms of the text template one
+func dupKey(value any) any {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+
+	if value != nil && !reflect.TypeOf(value).Comparable() {
+		return fmt.Sprint(value)
+	}
+
+	return value
+}
+
 // Check for dupes O(n)
 func isDupeInterface(va []any, dst any) bool {
 	var dupe bool
@@ -205,25 +575,43 @@ func bindByType(cfg Config, field Field, fieldMap map[string]any) (err error) {
 
 	fieldCfg, _ := cfg.GetField(field.Name)
 
+	if len(fieldCfg.Samples) > 0 || fieldCfg.Smoothing != 0 {
+		return bindSamples(fieldCfg, field, fieldMap)
+	}
+
+	if isUserAgentField(fieldCfg, field) {
+		return bindUserAgent(fieldCfg, field, fieldMap)
+	}
+
+	if fieldCfg.Generator == config.GeneratorURL {
+		return bindURL(fieldCfg, field, fieldMap)
+	}
+
+	if len(fieldCfg.Locale) > 0 || len(fieldCfg.Content) > 0 {
+		return bindLocale(fieldCfg, field, fieldMap)
+	}
+
 	switch field.Type {
 	case FieldTypeDate:
 		err = bindNearTime(fieldCfg, field, fieldMap)
 	case FieldTypeIP:
-		err = bindIP(field, fieldMap)
+		err = bindIP(fieldCfg, field, fieldMap)
 	case FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat:
 		err = bindDouble(fieldCfg, field, fieldMap)
 	case FieldTypeByte, FieldTypeShort, FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong: // TODO: generate > 63 bit values for unsigned_long
 		err = bindLong(fieldCfg, field, fieldMap)
 	case FieldTypeConstantKeyword:
 		err = bindConstantKeyword(field, fieldMap)
-	case FieldTypeKeyword:
+	case FieldTypeKeyword, FieldTypeWildcard:
 		err = bindKeyword(fieldCfg, field, fieldMap)
 	case FieldTypeBool:
 		err = bindBool(field, fieldMap)
 	case FieldTypeObject, FieldTypeNested, FieldTypeFlattened:
 		err = bindObject(cfg, fieldCfg, field, fieldMap)
 	case FieldTypeGeoPoint:
-		err = bindGeoPoint(field, fieldMap)
+		err = bindGeoPoint(fieldCfg, field, fieldMap)
+	case FieldTypeHistogram:
+		err = bindHistogram(fieldCfg, field, fieldMap)
 	default:
 		err = bindWordN(field, 25, fieldMap)
 	}
@@ -234,25 +622,43 @@ func bindByType(cfg Config, field Field, fieldMap map[string]any) (err error) {
 func bindByTypeWithReturn(cfg Config, field Field, fieldMap map[string]any) (err error) {
 	fieldCfg, _ := cfg.GetField(field.Name)
 
+	if len(fieldCfg.Samples) > 0 || fieldCfg.Smoothing != 0 {
+		return bindSamplesWithReturn(fieldCfg, field, fieldMap)
+	}
+
+	if isUserAgentField(fieldCfg, field) {
+		return bindUserAgentWithReturn(fieldCfg, field, fieldMap)
+	}
+
+	if fieldCfg.Generator == config.GeneratorURL {
+		return bindURLWithReturn(fieldCfg, field, fieldMap)
+	}
+
+	if len(fieldCfg.Locale) > 0 || len(fieldCfg.Content) > 0 {
+		return bindLocaleWithReturn(fieldCfg, field, fieldMap)
+	}
+
 	switch field.Type {
 	case FieldTypeDate:
 		err = bindNearTimeWithReturn(fieldCfg, field, fieldMap)
 	case FieldTypeIP:
-		err = bindIPWithReturn(field, fieldMap)
+		err = bindIPWithReturn(fieldCfg, field, fieldMap)
 	case FieldTypeDouble, FieldTypeFloat, FieldTypeHalfFloat, FieldTypeScaledFloat:
 		err = bindDoubleWithReturn(fieldCfg, field, fieldMap)
 	case FieldTypeByte, FieldTypeShort, FieldTypeInteger, FieldTypeLong, FieldTypeUnsignedLong: // TODO: generate > 63 bit values for unsigned_long
 		err = bindLongWithReturn(fieldCfg, field, fieldMap)
 	case FieldTypeConstantKeyword:
 		err = bindConstantKeywordWithReturn(field, fieldMap)
-	case FieldTypeKeyword:
+	case FieldTypeKeyword, FieldTypeWildcard:
 		err = bindKeywordWithReturn(fieldCfg, field, fieldMap)
 	case FieldTypeBool:
 		err = bindBoolWithReturn(field, fieldMap)
 	case FieldTypeObject, FieldTypeNested, FieldTypeFlattened:
 		err = bindObjectWithReturn(cfg, fieldCfg, field, fieldMap)
 	case FieldTypeGeoPoint:
-		err = bindGeoPointWithReturn(field, fieldMap)
+		err = bindGeoPointWithReturn(fieldCfg, field, fieldMap)
+	case FieldTypeHistogram:
+		err = bindHistogramWithReturn(fieldCfg, field, fieldMap)
 	default:
 		err = bindWordNWithReturn(field, 25, fieldMap)
 	}
@@ -261,6 +667,19 @@ func bindByTypeWithReturn(cfg Config, field Field, fieldMap map[string]any) (err
 }
 
 func makeFloatFunc(r *rand.Rand, fieldCfg ConfigField, field Field) func() float64 {
+	// clamps the range to the values the type can hold
+	typeMin, typeMax := getFloatTypeBounds(field)
+
+	if quantileF, err := makeQuantileFunc(r, fieldCfg); err == nil {
+		return func() float64 {
+			return math.Min(math.Max(quantileF(), typeMin), typeMax)
+		}
+	}
+
+	if normalF, ok := makeNormalFunc(r, fieldCfg, typeMin, typeMax); ok {
+		return normalF
+	}
+
 	minValue, _ := fieldCfg.Range.MinAsFloat64()
 	maxValue, err := fieldCfg.Range.MaxAsFloat64()
 	// maxValue not set, let's set it to 0 for the sake of the switch above
@@ -268,6 +687,9 @@ func makeFloatFunc(r *rand.Rand, fieldCfg ConfigField, field Field) func() float
 		maxValue = 0
 	}
 
+	minValue = math.Max(minValue, typeMin)
+	maxValue = math.Min(maxValue, typeMax)
+
 	var dummyFunc func() float64
 
 	switch {
@@ -276,8 +698,7 @@ func makeFloatFunc(r *rand.Rand, fieldCfg ConfigField, field Field) func() float
 	case len(field.Example) == 0:
 		dummyFunc = func() float64 { return r.Float64() * 10 }
 	default:
-		totDigit := len(field.Example)
-		max := math.Pow10(totDigit)
+		max := math.Min(exampleMagnitude(field), typeMax)
 		dummyFunc = func() float64 {
 			return r.Float64() * max
 		}
@@ -303,6 +724,36 @@ func makeIntFunc(r *rand.Rand, fieldCfg ConfigField, field Field) (func() int64,
 		return nil, fmt.Errorf("invalid range: min %d greater than max %d", minValue, maxValue)
 	}
 
+	if normalF, ok := makeNormalFunc(r, fieldCfg, float64(minValue), float64(maxValue)); ok {
+		minFloat, maxFloat := float64(minValue), float64(maxValue)
+		return func() int64 {
+			v := math.Round(normalF())
+			switch {
+			case v <= minFloat:
+				return minValue
+			case v >= maxFloat:
+				return maxValue
+			default:
+				return int64(v)
+			}
+		}, nil
+	}
+
+	if quantileF, err := makeQuantileFunc(r, fieldCfg); err == nil {
+		minFloat, maxFloat := float64(minValue), float64(maxValue)
+		return func() int64 {
+			v := math.Round(quantileF())
+			switch {
+			case v <= minFloat:
+				return minValue
+			case v >= maxFloat:
+				return maxValue
+			default:
+				return int64(v)
+			}
+		}, nil
+	}
+
 	// reinterprets bits (two's complement)
 	umin := uint64(minValue)
 	umax := uint64(maxValue)
@@ -344,8 +795,7 @@ func makeIntFunc(r *rand.Rand, fieldCfg ConfigField, field Field) (func() int64,
 	case len(field.Example) == 0:
 		dummyFunc = func() int64 { return r.Int63n(10) }
 	default:
-		totDigit := len(field.Example)
-		max := int64(math.Pow10(totDigit))
+		max := int64(exampleMagnitude(field))
 		dummyFunc = func() int64 {
 			return r.Int63n(max)
 		}
@@ -375,6 +825,10 @@ func bindObject(cfg Config, fieldCfg ConfigField, field Field, fieldMap map[stri
 		return nil
 	}
 
+	if fieldCfg.DynamicKeys != nil {
+		return bindDynamicKeys(cfg, fieldCfg, field, fieldMap)
+	}
+
 	return bindDynamicObject(cfg, field, fieldMap)
 }
 
@@ -393,53 +847,38 @@ func bindDynamicObject(cfg Config, field Field, fieldMap map[string]any) error {
 	return nil
 }
 
-func genNounsN(n int, buf *bytes.Buffer) {
+func genNounsN(r *rand.Rand, n int, buf *bytes.Buffer) {
 
 	for i := 0; i < n-1; i++ {
-		buf.WriteString(randomdata.Noun())
+		buf.WriteString(randomNoun(r))
 		buf.WriteByte(' ')
 	}
 
-	// randomdata.Adjective() + randomdata.Noun() -> 364 * 527 (~190k) different values
-	buf.WriteString(randomdata.Adjective())
-	buf.WriteString(randomdata.Noun())
+	// randomAdjective() + randomNoun() -> 527 * 364 (~190k) different values
+	buf.WriteString(randomAdjective(r))
+	buf.WriteString(randomNoun(r))
 }
 
-func genNounsNWithReturn(n int) string {
+func genNounsNWithReturn(r *rand.Rand, n int) string {
 	value := ""
 	for i := 0; i < n-1; i++ {
-		value += randomdata.Noun() + " "
+		value += randomNoun(r) + " "
 	}
 
-	// randomdata.Adjective() + randomdata.Noun() -> 364 * 527 (~190k) different values
-	value += randomdata.Adjective()
-	value += randomdata.Noun()
+	// randomAdjective() + randomNoun() -> 527 * 364 (~190k) different values
+	value += randomAdjective(r)
+	value += randomNoun(r)
 
 	return value
 }
 
-func randGeoPoint(r *rand.Rand) (int, int, int, int) {
-	lat := r.Intn(181) - 90
-	var latD int
-	if lat != -90 && lat != 90 {
-		latD = r.Intn(100)
-	}
-	var longD int
-	long := r.Intn(361) - 180
-	if long != -180 && long != 180 {
-		longD = r.Intn(100)
-	}
-
-	return lat, latD, long, longD
-}
-
 func bindConstantKeyword(field Field, fieldMap map[string]any) error {
 	var emitFNotReturn emitFNotReturn
 	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
 		value, ok := state.prevCache[field.Name].(string)
 		if !ok {
-			// randomdata.Adjective() + randomdata.Noun() -> 364 * 527 (~190k) different values
-			value = randomdata.Adjective() + randomdata.Noun()
+			// randomAdjective() + randomNoun() -> 527 * 364 (~190k) different values
+			value = randomAdjective(state.rand) + randomNoun(state.rand)
 			state.prevCache[field.Name] = value
 		}
 		buf.WriteString(value)
@@ -452,10 +891,19 @@ func bindConstantKeyword(field Field, fieldMap map[string]any) error {
 
 func bindKeyword(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
 	if len(fieldCfg.Enum) > 0 {
+		enumF := makeEnumFunc(fieldCfg.Enum)
+
 		var emitFNotReturn emitFNotReturn
 		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
-			idx := state.rand.Intn(len(fieldCfg.Enum))
-			buf.WriteString(fieldCfg.Enum[idx])
+			buf.WriteString(fieldCfg.Enum[enumF(state.rand)].Value)
+			return nil
+		}
+
+		fieldMap[field.Name] = emitFNotReturn
+	} else if exampleF, ok := makeExampleFunc(field); ok {
+		var emitFNotReturn emitFNotReturn
+		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+			buf.WriteString(exampleF(state.rand))
 			return nil
 		}
 
@@ -467,8 +915,8 @@ func bindKeyword(fieldCfg ConfigField, field Field, fieldMap map[string]any) err
 	} else {
 		var emitFNotReturn emitFNotReturn
 		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
-			// randomdata.Adjective() + randomdata.Noun() -> 364 * 527 (~190k) different values
-			buf.WriteString(randomdata.Adjective() + randomdata.Noun())
+			// randomAdjective() + randomNoun() -> 527 * 364 (~190k) different values
+			buf.WriteString(randomAdjective(state.rand) + randomNoun(state.rand))
 			return nil
 		}
 
@@ -477,6 +925,32 @@ func bindKeyword(fieldCfg ConfigField, field Field, fieldMap map[string]any) err
 	return nil
 }
 
+// makeEnumFunc returns the index of a random entry of enum, picked with probability proportional to its weight
+func makeEnumFunc(enum config.Enum) func(r *rand.Rand) int {
+	if !enum.Weighted() {
+		return func(r *rand.Rand) int {
+			return r.Intn(len(enum))
+		}
+	}
+
+	cumulative := make([]float64, len(enum))
+	var total float64
+	for i, v := range enum {
+		total += v.Weight
+		cumulative[i] = total
+	}
+
+	return func(r *rand.Rand) int {
+		x := r.Float64() * total
+		idx := sort.Search(len(cumulative), func(i int) bool { return cumulative[i] > x })
+		if idx == len(cumulative) {
+			idx--
+		}
+
+		return idx
+	}
+}
+
 func totWordsAndJoiner(fieldExample string) (int, string) {
 	totWords := len(keywordRegex.Split(fieldExample, -1))
 
@@ -497,12 +971,12 @@ func bindJoinRand(field Field, N int, joiner string, fieldMap map[string]any) er
 	var emitFNotReturn emitFNotReturn
 	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
 		for i := 0; i < N-1; i++ {
-			buf.WriteString(randomdata.Noun())
+			buf.WriteString(randomNoun(state.rand))
 			buf.WriteString(joiner)
 		}
-		// randomdata.Adjective() + randomdata.Noun() -> 364 * 527 (~190k) different values
-		buf.WriteString(randomdata.Adjective())
-		buf.WriteString(randomdata.Noun())
+		// randomAdjective() + randomNoun() -> 527 * 364 (~190k) different values
+		buf.WriteString(randomAdjective(state.rand))
+		buf.WriteString(randomNoun(state.rand))
 		return nil
 	}
 
@@ -543,22 +1017,10 @@ func bindBool(field Field, fieldMap map[string]any) error {
 	return nil
 }
 
-func bindGeoPoint(field Field, fieldMap map[string]any) error {
-	var emitFNotReturn emitFNotReturn
-	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
-		lat, latD, long, longD := randGeoPoint(state.rand)
-		_, err := fmt.Fprintf(buf, "%d.%d,%d.%d", lat, latD, long, longD)
-		return err
-	}
-
-	fieldMap[field.Name] = emitFNotReturn
-	return nil
-}
-
 func bindWordN(field Field, n int, fieldMap map[string]any) error {
 	var emitFNotReturn emitFNotReturn
 	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
-		genNounsN(state.rand.Intn(n), buf)
+		genNounsN(state.rand, state.rand.Intn(n), buf)
 		return nil
 	}
 
@@ -608,12 +1070,26 @@ func nearTime(fieldCfg ConfigField, state *genState) time.Time {
 		}
 	}
 
+	if fieldCfg.Pattern != nil && fieldCfg.Period != 0 && state.totEvents > 0 {
+		// the timestamps are placed by the volume of the pattern within the period, instead of evenly
+		start := timeNowToBind
+		if fieldCfg.Period < 0 {
+			start = start.Add(fieldCfg.Period)
+		}
+
+		timeline := state.patternTimeline(fieldCfg.Name, fieldCfg.Pattern, start, fieldCfg.Period.Abs())
+		return timeline.at(float64(state.counter) / float64(state.totEvents))
+	}
+
 	if fieldCfg.Period > 0 && state.totEvents > 0 {
 		offset = time.Duration((fieldCfg.Period.Nanoseconds() / int64(state.totEvents)) * int64(state.counter))
 	} else if fieldCfg.Period < 0 && state.totEvents > 0 {
 		offset = time.Duration((fieldCfg.Period.Nanoseconds() / int64(state.totEvents)) * (int64(state.totEvents - state.counter)))
 	} else {
 		offset = time.Duration(state.rand.Intn(FieldTypeDurationSpan)) * time.Millisecond
+		if fieldCfg.Pattern != nil {
+			offset = state.patternTimeline(fieldCfg.Name, fieldCfg.Pattern, time.Time{}, 0).gap(offset, timeNowToBind)
+		}
 	}
 
 	newTime := timeNowToBind.Add(offset)
@@ -625,13 +1101,16 @@ func nearTime(fieldCfg ConfigField, state *genState) time.Time {
 	return newTime
 }
 
-func bindIP(field Field, fieldMap map[string]any) error {
+func bindIP(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	ipF, err := makeIPFunc(fieldCfg)
+	if err != nil {
+		return err
+	}
+
 	var emitFNotReturn emitFNotReturn
 	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
-		i0, i1, i2, i3 := randIP(state.rand)
-
-		_, err := fmt.Fprintf(buf, "%d.%d.%d.%d", i0, i1, i2, i3)
-		return err
+		buf.WriteString(ipF(state.rand))
+		return nil
 	}
 
 	fieldMap[field.Name] = emitFNotReturn
@@ -654,10 +1133,32 @@ func fuzzyIntCounter(r *rand.Rand, previous int64, fuzziness float64) int64 {
 }
 
 func bindLong(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
-	if err := fieldCfg.ValidCounter(); err != nil {
+	if err := validCounter(fieldCfg); err != nil {
 		return err
 	}
 
+	// check that all the enum values are valid longs, if any
+	for i, v := range fieldCfg.Enum {
+		_, err := strconv.ParseInt(v.Value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("field %s enum value #%d is not a long: %w", fieldCfg.Name, i, err)
+		}
+	}
+
+	if len(fieldCfg.Enum) > 0 {
+		enumF := makeEnumFunc(fieldCfg.Enum)
+
+		var emitFNotReturn emitFNotReturn
+		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+			f, _ := strconv.ParseInt(fieldCfg.Enum[enumF(state.rand)].Value, 10, 64)
+			buf.WriteString(strconv.FormatInt(f, 10))
+			return nil
+		}
+
+		fieldMap[field.Name] = emitFNotReturn
+		return nil
+	}
+
 	if fieldCfg.Counter {
 		var emitFNotReturn emitFNotReturn
 		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
@@ -669,14 +1170,21 @@ func bindLong(fieldCfg ConfigField, field Field, fieldMap map[string]any) error
 				previous = previousDummyInt
 			}
 
-			if fieldCfg.Fuzziness <= 0 {
+			switch {
+			case fieldCfg.CounterRate != nil:
+				dummyInt = previous + int64(math.Round(counterRateIncrement(state.rand, fieldCfg.CounterRate)))
+			case fieldCfg.Fuzziness <= 0:
 				dummyFunc = makeIntCounterFunc(state.rand, previous, field)
 
 				dummyInt = dummyFunc()
-			} else {
+			default:
 				dummyInt = fuzzyIntCounter(state.rand, previous, fieldCfg.Fuzziness)
 			}
 
+			if counterResets(state, fieldCfg.CounterReset) {
+				dummyInt = 0
+			}
+
 			state.prevCache[field.Name] = dummyInt
 			v := make([]byte, 0, 32)
 			v = strconv.AppendInt(v, dummyInt, 10)
@@ -750,11 +1258,94 @@ func fuzzyFloatCounter(r *rand.Rand, previous, fuzziness float64) float64 {
 	return lowerBound + r.Float64()*(higherBound-lowerBound)
 }
 
+const (
+	// unroundedDecimals are the decimals of the values not rounded, formatted with %f
+	unroundedDecimals = -1
+	// shortestDecimals are the decimals of the values rounded to a `scaling_factor`, formatted with the fewest
+	// digits representing them
+	shortestDecimals = -2
+)
+
+// makeRoundFloatFunc returns the function rounding the generated values according to `precision` and
+// `rounding` config, or to the `scaling_factor` for `scaled_float` fields, and the number of decimals to
+// format them with: unroundedDecimals when not rounded at all, and shortestDecimals for a `scaling_factor`,
+// like 1/3 or 0.5, whose multiples don't have a fixed number of decimals.
+func makeRoundFloatFunc(fieldCfg ConfigField, field Field) (func(float64) float64, int) {
+	roundF := math.Round
+	switch fieldCfg.Rounding {
+	case config.RoundingFloor:
+		roundF = math.Floor
+	case config.RoundingCeil:
+		roundF = math.Ceil
+	case config.RoundingHalfEven:
+		roundF = math.RoundToEven
+	}
+
+	var scale float64
+	var decimals int
+	switch {
+	case fieldCfg.Precision != nil:
+		decimals = *fieldCfg.Precision
+		scale = math.Pow10(decimals)
+	case field.Type == FieldTypeScaledFloat && field.ScalingFactor > 0:
+		scale = field.ScalingFactor
+		decimals = shortestDecimals
+	default:
+		return func(v float64) float64 { return v }, unroundedDecimals
+	}
+
+	return func(v float64) float64 {
+		return roundF(v*scale) / scale
+	}, decimals
+}
+
+// writeFloat writes the value with the given number of decimals, with the fewest digits representing it for
+// shortestDecimals, or with the default format for unroundedDecimals
+func writeFloat(buf *bytes.Buffer, v float64, decimals int) error {
+	if decimals == unroundedDecimals {
+		_, err := fmt.Fprintf(buf, "%f", v)
+		return err
+	}
+
+	f := make([]byte, 0, 32)
+	f = strconv.AppendFloat(f, v, 'f', decimals, 64)
+	buf.Write(f)
+	return nil
+}
+
 func bindDouble(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
-	if err := fieldCfg.ValidCounter(); err != nil {
+	if err := validCounter(fieldCfg); err != nil {
+		return err
+	}
+
+	if err := fieldCfg.ValidatePrecision(); err != nil {
 		return err
 	}
 
+	// check that all the enum values are valid doubles, if any
+	for i, v := range fieldCfg.Enum {
+		_, err := strconv.ParseFloat(v.Value, 64)
+		if err != nil {
+			return fmt.Errorf("field %s enum value #%d is not a double: %w", fieldCfg.Name, i, err)
+		}
+	}
+
+	if len(fieldCfg.Enum) > 0 {
+		enumF := makeEnumFunc(fieldCfg.Enum)
+
+		var emitFNotReturn emitFNotReturn
+		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+			f, _ := strconv.ParseFloat(fieldCfg.Enum[enumF(state.rand)].Value, 64)
+			buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+			return nil
+		}
+
+		fieldMap[field.Name] = emitFNotReturn
+		return nil
+	}
+
+	roundF, decimals := makeRoundFloatFunc(fieldCfg, field)
+
 	if fieldCfg.Counter {
 		var emitFNotReturn emitFNotReturn
 		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
@@ -766,17 +1357,24 @@ func bindDouble(fieldCfg ConfigField, field Field, fieldMap map[string]any) erro
 				previous = previousDummyFloat
 			}
 
-			if fieldCfg.Fuzziness <= 0 {
+			switch {
+			case fieldCfg.CounterRate != nil:
+				dummyFloat = previous + counterRateIncrement(state.rand, fieldCfg.CounterRate)
+			case fieldCfg.Fuzziness <= 0:
 				dummyFunc = makeFloatCounterFunc(state.rand, previous, field)
 
 				dummyFloat = dummyFunc()
-			} else {
+			default:
 				dummyFloat = fuzzyFloatCounter(state.rand, previous, fieldCfg.Fuzziness)
 			}
 
+			if counterResets(state, fieldCfg.CounterReset) {
+				dummyFloat = 0
+			}
+
+			dummyFloat = roundF(dummyFloat)
 			state.prevCache[field.Name] = dummyFloat
-			_, err := fmt.Fprintf(buf, "%f", dummyFloat)
-			return err
+			return writeFloat(buf, dummyFloat, decimals)
 		}
 
 		fieldMap[field.Name] = emitFNotReturn
@@ -788,9 +1386,8 @@ func bindDouble(fieldCfg ConfigField, field Field, fieldMap map[string]any) erro
 		var emitFNotReturn emitFNotReturn
 		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
 			dummyFunc := makeFloatFunc(state.rand, fieldCfg, field)
-			dummyFloat := dummyFunc()
-			_, err := fmt.Fprintf(buf, "%f", dummyFloat)
-			return err
+			dummyFloat := roundF(dummyFunc())
+			return writeFloat(buf, dummyFloat, decimals)
 		}
 
 		fieldMap[field.Name] = emitFNotReturn
@@ -809,10 +1406,10 @@ func bindDouble(fieldCfg ConfigField, field Field, fieldMap map[string]any) erro
 		} else {
 			dummyFloat = dummyFunc()
 		}
+		dummyFloat = roundF(dummyFloat)
 		state.prevCache[field.Name] = dummyFloat
-		_, err := fmt.Fprintf(buf, "%f", dummyFloat)
 
-		return err
+		return writeFloat(buf, dummyFloat, decimals)
 	}
 
 	fieldMap[field.Name] = emitFNotReturn
@@ -823,6 +1420,8 @@ func bindCardinality(cfg Config, field Field, fieldMap map[string]any) error {
 
 	fieldCfg, _ := cfg.GetField(field.Name)
 	cardinality := fieldCfg.Cardinality
+	nTries := cardinalityTries(fieldCfg)
+	zipf := fieldCfg.CardinalityDistribution == config.CardinalityDistributionZipf
 
 	if strings.HasSuffix(field.Name, ".*") {
 		field.Name = replacer.Replace(field.Name)
@@ -833,6 +1432,10 @@ func bindCardinality(cfg Config, field Field, fieldMap map[string]any) error {
 		return err
 	}
 
+	if err := bindExcludeValues(fieldCfg, field, fieldMap, false); err != nil {
+		return err
+	}
+
 	// We will wrap the function we just generated
 	boundF, ok := fieldMap[field.Name].(emitFNotReturn)
 	if !ok {
@@ -842,13 +1445,24 @@ func bindCardinality(cfg Config, field Field, fieldMap map[string]any) error {
 	var emitFNotReturn emitFNotReturn
 	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
 		// Have we rolled over once?  If not, generate a value and cache it.
-		if len(state.prevCacheCardinality[field.Name]) < cardinality {
+		// With zipf, generate a value the first time its rank is chosen instead.
+		var rank int
+		newValue := len(state.prevCacheCardinality[field.Name]) < cardinality
+		if zipf {
+			rank = cardinalityZipfRank(state, field.Name, cardinality)
+			newValue = rank >= len(state.prevCacheCardinality[field.Name])
+		}
+
+		if newValue {
+			if err := checkStrictCardinality(fieldCfg, field, state); err != nil {
+				return err
+			}
 
 			// Do college try dupe detection on value;
-			// Allow dupe if no unique value in nTries.
-			nTries := 11 // "These go to 11."
+			// Allow dupe if no unique value in nTries, unless strict.
 			var tmp bytes.Buffer
 			var value []byte
+			var dupe bool
 			for i := 0; i < nTries; i++ {
 
 				tmp.Reset()
@@ -857,18 +1471,25 @@ func bindCardinality(cfg Config, field Field, fieldMap map[string]any) error {
 				}
 
 				value = tmp.Bytes()
-				if !isDupeAny(state.prevCacheForDup[field.Name], string(value)) {
+				if dupe = isDupeAny(state.prevCacheForDup[field.Name], string(value)); !dupe {
 					break
 				}
 			}
 
+			if dupe && fieldCfg.StrictCardinality {
+				return fmt.Errorf("field %s: cannot generate %d distinct values for `strict_cardinality`", field.Name, cardinality)
+			}
+
 			state.prevCacheForDup[field.Name][string(value)] = struct{}{}
 			state.prevCacheCardinality[field.Name] = append(state.prevCacheCardinality[field.Name], value)
 		}
 
 		idx := int(state.counter % uint64(cardinality))
+		if zipf {
+			idx = rank
+		}
 
-		// Safety check; should be a noop
+		// Safety check; should be a noop, but for the new values with zipf
 		if idx >= len(state.prevCacheCardinality[field.Name]) {
 			idx = len(state.prevCacheCardinality[field.Name]) - 1
 		}
@@ -882,6 +1503,93 @@ func bindCardinality(cfg Config, field Field, fieldMap map[string]any) error {
 	return nil
 }
 
+// cardinalityTries returns the number of values generated to find one not generated yet for a `cardinality`
+// field: a duplicate is allowed after them, unless `strict_cardinality` is set.
+func cardinalityTries(fieldCfg ConfigField) int {
+	if fieldCfg.StrictCardinality {
+		return strictCardinalityTries
+	}
+
+	return 11 // "These go to 11."
+}
+
+// cardinalityZipfRank returns the rank of the value of a field with `cardinality_distribution: zipf`, between 0
+// and cardinality-1, the lower the more frequent
+func cardinalityZipfRank(state *genState, fieldName string, cardinality int) int {
+	z, ok := state.prevCacheZipf[fieldName]
+	if !ok {
+		z = rand.NewZipf(state.rand, cardinalityZipfExponent, 1, uint64(cardinality-1))
+		state.prevCacheZipf[fieldName] = z
+	}
+
+	return int(z.Uint64())
+}
+
+// checkStrictCardinality returns an error when the corpus has fewer events than the distinct values a field with
+// `strict_cardinality` must take
+func checkStrictCardinality(fieldCfg ConfigField, field Field, state *genState) error {
+	if fieldCfg.StrictCardinality && state.totEvents > 0 && state.totEvents < uint64(fieldCfg.Cardinality) {
+		return fmt.Errorf("field %s: `strict_cardinality` requires at least %d events, got %d", field.Name, fieldCfg.Cardinality, state.totEvents)
+	}
+
+	return nil
+}
+
+func bindPerRunConstant(field Field, fieldMap map[string]any) error {
+	boundF, ok := fieldMap[field.Name].(emitFNotReturn)
+	if !ok {
+		return errors.New("cannot bind per run constant")
+	}
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		value, ok := state.prevCacheRunConstant[field.Name].([]byte)
+		if !ok {
+			var tmp bytes.Buffer
+			if err := boundF(state, &tmp); err != nil {
+				return err
+			}
+
+			value = tmp.Bytes()
+			state.prevCacheRunConstant[field.Name] = value
+		}
+
+		buf.Write(value)
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
+
+func bindPerBatchConstant(field Field, fieldMap map[string]any) error {
+	boundF, ok := fieldMap[field.Name].(emitFNotReturn)
+	if !ok {
+		return errors.New("cannot bind per batch constant")
+	}
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		batch := state.batch()
+		cached, ok := state.prevCacheBatchConstant[field.Name]
+		if !ok || cached.batch != batch {
+			var tmp bytes.Buffer
+			if err := boundF(state, &tmp); err != nil {
+				return err
+			}
+
+			cached = batchConstant{batch: batch, value: tmp.Bytes()}
+			state.prevCacheBatchConstant[field.Name] = cached
+		}
+
+		buf.Write(cached.value.([]byte))
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
+
 func makeDynamicStub(boundF any) emitFNotReturn {
 	return func(state *genState, buf *bytes.Buffer) error {
 		v := state.pool.Get()
@@ -917,8 +1625,8 @@ func bindConstantKeywordWithReturn(field Field, fieldMap map[string]any) error {
 	emitF = func(state *genState) any {
 		value, ok := state.prevCache[field.Name].(string)
 		if !ok {
-			// randomdata.Adjective() + randomdata.Noun() -> 364 * 527 (~190k) different values
-			value = randomdata.Adjective() + randomdata.Noun()
+			// randomAdjective() + randomNoun() -> 527 * 364 (~190k) different values
+			value = randomAdjective(state.rand) + randomNoun(state.rand)
 			state.prevCache[field.Name] = value
 		}
 		return value
@@ -930,10 +1638,18 @@ func bindConstantKeywordWithReturn(field Field, fieldMap map[string]any) error {
 
 func bindKeywordWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
 	if len(fieldCfg.Enum) > 0 {
+		enumF := makeEnumFunc(fieldCfg.Enum)
+
+		var emitF emitF
+		emitF = func(state *genState) any {
+			return fieldCfg.Enum[enumF(state.rand)].Value
+		}
+
+		fieldMap[field.Name] = emitF
+	} else if exampleF, ok := makeExampleFunc(field); ok {
 		var emitF emitF
 		emitF = func(state *genState) any {
-			idx := state.rand.Intn(len(fieldCfg.Enum))
-			return fieldCfg.Enum[idx]
+			return exampleF(state.rand)
 		}
 
 		fieldMap[field.Name] = emitF
@@ -944,8 +1660,8 @@ func bindKeywordWithReturn(fieldCfg ConfigField, field Field, fieldMap map[strin
 	} else {
 		var emitF emitF
 		emitF = func(state *genState) any {
-			// randomdata.Adjective() + randomdata.Noun() -> 364 * 527 (~190k) different values
-			return randomdata.Adjective() + randomdata.Noun()
+			// randomAdjective() + randomNoun() -> 527 * 364 (~190k) different values
+			return randomAdjective(state.rand) + randomNoun(state.rand)
 		}
 
 		fieldMap[field.Name] = emitF
@@ -958,12 +1674,12 @@ func bindJoinRandWithReturn(field Field, N int, joiner string, fieldMap map[stri
 	emitF = func(state *genState) any {
 		value := ""
 		for i := 0; i < N-1; i++ {
-			value += randomdata.Noun() + joiner
+			value += randomNoun(state.rand) + joiner
 		}
 
-		// randomdata.Adjective() + randomdata.Noun() -> 364 * 527 (~190k) different values
-		value += randomdata.Adjective()
-		value += randomdata.Noun()
+		// randomAdjective() + randomNoun() -> 527 * 364 (~190k) different values
+		value += randomAdjective(state.rand)
+		value += randomNoun(state.rand)
 
 		return value
 	}
@@ -997,22 +1713,10 @@ func bindBoolWithReturn(field Field, fieldMap map[string]any) error {
 	return nil
 }
 
-func bindGeoPointWithReturn(field Field, fieldMap map[string]any) error {
-	var emitF emitF
-	emitF = func(state *genState) any {
-		lat, latD, long, longD := randGeoPoint(state.rand)
-		return fmt.Sprintf("%d.%d,%d.%d", lat, latD, long, longD)
-	}
-
-	fieldMap[field.Name] = emitF
-
-	return nil
-}
-
 func bindWordNWithReturn(field Field, n int, fieldMap map[string]any) error {
 	var emitF emitF
 	emitF = func(state *genState) any {
-		return genNounsNWithReturn(state.rand.Intn(n))
+		return genNounsNWithReturn(state.rand, state.rand.Intn(n))
 	}
 	fieldMap[field.Name] = emitF
 	return nil
@@ -1032,12 +1736,15 @@ func bindNearTimeWithReturn(fieldCfg ConfigField, field Field, fieldMap map[stri
 	return nil
 }
 
-func bindIPWithReturn(field Field, fieldMap map[string]any) error {
+func bindIPWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	ipF, err := makeIPFunc(fieldCfg)
+	if err != nil {
+		return err
+	}
+
 	var emitF emitF
 	emitF = func(state *genState) any {
-		i0, i1, i2, i3 := randIP(state.rand)
-
-		return fmt.Sprintf("%d.%d.%d.%d", i0, i1, i2, i3)
+		return ipF(state.rand)
 	}
 
 	fieldMap[field.Name] = emitF
@@ -1052,34 +1759,24 @@ func randIP(r *rand.Rand) (int, int, int, int) {
 	return i0, i1, i2, i3
 }
 func bindLongWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
-	if err := fieldCfg.ValidCounter(); err != nil {
-		return err
-	}
-
-	if err := fieldCfg.ValidateCounterResetStrategy(); err != nil {
-		return err
-	}
-
-	if err := fieldCfg.ValidateCounterResetAfterN(); err != nil {
-		return err
-	}
-
-	if err := fieldCfg.ValidateCounterResetProbabilistic(); err != nil {
+	if err := validCounter(fieldCfg); err != nil {
 		return err
 	}
 
 	// check that all the enum values are valid longs, if any
 	for i, v := range fieldCfg.Enum {
-		_, err := strconv.ParseInt(v, 10, 64)
+		_, err := strconv.ParseInt(v.Value, 10, 64)
 		if err != nil {
 			return fmt.Errorf("field %s enum value #%d is not a long: %w", fieldCfg.Name, i, err)
 		}
 	}
 
 	if len(fieldCfg.Enum) > 0 {
-		emitF := func(state *genState) any {
-			idx := state.rand.Intn(len(fieldCfg.Enum))
-			f, _ := strconv.ParseInt(fieldCfg.Enum[idx], 10, 64)
+		enumF := makeEnumFunc(fieldCfg.Enum)
+
+		var emitF emitF
+		emitF = func(state *genState) any {
+			f, _ := strconv.ParseInt(fieldCfg.Enum[enumF(state.rand)].Value, 10, 64)
 			return f
 		}
 
@@ -1099,32 +1796,19 @@ func bindLongWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]a
 				previous = previousDummyInt
 			}
 
-			if fieldCfg.Fuzziness <= 0 {
+			switch {
+			case fieldCfg.CounterRate != nil:
+				dummyInt = previous + int64(math.Round(counterRateIncrement(state.rand, fieldCfg.CounterRate)))
+			case fieldCfg.Fuzziness <= 0:
 				dummyFunc = makeIntCounterFunc(state.rand, previous, field)
 
 				dummyInt = dummyFunc()
-			} else {
+			default:
 				dummyInt = fuzzyIntCounter(state.rand, previous, fieldCfg.Fuzziness)
 			}
 
-			if fieldCfg.CounterReset != nil {
-				switch fieldCfg.CounterReset.Strategy {
-				case config.CounterResetStrategyRandom:
-					// 50% chance to reset
-					if state.rand.Intn(2) == 0 {
-						dummyInt = 0
-					}
-				case config.CounterResetStrategyProbabilistic:
-					// Probability% chance to reset
-					if state.rand.Intn(100) < int(*fieldCfg.CounterReset.Probability) {
-						dummyInt = 0
-					}
-				case config.CounterResetStrategyAfterN:
-					// Reset after N
-					if state.counter%*fieldCfg.CounterReset.ResetAfterN == 0 {
-						dummyInt = 0
-					}
-				}
+			if counterResets(state, fieldCfg.CounterReset) {
+				dummyInt = 0
 			}
 
 			state.prevCache[field.Name] = dummyInt
@@ -1183,8 +1867,7 @@ func makeIntCounterFunc(r *rand.Rand, previousDummyInt int64, field Field) func(
 	case len(field.Example) == 0:
 		dummyFunc = func() int64 { return previousDummyInt + r.Int63n(10) }
 	default:
-		totDigit := len(field.Example)
-		max := int64(math.Pow10(totDigit))
+		max := int64(exampleMagnitude(field))
 		dummyFunc = func() int64 {
 			return previousDummyInt + r.Int63n(max)
 		}
@@ -1200,8 +1883,7 @@ func makeFloatCounterFunc(r *rand.Rand, previousDummyFloat float64, field Field)
 	case len(field.Example) == 0:
 		dummyFunc = func() float64 { return previousDummyFloat + r.Float64()*10 }
 	default:
-		totDigit := len(field.Example)
-		max := math.Pow10(totDigit)
+		max := exampleMagnitude(field)
 		dummyFunc = func() float64 {
 			return previousDummyFloat + r.Float64()*max
 		}
@@ -1211,34 +1893,30 @@ func makeFloatCounterFunc(r *rand.Rand, previousDummyFloat float64, field Field)
 }
 
 func bindDoubleWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
-	if err := fieldCfg.ValidCounter(); err != nil {
+	if err := validCounter(fieldCfg); err != nil {
 		return err
 	}
 
-	if err := fieldCfg.ValidateCounterResetStrategy(); err != nil {
-		return err
-	}
-
-	if err := fieldCfg.ValidateCounterResetAfterN(); err != nil {
-		return err
-	}
-
-	if err := fieldCfg.ValidateCounterResetProbabilistic(); err != nil {
+	if err := fieldCfg.ValidatePrecision(); err != nil {
 		return err
 	}
 
 	// check that all the enum values are valid doubles, if any
 	for i, v := range fieldCfg.Enum {
-		_, err := strconv.ParseFloat(v, 64)
+		_, err := strconv.ParseFloat(v.Value, 64)
 		if err != nil {
 			return fmt.Errorf("field %s enum value #%d is not a double: %w", fieldCfg.Name, i, err)
 		}
 	}
 
+	roundF, _ := makeRoundFloatFunc(fieldCfg, field)
+
 	if len(fieldCfg.Enum) > 0 {
-		emitF := func(state *genState) any {
-			idx := state.rand.Intn(len(fieldCfg.Enum))
-			f, _ := strconv.ParseFloat(fieldCfg.Enum[idx], 64)
+		enumF := makeEnumFunc(fieldCfg.Enum)
+
+		var emitF emitF
+		emitF = func(state *genState) any {
+			f, _ := strconv.ParseFloat(fieldCfg.Enum[enumF(state.rand)].Value, 64)
 			return f
 		}
 
@@ -1258,34 +1936,22 @@ func bindDoubleWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string
 				previous = previousDummyFloat
 			}
 
-			if fieldCfg.Fuzziness <= 0 {
+			switch {
+			case fieldCfg.CounterRate != nil:
+				dummyFloat = previous + counterRateIncrement(state.rand, fieldCfg.CounterRate)
+			case fieldCfg.Fuzziness <= 0:
 				dummyFunc = makeFloatCounterFunc(state.rand, previous, field)
 
 				dummyFloat = dummyFunc()
-			} else {
+			default:
 				dummyFloat = fuzzyFloatCounter(state.rand, previous, fieldCfg.Fuzziness)
 			}
 
-			if fieldCfg.CounterReset != nil {
-				switch fieldCfg.CounterReset.Strategy {
-				case config.CounterResetStrategyRandom:
-					// 50% chance to reset
-					if state.rand.Intn(2) == 0 {
-						dummyFloat = 0
-					}
-				case config.CounterResetStrategyProbabilistic:
-					// Probability% chance to reset
-					if state.rand.Intn(100) < int(*fieldCfg.CounterReset.Probability) {
-						dummyFloat = 0
-					}
-				case config.CounterResetStrategyAfterN:
-					// Reset after N
-					if state.counter%*fieldCfg.CounterReset.ResetAfterN == 0 {
-						dummyFloat = 0
-					}
-				}
+			if counterResets(state, fieldCfg.CounterReset) {
+				dummyFloat = 0
 			}
 
+			dummyFloat = roundF(dummyFloat)
 			state.prevCache[field.Name] = dummyFloat
 			return dummyFloat
 		}
@@ -1299,7 +1965,7 @@ func bindDoubleWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string
 		var emitF emitF
 		emitF = func(state *genState) any {
 			dummyFunc := makeFloatFunc(state.rand, fieldCfg, field)
-			return dummyFunc()
+			return roundF(dummyFunc())
 		}
 
 		fieldMap[field.Name] = emitF
@@ -1319,6 +1985,7 @@ func bindDoubleWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string
 		} else {
 			dummyFloat = dummyFunc()
 		}
+		dummyFloat = roundF(dummyFloat)
 		state.prevCache[field.Name] = dummyFloat
 		return dummyFloat
 	}
@@ -1332,6 +1999,8 @@ func bindCardinalityWithReturn(cfg Config, field Field, fieldMap map[string]any)
 
 	fieldCfg, _ := cfg.GetField(field.Name)
 	cardinality := fieldCfg.Cardinality
+	nTries := cardinalityTries(fieldCfg)
+	zipf := fieldCfg.CardinalityDistribution == config.CardinalityDistributionZipf
 
 	if strings.HasSuffix(field.Name, ".*") {
 		field.Name = replacer.Replace(field.Name)
@@ -1342,31 +2011,54 @@ func bindCardinalityWithReturn(cfg Config, field Field, fieldMap map[string]any)
 		return err
 	}
 
+	if err := bindExcludeValues(fieldCfg, field, fieldMap, true); err != nil {
+		return err
+	}
+
 	// We will wrap the function we just generated
 	boundFWithReturn := fieldMap[field.Name].(emitF)
 	var emitF emitF
 	emitF = func(state *genState) any {
 		var value any
 		// Have we rolled over once?  If not, generate a value and cache it.
-		if len(state.prevCacheCardinality[field.Name]) < cardinality {
+		// With zipf, generate a value the first time its rank is chosen instead.
+		var rank int
+		newValue := len(state.prevCacheCardinality[field.Name]) < cardinality
+		if zipf {
+			rank = cardinalityZipfRank(state, field.Name, cardinality)
+			newValue = rank >= len(state.prevCacheCardinality[field.Name])
+		}
+
+		if newValue {
+			if err := checkStrictCardinality(fieldCfg, field, state); err != nil {
+				panic(err)
+			}
+
 			// Do college try dupe detection on value;
-			// Allow dupe if no unique value in nTries.
-			nTries := 11 // "These go to 11."
+			// Allow dupe if no unique value in nTries, unless strict.
+			var dupe bool
 			for i := 0; i < nTries; i++ {
 				value = boundFWithReturn(state)
 
-				if !isDupeAny(state.prevCacheForDup[field.Name], value) {
+				if dupe = isDupeAny(state.prevCacheForDup[field.Name], value); !dupe {
 					break
 				}
 			}
 
-			state.prevCacheForDup[field.Name][value] = struct{}{}
+			if dupe && fieldCfg.StrictCardinality {
+				panic(fmt.Errorf("field %s: cannot generate %d distinct values for `strict_cardinality`", field.Name, cardinality))
+			}
+
+			state.prevCacheForDup[field.Name][dupKey(value)] = struct{}{}
 			state.prevCacheCardinality[field.Name] = append(state.prevCacheCardinality[field.Name], value)
 		}
 
 		idx := int(state.counter % uint64(cardinality))
+		if zipf {
+			idx = rank
+		}
 
-		// Safety check; should be a noop
+		// Safety check; should be a noop, but for the new values with zipf
 		if idx >= len(state.prevCacheCardinality[field.Name]) {
 			idx = len(state.prevCacheCardinality[field.Name]) - 1
 		}
@@ -1380,6 +2072,49 @@ func bindCardinalityWithReturn(cfg Config, field Field, fieldMap map[string]any)
 	return nil
 }
 
+func bindPerRunConstantWithReturn(field Field, fieldMap map[string]any) error {
+	boundFWithReturn, ok := fieldMap[field.Name].(emitF)
+	if !ok {
+		return errors.New("cannot bind per run constant")
+	}
+
+	var emitF emitF
+	emitF = func(state *genState) any {
+		value, ok := state.prevCacheRunConstant[field.Name]
+		if !ok {
+			value = boundFWithReturn(state)
+			state.prevCacheRunConstant[field.Name] = value
+		}
+
+		return value
+	}
+
+	fieldMap[field.Name] = emitF
+	return nil
+}
+
+func bindPerBatchConstantWithReturn(field Field, fieldMap map[string]any) error {
+	boundFWithReturn, ok := fieldMap[field.Name].(emitF)
+	if !ok {
+		return errors.New("cannot bind per batch constant")
+	}
+
+	var emitF emitF
+	emitF = func(state *genState) any {
+		batch := state.batch()
+		cached, ok := state.prevCacheBatchConstant[field.Name]
+		if !ok || cached.batch != batch {
+			cached = batchConstant{batch: batch, value: boundFWithReturn(state)}
+			state.prevCacheBatchConstant[field.Name] = cached
+		}
+
+		return cached.value
+	}
+
+	fieldMap[field.Name] = emitF
+	return nil
+}
+
 func bindObjectWithReturn(cfg Config, fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
 	if len(field.ObjectType) > 0 {
 		field.Type = field.ObjectType
@@ -1401,6 +2136,10 @@ func bindObjectWithReturn(cfg Config, fieldCfg ConfigField, field Field, fieldMa
 		return nil
 	}
 
+	if fieldCfg.DynamicKeys != nil {
+		return bindDynamicKeysWithReturn(cfg, fieldCfg, field, fieldMap)
+	}
+
 	return bindDynamicObjectWithReturn(cfg, field, fieldMap)
 }
 
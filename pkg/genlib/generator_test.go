@@ -3,9 +3,11 @@ package genlib
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"log"
 	"math/rand"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,17 +17,44 @@ import (
 
 func TestMain(m *testing.M) {
 	timeNow := time.Now()
-	randSeed := rand.Int63()
 
-	log.Printf("rand seed generator initialised with value `%d`\n", randSeed)
 	log.Printf("time now generator initialised with value `%s`\n", timeNow.UTC().Format(time.RFC3339Nano))
 
-	InitGeneratorRandSeed(randSeed)
 	InitGeneratorTimeNow(timeNow)
 
 	os.Exit(m.Run())
 }
 
+func TestGenerateTemplateFromFieldSeed(t *testing.T) {
+	// more keys than nouns, so that some keys are the random ids of the fallback
+	var flds Fields
+	for i := 0; i < 200; i++ {
+		flds = append(flds, Field{Name: fmt.Sprintf("labels%d.*", i), Type: FieldTypeKeyword})
+	}
+
+	templates := make([][]byte, 4)
+	var wg sync.WaitGroup
+	for i := range templates {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			templates[i], _ = generateCustomTemplateFromField(Config{}, flds, rand.New(rand.NewSource(1)))
+		}(i)
+	}
+	wg.Wait()
+
+	for _, template := range templates[1:] {
+		if !bytes.Equal(templates[0], template) {
+			t.Fatal("expected the same template with the same seed")
+		}
+	}
+
+	other, _ := generateCustomTemplateFromField(Config{}, flds, rand.New(rand.NewSource(2)))
+	if bytes.Equal(templates[0], other) {
+		t.Fatal("expected a different template with a different seed")
+	}
+}
+
 func Benchmark_GeneratorCustomTemplateJSONContent(b *testing.B) {
 	ctx := context.Background()
 	flds, _, err := fields.LoadFields(ctx, fields.ProductionBaseURL, "endpoint", "process", "8.2.0")
@@ -6,9 +6,12 @@ package genlib
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"math/rand"
 	"regexp"
+	"strings"
 )
 
 type emitter struct {
@@ -16,71 +19,276 @@ type emitter struct {
 	fieldType string
 	emitFunc  emitFNotReturn
 	prefix    []byte
+	// ref is true when the placeholder references the value of the field already rendered in the event
+	ref bool
+	// format is nil when the value is written as rendered
+	format placeholderFormat
+	// rangeValue is true for the `{{.}}` placeholder, writing the value iterated by the enclosing `range` block
+	rangeValue bool
+	// body is set for a `range` block over the array field, as in `{{range .answers}}` or `{{range $.answers}}`,
+	// and for an `if` and a `repeat` block
+	body *block
+	// repeat is set for a `repeat` block, as in `{{#repeat 1 3 ","}}`
+	repeat *repeatCount
+	// cond is set for an `if` block, as in `{{if $.referrer}}` or `{{if eq $.method "GET"}}`
+	cond *condition
+	// elseBody is set for an `if` block with an `else`
+	elseBody *block
+}
+
+// block holds the emitters of a template, or of the body of a `range`, and the template after the last of them
+type block struct {
+	emitters []emitter
+	trailing []byte
+}
+
+const (
+	// refPlaceholderPrefix marks a placeholder referencing the value of a field already rendered in the event,
+	// as in `{{$.timestamp}}`, instead of generating a new one
+	refPlaceholderPrefix = "$."
+	rangeBlockPrefix     = "range "
+	ifBlockPrefix        = "if "
+	repeatBlockPrefix    = "repeat "
+	elseBlock            = "else"
+	endBlock             = "end"
+	// the blocks can be written as `{{#if .field}}...{{/if}}` and `{{#repeat 3}}...{{/repeat}}` too
+	hashBlockPrefix = "#"
+	ifEndBlock      = "/if"
+	repeatEndBlock  = "/repeat"
+)
+
+// blockEnd returns the placeholder closing the block opened by the content of a placeholder
+func blockEnd(content string) string {
+	switch {
+	case strings.HasPrefix(content, hashBlockPrefix+ifBlockPrefix):
+		return ifEndBlock
+	case strings.HasPrefix(content, hashBlockPrefix+repeatBlockPrefix):
+		return repeatEndBlock
+	default:
+		return endBlock
+	}
+}
+
+// isBlockEnd reports whether the content of a placeholder closes a block
+func isBlockEnd(content string) bool {
+	return content == endBlock || content == ifEndBlock || content == repeatEndBlock
 }
 
 // GeneratorWithCustomTemplate is resolved at construction to a slice of emit functions
 type GeneratorWithCustomTemplate struct {
 	totEvents        uint64
+	fields           Fields
 	emitters         []emitter
 	trailingTemplate []byte
 	state            *genState
+	hooks            hooks
+	timeline         timeline
 }
 
-func parseCustomTemplate(template []byte) ([]string, map[string][]byte, []byte) {
+// placeholderRegex matches the placeholders of the custom template: the template between them is written as is
+var placeholderRegex = regexp.MustCompile(`{{(?:\$?\.[^}]*|range \$?\.[^}]+|if (?:eq |ne )?\$?\.[^}]*|#if (?:eq |ne )?\$?\.?[^}]+|#?repeat [^}]+|else|end|/if|/repeat)}}`)
+
+// parseCustomTemplate returns the content of the placeholders of the template, in order, the template before each of
+// them, and the template after the last one
+func parseCustomTemplate(template []byte) ([]string, [][]byte, []byte) {
 	if len(template) == 0 {
 		return nil, nil, nil
 	}
 
-	tokenizer := regexp.MustCompile(`([^{]*)({{\.[^}]+}})*`)
-	allIndexes := tokenizer.FindAllSubmatchIndex(template, -1)
+	allIndexes := placeholderRegex.FindAllIndex(template, -1)
 
 	orderedFields := make([]string, 0, len(allIndexes))
-	templateFieldsMap := make(map[string][]byte, len(allIndexes))
+	fieldPrefixes := make([][]byte, 0, len(allIndexes))
 
-	var fieldPrefixBuffer []byte
-	var fieldPrefixPreviousN int
-	var trimTrailingTemplateN int
+	var previousN int
+	for _, loc := range allIndexes {
+		// strip the curly braces and the leading dot of the field name
+		content := template[loc[0]+2 : loc[1]-2]
+		if content[0] == '.' {
+			content = content[1:]
+		}
 
-	for i, loc := range allIndexes {
-		var fieldName []byte
 		var fieldPrefix []byte
+		if loc[0] > previousN {
+			fieldPrefix = template[previousN:loc[0]]
+		}
 
-		if loc[4] > -1 && loc[5] > -1 {
-			fieldName = template[loc[4]+3 : loc[5]-2]
+		orderedFields = append(orderedFields, string(content))
+		fieldPrefixes = append(fieldPrefixes, fieldPrefix)
+		previousN = loc[1]
+	}
+
+	var trailingTemplate []byte
+	if previousN < len(template) {
+		trailingTemplate = template[previousN:]
+	}
+
+	return orderedFields, fieldPrefixes, trailingTemplate
+}
+
+// parseEmitters returns the emitters of the placeholders from position i up to the end of the enclosing block,
+// and the position of the `end`, `/if`, `/repeat` or `else` placeholder of the block
+func parseEmitters(cfg Config, placeholders []string, prefixes [][]byte, fieldTypes map[string]string, i int, inRange bool) ([]emitter, int, error) {
+	emitters := make([]emitter, 0, len(placeholders)-i)
+	for ; i < len(placeholders); i++ {
+		content := placeholders[i]
+		if isBlockEnd(content) || content == elseBlock {
+			return emitters, i, nil
 		}
 
-		if loc[2] > -1 && loc[3] > -1 {
-			fieldPrefix = template[loc[2]:loc[3]]
+		if strings.HasPrefix(strings.TrimPrefix(content, hashBlockPrefix), repeatBlockPrefix) {
+			e, end, err := parseRepeatBlock(cfg, placeholders, prefixes, fieldTypes, i, inRange)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			emitters = append(emitters, e)
+			i = end
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimPrefix(content, hashBlockPrefix), ifBlockPrefix) {
+			e, end, err := parseIfBlock(cfg, placeholders, prefixes, fieldTypes, i, inRange)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			emitters = append(emitters, e)
+			i = end
+			continue
 		}
 
-		if len(fieldName) == 0 {
-			if template[fieldPrefixPreviousN] == byte(123) {
-				fieldPrefixBuffer = append(fieldPrefixBuffer, byte(123))
-			} else {
-				if i == len(allIndexes)-1 {
-					fieldPrefixBuffer = template[trimTrailingTemplateN:]
-				} else {
-					fieldPrefixBuffer = append(fieldPrefixBuffer, fieldPrefix...)
-					fieldPrefixBufferIdx := bytes.Index(template[trimTrailingTemplateN:], fieldPrefixBuffer)
-					if fieldPrefixBufferIdx > 0 {
-						trimTrailingTemplateN += fieldPrefixBufferIdx
-					}
+		if strings.HasPrefix(content, rangeBlockPrefix) {
+			p, err := parsePlaceholder(strings.TrimPrefix(strings.TrimPrefix(content, rangeBlockPrefix), "."))
+			if err != nil {
+				return nil, 0, err
+			}
+
+			if p.format != nil {
+				return nil, 0, fmt.Errorf("cannot format {{%s}}", content)
+			}
+
+			if fieldCfg, _ := cfg.GetField(p.fieldName); fieldCfg.ArrayLength == nil {
+				return nil, 0, fmt.Errorf("cannot range over field %s: `array_length` is not set", p.fieldName)
+			}
+
+			bodyEmitters, end, err := parseEmitters(cfg, placeholders, prefixes, fieldTypes, i+1, true)
+			if err != nil {
+				return nil, 0, err
+			}
 
-				}
+			if end == len(placeholders) || placeholders[end] != endBlock {
+				return nil, 0, fmt.Errorf("missing {{end}} of {{%s}}", content)
 			}
-		} else {
-			fieldPrefixBuffer = append(fieldPrefixBuffer, fieldPrefix...)
-			trimTrailingTemplateN = loc[5]
-			templateFieldsMap[string(fieldName)] = fieldPrefixBuffer
-			orderedFields = append(orderedFields, string(fieldName))
-			fieldPrefixBuffer = nil
+
+			emitters = append(emitters, emitter{
+				fieldName: p.fieldName,
+				fieldType: fieldTypes[p.fieldName],
+				prefix:    prefixes[i],
+				ref:       p.ref,
+				body:      &block{emitters: bodyEmitters, trailing: prefixes[end]},
+			})
+
+			i = end
+			continue
+		}
+
+		p, err := parsePlaceholder(content)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if len(p.fieldName) == 0 && !inRange {
+			return nil, 0, errors.New("{{.}} is only allowed in a range block")
 		}
 
-		fieldPrefixPreviousN = loc[2]
+		emitters = append(emitters, emitter{
+			fieldName:  p.fieldName,
+			fieldType:  fieldTypes[p.fieldName],
+			prefix:     prefixes[i],
+			ref:        p.ref,
+			format:     p.format,
+			rangeValue: len(p.fieldName) == 0,
+		})
+	}
+
+	return emitters, i, nil
+}
+
+// parseIfBlock returns the emitter of the `if` block at position i, and the position of its `end` placeholder
+func parseIfBlock(cfg Config, placeholders []string, prefixes [][]byte, fieldTypes map[string]string, i int, inRange bool) (emitter, int, error) {
+	content := placeholders[i]
+	p, cond, err := parseCondition(strings.TrimPrefix(strings.TrimPrefix(content, hashBlockPrefix), ifBlockPrefix))
+	if err != nil {
+		return emitter{}, 0, err
+	}
+
+	if len(p.fieldName) == 0 && !inRange {
+		return emitter{}, 0, errors.New("{{.}} is only allowed in a range block")
 	}
 
-	return orderedFields, templateFieldsMap, fieldPrefixBuffer
+	bodyEmitters, end, err := parseEmitters(cfg, placeholders, prefixes, fieldTypes, i+1, inRange)
+	if err != nil {
+		return emitter{}, 0, err
+	}
+
+	closing := blockEnd(content)
+	if end == len(placeholders) || (placeholders[end] != closing && placeholders[end] != elseBlock) {
+		return emitter{}, 0, fmt.Errorf("missing {{%s}} of {{%s}}", closing, content)
+	}
 
+	// the condition is on the value of the field in the event, as the one written by its placeholders, and not
+	// on a new one: `{{if .field}}` is bound like `{{if $.field}}`
+	e := emitter{
+		fieldName:  p.fieldName,
+		fieldType:  fieldTypes[p.fieldName],
+		prefix:     prefixes[i],
+		ref:        len(p.fieldName) > 0,
+		rangeValue: len(p.fieldName) == 0,
+		cond:       cond,
+		body:       &block{emitters: bodyEmitters, trailing: prefixes[end]},
+	}
+
+	if placeholders[end] == elseBlock {
+		elseEmitters, elseEnd, err := parseEmitters(cfg, placeholders, prefixes, fieldTypes, end+1, inRange)
+		if err != nil {
+			return emitter{}, 0, err
+		}
+
+		if elseEnd == len(placeholders) || placeholders[elseEnd] != closing {
+			return emitter{}, 0, fmt.Errorf("missing {{%s}} of {{%s}}", closing, content)
+		}
+
+		e.elseBody = &block{emitters: elseEmitters, trailing: prefixes[elseEnd]}
+		end = elseEnd
+	}
+
+	return e, end, nil
+}
+
+// parseRepeatBlock returns the emitter of the `repeat` block at position i, and the position of its `end` placeholder
+func parseRepeatBlock(cfg Config, placeholders []string, prefixes [][]byte, fieldTypes map[string]string, i int, inRange bool) (emitter, int, error) {
+	content := placeholders[i]
+	count, err := parseRepeatCount(strings.TrimPrefix(strings.TrimPrefix(content, hashBlockPrefix), repeatBlockPrefix))
+	if err != nil {
+		return emitter{}, 0, err
+	}
+
+	bodyEmitters, end, err := parseEmitters(cfg, placeholders, prefixes, fieldTypes, i+1, inRange)
+	if err != nil {
+		return emitter{}, 0, err
+	}
+
+	closing := blockEnd(content)
+	if end == len(placeholders) || placeholders[end] != closing {
+		return emitter{}, 0, fmt.Errorf("missing {{%s}} of {{%s}}", closing, content)
+	}
+
+	return emitter{
+		prefix: prefixes[i],
+		repeat: count,
+		body:   &block{emitters: bodyEmitters, trailing: prefixes[end]},
+	}, end, nil
 }
 
 func newGeneratorWithCustomTemplate(cfg Config, fields Fields, totEvents uint64, opts options) (Generator, error) {
@@ -93,36 +301,270 @@ func newGeneratorWithCustomTemplate(cfg Config, fields Fields, totEvents uint64,
 	}
 
 	// Parse the template and extract relevant information
-	orderedFields, templateFieldsMap, trailingTemplate := parseCustomTemplate(opts.template)
+	orderedFields, fieldPrefixes, trailingTemplate := parseCustomTemplate(opts.template)
 
 	// Preprocess the fields, generating appropriate emit functions
 	state := newGenState(opts.randSeed)
-	fieldMap := make(map[string]any)
+	fieldMap, err := bindFields(cfg, fields, opts.hooks, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateTimeline(cfg, fields, false); err != nil {
+		return nil, err
+	}
+
 	fieldTypes := make(map[string]string)
 	for _, field := range fields {
-		if err := bindField(cfg, field, fieldMap, false); err != nil {
-			return nil, err
-		}
-
 		fieldTypes[field.Name] = field.Type
 		state.prevCacheForDup[field.Name] = make(map[any]struct{})
 		state.prevCacheCardinality[field.Name] = make([]any, 0)
 	}
 
 	// Roll into slice of emit functions
-	emitters := make([]emitter, 0, len(fieldMap))
-	for _, fieldName := range orderedFields {
-		emitters = append(emitters, emitter{
-			fieldName: fieldName,
-			emitFunc:  fieldMap[fieldName].(emitFNotReturn),
-			fieldType: fieldTypes[fieldName],
-			prefix:    templateFieldsMap[fieldName],
-		})
+	emitters, end, err := parseEmitters(cfg, orderedFields, fieldPrefixes, fieldTypes, 0, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if end < len(orderedFields) {
+		return nil, fmt.Errorf("unexpected {{%s}} outside of a block", orderedFields[end])
+	}
+
+	emitters, err = bindEmitters(emitters, fieldMap)
+	if err != nil {
+		return nil, err
 	}
 
 	state.totEvents = totEvents
+	state.batchSize = opts.batchSize
+	state.tenantCount = len(cfg.Tenants())
+
+	return &GeneratorWithCustomTemplate{fields: fields, emitters: emitters, trailingTemplate: trailingTemplate, totEvents: totEvents, state: state, hooks: opts.hooks, timeline: timeline{cfg: cfg}}, nil
+}
+
+// Clone returns a generator sharing the emit functions, but with its own state, seeded with randSeed,
+// so that it can emit events concurrently with the original generator.
+func (gen *GeneratorWithCustomTemplate) Clone(randSeed int64) Generator {
+	clone := &GeneratorWithCustomTemplate{fields: gen.fields, emitters: gen.emitters, trailingTemplate: gen.trailingTemplate, totEvents: gen.totEvents, state: gen.state.clone(randSeed), hooks: gen.hooks, timeline: timeline{cfg: gen.timeline.cfg}}
+	if gen.timeline.next > 0 {
+		// the clone starts from the first event, before any timeline step: the config has been already bound, so it never fails
+		_ = clone.rebind(gen.timeline.cfg)
+	}
+
+	return clone
+}
+
+// Reload binds the fields again with cfg, keeping the state of the generator: the values already
+// generated for cardinalities, counters and constants are kept, and the event counter goes on.
+// The steps of the timeline of cfg already due are applied before the next event.
+func (gen *GeneratorWithCustomTemplate) Reload(cfg Config) error {
+	if err := validateTimeline(cfg, gen.fields, false); err != nil {
+		return err
+	}
+
+	if err := gen.rebind(cfg); err != nil {
+		return err
+	}
+
+	// the `at` steps keep counting from the first event
+	gen.timeline = timeline{cfg: cfg, start: gen.timeline.start, now: gen.timeline.now}
+
+	return nil
+}
+
+func (gen *GeneratorWithCustomTemplate) rebind(cfg Config) error {
+	fieldMap, err := bindFields(cfg, gen.fields, gen.hooks, false)
+	if err != nil {
+		return err
+	}
 
-	return &GeneratorWithCustomTemplate{emitters: emitters, trailingTemplate: trailingTemplate, totEvents: totEvents, state: state}, nil
+	emitters, err := bindEmitters(gen.emitters, fieldMap)
+	if err != nil {
+		return err
+	}
+
+	gen.emitters = emitters
+
+	return nil
+}
+
+// bindEmitters returns a copy of emitters with the emit functions of fieldMap.
+// The fields referenced by a placeholder are rendered once per event, and every placeholder of the field
+// writes the same value.
+func bindEmitters(emitters []emitter, fieldMap map[string]any) ([]emitter, error) {
+	if err := bindReferencedFields(emitters, fieldMap); err != nil {
+		return nil, err
+	}
+
+	return bindEmitFuncs(emitters, fieldMap), nil
+}
+
+func bindReferencedFields(emitters []emitter, fieldMap map[string]any) error {
+	for _, e := range emitters {
+		if e.body != nil {
+			if err := bindReferencedFields(e.body.emitters, fieldMap); err != nil {
+				return err
+			}
+		}
+
+		if e.elseBody != nil {
+			if err := bindReferencedFields(e.elseBody.emitters, fieldMap); err != nil {
+				return err
+			}
+		}
+
+		if !e.ref {
+			continue
+		}
+
+		if _, err := bindEventRawValue(e.fieldName, fieldMap, false); err != nil {
+			return fmt.Errorf("cannot reference field %s: %w", e.fieldName, err)
+		}
+	}
+
+	return nil
+}
+
+func bindEmitFuncs(emitters []emitter, fieldMap map[string]any) []emitter {
+	bound := make([]emitter, 0, len(emitters))
+	for _, e := range emitters {
+		switch {
+		case e.repeat != nil:
+			e.body = &block{emitters: bindEmitFuncs(e.body.emitters, fieldMap), trailing: e.body.trailing}
+			e.emitFunc = makeRepeatEmitF(e.repeat, e.body)
+		case e.cond != nil:
+			valueF := emitRangeValue
+			if !e.rangeValue {
+				valueF = fieldMap[e.fieldName].(emitFNotReturn)
+			}
+
+			e.body = &block{emitters: bindEmitFuncs(e.body.emitters, fieldMap), trailing: e.body.trailing}
+			if e.elseBody != nil {
+				e.elseBody = &block{emitters: bindEmitFuncs(e.elseBody.emitters, fieldMap), trailing: e.elseBody.trailing}
+			}
+
+			e.emitFunc = makeIfEmitF(valueF, e.cond, e.body, e.elseBody)
+		case e.body != nil:
+			e.body = &block{emitters: bindEmitFuncs(e.body.emitters, fieldMap), trailing: e.body.trailing}
+			e.emitFunc = makeRangeEmitF(fieldMap[e.fieldName].(emitFNotReturn), e.body)
+		case e.rangeValue:
+			e.emitFunc = emitRangeValue
+		default:
+			e.emitFunc = fieldMap[e.fieldName].(emitFNotReturn)
+		}
+
+		if e.format != nil {
+			e.emitFunc = makeFormatEmitF(e.emitFunc, e.format)
+		}
+
+		bound = append(bound, e)
+	}
+
+	return bound
+}
+
+// makeRangeEmitF writes the body of a `range` block for each value of the array field
+func makeRangeEmitF(emitFunc emitFNotReturn, body *block) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		v := state.pool.Get()
+		tmp := v.(*bytes.Buffer)
+		tmp.Reset()
+		defer state.pool.Put(tmp)
+
+		if err := emitFunc(state, tmp); err != nil {
+			return err
+		}
+
+		values, err := decodeArray(tmp.Bytes())
+		if err != nil {
+			return err
+		}
+
+		for _, value := range values {
+			state.rangeValues = append(state.rangeValues, value)
+			err := emitBlock(state, buf, body.emitters, body.trailing)
+			state.rangeValues = state.rangeValues[:len(state.rangeValues)-1]
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// makeRepeatEmitF writes the body of a `repeat` block the number of times of the count, with its separator
+// between them
+func makeRepeatEmitF(count *repeatCount, body *block) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		n := count.n(state)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				buf.Write(count.separator)
+			}
+
+			if err := emitBlock(state, buf, body.emitters, body.trailing); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// makeIfEmitF writes the body of an `if` block when the value of the field satisfies the condition,
+// or the body of its `else` otherwise
+func makeIfEmitF(emitFunc emitFNotReturn, cond *condition, body, elseBody *block) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		v := state.pool.Get()
+		tmp := v.(*bytes.Buffer)
+		tmp.Reset()
+		defer state.pool.Put(tmp)
+
+		if err := emitFunc(state, tmp); err != nil {
+			return err
+		}
+
+		if cond.isTrue(tmp.Bytes()) {
+			return emitBlock(state, buf, body.emitters, body.trailing)
+		}
+
+		if elseBody != nil {
+			return emitBlock(state, buf, elseBody.emitters, elseBody.trailing)
+		}
+
+		return nil
+	}
+}
+
+func emitRangeValue(state *genState, buf *bytes.Buffer) error {
+	buf.Write(state.rangeValues[len(state.rangeValues)-1])
+	return nil
+}
+
+func emitBlock(state *genState, buf *bytes.Buffer, emitters []emitter, trailing []byte) error {
+	for _, e := range emitters {
+		buf.Write(e.prefix)
+		state.emitting = e.fieldName
+		if err := e.emitFunc(state, buf); err != nil {
+			return err
+		}
+
+		state.emitting = ""
+	}
+
+	buf.Write(trailing)
+
+	return nil
+}
+
+func (gen *GeneratorWithCustomTemplate) SaveState(w io.Writer) error {
+	return gen.state.save(w, false)
+}
+
+func (gen *GeneratorWithCustomTemplate) LoadState(r io.Reader) error {
+	return gen.state.load(r, false)
 }
 
 func (gen *GeneratorWithCustomTemplate) Close() error {
@@ -130,7 +572,7 @@ func (gen *GeneratorWithCustomTemplate) Close() error {
 }
 
 func (gen *GeneratorWithCustomTemplate) Emit(buf *bytes.Buffer) error {
-	if err := gen.emit(buf); err != nil {
+	if err := emitHandlingErrors(gen.state, gen.hooks, buf, gen.emit); err != nil {
 		return err
 	}
 
@@ -139,16 +581,38 @@ func (gen *GeneratorWithCustomTemplate) Emit(buf *bytes.Buffer) error {
 	return nil
 }
 
+func (gen *GeneratorWithCustomTemplate) EmitDocument(buf *bytes.Buffer) (map[string]any, error) {
+	return emitDocument(buf, gen.Emit)
+}
+
+func (gen *GeneratorWithCustomTemplate) EmitBatch(buf *bytes.Buffer, n int) ([][]byte, error) {
+	return emitBatch(buf, n, gen.Emit)
+}
+
 func (gen *GeneratorWithCustomTemplate) emit(buf *bytes.Buffer) error {
 	if gen.totEvents == 0 || gen.state.counter < gen.totEvents {
-		for _, e := range gen.emitters {
-			buf.Write(e.prefix)
-			if err := e.emitFunc(gen.state, buf); err != nil {
+		if cfg, steps, ok := gen.timeline.advance(gen.state.counter); ok {
+			if err := gen.rebind(cfg); err != nil {
+				return err
+			}
+
+			if err := gen.hooks.runTimelineSteps(gen.state, steps); err != nil {
 				return err
 			}
 		}
 
-		buf.Write(gen.trailingTemplate)
+		if err := gen.hooks.runBeforeEvent(gen.state); err != nil {
+			return err
+		}
+
+		offset := buf.Len()
+		if err := emitBlock(gen.state, buf, gen.emitters, gen.trailingTemplate); err != nil {
+			return err
+		}
+
+		if err := gen.hooks.runAfterEvent(gen.state, buf, offset); err != nil {
+			return err
+		}
 	} else {
 		return io.EOF
 	}
@@ -2,11 +2,17 @@ package genlib
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"math/rand"
 	"net"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -41,7 +47,7 @@ func Test_ParseTemplate(t *testing.T) {
 		{
 			template:                  []byte("with prefix {{.aField}} {{.anotherField}}"),
 			expectedOrderFields:       []string{"aField", "anotherField"},
-			expectedTemplateFieldsMap: map[string][]byte{"aField": []byte("with prefix"), "anotherField": []byte(" ")},
+			expectedTemplateFieldsMap: map[string][]byte{"aField": []byte("with prefix "), "anotherField": []byte(" ")},
 			expectedTrailingTemplate:  nil,
 		},
 		{
@@ -53,7 +59,7 @@ func Test_ParseTemplate(t *testing.T) {
 		{
 			template:                  []byte("with prefix {{.aField}} {{.anotherField}} and trailing"),
 			expectedOrderFields:       []string{"aField", "anotherField"},
-			expectedTemplateFieldsMap: map[string][]byte{"aField": []byte("with prefix"), "anotherField": []byte(" ")},
+			expectedTemplateFieldsMap: map[string][]byte{"aField": []byte("with prefix "), "anotherField": []byte(" ")},
 			expectedTrailingTemplate:  []byte(" and trailing"),
 		},
 		{
@@ -146,10 +152,33 @@ func Test_ParseTemplate(t *testing.T) {
 			expectedTemplateFieldsMap: map[string][]byte{"aField": []byte("{"), "anotherField": []byte(" with curly brace as prefix just before a field and { in the middle ")},
 			expectedTrailingTemplate:  []byte(" and { curly brace in trailing with again { curly brace in trailing"),
 		},
+		{
+			template:                  []byte("{{.aField}} referenced {{$.aField}}"),
+			expectedOrderFields:       []string{"aField", "$.aField"},
+			expectedTemplateFieldsMap: map[string][]byte{"aField": nil, "$.aField": []byte(" referenced ")},
+			expectedTrailingTemplate:  nil,
+		},
+		{
+			template:                  []byte(`{{.aField | printf "%08d"}} {{$.aField}}`),
+			expectedOrderFields:       []string{`aField | printf "%08d"`, "$.aField"},
+			expectedTemplateFieldsMap: map[string][]byte{`aField | printf "%08d"`: nil, "$.aField": []byte(" ")},
+			expectedTrailingTemplate:  nil,
+		},
+		{
+			template:                  []byte(`[{{range $.aField}}{"value":"{{.}}"},{{end}}]`),
+			expectedOrderFields:       []string{"range $.aField", "", "end"},
+			expectedTemplateFieldsMap: map[string][]byte{"range $.aField": []byte("["), "": []byte(`{"value":"`), "end": []byte(`"},`)},
+			expectedTrailingTemplate:  []byte("]"),
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(fmt.Sprintf("with template: %s", string(testCase.template)), func(t *testing.T) {
-			orderedFields, templateFieldsMap, trailingTemplate := parseCustomTemplate(testCase.template)
+			orderedFields, fieldPrefixes, trailingTemplate := parseCustomTemplate(testCase.template)
+			templateFieldsMap := make(map[string][]byte, len(orderedFields))
+			for i, fieldName := range orderedFields {
+				templateFieldsMap[fieldName] = fieldPrefixes[i]
+			}
+
 			if len(orderedFields) != len(testCase.expectedOrderFields) {
 				t.Errorf("Expected equal orderedFields")
 			}
@@ -167,6 +196,8 @@ func Test_ParseTemplate(t *testing.T) {
 			for k := range templateFieldsMap {
 				if _, ok := testCase.expectedTemplateFieldsMap[k]; !ok {
 					t.Errorf("Missing expected field `%s` in templateFieldsMap", k)
+				} else if !bytes.Equal(templateFieldsMap[k], testCase.expectedTemplateFieldsMap[k]) {
+					t.Errorf("Expected prefix of field `%s` is wrong (expected: `%s`, given: `%s`", k, testCase.expectedTemplateFieldsMap[k], templateFieldsMap[k])
 				}
 			}
 
@@ -981,6 +1012,4087 @@ func Test_FieldIPWithCustomTemplate(t *testing.T) {
 	}
 }
 
+func Test_FieldPerRunConstantWithCustomTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeLong,
+	}
+
+	template := []byte(`{"alpha":{{.alpha}}}`)
+	configYaml := []byte("fields:\n  - name: alpha\n    per_run_constant: true")
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1024
+	g := makeGeneratorWithCustomTemplate(t, cfg, []Field{fld}, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	var first int64
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[int64](t, buf.Bytes())
+		buf.Reset()
+
+		v, ok := m[fld.Name]
+		if !ok {
+			t.Errorf("Missing key %v", fld.Name)
+		}
+
+		if i == 0 {
+			first = v
+		}
+
+		if v != first {
+			t.Errorf("Expected constant value %d, got %d", first, v)
+		}
+	}
+}
+
+func Test_FieldFloatPrecisionWithCustomTemplate(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		field    Field
+		yaml     []byte
+		decimals int
+	}{
+		{
+			scenario: "precision",
+			field:    Field{Name: "alpha", Type: FieldTypeDouble},
+			yaml:     []byte("fields:\n  - name: alpha\n    precision: 2\n    range:\n      min: 0\n      max: 100"),
+			decimals: 2,
+		},
+		{
+			scenario: "zero precision",
+			field:    Field{Name: "alpha", Type: FieldTypeFloat},
+			yaml:     []byte("fields:\n  - name: alpha\n    precision: 0\n    rounding: floor"),
+			decimals: 0,
+		},
+		{
+			scenario: "scaled float",
+			field:    Field{Name: "alpha", Type: FieldTypeScaledFloat, ScalingFactor: 1000},
+			decimals: 3,
+		},
+		{
+			scenario: "scaled float by quarters",
+			field:    Field{Name: "alpha", Type: FieldTypeScaledFloat, ScalingFactor: 4},
+			decimals: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			var cfg Config
+			if testCase.yaml != nil {
+				var err error
+				cfg, err = config.LoadConfigFromYaml(testCase.yaml)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			template := []byte(`{{.alpha}}`)
+			g := makeGeneratorWithCustomTemplate(t, cfg, []Field{testCase.field}, template, 0)
+
+			var buf bytes.Buffer
+			nSpins := 128
+			for i := 0; i < nSpins; i++ {
+				if err := g.Emit(&buf); err != nil {
+					t.Fatal(err)
+				}
+
+				value := buf.String()
+				buf.Reset()
+
+				decimals := 0
+				if idx := strings.Index(value, "."); idx > -1 {
+					decimals = len(value) - idx - 1
+				}
+
+				// the multiples of a scaling factor are written with the fewest decimals
+				if decimals != testCase.decimals && (testCase.field.Type != FieldTypeScaledFloat || decimals > testCase.decimals) {
+					t.Errorf("Expected %d decimals, got %s", testCase.decimals, value)
+				}
+
+				if factor := testCase.field.ScalingFactor; factor > 0 {
+					v, err := strconv.ParseFloat(value, 64)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if math.Abs(v*factor-math.Round(v*factor)) > 1e-6 {
+						t.Errorf("Expected a multiple of 1/%v, got %s", factor, value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_FieldFloatPrecisionErrorsWithCustomTemplate(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+	}{
+		{scenario: "negative precision", config: "precision: -1"},
+		{scenario: "precision out of the range of a float64", config: "precision: 400"},
+		{scenario: "unknown rounding", config: "precision: 2\n    rounding: up"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := config.LoadConfigFromYaml([]byte("fields:\n  - name: alpha\n    " + testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = NewGenerator(cfg, []Field{{Name: "alpha", Type: FieldTypeDouble}}, 1, WithCustomTemplate([]byte(`{{.alpha}}`)))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func Test_FieldUnitErrorsWithCustomTemplate(t *testing.T) {
+	for _, fieldType := range []string{FieldTypeKeyword, FieldTypeDate, FieldTypeBool} {
+		t.Run(fieldType, func(t *testing.T) {
+			cfg, err := config.LoadConfigFromYaml([]byte("fields:\n  - name: alpha\n    unit: percent"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = NewGenerator(cfg, []Field{{Name: "alpha", Type: fieldType}}, 1, WithCustomTemplate([]byte(`{{.alpha}}`)))
+			if err == nil || !strings.Contains(err.Error(), "`unit` is not supported for field type "+fieldType) {
+				t.Fatalf("expected unit error, got %v", err)
+			}
+		})
+	}
+}
+
+func Test_ConstraintSumTotalFieldWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "total", Type: FieldTypeLong},
+		{Name: "used", Type: FieldTypeLong},
+		{Name: "free", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"used":{{.used}},"free":{{.free}},"total":{{.total}}}`)
+	configYaml := []byte(`fields:
+  - name: total
+    range:
+      min: 1000
+      max: 2000
+constraints:
+  - type: sum
+    fields: ["used", "free"]
+    total_field: total`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1024
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[int64](t, buf.Bytes())
+		buf.Reset()
+
+		if m["total"] < 1000 || m["total"] > 2000 {
+			t.Errorf("Expected total in range, got %d", m["total"])
+		}
+
+		if m["used"]+m["free"] != m["total"] {
+			t.Errorf("Expected values to sum to total, got %v", m)
+		}
+	}
+}
+
+func Test_ConstraintAggregateWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "latency.min", Type: FieldTypeDouble},
+		{Name: "latency.avg", Type: FieldTypeDouble},
+		{Name: "latency.max", Type: FieldTypeDouble},
+		{Name: "latency.count", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"max":{{.latency.max}},"min":{{.latency.min}},"avg":{{.latency.avg}},"count":{{.latency.count}}}`)
+	configYaml := []byte(`constraints:
+  - type: aggregate
+    samples: 5
+    range:
+      min: 10
+      max: 20
+    fields: ["latency.min", "latency.avg", "latency.max", "latency.count"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1024
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		buf.Reset()
+
+		if m["min"] < 10 || m["max"] > 20 {
+			t.Errorf("Expected values in range, got %v", m)
+		}
+
+		if m["min"] > m["avg"] || m["avg"] > m["max"] {
+			t.Errorf("Expected min <= avg <= max, got %v", m)
+		}
+
+		if m["count"] != 5 {
+			t.Errorf("Expected count of 5, got %v", m["count"])
+		}
+	}
+}
+
+func Test_ConstraintAggregateInvalidRangeWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "latency.min", Type: FieldTypeDouble},
+		{Name: "latency.max", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"max":{{.latency.max}},"min":{{.latency.min}}}`)
+	for _, configYaml := range []string{
+		"constraints:\n  - type: aggregate\n    range:\n      min: 20\n      max: 10\n    fields: [\"latency.min\", \"latency.max\"]",
+		// the default max is 100
+		"constraints:\n  - type: aggregate\n    range:\n      min: 200\n    fields: [\"latency.min\", \"latency.max\"]",
+	} {
+		cfg, err := config.LoadConfigFromYaml([]byte(configYaml))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewGenerator(cfg, flds, 1, WithCustomTemplate(template)); err == nil {
+			t.Errorf("Expected error for min greater than max, with config %s", configYaml)
+		}
+	}
+}
+
+func Test_ConstraintAggregateRoundingWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "latency.min", Type: FieldTypeDouble},
+		{Name: "latency.avg", Type: FieldTypeDouble},
+		{Name: "latency.max", Type: FieldTypeDouble},
+		{Name: "latency.sum", Type: FieldTypeDouble},
+		{Name: "latency.count", Type: FieldTypeLong},
+	}
+
+	// the avg is rounded more coarsely than the min and the max, in a range narrower than its precision
+	template := []byte(`{"max":{{.latency.max}},"min":{{.latency.min}},"avg":{{.latency.avg}},"sum":{{.latency.sum}},"count":{{.latency.count}}}`)
+	configYaml := []byte(`fields:
+  - name: latency.min
+    precision: 2
+  - name: latency.max
+    precision: 2
+  - name: latency.avg
+    precision: 1
+  - name: latency.sum
+    precision: 1
+constraints:
+  - type: aggregate
+    samples: 3
+    range:
+      min: 10.3
+      max: 10.6
+    fields: ["latency.min", "latency.avg", "latency.max", "latency.sum", "latency.count"]`)
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for seed := int64(0); seed < 500; seed++ {
+		g, err := NewGenerator(cfg, flds, 4, WithCustomTemplate(template), WithRandSeed(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		for i := 0; i < 4; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[float64](t, buf.Bytes())
+			buf.Reset()
+
+			// the avg is between the min and the max whenever a value with its decimals is
+			if math.Ceil(m["min"]*10-1e-6) <= math.Floor(m["max"]*10+1e-6) && (m["min"] > m["avg"] || m["avg"] > m["max"]) {
+				t.Fatalf("seed %d: expected min <= avg <= max, got %v", seed, m)
+			}
+
+			// the avg is the rounded sum over the count, unless moved within the min and the max
+			avg := math.Round(m["sum"]/m["count"]*10) / 10
+			if m["avg"] != avg && m["avg"] != math.Ceil(m["min"]*10-1e-6)/10 && m["avg"] != math.Floor(m["max"]*10+1e-6)/10 {
+				t.Fatalf("seed %d: expected avg %v, got %v", seed, avg, m)
+			}
+		}
+	}
+}
+
+func Test_SaveAndLoadStateWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "count", Type: FieldTypeLong},
+		{Name: "location", Type: FieldTypeGeoPoint},
+	}
+
+	template := []byte(`{"host":"{{.host}}","count":{{.count}},"location":{{.location}}}`)
+	configYaml := []byte(`fields:
+  - name: host
+    cardinality: 3
+  - name: count
+    counter: true
+  - name: location
+    geo_format: object
+    geo_trajectory:
+      max_speed: 50`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type point struct{ lat, lon float64 }
+
+	emit := func(g Generator, n int) (map[string]struct{}, float64, []point) {
+		hosts := make(map[string]struct{})
+		var count float64
+		var locations []point
+		var buf bytes.Buffer
+		for i := 0; i < n; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[any](t, buf.Bytes())
+			buf.Reset()
+
+			hosts[m["host"].(string)] = struct{}{}
+			count = m["count"].(float64)
+			location := m["location"].(map[string]any)
+			locations = append(locations, point{lat: location["lat"].(float64), lon: location["lon"].(float64)})
+		}
+
+		return hosts, count, locations
+	}
+
+	nSpins := 10
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+	hosts, count, locations := emit(g, nSpins)
+
+	var state bytes.Buffer
+	if err := g.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err = NewGenerator(cfg, flds, uint64(nSpins), WithCustomTemplate(template), WithRandSeed(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	nextHosts, nextCount, nextLocations := emit(g, nSpins)
+	if nextCount <= count {
+		t.Errorf("Expected the counter to carry on from %v, got %v", count, nextCount)
+	}
+
+	// a minute at 50 km/h from the last location of the previous run
+	last, next := locations[len(locations)-1], nextLocations[0]
+	if distance := haversineKm(last.lat, last.lon, next.lat, next.lon); distance > 0.834 {
+		t.Errorf("Expected the trajectory to carry on from %v, got %v, %v km away", last, next, distance)
+	}
+
+	for host := range nextHosts {
+		if _, ok := hosts[host]; !ok {
+			t.Errorf("Expected the hosts of the previous run %v, got %s", hosts, host)
+		}
+	}
+}
+
+func Test_SaveAndLoadStateEntitiesWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "ip", Type: FieldTypeIP},
+		{Name: "user", Type: FieldTypeKeyword},
+		{Name: "@timestamp", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{"host":"{{.host}}","ip":"{{.ip}}","user":"{{.user}}","@timestamp":"{{.@timestamp}}"}`)
+	configYaml := []byte(`fields:
+  - name: "@timestamp"
+    range:
+      from: "2023-01-01T00:00:00+00:00"
+    inter_arrival:
+      distribution: fixed
+      mean: 1s
+correlations:
+  - cardinality: 2
+    fields: [host, ip]
+sessions:
+  - length:
+      min: 100
+      max: 100
+    fields: [user]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type event struct {
+		entity string
+		user   string
+		ts     time.Time
+	}
+
+	emit := func(g Generator, n int) []event {
+		var events []event
+		var buf bytes.Buffer
+		for i := 0; i < n; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[string](t, buf.Bytes())
+			buf.Reset()
+
+			ts, err := time.Parse(FieldTypeTimeLayout, m["@timestamp"])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			events = append(events, event{entity: m["host"] + " " + m["ip"], user: m["user"], ts: ts})
+		}
+
+		return events
+	}
+
+	nSpins := 10
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+	events := emit(g, nSpins)
+
+	entities := make(map[string]struct{})
+	for _, e := range events {
+		entities[e.entity] = struct{}{}
+	}
+
+	var state bytes.Buffer
+	if err := g.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err = NewGenerator(cfg, flds, uint64(nSpins), WithCustomTemplate(template), WithRandSeed(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	last := events[len(events)-1]
+	nextEvents := emit(g, nSpins)
+
+	// the timestamps carry on a second after the last one of the previous run
+	if expected := last.ts.Add(time.Second); !nextEvents[0].ts.Equal(expected) {
+		t.Errorf("Expected the timestamps to carry on from %v, got %v", expected, nextEvents[0].ts)
+	}
+
+	for _, e := range nextEvents {
+		// the entities of the correlation are the ones of the previous run
+		if _, ok := entities[e.entity]; !ok {
+			t.Errorf("Expected the entities of the previous run %v, got %s", entities, e.entity)
+		}
+
+		// the session of the previous run goes on
+		if e.user != last.user {
+			t.Errorf("Expected the user of the session of the previous run %s, got %s", last.user, e.user)
+		}
+	}
+}
+
+func Test_FieldBucketWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "timestamp", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{"host":"{{.host}}","@timestamp":"{{.timestamp}}"}`)
+	configYaml := []byte(`fields:
+  - name: host
+    enum: ["a", "b", "c"]
+  - name: timestamp
+    range:
+      from: "2023-01-01T00:00:00.000000000+00:00"
+    bucket:
+      interval: 10s
+      docs: 2
+      entity: host`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 60
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	docs := make(map[string]int)
+	seen := make(map[string]struct{})
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		ts, err := time.Parse(FieldTypeTimeLayout, m["@timestamp"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := seen[m["host"]+m["@timestamp"]]; ok {
+			t.Errorf("Expected no duplicate timestamp for %s, got %s twice", m["host"], m["@timestamp"])
+		}
+
+		seen[m["host"]+m["@timestamp"]] = struct{}{}
+		docs[fmt.Sprintf("%s/%d", m["host"], ts.Sub(from)/(10*time.Second))]++
+	}
+
+	if len(docs) != 30 {
+		t.Errorf("Expected 10 buckets for 3 hosts, got %d", len(docs))
+	}
+
+	for bucket, n := range docs {
+		if n != 2 {
+			t.Errorf("Expected 2 docs in bucket %s, got %d", bucket, n)
+		}
+	}
+}
+
+func Test_FieldBucketGapsWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "timestamp", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{"host":"{{.host}}","@timestamp":"{{.timestamp}}"}`)
+	configYaml := []byte(`fields:
+  - name: host
+    cardinality: 5
+  - name: timestamp
+    range:
+      from: "2023-01-01T00:00:00.000000000+00:00"
+    bucket:
+      interval: 10s
+      entity: host
+      gaps:
+        probability: 0.2
+        min: 2
+        max: 3`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 500
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	buckets := make(map[string][]int64)
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		ts, err := time.Parse(FieldTypeTimeLayout, m["@timestamp"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buckets[m["host"]] = append(buckets[m["host"]], int64(ts.Sub(from)/(10*time.Second)))
+	}
+
+	if len(buckets) != 5 {
+		t.Fatalf("Expected 5 hosts, got %d", len(buckets))
+	}
+
+	var gaps int
+	for host, hostBuckets := range buckets {
+		for i := 1; i < len(hostBuckets); i++ {
+			switch missing := hostBuckets[i] - hostBuckets[i-1] - 1; {
+			case missing < 0:
+				t.Errorf("Expected one document per interval for %s, got interval %d twice", host, hostBuckets[i])
+			case missing == 1 || missing > 3:
+				t.Errorf("Expected gaps of 2 or 3 intervals for %s, got %d", host, missing)
+			case missing > 0:
+				gaps++
+			}
+		}
+	}
+
+	if gaps == 0 {
+		t.Error("Expected some gaps, got none")
+	}
+}
+
+func Test_FieldFloatSpecialValuesWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeDouble},
+		{Name: "beta", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"alpha":{{.alpha}},"beta":{{.beta}}}`)
+	configYaml := []byte(`version: 3
+fields:
+  - name: alpha
+    range:
+      min: -1.7e308
+      max: 1.7e308
+  - name: beta
+    precision: 2
+    normalize_negative_zero: true
+    range:
+      min: -0.004
+      max: 0.001`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Contains(buf.Bytes(), []byte("Inf")) || bytes.Contains(buf.Bytes(), []byte("NaN")) || bytes.Contains(buf.Bytes(), []byte("-0.00")) {
+			t.Errorf("Expected no special values, got %s", buf.String())
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		buf.Reset()
+
+		if math.IsInf(m["alpha"], 0) || m["beta"] != 0 {
+			t.Errorf("Expected finite alpha and zero beta, got %v", m)
+		}
+	}
+}
+
+func Test_FieldLocaleWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+		{Name: "gamma", Type: "text"},
+		{Name: "delta", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}","beta":"{{.beta}}","gamma":"{{.gamma}}","delta":"{{.delta}}"}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    locale: ja
+    content: person_name
+  - name: beta
+    locale: de
+    content: address
+  - name: gamma
+    locale: ja
+    content: text
+  - name: delta
+    locale: ar`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	nonASCII := map[string]int{}
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		if len(strings.Fields(m["alpha"])) != 2 {
+			t.Errorf("Expected a first and a last name, got %s", m["alpha"])
+		}
+
+		if !strings.Contains(m["beta"], ", ") {
+			t.Errorf("Expected an address, got %s", m["beta"])
+		}
+
+		if strings.Contains(m["gamma"], " ") || !strings.HasSuffix(m["gamma"], "。") {
+			t.Errorf("Expected a japanese text, got %s", m["gamma"])
+		}
+
+		for k, v := range m {
+			for _, r := range v {
+				if r > 127 {
+					nonASCII[k]++
+					break
+				}
+			}
+		}
+	}
+
+	// every japanese and arabic value is non-ASCII, german addresses only sometimes
+	for _, k := range []string{"alpha", "gamma", "delta"} {
+		if nonASCII[k] != nSpins {
+			t.Errorf("Expected only non-ASCII values for %s, got %d out of %d", k, nonASCII[k], nSpins)
+		}
+	}
+
+	if nonASCII["beta"] == 0 {
+		t.Errorf("Expected some non-ASCII values for beta")
+	}
+
+	cfg, err = config.LoadConfigFromYaml([]byte("fields:\n  - name: alpha\n    locale: xx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewGenerator(cfg, flds, 1, WithCustomTemplate(template))
+	if err == nil {
+		t.Errorf("Expected an error for an unknown locale")
+	}
+}
+
+func Test_FieldUnicodeSaltWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+		{Name: "gamma", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}","beta":"{{.beta}}","gamma":"{{.gamma}}"}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    unicode_salt: 0.5
+  - name: beta
+    unicode_salt: 1`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	salted := map[string]int{}
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		for k, v := range m {
+			for _, r := range v {
+				if r > 127 {
+					salted[k]++
+					break
+				}
+			}
+		}
+	}
+
+	if salted["alpha"] == 0 || salted["alpha"] == nSpins {
+		t.Errorf("Expected some salted values for alpha, got %d out of %d", salted["alpha"], nSpins)
+	}
+
+	if salted["beta"] != nSpins {
+		t.Errorf("Expected only salted values for beta, got %d out of %d", salted["beta"], nSpins)
+	}
+
+	if salted["gamma"] != 0 {
+		t.Errorf("Expected no salted values for gamma, got %d out of %d", salted["gamma"], nSpins)
+	}
+}
+
+func Test_FieldEscapeWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "message", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"message":"{{.message | escape "json"}}"}`)
+	configYaml := []byte(`fields:
+  - name: message
+    enum: ["a \"quoted\" message\nwith a \\ backslash"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 1)
+
+	var buf bytes.Buffer
+	if err := g.Emit(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[string](t, buf.Bytes())
+	if expected := "a \"quoted\" message\nwith a \\ backslash"; m["message"] != expected {
+		t.Errorf("Expected %q, got %q", expected, m["message"])
+	}
+}
+
+func Test_FieldQuantilesWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeLong},
+		{Name: "beta", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"alpha":{{.alpha}},"beta":{{.beta}}}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    quantiles:
+      p50: 100
+      p90: 300
+      p99: 1000
+  - name: beta
+    range:
+      min: 10
+      max: 500
+    quantiles:
+      p25: 50
+      p75: 200`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 20000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	var alpha, beta []float64
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		buf.Reset()
+
+		alpha = append(alpha, m["alpha"])
+		beta = append(beta, m["beta"])
+	}
+
+	sort.Float64s(alpha)
+	sort.Float64s(beta)
+
+	// the fraction of the values below the target value of a quantile, steadier than the value at the quantile
+	// where the distribution is sparse, like in the tail
+	below := func(values []float64, value float64) float64 {
+		return float64(sort.SearchFloat64s(values, value)) / float64(len(values))
+	}
+
+	for q, expected := range map[float64]float64{0.5: 100, 0.9: 300, 0.99: 1000} {
+		if got := below(alpha, expected); math.Abs(got-q) > 0.02 {
+			t.Errorf("Expected %g of alpha values below %g, got %g", q, expected, got)
+		}
+	}
+
+	for q, expected := range map[float64]float64{0.25: 50, 0.75: 200} {
+		if got := below(beta, expected); math.Abs(got-q) > 0.02 {
+			t.Errorf("Expected %g of beta values below %g, got %g", q, expected, got)
+		}
+	}
+
+	if alpha[0] < 0 || beta[0] < 10 || beta[len(beta)-1] > 500 {
+		t.Errorf("Expected values within bounds, got alpha from %g, beta from %g to %g", alpha[0], beta[0], beta[len(beta)-1])
+	}
+}
+
+func Test_FieldSamplesWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+		{Name: "gamma", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}","beta":{{.beta}},"gamma":{{.gamma}}}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    samples: ["GET", "GET", "GET", "POST"]
+  - name: beta
+    samples: ["10", "20"]
+  - name: gamma
+    samples: ["10", "20"]
+    smoothing: 0.1`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	methods := map[string]int{}
+	var smoothed int
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		methods[m["alpha"].(string)]++
+
+		if beta := m["beta"].(float64); beta != 10 && beta != 20 {
+			t.Errorf("Expected beta one of the samples, got %v", beta)
+		}
+
+		gamma := m["gamma"].(float64)
+		if gamma < 0 || gamma > 30 {
+			t.Errorf("Expected gamma around the samples, got %v", gamma)
+		}
+
+		if gamma != 10 && gamma != 20 {
+			smoothed++
+		}
+	}
+
+	if len(methods) != 2 || methods["GET"] < nSpins*6/10 || methods["GET"] > nSpins*9/10 {
+		t.Errorf("Expected about 75%% of GET, got %v", methods)
+	}
+
+	if smoothed < nSpins*9/10 {
+		t.Errorf("Expected smoothed gamma values, got %d out of %d", smoothed, nSpins)
+	}
+}
+
+func Test_FieldTimeOfDayWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "timestamp", Type: FieldTypeDate},
+		{Name: "latency", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"timestamp":"{{.timestamp}}","latency":{{.latency}}}`)
+	configYaml := []byte(`fields:
+  - name: timestamp
+    range:
+      from: "2023-01-01T00:00:00.000000+00:00"
+      to: "2023-01-03T00:00:00.000000+00:00"
+  - name: latency
+    range:
+      min: 10
+      max: 20
+    time_of_day:
+      timestamp: timestamp
+      windows:
+        - from: "09:00"
+          to: "17:00"
+          field:
+            range:
+              min: 100
+              max: 200
+        - from: "22:00"
+          to: "02:30"
+          field:
+            range:
+              min: 1000
+              max: 2000`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	windows := map[int64]int{}
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		timestamp, err := time.Parse(FieldTypeTimeLayout, m["timestamp"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		minute := timestamp.UTC().Hour()*60 + timestamp.UTC().Minute()
+		latency := int64(m["latency"].(float64))
+
+		var minValue int64
+		switch {
+		case minute >= 9*60 && minute < 17*60:
+			minValue = 100
+		case minute >= 22*60 || minute < 2*60+30:
+			minValue = 1000
+		default:
+			minValue = 10
+		}
+
+		if latency < minValue || latency > 2*minValue {
+			t.Errorf("Expected latency between %d and %d at %s, got %d", minValue, 2*minValue, timestamp, latency)
+		}
+
+		windows[minValue]++
+	}
+
+	if len(windows) != 3 {
+		t.Errorf("Expected events in all the windows, got %v", windows)
+	}
+}
+
+func Test_CorrelationsWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "host.ip", Type: FieldTypeIP},
+		{Name: "host.mac", Type: FieldTypeKeyword},
+		{Name: "user", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"host":{"name":"{{.host.name}}","ip":"{{.host.ip}}","mac":"{{.host.mac}}"},"user":"{{.user}}"}`)
+	configYaml := []byte(`correlations:
+  - name: host
+    fields: [host.name, host.ip, host.mac]
+    cardinality: 5`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	hosts := map[string]string{}
+	users := map[string]struct{}{}
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		host := m["host"].(map[string]any)
+		entity := host["ip"].(string) + " " + host["mac"].(string)
+		if previous, ok := hosts[host["name"].(string)]; ok && previous != entity {
+			t.Errorf("Expected host %s to always be %s, got %s", host["name"], previous, entity)
+		}
+
+		hosts[host["name"].(string)] = entity
+		users[m["user"].(string)] = struct{}{}
+	}
+
+	if len(hosts) != 5 {
+		t.Errorf("Expected 5 hosts, got %d", len(hosts))
+	}
+
+	if len(users) <= 5 {
+		t.Errorf("Expected the fields not correlated to be independent, got %d users", len(users))
+	}
+}
+
+func Test_SessionsWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "session.id", Type: FieldTypeKeyword},
+		{Name: "source.ip", Type: FieldTypeIP},
+		{Name: "user.name", Type: FieldTypeKeyword},
+		{Name: "message", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"session":{"id":"{{.session.id}}"},"source":{"ip":"{{.source.ip}}"},"user":{"name":"{{.user.name}}"},"message":"{{.message}}"}`)
+	configYaml := []byte(`sessions:
+  - name: user
+    fields: [session.id, source.ip, user.name]
+    length:
+      min: 3
+      max: 5`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var lengths []int
+	var previous string
+	messages := map[string]struct{}{}
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		session := m["session"].(map[string]any)["id"].(string) + " " + m["source"].(map[string]any)["ip"].(string) + " " + m["user"].(map[string]any)["name"].(string)
+		if session != previous {
+			lengths = append(lengths, 0)
+			previous = session
+		}
+
+		lengths[len(lengths)-1]++
+		messages[m["message"].(string)] = struct{}{}
+	}
+
+	// the last session can be cut by the end of the generation
+	for i, length := range lengths {
+		if length > 5 || length < 3 && i < len(lengths)-1 {
+			t.Errorf("Expected sessions of 3 to 5 events, got %d", length)
+		}
+	}
+
+	if len(lengths) < nSpins/5 {
+		t.Errorf("Expected at least %d sessions, got %d", nSpins/5, len(lengths))
+	}
+
+	if len(messages) <= len(lengths) {
+		t.Errorf("Expected the fields not in the session to be independent, got %d messages", len(messages))
+	}
+}
+
+func Test_TenantsWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "data_stream.namespace", Type: FieldTypeKeyword},
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "count", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"namespace":{{.data_stream.namespace}},"host":"{{.host}}","count":{{.count}}}`)
+	configYaml := []byte(`fields:
+  - name: count
+    counter: true
+tenants:
+  - name: acme
+    weight: 3
+    fields:
+      - name: data_stream.namespace
+        value: acme
+      - name: host
+        cardinality: 2
+  - name: globex
+    fields:
+      - name: data_stream.namespace
+        value: globex
+      - name: host
+        cardinality: 5`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := map[string]map[string]struct{}{"acme": {}, "globex": {}}
+	events := map[string]int{}
+	emit := func(g Generator, n int) {
+		counts := map[string]float64{}
+		var buf bytes.Buffer
+		for i := 0; i < n; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[any](t, buf.Bytes())
+			buf.Reset()
+
+			namespace := m["namespace"].(string)
+			hostsOfTenant, ok := hosts[namespace]
+			if !ok {
+				t.Fatalf("Expected a tenant namespace, got %s", namespace)
+			}
+
+			hostsOfTenant[m["host"].(string)] = struct{}{}
+			events[namespace]++
+
+			count := m["count"].(float64)
+			if previous, ok := counts[namespace]; ok && count < previous {
+				t.Errorf("Expected the counter of %s not to decrease from %v, got %v", namespace, previous, count)
+			}
+
+			counts[namespace] = count
+		}
+	}
+
+	nSpins := 2000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+	emit(g, nSpins/2)
+
+	var state bytes.Buffer
+	if err := g.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err = NewGenerator(cfg, flds, uint64(nSpins), WithCustomTemplate(template), WithRandSeed(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	emit(g, nSpins/2)
+
+	if len(hosts["acme"]) != 2 || len(hosts["globex"]) != 5 {
+		t.Errorf("Expected 2 hosts for acme and 5 for globex, got %v", hosts)
+	}
+
+	for host := range hosts["acme"] {
+		if _, ok := hosts["globex"][host]; ok {
+			t.Errorf("Expected the tenants to have their own hosts, got %s for both", host)
+		}
+	}
+
+	if events["acme"] < 2*events["globex"] {
+		t.Errorf("Expected about 3 acme events for each globex one, got %v", events)
+	}
+}
+
+func Test_FieldGeoPointBBoxWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "location", Type: FieldTypeGeoPoint},
+		{Name: "pin", Type: FieldTypeGeoPoint},
+		{Name: "hash", Type: FieldTypeGeoPoint},
+	}
+
+	template := []byte(`{"location":{{.location}},"pin":"{{.pin}}","hash":"{{.hash}}"}`)
+	configYaml := []byte(`fields:
+  - name: location
+    geo_format: object
+    geo_bbox:
+      top_left: {lat: 48.9, lon: 2.2}
+      bottom_right: {lat: 48.8, lon: 2.5}
+  - name: pin
+    precision: 2
+    geo_bbox:
+      top_left: {lat: 10, lon: 170}
+      bottom_right: {lat: -10, lon: -170}
+  - name: hash
+    geo_format: geohash
+    geo_bbox:
+      top_left: {lat: 48.9, lon: 2.2}
+      bottom_right: {lat: 48.8, lon: 2.5}`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		location := m["location"].(map[string]any)
+		if lat := location["lat"].(float64); lat < 48.8 || lat > 48.9 {
+			t.Errorf("Expected the latitude of the location in the bbox, got %v", lat)
+		}
+
+		if lon := location["lon"].(float64); lon < 2.2 || lon > 2.5 {
+			t.Errorf("Expected the longitude of the location in the bbox, got %v", lon)
+		}
+
+		latLon := strings.Split(m["pin"].(string), ",")
+		if len(latLon) != 2 || len(latLon[0]) > len("-10.00") || len(latLon[1]) > len("-180.00") {
+			t.Fatalf("Expected a lat,lon pin with 2 decimals, got %s", m["pin"])
+		}
+
+		lat, err := strconv.ParseFloat(latLon[0], 64)
+		if err != nil || lat < -10 || lat > 10 {
+			t.Errorf("Expected the latitude of the pin in the bbox, got %s", latLon[0])
+		}
+
+		lon, err := strconv.ParseFloat(latLon[1], 64)
+		if err != nil || (lon < 170 && lon > -170) {
+			t.Errorf("Expected the longitude of the pin across the antimeridian, got %s", latLon[1])
+		}
+
+		if hash := m["hash"].(string); len(hash) != 12 || !strings.HasPrefix(hash, "u09") {
+			t.Errorf("Expected a geohash in Paris, got %s", hash)
+		}
+	}
+}
+
+func Test_FieldGeoTrajectoryWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "@timestamp", Type: FieldTypeDate},
+		{Name: "location", Type: FieldTypeGeoPoint},
+	}
+
+	template := []byte(`{"host":"{{.host}}","@timestamp":"{{.@timestamp}}","location":{{.location}}}`)
+	configYaml := []byte(`fields:
+  - name: host
+    enum: [a, b, c]
+  - name: "@timestamp"
+    range:
+      from: "2023-01-01T00:00:00+00:00"
+    inter_arrival:
+      distribution: exponential
+      mean: 30s
+  - name: location
+    geo_format: object
+    geo_bbox:
+      top_left: {lat: 48.9, lon: 2.2}
+      bottom_right: {lat: 48.8, lon: 2.5}
+    geo_trajectory:
+      max_speed: 50
+      entity: host
+      timestamp: "@timestamp"`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	type point struct {
+		lat, lon float64
+		t        time.Time
+	}
+
+	last := make(map[string]point)
+	var moved int
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		ts, err := time.Parse(FieldTypeTimeLayout, m["@timestamp"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		location := m["location"].(map[string]any)
+		p := point{lat: location["lat"].(float64), lon: location["lon"].(float64), t: ts}
+		if p.lat < 48.8 || p.lat > 48.9 || p.lon < 2.2 || p.lon > 2.5 {
+			t.Errorf("Expected the location in the bbox, got %v,%v", p.lat, p.lon)
+		}
+
+		host := m["host"].(string)
+		if previous, ok := last[host]; ok {
+			distance := haversineKm(previous.lat, previous.lon, p.lat, p.lon)
+			if maxDistance := 50*p.t.Sub(previous.t).Hours() + 0.001; distance > maxDistance {
+				t.Errorf("Expected host %s to move at most %v km, got %v km", host, maxDistance, distance)
+			}
+
+			if distance > 0 {
+				moved++
+			}
+		}
+
+		last[host] = p
+	}
+
+	if moved < nSpins/2 {
+		t.Errorf("Expected the hosts to move, moved %d times", moved)
+	}
+}
+
+func Test_FieldEnumWeightsWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "method", Type: FieldTypeKeyword},
+		{Name: "status", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"method":"{{.method}}","status":{{.status}}}`)
+	configYaml := []byte(`fields:
+  - name: method
+    enum:
+      - value: GET
+        weight: 70
+      - value: POST
+        weight: 25
+      - PUT
+      - {value: DELETE, weight: 4}
+  - name: status
+    enum:
+      - {value: 200, weight: 9}
+      - 500`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 10000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	methods := map[string]int{}
+	statuses := map[float64]int{}
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		methods[m["method"].(string)]++
+		statuses[m["status"].(float64)]++
+	}
+
+	expected := map[string]float64{"GET": 0.7, "POST": 0.25, "PUT": 0.01, "DELETE": 0.04}
+	for method, share := range expected {
+		if got := float64(methods[method]) / float64(nSpins); math.Abs(got-share) > 0.02 {
+			t.Errorf("Expected a share of %v for %s, got %v", share, method, got)
+		}
+	}
+
+	if len(methods) != len(expected) {
+		t.Errorf("Expected only the enum values, got %v", methods)
+	}
+
+	if got := float64(statuses[200]) / float64(nSpins); math.Abs(got-0.9) > 0.02 {
+		t.Errorf("Expected a share of 0.9 for 200, got %v", got)
+	}
+}
+
+func Test_FieldStrictCardinalityWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "ip", Type: FieldTypeIP},
+		{Name: "port", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"ip":"{{.ip}}","port":{{.port}}}`)
+	configYaml := []byte(`fields:
+  - name: ip
+    cardinality: 50
+    strict_cardinality: true
+  - name: port
+    range:
+      min: 1
+      max: 60
+    cardinality: 50
+    strict_cardinality: true`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 200
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	ips := make(map[string]struct{})
+	ports := make(map[float64]struct{})
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		ips[m["ip"].(string)] = struct{}{}
+		ports[m["port"].(float64)] = struct{}{}
+	}
+
+	if len(ips) != 50 {
+		t.Errorf("expected 50 distinct ips, got %d", len(ips))
+	}
+
+	if len(ports) != 50 {
+		t.Errorf("expected 50 distinct ports, got %d", len(ports))
+	}
+
+	// not enough possible values
+	configYaml = []byte(`fields:
+  - name: port
+    range:
+      min: 1
+      max: 5
+    cardinality: 10
+    strict_cardinality: true`)
+
+	cfg, err = config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g = makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var failed bool
+	for i := 0; i < nSpins && !failed; i++ {
+		failed = g.Emit(&buf) != nil
+		buf.Reset()
+	}
+
+	if !failed {
+		t.Error("expected error with fewer possible values than cardinality")
+	}
+
+	// not enough events
+	configYaml = []byte(`fields:
+  - name: ip
+    cardinality: 50
+    strict_cardinality: true`)
+
+	cfg, err = config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g = makeGeneratorWithCustomTemplate(t, cfg, flds, template, 20)
+	if err := g.Emit(&buf); err == nil {
+		t.Error("expected error with fewer events than cardinality")
+	}
+}
+
+func Test_FieldCardinalityZipfWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "service", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"host":"{{.host}}","service":"{{.service}}"}`)
+	configYaml := []byte(`fields:
+  - name: host
+    cardinality: 100
+    cardinality_distribution: zipf
+  - name: service
+    cardinality: 1
+    cardinality_distribution: zipf`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 2000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	hosts := make(map[string]int)
+	services := make(map[string]struct{})
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		hosts[m["host"]]++
+		services[m["service"]] = struct{}{}
+	}
+
+	if len(hosts) > 100 {
+		t.Errorf("expected at most 100 distinct hosts, got %d", len(hosts))
+	}
+
+	if len(services) != 1 {
+		t.Errorf("expected 1 distinct service, got %d", len(services))
+	}
+
+	// with a uniform distribution the most frequent host would be about 20 times
+	var top int
+	for _, count := range hosts {
+		if count > top {
+			top = count
+		}
+	}
+
+	if top < 200 {
+		t.Errorf("expected the most frequent host to dominate, got %d times out of %d", top, nSpins)
+	}
+}
+
+func Test_FieldExcludeValuesWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "domain", Type: FieldTypeKeyword},
+		{Name: "port", Type: FieldTypeLong},
+		{Name: "ratio", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"domain":"{{.domain}}","port":{{.port}},"ratio":{{.ratio}}}`)
+	configYaml := []byte(`fields:
+  - name: domain
+    enum: [example.com, customer.com, example.org]
+    cardinality: 2
+    exclude_values: [customer.com]
+  - name: port
+    range:
+      min: 0
+      max: 3
+    exclude_values: [0, 1]
+  - name: ratio
+    range:
+      min: 1
+      max: 2
+    precision: 1
+    exclude_values: [1.5, 1.50]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 200
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	domains := make(map[string]struct{})
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		domains[m["domain"].(string)] = struct{}{}
+
+		if port := m["port"].(float64); port < 2 {
+			t.Errorf("expected port not in exclude_values, got %v", port)
+		}
+
+		if ratio := m["ratio"].(float64); ratio == 1.5 {
+			t.Errorf("expected ratio not in exclude_values, got %v", ratio)
+		}
+	}
+
+	if _, ok := domains["customer.com"]; ok || len(domains) != 2 {
+		t.Errorf("expected example.com and example.org, got %v", domains)
+	}
+
+	// all the values excluded
+	configYaml = []byte(`fields:
+  - name: domain
+    enum: [customer.com]
+    exclude_values: [customer.com]`)
+
+	cfg, err = config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g = makeGeneratorWithCustomTemplate(t, cfg, flds, []byte(`{"domain":"{{.domain}}"}`), uint64(nSpins))
+	if err := g.Emit(&buf); err == nil {
+		t.Error("expected error with all the values excluded")
+	}
+}
+
+func Test_FieldAffixesWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "instance", Type: FieldTypeKeyword},
+		{Name: "hash", Type: FieldTypeKeyword},
+		{Name: "port", Type: FieldTypeLong},
+		{Name: "tags", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"instance":"{{.instance}}","hash":"{{.hash}}","port":"{{.port}}","tags":{{.tags}}}`)
+	configYaml := []byte(`fields:
+  - name: instance
+    enum: [0abc]
+    format: 'i-%s'
+  - name: hash
+    enum: [deadbeef]
+    prefix: 'sha256:'
+    suffix: '!'
+  - name: port
+    enum: [80]
+    format: '%05s'
+  - name: tags
+    enum: [a]
+    prefix: 'tag-'
+    array_length:
+      min: 2
+      max: 2`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 1)
+
+	var buf bytes.Buffer
+	if err := g.Emit(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[any](t, buf.Bytes())
+	if m["instance"] != "i-0abc" {
+		t.Errorf("expected instance i-0abc, got %v", m["instance"])
+	}
+
+	if m["hash"] != "sha256:deadbeef!" {
+		t.Errorf("expected hash sha256:deadbeef!, got %v", m["hash"])
+	}
+
+	if m["port"] != "00080" {
+		t.Errorf("expected port 00080, got %v", m["port"])
+	}
+
+	if tags, ok := m["tags"].([]any); !ok || len(tags) != 2 || tags[0] != "tag-a" || tags[1] != "tag-a" {
+		t.Errorf("expected tags [tag-a tag-a], got %v", m["tags"])
+	}
+}
+
+func Test_FieldUserAgentErrorsWithCustomTemplate(t *testing.T) {
+	testCases := []struct {
+		scenario string
+		config   string
+	}{
+		{scenario: "unknown generator", config: "generator: unknown"},
+		{scenario: "unknown browser", config: "generator: user_agent\n    user_agent:\n      browsers: {lynx: 1}"},
+		{scenario: "unknown os", config: "generator: user_agent\n    user_agent:\n      os: {beos: 1}"},
+		{scenario: "no browser on the os", config: "generator: user_agent\n    user_agent:\n      browsers: {safari: 1}\n      os: {linux: 1}"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := config.LoadConfigFromYaml([]byte("fields:\n  - name: ua\n    " + testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = NewGenerator(cfg, []Field{{Name: "ua", Type: FieldTypeKeyword}}, 1, WithCustomTemplate([]byte(`{{.ua}}`)))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func Test_FieldCIDRErrorsWithCustomTemplate(t *testing.T) {
+	testCases := []struct {
+		scenario  string
+		fieldType string
+		config    string
+	}{
+		{scenario: "not an ip field", fieldType: FieldTypeKeyword, config: "cidr: [10.0.0.0/8]"},
+		{scenario: "not a network", fieldType: FieldTypeIP, config: "cidr: [10.0.0.256/8]"},
+		{scenario: "only reserved", fieldType: FieldTypeIP, config: "cidr: [224.0.0.0/3]\n    exclude_reserved: true"},
+		{scenario: "no ipv6 network", fieldType: FieldTypeIP, config: "cidr: [10.0.0.0/8]\n    ipv6_fraction: 0.1"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := config.LoadConfigFromYaml([]byte("fields:\n  - name: ip\n    " + testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = NewGenerator(cfg, []Field{{Name: "ip", Type: testCase.fieldType}}, 1, WithCustomTemplate([]byte(`{{.ip}}`)))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func Test_FieldUserAgentWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "user_agent.original", Type: FieldTypeKeyword, Example: "Mozilla/5.0"},
+		{Name: "firefox", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"ua":"{{.user_agent.original}}","firefox":"{{.firefox}}"}`)
+	configYaml := []byte(`fields:
+  - name: firefox
+    generator: user_agent
+    user_agent:
+      browsers: {firefox: 1, safari: 1}
+      os: {linux: 1}`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 100)
+
+	var buf bytes.Buffer
+	userAgents := make(map[any]struct{})
+	for i := 0; i < 100; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		if ua, ok := m["ua"].(string); !ok || !strings.HasPrefix(ua, "Mozilla/5.0 (") {
+			t.Errorf("expected a user agent, got %v", m["ua"])
+		}
+
+		if ua, ok := m["firefox"].(string); !ok || !strings.Contains(ua, "Linux") || !strings.Contains(ua, " Firefox/") {
+			t.Errorf("expected a Firefox on Linux user agent, got %v", m["firefox"])
+		}
+
+		userAgents[m["ua"]] = struct{}{}
+	}
+
+	if len(userAgents) < 10 {
+		t.Errorf("expected at least 10 different user agents, got %d", len(userAgents))
+	}
+}
+
+func Test_FieldURLWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "url.original", Type: FieldTypeKeyword},
+		{Name: "url.path", Type: FieldTypeKeyword},
+		{Name: "url.query", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"original":"{{.url.original}}","path":"{{.url.path}}","query":"{{.url.query}}"}`)
+	configYaml := []byte(`fields:
+  - name: url.original
+    generator: url
+    url:
+      schemes: [https]
+      domains: [shop.example.com]
+      path_depth: {min: 2, max: 2}
+      query_params: {min: 1, max: 3}
+  - name: url.path
+    generator: url
+  - name: url.query
+    generator: url`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 100)
+
+	var buf bytes.Buffer
+	for i := 0; i < 100; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		u, err := url.Parse(m["original"])
+		if err != nil {
+			t.Fatalf("expected a valid URL, got %s: %v", m["original"], err)
+		}
+
+		if u.Scheme != "https" || u.Host != "shop.example.com" || strings.Count(u.Path, "/") != 2 {
+			t.Errorf("expected an https URL of shop.example.com with 2 path segments, got %s", m["original"])
+		}
+
+		if query, err := url.ParseQuery(u.RawQuery); err != nil || len(query) < 1 || len(query) > 3 {
+			t.Errorf("expected between 1 and 3 query params, got %s", u.RawQuery)
+		}
+
+		if !strings.HasPrefix(m["path"], "/") || strings.Contains(m["path"], "?") || strings.Contains(m["path"], "://") {
+			t.Errorf("expected a path, got %s", m["path"])
+		}
+
+		if _, err := url.ParseQuery(m["query"]); err != nil || strings.HasPrefix(m["query"], "?") {
+			t.Errorf("expected a query, got %s", m["query"])
+		}
+	}
+}
+
+func Test_FieldCIDRWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "source.ip", Type: FieldTypeIP},
+		{Name: "destination.ip", Type: FieldTypeIP},
+	}
+
+	template := []byte(`{"source":"{{.source.ip}}","destination":"{{.destination.ip}}"}`)
+	configYaml := []byte(`fields:
+  - name: source.ip
+    cidr: [{value: 10.0.0.0/8, weight: 3}, 192.168.0.0/16]
+    exclude_reserved: true
+  - name: destination.ip
+    exclude_reserved: true
+    ipv6_fraction: 0.5`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 1000)
+
+	_, internal, _ := net.ParseCIDR("10.0.0.0/8")
+	_, home, _ := net.ParseCIDR("192.168.0.0/16")
+
+	var buf bytes.Buffer
+	var internalIPs, ipv6IPs int
+	for i := 0; i < 1000; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		source := net.ParseIP(m["source"])
+		if internal.Contains(source) {
+			internalIPs++
+		} else if !home.Contains(source) {
+			t.Errorf("expected an IP of the networks, got %s", m["source"])
+		}
+
+		destination := net.ParseIP(m["destination"])
+		if destination == nil || !destination.IsGlobalUnicast() || destination.IsPrivate() {
+			t.Errorf("expected a public IP, got %s", m["destination"])
+		}
+
+		if destination.To4() == nil {
+			ipv6IPs++
+		}
+	}
+
+	if internalIPs < 700 || internalIPs > 800 {
+		t.Errorf("expected about 750 IPs of 10.0.0.0/8, got %d", internalIPs)
+	}
+
+	if ipv6IPs < 450 || ipv6IPs > 550 {
+		t.Errorf("expected about 500 IPv6 IPs, got %d", ipv6IPs)
+	}
+}
+
+func Test_FieldSourceForWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "message", Type: FieldTypeKeyword},
+		{Name: "status", Type: FieldTypeLong},
+		{Name: "path", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"message":"{{.message}}","status":{{$.status}},"path":"{{$.path}}"}`)
+	configYaml := []byte(`fields:
+  - name: message
+    enum: ["GET"]
+    source_for:
+      - field: status
+        format: 'status=%s'
+      - field: path
+  - name: status
+    range:
+      min: 200
+      max: 599
+  - name: path
+    enum: ["/a", "/b"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 10)
+
+	var buf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		expected := fmt.Sprintf("GET status=%v %v", m["status"], m["path"])
+		if m["message"] != expected {
+			t.Errorf("expected message %s, got %v", expected, m["message"])
+		}
+	}
+}
+
+func Test_FieldNormalizeWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "city", Type: FieldTypeKeyword},
+		{Name: "code", Type: FieldTypeKeyword},
+		{Name: "tags", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"city":"{{.city}}","code":"{{.code}}","tags":{{.tags}}}`)
+	configYaml := []byte(`fields:
+  - name: city
+    enum: ["São Paulo"]
+    normalize:
+      case: lower
+      replace_spaces: _
+      strip_diacritics: true
+  - name: code
+    enum: [ab]
+    prefix: 'x-'
+    normalize:
+      case: upper
+  - name: tags
+    enum: ["Crème Brûlée"]
+    normalize:
+      strip_diacritics: true
+    array_length:
+      min: 2
+      max: 2`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 1)
+
+	var buf bytes.Buffer
+	if err := g.Emit(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[any](t, buf.Bytes())
+	if m["city"] != "sao_paulo" {
+		t.Errorf("expected city sao_paulo, got %v", m["city"])
+	}
+
+	if m["code"] != "X-AB" {
+		t.Errorf("expected code X-AB, got %v", m["code"])
+	}
+
+	if tags, ok := m["tags"].([]any); !ok || len(tags) != 2 || tags[0] != "Creme Brulee" || tags[1] != "Creme Brulee" {
+		t.Errorf("expected tags [Creme Brulee Creme Brulee], got %v", m["tags"])
+	}
+}
+
+func Test_FieldPatternWithCustomTemplate(t *testing.T) {
+	saveTimeState(t)
+
+	fld := Field{
+		Name: "@timestamp",
+		Type: FieldTypeDate,
+	}
+
+	template := []byte(`{"@timestamp":"{{.@timestamp}}"}`)
+	configYaml := []byte(`fields:
+  - name: "@timestamp"
+    range:
+      from: "2023-01-02T00:00:00.000000+00:00"
+      to: "2023-01-09T00:00:00.000000+00:00"
+    pattern:
+      peak_hours: [12]
+      peak_factor: 4
+      weekend_factor: 0.5`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 16200
+	g := makeGeneratorWithCustomTemplate(t, cfg, []Field{fld}, template, uint64(nSpins))
+
+	// 2023-01-02 is a Monday: a weekday has 23 hours at volume 1 and 1 at 4, a weekend day half of it
+	hours := map[time.Weekday]map[int]int{}
+	var previous time.Time
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		ts, err := time.Parse(FieldTypeTimeLayout, m["@timestamp"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ts.Before(previous) {
+			t.Fatalf("Expected timestamps in order, got %s after %s", ts, previous)
+		}
+
+		previous = ts
+		ts = ts.UTC()
+		if hours[ts.Weekday()] == nil {
+			hours[ts.Weekday()] = map[int]int{}
+		}
+
+		hours[ts.Weekday()][ts.Hour()]++
+	}
+
+	if previous.After(time.Date(2023, 1, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected timestamps within the range, got %s", previous)
+	}
+
+	// 16200 events over 162 volume units, 100 per hour at volume 1
+	for _, expected := range []struct {
+		weekday time.Weekday
+		hour    int
+		events  int
+	}{
+		{time.Monday, 3, 100},
+		{time.Monday, 12, 400},
+		{time.Wednesday, 12, 400},
+		{time.Saturday, 3, 50},
+		{time.Sunday, 12, 200},
+	} {
+		if got := hours[expected.weekday][expected.hour]; got < expected.events-2 || got > expected.events+2 {
+			t.Errorf("Expected %d events on %s at %d, got %d", expected.events, expected.weekday, expected.hour, got)
+		}
+	}
+}
+
+func Test_FieldCounterRateWithCustomTemplate(t *testing.T) {
+	fld := Field{
+		Name: "bytes",
+		Type: FieldTypeLong,
+	}
+
+	template := []byte(`{"bytes":"{{.bytes}}"}`)
+	configYaml := []byte(`fields:
+  - name: bytes
+    counter: true
+    counter_rate:
+      min: 100
+      max: 200
+    counter_reset:
+      strategy: after_n
+      reset_after_n: 10`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 50
+	g := makeGeneratorWithCustomTemplate(t, cfg, []Field{fld}, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	var previous int64
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		value, err := strconv.ParseInt(m[fld.Name], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i%10 == 0 {
+			if value != 0 {
+				t.Errorf("Expected the counter to reset to 0 at event %d, got %d", i, value)
+			}
+		} else if increment := value - previous; increment < 100 || increment > 200 {
+			t.Errorf("Expected an increment between 100 and 200 at event %d, got %d", i, increment)
+		}
+
+		previous = value
+	}
+}
+
+func Test_FieldHistogramWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "latency", Type: FieldTypeHistogram},
+		{Name: "sizes", Type: FieldTypeHistogram},
+	}
+
+	template := []byte(`{"latency":{{.latency}},"sizes":{{.sizes}}}`)
+	configYaml := []byte(`fields:
+  - name: latency
+    range:
+      min: 0
+      max: 100
+    distribution: normal
+    mean: 50
+    stddev: 10
+    histogram:
+      buckets: 20
+      observations: 500
+  - name: sizes`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[struct {
+			Values []float64 `json:"values"`
+			Counts []int64   `json:"counts"`
+		}](t, buf.Bytes())
+		buf.Reset()
+
+		for name, observations := range map[string]int64{"latency": 500, "sizes": 100} {
+			h := m[name]
+			if len(h.Values) == 0 || len(h.Values) != len(h.Counts) {
+				t.Fatalf("Expected as many values as counts in %s, got %v", name, h)
+			}
+
+			var total int64
+			for j, value := range h.Values {
+				if j > 0 && value <= h.Values[j-1] {
+					t.Errorf("Expected strictly increasing values in %s, got %v", name, h.Values)
+				}
+
+				if h.Counts[j] <= 0 {
+					t.Errorf("Expected positive counts in %s, got %v", name, h.Counts)
+				}
+
+				total += h.Counts[j]
+			}
+
+			if total != observations {
+				t.Errorf("Expected %d observations in %s, got %d", observations, name, total)
+			}
+		}
+
+		latency := m["latency"]
+		if len(latency.Values) > 20 || latency.Values[0] < 2.5 || latency.Values[len(latency.Values)-1] > 97.5 {
+			t.Errorf("Expected the latency buckets within the range, got %v", latency.Values)
+		}
+	}
+}
+
+func Test_FieldReuseWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "visitor", Type: FieldTypeKeyword},
+		{Name: "token", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"visitor":"{{.visitor}}","token":"{{.token}}"}`)
+	configYaml := []byte(`fields:
+  - name: visitor
+    reuse:
+      probability: 0.5
+      size: 10
+  - name: token
+    reuse:
+      probability: 1`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 200
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	var reused int
+	var firstToken string
+	visitors := make(map[string]struct{})
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		if _, ok := visitors[m["visitor"]]; ok {
+			reused++
+		}
+
+		visitors[m["visitor"]] = struct{}{}
+
+		if i == 0 {
+			firstToken = m["token"]
+		} else if m["token"] != firstToken {
+			t.Errorf("expected token %s to be always reused, got %s", firstToken, m["token"])
+		}
+	}
+
+	if reused < 60 || reused > 140 {
+		t.Errorf("expected about half of the visitors to be reused, got %d out of %d", reused, nSpins)
+	}
+}
+
+func Test_FieldNormalDistributionWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "latency", Type: FieldTypeLong},
+		{Name: "cpu", Type: FieldTypeDouble},
+		{Name: "level", Type: FieldTypeByte},
+	}
+
+	template := []byte(`{"latency":{{.latency}},"cpu":{{.cpu}},"level":{{.level}}}`)
+	configYaml := []byte(`fields:
+  - name: latency
+    distribution: normal
+    mean: 100
+    stddev: 10
+  - name: cpu
+    distribution: normal
+    mean: 0.5
+    stddev: 0.2
+    range:
+      min: 0.4
+      max: 0.6
+  - name: level
+    distribution: normal
+    mean: 200
+    stddev: 50`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 2000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	var sum, sumSquares float64
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		buf.Reset()
+
+		sum += m["latency"]
+		sumSquares += m["latency"] * m["latency"]
+
+		if m["cpu"] < 0.4 || m["cpu"] > 0.6 {
+			t.Errorf("expected cpu within the range, got %v", m["cpu"])
+		}
+
+		if m["level"] > 127 {
+			t.Errorf("expected level within the bounds of byte, got %v", m["level"])
+		}
+	}
+
+	mean := sum / float64(nSpins)
+	stddev := math.Sqrt(sumSquares/float64(nSpins) - mean*mean)
+	if mean < 98 || mean > 102 || stddev < 9 || stddev > 11 {
+		t.Errorf("expected latency with mean 100 and stddev 10, got mean %v and stddev %v", mean, stddev)
+	}
+}
+
+func Test_FieldInterArrivalWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "tick", Type: FieldTypeDate},
+		{Name: "arrival", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{"host":"{{.host}}","tick":"{{.tick}}","arrival":"{{.arrival}}"}`)
+	configYaml := []byte(`fields:
+  - name: host
+    enum: [a, b]
+  - name: tick
+    range:
+      from: "2023-01-01T00:00:00+00:00"
+    inter_arrival:
+      distribution: fixed
+      mean: 2s
+  - name: arrival
+    range:
+      from: "2023-01-01T00:00:00+00:00"
+    inter_arrival:
+      distribution: exponential
+      mean: 10s
+      entity: host`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := make(map[string]time.Time)
+	gaps := make(map[string]time.Duration)
+	counts := make(map[string]int)
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		tick, err := time.Parse(FieldTypeTimeLayout, m["tick"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := from.Add(time.Duration(i) * 2 * time.Second); !tick.Equal(expected) {
+			t.Errorf("expected tick %v, got %v", expected, tick)
+		}
+
+		arrival, err := time.Parse(FieldTypeTimeLayout, m["arrival"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		host := m["host"]
+		if previous, ok := last[host]; ok {
+			if arrival.Before(previous) {
+				t.Errorf("expected arrival after %v for %q, got %v", previous, host, arrival)
+			}
+
+			gaps[host] += arrival.Sub(previous)
+			counts[host]++
+		} else if !arrival.Equal(from) {
+			t.Errorf("expected the first arrival of %q at %v, got %v", host, from, arrival)
+		}
+
+		last[host] = arrival
+	}
+
+	for host, gap := range gaps {
+		if mean := gap / time.Duration(counts[host]); mean < 7*time.Second || mean > 13*time.Second {
+			t.Errorf("expected a mean time between the arrivals of %q of 10s, got %v", host, mean)
+		}
+	}
+}
+
+func Test_FieldOrderWithCustomTemplate(t *testing.T) {
+	saveTimeState(t)
+
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "timestamp", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{"host":"{{.host}}","@timestamp":"{{.timestamp}}"}`)
+	testCases := []struct {
+		scenario string
+		config   string
+		byHost   bool
+	}{
+		{
+			scenario: "strictly increasing",
+			config:   "fields:\n  - name: host\n    enum: [a, b]\n  - name: timestamp\n    order: strictly_increasing",
+			byHost:   false,
+		},
+		{
+			scenario: "increasing per entity",
+			config:   "fields:\n  - name: host\n    enum: [a, b]\n  - name: timestamp\n    order: increasing_per_entity\n    order_entity: host",
+			byHost:   true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := config.LoadConfigFromYaml([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nSpins := 256
+			g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+			last := make(map[string]time.Time)
+			var buf bytes.Buffer
+			for i := 0; i < nSpins; i++ {
+				if err := g.Emit(&buf); err != nil {
+					t.Fatal(err)
+				}
+
+				m := unmarshalJSONT[string](t, buf.Bytes())
+				buf.Reset()
+
+				ts, err := time.Parse(FieldTypeTimeLayout, m["@timestamp"])
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				key := ""
+				if testCase.byHost {
+					key = m["host"]
+				}
+
+				if previous, ok := last[key]; ok && !ts.After(previous) {
+					t.Errorf("Expected timestamp after %v for %q, got %v", previous, key, ts)
+				}
+
+				last[key] = ts
+			}
+		})
+	}
+}
+
+func Test_FieldHashWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "host.id", Type: FieldTypeKeyword},
+		{Name: "host.port", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"host":"{{.host.name}}","id":"{{.host.id}}","port":{{.host.port}}}`)
+	configYaml := []byte(`fields:
+  - name: host.name
+    enum: ["host-1", "host-2", "host-3"]
+  - name: host.id
+    hash_of: host.name
+  - name: host.port
+    hash_of: host.name
+    range:
+      min: 1024
+      max: 2048`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 256
+	values := make(map[string]map[string]any)
+	for _, randSeed := range []int64{1, 2} {
+		g, err := NewGenerator(cfg, flds, uint64(nSpins), WithCustomTemplate(template), WithRandSeed(randSeed))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		for i := 0; i < nSpins; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[any](t, buf.Bytes())
+			buf.Reset()
+
+			host := m["host"].(string)
+			if port := m["port"].(float64); port < 1024 || port > 2048 {
+				t.Errorf("Expected port in range, got %v", port)
+			}
+
+			if previous, ok := values[host]; ok && (previous["id"] != m["id"] || previous["port"] != m["port"]) {
+				t.Errorf("Expected the values of %s to be %v, got %v with seed %d", host, previous, m, randSeed)
+			}
+
+			values[host] = m
+		}
+	}
+
+	if len(values) != 3 || values["host-1"]["id"] == values["host-2"]["id"] {
+		t.Errorf("Expected different values for different entities, got %v", values)
+	}
+}
+
+func Test_FieldASNWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "source.ip", Type: FieldTypeIP},
+		{Name: "source.as.number", Type: FieldTypeLong},
+		{Name: "source.as.organization.name", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"ip":"{{.source.ip}}","number":{{.source.as.number}},"organization":"{{.source.as.organization.name}}"}`)
+	configYaml := []byte(`fields:
+  - name: source.ip
+    cidr: [8.8.8.0/24, 10.0.0.0/8]
+  - name: source.as.number
+    asn_of: source.ip
+  - name: source.as.organization.name
+    asn_of: source.ip`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	organizations := make(map[float64]string)
+	numbers := make(map[string]float64)
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		ip, number, organization := m["ip"].(string), m["number"].(float64), m["organization"].(string)
+		if strings.HasPrefix(ip, "8.8.8.") && (number != 15169 || organization != "GOOGLE") {
+			t.Errorf("Expected %s to belong to AS15169 GOOGLE, got AS%v %s", ip, number, organization)
+		}
+
+		if previous, ok := organizations[number]; ok && previous != organization {
+			t.Errorf("Expected AS%v to be %s, got %s", number, previous, organization)
+		}
+
+		// the addresses of the same /16 network belong to the same autonomous system
+		network := strings.Join(strings.Split(ip, ".")[:2], ".")
+		if previous, ok := numbers[network]; ok && previous != number {
+			t.Errorf("Expected the network %s to belong to AS%v, got AS%v for %s", network, previous, number, ip)
+		}
+
+		organizations[number] = organization
+		numbers[network] = number
+	}
+
+	if len(organizations) < 3 {
+		t.Errorf("Expected the networks to belong to several autonomous systems, got %v", organizations)
+	}
+}
+
+func Test_FieldTLSWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "@timestamp", Type: FieldTypeDate},
+		{Name: "tls.version", Type: FieldTypeKeyword},
+		{Name: "tls.cipher", Type: FieldTypeKeyword},
+		{Name: "tls.client.ja3", Type: FieldTypeKeyword},
+		{Name: "tls.client.server_name", Type: FieldTypeKeyword},
+		{Name: "tls.server.ja3s", Type: FieldTypeKeyword},
+		{Name: "tls.server.issuer", Type: FieldTypeKeyword},
+		{Name: "tls.server.not_before", Type: FieldTypeDate},
+		{Name: "tls.server.not_after", Type: FieldTypeDate},
+		{Name: "tls.server.hash.sha256", Type: FieldTypeKeyword},
+		{Name: "fingerprint", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"@timestamp":"{{.@timestamp}}","version":"{{.tls.version}}","cipher":"{{.tls.cipher}}","ja3":"{{.tls.client.ja3}}","server_name":"{{.tls.client.server_name}}","ja3s":"{{.tls.server.ja3s}}","issuer":"{{.tls.server.issuer}}","not_before":"{{.tls.server.not_before}}","not_after":"{{.tls.server.not_after}}","sha256":"{{.tls.server.hash.sha256}}","fingerprint":"{{.fingerprint}}"}`)
+	configYaml := []byte(`fields:
+  - name: "@timestamp"
+    period: -720h
+  - name: tls.version
+    generator: tls
+  - name: tls.cipher
+    generator: tls
+  - name: tls.client.ja3
+    generator: tls
+    tls:
+      clients: {chrome: 1, java: 1}
+  - name: tls.client.server_name
+    generator: tls
+    tls:
+      server_names: [a.example.com, b.example.com, c.example.com, d.example.com]
+  - name: tls.server.ja3s
+    generator: tls
+  - name: tls.server.issuer
+    generator: tls
+  - name: tls.server.not_before
+    generator: tls
+    tls:
+      timestamp: "@timestamp"
+  - name: tls.server.not_after
+    generator: tls
+  - name: tls.server.hash.sha256
+    generator: tls
+  - name: fingerprint
+    generator: tls
+    tls:
+      part: client.ja3`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	versions := make(map[string]map[string]struct{})
+	ja3s := make(map[string]string)
+	certificates := make(map[string]string)
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		if m["fingerprint"] != m["ja3"] {
+			t.Errorf("Expected the fields of the same part to be equal, got %s and %s", m["fingerprint"], m["ja3"])
+		}
+
+		// the java client doesn't support TLS 1.3, that has its own cipher suites
+		if isTLS13 := !strings.Contains(m["cipher"], "_WITH_"); isTLS13 != (m["version"] == "1.3") {
+			t.Errorf("Expected the cipher %s not to be negotiated with TLS %s", m["cipher"], m["version"])
+		}
+
+		key := m["server_name"] + " " + m["cipher"]
+		if previous, ok := ja3s[key]; ok && previous != m["ja3s"] {
+			t.Errorf("Expected the server %s to answer %s with the JA3S %s, got %s", m["server_name"], m["cipher"], previous, m["ja3s"])
+		}
+
+		timestamp, err := time.Parse(FieldTypeTimeLayout, m["@timestamp"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		notBefore, err := time.Parse(FieldTypeTimeLayout, m["not_before"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		notAfter, err := time.Parse(FieldTypeTimeLayout, m["not_after"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if timestamp.Before(notBefore) || !timestamp.Before(notAfter) {
+			t.Errorf("Expected the certificate to be valid at %s, got from %s to %s", timestamp, notBefore, notAfter)
+		}
+
+		// a certificate is issued to a single server
+		certificate := m["issuer"] + " " + m["not_before"]
+		if previous, ok := certificates[m["sha256"]]; ok && previous != m["server_name"]+" "+certificate {
+			t.Errorf("Expected the certificate %s to be %s, got %s", m["sha256"], previous, m["server_name"]+" "+certificate)
+		}
+
+		if _, ok := versions[m["ja3"]]; !ok {
+			versions[m["ja3"]] = make(map[string]struct{})
+		}
+
+		versions[m["ja3"]][m["version"]] = struct{}{}
+		ja3s[key] = m["ja3s"]
+		certificates[m["sha256"]] = m["server_name"] + " " + certificate
+	}
+
+	// the java client negotiates TLS 1.2 only, the chrome one TLS 1.3 with the servers supporting it
+	if _, ok := versions[tlsClients["java"].ja3()]["1.3"]; ok || len(versions[tlsClients["java"].ja3()]) != 1 {
+		t.Errorf("Expected the java client to negotiate TLS 1.2 only, got %v", versions[tlsClients["java"].ja3()])
+	}
+
+	if _, ok := versions[tlsClients["chrome"].ja3()]["1.3"]; !ok || len(versions) != 2 {
+		t.Errorf("Expected the chrome client to negotiate TLS 1.3, got %v", versions)
+	}
+}
+
+func Test_FieldCumulativeWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "delta", Type: FieldTypeDouble},
+		{Name: "total", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"total":{{.total}},"delta":{{.delta}}}`)
+	configYaml := []byte(`fields:
+  - name: delta
+    precision: 2
+    range:
+      min: 0
+      max: 10
+  - name: total
+    precision: 2
+    cumulative_of: delta`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1024
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var total float64
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		buf.Reset()
+
+		total = math.Round((total+m["delta"])*100) / 100
+		if total != m["total"] {
+			t.Errorf("Expected running total %v, got %v", total, m["total"])
+		}
+	}
+}
+
+func Test_HooksWithCustomTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeKeyword,
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}"}`)
+	t.Logf("with template: %s", string(template))
+
+	var before, after []uint64
+	g, err := NewGenerator(Config{}, []Field{fld}, 0, WithCustomTemplate(template),
+		WithBeforeEvent(func(event uint64) error {
+			before = append(before, event)
+			return nil
+		}),
+		WithAfterField(func(event uint64, field string, value any) (any, error) {
+			if _, ok := value.([]byte); !ok || field != "alpha" {
+				t.Errorf("unexpected value %v for field %s", value, field)
+			}
+
+			return "redacted", nil
+		}),
+		WithAfterEvent(func(event uint64, doc []byte) error {
+			after = append(after, event)
+			if string(doc) != `{"alpha":"redacted"}` {
+				t.Errorf("unexpected event %s", doc)
+			}
+
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < 3; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(before) != 3 || len(after) != 3 || before[2] != 2 || after[2] != 2 {
+		t.Errorf("Expected hooks called for events 0 to 2, got %v and %v", before, after)
+	}
+
+	hookErr := errors.New("stop")
+	g, err = NewGenerator(Config{}, []Field{fld}, 0, WithCustomTemplate(template), WithBeforeEvent(func(event uint64) error {
+		return hookErr
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Emit(&buf); !errors.Is(err, hookErr) {
+		t.Errorf("Expected hook error, got %v", err)
+	}
+}
+
+func Test_EventErrorsWithCustomTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeKeyword,
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}"}`)
+	t.Logf("with template: %s", string(template))
+
+	fieldErr := errors.New("odd event")
+	afterField := WithAfterField(func(event uint64, field string, value any) (any, error) {
+		if event%2 == 1 {
+			return nil, fieldErr
+		}
+
+		return value, nil
+	})
+
+	var failed []*EventError
+	g, err := NewGenerator(Config{}, []Field{fld}, 6, WithCustomTemplate(template), afterField,
+		WithEventErrorHandler(func(err *EventError) error {
+			failed = append(failed, err)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	var emitted int
+	for {
+		buf.Reset()
+		err := g.Emit(&buf)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		unmarshalJSONT[string](t, buf.Bytes())
+		emitted++
+	}
+
+	if emitted != 3 || len(failed) != 3 {
+		t.Fatalf("Expected 3 events emitted and 3 failed, got %d and %d", emitted, len(failed))
+	}
+
+	for i, eventErr := range failed {
+		if eventErr.Event != uint64(2*i+1) || eventErr.Field != "alpha" || !errors.Is(eventErr, fieldErr) {
+			t.Errorf("Unexpected event error %v", eventErr)
+		}
+	}
+
+	errs := make(chan *EventError, 1)
+	g, err = NewGenerator(Config{}, []Field{fld}, 2, WithCustomTemplate(template), afterField, WithEventErrorHandler(EventErrorsTo(errs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := g.Emit(&buf); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+	}
+
+	if eventErr := <-errs; eventErr.Event != 1 {
+		t.Errorf("Expected event 1 failed, got %v", eventErr)
+	}
+
+	stopErr := errors.New("stop")
+	g, err = NewGenerator(Config{}, []Field{fld}, 0, WithCustomTemplate(template), afterField,
+		WithEventErrorHandler(func(err *EventError) error {
+			return stopErr
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Emit(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Emit(&buf); !errors.Is(err, stopErr) {
+		t.Errorf("Expected handler error, got %v", err)
+	}
+}
+
+func Test_EmitBatchWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "sequence", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"host":"{{.host}}","sequence":{{.sequence}}}`)
+	configYaml := []byte(`fields:
+  - name: sequence
+    counter: true`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 5)
+
+	var buf bytes.Buffer
+	buf.WriteString("previous event\n")
+	events, err := g.EmitBatch(&buf, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+
+	var previous float64
+	for i, event := range events {
+		m := unmarshalJSONT[any](t, event)
+		sequence := m["sequence"].(float64)
+		if i > 0 && sequence < previous {
+			t.Errorf("Expected the events in order, got sequence %v after %v", sequence, previous)
+		}
+
+		previous = sequence
+	}
+
+	if !strings.HasPrefix(buf.String(), "previous event\n") || buf.Len() != len("previous event\n")+len(events[0])+len(events[1])+len(events[2]) {
+		t.Errorf("Expected the events rendered in the buffer one after the other, got %s", buf.String())
+	}
+
+	// the last events are less than asked for
+	buf.Reset()
+	events, err = g.EmitBatch(&buf, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Errorf("Expected the last 2 events, got %d", len(events))
+	}
+
+	if _, err := g.EmitBatch(&buf, 3); err != io.EOF {
+		t.Errorf("Expected io.EOF after totEvents, got %v", err)
+	}
+}
+
+func Test_EmitDocumentWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "host.cpu.pct", Type: FieldTypeDouble},
+		{Name: "event.sequence", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"host.name":"{{.host.name}}","host.cpu.pct":{{.host.cpu.pct}},"event.sequence":{{.event.sequence}}}`)
+	t.Logf("with template: %s", string(template))
+
+	g := makeGeneratorWithCustomTemplate(t, Config{}, flds, template, 1)
+
+	var buf bytes.Buffer
+	buf.WriteString("previous event\n")
+	document, err := g.EmitDocument(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(buf.String(), "previous event\n{") {
+		t.Errorf("Expected event rendered in the buffer, got %s", buf.String())
+	}
+
+	host, ok := document["host"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected nested host object, got %v", document)
+	}
+
+	if _, ok := host["name"].(string); !ok {
+		t.Errorf("Expected host.name string, got %T", host["name"])
+	}
+
+	cpu, ok := host["cpu"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected nested host.cpu object, got %v", host)
+	}
+
+	if _, ok := cpu["pct"].(float64); !ok {
+		t.Errorf("Expected host.cpu.pct float64, got %T", cpu["pct"])
+	}
+
+	if _, ok := document["event"].(map[string]any)["sequence"].(int64); !ok {
+		t.Errorf("Expected event.sequence int64, got %v", document["event"])
+	}
+
+	if _, err := g.EmitDocument(&buf); err != io.EOF {
+		t.Errorf("Expected io.EOF after totEvents, got %v", err)
+	}
+}
+
+func Test_CloneWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}","beta":{{.beta}}}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    enum: ["a", "b", "c", "d", "e", "f"]
+    cardinality: 3
+  - name: beta
+    counter: true`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	// clones with the same seed generate the same events, independently
+	clones := []Generator{g.Clone(1), g.Clone(1)}
+	outputs := make([]bytes.Buffer, len(clones))
+
+	var wg sync.WaitGroup
+	for i := range clones {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < nSpins; j++ {
+				if err := clones[i].Emit(&outputs[i]); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+
+			if err := clones[i].Emit(&outputs[i]); err != io.EOF {
+				t.Errorf("Expected io.EOF after totEvents, got %v", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if outputs[0].Len() == 0 || outputs[0].String() != outputs[1].String() {
+		t.Errorf("Expected the same events from clones with the same seed")
+	}
+}
+
+func Test_ReloadWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"alpha":"{{.alpha}}","beta":{{.beta}}}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    enum: ["before"]
+  - name: beta
+    counter: true`)
+	reloadedConfigYaml := []byte(`fields:
+  - name: alpha
+    enum: ["after"]
+  - name: beta
+    counter: true`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloadedCfg, err := config.LoadConfigFromYaml(reloadedConfigYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 0)
+
+	var previous float64
+	var buf bytes.Buffer
+	for i := 0; i < 20; i++ {
+		if i == 10 {
+			if err := g.Reload(reloadedCfg); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		expected := "before"
+		if i >= 10 {
+			expected = "after"
+		}
+
+		if m["alpha"] != expected {
+			t.Errorf("Expected %s at event %d, got %v", expected, i, m["alpha"])
+		}
+
+		// the counter keeps increasing across the reload
+		if m["beta"].(float64) < previous {
+			t.Errorf("Expected counter not reset by reload, got %v after %v", m["beta"], previous)
+		}
+
+		previous = m["beta"].(float64)
+	}
+}
+
+func Test_FieldReferenceWithCustomTemplate(t *testing.T) {
+	fld := Field{
+		Name: "timestamp",
+		Type: FieldTypeDate,
+	}
+
+	template := []byte(`{"@timestamp":"{{.timestamp}}","event.created":"{{$.timestamp}}"}`)
+	t.Logf("with template: %s", string(template))
+
+	g := makeGeneratorWithCustomTemplate(t, Config{}, []Field{fld}, template, 10)
+
+	var buf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		if len(m["@timestamp"]) == 0 {
+			t.Errorf("missing value for field %s", fld.Name)
+		}
+
+		if m["@timestamp"] != m["event.created"] {
+			t.Errorf("referenced value %s differs from rendered value %s", m["event.created"], m["@timestamp"])
+		}
+	}
+}
+
+func Test_FieldFormatWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "bytes", Type: FieldTypeLong},
+		{Name: "timestamp", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{{.bytes | printf "%08d"}} {{$.bytes}} {{.timestamp}} {{$.timestamp | date "2006-01-02"}}`)
+	t.Logf("with template: %s", string(template))
+
+	g := makeGeneratorWithCustomTemplate(t, Config{}, flds, template, 10)
+
+	var buf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		parts := strings.Split(buf.String(), " ")
+		buf.Reset()
+
+		if len(parts) != 4 {
+			t.Fatalf("unexpected event %v", parts)
+		}
+
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parts[0] != fmt.Sprintf("%08d", n) {
+			t.Errorf("expected %08d, got %s", n, parts[0])
+		}
+
+		ts, err := time.Parse(FieldTypeTimeLayout, parts[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parts[3] != ts.Format("2006-01-02") {
+			t.Errorf("expected %s, got %s", ts.Format("2006-01-02"), parts[3])
+		}
+	}
+}
+
+func Test_ParsePlaceholder(t *testing.T) {
+	testCases := []struct {
+		content  string
+		field    string
+		ref      bool
+		format   bool
+		hasError bool
+	}{
+		{content: "aField", field: "aField"},
+		{content: "$.aField", field: "aField", ref: true},
+		{content: `aField | printf "%08d"`, field: "aField", format: true},
+		{content: `$.aField | date "2006-01-02"`, field: "aField", ref: true, format: true},
+		{content: `aField | printf %08d`, hasError: true},
+		{content: `aField | upper "x"`, hasError: true},
+		{content: `aField | lower | base64`, field: "aField", format: true},
+		{content: `aField | join "|" | upper`, field: "aField", format: true},
+		{content: `aField | add 1`, field: "aField", format: true},
+		{content: `aField | add "one"`, hasError: true},
+		{content: `aField | default`, hasError: true},
+		{content: `aField | unknown`, hasError: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.content, func(t *testing.T) {
+			p, err := parsePlaceholder(testCase.content)
+			if testCase.hasError {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.fieldName != testCase.field || p.ref != testCase.ref || (p.format != nil) != testCase.format {
+				t.Errorf("unexpected placeholder %+v", p)
+			}
+		})
+	}
+}
+
+func Test_PlaceholderFuncs(t *testing.T) {
+	testCases := []struct {
+		content  string
+		value    string
+		expected string
+		hasError bool
+	}{
+		{content: `f | upper`, value: "Host-1", expected: "HOST-1"},
+		{content: `f | lower`, value: "Host-1", expected: "host-1"},
+		{content: `f | default "none"`, value: "", expected: "none"},
+		{content: `f | default "none"`, value: "null", expected: "none"},
+		{content: `f | default "none"`, value: "a", expected: "a"},
+		{content: `f | add 10`, value: "5", expected: "15"},
+		{content: `f | add -0.5`, value: "5", expected: "4.5"},
+		{content: `f | add "1.5"`, value: "1.25", expected: "2.75"},
+		{content: `f | add 1`, value: "a", hasError: true},
+		{content: `f | formatDate "2006-01-02"`, value: "2023-04-05T06:07:08.000Z", expected: "2023-04-05"},
+		{content: `f | urlencode`, value: "a b&c=d", expected: "a+b%26c%3Dd"},
+		{content: `f | base64`, value: "hello", expected: "aGVsbG8="},
+		{content: `f | join ", "`, value: `["a","b",3]`, expected: "a, b, 3"},
+		{content: `f | join "|"`, value: "a", expected: "a"},
+		{content: `f | uuid`, value: "www.example.com", expected: "b63cdfa4-3df9-568e-97ae-006c5b8fd652"},
+		{content: `f | lower | urlencode | printf "q=%s"`, value: "A B", expected: "q=a+b"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.content, func(t *testing.T) {
+			p, err := parsePlaceholder(testCase.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			err = p.format([]byte(testCase.value), &buf)
+			if testCase.hasError {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.String() != testCase.expected {
+				t.Errorf("expected %s, got %s", testCase.expected, buf.String())
+			}
+		})
+	}
+}
+
+func Test_FieldArrayWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "answers", Type: FieldTypeKeyword},
+		{Name: "ttl", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"answers":{{.answers}},"looped":[{{range $.answers}}"{{.}}",{{end}}"end"],"ttl":{{.ttl}}}`)
+	configYaml := []byte(`fields:
+  - name: answers
+    enum: ["a", "b", "c"]
+    array_length:
+      min: 1
+      max: 3
+  - name: ttl
+    range:
+      min: 1
+      max: 10
+    array_length:
+      min: 2
+      max: 2`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 10)
+
+	var buf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[[]any](t, buf.Bytes())
+		buf.Reset()
+
+		if len(m["answers"]) < 1 || len(m["answers"]) > 3 {
+			t.Errorf("expected 1 to 3 answers, got %v", m["answers"])
+		}
+
+		// the looped values are followed by the "end" marker
+		m["looped"] = m["looped"][:len(m["looped"])-1]
+		if len(m["looped"]) != len(m["answers"]) {
+			t.Errorf("expected a looped value for each answer, got %v and %v", m["looped"], m["answers"])
+		}
+
+		for j, answer := range m["answers"] {
+			if answer != "a" && answer != "b" && answer != "c" {
+				t.Errorf("unexpected answer %v", answer)
+			}
+
+			if m["looped"][j] != answer {
+				t.Errorf("expected looped value %v, got %v", answer, m["looped"][j])
+			}
+		}
+
+		if len(m["ttl"]) != 2 {
+			t.Errorf("expected 2 ttl, got %v", m["ttl"])
+		}
+
+		for _, ttl := range m["ttl"] {
+			if v, ok := ttl.(float64); !ok || v < 1 || v > 10 {
+				t.Errorf("unexpected ttl %v", ttl)
+			}
+		}
+	}
+}
+
+func Test_FieldArrayDistinctWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "tags", Type: FieldTypeKeyword},
+		{Name: "source.ip", Type: FieldTypeIP},
+		{Name: "destination.ip", Type: FieldTypeIP},
+		{Name: "related.ip", Type: FieldTypeIP},
+	}
+
+	template := []byte(`{"tags":{{.tags}},"source":"{{.source.ip}}","destination":"{{.destination.ip}}","related":{{.related.ip}}}`)
+	configYaml := []byte(`fields:
+  - name: tags
+    enum: [{value: "a", weight: 10}, "b", "c"]
+    array_length:
+      min: 3
+      max: 3
+      distinct: true
+  - name: source.ip
+    enum: ["10.0.0.1", "10.0.0.2"]
+  - name: destination.ip
+    enum: ["10.0.0.1", "10.0.0.2"]
+  - name: related.ip
+    array_length:
+      min: 2
+      max: 4
+      values_from: [source.ip, destination.ip]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 20)
+
+	var buf bytes.Buffer
+	for i := 0; i < 20; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		tags := m["tags"].([]any)
+		if len(tags) != 3 || tags[0] == tags[1] || tags[0] == tags[2] || tags[1] == tags[2] {
+			t.Errorf("expected 3 distinct tags, got %v", tags)
+		}
+
+		related := m["related"].([]any)
+		if len(related) < 2 || len(related) > 4 {
+			t.Errorf("expected 2 to 4 related ips, got %v", related)
+		}
+
+		seen := make(map[any]struct{})
+		for _, ip := range related {
+			if _, ok := seen[ip]; ok {
+				t.Errorf("duplicate related ip %v in %v", ip, related)
+			}
+
+			seen[ip] = struct{}{}
+		}
+
+		if _, ok := seen[m["source"]]; !ok {
+			t.Errorf("expected source ip %v in %v", m["source"], related)
+		}
+
+		if _, ok := seen[m["destination"]]; !ok {
+			t.Errorf("expected destination ip %v in %v", m["destination"], related)
+		}
+
+		if related[0] != m["source"] {
+			t.Errorf("expected related ips to start with the source ip %v, got %v", m["source"], related)
+		}
+	}
+}
+
+func Test_FieldArrayGroupWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "dns.answers", Type: FieldTypeNested},
+		{Name: "dns.answers.data", Type: FieldTypeKeyword},
+		{Name: "dns.answers.ttl", Type: FieldTypeLong},
+		{Name: "dns.answers.ips", Type: FieldTypeIP},
+		{Name: "dns.question.name", Type: FieldTypeKeyword},
+	}
+
+	configYaml := []byte(`fields:
+  - name: dns.answers
+    array_length:
+      min: 1
+      max: 3
+  - name: dns.answers.data
+    enum: ["a", "b", "c"]
+  - name: dns.answers.ttl
+    range:
+      min: 1
+      max: 10
+  - name: dns.answers.ips
+    array_length:
+      min: 2
+      max: 2
+  - name: dns.question.name
+    enum: ["example.com"]`)
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, template := range [][]byte{
+		[]byte(`{"dns.answers":{{.dns.answers}},"dns.question.name":"{{.dns.question.name}}"}`),
+		// the template generated from the fields writes the group as an array of objects
+		nil,
+	} {
+		t.Logf("with template: %s", string(template))
+
+		g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 10)
+
+		var buf bytes.Buffer
+		for i := 0; i < 10; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[any](t, buf.Bytes())
+			buf.Reset()
+
+			if len(m) != 2 || m["dns.question.name"] != "example.com" {
+				t.Errorf("expected only dns.answers and dns.question.name, got %v", m)
+			}
+
+			answers, ok := m["dns.answers"].([]any)
+			if !ok || len(answers) < 1 || len(answers) > 3 {
+				t.Fatalf("expected 1 to 3 answers, got %v", m["dns.answers"])
+			}
+
+			for _, answer := range answers {
+				a, ok := answer.(map[string]any)
+				if !ok || len(a) != 3 {
+					t.Fatalf("expected an object with data, ttl and ips, got %v", answer)
+				}
+
+				if a["data"] != "a" && a["data"] != "b" && a["data"] != "c" {
+					t.Errorf("unexpected data %v", a["data"])
+				}
+
+				if v, ok := a["ttl"].(float64); !ok || v < 1 || v > 10 {
+					t.Errorf("unexpected ttl %v", a["ttl"])
+				}
+
+				if ips, ok := a["ips"].([]any); !ok || len(ips) != 2 {
+					t.Errorf("expected 2 ips, got %v", a["ips"])
+				}
+			}
+		}
+	}
+}
+
+func Test_FieldDynamicKeysWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "prometheus.labels.*", Type: FieldTypeObject, ObjectType: FieldTypeWildcard},
+		{Name: "prometheus.metrics.*", Type: FieldTypeObject, ObjectType: FieldTypeDouble},
+	}
+
+	configYaml := []byte(`fields:
+  - name: prometheus.labels.*
+    enum: ["a", "b"]
+    dynamic_keys:
+      max: 3
+  - name: prometheus.metrics.*
+    range:
+      min: 1
+      max: 100
+    dynamic_keys:
+      min: 2
+      max: 4
+      cardinality: 10`)
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, template := range [][]byte{
+		[]byte(`{"prometheus.labels":{{.prometheus.labels}},"prometheus.metrics":{{.prometheus.metrics}}}`),
+		// the template generated from the fields writes the objects
+		nil,
+	} {
+		t.Logf("with template: %s", string(template))
+
+		g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 20)
+
+		metricNames := make(map[string]struct{})
+		var buf bytes.Buffer
+		for i := 0; i < 20; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[map[string]any](t, buf.Bytes())
+			buf.Reset()
+
+			if len(m["prometheus.labels"]) > 3 {
+				t.Errorf("expected at most 3 labels, got %v", m["prometheus.labels"])
+			}
+
+			for _, label := range m["prometheus.labels"] {
+				if label != "a" && label != "b" {
+					t.Errorf("unexpected label %v", label)
+				}
+			}
+
+			if len(m["prometheus.metrics"]) < 2 || len(m["prometheus.metrics"]) > 4 {
+				t.Errorf("expected 2 to 4 metrics, got %v", m["prometheus.metrics"])
+			}
+
+			for name, metric := range m["prometheus.metrics"] {
+				metricNames[name] = struct{}{}
+				if v, ok := metric.(float64); !ok || v < 1 || v > 100 {
+					t.Errorf("unexpected metric %s: %v", name, metric)
+				}
+			}
+		}
+
+		if len(metricNames) > 10 {
+			t.Errorf("expected at most 10 metric names, got %v", metricNames)
+		}
+	}
+}
+
+func Test_BlockErrorsWithCustomTemplate(t *testing.T) {
+	fld := Field{Name: "answers", Type: FieldTypeKeyword}
+
+	cfg, err := config.LoadConfigFromYaml([]byte("fields:\n  - name: answers\n    array_length:\n      max: 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		scenario string
+		cfg      Config
+		template string
+	}{
+		{scenario: "missing end", cfg: cfg, template: `{{range .answers}}{{.}}`},
+		{scenario: "unexpected end", cfg: cfg, template: `{{.answers}}{{end}}`},
+		{scenario: "value outside of range", cfg: cfg, template: `{{.}}`},
+		{scenario: "range over non array field", cfg: Config{}, template: `{{range .answers}}{{.}}{{end}}`},
+		{scenario: "else in range", cfg: cfg, template: `{{range .answers}}{{.}}{{else}}none{{end}}`},
+		{scenario: "missing end of if", cfg: cfg, template: `{{if $.answers}}some{{else}}none`},
+		{scenario: "unexpected else", cfg: cfg, template: `{{.answers}}{{else}}`},
+		{scenario: "condition on value outside of range", cfg: cfg, template: `{{if .}}some{{end}}`},
+		{scenario: "invalid condition", cfg: cfg, template: `{{if eq $.answers}}some{{end}}`},
+		{scenario: "missing /if", cfg: cfg, template: `{{#if $.answers}}some{{end}}`},
+		{scenario: "missing /repeat", cfg: cfg, template: `{{#repeat 2}}{{.answers}}{{/if}}`},
+		{scenario: "missing end of repeat", cfg: cfg, template: `{{repeat 2}}{{.answers}}`},
+		{scenario: "invalid repeat count", cfg: cfg, template: `{{repeat 3 1}}{{.answers}}{{end}}`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			_, err := NewGenerator(testCase.cfg, []Field{fld}, 1, WithCustomTemplate([]byte(testCase.template)))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func Test_IfWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "method", Type: FieldTypeKeyword},
+		{Name: "referrer", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{{.method}} {{if $.referrer}}ref={{$.referrer}}{{else}}noref{{end}} {{if eq $.method "GET"}}read{{else}}write{{end}}{{if ne $.method "GET"}} {{$.method}}{{end}}`)
+	configYaml := []byte(`fields:
+  - name: method
+    enum: ["GET", "POST"]
+  - name: referrer
+    enum: ["", "http://example.com"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 20)
+
+	var buf bytes.Buffer
+	for i := 0; i < 20; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		event := buf.String()
+		buf.Reset()
+
+		var expected string
+		switch {
+		case strings.HasPrefix(event, "GET "):
+			expected = "read"
+		case strings.HasPrefix(event, "POST "):
+			expected = "write POST"
+		default:
+			t.Fatalf("unexpected event %s", event)
+		}
+
+		if !strings.HasSuffix(event, " "+expected) {
+			t.Errorf("expected event %s to end with %s", event, expected)
+		}
+
+		if !strings.Contains(event, " ref=http://example.com ") && !strings.Contains(event, " noref ") {
+			t.Errorf("unexpected referrer in event %s", event)
+		}
+	}
+}
+
+func Test_IfOnWrittenValueWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "a", Type: FieldTypeKeyword},
+		{Name: "seq", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{{if .a}}A={{.a}}{{else}}none{{end}} {{if .seq}}{{.seq}}{{end}}`)
+	configYaml := []byte(`fields:
+  - name: a
+    enum: ["", "x"]
+  - name: seq
+    counter: true
+    counter_rate:
+      min: 1
+      max: 1`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 20)
+
+	var buf bytes.Buffer
+	var previous int64
+	for i := 0; i < 20; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		event := buf.String()
+		buf.Reset()
+
+		// the body writes the value the condition is true for, and the counter is advanced once per event
+		written, seq, _ := strings.Cut(event, " ")
+		if written != "A=x" && written != "none" {
+			t.Errorf("unexpected value of the condition in event %s", event)
+		}
+
+		value, err := strconv.ParseInt(seq, 10, 64)
+		if err != nil {
+			t.Fatalf("unexpected counter in event %s", event)
+		}
+
+		if i > 0 && value != previous+1 {
+			t.Errorf("expected counter %d in event %s", previous+1, event)
+		}
+
+		previous = value
+	}
+}
+
+func Test_HashBlocksWithCustomTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "method", Type: FieldTypeKeyword},
+		{Name: "referrer", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{{.method}}{{#if $.referrer}} ref={{$.referrer}}{{else}} noref{{/if}} [{{#repeat 1 3 ","}}{{#if eq $.method "GET"}}r{{/if}}{{#if ne $.method "GET"}}w{{/if}}{{/repeat}}]`)
+	configYaml := []byte(`fields:
+  - name: method
+    enum: ["GET", "POST"]
+  - name: referrer
+    enum: ["", "http://example.com"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, flds, template, 20)
+
+	var buf bytes.Buffer
+	for i := 0; i < 20; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		event := buf.String()
+		buf.Reset()
+
+		method, rest, _ := strings.Cut(event, " ")
+		if !strings.HasPrefix(rest, "ref=http://example.com [") && !strings.HasPrefix(rest, "noref [") {
+			t.Errorf("unexpected referrer in event %s", event)
+		}
+
+		expected := "r"
+		if method == "POST" {
+			expected = "w"
+		}
+
+		_, repeated, _ := strings.Cut(rest, "[")
+		items := strings.Split(strings.TrimSuffix(repeated, "]"), ",")
+		if len(items) < 1 || len(items) > 3 {
+			t.Errorf("expected 1 to 3 repetitions in event %s", event)
+		}
+
+		for _, item := range items {
+			if item != expected {
+				t.Errorf("expected repetition %s in event %s, got %s", expected, event, item)
+			}
+		}
+	}
+}
+
+func Test_HashIfOnFieldWithCustomTemplate(t *testing.T) {
+	fld := Field{Name: "referrer", Type: FieldTypeKeyword}
+
+	template := []byte(`[{{#if referrer}}{{.referrer}}{{/if}}]`)
+	configYaml := []byte(`fields:
+  - name: referrer
+    enum: ["", "http://example.com"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithCustomTemplate(t, cfg, []Field{fld}, template, 20)
+
+	var buf bytes.Buffer
+	for i := 0; i < 20; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		event := buf.String()
+		buf.Reset()
+
+		// the optional field is written only when set, with the value it is set to
+		if event != "[http://example.com]" && event != "[]" {
+			t.Errorf("unexpected optional field in event %s", event)
+		}
+	}
+}
+
+func Test_RepeatWithCustomTemplate(t *testing.T) {
+	fld := Field{Name: "name", Type: FieldTypeKeyword}
+
+	template := []byte(`{{repeat 3}}{{.name}};{{end}}{{repeat 0}}never{{end}}`)
+	t.Logf("with template: %s", string(template))
+
+	g := makeGeneratorWithCustomTemplate(t, Config{}, []Field{fld}, template, 10)
+
+	var buf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		event := buf.String()
+		buf.Reset()
+
+		if strings.Contains(event, "never") {
+			t.Errorf("unexpected body of a repeat 0 in event %s", event)
+		}
+
+		names := strings.Split(strings.TrimSuffix(event, ";"), ";")
+		if len(names) != 3 {
+			t.Errorf("expected 3 repetitions in event %s", event)
+		}
+	}
+}
+
+func Test_ParseRepeatCount(t *testing.T) {
+	testCases := []struct {
+		content   string
+		min, max  int
+		separator string
+		hasError  bool
+	}{
+		{content: "3", min: 3, max: 3},
+		{content: "1 5", min: 1, max: 5},
+		{content: `1 5 ","`, min: 1, max: 5, separator: ","},
+		{content: `2 ", "`, min: 2, max: 2, separator: ", "},
+		{content: "", hasError: true},
+		{content: "-1", hasError: true},
+		{content: "5 1", hasError: true},
+		{content: "1 2 3", hasError: true},
+		{content: `1 ","x`, hasError: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.content, func(t *testing.T) {
+			count, err := parseRepeatCount(testCase.content)
+			if testCase.hasError {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if count.min != testCase.min || count.max != testCase.max || string(count.separator) != testCase.separator {
+				t.Errorf("unexpected repeat count %+v", count)
+			}
+		})
+	}
+}
+
+func Test_ParseCondition(t *testing.T) {
+	testCases := []struct {
+		content  string
+		field    string
+		op       string
+		value    string
+		hasError bool
+	}{
+		{content: "$.aField", field: "aField"},
+		{content: ".aField", field: "aField"},
+		{content: ".", field: ""},
+		{content: `eq $.aField "GET"`, field: "aField", op: "eq", value: "GET"},
+		{content: `ne .aField "with space"`, field: "aField", op: "ne", value: "with space"},
+		{content: `eq $.aField`, hasError: true},
+		{content: `eq $.aField GET`, hasError: true},
+		{content: `$.aField "GET"`, hasError: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.content, func(t *testing.T) {
+			p, cond, err := parseCondition(testCase.content)
+			if testCase.hasError {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.fieldName != testCase.field || cond.op != testCase.op || string(cond.value) != testCase.value {
+				t.Errorf("unexpected condition %+v on %+v", cond, p)
+			}
+		})
+	}
+}
+
 func Test_FieldFloatsWithCustomTemplate(t *testing.T) {
 	_testNumericWithCustomTemplate[float64](t, FieldTypeDouble)
 	_testNumericWithCustomTemplate[float32](t, FieldTypeFloat)
@@ -18,9 +18,13 @@ var generateOnFieldNotInFieldsYaml = errors.New("generate called on a field not
 // GeneratorWithTextTemplate
 type GeneratorWithTextTemplate struct {
 	tpl       *template.Template
+	fields    Fields
+	fieldMap  map[string]any
 	state     *genState
 	errChan   chan error
 	totEvents uint64
+	hooks     hooks
+	timeline  timeline
 }
 
 // awsAZs list all possible AZs for a specific AWS region
@@ -51,18 +55,39 @@ var awsAZs map[string][]string = map[string][]string{
 func newGeneratorWithTextTemplate(cfg Config, fields Fields, totEvents uint64, opts options) (Generator, error) {
 	// Preprocess the fields, generating appropriate bound function
 	state := newGenState(opts.randSeed)
-	fieldMap := make(map[string]any)
-	for _, field := range fields {
-		if err := bindField(cfg, field, fieldMap, true); err != nil {
-			return nil, err
-		}
+	fieldMap, err := bindFields(cfg, fields, opts.hooks, true)
+	if err != nil {
+		return nil, err
+	}
 
+	if err := validateTimeline(cfg, fields, true); err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
 		state.prevCacheForDup[field.Name] = make(map[any]struct{})
 		state.prevCacheCardinality[field.Name] = make([]any, 0)
 	}
 
 	errChan := make(chan error)
 
+	t := template.New("generator")
+	t = t.Option("missingkey=error")
+
+	parsedTpl, err := t.Funcs(makeTemplateFuncs(state, fieldMap, errChan)).Parse(string(opts.template))
+	if err != nil {
+		return nil, err
+	}
+
+	state.totEvents = totEvents
+	state.batchSize = opts.batchSize
+	state.tenantCount = len(cfg.Tenants())
+
+	return &GeneratorWithTextTemplate{tpl: parsedTpl, fields: fields, fieldMap: fieldMap, totEvents: totEvents, state: state, errChan: errChan, hooks: opts.hooks, timeline: timeline{cfg: cfg}}, nil
+}
+
+// makeTemplateFuncs returns the template functions bound to the state of a generator
+func makeTemplateFuncs(state *genState, fieldMap map[string]any, errChan chan error) template.FuncMap {
 	templateFns := sprig.TxtFuncMap()
 
 	templateFns["awsAZFromRegion"] = func(region string) string {
@@ -74,6 +99,12 @@ func newGeneratorWithTextTemplate(cfg Config, fields Fields, totEvents uint64, o
 		return azs[state.rand.Intn(len(azs))]
 	}
 
+	templateFns["batchIndex"] = func() uint64 {
+		return state.batch()
+	}
+
+	templateFns["escape"] = makeEscapeTemplateFunc()
+
 	templateFns["generate"] = func(field string) any {
 		bindF, ok := fieldMap[field].(emitF)
 		if !ok {
@@ -81,20 +112,75 @@ func newGeneratorWithTextTemplate(cfg Config, fields Fields, totEvents uint64, o
 			return nil
 		}
 
-		return bindF(state)
+		state.emitting = field
+		value := bindF(state)
+		state.emitting = ""
+
+		return value
 	}
 
-	t := template.New("generator")
-	t = t.Option("missingkey=error")
+	return templateFns
+}
+
+// Clone returns a generator sharing the parsed template and the bound fields, but with its own state,
+// seeded with randSeed, so that it can emit events concurrently with the original generator.
+func (gen *GeneratorWithTextTemplate) Clone(randSeed int64) Generator {
+	state := gen.state.clone(randSeed)
+	errChan := make(chan error)
 
-	parsedTpl, err := t.Funcs(templateFns).Parse(string(opts.template))
+	// the template has been already parsed, so cloning it never fails
+	tpl := template.Must(gen.tpl.Clone())
+	tpl.Funcs(makeTemplateFuncs(state, gen.fieldMap, errChan))
+
+	clone := &GeneratorWithTextTemplate{tpl: tpl, fields: gen.fields, fieldMap: gen.fieldMap, totEvents: gen.totEvents, state: state, errChan: errChan, hooks: gen.hooks, timeline: timeline{cfg: gen.timeline.cfg}}
+	if gen.timeline.next > 0 {
+		// the clone starts from the first event, before any timeline step: the config has been already bound, so it never fails
+		_ = clone.rebind(gen.timeline.cfg)
+	}
+
+	return clone
+}
+
+// Reload binds the fields again with cfg, keeping the state of the generator: the values already
+// generated for cardinalities, counters and constants are kept, and the event counter goes on.
+// The steps of the timeline of cfg already due are applied before the next event.
+func (gen *GeneratorWithTextTemplate) Reload(cfg Config) error {
+	if err := validateTimeline(cfg, gen.fields, true); err != nil {
+		return err
+	}
+
+	if err := gen.rebind(cfg); err != nil {
+		return err
+	}
+
+	// the `at` steps keep counting from the first event
+	gen.timeline = timeline{cfg: cfg, start: gen.timeline.start, now: gen.timeline.now}
+
+	return nil
+}
+
+func (gen *GeneratorWithTextTemplate) rebind(cfg Config) error {
+	fieldMap, err := bindFields(cfg, gen.fields, gen.hooks, true)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	state.totEvents = totEvents
+	// the template has been already parsed, so cloning it never fails
+	tpl := template.Must(gen.tpl.Clone())
+	tpl.Funcs(makeTemplateFuncs(gen.state, fieldMap, gen.errChan))
+
+	gen.tpl = tpl
+	gen.fieldMap = fieldMap
+
+	return nil
+}
+
+func (gen *GeneratorWithTextTemplate) SaveState(w io.Writer) error {
+	return gen.state.save(w, true)
+}
 
-	return &GeneratorWithTextTemplate{tpl: parsedTpl, totEvents: totEvents, state: state, errChan: errChan}, nil
+func (gen *GeneratorWithTextTemplate) LoadState(r io.Reader) error {
+	return gen.state.load(r, true)
 }
 
 func (gen *GeneratorWithTextTemplate) Close() error {
@@ -102,7 +188,7 @@ func (gen *GeneratorWithTextTemplate) Close() error {
 }
 
 func (gen *GeneratorWithTextTemplate) Emit(buf *bytes.Buffer) error {
-	if err := gen.emit(buf); err != nil {
+	if err := emitHandlingErrors(gen.state, gen.hooks, buf, gen.emit); err != nil {
 		return err
 	}
 
@@ -110,16 +196,43 @@ func (gen *GeneratorWithTextTemplate) Emit(buf *bytes.Buffer) error {
 	return nil
 }
 
+func (gen *GeneratorWithTextTemplate) EmitDocument(buf *bytes.Buffer) (map[string]any, error) {
+	return emitDocument(buf, gen.Emit)
+}
+
+func (gen *GeneratorWithTextTemplate) EmitBatch(buf *bytes.Buffer, n int) ([][]byte, error) {
+	return emitBatch(buf, n, gen.Emit)
+}
+
 func (gen *GeneratorWithTextTemplate) emit(buf *bytes.Buffer) error {
 	if gen.totEvents == 0 || gen.state.counter < gen.totEvents {
 		select {
 		case <-gen.errChan:
 			return generateOnFieldNotInFieldsYaml
 		default:
+			if cfg, steps, ok := gen.timeline.advance(gen.state.counter); ok {
+				if err := gen.rebind(cfg); err != nil {
+					return err
+				}
+
+				if err := gen.hooks.runTimelineSteps(gen.state, steps); err != nil {
+					return err
+				}
+			}
+
+			if err := gen.hooks.runBeforeEvent(gen.state); err != nil {
+				return err
+			}
+
+			offset := buf.Len()
 			err := gen.tpl.Execute(buf, nil)
 			if err != nil {
 				return err
 			}
+
+			if err := gen.hooks.runAfterEvent(gen.state, buf, offset); err != nil {
+				return err
+			}
 		}
 	} else {
 		return io.EOF
@@ -2,11 +2,18 @@ package genlib
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"math/rand"
 	"net"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -882,6 +889,3367 @@ func Test_FieldLongCounterResetAfterN5WithTextTemplate(t *testing.T) {
 	}
 }
 
+func Test_FieldPerRunConstantWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeKeyword,
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}"}`)
+	configYaml := []byte("fields:\n  - name: alpha\n    per_run_constant: true")
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1024
+	g := makeGeneratorWithTextTemplate(t, cfg, []Field{fld}, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	var first string
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		v, ok := m[fld.Name]
+		if !ok {
+			t.Errorf("Missing key %v", fld.Name)
+		}
+
+		if i == 0 {
+			first = v
+		}
+
+		if v != first {
+			t.Errorf("Expected constant value %s, got %s", first, v)
+		}
+	}
+}
+
+func Test_FieldPerBatchConstantWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeKeyword,
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}","batch":{{batchIndex}}}`)
+	configYaml := []byte("fields:\n  - name: alpha\n    per_batch_constant: true")
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batchSize := 10
+	nSpins := 100
+	g, err := NewGenerator(cfg, []Field{fld}, uint64(nSpins), WithTextTemplate(template), WithBatchSize(uint64(batchSize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	valuesPerBatch := make(map[float64]map[any]struct{})
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		batch := m["batch"].(float64)
+		if int(batch) != i/batchSize {
+			t.Errorf("Expected batch %d, got %v", i/batchSize, batch)
+		}
+
+		if _, ok := valuesPerBatch[batch]; !ok {
+			valuesPerBatch[batch] = make(map[any]struct{})
+		}
+
+		valuesPerBatch[batch][m[fld.Name]] = struct{}{}
+	}
+
+	if len(valuesPerBatch) != nSpins/batchSize {
+		t.Errorf("Expected %d batches, got %d", nSpins/batchSize, len(valuesPerBatch))
+	}
+
+	for batch, values := range valuesPerBatch {
+		if len(values) != 1 {
+			t.Errorf("Expected a single value in batch %v, got %d", batch, len(values))
+		}
+	}
+}
+
+func Test_FieldFloatPrecisionWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeDouble,
+	}
+
+	template := []byte(`{"alpha":{{generate "alpha"}}}`)
+	configYaml := []byte("fields:\n  - name: alpha\n    precision: 1\n    rounding: ceil\n    range:\n      min: 0\n      max: 100")
+	t.Logf("with template: %s", string(template))
+
+	nSpins := rand.Intn(1024) + 1
+	for i := 0; i < nSpins; i++ {
+		v := testSingleTWithTextTemplate[float64](t, fld, configYaml, template)
+
+		if v != math.Round(v*10)/10 {
+			t.Errorf("Expected value rounded to 1 decimal, got %v", v)
+		}
+	}
+}
+
+func Test_ConstraintSumWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "user", Type: FieldTypeDouble},
+		{Name: "system", Type: FieldTypeDouble},
+		{Name: "idle", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"user":{{generate "user"}},"system":{{generate "system"}},"idle":{{generate "idle"}}}`)
+	configYaml := []byte(`fields:
+  - name: user
+    precision: 1
+  - name: system
+    precision: 1
+  - name: idle
+    precision: 1
+constraints:
+  - type: sum
+    fields: ["user", "system", "idle"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1024
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		buf.Reset()
+
+		var sum float64
+		for _, fld := range flds {
+			v, ok := m[fld.Name]
+			if !ok {
+				t.Errorf("Missing key %v", fld.Name)
+			}
+
+			if v < 0 {
+				t.Errorf("Expected non negative value for %s, got %v", fld.Name, v)
+			}
+
+			sum += v
+		}
+
+		if math.Abs(sum-100) > 1e-9 {
+			t.Errorf("Expected values to sum to 100, got %v (%v)", sum, m)
+		}
+	}
+}
+
+func Test_SaveAndLoadStateWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "count", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"host":"{{generate "host"}}","count":{{generate "count"}}}`)
+	configYaml := []byte(`fields:
+  - name: host
+    cardinality: 3
+  - name: count
+    counter: true`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emit := func(g Generator, n int) (map[string]struct{}, float64) {
+		hosts := make(map[string]struct{})
+		var count float64
+		var buf bytes.Buffer
+		for i := 0; i < n; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[any](t, buf.Bytes())
+			buf.Reset()
+
+			hosts[m["host"].(string)] = struct{}{}
+			count = m["count"].(float64)
+		}
+
+		return hosts, count
+	}
+
+	nSpins := 10
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+	hosts, count := emit(g, nSpins)
+
+	var state bytes.Buffer
+	if err := g.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err = NewGenerator(cfg, flds, uint64(nSpins), WithTextTemplate(template), WithRandSeed(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	nextHosts, nextCount := emit(g, nSpins)
+	if nextCount <= count {
+		t.Errorf("Expected the counter to carry on from %v, got %v", count, nextCount)
+	}
+
+	for host := range nextHosts {
+		if _, ok := hosts[host]; !ok {
+			t.Errorf("Expected the hosts of the previous run %v, got %s", hosts, host)
+		}
+	}
+}
+
+func Test_SaveAndLoadStateValuesWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "tags", Type: FieldTypeKeyword},
+		{Name: "location", Type: FieldTypeGeoPoint},
+		{Name: "latency", Type: FieldTypeHistogram},
+		{Name: "ports", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"tags":{{generate "tags" | toJson}},"location":{{generate "location"}},"latency":{{generate "latency"}},"ports":{{generate "ports" | toJson}}}`)
+	configYaml := []byte(`fields:
+  - name: tags
+    cardinality: 3
+    array_length:
+      min: 1
+      max: 3
+  - name: location
+    cardinality: 2
+    geo_format: object
+    precision: 2
+  - name: latency
+    cardinality: 2
+    range:
+      min: 0
+      max: 10
+    precision: 1
+  - name: ports
+    per_run_constant: true
+    array_length:
+      min: 2
+      max: 2`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emit := func(g Generator, n int) map[string]map[string]struct{} {
+		values := map[string]map[string]struct{}{"tags": {}, "location": {}, "latency": {}, "ports": {}}
+		var buf bytes.Buffer
+		for i := 0; i < n; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			var m map[string]json.RawMessage
+			if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+				t.Fatal(err)
+			}
+			buf.Reset()
+
+			var tags []string
+			if err := json.Unmarshal(m["tags"], &tags); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, tag := range tags {
+				values["tags"][tag] = struct{}{}
+			}
+
+			for _, name := range []string{"location", "latency", "ports"} {
+				values[name][string(m[name])] = struct{}{}
+			}
+		}
+
+		return values
+	}
+
+	nSpins := 20
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+	values := emit(g, nSpins)
+
+	var state bytes.Buffer
+	if err := g.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err = NewGenerator(cfg, flds, uint64(nSpins), WithTextTemplate(template), WithRandSeed(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	// the values of the previous run are generated again, written the same way
+	for name, nextValues := range emit(g, nSpins) {
+		for value := range nextValues {
+			if _, ok := values[name][value]; !ok {
+				t.Errorf("Expected the %s of the previous run %v, got %s", name, values[name], value)
+			}
+		}
+	}
+}
+
+func Test_LoadStateTenantsWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"host":"{{generate "host"}}"}`)
+	tenantsYaml := func(n int) []byte {
+		configYaml := "fields:\n  - name: host\n    cardinality: 2\ntenants:\n"
+		for i := 0; i < n; i++ {
+			configYaml += fmt.Sprintf("  - name: tenant%d\n", i)
+		}
+
+		return []byte(configYaml)
+	}
+
+	cfg, err := config.LoadConfigFromYaml(tenantsYaml(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 10)
+	var buf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var state bytes.Buffer
+	if err := g.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, configYaml := range [][]byte{tenantsYaml(3), []byte("fields:\n  - name: host\n    cardinality: 2")} {
+		cfg, err := config.LoadConfigFromYaml(configYaml)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		g, err := NewGenerator(cfg, flds, 10, WithTextTemplate(template))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := g.LoadState(bytes.NewReader(state.Bytes())); !errors.Is(err, stateTenantsNotCompatible) {
+			t.Errorf("Expected the state to be rejected, got %v", err)
+		}
+	}
+
+	g, err = NewGenerator(cfg, flds, 10, WithTextTemplate(template))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.LoadState(bytes.NewReader(state.Bytes())); err != nil {
+		t.Errorf("Expected the state to be loaded with the same tenants, got %v", err)
+	}
+}
+
+func Test_FieldBucketWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "timestamp", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{{$timestamp := generate "timestamp"}}{"host":"{{generate "host"}}","@timestamp":"{{$timestamp.Format "2006-01-02T15:04:05.999999Z07:00"}}"}`)
+	configYaml := []byte(`fields:
+  - name: host
+    enum: ["a", "b", "c"]
+  - name: timestamp
+    range:
+      from: "2023-01-01T00:00:00.000000000+00:00"
+    bucket:
+      interval: 10s
+      docs: 2
+      entity: host`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 60
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	docs := make(map[string]int)
+	seen := make(map[string]struct{})
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		ts, err := time.Parse(FieldTypeTimeLayout, m["@timestamp"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := seen[m["host"]+m["@timestamp"]]; ok {
+			t.Errorf("Expected no duplicate timestamp for %s, got %s twice", m["host"], m["@timestamp"])
+		}
+
+		seen[m["host"]+m["@timestamp"]] = struct{}{}
+		docs[fmt.Sprintf("%s/%d", m["host"], ts.Sub(from)/(10*time.Second))]++
+	}
+
+	if len(docs) != 30 {
+		t.Errorf("Expected 10 buckets for 3 hosts, got %d", len(docs))
+	}
+
+	for bucket, n := range docs {
+		if n != 2 {
+			t.Errorf("Expected 2 docs in bucket %s, got %d", bucket, n)
+		}
+	}
+}
+
+func Test_FieldBucketGapsWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "timestamp", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{{$timestamp := generate "timestamp"}}{"host":"{{generate "host"}}","@timestamp":"{{$timestamp.Format "2006-01-02T15:04:05.999999Z07:00"}}"}`)
+	configYaml := []byte(`fields:
+  - name: host
+    enum: ["a", "b", "c", "d", "e"]
+  - name: timestamp
+    range:
+      from: "2023-01-01T00:00:00.000000000+00:00"
+    bucket:
+      interval: 10s
+      entity: host
+      gaps:
+        probability: 0.2
+        min: 2
+        max: 3`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 500
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	buckets := make(map[string][]int64)
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		ts, err := time.Parse(FieldTypeTimeLayout, m["@timestamp"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buckets[m["host"]] = append(buckets[m["host"]], int64(ts.Sub(from)/(10*time.Second)))
+	}
+
+	if len(buckets) != 5 {
+		t.Fatalf("Expected 5 hosts, got %d", len(buckets))
+	}
+
+	var gaps int
+	for host, hostBuckets := range buckets {
+		for i := 1; i < len(hostBuckets); i++ {
+			switch missing := hostBuckets[i] - hostBuckets[i-1] - 1; {
+			case missing < 0:
+				t.Errorf("Expected one document per interval for %s, got interval %d twice", host, hostBuckets[i])
+			case missing == 1 || missing > 3:
+				t.Errorf("Expected gaps of 2 or 3 intervals for %s, got %d", host, missing)
+			case missing > 0:
+				gaps++
+			}
+		}
+	}
+
+	if gaps == 0 {
+		t.Error("Expected some gaps, got none")
+	}
+}
+
+func Test_FieldFloatSpecialValuesWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeDouble},
+		{Name: "beta", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"alpha":{{generate "alpha"}},"beta":{{generate "beta" | printf "%.2f"}}}`)
+	configYaml := []byte(`version: 3
+fields:
+  - name: alpha
+    range:
+      min: -1.7e308
+      max: 1.7e308
+  - name: beta
+    precision: 2
+    normalize_negative_zero: true
+    range:
+      min: -0.004
+      max: 0.001`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Contains(buf.Bytes(), []byte("Inf")) || bytes.Contains(buf.Bytes(), []byte("NaN")) || bytes.Contains(buf.Bytes(), []byte("-0.00")) {
+			t.Errorf("Expected no special values, got %s", buf.String())
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		buf.Reset()
+
+		if math.IsInf(m["alpha"], 0) || m["beta"] != 0 {
+			t.Errorf("Expected finite alpha and zero beta, got %v", m)
+		}
+	}
+}
+
+func Test_FieldLocaleWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+		{Name: "gamma", Type: "text"},
+		{Name: "delta", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}","beta":"{{generate "beta"}}","gamma":"{{generate "gamma"}}","delta":"{{generate "delta"}}"}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    locale: ja
+    content: person_name
+  - name: beta
+    locale: de
+    content: address
+  - name: gamma
+    locale: ja
+    content: text
+  - name: delta
+    locale: ar`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	nonASCII := map[string]int{}
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		if len(strings.Fields(m["alpha"])) != 2 {
+			t.Errorf("Expected a first and a last name, got %s", m["alpha"])
+		}
+
+		if !strings.Contains(m["beta"], ", ") {
+			t.Errorf("Expected an address, got %s", m["beta"])
+		}
+
+		if strings.Contains(m["gamma"], " ") || !strings.HasSuffix(m["gamma"], "。") {
+			t.Errorf("Expected a japanese text, got %s", m["gamma"])
+		}
+
+		for k, v := range m {
+			for _, r := range v {
+				if r > 127 {
+					nonASCII[k]++
+					break
+				}
+			}
+		}
+	}
+
+	// every japanese and arabic value is non-ASCII, german addresses only sometimes
+	for _, k := range []string{"alpha", "gamma", "delta"} {
+		if nonASCII[k] != nSpins {
+			t.Errorf("Expected only non-ASCII values for %s, got %d out of %d", k, nonASCII[k], nSpins)
+		}
+	}
+
+	if nonASCII["beta"] == 0 {
+		t.Errorf("Expected some non-ASCII values for beta")
+	}
+
+	cfg, err = config.LoadConfigFromYaml([]byte("fields:\n  - name: alpha\n    locale: xx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewGenerator(cfg, flds, 1, WithTextTemplate(template))
+	if err == nil {
+		t.Errorf("Expected an error for an unknown locale")
+	}
+}
+
+func Test_FieldUnicodeSaltWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeKeyword},
+		{Name: "gamma", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}","beta":"{{generate "beta"}}","gamma":"{{generate "gamma"}}"}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    unicode_salt: 0.5
+  - name: beta
+    unicode_salt: 1`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	salted := map[string]int{}
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		for k, v := range m {
+			for _, r := range v {
+				if r > 127 {
+					salted[k]++
+					break
+				}
+			}
+		}
+	}
+
+	if salted["alpha"] == 0 || salted["alpha"] == nSpins {
+		t.Errorf("Expected some salted values for alpha, got %d out of %d", salted["alpha"], nSpins)
+	}
+
+	if salted["beta"] != nSpins {
+		t.Errorf("Expected only salted values for beta, got %d out of %d", salted["beta"], nSpins)
+	}
+
+	if salted["gamma"] != 0 {
+		t.Errorf("Expected no salted values for gamma, got %d out of %d", salted["gamma"], nSpins)
+	}
+}
+
+func Test_FieldEscapeWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "message", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"message":"{{generate "message" | escape "json"}}"}`)
+	configYaml := []byte(`fields:
+  - name: message
+    enum: ["a \"quoted\" message\nwith a \\ backslash"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 1)
+
+	var buf bytes.Buffer
+	if err := g.Emit(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[string](t, buf.Bytes())
+	if expected := "a \"quoted\" message\nwith a \\ backslash"; m["message"] != expected {
+		t.Errorf("Expected %q, got %q", expected, m["message"])
+	}
+}
+
+func Test_FieldQuantilesWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeLong},
+		{Name: "beta", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"alpha":{{generate "alpha"}},"beta":{{generate "beta"}}}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    quantiles:
+      p50: 100
+      p90: 300
+      p99: 1000
+  - name: beta
+    range:
+      min: 10
+      max: 500
+    quantiles:
+      p25: 50
+      p75: 200`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 20000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	var alpha, beta []float64
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		buf.Reset()
+
+		alpha = append(alpha, m["alpha"])
+		beta = append(beta, m["beta"])
+	}
+
+	sort.Float64s(alpha)
+	sort.Float64s(beta)
+
+	// the fraction of the values below the target value of a quantile, steadier than the value at the quantile
+	// where the distribution is sparse, like in the tail
+	below := func(values []float64, value float64) float64 {
+		return float64(sort.SearchFloat64s(values, value)) / float64(len(values))
+	}
+
+	for q, expected := range map[float64]float64{0.5: 100, 0.9: 300, 0.99: 1000} {
+		if got := below(alpha, expected); math.Abs(got-q) > 0.02 {
+			t.Errorf("Expected %g of alpha values below %g, got %g", q, expected, got)
+		}
+	}
+
+	for q, expected := range map[float64]float64{0.25: 50, 0.75: 200} {
+		if got := below(beta, expected); math.Abs(got-q) > 0.02 {
+			t.Errorf("Expected %g of beta values below %g, got %g", q, expected, got)
+		}
+	}
+
+	if alpha[0] < 0 || beta[0] < 10 || beta[len(beta)-1] > 500 {
+		t.Errorf("Expected values within bounds, got alpha from %g, beta from %g to %g", alpha[0], beta[0], beta[len(beta)-1])
+	}
+}
+
+func Test_FieldSamplesWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+		{Name: "gamma", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}","beta":{{generate "beta"}},"gamma":{{generate "gamma"}}}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    samples: ["GET", "GET", "GET", "POST"]
+  - name: beta
+    samples: ["10", "20"]
+  - name: gamma
+    samples: ["10", "20"]
+    smoothing: 0.1`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	methods := map[string]int{}
+	var smoothed int
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		methods[m["alpha"].(string)]++
+
+		if beta := m["beta"].(float64); beta != 10 && beta != 20 {
+			t.Errorf("Expected beta one of the samples, got %v", beta)
+		}
+
+		gamma := m["gamma"].(float64)
+		if gamma < 0 || gamma > 30 {
+			t.Errorf("Expected gamma around the samples, got %v", gamma)
+		}
+
+		if gamma != 10 && gamma != 20 {
+			smoothed++
+		}
+	}
+
+	if len(methods) != 2 || methods["GET"] < nSpins*6/10 || methods["GET"] > nSpins*9/10 {
+		t.Errorf("Expected about 75%% of GET, got %v", methods)
+	}
+
+	if smoothed < nSpins*9/10 {
+		t.Errorf("Expected smoothed gamma values, got %d out of %d", smoothed, nSpins)
+	}
+}
+
+func Test_FieldTimeOfDayWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "timestamp", Type: FieldTypeDate},
+		{Name: "latency", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{{$timestamp := generate "timestamp"}}{"timestamp":"{{$timestamp.Format "2006-01-02T15:04:05.999999Z07:00"}}","latency":{{generate "latency"}}}`)
+	configYaml := []byte(`fields:
+  - name: timestamp
+    range:
+      from: "2023-01-01T00:00:00.000000+00:00"
+      to: "2023-01-03T00:00:00.000000+00:00"
+  - name: latency
+    range:
+      min: 10
+      max: 20
+    time_of_day:
+      timestamp: timestamp
+      windows:
+        - from: "09:00"
+          to: "17:00"
+          field:
+            range:
+              min: 100
+              max: 200
+        - from: "22:00"
+          to: "02:30"
+          field:
+            range:
+              min: 1000
+              max: 2000`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	windows := map[int64]int{}
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		timestamp, err := time.Parse(FieldTypeTimeLayout, m["timestamp"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		minute := timestamp.UTC().Hour()*60 + timestamp.UTC().Minute()
+		latency := int64(m["latency"].(float64))
+
+		var minValue int64
+		switch {
+		case minute >= 9*60 && minute < 17*60:
+			minValue = 100
+		case minute >= 22*60 || minute < 2*60+30:
+			minValue = 1000
+		default:
+			minValue = 10
+		}
+
+		if latency < minValue || latency > 2*minValue {
+			t.Errorf("Expected latency between %d and %d at %s, got %d", minValue, 2*minValue, timestamp, latency)
+		}
+
+		windows[minValue]++
+	}
+
+	if len(windows) != 3 {
+		t.Errorf("Expected events in all the windows, got %v", windows)
+	}
+}
+
+func Test_CorrelationsWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "host.ip", Type: FieldTypeIP},
+		{Name: "host.mac", Type: FieldTypeKeyword},
+		{Name: "user", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"host":{"name":"{{generate "host.name"}}","ip":"{{generate "host.ip"}}","mac":"{{generate "host.mac"}}"},"user":"{{generate "user"}}"}`)
+	configYaml := []byte(`correlations:
+  - name: host
+    fields: [host.name, host.ip, host.mac]
+    cardinality: 5`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	hosts := map[string]string{}
+	users := map[string]struct{}{}
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		host := m["host"].(map[string]any)
+		entity := host["ip"].(string) + " " + host["mac"].(string)
+		if previous, ok := hosts[host["name"].(string)]; ok && previous != entity {
+			t.Errorf("Expected host %s to always be %s, got %s", host["name"], previous, entity)
+		}
+
+		hosts[host["name"].(string)] = entity
+		users[m["user"].(string)] = struct{}{}
+	}
+
+	if len(hosts) != 5 {
+		t.Errorf("Expected 5 hosts, got %d", len(hosts))
+	}
+
+	if len(users) <= 5 {
+		t.Errorf("Expected the fields not correlated to be independent, got %d users", len(users))
+	}
+}
+
+func Test_SessionsWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "session.id", Type: FieldTypeKeyword},
+		{Name: "source.ip", Type: FieldTypeIP},
+		{Name: "user.name", Type: FieldTypeKeyword},
+		{Name: "message", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"session":{"id":"{{generate "session.id"}}"},"source":{"ip":"{{generate "source.ip"}}"},"user":{"name":"{{generate "user.name"}}"},"message":"{{generate "message"}}"}`)
+	configYaml := []byte(`sessions:
+  - name: user
+    fields: [session.id, source.ip, user.name]
+    length:
+      min: 3
+      max: 5`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var lengths []int
+	var previous string
+	messages := map[string]struct{}{}
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		session := m["session"].(map[string]any)["id"].(string) + " " + m["source"].(map[string]any)["ip"].(string) + " " + m["user"].(map[string]any)["name"].(string)
+		if session != previous {
+			lengths = append(lengths, 0)
+			previous = session
+		}
+
+		lengths[len(lengths)-1]++
+		messages[m["message"].(string)] = struct{}{}
+	}
+
+	// the last session can be cut by the end of the generation
+	for i, length := range lengths {
+		if length > 5 || length < 3 && i < len(lengths)-1 {
+			t.Errorf("Expected sessions of 3 to 5 events, got %d", length)
+		}
+	}
+
+	if len(lengths) < nSpins/5 {
+		t.Errorf("Expected at least %d sessions, got %d", nSpins/5, len(lengths))
+	}
+
+	if len(messages) <= len(lengths) {
+		t.Errorf("Expected the fields not in the session to be independent, got %d messages", len(messages))
+	}
+}
+
+func Test_TenantsWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "data_stream.namespace", Type: FieldTypeKeyword},
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "count", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"namespace":"{{generate "data_stream.namespace"}}","host":"{{generate "host"}}","count":{{generate "count"}}}`)
+	configYaml := []byte(`fields:
+  - name: count
+    counter: true
+tenants:
+  - name: acme
+    weight: 3
+    fields:
+      - name: data_stream.namespace
+        value: acme
+      - name: host
+        cardinality: 2
+  - name: globex
+    fields:
+      - name: data_stream.namespace
+        value: globex
+      - name: host
+        cardinality: 5`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := map[string]map[string]struct{}{"acme": {}, "globex": {}}
+	events := map[string]int{}
+	emit := func(g Generator, n int) {
+		counts := map[string]float64{}
+		var buf bytes.Buffer
+		for i := 0; i < n; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[any](t, buf.Bytes())
+			buf.Reset()
+
+			namespace := m["namespace"].(string)
+			hostsOfTenant, ok := hosts[namespace]
+			if !ok {
+				t.Fatalf("Expected a tenant namespace, got %s", namespace)
+			}
+
+			hostsOfTenant[m["host"].(string)] = struct{}{}
+			events[namespace]++
+
+			count := m["count"].(float64)
+			if previous, ok := counts[namespace]; ok && count < previous {
+				t.Errorf("Expected the counter of %s not to decrease from %v, got %v", namespace, previous, count)
+			}
+
+			counts[namespace] = count
+		}
+	}
+
+	nSpins := 2000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+	emit(g, nSpins/2)
+
+	var state bytes.Buffer
+	if err := g.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err = NewGenerator(cfg, flds, uint64(nSpins), WithTextTemplate(template), WithRandSeed(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	emit(g, nSpins/2)
+
+	if len(hosts["acme"]) != 2 || len(hosts["globex"]) != 5 {
+		t.Errorf("Expected 2 hosts for acme and 5 for globex, got %v", hosts)
+	}
+
+	for host := range hosts["acme"] {
+		if _, ok := hosts["globex"][host]; ok {
+			t.Errorf("Expected the tenants to have their own hosts, got %s for both", host)
+		}
+	}
+
+	if events["acme"] < 2*events["globex"] {
+		t.Errorf("Expected about 3 acme events for each globex one, got %v", events)
+	}
+}
+
+func Test_FieldGeoPointBBoxWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "location", Type: FieldTypeGeoPoint},
+		{Name: "pin", Type: FieldTypeGeoPoint},
+		{Name: "hash", Type: FieldTypeGeoPoint},
+	}
+
+	template := []byte(`{"location":{{generate "location"}},"pin":"{{generate "pin"}}","hash":"{{generate "hash"}}"}`)
+	configYaml := []byte(`fields:
+  - name: location
+    geo_format: object
+    geo_bbox:
+      top_left: {lat: 48.9, lon: 2.2}
+      bottom_right: {lat: 48.8, lon: 2.5}
+  - name: pin
+    precision: 2
+    geo_bbox:
+      top_left: {lat: 10, lon: 170}
+      bottom_right: {lat: -10, lon: -170}
+  - name: hash
+    geo_format: geohash
+    geo_bbox:
+      top_left: {lat: 48.9, lon: 2.2}
+      bottom_right: {lat: 48.8, lon: 2.5}`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		location := m["location"].(map[string]any)
+		if lat := location["lat"].(float64); lat < 48.8 || lat > 48.9 {
+			t.Errorf("Expected the latitude of the location in the bbox, got %v", lat)
+		}
+
+		if lon := location["lon"].(float64); lon < 2.2 || lon > 2.5 {
+			t.Errorf("Expected the longitude of the location in the bbox, got %v", lon)
+		}
+
+		latLon := strings.Split(m["pin"].(string), ",")
+		if len(latLon) != 2 || len(latLon[0]) > len("-10.00") || len(latLon[1]) > len("-180.00") {
+			t.Fatalf("Expected a lat,lon pin with 2 decimals, got %s", m["pin"])
+		}
+
+		lat, err := strconv.ParseFloat(latLon[0], 64)
+		if err != nil || lat < -10 || lat > 10 {
+			t.Errorf("Expected the latitude of the pin in the bbox, got %s", latLon[0])
+		}
+
+		lon, err := strconv.ParseFloat(latLon[1], 64)
+		if err != nil || (lon < 170 && lon > -170) {
+			t.Errorf("Expected the longitude of the pin across the antimeridian, got %s", latLon[1])
+		}
+
+		if hash := m["hash"].(string); len(hash) != 12 || !strings.HasPrefix(hash, "u09") {
+			t.Errorf("Expected a geohash in Paris, got %s", hash)
+		}
+	}
+}
+
+func Test_FieldGeoTrajectoryWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "vehicle", Type: FieldTypeKeyword},
+		{Name: "location", Type: FieldTypeGeoPoint},
+	}
+
+	template := []byte(`{"vehicle":"{{generate "vehicle"}}","location":{{generate "location"}},"lat":{{(generate "location").Lat}}}`)
+	configYaml := []byte(`fields:
+  - name: vehicle
+    enum: [a, b]
+  - name: location
+    geo_format: object
+    geo_trajectory:
+      max_speed: 120
+      max_turn: 10
+      entity: vehicle`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	last := make(map[string][2]float64)
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		location := m["location"].(map[string]any)
+		lat, lon := location["lat"].(float64), location["lon"].(float64)
+		if lat != math.Round(m["lat"].(float64)*1e6)/1e6 {
+			t.Errorf("Expected the same location in the event, got latitudes %v and %v", lat, m["lat"])
+		}
+
+		// without a timestamp the points of a vehicle are a minute apart, 2 km at 120 km/h
+		vehicle := m["vehicle"].(string)
+		if previous, ok := last[vehicle]; ok {
+			if distance := haversineKm(previous[0], previous[1], lat, lon); distance > 2.001 {
+				t.Errorf("Expected vehicle %s to move at most 2 km, got %v km", vehicle, distance)
+			}
+		}
+
+		last[vehicle] = [2]float64{lat, lon}
+	}
+}
+
+func Test_FieldEnumWeightsWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "method", Type: FieldTypeKeyword},
+		{Name: "status", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"method":"{{generate "method"}}","status":{{generate "status"}}}`)
+	configYaml := []byte(`fields:
+  - name: method
+    enum:
+      - value: GET
+        weight: 70
+      - value: POST
+        weight: 25
+      - PUT
+      - {value: DELETE, weight: 4}
+  - name: status
+    enum:
+      - {value: 200, weight: 9}
+      - 500`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 10000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	methods := map[string]int{}
+	statuses := map[float64]int{}
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		methods[m["method"].(string)]++
+		statuses[m["status"].(float64)]++
+	}
+
+	expected := map[string]float64{"GET": 0.7, "POST": 0.25, "PUT": 0.01, "DELETE": 0.04}
+	for method, share := range expected {
+		if got := float64(methods[method]) / float64(nSpins); math.Abs(got-share) > 0.02 {
+			t.Errorf("Expected a share of %v for %s, got %v", share, method, got)
+		}
+	}
+
+	if len(methods) != len(expected) {
+		t.Errorf("Expected only the enum values, got %v", methods)
+	}
+
+	if got := float64(statuses[200]) / float64(nSpins); math.Abs(got-0.9) > 0.02 {
+		t.Errorf("Expected a share of 0.9 for 200, got %v", got)
+	}
+}
+
+func Test_FieldStrictCardinalityWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "ip", Type: FieldTypeIP},
+		{Name: "port", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"ip":"{{generate "ip"}}","port":{{generate "port"}}}`)
+	configYaml := []byte(`fields:
+  - name: ip
+    cardinality: 50
+    strict_cardinality: true
+  - name: port
+    range:
+      min: 1
+      max: 60
+    cardinality: 50
+    strict_cardinality: true`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 200
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	ips := make(map[string]struct{})
+	ports := make(map[float64]struct{})
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		ips[m["ip"].(string)] = struct{}{}
+		ports[m["port"].(float64)] = struct{}{}
+	}
+
+	if len(ips) != 50 {
+		t.Errorf("expected 50 distinct ips, got %d", len(ips))
+	}
+
+	if len(ports) != 50 {
+		t.Errorf("expected 50 distinct ports, got %d", len(ports))
+	}
+
+	// not enough possible values
+	configYaml = []byte(`fields:
+  - name: port
+    range:
+      min: 1
+      max: 5
+    cardinality: 10
+    strict_cardinality: true`)
+
+	cfg, err = config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g = makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var failed bool
+	for i := 0; i < nSpins && !failed; i++ {
+		failed = g.Emit(&buf) != nil
+		buf.Reset()
+	}
+
+	if !failed {
+		t.Error("expected error with fewer possible values than cardinality")
+	}
+
+	// not enough events
+	configYaml = []byte(`fields:
+  - name: ip
+    cardinality: 50
+    strict_cardinality: true`)
+
+	cfg, err = config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g = makeGeneratorWithTextTemplate(t, cfg, flds, template, 20)
+	if err := g.Emit(&buf); err == nil {
+		t.Error("expected error with fewer events than cardinality")
+	}
+}
+
+func Test_FieldCardinalityZipfWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "service", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"host":"{{generate "host"}}","service":"{{generate "service"}}"}`)
+	configYaml := []byte(`fields:
+  - name: host
+    cardinality: 100
+    cardinality_distribution: zipf
+  - name: service
+    cardinality: 1
+    cardinality_distribution: zipf`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 2000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	hosts := make(map[string]int)
+	services := make(map[string]struct{})
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		hosts[m["host"]]++
+		services[m["service"]] = struct{}{}
+	}
+
+	if len(hosts) > 100 {
+		t.Errorf("expected at most 100 distinct hosts, got %d", len(hosts))
+	}
+
+	if len(services) != 1 {
+		t.Errorf("expected 1 distinct service, got %d", len(services))
+	}
+
+	// with a uniform distribution the most frequent host would be about 20 times
+	var top int
+	for _, count := range hosts {
+		if count > top {
+			top = count
+		}
+	}
+
+	if top < 200 {
+		t.Errorf("expected the most frequent host to dominate, got %d times out of %d", top, nSpins)
+	}
+}
+
+func Test_FieldExcludeValuesWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "domain", Type: FieldTypeKeyword},
+		{Name: "port", Type: FieldTypeLong},
+		{Name: "ratio", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"domain":"{{generate "domain"}}","port":{{generate "port"}},"ratio":{{generate "ratio"}}}`)
+	configYaml := []byte(`fields:
+  - name: domain
+    enum: [example.com, customer.com, example.org]
+    cardinality: 2
+    exclude_values: [customer.com]
+  - name: port
+    range:
+      min: 0
+      max: 3
+    exclude_values: [0, 1]
+  - name: ratio
+    range:
+      min: 1
+      max: 2
+    precision: 1
+    exclude_values: [1.5, 1.50]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 200
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	domains := make(map[string]struct{})
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		domains[m["domain"].(string)] = struct{}{}
+
+		if port := m["port"].(float64); port < 2 {
+			t.Errorf("expected port not in exclude_values, got %v", port)
+		}
+
+		if ratio := m["ratio"].(float64); ratio == 1.5 {
+			t.Errorf("expected ratio not in exclude_values, got %v", ratio)
+		}
+	}
+
+	if _, ok := domains["customer.com"]; ok || len(domains) != 2 {
+		t.Errorf("expected example.com and example.org, got %v", domains)
+	}
+
+	// all the values excluded
+	configYaml = []byte(`fields:
+  - name: domain
+    enum: [customer.com]
+    exclude_values: [customer.com]`)
+
+	cfg, err = config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g = makeGeneratorWithTextTemplate(t, cfg, flds, []byte(`{"domain":"{{generate "domain"}}"}`), uint64(nSpins))
+	if err := g.Emit(&buf); err == nil {
+		t.Error("expected error with all the values excluded")
+	}
+}
+
+func Test_FieldAffixesWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "instance", Type: FieldTypeKeyword},
+		{Name: "hash", Type: FieldTypeKeyword},
+		{Name: "port", Type: FieldTypeLong},
+		{Name: "tags", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"instance":"{{generate "instance"}}","hash":"{{generate "hash"}}","port":"{{generate "port"}}","tags":[{{range $i, $t := generate "tags"}}{{if $i}},{{end}}"{{$t}}"{{end}}]}`)
+	configYaml := []byte(`fields:
+  - name: instance
+    enum: [0abc]
+    format: 'i-%s'
+  - name: hash
+    enum: [deadbeef]
+    prefix: 'sha256:'
+    suffix: '!'
+  - name: port
+    enum: [80]
+    format: '%05s'
+  - name: tags
+    enum: [a]
+    prefix: 'tag-'
+    array_length:
+      min: 2
+      max: 2`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 1)
+
+	var buf bytes.Buffer
+	if err := g.Emit(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[any](t, buf.Bytes())
+	if m["instance"] != "i-0abc" {
+		t.Errorf("expected instance i-0abc, got %v", m["instance"])
+	}
+
+	if m["hash"] != "sha256:deadbeef!" {
+		t.Errorf("expected hash sha256:deadbeef!, got %v", m["hash"])
+	}
+
+	if m["port"] != "00080" {
+		t.Errorf("expected port 00080, got %v", m["port"])
+	}
+
+	if tags, ok := m["tags"].([]any); !ok || len(tags) != 2 || tags[0] != "tag-a" || tags[1] != "tag-a" {
+		t.Errorf("expected tags [tag-a tag-a], got %v", m["tags"])
+	}
+}
+
+func Test_FieldUserAgentWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "user_agent.original", Type: FieldTypeKeyword, Example: "Mozilla/5.0"},
+		{Name: "firefox", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"ua":"{{generate "user_agent.original"}}","firefox":"{{generate "firefox"}}"}`)
+	configYaml := []byte(`fields:
+  - name: firefox
+    generator: user_agent
+    user_agent:
+      browsers: {firefox: 1, safari: 1}
+      os: {linux: 1}`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 100)
+
+	var buf bytes.Buffer
+	userAgents := make(map[any]struct{})
+	for i := 0; i < 100; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		if ua, ok := m["ua"].(string); !ok || !strings.HasPrefix(ua, "Mozilla/5.0 (") {
+			t.Errorf("expected a user agent, got %v", m["ua"])
+		}
+
+		if ua, ok := m["firefox"].(string); !ok || !strings.Contains(ua, "Linux") || !strings.Contains(ua, " Firefox/") {
+			t.Errorf("expected a Firefox on Linux user agent, got %v", m["firefox"])
+		}
+
+		userAgents[m["ua"]] = struct{}{}
+	}
+
+	if len(userAgents) < 10 {
+		t.Errorf("expected at least 10 different user agents, got %d", len(userAgents))
+	}
+}
+
+func Test_FieldURLWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "url.original", Type: FieldTypeKeyword},
+		{Name: "url.path", Type: FieldTypeKeyword},
+		{Name: "url.query", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"original":"{{generate "url.original"}}","path":"{{generate "url.path"}}","query":"{{generate "url.query"}}"}`)
+	configYaml := []byte(`fields:
+  - name: url.original
+    generator: url
+    url:
+      schemes: [https]
+      domains: [shop.example.com]
+      path_depth: {min: 2, max: 2}
+      query_params: {min: 1, max: 3}
+  - name: url.path
+    generator: url
+  - name: url.query
+    generator: url`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 100)
+
+	var buf bytes.Buffer
+	for i := 0; i < 100; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		u, err := url.Parse(m["original"])
+		if err != nil {
+			t.Fatalf("expected a valid URL, got %s: %v", m["original"], err)
+		}
+
+		if u.Scheme != "https" || u.Host != "shop.example.com" || strings.Count(u.Path, "/") != 2 {
+			t.Errorf("expected an https URL of shop.example.com with 2 path segments, got %s", m["original"])
+		}
+
+		if query, err := url.ParseQuery(u.RawQuery); err != nil || len(query) < 1 || len(query) > 3 {
+			t.Errorf("expected between 1 and 3 query params, got %s", u.RawQuery)
+		}
+
+		if !strings.HasPrefix(m["path"], "/") || strings.Contains(m["path"], "?") || strings.Contains(m["path"], "://") {
+			t.Errorf("expected a path, got %s", m["path"])
+		}
+
+		if _, err := url.ParseQuery(m["query"]); err != nil || strings.HasPrefix(m["query"], "?") {
+			t.Errorf("expected a query, got %s", m["query"])
+		}
+	}
+}
+
+func Test_FieldCIDRWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "source.ip", Type: FieldTypeIP},
+		{Name: "destination.ip", Type: FieldTypeIP},
+	}
+
+	template := []byte(`{"source":"{{generate "source.ip"}}","destination":"{{generate "destination.ip"}}"}`)
+	configYaml := []byte(`fields:
+  - name: source.ip
+    cidr: [{value: 10.0.0.0/8, weight: 3}, 192.168.0.0/16]
+    exclude_reserved: true
+  - name: destination.ip
+    exclude_reserved: true
+    ipv6_fraction: 0.5`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 1000)
+
+	_, internal, _ := net.ParseCIDR("10.0.0.0/8")
+	_, home, _ := net.ParseCIDR("192.168.0.0/16")
+
+	var buf bytes.Buffer
+	var internalIPs, ipv6IPs int
+	for i := 0; i < 1000; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		source := net.ParseIP(m["source"])
+		if internal.Contains(source) {
+			internalIPs++
+		} else if !home.Contains(source) {
+			t.Errorf("expected an IP of the networks, got %s", m["source"])
+		}
+
+		destination := net.ParseIP(m["destination"])
+		if destination == nil || !destination.IsGlobalUnicast() || destination.IsPrivate() {
+			t.Errorf("expected a public IP, got %s", m["destination"])
+		}
+
+		if destination.To4() == nil {
+			ipv6IPs++
+		}
+	}
+
+	if internalIPs < 700 || internalIPs > 800 {
+		t.Errorf("expected about 750 IPs of 10.0.0.0/8, got %d", internalIPs)
+	}
+
+	if ipv6IPs < 450 || ipv6IPs > 550 {
+		t.Errorf("expected about 500 IPv6 IPs, got %d", ipv6IPs)
+	}
+}
+
+func Test_FieldSourceForWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "message", Type: FieldTypeKeyword},
+		{Name: "status", Type: FieldTypeLong},
+		{Name: "path", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"message":"{{generate "message"}}","status":{{generate "status"}},"path":"{{generate "path"}}"}`)
+	configYaml := []byte(`fields:
+  - name: message
+    enum: ["GET"]
+    source_for:
+      - field: status
+        format: 'status=%s'
+      - field: path
+  - name: status
+    range:
+      min: 200
+      max: 599
+  - name: path
+    enum: ["/a", "/b"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 10)
+
+	var buf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		expected := fmt.Sprintf("GET status=%v %v", m["status"], m["path"])
+		if m["message"] != expected {
+			t.Errorf("expected message %s, got %v", expected, m["message"])
+		}
+	}
+}
+
+func Test_FieldNormalizeWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "city", Type: FieldTypeKeyword},
+		{Name: "code", Type: FieldTypeKeyword},
+		{Name: "tags", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"city":"{{generate "city"}}","code":"{{generate "code"}}","tags":[{{range $i, $t := generate "tags"}}{{if $i}},{{end}}"{{$t}}"{{end}}]}`)
+	configYaml := []byte(`fields:
+  - name: city
+    enum: ["São Paulo"]
+    normalize:
+      case: lower
+      replace_spaces: _
+      strip_diacritics: true
+  - name: code
+    enum: [ab]
+    prefix: 'x-'
+    normalize:
+      case: upper
+  - name: tags
+    enum: ["Crème Brûlée"]
+    normalize:
+      strip_diacritics: true
+    array_length:
+      min: 2
+      max: 2`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 1)
+
+	var buf bytes.Buffer
+	if err := g.Emit(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := unmarshalJSONT[any](t, buf.Bytes())
+	if m["city"] != "sao_paulo" {
+		t.Errorf("expected city sao_paulo, got %v", m["city"])
+	}
+
+	if m["code"] != "X-AB" {
+		t.Errorf("expected code X-AB, got %v", m["code"])
+	}
+
+	if tags, ok := m["tags"].([]any); !ok || len(tags) != 2 || tags[0] != "Creme Brulee" || tags[1] != "Creme Brulee" {
+		t.Errorf("expected tags [Creme Brulee Creme Brulee], got %v", m["tags"])
+	}
+}
+
+func Test_FieldPatternWithTextTemplate(t *testing.T) {
+	saveTimeState(t)
+
+	fld := Field{
+		Name: "@timestamp",
+		Type: FieldTypeDate,
+	}
+
+	template := []byte(`{"@timestamp":"{{$t := generate "@timestamp"}}{{$t.Format "2006-01-02T15:04:05.999999Z07:00"}}"}`)
+	configYaml := []byte(`fields:
+  - name: "@timestamp"
+    range:
+      from: "2023-01-02T00:00:00.000000+00:00"
+      to: "2023-01-09T00:00:00.000000+00:00"
+    pattern:
+      peak_hours: [12]
+      peak_factor: 4
+      weekend_factor: 0.5`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 16200
+	g := makeGeneratorWithTextTemplate(t, cfg, []Field{fld}, template, uint64(nSpins))
+
+	// 2023-01-02 is a Monday: a weekday has 23 hours at volume 1 and 1 at 4, a weekend day half of it
+	hours := map[time.Weekday]map[int]int{}
+	var previous time.Time
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		ts, err := time.Parse(FieldTypeTimeLayout, m["@timestamp"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ts.Before(previous) {
+			t.Fatalf("Expected timestamps in order, got %s after %s", ts, previous)
+		}
+
+		previous = ts
+		ts = ts.UTC()
+		if hours[ts.Weekday()] == nil {
+			hours[ts.Weekday()] = map[int]int{}
+		}
+
+		hours[ts.Weekday()][ts.Hour()]++
+	}
+
+	if previous.After(time.Date(2023, 1, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected timestamps within the range, got %s", previous)
+	}
+
+	// 16200 events over 162 volume units, 100 per hour at volume 1
+	for _, expected := range []struct {
+		weekday time.Weekday
+		hour    int
+		events  int
+	}{
+		{time.Monday, 3, 100},
+		{time.Monday, 12, 400},
+		{time.Wednesday, 12, 400},
+		{time.Saturday, 3, 50},
+		{time.Sunday, 12, 200},
+	} {
+		if got := hours[expected.weekday][expected.hour]; got < expected.events-2 || got > expected.events+2 {
+			t.Errorf("Expected %d events on %s at %d, got %d", expected.events, expected.weekday, expected.hour, got)
+		}
+	}
+}
+
+func Test_FieldCounterRateWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "bytes",
+		Type: FieldTypeLong,
+	}
+
+	template := []byte(`{"bytes":"{{generate "bytes"}}"}`)
+	configYaml := []byte(`fields:
+  - name: bytes
+    counter: true
+    counter_rate:
+      min: 100
+      max: 200
+    counter_reset:
+      strategy: after_n
+      reset_after_n: 10`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 50
+	g := makeGeneratorWithTextTemplate(t, cfg, []Field{fld}, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	var previous int64
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		value, err := strconv.ParseInt(m[fld.Name], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i%10 == 0 {
+			if value != 0 {
+				t.Errorf("Expected the counter to reset to 0 at event %d, got %d", i, value)
+			}
+		} else if increment := value - previous; increment < 100 || increment > 200 {
+			t.Errorf("Expected an increment between 100 and 200 at event %d, got %d", i, increment)
+		}
+
+		previous = value
+	}
+}
+
+func Test_FieldHistogramWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "latency", Type: FieldTypeHistogram},
+		{Name: "sizes", Type: FieldTypeHistogram},
+	}
+
+	template := []byte(`{"latency":{{generate "latency"}},"sizes":{{generate "sizes"}}}`)
+	configYaml := []byte(`fields:
+  - name: latency
+    range:
+      min: 0
+      max: 100
+    distribution: normal
+    mean: 50
+    stddev: 10
+    histogram:
+      buckets: 20
+      observations: 500
+  - name: sizes`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[struct {
+			Values []float64 `json:"values"`
+			Counts []int64   `json:"counts"`
+		}](t, buf.Bytes())
+		buf.Reset()
+
+		for name, observations := range map[string]int64{"latency": 500, "sizes": 100} {
+			h := m[name]
+			if len(h.Values) == 0 || len(h.Values) != len(h.Counts) {
+				t.Fatalf("Expected as many values as counts in %s, got %v", name, h)
+			}
+
+			var total int64
+			for j, value := range h.Values {
+				if j > 0 && value <= h.Values[j-1] {
+					t.Errorf("Expected strictly increasing values in %s, got %v", name, h.Values)
+				}
+
+				if h.Counts[j] <= 0 {
+					t.Errorf("Expected positive counts in %s, got %v", name, h.Counts)
+				}
+
+				total += h.Counts[j]
+			}
+
+			if total != observations {
+				t.Errorf("Expected %d observations in %s, got %d", observations, name, total)
+			}
+		}
+
+		latency := m["latency"]
+		if len(latency.Values) > 20 || latency.Values[0] < 2.5 || latency.Values[len(latency.Values)-1] > 97.5 {
+			t.Errorf("Expected the latency buckets within the range, got %v", latency.Values)
+		}
+	}
+}
+
+func Test_FieldReuseWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "visitor", Type: FieldTypeKeyword},
+		{Name: "token", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"visitor":"{{generate "visitor"}}","token":"{{generate "token"}}"}`)
+	configYaml := []byte(`fields:
+  - name: visitor
+    reuse:
+      probability: 0.5
+      size: 10
+  - name: token
+    reuse:
+      probability: 1`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 200
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	var reused int
+	var firstToken string
+	visitors := make(map[string]struct{})
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		if _, ok := visitors[m["visitor"]]; ok {
+			reused++
+		}
+
+		visitors[m["visitor"]] = struct{}{}
+
+		if i == 0 {
+			firstToken = m["token"]
+		} else if m["token"] != firstToken {
+			t.Errorf("expected token %s to be always reused, got %s", firstToken, m["token"])
+		}
+	}
+
+	if reused < 60 || reused > 140 {
+		t.Errorf("expected about half of the visitors to be reused, got %d out of %d", reused, nSpins)
+	}
+}
+
+func Test_FieldNormalDistributionWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "latency", Type: FieldTypeLong},
+		{Name: "cpu", Type: FieldTypeDouble},
+		{Name: "level", Type: FieldTypeByte},
+	}
+
+	template := []byte(`{"latency":{{generate "latency"}},"cpu":{{generate "cpu"}},"level":{{generate "level"}}}`)
+	configYaml := []byte(`fields:
+  - name: latency
+    distribution: normal
+    mean: 100
+    stddev: 10
+  - name: cpu
+    distribution: normal
+    mean: 0.5
+    stddev: 0.2
+    range:
+      min: 0.4
+      max: 0.6
+  - name: level
+    distribution: normal
+    mean: 200
+    stddev: 50`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 2000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	var sum, sumSquares float64
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[float64](t, buf.Bytes())
+		buf.Reset()
+
+		sum += m["latency"]
+		sumSquares += m["latency"] * m["latency"]
+
+		if m["cpu"] < 0.4 || m["cpu"] > 0.6 {
+			t.Errorf("expected cpu within the range, got %v", m["cpu"])
+		}
+
+		if m["level"] > 127 {
+			t.Errorf("expected level within the bounds of byte, got %v", m["level"])
+		}
+	}
+
+	mean := sum / float64(nSpins)
+	stddev := math.Sqrt(sumSquares/float64(nSpins) - mean*mean)
+	if mean < 98 || mean > 102 || stddev < 9 || stddev > 11 {
+		t.Errorf("expected latency with mean 100 and stddev 10, got mean %v and stddev %v", mean, stddev)
+	}
+}
+
+func Test_FieldInterArrivalWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "tick", Type: FieldTypeDate},
+		{Name: "arrival", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{{$tick := generate "tick"}}{{$arrival := generate "arrival"}}{"host":"{{generate "host"}}","tick":"{{$tick.Format "2006-01-02T15:04:05.999999Z07:00"}}","arrival":"{{$arrival.Format "2006-01-02T15:04:05.999999Z07:00"}}"}`)
+	configYaml := []byte(`fields:
+  - name: host
+    enum: [a, b]
+  - name: tick
+    range:
+      from: "2023-01-01T00:00:00+00:00"
+    inter_arrival:
+      distribution: fixed
+      mean: 2s
+  - name: arrival
+    range:
+      from: "2023-01-01T00:00:00+00:00"
+    inter_arrival:
+      distribution: exponential
+      mean: 10s
+      entity: host`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1000
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := make(map[string]time.Time)
+	gaps := make(map[string]time.Duration)
+	counts := make(map[string]int)
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		tick, err := time.Parse(FieldTypeTimeLayout, m["tick"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := from.Add(time.Duration(i) * 2 * time.Second); !tick.Equal(expected) {
+			t.Errorf("expected tick %v, got %v", expected, tick)
+		}
+
+		arrival, err := time.Parse(FieldTypeTimeLayout, m["arrival"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		host := m["host"]
+		if previous, ok := last[host]; ok {
+			if arrival.Before(previous) {
+				t.Errorf("expected arrival after %v for %q, got %v", previous, host, arrival)
+			}
+
+			gaps[host] += arrival.Sub(previous)
+			counts[host]++
+		} else if !arrival.Equal(from) {
+			t.Errorf("expected the first arrival of %q at %v, got %v", host, from, arrival)
+		}
+
+		last[host] = arrival
+	}
+
+	for host, gap := range gaps {
+		if mean := gap / time.Duration(counts[host]); mean < 7*time.Second || mean > 13*time.Second {
+			t.Errorf("expected a mean time between the arrivals of %q of 10s, got %v", host, mean)
+		}
+	}
+}
+
+func Test_FieldOrderWithTextTemplate(t *testing.T) {
+	saveTimeState(t)
+
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "timestamp", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{{$timestamp := generate "timestamp"}}{"host":"{{generate "host"}}","@timestamp":"{{$timestamp.Format "2006-01-02T15:04:05.999999Z07:00"}}"}`)
+	testCases := []struct {
+		scenario string
+		config   string
+		byHost   bool
+	}{
+		{
+			scenario: "strictly increasing",
+			config:   "fields:\n  - name: host\n    enum: [a, b]\n  - name: timestamp\n    order: strictly_increasing",
+			byHost:   false,
+		},
+		{
+			scenario: "increasing per entity",
+			config:   "fields:\n  - name: host\n    enum: [a, b]\n  - name: timestamp\n    order: increasing_per_entity\n    order_entity: host",
+			byHost:   true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.scenario, func(t *testing.T) {
+			cfg, err := config.LoadConfigFromYaml([]byte(testCase.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nSpins := 256
+			g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+			last := make(map[string]time.Time)
+			var buf bytes.Buffer
+			for i := 0; i < nSpins; i++ {
+				if err := g.Emit(&buf); err != nil {
+					t.Fatal(err)
+				}
+
+				m := unmarshalJSONT[string](t, buf.Bytes())
+				buf.Reset()
+
+				ts, err := time.Parse(FieldTypeTimeLayout, m["@timestamp"])
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				key := ""
+				if testCase.byHost {
+					key = m["host"]
+				}
+
+				if previous, ok := last[key]; ok && !ts.After(previous) {
+					t.Errorf("Expected timestamp after %v for %q, got %v", previous, key, ts)
+				}
+
+				last[key] = ts
+			}
+		})
+	}
+}
+
+func Test_FieldHashWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "host.id", Type: FieldTypeKeyword},
+		{Name: "host.load", Type: FieldTypeDouble},
+	}
+
+	template := []byte(`{"host":"{{generate "host.name"}}","id":"{{generate "host.id"}}","load":{{generate "host.load"}}}`)
+	configYaml := []byte(`fields:
+  - name: host.name
+    enum: ["host-1", "host-2", "host-3"]
+  - name: host.id
+    hash_of: host.name
+  - name: host.load
+    hash_of: host.name
+    precision: 2
+    range:
+      min: 0
+      max: 1`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 256
+	values := make(map[string]map[string]any)
+	for _, randSeed := range []int64{1, 2} {
+		g, err := NewGenerator(cfg, flds, uint64(nSpins), WithTextTemplate(template), WithRandSeed(randSeed))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		for i := 0; i < nSpins; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[any](t, buf.Bytes())
+			buf.Reset()
+
+			host := m["host"].(string)
+			if previous, ok := values[host]; ok && (previous["id"] != m["id"] || previous["load"] != m["load"]) {
+				t.Errorf("Expected the values of %s to be %v, got %v with seed %d", host, previous, m, randSeed)
+			}
+
+			values[host] = m
+		}
+	}
+
+	if len(values) != 3 || values["host-1"]["id"] != fmt.Sprintf("%016x", hashOf("host.id", "host-1")) {
+		t.Errorf("Expected the id to be the hash of the entity, got %v", values)
+	}
+}
+
+func Test_FieldASNWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "destination.ip", Type: FieldTypeIP},
+		{Name: "destination.as.number", Type: FieldTypeLong},
+		{Name: "destination.as.organization.name", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{"ip":"{{generate "destination.ip"}}","number":{{generate "destination.as.number"}},"organization":"{{generate "destination.as.organization.name"}}"}`)
+	configYaml := []byte(`fields:
+  - name: destination.ip
+    cidr: [1.1.1.0/24, 2606:4700::/32]
+  - name: destination.as.number
+    asn_of: destination.ip
+  - name: destination.as.organization.name
+    asn_of: destination.ip`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		if m["number"].(float64) != 13335 || m["organization"].(string) != "CLOUDFLARENET" {
+			t.Errorf("Expected %s to belong to AS13335 CLOUDFLARENET, got AS%v %s", m["ip"], m["number"], m["organization"])
+		}
+	}
+}
+
+func Test_FieldTLSWithTextTemplate(t *testing.T) {
+	saveTimeState(t)
+
+	flds := []Field{
+		{Name: "tls.version", Type: FieldTypeKeyword},
+		{Name: "tls.curve", Type: FieldTypeKeyword},
+		{Name: "tls.client.ja3", Type: FieldTypeKeyword},
+		{Name: "tls.server.subject", Type: FieldTypeKeyword},
+		{Name: "tls.server.not_after", Type: FieldTypeDate},
+	}
+
+	template := []byte(`{{$notAfter := generate "tls.server.not_after"}}{"version":"{{generate "tls.version"}}","curve":"{{generate "tls.curve"}}","ja3":"{{generate "tls.client.ja3"}}","subject":"{{generate "tls.server.subject"}}","not_after":"{{$notAfter.Format "2006-01-02T15:04:05.999999999-07:00"}}"}`)
+	configYaml := []byte(`fields:
+  - name: tls.version
+    generator: tls
+  - name: tls.curve
+    generator: tls
+  - name: tls.client.ja3
+    generator: tls
+    tls:
+      clients: {curl: 1}
+  - name: tls.server.subject
+    generator: tls
+    tls:
+      server_names: [www.example.com]
+  - name: tls.server.not_after
+    generator: tls`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	expected := negotiateTLS(tlsClients["curl"], "www.example.com", timeNowToBind)
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+
+		// a single client and a single server always have the same handshake, with the certificate valid at the time of the generation
+		if m["version"] != expected.part(config.TLSPartVersion) || m["curve"] != expected.part(config.TLSPartCurve) || m["ja3"] != tlsClients["curl"].ja3() || m["subject"] != "CN=www.example.com" {
+			t.Errorf("Expected the handshake of curl with www.example.com, got %v", m)
+		}
+
+		notAfter, err := time.Parse(FieldTypeTimeLayout, m["not_after"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !notAfter.Equal(expected.notAfter) || !notAfter.After(timeNowToBind) {
+			t.Errorf("Expected the certificate to expire at %s, got %s", expected.notAfter, notAfter)
+		}
+	}
+}
+
+func Test_FieldTLSSettingsDifferWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "tls.version", Type: FieldTypeKeyword},
+		{Name: "tls.cipher", Type: FieldTypeKeyword},
+	}
+
+	configYaml := []byte(`fields:
+  - name: tls.version
+    generator: tls
+    tls:
+      clients: {chrome: 1}
+  - name: tls.cipher
+    generator: tls
+    tls:
+      clients: {firefox: 1}`)
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewGenerator(cfg, flds, 1, WithTextTemplate([]byte(`{{generate "tls.version"}}`)))
+	if err == nil || !strings.Contains(err.Error(), "field tls.cipher: `tls.clients` differs from the one of field tls.version") {
+		t.Errorf("Expected the different clients to be rejected, got %v", err)
+	}
+}
+
+func Test_FieldCumulativeWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host.name", Type: FieldTypeKeyword},
+		{Name: "network.in.bytes", Type: FieldTypeLong},
+		{Name: "network.in.total", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"host":"{{generate "host.name"}}","total":{{generate "network.in.total"}},"delta":{{generate "network.in.bytes"}}}`)
+	configYaml := []byte(`fields:
+  - name: host.name
+    enum: ["host-1", "host-2", "host-3"]
+  - name: network.in.bytes
+    range:
+      min: 1
+      max: 1000
+  - name: network.in.total
+    cumulative_of: network.in.bytes
+    cumulative_entity: host.name`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1024
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	totals := make(map[string]float64)
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		host := m["host"].(string)
+		totals[host] += m["delta"].(float64)
+		if totals[host] != m["total"].(float64) {
+			t.Errorf("Expected running total %v for %s, got %v", totals[host], host, m["total"])
+		}
+	}
+
+	if len(totals) != 3 {
+		t.Errorf("Expected 3 entities, got %d", len(totals))
+	}
+}
+
+func Test_ConstraintHistogramWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "le_10", Type: FieldTypeLong},
+		{Name: "le_50", Type: FieldTypeLong},
+		{Name: "le_100", Type: FieldTypeLong},
+		{Name: "le_inf", Type: FieldTypeLong},
+	}
+
+	template := []byte(`[{{generate "le_10"}},{{generate "le_50"}},{{generate "le_100"}},{{generate "le_inf"}}]`)
+	configYaml := []byte(`constraints:
+  - type: histogram
+    samples: 20
+    cumulative: true
+    range:
+      min: 0
+      max: 200
+    buckets: [10, 50, 100]
+    fields: ["le_10", "le_50", "le_100", "le_inf"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 1024
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	var buf bytes.Buffer
+	for i := 0; i < nSpins; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		var counts []int64
+		if err := json.Unmarshal(buf.Bytes(), &counts); err != nil {
+			t.Fatal(err)
+		}
+		buf.Reset()
+
+		for j := 1; j < len(counts); j++ {
+			if counts[j] < counts[j-1] {
+				t.Errorf("Expected monotonic bucket counts, got %v", counts)
+			}
+		}
+
+		if counts[len(counts)-1] != 20 {
+			t.Errorf("Expected last bucket to count all the 20 samples, got %v", counts)
+		}
+	}
+}
+
+func Test_HooksWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeLong,
+	}
+
+	template := []byte(`{"alpha":{{generate "alpha"}}}`)
+	t.Logf("with template: %s", string(template))
+
+	g, err := NewGenerator(Config{}, []Field{fld}, 0, WithTextTemplate(template),
+		WithAfterField(func(event uint64, field string, value any) (any, error) {
+			return int64(event), nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < 3; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[int64](t, buf.Bytes())
+		buf.Reset()
+
+		if m["alpha"] != int64(i) {
+			t.Errorf("Expected value replaced by the hook with %d, got %d", i, m["alpha"])
+		}
+	}
+}
+
+func Test_EventErrorsWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeKeyword,
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}"}`)
+	t.Logf("with template: %s", string(template))
+
+	fieldErr := errors.New("odd event")
+	afterField := WithAfterField(func(event uint64, field string, value any) (any, error) {
+		if event%2 == 1 {
+			return nil, fieldErr
+		}
+
+		return value, nil
+	})
+
+	var failed []*EventError
+	g, err := NewGenerator(Config{}, []Field{fld}, 6, WithTextTemplate(template), afterField,
+		WithEventErrorHandler(func(err *EventError) error {
+			failed = append(failed, err)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	var emitted int
+	for {
+		buf.Reset()
+		err := g.Emit(&buf)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		unmarshalJSONT[string](t, buf.Bytes())
+		emitted++
+	}
+
+	if emitted != 3 || len(failed) != 3 {
+		t.Fatalf("Expected 3 events emitted and 3 failed, got %d and %d", emitted, len(failed))
+	}
+
+	for i, eventErr := range failed {
+		if eventErr.Event != uint64(2*i+1) || eventErr.Field != "alpha" || !errors.Is(eventErr, fieldErr) {
+			t.Errorf("Unexpected event error %v", eventErr)
+		}
+	}
+
+	errs := make(chan *EventError, 1)
+	g, err = NewGenerator(Config{}, []Field{fld}, 2, WithTextTemplate(template), afterField, WithEventErrorHandler(EventErrorsTo(errs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := g.Emit(&buf); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+	}
+
+	if eventErr := <-errs; eventErr.Event != 1 {
+		t.Errorf("Expected event 1 failed, got %v", eventErr)
+	}
+
+	stopErr := errors.New("stop")
+	g, err = NewGenerator(Config{}, []Field{fld}, 0, WithTextTemplate(template), afterField,
+		WithEventErrorHandler(func(err *EventError) error {
+			return stopErr
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Emit(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Emit(&buf); !errors.Is(err, stopErr) {
+		t.Errorf("Expected handler error, got %v", err)
+	}
+}
+
+func Test_EmitBatchWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "host", Type: FieldTypeKeyword},
+		{Name: "sequence", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"host":"{{generate "host"}}","sequence":{{generate "sequence"}}}`)
+	configYaml := []byte(`fields:
+  - name: sequence
+    counter: true`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 5)
+
+	var buf bytes.Buffer
+	buf.WriteString("previous event\n")
+	events, err := g.EmitBatch(&buf, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+
+	var previous float64
+	for i, event := range events {
+		m := unmarshalJSONT[any](t, event)
+		sequence := m["sequence"].(float64)
+		if i > 0 && sequence < previous {
+			t.Errorf("Expected the events in order, got sequence %v after %v", sequence, previous)
+		}
+
+		previous = sequence
+	}
+
+	if !strings.HasPrefix(buf.String(), "previous event\n") || buf.Len() != len("previous event\n")+len(events[0])+len(events[1])+len(events[2]) {
+		t.Errorf("Expected the events rendered in the buffer one after the other, got %s", buf.String())
+	}
+
+	// the last events are less than asked for
+	buf.Reset()
+	events, err = g.EmitBatch(&buf, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Errorf("Expected the last 2 events, got %d", len(events))
+	}
+
+	if _, err := g.EmitBatch(&buf, 3); err != io.EOF {
+		t.Errorf("Expected io.EOF after totEvents, got %v", err)
+	}
+}
+
+func Test_EmitDocumentWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeLong,
+	}
+
+	template := []byte(`{{generate "alpha"}}`)
+	t.Logf("with template: %s", string(template))
+
+	g := makeGeneratorWithTextTemplate(t, Config{}, []Field{fld}, template, 0)
+
+	var buf bytes.Buffer
+	if _, err := g.EmitDocument(&buf); err == nil {
+		t.Errorf("Expected error for an event not being a JSON object")
+	}
+}
+
+func Test_CloneWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "alpha", Type: FieldTypeKeyword},
+		{Name: "beta", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}","beta":{{generate "beta"}}}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    enum: ["a", "b", "c", "d", "e", "f"]
+    cardinality: 3
+  - name: beta
+    counter: true`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSpins := 100
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, uint64(nSpins))
+
+	// clones with the same seed generate the same events, independently
+	clones := []Generator{g.Clone(1), g.Clone(1)}
+	outputs := make([]bytes.Buffer, len(clones))
+
+	var wg sync.WaitGroup
+	for i := range clones {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < nSpins; j++ {
+				if err := clones[i].Emit(&outputs[i]); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+
+			if err := clones[i].Emit(&outputs[i]); err != io.EOF {
+				t.Errorf("Expected io.EOF after totEvents, got %v", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if outputs[0].Len() == 0 || outputs[0].String() != outputs[1].String() {
+		t.Errorf("Expected the same events from clones with the same seed")
+	}
+}
+
+func Test_TimelineWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeKeyword,
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}"}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    enum: ["normal"]
+timeline:
+  - at_event: 5
+    fields:
+      - name: alpha
+        enum: ["anomaly"]
+  - at_event: 10
+    fields:
+      - name: alpha
+        enum: ["recovered"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, []Field{fld}, template, 0)
+
+	emitAll := func(g Generator) []string {
+		var values []string
+		var buf bytes.Buffer
+		for i := 0; i < 15; i++ {
+			if err := g.Emit(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			m := unmarshalJSONT[string](t, buf.Bytes())
+			buf.Reset()
+			values = append(values, m["alpha"])
+		}
+
+		return values
+	}
+
+	values := emitAll(g)
+	for i, value := range values {
+		expected := "normal"
+		switch {
+		case i >= 10:
+			expected = "recovered"
+		case i >= 5:
+			expected = "anomaly"
+		}
+
+		if value != expected {
+			t.Errorf("Expected %s at event %d, got %s", expected, i, value)
+		}
+	}
+
+	// a clone starts from the beginning of the timeline
+	if cloneValues := emitAll(g.Clone(1)); strings.Join(cloneValues, ",") != strings.Join(values, ",") {
+		t.Errorf("Expected clone to replay the timeline, got %v", cloneValues)
+	}
+}
+
+func Test_TimelineAtWithTextTemplate(t *testing.T) {
+	fld := Field{
+		Name: "alpha",
+		Type: FieldTypeKeyword,
+	}
+
+	template := []byte(`{"alpha":"{{generate "alpha"}}"}`)
+	configYaml := []byte(`fields:
+  - name: alpha
+    enum: ["normal"]
+timeline:
+  - at: 1m
+    set:
+      eps: 2x
+    fields:
+      - name: alpha
+        enum: ["anomaly"]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var applied []uint64
+	g, err := NewGenerator(cfg, []Field{fld}, 0, WithTextTemplate(template), WithTimelineStep(func(event uint64, step TimelineStep) error {
+		if factor, ok := step.Set.EPSFactor(); !ok || factor != 2 {
+			t.Errorf("Expected the step setting eps to 2x, got %v", factor)
+		}
+
+		applied = append(applied, event)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fake clock advancing by 10 seconds per event
+	clock := time.Unix(0, 0)
+	g.(*GeneratorWithTextTemplate).timeline.now = func() time.Time {
+		return clock
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[string](t, buf.Bytes())
+		buf.Reset()
+		clock = clock.Add(10 * time.Second)
+
+		expected := "normal"
+		if i >= 6 {
+			expected = "anomaly"
+		}
+
+		if m["alpha"] != expected {
+			t.Errorf("Expected %s at event %d, got %s", expected, i, m["alpha"])
+		}
+	}
+
+	if len(applied) != 1 || applied[0] != 6 {
+		t.Errorf("Expected the step applied once before event 6, got %v", applied)
+	}
+}
+
+func Test_FieldArrayWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "answers", Type: FieldTypeKeyword},
+		{Name: "ttl", Type: FieldTypeLong},
+	}
+
+	template := []byte(`{{$answers := generate "answers"}}{"answers":{{toJson $answers}},"looped":[{{range $i, $answer := $answers}}{{if $i}},{{end}}"{{$answer}}"{{end}}],"ttl":{{generate "ttl" | toJson}}}`)
+	configYaml := []byte(`fields:
+  - name: answers
+    enum: ["a", "b", "c"]
+    array_length:
+      min: 1
+      max: 3
+  - name: ttl
+    range:
+      min: 1
+      max: 10
+    array_length:
+      min: 2
+      max: 2`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 10)
+
+	var buf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[[]any](t, buf.Bytes())
+		buf.Reset()
+
+		if len(m["answers"]) < 1 || len(m["answers"]) > 3 {
+			t.Errorf("expected 1 to 3 answers, got %v", m["answers"])
+		}
+
+		if len(m["looped"]) != len(m["answers"]) {
+			t.Errorf("expected a looped value for each answer, got %v and %v", m["looped"], m["answers"])
+		}
+
+		for j, answer := range m["answers"] {
+			if answer != "a" && answer != "b" && answer != "c" {
+				t.Errorf("unexpected answer %v", answer)
+			}
+
+			if m["looped"][j] != answer {
+				t.Errorf("expected looped value %v, got %v", answer, m["looped"][j])
+			}
+		}
+
+		if len(m["ttl"]) != 2 {
+			t.Errorf("expected 2 ttl, got %v", m["ttl"])
+		}
+
+		for _, ttl := range m["ttl"] {
+			if v, ok := ttl.(float64); !ok || v < 1 || v > 10 {
+				t.Errorf("unexpected ttl %v", ttl)
+			}
+		}
+	}
+}
+
+func Test_FieldArrayDistinctWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "tags", Type: FieldTypeKeyword},
+		{Name: "source.ip", Type: FieldTypeIP},
+		{Name: "destination.ip", Type: FieldTypeIP},
+		{Name: "related.ip", Type: FieldTypeIP},
+	}
+
+	template := []byte(`{"tags":{{generate "tags" | toJson}},"source":"{{generate "source.ip"}}","destination":"{{generate "destination.ip"}}","related":{{generate "related.ip" | toJson}}}`)
+	configYaml := []byte(`fields:
+  - name: tags
+    enum: [{value: "a", weight: 10}, "b", "c"]
+    array_length:
+      min: 3
+      max: 3
+      distinct: true
+  - name: source.ip
+    enum: ["10.0.0.1", "10.0.0.2"]
+  - name: destination.ip
+    enum: ["10.0.0.1", "10.0.0.2"]
+  - name: related.ip
+    array_length:
+      min: 2
+      max: 4
+      values_from: [source.ip, destination.ip]`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 20)
+
+	var buf bytes.Buffer
+	for i := 0; i < 20; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[any](t, buf.Bytes())
+		buf.Reset()
+
+		tags := m["tags"].([]any)
+		if len(tags) != 3 || tags[0] == tags[1] || tags[0] == tags[2] || tags[1] == tags[2] {
+			t.Errorf("expected 3 distinct tags, got %v", tags)
+		}
+
+		related := m["related"].([]any)
+		if len(related) < 2 || len(related) > 4 {
+			t.Errorf("expected 2 to 4 related ips, got %v", related)
+		}
+
+		seen := make(map[any]struct{})
+		for _, ip := range related {
+			if _, ok := seen[ip]; ok {
+				t.Errorf("duplicate related ip %v in %v", ip, related)
+			}
+
+			seen[ip] = struct{}{}
+		}
+
+		if _, ok := seen[m["source"]]; !ok {
+			t.Errorf("expected source ip %v in %v", m["source"], related)
+		}
+
+		if _, ok := seen[m["destination"]]; !ok {
+			t.Errorf("expected destination ip %v in %v", m["destination"], related)
+		}
+
+		if related[0] != m["source"] {
+			t.Errorf("expected related ips to start with the source ip %v, got %v", m["source"], related)
+		}
+	}
+}
+
+func Test_FieldArrayGroupWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "dns.answers", Type: FieldTypeNested},
+		{Name: "dns.answers.data", Type: FieldTypeKeyword},
+		{Name: "dns.answers.ttl", Type: FieldTypeLong},
+		{Name: "dns.answers.ips", Type: FieldTypeIP},
+		{Name: "dns.question.name", Type: FieldTypeKeyword},
+	}
+
+	template := []byte(`{{$answers := generate "dns.answers"}}{"dns.answers":{{toJson $answers}},"looped":[{{range $i, $answer := $answers}}{{if $i}},{{end}}"{{index $answer "data"}}"{{end}}]}`)
+	configYaml := []byte(`fields:
+  - name: dns.answers
+    array_length:
+      min: 1
+      max: 3
+  - name: dns.answers.data
+    enum: ["a", "b", "c"]
+  - name: dns.answers.ttl
+    range:
+      min: 1
+      max: 10
+  - name: dns.answers.ips
+    array_length:
+      min: 2
+      max: 2`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 10)
+
+	var buf bytes.Buffer
+	for i := 0; i < 10; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[[]any](t, buf.Bytes())
+		buf.Reset()
+
+		answers := m["dns.answers"]
+		if len(answers) < 1 || len(answers) > 3 {
+			t.Fatalf("expected 1 to 3 answers, got %v", answers)
+		}
+
+		if len(m["looped"]) != len(answers) {
+			t.Errorf("expected a looped value for each answer, got %v and %v", m["looped"], answers)
+		}
+
+		for j, answer := range answers {
+			a, ok := answer.(map[string]any)
+			if !ok || len(a) != 3 {
+				t.Fatalf("expected an object with data, ttl and ips, got %v", answer)
+			}
+
+			if a["data"] != "a" && a["data"] != "b" && a["data"] != "c" {
+				t.Errorf("unexpected data %v", a["data"])
+			}
+
+			if m["looped"][j] != a["data"] {
+				t.Errorf("expected looped value %v, got %v", a["data"], m["looped"][j])
+			}
+
+			if v, ok := a["ttl"].(float64); !ok || v < 1 || v > 10 {
+				t.Errorf("unexpected ttl %v", a["ttl"])
+			}
+
+			if ips, ok := a["ips"].([]any); !ok || len(ips) != 2 {
+				t.Errorf("expected 2 ips, got %v", a["ips"])
+			}
+		}
+	}
+}
+
+func Test_FieldDynamicKeysWithTextTemplate(t *testing.T) {
+	flds := []Field{
+		{Name: "prometheus.labels.*", Type: FieldTypeObject, ObjectType: FieldTypeWildcard},
+		{Name: "prometheus.metrics.*", Type: FieldTypeObject, ObjectType: FieldTypeDouble},
+	}
+
+	template := []byte(`{"prometheus.labels":{{generate "prometheus.labels" | toJson}},"prometheus.metrics":{{generate "prometheus.metrics" | toJson}}}`)
+	configYaml := []byte(`fields:
+  - name: prometheus.labels.*
+    enum: ["a", "b"]
+    dynamic_keys:
+      max: 3
+  - name: prometheus.metrics.*
+    range:
+      min: 1
+      max: 100
+    dynamic_keys:
+      min: 2
+      max: 4
+      cardinality: 10`)
+	t.Logf("with template: %s", string(template))
+
+	cfg, err := config.LoadConfigFromYaml(configYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := makeGeneratorWithTextTemplate(t, cfg, flds, template, 20)
+
+	metricNames := make(map[string]struct{})
+	var buf bytes.Buffer
+	for i := 0; i < 20; i++ {
+		if err := g.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m := unmarshalJSONT[map[string]any](t, buf.Bytes())
+		buf.Reset()
+
+		if len(m["prometheus.labels"]) > 3 {
+			t.Errorf("expected at most 3 labels, got %v", m["prometheus.labels"])
+		}
+
+		for _, label := range m["prometheus.labels"] {
+			if label != "a" && label != "b" {
+				t.Errorf("unexpected label %v", label)
+			}
+		}
+
+		if len(m["prometheus.metrics"]) < 2 || len(m["prometheus.metrics"]) > 4 {
+			t.Errorf("expected 2 to 4 metrics, got %v", m["prometheus.metrics"])
+		}
+
+		for name, metric := range m["prometheus.metrics"] {
+			metricNames[name] = struct{}{}
+			if v, ok := metric.(float64); !ok || v < 1 || v > 100 {
+				t.Errorf("unexpected metric %s: %v", name, metric)
+			}
+		}
+	}
+
+	if len(metricNames) > 10 {
+		t.Errorf("expected at most 10 metric names, got %v", metricNames)
+	}
+}
+
 func Test_FieldFloatsWithTextTemplate(t *testing.T) {
 	_testNumericWithTextTemplate[float64](t, FieldTypeDouble)
 	_testNumericWithTextTemplate[float32](t, FieldTypeFloat)
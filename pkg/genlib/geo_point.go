@@ -0,0 +1,188 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+const (
+	// geoPointDecimals are the decimals of the coordinates when `precision` is not set, about 10cm
+	geoPointDecimals = 6
+	// geohashLength is the length of the geohashes, the maximum precision of Elasticsearch
+	geohashLength = 12
+	geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// geoPoint is the value of a `geo_point` field with `geo_format: object` returned to the text template:
+// it's written as a JSON object, and its coordinates can be accessed as `.Lat` and `.Lon`.
+type geoPoint struct {
+	Lat      float64
+	Lon      float64
+	decimals int
+}
+
+func (p geoPoint) String() string {
+	return `{"lat":` + strconv.FormatFloat(p.Lat, 'f', p.decimals, 64) + `,"lon":` + strconv.FormatFloat(p.Lon, 'f', p.decimals, 64) + `}`
+}
+
+// makeGeoPointFunc returns the latitude and the longitude of points uniformly distributed on the surface of
+// the Earth within the `geo_bbox` of the field, the whole globe when not set, so that the points are not
+// packed at the poles.
+func makeGeoPointFunc(fieldCfg ConfigField) func(r *rand.Rand) (float64, float64) {
+	minLat, maxLat, minLon, lonSpan := -90.0, 90.0, -180.0, 360.0
+	if bbox := fieldCfg.GeoBBox; bbox != nil {
+		minLat, maxLat, minLon = bbox.BottomRight.Lat, bbox.TopLeft.Lat, bbox.TopLeft.Lon
+
+		// the box crosses the antimeridian when the left longitude is greater than the right one
+		lonSpan = bbox.BottomRight.Lon - bbox.TopLeft.Lon
+		if lonSpan < 0 {
+			lonSpan += 360
+		}
+	}
+
+	toRadians := math.Pi / 180
+	sinMinLat, sinMaxLat := math.Sin(minLat*toRadians), math.Sin(maxLat*toRadians)
+
+	return func(r *rand.Rand) (float64, float64) {
+		lat := math.Asin(sinMinLat+r.Float64()*(sinMaxLat-sinMinLat)) / toRadians
+
+		lon := minLon + r.Float64()*lonSpan
+		if lon > 180 {
+			lon -= 360
+		}
+
+		return lat, lon
+	}
+}
+
+// geohash returns the geohash of the point with geohashLength characters
+func geohash(lat, lon float64) string {
+	minLat, maxLat, minLon, maxLon := -90.0, 90.0, -180.0, 180.0
+
+	hash := make([]byte, 0, geohashLength)
+	var bits, idx int
+	// the bits alternate between the longitude and the latitude, starting from the longitude
+	even := true
+	for len(hash) < geohashLength {
+		if even {
+			mid := (minLon + maxLon) / 2
+			if lon >= mid {
+				idx = idx<<1 | 1
+				minLon = mid
+			} else {
+				idx = idx << 1
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				idx = idx<<1 | 1
+				minLat = mid
+			} else {
+				idx = idx << 1
+				maxLat = mid
+			}
+		}
+
+		even = !even
+		bits++
+		if bits == 5 {
+			hash = append(hash, geohashBase32[idx])
+			bits, idx = 0, 0
+		}
+	}
+
+	return string(hash)
+}
+
+func geoPointDecimalsOf(fieldCfg ConfigField) int {
+	if fieldCfg.Precision != nil {
+		return *fieldCfg.Precision
+	}
+
+	return geoPointDecimals
+}
+
+// writeGeoPoint writes the point to buf in the `geo_format` of the field
+func writeGeoPoint(buf *bytes.Buffer, lat, lon float64, format string, decimals int) {
+	switch format {
+	case config.GeoFormatObject:
+		buf.WriteString(geoPoint{Lat: lat, Lon: lon, decimals: decimals}.String())
+	case config.GeoFormatGeohash:
+		buf.WriteString(geohash(lat, lon))
+	default:
+		buf.WriteString(strconv.FormatFloat(lat, 'f', decimals, 64))
+		buf.WriteByte(',')
+		buf.WriteString(strconv.FormatFloat(lon, 'f', decimals, 64))
+	}
+}
+
+// geoPointValue returns the point in the `geo_format` of the field, as returned to the text template
+func geoPointValue(lat, lon float64, format string, decimals int) any {
+	switch format {
+	case config.GeoFormatObject:
+		return geoPoint{Lat: lat, Lon: lon, decimals: decimals}
+	case config.GeoFormatGeohash:
+		return geohash(lat, lon)
+	default:
+		return strconv.FormatFloat(lat, 'f', decimals, 64) + "," + strconv.FormatFloat(lon, 'f', decimals, 64)
+	}
+}
+
+func bindGeoPoint(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	if err := validGeoPoint(fieldCfg, field); err != nil {
+		return err
+	}
+
+	decimals := geoPointDecimalsOf(fieldCfg)
+	geoPointF := makeGeoPointFunc(fieldCfg)
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		lat, lon := geoPointF(state.rand)
+		writeGeoPoint(buf, lat, lon, fieldCfg.GeoFormat, decimals)
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
+
+func bindGeoPointWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	if err := validGeoPoint(fieldCfg, field); err != nil {
+		return err
+	}
+
+	decimals := geoPointDecimalsOf(fieldCfg)
+	geoPointF := makeGeoPointFunc(fieldCfg)
+
+	var emitF emitF
+	emitF = func(state *genState) any {
+		lat, lon := geoPointF(state.rand)
+		return geoPointValue(lat, lon, fieldCfg.GeoFormat, decimals)
+	}
+
+	fieldMap[field.Name] = emitF
+	return nil
+}
+
+func validGeoPoint(fieldCfg ConfigField, field Field) error {
+	if err := fieldCfg.ValidGeoPoint(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if err := fieldCfg.ValidatePrecision(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	return nil
+}
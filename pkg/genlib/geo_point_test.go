@@ -0,0 +1,61 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeohash(t *testing.T) {
+	testCases := []struct {
+		lat, lon float64
+		expected string
+	}{
+		{lat: 57.64911, lon: 10.40744, expected: "u4pruydqqvj8"},
+		{lat: 0, lon: 0, expected: "s00000000000"},
+		{lat: -90, lon: -180, expected: "000000000000"},
+	}
+
+	for _, testCase := range testCases {
+		if got := geohash(testCase.lat, testCase.lon); got != testCase.expected {
+			t.Errorf("Expected geohash %s for %v,%v, got %s", testCase.expected, testCase.lat, testCase.lon, got)
+		}
+	}
+}
+
+// haversineKm returns the great circle distance between two points, in km
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := math.Pi / 180
+	dLat, dLon := (lat2-lat1)*toRadians, (lon2-lon1)*toRadians
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1*toRadians)*math.Cos(lat2*toRadians)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+func TestGeoDestination(t *testing.T) {
+	testCases := []struct {
+		lat, lon, heading, distance float64
+	}{
+		{lat: 48.856614, lon: 2.352222, heading: 90, distance: 10},
+		{lat: 0, lon: 179.99, heading: 90, distance: 5},
+		{lat: -33.86, lon: 151.2, heading: 225, distance: 120},
+	}
+
+	for _, testCase := range testCases {
+		lat, lon := geoDestination(testCase.lat, testCase.lon, testCase.heading, testCase.distance)
+		if lon < -180 || lon >= 180 {
+			t.Errorf("Expected a longitude between -180 and 180, got %v", lon)
+		}
+
+		if got := haversineKm(testCase.lat, testCase.lon, lat, lon); math.Abs(got-testCase.distance) > 1e-6 {
+			t.Errorf("Expected the destination %v km away from %v,%v, got %v km", testCase.distance, testCase.lat, testCase.lon, got)
+		}
+	}
+
+	// going east across the antimeridian
+	if _, lon := geoDestination(0, 179.99, 90, 5); lon > -179 {
+		t.Errorf("Expected the destination across the antimeridian, got longitude %v", lon)
+	}
+}
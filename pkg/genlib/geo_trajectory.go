@@ -0,0 +1,257 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+const (
+	// earthRadiusKm is the mean radius of the Earth
+	earthRadiusKm = 6371.0088
+	// geoTrajectoryStep is the time between the points of an entity when the trajectory has no `timestamp`
+	geoTrajectoryStep = time.Minute
+)
+
+// trajectoryPosition is the last point of the trajectory of an entity, with the heading, in degrees clockwise
+// from north, and the speed, in km/h, it's moving with.
+type trajectoryPosition struct {
+	Lat     float64
+	Lon     float64
+	Heading float64
+	Speed   float64
+	Time    time.Time
+}
+
+// geoTrajectories holds the last positions of a `geo_trajectory` field, by entity
+type geoTrajectories struct {
+	positions map[string]trajectoryPosition
+	// the position of the entity of the last event the field was generated in
+	initialised bool
+	counter     uint64
+	current     trajectoryPosition
+}
+
+// bindGeoTrajectoryFields replaces the emit functions of the `geo_point` fields with `geo_trajectory`, so that
+// the point of an entity moves from its previous one instead of being drawn anew at each event.
+func bindGeoTrajectoryFields(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	fieldsByName := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		fieldsByName[field.Name] = field
+	}
+
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok || fieldCfg.GeoTrajectory == nil {
+			continue
+		}
+
+		if field.Type != FieldTypeGeoPoint {
+			return fmt.Errorf("field %s: `geo_trajectory` requires the %s field type", field.Name, FieldTypeGeoPoint)
+		}
+
+		trajectory := *fieldCfg.GeoTrajectory
+
+		entityF := func(state *genState) (any, error) {
+			return "", nil
+		}
+
+		if len(trajectory.Entity) > 0 {
+			if _, ok := fieldMap[trajectory.Entity]; !ok {
+				return fmt.Errorf("field %s: entity field %s not present in fields definition", field.Name, trajectory.Entity)
+			}
+
+			var err error
+			entityF, err = bindEventRawValue(trajectory.Entity, fieldMap, withReturn)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+
+		var timestampF func(state *genState) (any, error)
+		if len(trajectory.Timestamp) > 0 {
+			timestampField, ok := fieldsByName[trajectory.Timestamp]
+			if _, bound := fieldMap[trajectory.Timestamp]; !ok || !bound {
+				return fmt.Errorf("field %s: timestamp field %s not present in fields definition", field.Name, trajectory.Timestamp)
+			}
+
+			if timestampField.Type != FieldTypeDate {
+				return fmt.Errorf("field %s: timestamp field %s must have the %s type", field.Name, timestampField.Name, FieldTypeDate)
+			}
+
+			var err error
+			timestampF, err = bindEventRawValue(trajectory.Timestamp, fieldMap, withReturn)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+
+		positionF := makeGeoTrajectoryFunc(field.Name, fieldCfg, entityF, timestampF)
+		decimals := geoPointDecimalsOf(fieldCfg)
+		if withReturn {
+			var emitF emitF
+			emitF = func(state *genState) any {
+				position, err := positionF(state)
+				if err != nil {
+					panic(err)
+				}
+
+				return geoPointValue(position.Lat, position.Lon, fieldCfg.GeoFormat, decimals)
+			}
+
+			fieldMap[field.Name] = emitF
+		} else {
+			var emitFNotReturn emitFNotReturn
+			emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+				position, err := positionF(state)
+				if err != nil {
+					return err
+				}
+
+				writeGeoPoint(buf, position.Lat, position.Lon, fieldCfg.GeoFormat, decimals)
+				return nil
+			}
+
+			fieldMap[field.Name] = emitFNotReturn
+		}
+	}
+
+	return nil
+}
+
+// makeGeoTrajectoryFunc moves the entity of the current event from its last position, once per event, and returns
+// the new position. The first position of an entity is drawn like the points without `geo_trajectory`.
+func makeGeoTrajectoryFunc(fieldName string, fieldCfg ConfigField, entityF, timestampF func(state *genState) (any, error)) func(state *genState) (trajectoryPosition, error) {
+	trajectory := *fieldCfg.GeoTrajectory
+	maxTurn := trajectory.MaxTurnOrDefault()
+	geoPointF := makeGeoPointFunc(fieldCfg)
+
+	return func(state *genState) (trajectoryPosition, error) {
+		trajectories, ok := state.prevCacheGeoTrajectory[fieldName]
+		if !ok {
+			trajectories = &geoTrajectories{positions: make(map[string]trajectoryPosition)}
+			state.prevCacheGeoTrajectory[fieldName] = trajectories
+		}
+
+		if trajectories.initialised && trajectories.counter == state.counter {
+			return trajectories.current, nil
+		}
+
+		entity, err := entityF(state)
+		if err != nil {
+			return trajectoryPosition{}, err
+		}
+
+		var t time.Time
+		if timestampF != nil {
+			if t, err = eventTime(state, timestampF); err != nil {
+				return trajectoryPosition{}, err
+			}
+		}
+
+		key := fmt.Sprint(entity)
+		position, ok := trajectories.positions[key]
+		switch {
+		case !ok:
+			position.Lat, position.Lon = geoPointF(state.rand)
+			position.Heading = state.rand.Float64() * 360
+			position.Speed = state.rand.Float64() * trajectory.MaxSpeed
+			position.Time = t
+		case timestampF == nil:
+			position = position.move(state.rand, geoTrajectoryStep, trajectory.MaxSpeed, maxTurn, fieldCfg.GeoBBox)
+		default:
+			position = position.move(state.rand, t.Sub(position.Time), trajectory.MaxSpeed, maxTurn, fieldCfg.GeoBBox)
+			position.Time = t
+		}
+
+		trajectories.positions[key] = position
+
+		trajectories.initialised = true
+		trajectories.counter = state.counter
+		trajectories.current = position
+		return position, nil
+	}
+}
+
+// eventTime returns the value of the date field of the current event
+func eventTime(state *genState, timestampF func(state *genState) (any, error)) (time.Time, error) {
+	value, err := timestampF(state)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(FieldTypeTimeLayout, v)
+	default:
+		return time.Time{}, fmt.Errorf("cannot use %v as timestamp", value)
+	}
+}
+
+// move returns the position reached after elapsed, once the heading is changed by up to maxTurn degrees and the
+// speed by a tenth of maxSpeed on average. At the border of the bounding box the entity turns back; when it cannot
+// move within the box in either direction, it waits where it is. A negative elapsed, as when the timestamps are not
+// ordered, doesn't move the entity.
+func (p trajectoryPosition) move(r *rand.Rand, elapsed time.Duration, maxSpeed, maxTurn float64, bbox *config.GeoBBox) trajectoryPosition {
+	p.Heading = math.Mod(p.Heading+(2*r.Float64()-1)*maxTurn+360, 360)
+	p.Speed = math.Max(0, math.Min(maxSpeed, p.Speed+r.NormFloat64()*maxSpeed/10))
+
+	if elapsed <= 0 {
+		return p
+	}
+
+	distance := p.Speed * elapsed.Hours()
+	for _, heading := range []float64{p.Heading, math.Mod(p.Heading+180, 360)} {
+		lat, lon := geoDestination(p.Lat, p.Lon, heading, distance)
+		if inGeoBBox(lat, lon, bbox) {
+			p.Lat, p.Lon, p.Heading = lat, lon, heading
+			return p
+		}
+	}
+
+	p.Heading = math.Mod(p.Heading+180, 360)
+	return p
+}
+
+// geoDestination returns the point reached from lat and lon going for distance km along the great circle with
+// the initial heading, in degrees clockwise from north
+func geoDestination(lat, lon, heading, distance float64) (float64, float64) {
+	toRadians := math.Pi / 180
+	phi, lambda, theta := lat*toRadians, lon*toRadians, heading*toRadians
+	delta := distance / earthRadiusKm
+
+	phi2 := math.Asin(math.Sin(phi)*math.Cos(delta) + math.Cos(phi)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi), math.Cos(delta)-math.Sin(phi)*math.Sin(phi2))
+
+	lon2 := math.Mod(lambda2/toRadians+540, 360) - 180
+	return phi2 / toRadians, lon2
+}
+
+// inGeoBBox returns whether the point is within the bounding box, always when the box is not set
+func inGeoBBox(lat, lon float64, bbox *config.GeoBBox) bool {
+	if bbox == nil {
+		return true
+	}
+
+	if lat < bbox.BottomRight.Lat || lat > bbox.TopLeft.Lat {
+		return false
+	}
+
+	left, right := bbox.TopLeft.Lon, bbox.BottomRight.Lon
+	if left <= right {
+		return lon >= left && lon <= right
+	}
+
+	// the box crosses the antimeridian
+	return lon >= left || lon <= right
+}
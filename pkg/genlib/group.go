@@ -0,0 +1,271 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// fieldTypeGroup is the type of the groups of fields in the fields definition
+const fieldTypeGroup = "group"
+
+var arrayGroupInvalidConfig = errors.New("`array_length.values_from` and `array_length.distinct` are not supported for a group of fields")
+
+// arrayGroup is a group of fields with `array_length`, generated as an array of objects holding its fields
+type arrayGroup struct {
+	name string
+	// members are the fields of the objects, with the groups with `array_length` nested in the group
+	members []Field
+}
+
+// groupNames returns the names of the groups of the fields, the prefixes of their dotted names
+func groupNames(fields Fields) map[string]struct{} {
+	names := make(map[string]struct{})
+	for _, field := range fields {
+		for i := 0; i < len(field.Name); i++ {
+			if field.Name[i] == '.' {
+				names[field.Name[:i]] = struct{}{}
+			}
+		}
+	}
+
+	return names
+}
+
+// isGroupField reports whether the field is the group of other fields, like a `nested` group of the fields definition
+func isGroupField(field Field, names map[string]struct{}) bool {
+	switch field.Type {
+	case FieldTypeObject, FieldTypeNested, fieldTypeGroup:
+		_, ok := names[field.Name]
+		return ok
+	default:
+		return false
+	}
+}
+
+// isArrayGroup reports whether name is a group of fields with `array_length`
+func isArrayGroup(cfg Config, name string, names map[string]struct{}) bool {
+	if _, ok := names[name]; !ok {
+		return false
+	}
+
+	fieldCfg, ok := cfg.GetField(name)
+	return ok && fieldCfg.ArrayLength != nil
+}
+
+// enclosingArrayGroup returns the innermost group with `array_length` the field belongs to, if any
+func enclosingArrayGroup(cfg Config, name string, names map[string]struct{}) (string, bool) {
+	for i := len(name) - 1; i > 0; i-- {
+		if name[i] == '.' && isArrayGroup(cfg, name[:i], names) {
+			return name[:i], true
+		}
+	}
+
+	return "", false
+}
+
+// eventFields returns the fields as they are written at the root of an event: the fields of a group with
+// `array_length` are replaced by the group, and the groups without it are left out, their fields being written
+// with their dotted names as usual.
+func eventFields(cfg Config, fields Fields, names map[string]struct{}) Fields {
+	rootFields := make(Fields, 0, len(fields))
+	written := make(map[string]struct{})
+	for _, field := range fields {
+		if isGroupField(field, names) {
+			continue
+		}
+
+		group, ok := "", false
+		for i := 0; i < len(field.Name); i++ {
+			if field.Name[i] == '.' && isArrayGroup(cfg, field.Name[:i], names) {
+				group, ok = field.Name[:i], true
+				break
+			}
+		}
+
+		if !ok {
+			rootFields = append(rootFields, field)
+			continue
+		}
+
+		if _, ok := written[group]; ok {
+			continue
+		}
+
+		written[group] = struct{}{}
+		rootFields = append(rootFields, Field{Name: group, Type: FieldTypeNested})
+	}
+
+	return rootFields
+}
+
+// arrayGroups returns the groups of the fields with `array_length`, the innermost first
+func arrayGroups(cfg Config, fields Fields, names map[string]struct{}) []arrayGroup {
+	members := make(map[string][]Field)
+	added := make(map[string]struct{})
+	for _, field := range fields {
+		if isGroupField(field, names) {
+			continue
+		}
+
+		// the nested groups are members of their enclosing group, in the position of their first field
+		member := field
+		for {
+			group, ok := enclosingArrayGroup(cfg, member.Name, names)
+			if !ok {
+				break
+			}
+
+			if _, ok := added[member.Name]; ok {
+				break
+			}
+
+			added[member.Name] = struct{}{}
+			members[group] = append(members[group], member)
+			member = Field{Name: group, Type: FieldTypeNested}
+		}
+	}
+
+	groups := make([]arrayGroup, 0, len(members))
+	for name, groupMembers := range members {
+		groups = append(groups, arrayGroup{name: name, members: groupMembers})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		depthI, depthJ := strings.Count(groups[i].name, "."), strings.Count(groups[j].name, ".")
+		if depthI != depthJ {
+			return depthI > depthJ
+		}
+
+		return groups[i].name < groups[j].name
+	})
+
+	return groups
+}
+
+// bindArrayGroups binds the groups of fields with `array_length`, like `dns.answers`, so that they generate an
+// array of objects: each object holds a value of each field of the group, its name relative to the group.
+// The fields of the group are bound as usual, and the objects are generated with their emit functions.
+func bindArrayGroups(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	names := groupNames(fields)
+	for _, group := range arrayGroups(cfg, fields, names) {
+		fieldCfg, _ := cfg.GetField(group.name)
+		if err := fieldCfg.ValidArrayLength(); err != nil {
+			return fmt.Errorf("field %s: %w", group.name, err)
+		}
+
+		if len(fieldCfg.ArrayLength.ValuesFrom) > 0 || fieldCfg.ArrayLength.Distinct {
+			return fmt.Errorf("field %s: %w", group.name, arrayGroupInvalidConfig)
+		}
+
+		var err error
+		if withReturn {
+			err = bindArrayGroupWithReturn(fieldCfg, group, fieldMap)
+		} else {
+			err = bindArrayGroup(cfg, fieldCfg, group, names, fieldMap)
+		}
+
+		if err != nil {
+			return fmt.Errorf("field %s: %w", group.name, err)
+		}
+	}
+
+	return nil
+}
+
+func bindArrayGroup(cfg Config, fieldCfg ConfigField, group arrayGroup, names map[string]struct{}, fieldMap map[string]any) error {
+	memberFs := make([]emitFNotReturn, 0, len(group.members))
+	prefixes := make([][]byte, 0, len(group.members))
+	wraps := make([][]byte, 0, len(group.members))
+	for i, member := range group.members {
+		memberF, ok := fieldMap[member.Name].(emitFNotReturn)
+		if !ok {
+			return fmt.Errorf("field %s of the group is not bound", member.Name)
+		}
+
+		// arrays and nested groups are written as JSON
+		wrap := templateFieldWrap(cfg, member)
+		if memberCfg, _ := cfg.GetField(member.Name); memberCfg.ArrayLength != nil || isArrayGroup(cfg, member.Name, names) {
+			wrap = ""
+		}
+
+		prefix := fmt.Sprintf(`"%s":%s`, strings.TrimPrefix(member.Name, group.name+"."), wrap)
+		if i > 0 {
+			prefix = "," + prefix
+		}
+
+		memberFs = append(memberFs, memberF)
+		prefixes = append(prefixes, []byte(prefix))
+		wraps = append(wraps, []byte(wrap))
+	}
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		n := arrayLength(fieldCfg, state)
+
+		buf.WriteByte('[')
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			buf.WriteByte('{')
+			for j, memberF := range memberFs {
+				buf.Write(prefixes[j])
+				if err := memberF(state, buf); err != nil {
+					return err
+				}
+
+				buf.Write(wraps[j])
+			}
+
+			buf.WriteByte('}')
+		}
+
+		buf.WriteByte(']')
+		return nil
+	}
+
+	fieldMap[group.name] = emitFNotReturn
+	return nil
+}
+
+func bindArrayGroupWithReturn(fieldCfg ConfigField, group arrayGroup, fieldMap map[string]any) error {
+	memberFs := make([]emitF, 0, len(group.members))
+	keys := make([]string, 0, len(group.members))
+	for _, member := range group.members {
+		memberF, ok := fieldMap[member.Name].(emitF)
+		if !ok {
+			return fmt.Errorf("field %s of the group is not bound", member.Name)
+		}
+
+		memberFs = append(memberFs, memberF)
+		keys = append(keys, strings.TrimPrefix(member.Name, group.name+"."))
+	}
+
+	var emitF emitF
+	emitF = func(state *genState) any {
+		n := arrayLength(fieldCfg, state)
+
+		objects := make([]map[string]any, n)
+		for i := range objects {
+			object := make(map[string]any, len(memberFs))
+			for j, memberF := range memberFs {
+				object[keys[j]] = memberF(state)
+			}
+
+			objects[i] = object
+		}
+
+		return objects
+	}
+
+	fieldMap[group.name] = emitF
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+)
+
+// bindHashedFields replaces the emit functions of the fields with `hash_of`, so that their value only depends on
+// the field name and on the value of the entity field: corpora generated independently, with any seed, get the
+// same value for the same entity, and can be joined on the field.
+func bindHashedFields(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok || len(fieldCfg.HashOf) == 0 {
+			continue
+		}
+
+		if err := fieldCfg.ValidHash(); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		if _, ok := fieldMap[fieldCfg.HashOf]; !ok {
+			return fmt.Errorf("field %s: entity field %s not present in fields definition", field.Name, fieldCfg.HashOf)
+		}
+
+		valueF, decimals, err := makeHashValueFunc(fieldCfg, field)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		entityF, err := bindEventRawValue(fieldCfg.HashOf, fieldMap, withReturn)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		hashedF := makeHashedFunc(field.Name, entityF, valueF)
+		if withReturn {
+			fieldMap[field.Name] = makeHashedEmitFWithReturn(hashedF)
+		} else {
+			fieldMap[field.Name] = makeHashedEmitF(hashedF, decimals)
+		}
+	}
+
+	return nil
+}
+
+// hashOf returns the hash of the value of the entity for the field
+func hashOf(fieldName string, entity any) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(fieldName))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(fmt.Sprint(entity)))
+	return h.Sum64()
+}
+
+// makeHashValueFunc returns the function generating the value of the field from a random number generator
+// seeded with the hash, and the decimals float values are written with
+func makeHashValueFunc(fieldCfg ConfigField, field Field) (func(r *rand.Rand, h uint64) (any, error), int, error) {
+	switch {
+	case isIntegerFieldType(field.Type):
+		// validates the range upfront
+		if _, err := makeIntFunc(nil, fieldCfg, field); err != nil {
+			return nil, 0, err
+		}
+
+		return func(r *rand.Rand, _ uint64) (any, error) {
+			intF, err := makeIntFunc(r, fieldCfg, field)
+			if err != nil {
+				return nil, err
+			}
+
+			return intF(), nil
+		}, 0, nil
+	case isFloatFieldType(field.Type):
+		if err := fieldCfg.ValidatePrecision(); err != nil {
+			return nil, 0, err
+		}
+
+		roundF, decimals := makeRoundFloatFunc(fieldCfg, field)
+		return func(r *rand.Rand, _ uint64) (any, error) {
+			return roundF(makeFloatFunc(r, fieldCfg, field)()), nil
+		}, decimals, nil
+	case (field.Type == FieldTypeKeyword || field.Type == FieldTypeWildcard) && len(fieldCfg.Enum) > 0:
+		enumF := makeEnumFunc(fieldCfg.Enum)
+		return func(r *rand.Rand, _ uint64) (any, error) {
+			return fieldCfg.Enum[enumF(r)].Value, nil
+		}, 0, nil
+	case field.Type == FieldTypeKeyword || field.Type == FieldTypeWildcard:
+		return func(_ *rand.Rand, h uint64) (any, error) {
+			return fmt.Sprintf("%016x", h), nil
+		}, 0, nil
+	case field.Type == FieldTypeIP:
+		ipF, err := makeIPFunc(fieldCfg)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return func(r *rand.Rand, _ uint64) (any, error) {
+			return ipF(r), nil
+		}, 0, nil
+	case field.Type == FieldTypeBool:
+		return func(r *rand.Rand, _ uint64) (any, error) {
+			return r.Intn(2) == 1, nil
+		}, 0, nil
+	default:
+		return nil, 0, fmt.Errorf("`hash_of` is not supported for field type %s", field.Type)
+	}
+}
+
+// makeHashedFunc returns the value of the field for the entity of the current event, generated once per entity
+func makeHashedFunc(fieldName string, entityF func(state *genState) (any, error), valueF func(r *rand.Rand, h uint64) (any, error)) func(state *genState) (any, error) {
+	return func(state *genState) (any, error) {
+		entity, err := entityF(state)
+		if err != nil {
+			return nil, err
+		}
+
+		values, ok := state.prevCacheHash[fieldName]
+		if !ok {
+			values = make(map[string]any)
+			state.prevCacheHash[fieldName] = values
+		}
+
+		key := fmt.Sprint(entity)
+		if value, ok := values[key]; ok {
+			return value, nil
+		}
+
+		h := hashOf(fieldName, key)
+		value, err := valueF(rand.New(rand.NewSource(int64(h))), h)
+		if err != nil {
+			return nil, err
+		}
+
+		values[key] = value
+		return value, nil
+	}
+}
+
+func makeHashedEmitF(hashedF func(state *genState) (any, error), decimals int) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		value, err := hashedF(state)
+		if err != nil {
+			return err
+		}
+
+		switch v := value.(type) {
+		case int64:
+			buf.Write(strconv.AppendInt(make([]byte, 0, 32), v, 10))
+		case float64:
+			return writeFloat(buf, v, decimals)
+		case bool:
+			buf.Write(strconv.AppendBool(make([]byte, 0, 5), v))
+		default:
+			buf.WriteString(fmt.Sprint(v))
+		}
+
+		return nil
+	}
+}
+
+func makeHashedEmitFWithReturn(hashedF func(state *genState) (any, error)) emitF {
+	return func(state *genState) any {
+		value, err := hashedF(state)
+		if err != nil {
+			panic(err)
+		}
+
+		return value
+	}
+}
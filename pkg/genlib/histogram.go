@@ -0,0 +1,177 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// histogram is the value of a `histogram` field returned to the text template: it's written as a JSON object, and
+// its buckets can be accessed as `.Values` and `.Counts`.
+type histogram struct {
+	Values   []float64
+	Counts   []int64
+	decimals int
+}
+
+func (h histogram) String() string {
+	var buf bytes.Buffer
+	h.write(&buf)
+	return buf.String()
+}
+
+// write writes the histogram to buf as the `{"values":[...],"counts":[...]}` object of Elasticsearch
+func (h histogram) write(buf *bytes.Buffer) {
+	v := make([]byte, 0, 32)
+
+	buf.WriteString(`{"values":[`)
+	for i, value := range h.Values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(strconv.AppendFloat(v[:0], value, 'f', h.decimals, 64))
+	}
+
+	buf.WriteString(`],"counts":[`)
+	for i, count := range h.Counts {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(strconv.AppendInt(v[:0], count, 10))
+	}
+
+	buf.WriteString(`]}`)
+}
+
+// makeHistogramFunc returns the histograms of the field: at each event `observations` values are drawn with the
+// distribution of the field and counted into `buckets` of the same width spanning the `range`, or the values drawn
+// when a bound is not set. The values of the histogram are the midpoints of the buckets, the empty ones skipped,
+// so that they are strictly increasing as Elasticsearch requires.
+func makeHistogramFunc(fieldCfg ConfigField, field Field) func(r *rand.Rand) histogram {
+	var histogramCfg config.Histogram
+	if fieldCfg.Histogram != nil {
+		histogramCfg = *fieldCfg.Histogram
+	}
+
+	buckets, observations := histogramCfg.BucketsOrDefault(), histogramCfg.ObservationsOrDefault()
+
+	rangeMin, minErr := fieldCfg.Range.MinAsFloat64()
+	rangeMax, maxErr := fieldCfg.Range.MaxAsFloat64()
+	roundF, decimals := makeRoundFloatFunc(fieldCfg, field)
+
+	observed := make([]float64, observations)
+	counts := make([]int64, buckets)
+
+	return func(r *rand.Rand) histogram {
+		valueF := makeFloatFunc(r, fieldCfg, field)
+
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for i := range observed {
+			observed[i] = valueF()
+			lo = math.Min(lo, observed[i])
+			hi = math.Max(hi, observed[i])
+		}
+
+		if minErr == nil {
+			lo = rangeMin
+		}
+
+		if maxErr == nil {
+			hi = rangeMax
+		}
+
+		for i := range counts {
+			counts[i] = 0
+		}
+
+		width := (hi - lo) / float64(buckets)
+		for _, value := range observed {
+			bucket := 0
+			if width > 0 {
+				bucket = int((value - lo) / width)
+			}
+
+			if bucket < 0 {
+				bucket = 0
+			} else if bucket >= buckets {
+				bucket = buckets - 1
+			}
+
+			counts[bucket]++
+		}
+
+		h := histogram{decimals: decimals}
+		for i, count := range counts {
+			if count == 0 {
+				continue
+			}
+
+			value := roundF(lo + (float64(i)+0.5)*width)
+			// buckets narrower than the precision are merged
+			if n := len(h.Values); n > 0 && h.Values[n-1] == value {
+				h.Counts[n-1] += count
+				continue
+			}
+
+			h.Values = append(h.Values, value)
+			h.Counts = append(h.Counts, count)
+		}
+
+		return h
+	}
+}
+
+func bindHistogram(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	if err := validHistogram(fieldCfg, field); err != nil {
+		return err
+	}
+
+	histogramF := makeHistogramFunc(fieldCfg, field)
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		histogramF(state.rand).write(buf)
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
+
+func bindHistogramWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	if err := validHistogram(fieldCfg, field); err != nil {
+		return err
+	}
+
+	histogramF := makeHistogramFunc(fieldCfg, field)
+
+	var emitF emitF
+	emitF = func(state *genState) any {
+		return histogramF(state.rand)
+	}
+
+	fieldMap[field.Name] = emitF
+	return nil
+}
+
+func validHistogram(fieldCfg ConfigField, field Field) error {
+	if err := fieldCfg.ValidHistogram(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if err := fieldCfg.ValidatePrecision(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	return nil
+}
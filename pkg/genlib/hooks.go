@@ -0,0 +1,126 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// BeforeEventHook is called before an event is generated, with the index of the event in the run.
+// Returning an error stops the generation of the event.
+type BeforeEventHook func(event uint64) error
+
+// AfterFieldHook is called after the value of a field is generated, with the index of the event in the run,
+// and returns the value to use instead. With the text template engine the value is the generated one, while
+// with the custom template engine it's the rendered value as `[]byte`.
+// Returning an error stops the generation of the event.
+type AfterFieldHook func(event uint64, field string, value any) (any, error)
+
+// AfterEventHook is called after an event is generated, with the index of the event in the run and the
+// rendered event. The rendered event can be modified in place but not retained after the call.
+// Returning an error is returned by Emit.
+type AfterEventHook func(event uint64, doc []byte) error
+
+// TimelineStepHook is called when a step of the config timeline is applied, before the event of the index in the
+// run, so that the caller can apply the settings of the step that are not about the fields, like its rate.
+// Returning an error stops the generation of the event.
+type TimelineStepHook func(event uint64, step TimelineStep) error
+
+// hooks holds the callbacks set with the generator options
+type hooks struct {
+	beforeEvent  BeforeEventHook
+	afterField   AfterFieldHook
+	afterEvent   AfterEventHook
+	eventError   EventErrorHandler
+	timelineStep TimelineStepHook
+}
+
+func (h hooks) runBeforeEvent(state *genState) error {
+	if h.beforeEvent == nil {
+		return nil
+	}
+
+	return h.beforeEvent(state.counter)
+}
+
+// runTimelineSteps calls the TimelineStep hook with each one of the steps applied before the event
+func (h hooks) runTimelineSteps(state *genState, steps []TimelineStep) error {
+	if h.timelineStep == nil {
+		return nil
+	}
+
+	for _, step := range steps {
+		if err := h.timelineStep(state.counter, step); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runAfterEvent calls the AfterEvent hook with the event rendered in buf starting from offset
+func (h hooks) runAfterEvent(state *genState, buf *bytes.Buffer, offset int) error {
+	if h.afterEvent == nil {
+		return nil
+	}
+
+	return h.afterEvent(state.counter, buf.Bytes()[offset:])
+}
+
+// bindHooks wraps the emit functions of all the fields with the AfterField hook, if any
+func bindHooks(h hooks, fieldMap map[string]any, withReturn bool) {
+	if h.afterField == nil {
+		return
+	}
+
+	for fieldName, boundF := range fieldMap {
+		if withReturn {
+			if boundFWithReturn, ok := boundF.(emitF); ok {
+				fieldMap[fieldName] = makeAfterFieldEmitFWithReturn(h.afterField, fieldName, boundFWithReturn)
+			}
+		} else {
+			if boundFNotReturn, ok := boundF.(emitFNotReturn); ok {
+				fieldMap[fieldName] = makeAfterFieldEmitF(h.afterField, fieldName, boundFNotReturn)
+			}
+		}
+	}
+}
+
+func makeAfterFieldEmitF(afterField AfterFieldHook, fieldName string, boundF emitFNotReturn) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		var tmp bytes.Buffer
+		if err := boundF(state, &tmp); err != nil {
+			return err
+		}
+
+		value, err := afterField(state.counter, fieldName, tmp.Bytes())
+		if err != nil {
+			return err
+		}
+
+		switch v := value.(type) {
+		case []byte:
+			buf.Write(v)
+		case string:
+			buf.WriteString(v)
+		default:
+			_, err = fmt.Fprint(buf, v)
+		}
+
+		return err
+	}
+}
+
+func makeAfterFieldEmitFWithReturn(afterField AfterFieldHook, fieldName string, boundF emitF) emitF {
+	return func(state *genState) any {
+		value, err := afterField(state.counter, fieldName, boundF(state))
+		if err != nil {
+			panic(err)
+		}
+
+		return value
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// bindInterArrivalFields replaces the emit functions of the date fields with `inter_arrival`, so that each
+// timestamp follows the previous one of the run, or of the entity of the event, by a time drawn from the
+// distribution of the config: with `exponential` the events are a Poisson process, as the arrivals to a queue.
+func bindInterArrivalFields(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok || fieldCfg.InterArrival == nil {
+			continue
+		}
+
+		if err := fieldCfg.ValidInterArrival(); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		if field.Type != FieldTypeDate {
+			return fmt.Errorf("field %s: `inter_arrival` requires the %s field type", field.Name, FieldTypeDate)
+		}
+
+		entityF := func(state *genState) (any, error) {
+			return "", nil
+		}
+
+		if entity := fieldCfg.InterArrival.Entity; len(entity) > 0 {
+			if _, ok := fieldMap[entity]; !ok {
+				return fmt.Errorf("field %s: entity field %s not present in fields definition", field.Name, entity)
+			}
+
+			var err error
+			entityF, err = bindEventRawValue(entity, fieldMap, withReturn)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+
+		from, err := fieldCfg.Range.FromAsTime()
+		hasFrom := err == nil
+
+		gapF := makeInterArrivalFunc(*fieldCfg.InterArrival)
+		arrivalF := func(state *genState) (time.Time, error) {
+			entity, err := entityF(state)
+			if err != nil {
+				return time.Time{}, err
+			}
+
+			last, ok := state.prevCacheInterArrival[field.Name]
+			if !ok {
+				last = make(map[string]time.Time)
+				state.prevCacheInterArrival[field.Name] = last
+			}
+
+			key := fmt.Sprint(entity)
+			t, ok := last[key]
+			switch {
+			case ok && fieldCfg.Pattern != nil:
+				gap := state.patternTimeline(field.Name, fieldCfg.Pattern, time.Time{}, 0).gap(gapF(state.rand), t)
+				t = t.Add(gap)
+			case ok:
+				t = t.Add(gapF(state.rand))
+			case hasFrom:
+				t = from
+			default:
+				t = timeNowToBind
+			}
+
+			last[key] = t
+			return t, nil
+		}
+
+		if withReturn {
+			var emitF emitF
+			emitF = func(state *genState) any {
+				t, err := arrivalF(state)
+				if err != nil {
+					panic(err)
+				}
+
+				return t
+			}
+
+			fieldMap[field.Name] = emitF
+			continue
+		}
+
+		var emitFNotReturn emitFNotReturn
+		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+			t, err := arrivalF(state)
+			if err != nil {
+				return err
+			}
+
+			buf.WriteString(t.Format(FieldTypeTimeLayout))
+			return nil
+		}
+
+		fieldMap[field.Name] = emitFNotReturn
+	}
+
+	return nil
+}
+
+// makeInterArrivalFunc returns the function drawing the time between two consecutive events
+func makeInterArrivalFunc(interArrival config.InterArrival) func(r *rand.Rand) time.Duration {
+	mean := float64(interArrival.Mean)
+
+	switch interArrival.Distribution {
+	case config.InterArrivalExponential:
+		return func(r *rand.Rand) time.Duration {
+			return time.Duration(r.ExpFloat64() * mean)
+		}
+	case config.InterArrivalLognormal:
+		// the parameters of the underlying normal distribution, so that the times have the given mean and stddev
+		ratio := float64(interArrival.Stddev) / mean
+		sigma := math.Sqrt(math.Log(1 + ratio*ratio))
+		mu := math.Log(mean) - sigma*sigma/2
+
+		return func(r *rand.Rand) time.Duration {
+			return time.Duration(math.Exp(mu + sigma*r.NormFloat64()))
+		}
+	default:
+		return func(_ *rand.Rand) time.Duration {
+			return interArrival.Mean
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// localeData holds the words the values of a locale are made of
+type localeData struct {
+	firstNames []string
+	lastNames  []string
+	streets    []string
+	cities     []string
+	words      []string
+	// lastNameFirst is true when the last name comes first in a person name
+	lastNameFirst bool
+	// wordSeparator separates the words of a text, empty for languages not separating them
+	wordSeparator string
+	// sentenceEnd ends a text
+	sentenceEnd string
+	// address formats the address from the street, the house number and the city
+	address func(street string, number int, city string) string
+}
+
+const defaultLocale = "en"
+
+var locales = map[string]localeData{
+	"en": {
+		firstNames:    []string{"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David", "Elizabeth", "William", "Susan", "Richard", "Jessica"},
+		lastNames:     []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Wilson", "Anderson", "Taylor", "Thomas", "Moore", "Martin"},
+		streets:       []string{"Main Street", "Oak Avenue", "Maple Drive", "Cedar Lane", "Park Road", "Elm Street", "Pine Court", "Washington Boulevard", "Lake View", "Hill Road"},
+		cities:        []string{"Springfield", "Riverside", "Franklin", "Greenville", "Bristol", "Clinton", "Fairview", "Salem", "Madison", "Georgetown"},
+		words:         []string{"the", "quick", "service", "request", "network", "server", "user", "data", "error", "report", "system", "update", "file", "access", "time", "value", "storage", "cluster", "node", "event"},
+		wordSeparator: " ",
+		sentenceEnd:   ".",
+		address: func(street string, number int, city string) string {
+			return strconv.Itoa(number) + " " + street + ", " + city
+		},
+	},
+	"de": {
+		firstNames:    []string{"Lukas", "Anna", "Jürgen", "Sophie", "Maximilian", "Jörg", "Lena", "Björn", "Käthe", "Felix", "Marie", "Günter", "Hannah", "Uwe"},
+		lastNames:     []string{"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schäfer", "Köhler", "Groß", "Hoffmann", "Schröder", "Krüger"},
+		streets:       []string{"Hauptstraße", "Schulstraße", "Gartenstraße", "Bahnhofstraße", "Dorfstraße", "Bergstraße", "Birkenweg", "Lindenstraße", "Kirchstraße", "Mühlenweg"},
+		cities:        []string{"Berlin", "München", "Köln", "Düsseldorf", "Nürnberg", "Lübeck", "Würzburg", "Göttingen", "Saarbrücken", "Osnabrück"},
+		words:         []string{"der", "die", "das", "Größe", "Überprüfung", "Anfrage", "Benutzer", "Fehler", "Straße", "Zugriff", "Dienst", "Knoten", "Ereignis", "Speicher", "Änderung", "schnell", "groß", "über", "für", "während"},
+		wordSeparator: " ",
+		sentenceEnd:   ".",
+		address: func(street string, number int, city string) string {
+			return street + " " + strconv.Itoa(number) + ", " + city
+		},
+	},
+	"ja": {
+		firstNames:    []string{"太郎", "花子", "翔太", "さくら", "健一", "由美", "大輔", "陽菜", "拓也", "美咲", "蓮", "結衣", "悠斗", "愛子"},
+		lastNames:     []string{"佐藤", "鈴木", "高橋", "田中", "伊藤", "渡辺", "山本", "中村", "小林", "加藤", "吉田", "山田", "佐々木", "松本"},
+		streets:       []string{"丸の内", "赤坂", "銀座", "新宿", "梅田", "栄", "天神", "中央", "本町", "桜木町"},
+		cities:        []string{"東京都千代田区", "東京都港区", "大阪府大阪市北区", "愛知県名古屋市中区", "福岡県福岡市中央区", "北海道札幌市中央区", "京都府京都市中京区", "神奈川県横浜市中区", "兵庫県神戸市中央区", "宮城県仙台市青葉区"},
+		words:         []string{"サーバー", "要求", "利用者", "接続", "障害", "更新", "記録", "時間", "データ", "処理", "完了", "失敗", "確認", "設定", "ネットワーク", "ファイル", "です", "ました", "の", "を"},
+		lastNameFirst: true,
+		wordSeparator: "",
+		sentenceEnd:   "。",
+		address: func(street string, number int, city string) string {
+			return city + street + strconv.Itoa(number%9+1) + "-" + strconv.Itoa(number)
+		},
+	},
+	"ar": {
+		firstNames:    []string{"محمد", "فاطمة", "أحمد", "مريم", "علي", "نور", "عمر", "ليلى", "يوسف", "سارة", "خالد", "هدى", "حسن", "زينب"},
+		lastNames:     []string{"العلي", "الحسن", "المصري", "الشامي", "الخطيب", "النجار", "الحداد", "السيد", "العمري", "الزهراني", "القحطاني", "الدوسري", "التميمي", "الكعبي"},
+		streets:       []string{"شارع الملك فهد", "شارع التحرير", "شارع الجامعة", "شارع النيل", "شارع الحمراء", "شارع السلام", "شارع الاستقلال", "شارع الرشيد", "شارع الورود", "شارع الأمير"},
+		cities:        []string{"القاهرة", "الرياض", "دبي", "عمّان", "بيروت", "الدار البيضاء", "تونس", "جدة", "الدوحة", "مسقط"},
+		words:         []string{"الخادم", "طلب", "المستخدم", "خطأ", "تحديث", "الشبكة", "ملف", "الوقت", "البيانات", "الوصول", "النظام", "تقرير", "حدث", "التخزين", "عقدة", "في", "من", "إلى", "على", "تم"},
+		wordSeparator: " ",
+		sentenceEnd:   ".",
+		address: func(street string, number int, city string) string {
+			return strconv.Itoa(number) + " " + street + "، " + city
+		},
+	},
+	"ru": {
+		firstNames:    []string{"Александр", "Анна", "Дмитрий", "Елена", "Сергей", "Ольга", "Андрей", "Наталья", "Алексей", "Татьяна", "Иван", "Мария", "Михаил", "Юлия"},
+		lastNames:     []string{"Иванов", "Смирнов", "Кузнецов", "Попов", "Васильев", "Петров", "Соколов", "Михайлов", "Новиков", "Фёдоров", "Морозов", "Волков", "Алексеев", "Лебедев"},
+		streets:       []string{"улица Ленина", "Садовая улица", "улица Гагарина", "Советская улица", "Невский проспект", "улица Мира", "Лесная улица", "Школьная улица", "улица Пушкина", "Набережная улица"},
+		cities:        []string{"Москва", "Санкт-Петербург", "Новосибирск", "Екатеринбург", "Казань", "Нижний Новгород", "Самара", "Омск", "Ростов-на-Дону", "Уфа"},
+		words:         []string{"сервер", "запрос", "пользователь", "ошибка", "обновление", "сеть", "файл", "время", "данные", "доступ", "система", "отчёт", "событие", "хранилище", "узел", "в", "на", "и", "для", "успешно"},
+		wordSeparator: " ",
+		sentenceEnd:   ".",
+		address: func(street string, number int, city string) string {
+			return street + ", " + strconv.Itoa(number) + ", " + city
+		},
+	},
+}
+
+// Locales returns the names of the locales available for the `locale` setting, sorted
+func Locales() []string {
+	names := make([]string, 0, len(locales))
+	for name := range locales {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// isLocaleFieldType reports whether the values of the field type can be generated from the words of a locale
+func isLocaleFieldType(fieldType string) bool {
+	switch fieldType {
+	case FieldTypeKeyword, FieldTypeWildcard, "text", "match_only_text":
+		return true
+	default:
+		return false
+	}
+}
+
+// makeLocaleFunc returns the function generating the values of a field with `locale` or `content`
+func makeLocaleFunc(fieldCfg ConfigField, field Field) (func(state *genState) string, error) {
+	if !isLocaleFieldType(field.Type) {
+		return nil, fmt.Errorf("`locale` and `content` are not supported for field type %s", field.Type)
+	}
+
+	localeName := fieldCfg.Locale
+	if len(localeName) == 0 {
+		localeName = defaultLocale
+	}
+
+	locale, ok := locales[localeName]
+	if !ok {
+		return nil, fmt.Errorf("unknown locale %s, must be one of %s", localeName, strings.Join(Locales(), ", "))
+	}
+
+	pick := func(state *genState, values []string) string {
+		return values[state.rand.Intn(len(values))]
+	}
+
+	switch fieldCfg.Content {
+	case config.ContentPersonName:
+		return func(state *genState) string {
+			first, last := pick(state, locale.firstNames), pick(state, locale.lastNames)
+			if locale.lastNameFirst {
+				return last + " " + first
+			}
+
+			return first + " " + last
+		}, nil
+	case config.ContentAddress:
+		return func(state *genState) string {
+			return locale.address(pick(state, locale.streets), 1+state.rand.Intn(199), pick(state, locale.cities))
+		}, nil
+	case config.ContentText:
+		return func(state *genState) string {
+			n := 5 + state.rand.Intn(11)
+			words := make([]string, n)
+			for i := range words {
+				words[i] = pick(state, locale.words)
+			}
+
+			return strings.Join(words, locale.wordSeparator) + locale.sentenceEnd
+		}, nil
+	case "":
+		// as the default keywords, two words joined together
+		return func(state *genState) string {
+			return pick(state, locale.words) + pick(state, locale.words)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown content %s, must be one of %s, %s, %s", fieldCfg.Content, config.ContentPersonName, config.ContentAddress, config.ContentText)
+	}
+}
+
+func bindLocale(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	localeF, err := makeLocaleFunc(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		buf.WriteString(localeF(state))
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
+
+func bindLocaleWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	localeF, err := makeLocaleFunc(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	var emitF emitF
+	emitF = func(state *genState) any {
+		return localeF(state)
+	}
+
+	fieldMap[field.Name] = emitF
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"golang.org/x/text/unicode/norm"
+)
+
+// stripDiacritics removes the combining marks of the decomposed text, like the accent of `é`, composing it back
+func stripDiacritics(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return norm.NFC.String(b.String())
+}
+
+// makeNormalizeFunc returns the function normalizing a keyword with the `normalize` settings of the field
+func makeNormalizeFunc(normalize *config.Normalize) func(s string) string {
+	return func(s string) string {
+		if normalize.StripDiacritics {
+			s = stripDiacritics(s)
+		}
+
+		switch normalize.Case {
+		case config.NormalizeCaseLower:
+			s = strings.ToLower(s)
+		case config.NormalizeCaseUpper:
+			s = strings.ToUpper(s)
+		}
+
+		if normalize.ReplaceSpaces != nil {
+			var b strings.Builder
+			for _, r := range s {
+				if unicode.IsSpace(r) {
+					b.WriteString(*normalize.ReplaceSpaces)
+				} else {
+					b.WriteRune(r)
+				}
+			}
+
+			s = b.String()
+		}
+
+		return s
+	}
+}
+
+// bindNormalize wraps the emit function already bound for the field, so that the generated keywords match the
+// values indexed with the normalizer of their mapping, like lowercase ones without accents.
+func bindNormalize(fieldCfg ConfigField, field Field, fieldMap map[string]any, withReturn bool) error {
+	if err := fieldCfg.ValidNormalize(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	if field.Type != FieldTypeKeyword && field.Type != FieldTypeConstantKeyword {
+		return fmt.Errorf("field %s: `normalize` is not supported for field type %s", field.Name, field.Type)
+	}
+
+	normalizeF := makeNormalizeFunc(fieldCfg.Normalize)
+
+	if withReturn {
+		boundF := fieldMap[field.Name].(emitF)
+
+		var emitF emitF
+		emitF = func(state *genState) any {
+			return normalizeF(fmt.Sprint(boundF(state)))
+		}
+
+		fieldMap[field.Name] = emitF
+		return nil
+	}
+
+	boundF := fieldMap[field.Name].(emitFNotReturn)
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		var tmp bytes.Buffer
+		if err := boundF(state, &tmp); err != nil {
+			return err
+		}
+
+		buf.WriteString(normalizeF(tmp.String()))
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
@@ -8,21 +8,68 @@ import "math/rand"
 
 // options holds the configuration options for generators.
 type options struct {
-	randSeed int64
-	template []byte
-	make     func(Config, Fields, uint64, options) (Generator, error)
+	randSeed  int64
+	batchSize uint64
+	hooks     hooks
+	template  []byte
+	make      func(Config, Fields, uint64, options) (Generator, error)
 }
 
 // Option defines a functional option for configuring generators.
 type Option func(*options)
 
-// WithRandSeed sets the random seed for the generator.
+// WithRandSeed sets the random seed for the generator, overriding the `seed` of the config.
 func WithRandSeed(seed int64) Option {
 	return func(o *options) {
 		o.randSeed = seed
 	}
 }
 
+// WithBatchSize sets the number of events in an output batch, used by
+// `per_batch_constant` fields. A zero size means the whole run is one batch.
+func WithBatchSize(size uint64) Option {
+	return func(o *options) {
+		o.batchSize = size
+	}
+}
+
+// WithBeforeEvent sets a callback called before each event is generated.
+func WithBeforeEvent(hook BeforeEventHook) Option {
+	return func(o *options) {
+		o.hooks.beforeEvent = hook
+	}
+}
+
+// WithAfterField sets a callback called after each field value is generated,
+// that can replace the value.
+func WithAfterField(hook AfterFieldHook) Option {
+	return func(o *options) {
+		o.hooks.afterField = hook
+	}
+}
+
+// WithAfterEvent sets a callback called after each event is generated.
+func WithAfterEvent(hook AfterEventHook) Option {
+	return func(o *options) {
+		o.hooks.afterEvent = hook
+	}
+}
+
+// WithTimelineStep sets a callback called when a step of the config timeline is applied.
+func WithTimelineStep(hook TimelineStepHook) Option {
+	return func(o *options) {
+		o.hooks.timelineStep = hook
+	}
+}
+
+// WithEventErrorHandler sets a callback called when the generation of an event fails, instead of Emit
+// returning the error, see EventErrorHandler.
+func WithEventErrorHandler(handler EventErrorHandler) Option {
+	return func(o *options) {
+		o.hooks.eventError = handler
+	}
+}
+
 // WithTextTemplate sets a Go text template for the generator.
 func WithTextTemplate(template []byte) Option {
 	return func(o *options) {
@@ -40,11 +87,15 @@ func WithCustomTemplate(template []byte) Option {
 }
 
 // applyOptions applies the given options and returns the final configuration.
-func applyOptions(opts []Option) options {
+// The random seed is the `seed` of cfg when set, a random one otherwise.
+func applyOptions(cfg Config, opts []Option) options {
 	o := options{
 		randSeed: rand.Int63(),
 		make:     newGeneratorWithCustomTemplate,
 	}
+	if seed, ok := cfg.Seed(); ok {
+		o.randSeed = seed
+	}
 	for _, opt := range opts {
 		opt(&o)
 	}
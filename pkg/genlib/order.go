@@ -0,0 +1,130 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// orderStep is the smallest increase of an ordered timestamp, the precision of FieldTypeTimeLayout
+const orderStep = time.Microsecond
+
+// bindOrderedFields wraps the emit functions of the date fields with `order`, so that each timestamp is greater
+// than the previous one of the run, or of the entity of the event for `increasing_per_entity`.
+func bindOrderedFields(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok {
+			continue
+		}
+
+		if err := fieldCfg.ValidOrder(); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		if fieldCfg.Order != config.OrderStrictlyIncreasing && fieldCfg.Order != config.OrderIncreasingPerEntity {
+			continue
+		}
+
+		if field.Type != FieldTypeDate {
+			return fmt.Errorf("field %s: `order` requires the %s field type", field.Name, FieldTypeDate)
+		}
+
+		entityF := func(state *genState) (any, error) {
+			return "", nil
+		}
+
+		if fieldCfg.Order == config.OrderIncreasingPerEntity {
+			if _, ok := fieldMap[fieldCfg.OrderEntity]; !ok {
+				return fmt.Errorf("field %s: entity field %s not present in fields definition", field.Name, fieldCfg.OrderEntity)
+			}
+
+			var err error
+			entityF, err = bindEventRawValue(fieldCfg.OrderEntity, fieldMap, withReturn)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+
+		orderF := makeOrderFunc(field.Name, entityF)
+		if withReturn {
+			fieldMap[field.Name] = makeOrderedEmitFWithReturn(fieldMap[field.Name].(emitF), orderF)
+		} else {
+			fieldMap[field.Name] = makeOrderedEmitF(fieldMap[field.Name].(emitFNotReturn), orderF)
+		}
+	}
+
+	return nil
+}
+
+// makeOrderFunc returns the timestamp moved after the last one of the entity of the current event, if needed
+func makeOrderFunc(fieldName string, entityF func(state *genState) (any, error)) func(state *genState, t time.Time) (time.Time, error) {
+	return func(state *genState, t time.Time) (time.Time, error) {
+		entity, err := entityF(state)
+		if err != nil {
+			return t, err
+		}
+
+		last, ok := state.prevCacheOrder[fieldName]
+		if !ok {
+			last = make(map[string]time.Time)
+			state.prevCacheOrder[fieldName] = last
+		}
+
+		key := fmt.Sprint(entity)
+		if previous, ok := last[key]; ok && !t.After(previous) {
+			t = previous.Add(orderStep)
+		}
+
+		last[key] = t
+		return t, nil
+	}
+}
+
+func makeOrderedEmitF(boundF emitFNotReturn, orderF func(state *genState, t time.Time) (time.Time, error)) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		v := state.pool.Get()
+		tmp := v.(*bytes.Buffer)
+		tmp.Reset()
+		defer state.pool.Put(tmp)
+
+		if err := boundF(state, tmp); err != nil {
+			return err
+		}
+
+		t, err := time.Parse(FieldTypeTimeLayout, tmp.String())
+		if err != nil {
+			return err
+		}
+
+		t, err = orderF(state, t)
+		if err != nil {
+			return err
+		}
+
+		buf.WriteString(t.Format(FieldTypeTimeLayout))
+		return nil
+	}
+}
+
+func makeOrderedEmitFWithReturn(boundF emitF, orderF func(state *genState, t time.Time) (time.Time, error)) emitF {
+	return func(state *genState) any {
+		t, ok := boundF(state).(time.Time)
+		if !ok {
+			panic("ordered field value is not a time")
+		}
+
+		t, err := orderF(state, t)
+		if err != nil {
+			panic(err)
+		}
+
+		return t
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+const (
+	// patternStep is the resolution the volume of a pattern is integrated with over the period of the timestamps
+	patternStep = time.Minute
+	// patternMaxSteps caps the steps of the integration, so that long periods have a coarser resolution
+	patternMaxSteps = 100000
+	// patternMeanWindow is the time the mean volume of a pattern is computed over, for the gaps between timestamps
+	patternMeanWindow = 7 * 24 * time.Hour
+)
+
+// patternTimeline places the timestamps of a period so that their volume follows a pattern
+type patternTimeline struct {
+	pattern *config.Pattern
+	start   time.Time
+	period  time.Duration
+	// cumulative are the volumes of the pattern integrated from the start of the period to the end of each step,
+	// normalised to 1
+	cumulative []float64
+	// mean is the mean volume of the pattern, so that the gaps between timestamps keep their mean
+	mean float64
+}
+
+// patternVolume returns the volume of the pattern at t, relative to the hours not at peak of the weekdays without
+// seasonality
+func patternVolume(pattern *config.Pattern, t time.Time) float64 {
+	t = t.UTC()
+	volume := 1.0
+	for _, hour := range pattern.PeakHours {
+		if t.Hour() == hour {
+			peakFactor := pattern.PeakFactor
+			if peakFactor == 0 {
+				peakFactor = config.DefaultPeakFactor
+			}
+
+			volume *= peakFactor
+			break
+		}
+	}
+
+	if weekday := t.Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+		weekendFactor := pattern.WeekendFactor
+		if weekendFactor == 0 {
+			weekendFactor = config.DefaultWeekendFactor
+		}
+
+		volume *= weekendFactor
+	}
+
+	for _, seasonality := range pattern.Seasonality {
+		phase := time.Duration(t.UnixNano()) % seasonality.Period
+		angle := 2 * math.Pi * float64(phase-seasonality.Peak) / float64(seasonality.Period)
+		volume *= 1 + seasonality.Amplitude*math.Cos(angle)
+	}
+
+	return volume
+}
+
+func newPatternTimeline(pattern *config.Pattern, start time.Time, period time.Duration) *patternTimeline {
+	steps := int(period / patternStep)
+	if steps > patternMaxSteps {
+		steps = patternMaxSteps
+	}
+
+	if steps < 1 {
+		steps = 1
+	}
+
+	timeline := &patternTimeline{pattern: pattern, start: start, period: period, cumulative: make([]float64, steps)}
+
+	step := period / time.Duration(steps)
+	var total float64
+	for i := range timeline.cumulative {
+		total += patternVolume(pattern, start.Add(step*time.Duration(i)+step/2))
+		timeline.cumulative[i] = total
+	}
+
+	for i := range timeline.cumulative {
+		timeline.cumulative[i] /= total
+	}
+
+	var sum float64
+	meanSteps := int(patternMeanWindow / patternStep)
+	for i := 0; i < meanSteps; i++ {
+		sum += patternVolume(pattern, start.Add(patternStep*time.Duration(i)))
+	}
+
+	timeline.mean = sum / float64(meanSteps)
+
+	return timeline
+}
+
+// at returns the time of the period by which the fraction of the volume of the period is reached
+func (p *patternTimeline) at(fraction float64) time.Time {
+	steps := len(p.cumulative)
+	i := sort.SearchFloat64s(p.cumulative, fraction)
+	if i >= steps {
+		return p.start.Add(p.period)
+	}
+
+	previous := 0.0
+	if i > 0 {
+		previous = p.cumulative[i-1]
+	}
+
+	within := 0.0
+	if p.cumulative[i] > previous {
+		within = (fraction - previous) / (p.cumulative[i] - previous)
+	}
+
+	offset := (float64(i) + within) / float64(steps) * float64(p.period)
+	return p.start.Add(time.Duration(offset))
+}
+
+// gap returns the gap between two timestamps at t, shrunk where the volume of the pattern is above its mean
+// and stretched where it's below
+func (p *patternTimeline) gap(gap time.Duration, t time.Time) time.Duration {
+	return time.Duration(float64(gap) * p.mean / patternVolume(p.pattern, t))
+}
+
+// patternTimeline returns the timeline of the pattern of the field for the period from start, built once and
+// reused while the period doesn't change
+func (s *genState) patternTimeline(fieldName string, pattern *config.Pattern, start time.Time, period time.Duration) *patternTimeline {
+	timeline, ok := s.prevCachePattern[fieldName]
+	if !ok || timeline.pattern != pattern || !timeline.start.Equal(start) || timeline.period != period {
+		timeline = newPatternTimeline(pattern, start, period)
+		s.prevCachePattern[fieldName] = timeline
+	}
+
+	return timeline
+}
@@ -0,0 +1,45 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatternVolume(t *testing.T) {
+	pattern := &config.Pattern{
+		Seasonality: []config.Seasonality{{Amplitude: 0.5, Period: 24 * time.Hour, Peak: 14 * time.Hour}},
+	}
+
+	// the sine curve peaks at 14:00 UTC and is at its lowest 12 hours later
+	assert.InDelta(t, 1.5, patternVolume(pattern, time.Date(2023, 1, 2, 14, 0, 0, 0, time.UTC)), 1e-9)
+	assert.InDelta(t, 0.5, patternVolume(pattern, time.Date(2023, 1, 3, 2, 0, 0, 0, time.UTC)), 1e-9)
+	assert.InDelta(t, 1, patternVolume(pattern, time.Date(2023, 1, 2, 20, 0, 0, 0, time.UTC)), 1e-9)
+
+	// the defaults double the volume of the peak hours and leave the weekends alone
+	pattern = &config.Pattern{PeakHours: []int{9}}
+	assert.Equal(t, 2.0, patternVolume(pattern, time.Date(2023, 1, 7, 9, 30, 0, 0, time.UTC)))
+	assert.Equal(t, 1.0, patternVolume(pattern, time.Date(2023, 1, 7, 10, 0, 0, 0, time.UTC)))
+}
+
+func TestPatternTimeline(t *testing.T) {
+	pattern := &config.Pattern{PeakHours: []int{1}, PeakFactor: 3}
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	timeline := newPatternTimeline(pattern, start, 2*time.Hour)
+
+	// the second hour holds 3/4 of the volume of the period
+	assert.Equal(t, start, timeline.at(0))
+	assert.Equal(t, start.Add(time.Hour), timeline.at(0.25))
+	assert.Equal(t, start.Add(90*time.Minute), timeline.at(0.625))
+	assert.Equal(t, start.Add(2*time.Hour), timeline.at(1))
+
+	// the gaps shrink where the volume is above the mean, here (23 + 3) / 24
+	mean := 26 / 24.0
+	assert.InDelta(t, float64(time.Second)*mean/3, float64(timeline.gap(time.Second, start.Add(time.Hour))), 1000)
+}
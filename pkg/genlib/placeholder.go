@@ -0,0 +1,429 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// placeholderFormat writes the value rendered for a placeholder formatted to buf
+type placeholderFormat func(value []byte, buf *bytes.Buffer) error
+
+// placeholder is a parsed placeholder of the custom template, as in `{{$.timestamp | date "2006-01-02"}}`
+type placeholder struct {
+	fieldName string
+	// ref is true when the placeholder references the value of the field already rendered in the event
+	ref bool
+	// format is nil when the value is written as rendered
+	format placeholderFormat
+}
+
+// placeholderFunc is a function a placeholder can pipe its value to, making the format of the value from the
+// argument of the function, if it takes one
+type placeholderFunc struct {
+	hasArg bool
+	make   func(arg string) (placeholderFormat, error)
+}
+
+// placeholderFuncs are the functions of the placeholders, by name
+var placeholderFuncs = map[string]placeholderFunc{
+	"printf":     {hasArg: true, make: noErrorFormat(makePrintfFormat)},
+	"date":       {hasArg: true, make: noErrorFormat(makeDateFormat)},
+	"formatDate": {hasArg: true, make: noErrorFormat(makeDateFormat)},
+	"escape":     {hasArg: true, make: makeEscapeFormat},
+	"default":    {hasArg: true, make: noErrorFormat(makeDefaultFormat)},
+	"add":        {hasArg: true, make: makeAddFormat},
+	"join":       {hasArg: true, make: noErrorFormat(makeJoinFormat)},
+	"upper":      {make: noArgFormat(upperFormat)},
+	"lower":      {make: noArgFormat(lowerFormat)},
+	"urlencode":  {make: noArgFormat(urlencodeFormat)},
+	"base64":     {make: noArgFormat(base64Format)},
+	"uuid":       {make: noArgFormat(uuidFormat)},
+}
+
+func noErrorFormat(makeFormat func(arg string) placeholderFormat) func(arg string) (placeholderFormat, error) {
+	return func(arg string) (placeholderFormat, error) {
+		return makeFormat(arg), nil
+	}
+}
+
+func noArgFormat(format placeholderFormat) func(arg string) (placeholderFormat, error) {
+	return func(string) (placeholderFormat, error) {
+		return format, nil
+	}
+}
+
+// placeholderFuncNames returns the names of the functions of the placeholders, sorted
+func placeholderFuncNames() []string {
+	names := make([]string, 0, len(placeholderFuncs))
+	for name := range placeholderFuncs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// parsePlaceholder parses the content of a placeholder, without the curly braces and the leading dot of the field name
+func parsePlaceholder(content string) (placeholder, error) {
+	stages := splitPipeline(content)
+	fieldName := strings.TrimSpace(stages[0])
+
+	p := placeholder{fieldName: strings.TrimPrefix(fieldName, refPlaceholderPrefix)}
+	p.ref = p.fieldName != fieldName
+
+	formats := make([]placeholderFormat, 0, len(stages)-1)
+	for _, stage := range stages[1:] {
+		funcName, arg, hasArg := strings.Cut(strings.TrimSpace(stage), " ")
+
+		f, ok := placeholderFuncs[funcName]
+		if !ok {
+			return placeholder{}, fmt.Errorf("placeholder %s: unknown function %s, must be one of %s", content, funcName, strings.Join(placeholderFuncNames(), ", "))
+		}
+
+		if f.hasArg != hasArg {
+			if f.hasArg {
+				return placeholder{}, fmt.Errorf("placeholder %s: %s requires a quoted string argument", content, funcName)
+			}
+
+			return placeholder{}, fmt.Errorf("placeholder %s: %s takes no argument", content, funcName)
+		}
+
+		if hasArg {
+			var err error
+			arg, err = parsePlaceholderArg(strings.TrimSpace(arg))
+			if err != nil {
+				return placeholder{}, fmt.Errorf("placeholder %s: the argument of %s must be a quoted string", content, funcName)
+			}
+		}
+
+		format, err := f.make(arg)
+		if err != nil {
+			return placeholder{}, fmt.Errorf("placeholder %s: %w", content, err)
+		}
+
+		formats = append(formats, format)
+	}
+
+	p.format = pipeFormats(formats)
+
+	return p, nil
+}
+
+// splitPipeline splits the content of a placeholder at the pipes outside of the quoted arguments
+func splitPipeline(content string) []string {
+	var stages []string
+	inQuotes, escaped := false, false
+	start := 0
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && inQuotes:
+			escaped = true
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == '|' && !inQuotes:
+			stages = append(stages, content[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(stages, content[start:])
+}
+
+// parsePlaceholderArg returns the argument of a placeholder function: a quoted string, or a number for `add`
+func parsePlaceholderArg(arg string) (string, error) {
+	if _, err := strconv.ParseFloat(arg, 64); err == nil {
+		return arg, nil
+	}
+
+	return strconv.Unquote(arg)
+}
+
+// pipeFormats returns the format writing the value formatted by each of the formats in turn, nil without formats
+func pipeFormats(formats []placeholderFormat) placeholderFormat {
+	switch len(formats) {
+	case 0:
+		return nil
+	case 1:
+		return formats[0]
+	}
+
+	return func(value []byte, buf *bytes.Buffer) error {
+		var in, out bytes.Buffer
+		in.Write(value)
+		for _, format := range formats[:len(formats)-1] {
+			out.Reset()
+			if err := format(in.Bytes(), &out); err != nil {
+				return err
+			}
+
+			in, out = out, in
+		}
+
+		return formats[len(formats)-1](in.Bytes(), buf)
+	}
+}
+
+// makePrintfFormat formats the rendered value with a fmt verb: integer and float values are formatted as numbers
+func makePrintfFormat(format string) placeholderFormat {
+	return func(value []byte, buf *bytes.Buffer) error {
+		s := string(value)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			_, err := fmt.Fprintf(buf, format, i)
+			return err
+		}
+
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			_, err := fmt.Fprintf(buf, format, f)
+			return err
+		}
+
+		_, err := fmt.Fprintf(buf, format, s)
+		return err
+	}
+}
+
+// makeDateFormat formats the rendered value of a date field with a Go time layout
+func makeDateFormat(layout string) placeholderFormat {
+	return func(value []byte, buf *bytes.Buffer) error {
+		t, err := time.Parse(FieldTypeTimeLayout, string(value))
+		if err != nil {
+			return fmt.Errorf("cannot format %s as date: %w", value, err)
+		}
+
+		buf.WriteString(t.Format(layout))
+		return nil
+	}
+}
+
+func upperFormat(value []byte, buf *bytes.Buffer) error {
+	buf.Write(bytes.ToUpper(value))
+	return nil
+}
+
+func lowerFormat(value []byte, buf *bytes.Buffer) error {
+	buf.Write(bytes.ToLower(value))
+	return nil
+}
+
+func urlencodeFormat(value []byte, buf *bytes.Buffer) error {
+	buf.WriteString(url.QueryEscape(string(value)))
+	return nil
+}
+
+func base64Format(value []byte, buf *bytes.Buffer) error {
+	buf.WriteString(base64.StdEncoding.EncodeToString(value))
+	return nil
+}
+
+// uuidFormat writes the UUID derived from the value, a version 5 one in the URL namespace: the same value always
+// gets the same UUID, so that the UUIDs are as correlated as the values they are derived from
+func uuidFormat(value []byte, buf *bytes.Buffer) error {
+	h := sha1.New()
+	h.Write(uuidNamespaceURL[:])
+	h.Write(value)
+
+	var uuid [16]byte
+	copy(uuid[:], h.Sum(nil))
+	uuid[6] = uuid[6]&0x0f | 0x50
+	uuid[8] = uuid[8]&0x3f | 0x80
+
+	fmt.Fprintf(buf, "%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+	return nil
+}
+
+// uuidNamespaceURL is the namespace of the UUIDs derived from the values, as defined in RFC 4122
+var uuidNamespaceURL = [16]byte{0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+
+// makeDefaultFormat writes the value, or defaultValue when the value is not set, that is empty or `null`
+func makeDefaultFormat(defaultValue string) placeholderFormat {
+	return func(value []byte, buf *bytes.Buffer) error {
+		if len(value) == 0 || string(value) == "null" {
+			buf.WriteString(defaultValue)
+			return nil
+		}
+
+		buf.Write(value)
+		return nil
+	}
+}
+
+// makeAddFormat adds a number to a numeric value, keeping it an integer when both are
+func makeAddFormat(arg string) (placeholderFormat, error) {
+	addend, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return nil, fmt.Errorf("the argument of add must be a number: %s", arg)
+	}
+
+	intAddend, intErr := strconv.ParseInt(arg, 10, 64)
+
+	return func(value []byte, buf *bytes.Buffer) error {
+		s := string(value)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil && intErr == nil {
+			buf.WriteString(strconv.FormatInt(i+intAddend, 10))
+			return nil
+		}
+
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("cannot add %s to %s: not a number", arg, value)
+		}
+
+		buf.WriteString(strconv.FormatFloat(f+addend, 'f', -1, 64))
+		return nil
+	}, nil
+}
+
+// makeJoinFormat writes the values of an array field joined by sep, and any other value as is
+func makeJoinFormat(sep string) placeholderFormat {
+	return func(value []byte, buf *bytes.Buffer) error {
+		if len(value) == 0 || value[0] != '[' {
+			buf.Write(value)
+			return nil
+		}
+
+		values, err := decodeArray(value)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(bytes.Join(values, []byte(sep)))
+		return nil
+	}
+}
+
+// makeFormatEmitF wraps the emit function of a field writing its value formatted
+func makeFormatEmitF(emitFunc emitFNotReturn, format placeholderFormat) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		v := state.pool.Get()
+		tmp := v.(*bytes.Buffer)
+		tmp.Reset()
+		defer state.pool.Put(tmp)
+
+		if err := emitFunc(state, tmp); err != nil {
+			return err
+		}
+
+		return format(tmp.Bytes(), buf)
+	}
+}
+
+// condition is the condition of an `if` block of the custom template
+type condition struct {
+	// op is empty when the condition is the value of the field being set
+	op    string
+	value []byte
+}
+
+// parseCondition parses the condition of an `if` block, as in `$.referrer` or `eq $.method "GET"`,
+// returning the placeholder of the field the condition is about
+func parseCondition(content string) (placeholder, *condition, error) {
+	cond := &condition{}
+	for _, op := range []string{"eq ", "ne "} {
+		if strings.HasPrefix(content, op) {
+			cond.op = strings.TrimSpace(op)
+			content = strings.TrimPrefix(content, op)
+		}
+	}
+
+	fieldName, arg, hasArg := strings.Cut(strings.TrimSpace(content), " ")
+	if hasArg != (len(cond.op) > 0) {
+		return placeholder{}, nil, fmt.Errorf("invalid condition %s: must be a field, or `eq` or `ne` with a field and a quoted string", content)
+	}
+
+	if hasArg {
+		value, err := strconv.Unquote(strings.TrimSpace(arg))
+		if err != nil {
+			return placeholder{}, nil, fmt.Errorf("invalid condition %s: the value must be a quoted string", content)
+		}
+
+		cond.value = []byte(value)
+	}
+
+	p, err := parsePlaceholder(strings.TrimPrefix(fieldName, "."))
+	if err != nil {
+		return placeholder{}, nil, err
+	}
+
+	return p, cond, nil
+}
+
+// isTrue reports whether the value rendered for the field of the condition satisfies it: without an operator,
+// the value must be set, that is not empty, `false`, `0`, `null` or an empty array
+func (c *condition) isTrue(value []byte) bool {
+	switch c.op {
+	case "eq":
+		return bytes.Equal(value, c.value)
+	case "ne":
+		return !bytes.Equal(value, c.value)
+	}
+
+	switch string(value) {
+	case "", "false", "0", "null", "[]":
+		return false
+	default:
+		return true
+	}
+}
+
+// repeatCount is the number of times the body of a `repeat` block is written, randomly chosen between min and max
+// for each event, and the separator written between them
+type repeatCount struct {
+	min, max  int
+	separator []byte
+}
+
+// parseRepeatCount parses the arguments of a `repeat` block, as in `3`, `1 5` or `1 5 ","`
+func parseRepeatCount(content string) (*repeatCount, error) {
+	args, separator, hasSeparator := strings.Cut(content, `"`)
+
+	count := &repeatCount{}
+	if hasSeparator {
+		unquoted, err := strconv.Unquote(`"` + strings.TrimSpace(separator))
+		if err != nil {
+			return nil, fmt.Errorf("invalid repeat %s: the separator must be a quoted string", content)
+		}
+
+		count.separator = []byte(unquoted)
+	}
+
+	bounds := strings.Fields(args)
+	if len(bounds) == 0 || len(bounds) > 2 {
+		return nil, fmt.Errorf("invalid repeat %s: must be a count, or a minimum and a maximum count, and an optional quoted separator", content)
+	}
+
+	var err error
+	if count.min, err = strconv.Atoi(bounds[0]); err != nil || count.min < 0 {
+		return nil, fmt.Errorf("invalid repeat %s: the count must be a not negative integer", content)
+	}
+
+	count.max = count.min
+	if len(bounds) == 2 {
+		if count.max, err = strconv.Atoi(bounds[1]); err != nil || count.max < count.min {
+			return nil, fmt.Errorf("invalid repeat %s: the maximum count must be an integer not less than the minimum", content)
+		}
+	}
+
+	return count, nil
+}
+
+// n returns the number of times the body of the block is written in the current event
+func (c *repeatCount) n(state *genState) int {
+	if c.max > c.min {
+		return c.min + state.rand.Intn(c.max-c.min+1)
+	}
+
+	return c.min
+}
@@ -0,0 +1,74 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// makeQuantileFunc returns the function generating values with the target `quantiles` of the field, sampling the
+// inverse of a cumulative distribution function going through them. Between two quantiles the values are uniformly
+// distributed; below the first quantile they go down to `range.min`, or to zero when the values are not negative,
+// and above the last quantile up to `range.max`. Without a bound the tail is exponential, as the one of latencies,
+// with the rate of the two quantiles next to it.
+func makeQuantileFunc(r *rand.Rand, fieldCfg ConfigField) (func() float64, error) {
+	points, err := fieldCfg.QuantilePoints()
+	if err != nil {
+		return nil, err
+	}
+
+	first, last := points[0], points[len(points)-1]
+
+	lowerTail := makeLowerTailFunc(points[0], points[1])
+	if minValue, err := fieldCfg.Range.MinAsFloat64(); err == nil {
+		points = append([]config.QuantilePoint{{Quantile: 0, Value: minValue}}, points...)
+	} else if first.Value >= 0 {
+		points = append([]config.QuantilePoint{{Quantile: 0, Value: 0}}, points...)
+	}
+
+	upperTail := makeUpperTailFunc(points[len(points)-2], last)
+	if maxValue, err := fieldCfg.Range.MaxAsFloat64(); err == nil {
+		points = append(points, config.QuantilePoint{Quantile: 1, Value: maxValue})
+	}
+
+	return func() float64 {
+		u := r.Float64()
+		if u < points[0].Quantile {
+			return lowerTail(u)
+		}
+
+		if u >= points[len(points)-1].Quantile {
+			return upperTail(u)
+		}
+
+		// the first point with a quantile greater than u
+		i := sort.Search(len(points), func(i int) bool { return points[i].Quantile > u })
+		lo, hi := points[i-1], points[i]
+
+		return lo.Value + (hi.Value-lo.Value)*(u-lo.Quantile)/(hi.Quantile-lo.Quantile)
+	}, nil
+}
+
+// makeLowerTailFunc returns the exponential tail below the quantile of lo, with the rate between lo and hi
+func makeLowerTailFunc(lo, hi config.QuantilePoint) func(u float64) float64 {
+	scale := (hi.Value - lo.Value) / math.Log(hi.Quantile/lo.Quantile)
+	return func(u float64) float64 {
+		// u is zero only once in 2^53 values, keep it finite
+		u = math.Max(u, math.SmallestNonzeroFloat64)
+		return lo.Value - scale*math.Log(lo.Quantile/u)
+	}
+}
+
+// makeUpperTailFunc returns the exponential tail above the quantile of hi, with the rate between lo and hi
+func makeUpperTailFunc(lo, hi config.QuantilePoint) func(u float64) float64 {
+	scale := (hi.Value - lo.Value) / math.Log((1-lo.Quantile)/(1-hi.Quantile))
+	return func(u float64) float64 {
+		return hi.Value + scale*math.Log((1-hi.Quantile)/(1-u))
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// reservoir is a uniform sample of the values generated so far by a field, of at most size values
+type reservoir struct {
+	values []any
+	// seen is the number of values generated so far
+	seen int64
+}
+
+// add offers value to the sample: once the sample is full, each of the values generated so far has the same
+// probability to be in it.
+func (r *reservoir) add(state *genState, value any, size int) {
+	r.seen++
+	if len(r.values) < size {
+		r.values = append(r.values, value)
+		return
+	}
+
+	if i := state.rand.Int63n(r.seen); i < int64(size) {
+		r.values[i] = value
+	}
+}
+
+// pick returns a value of the sample, when the value of the field is reused with the probability of the config
+func (r *reservoir) pick(state *genState, probability float64) (any, bool) {
+	if len(r.values) == 0 || state.rand.Float64() >= probability {
+		return nil, false
+	}
+
+	return r.values[state.rand.Intn(len(r.values))], true
+}
+
+func reservoirOf(state *genState, fieldName string) *reservoir {
+	r, ok := state.prevCacheReuse[fieldName]
+	if !ok {
+		r = &reservoir{}
+		state.prevCacheReuse[fieldName] = r
+	}
+
+	return r
+}
+
+// bindReuse wraps the emit function already bound for the field, so that with the `reuse` probability the field
+// gets a value it already generated in the run, like a returning visitor or a file hash seen before, instead of
+// a new one.
+func bindReuse(fieldCfg ConfigField, field Field, fieldMap map[string]any, withReturn bool) error {
+	if err := fieldCfg.ValidReuse(); err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	probability, size := fieldCfg.Reuse.Probability, fieldCfg.Reuse.SizeOrDefault()
+
+	if withReturn {
+		boundF := fieldMap[field.Name].(emitF)
+
+		var emitF emitF
+		emitF = func(state *genState) any {
+			r := reservoirOf(state, field.Name)
+			if value, ok := r.pick(state, probability); ok {
+				return value
+			}
+
+			value := boundF(state)
+			r.add(state, value, size)
+			return value
+		}
+
+		fieldMap[field.Name] = emitF
+		return nil
+	}
+
+	boundF := fieldMap[field.Name].(emitFNotReturn)
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		r := reservoirOf(state, field.Name)
+		if value, ok := r.pick(state, probability); ok {
+			buf.Write(value.([]byte))
+			return nil
+		}
+
+		var tmp bytes.Buffer
+		if err := boundF(state, &tmp); err != nil {
+			return err
+		}
+
+		r.add(state, tmp.Bytes(), size)
+		buf.Write(tmp.Bytes())
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// runtimeSourceF returns the text appended to the value of a source field in the current event
+type runtimeSourceF func(state *genState) (string, error)
+
+// bindRuntimeSources wraps the emit functions of the fields with `source_for`, so that their values embed the
+// values of the runtime fields in the same event, formatted as their scripts parse them, like `status=%s` for a
+// runtime field grokking the status code out of `message`. The runtime fields are generated according to their
+// own config, once per event, so that a placeholder of a runtime field writes the value embedded in its source.
+func bindRuntimeSources(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok || len(fieldCfg.SourceFor) == 0 {
+			continue
+		}
+
+		if err := fieldCfg.ValidSourceFor(); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		if !isLocaleFieldType(field.Type) {
+			return fmt.Errorf("field %s: `source_for` is not supported for field type %s", field.Name, field.Type)
+		}
+
+		if _, ok := fieldMap[field.Name]; !ok {
+			return fmt.Errorf("field %s: `source_for` is not supported for a field with `object_keys` or `dynamic_keys`", field.Name)
+		}
+
+		sourceFs := make([]runtimeSourceF, 0, len(fieldCfg.SourceFor))
+		for _, sourceFor := range fieldCfg.SourceFor {
+			if _, ok := fieldMap[sourceFor.Field]; !ok {
+				return fmt.Errorf("field %s: runtime field %s not present in fields definition", field.Name, sourceFor.Field)
+			}
+
+			valueF, err := bindEventRawValue(sourceFor.Field, fieldMap, withReturn)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+
+			sourceFs = append(sourceFs, makeRuntimeSourceFunc(valueF, sourceFor.Format))
+		}
+
+		appendF := makeAppendRuntimeSourcesFunc(sourceFs)
+		if withReturn {
+			boundF := fieldMap[field.Name].(emitF)
+
+			var emitF emitF
+			emitF = func(state *genState) any {
+				// the runtime fields bound with return never fail
+				value, _ := appendF(state, fmt.Sprint(boundF(state)))
+				return value
+			}
+
+			fieldMap[field.Name] = emitF
+			continue
+		}
+
+		boundF := fieldMap[field.Name].(emitFNotReturn)
+
+		var emitFNotReturn emitFNotReturn
+		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+			var tmp bytes.Buffer
+			if err := boundF(state, &tmp); err != nil {
+				return err
+			}
+
+			value, err := appendF(state, tmp.String())
+			if err != nil {
+				return err
+			}
+
+			buf.WriteString(value)
+			return nil
+		}
+
+		fieldMap[field.Name] = emitFNotReturn
+	}
+
+	return nil
+}
+
+// makeRuntimeSourceFunc returns the value of the runtime field in the current event, formatted with format
+func makeRuntimeSourceFunc(valueF func(state *genState) (any, error), format string) runtimeSourceF {
+	if len(format) == 0 {
+		format = "%s"
+	}
+
+	return func(state *genState) (string, error) {
+		value, err := valueF(state)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf(format, fmt.Sprint(value)), nil
+	}
+}
+
+// makeAppendRuntimeSourcesFunc returns the function appending the formatted values of the runtime fields to the
+// value of their source field, separated by spaces
+func makeAppendRuntimeSourcesFunc(sourceFs []runtimeSourceF) func(state *genState, value string) (string, error) {
+	return func(state *genState, value string) (string, error) {
+		parts := make([]string, 0, len(sourceFs)+1)
+		if len(value) > 0 {
+			parts = append(parts, value)
+		}
+
+		for _, sourceF := range sourceFs {
+			part, err := sourceF(state)
+			if err != nil {
+				return "", err
+			}
+
+			parts = append(parts, part)
+		}
+
+		return strings.Join(parts, " "), nil
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// makeNumericSampleFunc returns the function resampling the numeric `samples` of the field: with `smoothing`,
+// normal noise is added to the sampled value, with a standard deviation of `smoothing` times the one of the samples
+func makeNumericSampleFunc(fieldCfg ConfigField, field Field) (func(state *genState) float64, error) {
+	samples := make([]float64, len(fieldCfg.Samples))
+	var sum float64
+	for i, sample := range fieldCfg.Samples {
+		v, err := strconv.ParseFloat(sample, 64)
+		if err != nil {
+			return nil, fmt.Errorf("field %s sample #%d is not a number: %w", field.Name, i, err)
+		}
+
+		samples[i] = v
+		sum += v
+	}
+
+	var bandwidth float64
+	if fieldCfg.Smoothing > 0 {
+		mean := sum / float64(len(samples))
+		var variance float64
+		for _, v := range samples {
+			variance += (v - mean) * (v - mean)
+		}
+
+		bandwidth = fieldCfg.Smoothing * math.Sqrt(variance/float64(len(samples)))
+	}
+
+	return func(state *genState) float64 {
+		v := samples[state.rand.Intn(len(samples))]
+		if bandwidth > 0 {
+			v += state.rand.NormFloat64() * bandwidth
+		}
+
+		return v
+	}, nil
+}
+
+// makeSampleFunc returns the function resampling the `samples` of the field, returning the value as the other
+// bind functions of its type do: int64 for integer types, float64 for float types and string otherwise
+func makeSampleFunc(fieldCfg ConfigField, field Field) (func(state *genState) any, error) {
+	if err := fieldCfg.ValidSamples(); err != nil {
+		return nil, fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	switch {
+	case isIntegerFieldType(field.Type):
+		sampleF, err := makeNumericSampleFunc(fieldCfg, field)
+		if err != nil {
+			return nil, err
+		}
+
+		typeMin, typeMax := getIntTypeBounds(field.Type)
+		return func(state *genState) any {
+			v := math.Round(sampleF(state))
+			switch {
+			case v <= float64(typeMin):
+				return typeMin
+			case v >= float64(typeMax):
+				return typeMax
+			default:
+				return int64(v)
+			}
+		}, nil
+	case isFloatFieldType(field.Type):
+		sampleF, err := makeNumericSampleFunc(fieldCfg, field)
+		if err != nil {
+			return nil, err
+		}
+
+		roundF, _ := makeRoundFloatFunc(fieldCfg, field)
+		typeMin, typeMax := getFloatTypeBounds(field)
+		return func(state *genState) any {
+			return roundF(math.Min(math.Max(sampleF(state), typeMin), typeMax))
+		}, nil
+	case fieldCfg.Smoothing > 0:
+		return nil, fmt.Errorf("field %s: `smoothing` is not supported for field type %s", field.Name, field.Type)
+	default:
+		return func(state *genState) any {
+			return fieldCfg.Samples[state.rand.Intn(len(fieldCfg.Samples))]
+		}, nil
+	}
+}
+
+func bindSamples(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	sampleF, err := makeSampleFunc(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	_, decimals := makeRoundFloatFunc(fieldCfg, field)
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		switch v := sampleF(state).(type) {
+		case int64:
+			buf.WriteString(strconv.FormatInt(v, 10))
+		case float64:
+			return writeFloat(buf, v, decimals)
+		default:
+			buf.WriteString(v.(string))
+		}
+
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
+
+func bindSamplesWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	sampleF, err := makeSampleFunc(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	var emitF emitF
+	emitF = func(state *genState) any {
+		return sampleF(state)
+	}
+
+	fieldMap[field.Name] = emitF
+	return nil
+}
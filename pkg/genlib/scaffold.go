@@ -0,0 +1,81 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// scaffoldArrayLength is the `array_length` suggested, commented out, for the `nested` groups of fields
+const scaffoldArrayLength = "{min: 1, max: 5}"
+
+// ScaffoldConfig writes to w a config file with an entry for each of the fields, as a starting point to customise
+// the generation: the description and the example of each field are written as comments, and the example of a
+// `keyword` or numeric field pre-fills its `enum`, so that the generated values look like the documented ones.
+func ScaffoldConfig(w io.Writer, flds Fields) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "fields:")
+	for i, field := range flds {
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(field.Description), "\n") {
+			if line = strings.TrimSpace(line); len(line) > 0 {
+				fmt.Fprintf(bw, "  # %s\n", line)
+			}
+		}
+
+		example := strings.Join(strings.Fields(field.Example), " ")
+		if len(example) > 0 {
+			fmt.Fprintf(bw, "  # example: %s\n", example)
+		}
+
+		fmt.Fprintf(bw, "  - name: %s\n", field.Name)
+
+		if field.Type == FieldTypeNested {
+			fmt.Fprintf(bw, "    # array_length: %s\n", scaffoldArrayLength)
+		}
+
+		if enum, ok := scaffoldEnum(field); ok {
+			fmt.Fprintf(bw, "    enum: [%s]\n", enum)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// scaffoldEnum returns the `enum` of the field made of its example, when the field type supports `enum` and the
+// example is a single value of the type, and the values of the field are not generated by the `user_agent` generator
+func scaffoldEnum(field Field) (string, bool) {
+	example := strings.TrimSpace(field.Example)
+	if len(example) == 0 || strings.ContainsAny(example, "\n[]{}") || field.Name == userAgentFieldName {
+		return "", false
+	}
+
+	switch {
+	case field.Type == FieldTypeKeyword || field.Type == FieldTypeWildcard:
+		return strconv.Quote(example), true
+	case isIntegerFieldType(field.Type):
+		if _, err := strconv.ParseInt(example, 10, 64); err != nil {
+			return "", false
+		}
+
+		return example, true
+	case isFloatFieldType(field.Type):
+		if _, err := strconv.ParseFloat(example, 64); err != nil {
+			return "", false
+		}
+
+		return example, true
+	default:
+		return "", false
+	}
+}
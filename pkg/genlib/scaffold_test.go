@@ -0,0 +1,72 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+func TestScaffoldConfig(t *testing.T) {
+	flds := Fields{
+		{Name: "dns.answers", Type: FieldTypeNested, Description: "An array containing an object for each answer."},
+		{Name: "dns.answers.ttl", Type: FieldTypeLong, Description: "The time interval in seconds.", Example: "180"},
+		{Name: "event.duration", Type: FieldTypeDouble, Example: "not a number"},
+		{Name: "host.name", Type: FieldTypeKeyword, Description: "Name of the host.\n\nIt can contain what `hostname` returns.\n", Example: `"my host"`},
+		{Name: "tags", Type: FieldTypeKeyword, Example: `["production", "env2"]`},
+		{Name: "source.ip", Type: FieldTypeIP, Example: "10.0.0.1"},
+		{Name: "user_agent.original", Type: FieldTypeKeyword, Example: "Mozilla/5.0"},
+	}
+
+	expected := `fields:
+  # An array containing an object for each answer.
+  - name: dns.answers
+    # array_length: {min: 1, max: 5}
+
+  # The time interval in seconds.
+  # example: 180
+  - name: dns.answers.ttl
+    enum: [180]
+
+  # example: not a number
+  - name: event.duration
+
+  # Name of the host.
+  # It can contain what ` + "`hostname`" + ` returns.
+  # example: "my host"
+  - name: host.name
+    enum: ["\"my host\""]
+
+  # example: ["production", "env2"]
+  - name: tags
+
+  # example: 10.0.0.1
+  - name: source.ip
+
+  # example: Mozilla/5.0
+  - name: user_agent.original
+`
+
+	var buf bytes.Buffer
+	if err := ScaffoldConfig(&buf, flds); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	cfg, err := config.LoadConfigFromYaml(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fieldCfg, ok := cfg.GetField("host.name")
+	if !ok || len(fieldCfg.Enum) != 1 || fieldCfg.Enum[0].Value != `"my host"` {
+		t.Errorf("expected the example as enum of host.name, got %v", fieldCfg.Enum)
+	}
+}
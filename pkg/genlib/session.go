@@ -0,0 +1,141 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// sessionState holds the values of the fields of the current session of a session definition, and the events left
+// before a new session starts
+type sessionState struct {
+	// values are the values of the fields of the session, in the order of the fields of the definition,
+	// nil when not generated yet
+	values    []any
+	remaining int
+	started   bool
+	counter   uint64
+}
+
+// bindSessions replaces the emit functions of the fields of each session, so that consecutive events share their
+// values: a session lasts a number of events drawn from its `length`, and the values of its fields are generated
+// with their own config the first time they are needed in the session.
+func bindSessions(cfg Config, fieldMap map[string]any, withReturn bool) error {
+	correlated := make(map[string]struct{})
+	for _, correlation := range cfg.Correlations() {
+		for _, fieldName := range correlation.Fields {
+			correlated[fieldName] = struct{}{}
+		}
+	}
+
+	inSession := make(map[string]int)
+	for i, session := range cfg.Sessions() {
+		if err := session.Validate(); err != nil {
+			return fmt.Errorf("session #%d: %w", i, err)
+		}
+
+		boundFs := make([]any, 0, len(session.Fields))
+		for _, fieldName := range session.Fields {
+			boundF, ok := fieldMap[fieldName]
+			if !ok {
+				return fmt.Errorf("session #%d: field %s not present in fields definition", i, fieldName)
+			}
+
+			if j, ok := inSession[fieldName]; ok {
+				return fmt.Errorf("session #%d: field %s already in session #%d", i, fieldName, j)
+			}
+
+			if _, ok := correlated[fieldName]; ok {
+				return fmt.Errorf("session #%d: field %s already in a correlation", i, fieldName)
+			}
+
+			inSession[fieldName] = i
+			boundFs = append(boundFs, boundF)
+		}
+
+		values := makeSessionValuesFunc(i, session.Length, boundFs, withReturn)
+		for j, fieldName := range session.Fields {
+			if withReturn {
+				fieldMap[fieldName] = makeCorrelationEmitFWithReturn(values, j)
+			} else {
+				fieldMap[fieldName] = makeCorrelationEmitF(values, j)
+			}
+		}
+	}
+
+	return nil
+}
+
+// makeSessionValuesFunc returns the function providing the values of the session of the event, starting a new
+// session when the current one has no events left, and generating the values with boundFs the first time they are
+// needed in the session
+func makeSessionValuesFunc(sessionIdx int, length config.SessionLength, boundFs []any, withReturn bool) func(state *genState) ([]any, error) {
+	return func(state *genState) ([]any, error) {
+		session, ok := state.prevCacheSession[sessionIdx]
+		if !ok {
+			session = &sessionState{}
+			state.prevCacheSession[sessionIdx] = session
+		}
+
+		if !session.started || session.counter != state.counter {
+			if session.remaining == 0 {
+				session.remaining = randSessionLength(state, length)
+				session.values = nil
+			}
+
+			session.remaining--
+			session.counter = state.counter
+			session.started = true
+		}
+
+		if len(session.values) == len(boundFs) {
+			return session.values, nil
+		}
+
+		values := make([]any, 0, len(boundFs))
+		for _, boundF := range boundFs {
+			if withReturn {
+				values = append(values, boundF.(emitF)(state))
+				continue
+			}
+
+			var tmp bytes.Buffer
+			if err := boundF.(emitFNotReturn)(state, &tmp); err != nil {
+				return nil, err
+			}
+
+			values = append(values, tmp.Bytes())
+		}
+
+		session.values = values
+		return values, nil
+	}
+}
+
+// randSessionLength returns the number of events of a new session: uniform between the min and the max of length,
+// or geometric with its mean when set, capped at the max
+func randSessionLength(state *genState, length config.SessionLength) int {
+	minLength := length.MinOrDefault()
+	if length.Mean == 0 {
+		return minLength + state.rand.Intn(length.Max-minLength+1)
+	}
+
+	if length.Mean <= float64(minLength) {
+		return minLength
+	}
+
+	// geometric distribution over the events after the min, whose mean is the mean of the length minus the min
+	p := 1 / (length.Mean - float64(minLength) + 1)
+	extra := math.Floor(math.Log(1-state.rand.Float64()) / math.Log(1-p))
+	if extra > float64(length.Max-minLength) {
+		return length.Max
+	}
+
+	return minLength + int(extra)
+}
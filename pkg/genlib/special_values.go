@@ -0,0 +1,95 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+)
+
+// bindFloatSpecialValues wraps the emit functions of the float fields so that they never produce NaN or infinite
+// values, unless `allow_nan_inf` is set, and write negative zero as zero when `normalize_negative_zero` is set:
+// NaN is replaced by zero, and infinite values by the bounds of the field type.
+func bindFloatSpecialValues(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) {
+	for _, field := range fields {
+		if !isFloatFieldType(field.Type) {
+			continue
+		}
+
+		fieldCfg, _ := cfg.GetField(field.Name)
+		if fieldCfg.ArrayLength != nil || (fieldCfg.AllowNaNInf && !fieldCfg.NormalizeNegativeZero) {
+			continue
+		}
+
+		boundF, ok := fieldMap[field.Name]
+		if !ok {
+			continue
+		}
+
+		typeMin, typeMax := getFloatTypeBounds(field)
+		normalizeF := func(v float64) float64 {
+			switch {
+			case fieldCfg.AllowNaNInf:
+			case math.IsNaN(v):
+				v = 0
+			case math.IsInf(v, 1):
+				v = typeMax
+			case math.IsInf(v, -1):
+				v = typeMin
+			}
+
+			if fieldCfg.NormalizeNegativeZero && v == 0 {
+				// negative zero is equal to zero
+				v = 0
+			}
+
+			return v
+		}
+
+		if withReturn {
+			boundFWithReturn := boundF.(emitF)
+
+			var emitF emitF
+			emitF = func(state *genState) any {
+				value := boundFWithReturn(state)
+				if v, ok := value.(float64); ok {
+					return normalizeF(v)
+				}
+
+				return value
+			}
+
+			fieldMap[field.Name] = emitF
+			continue
+		}
+
+		_, decimals := makeRoundFloatFunc(fieldCfg, field)
+		boundFNotReturn := boundF.(emitFNotReturn)
+
+		var emitFNotReturn emitFNotReturn
+		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+			offset := buf.Len()
+			if err := boundFNotReturn(state, buf); err != nil {
+				return err
+			}
+
+			v, err := strconv.ParseFloat(string(buf.Bytes()[offset:]), 64)
+			if err != nil {
+				// not a float value, like a constant set in the config
+				return nil
+			}
+
+			if normalized := normalizeF(v); normalized != v || math.Signbit(normalized) != math.Signbit(v) {
+				buf.Truncate(offset)
+				return writeFloat(buf, normalized, decimals)
+			}
+
+			return nil
+		}
+
+		fieldMap[field.Name] = emitFNotReturn
+	}
+}
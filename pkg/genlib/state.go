@@ -0,0 +1,273 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"time"
+)
+
+// stateFormatVersion is increased when the saved state changes in a way older generators cannot load
+const stateFormatVersion = 2
+
+var stateNotCompatible = errors.New("state saved by a generator with another template type or version")
+
+var stateTenantsNotCompatible = errors.New("state saved by a generator with another number of tenants")
+
+func init() {
+	// the concrete types of the values cached in the state, in addition to the ones registered by gob: the arrays,
+	// the objects, the dates, the histograms and the geo points of the text template generator
+	gob.Register([]any{})
+	gob.Register(map[string]any{})
+	gob.Register(time.Time{})
+	gob.Register(histogram{})
+	gob.Register(geoPoint{})
+}
+
+// savedState is the part of the state of a generator carried over from a run to the next one, like the entities of
+// the cardinalities and of the correlations: the event counter, the random number generator and the per-event
+// caches are not, they start again at each run.
+type savedState struct {
+	Version int
+	// WithReturn is true for the state of the text template generator, whose cached values have different types
+	WithReturn bool
+	// previous values, like the last value of counter and fuzziness fields
+	Prev map[string]any
+	// values of the fields with cardinality, like the entities of the run
+	Cardinality map[string][]any
+	// values of the fields with per_run_constant
+	RunConstant map[string]any
+	// running totals by entity of the fields with cumulative_of
+	Cumulative map[string]map[string]float64
+	// last positions by entity of the fields with geo_trajectory
+	GeoTrajectory map[string]map[string]trajectoryPosition
+	// last timestamps by entity of the fields with order
+	Order map[string]map[string]time.Time
+	// last timestamps by entity of the fields with inter_arrival
+	InterArrival map[string]map[string]time.Time
+	// entities generated so far of the correlations, by correlation and by entity
+	Correlation map[int]map[int][]any
+	// sessions in progress, by session
+	Session map[int]savedSession
+	// tenants of the config the state was saved with, zero when none
+	TenantCount int
+	// caches of the tenants after the first one, whose caches are the ones above
+	Tenants []savedState
+}
+
+// savedSession is a session in progress as saved, with the values of its fields, nil when not generated yet, and the
+// events left
+type savedSession struct {
+	Values    []any
+	Remaining int
+}
+
+// save writes the state to w
+func (s *genState) save(w io.Writer, withReturn bool) error {
+	var tenants []savedState
+	for i := 1; i < len(s.tenants); i++ {
+		s.useTenant(i, len(s.tenants))
+		tenants = append(tenants, s.savedCaches())
+	}
+
+	if len(s.tenants) > 0 {
+		s.useTenant(0, len(s.tenants))
+		s.tenant.selected = false
+	}
+
+	saved := s.savedCaches()
+	saved.Version = stateFormatVersion
+	saved.WithReturn = withReturn
+	saved.TenantCount = s.tenantCount
+	saved.Tenants = tenants
+
+	return gob.NewEncoder(w).Encode(saved)
+}
+
+// savedCaches returns the caches of the current tenant to save
+func (s *genState) savedCaches() savedState {
+	saved := savedState{
+		Prev:          s.prevCache,
+		Cardinality:   s.prevCacheCardinality,
+		RunConstant:   s.prevCacheRunConstant,
+		Cumulative:    make(map[string]map[string]float64, len(s.prevCacheCumulative)),
+		GeoTrajectory: make(map[string]map[string]trajectoryPosition, len(s.prevCacheGeoTrajectory)),
+		Order:         s.prevCacheOrder,
+		InterArrival:  s.prevCacheInterArrival,
+		Correlation:   make(map[int]map[int][]any, len(s.prevCacheCorrelation)),
+		Session:       make(map[int]savedSession, len(s.prevCacheSession)),
+	}
+
+	for fieldName, accumulators := range s.prevCacheCumulative {
+		saved.Cumulative[fieldName] = accumulators.totals
+	}
+
+	for fieldName, trajectories := range s.prevCacheGeoTrajectory {
+		saved.GeoTrajectory[fieldName] = trajectories.positions
+	}
+
+	for correlationIdx, pool := range s.prevCacheCorrelation {
+		entities := make(map[int][]any)
+		for i, entity := range pool.entities {
+			if entity != nil {
+				entities[i] = entity
+			}
+		}
+
+		saved.Correlation[correlationIdx] = entities
+	}
+
+	for sessionIdx, session := range s.prevCacheSession {
+		saved.Session[sessionIdx] = savedSession{Values: session.values, Remaining: session.remaining}
+	}
+
+	return saved
+}
+
+// load replaces the state with the one read from r, as written by save
+func (s *genState) load(r io.Reader, withReturn bool) error {
+	var saved savedState
+	if err := gob.NewDecoder(r).Decode(&saved); err != nil {
+		return err
+	}
+
+	if saved.Version != stateFormatVersion || saved.WithReturn != withReturn {
+		return stateNotCompatible
+	}
+
+	// the caches of each tenant are restored to the tenant of the same index
+	if saved.TenantCount != s.tenantCount {
+		return stateTenantsNotCompatible
+	}
+
+	if s.tenantCount > 0 {
+		s.useTenant(0, s.tenantCount)
+		s.tenant.selected = false
+	}
+
+	s.restoreCaches(saved)
+
+	for i, tenant := range saved.Tenants {
+		s.useTenant(i+1, s.tenantCount)
+		s.restoreCaches(tenant)
+	}
+
+	if s.tenantCount > 0 {
+		s.useTenant(0, s.tenantCount)
+	}
+
+	return nil
+}
+
+// restoreCaches replaces the caches of the current tenant with the saved ones
+func (s *genState) restoreCaches(saved savedState) {
+	for fieldName, value := range saved.Prev {
+		s.prevCache[fieldName] = value
+	}
+
+	for fieldName, values := range saved.Cardinality {
+		dup := make(map[any]struct{}, len(values))
+		for _, value := range values {
+			dup[dupKey(value)] = struct{}{}
+		}
+
+		s.prevCacheCardinality[fieldName] = values
+		s.prevCacheForDup[fieldName] = dup
+	}
+
+	for fieldName, value := range saved.RunConstant {
+		s.prevCacheRunConstant[fieldName] = value
+	}
+
+	for fieldName, totals := range saved.Cumulative {
+		s.prevCacheCumulative[fieldName] = &cumulativeAccumulators{totals: totals}
+	}
+
+	for fieldName, positions := range saved.GeoTrajectory {
+		s.prevCacheGeoTrajectory[fieldName] = &geoTrajectories{positions: positions}
+	}
+
+	for fieldName, last := range saved.Order {
+		s.prevCacheOrder[fieldName] = last
+	}
+
+	for fieldName, last := range saved.InterArrival {
+		s.prevCacheInterArrival[fieldName] = last
+	}
+
+	// the pools are resized to the cardinality of the correlations when their entities are first needed
+	for correlationIdx, entities := range saved.Correlation {
+		pool := &correlationPool{}
+		for i, entity := range entities {
+			for len(pool.entities) <= i {
+				pool.entities = append(pool.entities, nil)
+			}
+
+			pool.entities[i] = entity
+		}
+
+		s.prevCacheCorrelation[correlationIdx] = pool
+	}
+
+	// a session in progress carries on with its events left at the first event of the run
+	for sessionIdx, session := range saved.Session {
+		s.prevCacheSession[sessionIdx] = &sessionState{values: session.Values, remaining: session.Remaining}
+	}
+}
+
+// savedHistogram and savedGeoPoint are the histograms and the geo points as saved, with the decimals they are
+// written with
+type savedHistogram struct {
+	Values   []float64
+	Counts   []int64
+	Decimals int
+}
+
+type savedGeoPoint struct {
+	Lat      float64
+	Lon      float64
+	Decimals int
+}
+
+// GobEncode encodes the histogram for the saved state
+func (h histogram) GobEncode() ([]byte, error) {
+	return gobEncode(savedHistogram{Values: h.Values, Counts: h.Counts, Decimals: h.decimals})
+}
+
+// GobDecode decodes the histogram of the saved state
+func (h *histogram) GobDecode(b []byte) error {
+	var saved savedHistogram
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&saved); err != nil {
+		return err
+	}
+
+	*h = histogram{Values: saved.Values, Counts: saved.Counts, decimals: saved.Decimals}
+	return nil
+}
+
+// GobEncode encodes the geo point for the saved state
+func (p geoPoint) GobEncode() ([]byte, error) {
+	return gobEncode(savedGeoPoint{Lat: p.Lat, Lon: p.Lon, Decimals: p.decimals})
+}
+
+// GobDecode decodes the geo point of the saved state
+func (p *geoPoint) GobDecode(b []byte) error {
+	var saved savedGeoPoint
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&saved); err != nil {
+		return err
+	}
+
+	*p = geoPoint{Lat: saved.Lat, Lon: saved.Lon, decimals: saved.Decimals}
+	return nil
+}
+
+func gobEncode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
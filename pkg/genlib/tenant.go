@@ -0,0 +1,175 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// tenantCaches holds the caches of the values generated for a tenant, so that each tenant has its own
+// entities, counters and constants
+type tenantCaches struct {
+	prev          map[string]any
+	forDup        map[string]map[any]struct{}
+	cardinality   map[string][]any
+	runConstant   map[string]any
+	batchConstant map[string]batchConstant
+	cumulative    map[string]*cumulativeAccumulators
+	geoTrajectory map[string]*geoTrajectories
+	hash          map[string]map[string]any
+	order         map[string]map[string]time.Time
+	bucket        map[string]*bucketCursor
+	reuse         map[string]*reservoir
+	interArrival  map[string]map[string]time.Time
+	correlation   map[int]*correlationPool
+	session       map[int]*sessionState
+}
+
+// eventTenant is the tenant selected for the event of counter
+type eventTenant struct {
+	selected bool
+	counter  uint64
+	index    int
+}
+
+// bindTenants binds the fields for each tenant of cfg, and returns the emit functions writing the values of the
+// tenant selected for the event: a tenant is randomly selected once per event, according to the weights.
+func bindTenants(cfg Config, fields Fields, h hooks, withReturn bool) (map[string]any, error) {
+	tenants := cfg.Tenants()
+	tenantMaps := make([]map[string]any, 0, len(tenants))
+	weights := make([]float64, 0, len(tenants))
+	for i, tenant := range tenants {
+		tenantMap, err := bindFields(cfg.WithTenant(i), fields, hooks{}, withReturn)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+
+		tenantMaps = append(tenantMaps, tenantMap)
+		weights = append(weights, tenant.Weight)
+	}
+
+	fieldMap := make(map[string]any)
+	for fieldName := range tenantMaps[0] {
+		if withReturn {
+			fieldMap[fieldName] = makeTenantEmitFWithReturn(fieldName, tenantMaps, weights)
+		} else {
+			fieldMap[fieldName] = makeTenantEmitF(fieldName, tenantMaps, weights)
+		}
+	}
+
+	bindHooks(h, fieldMap, withReturn)
+
+	return fieldMap, nil
+}
+
+func makeTenantEmitF(fieldName string, tenantMaps []map[string]any, weights []float64) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		i := state.selectTenant(weights)
+		return tenantMaps[i][fieldName].(emitFNotReturn)(state, buf)
+	}
+}
+
+func makeTenantEmitFWithReturn(fieldName string, tenantMaps []map[string]any, weights []float64) emitF {
+	return func(state *genState) any {
+		i := state.selectTenant(weights)
+		return tenantMaps[i][fieldName].(emitF)(state)
+	}
+}
+
+// selectTenant returns the tenant of the current event, selecting it at the first field of the event,
+// and swaps in its caches
+func (s *genState) selectTenant(weights []float64) int {
+	if s.tenant.selected && s.tenant.counter == s.counter {
+		return s.tenant.index
+	}
+
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+
+	i := 0
+	for pick := s.rand.Float64() * total; i < len(weights)-1; i++ {
+		pick -= weights[i]
+		if pick < 0 {
+			break
+		}
+	}
+
+	s.useTenant(i, len(weights))
+	s.tenant = eventTenant{selected: true, counter: s.counter, index: i}
+
+	return i
+}
+
+// useTenant swaps the caches of the current tenant with the ones of the i-th tenant, out of n
+func (s *genState) useTenant(i, n int) {
+	for len(s.tenants) < n {
+		s.tenants = append(s.tenants, nil)
+	}
+
+	if i == s.tenant.index {
+		return
+	}
+
+	s.tenants[s.tenant.index] = s.saveTenantCaches()
+
+	caches := s.tenants[i]
+	if caches == nil {
+		caches = s.newTenantCaches()
+	}
+
+	s.restoreTenantCaches(caches)
+	s.tenant.index = i
+}
+
+func (s *genState) saveTenantCaches() *tenantCaches {
+	return &tenantCaches{
+		prev:          s.prevCache,
+		forDup:        s.prevCacheForDup,
+		cardinality:   s.prevCacheCardinality,
+		runConstant:   s.prevCacheRunConstant,
+		batchConstant: s.prevCacheBatchConstant,
+		cumulative:    s.prevCacheCumulative,
+		geoTrajectory: s.prevCacheGeoTrajectory,
+		hash:          s.prevCacheHash,
+		order:         s.prevCacheOrder,
+		bucket:        s.prevCacheBucket,
+		reuse:         s.prevCacheReuse,
+		interArrival:  s.prevCacheInterArrival,
+		correlation:   s.prevCacheCorrelation,
+		session:       s.prevCacheSession,
+	}
+}
+
+func (s *genState) restoreTenantCaches(caches *tenantCaches) {
+	s.prevCache = caches.prev
+	s.prevCacheForDup = caches.forDup
+	s.prevCacheCardinality = caches.cardinality
+	s.prevCacheRunConstant = caches.runConstant
+	s.prevCacheBatchConstant = caches.batchConstant
+	s.prevCacheCumulative = caches.cumulative
+	s.prevCacheGeoTrajectory = caches.geoTrajectory
+	s.prevCacheHash = caches.hash
+	s.prevCacheOrder = caches.order
+	s.prevCacheBucket = caches.bucket
+	s.prevCacheReuse = caches.reuse
+	s.prevCacheInterArrival = caches.interArrival
+	s.prevCacheCorrelation = caches.correlation
+	s.prevCacheSession = caches.session
+}
+
+// newTenantCaches returns empty caches, initialised for the same fields as the current ones
+func (s *genState) newTenantCaches() *tenantCaches {
+	empty := newGenState(0)
+	for fieldName := range s.prevCacheForDup {
+		empty.prevCacheForDup[fieldName] = make(map[any]struct{})
+		empty.prevCacheCardinality[fieldName] = make([]any, 0)
+	}
+
+	return empty.saveTenantCaches()
+}
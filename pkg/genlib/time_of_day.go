@@ -0,0 +1,140 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// timeOfDayWindow is a window of the day, in minutes, with the emit function of the field bound to its config
+type timeOfDayWindow struct {
+	from, to int
+	boundF   any
+}
+
+// contains reports whether the minute of the day is within the window, that spans midnight when to is before from
+func (w timeOfDayWindow) contains(minute int) bool {
+	if w.from < w.to {
+		return minute >= w.from && minute < w.to
+	}
+
+	return minute >= w.from || minute < w.to
+}
+
+// bindTimeOfDayFields replaces the emit functions of the fields with `time_of_day`, so that their value is
+// generated with the config of the window of the day the timestamp of the event falls within, if any.
+func bindTimeOfDayFields(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	fieldsByName := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		fieldsByName[field.Name] = field
+	}
+
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok {
+			continue
+		}
+
+		if err := fieldCfg.ValidTimeOfDay(); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		if fieldCfg.TimeOfDay == nil {
+			continue
+		}
+
+		timestampField, ok := fieldsByName[fieldCfg.TimeOfDay.Timestamp]
+		if !ok {
+			return fmt.Errorf("field %s: timestamp field %s not present in fields definition", field.Name, fieldCfg.TimeOfDay.Timestamp)
+		}
+
+		if timestampField.Type != FieldTypeDate {
+			return fmt.Errorf("field %s: timestamp field %s must have the %s type", field.Name, timestampField.Name, FieldTypeDate)
+		}
+
+		windows := make([]timeOfDayWindow, 0, len(fieldCfg.TimeOfDay.Windows))
+		for i, window := range fieldCfg.TimeOfDay.Windows {
+			from, to, _ := window.Minutes()
+
+			windowMap := make(map[string]any)
+			if err := bindField(cfg.WithField(field.Name, window.Field), field, windowMap, withReturn); err != nil {
+				return fmt.Errorf("field %s: time_of_day window #%d: %w", field.Name, i, err)
+			}
+
+			windows = append(windows, timeOfDayWindow{from: from, to: to, boundF: windowMap[field.Name]})
+		}
+
+		timestampF, err := bindEventRawValue(timestampField.Name, fieldMap, withReturn)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		windowF := makeTimeOfDayFunc(fieldMap[field.Name], windows, timestampF)
+		if withReturn {
+			fieldMap[field.Name] = makeTimeOfDayEmitFWithReturn(windowF)
+		} else {
+			fieldMap[field.Name] = makeTimeOfDayEmitF(windowF)
+		}
+	}
+
+	return nil
+}
+
+// makeTimeOfDayFunc returns the function returning the emit function of the window the timestamp of the
+// event falls within, or boundF when none
+func makeTimeOfDayFunc(boundF any, windows []timeOfDayWindow, timestampF func(state *genState) (any, error)) func(state *genState) (any, error) {
+	return func(state *genState) (any, error) {
+		value, err := timestampF(state)
+		if err != nil {
+			return nil, err
+		}
+
+		var t time.Time
+		switch v := value.(type) {
+		case time.Time:
+			t = v
+		case string:
+			if t, err = time.Parse(FieldTypeTimeLayout, v); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("cannot use %v as timestamp", value)
+		}
+
+		t = t.UTC()
+		minute := t.Hour()*60 + t.Minute()
+		for _, window := range windows {
+			if window.contains(minute) {
+				return window.boundF, nil
+			}
+		}
+
+		return boundF, nil
+	}
+}
+
+func makeTimeOfDayEmitF(windowF func(state *genState) (any, error)) emitFNotReturn {
+	return func(state *genState, buf *bytes.Buffer) error {
+		boundF, err := windowF(state)
+		if err != nil {
+			return err
+		}
+
+		return boundF.(emitFNotReturn)(state, buf)
+	}
+}
+
+func makeTimeOfDayEmitFWithReturn(windowF func(state *genState) (any, error)) emitF {
+	return func(state *genState) any {
+		boundF, err := windowF(state)
+		if err != nil {
+			panic(err)
+		}
+
+		return boundF.(emitF)(state)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeline tracks the steps of the config timeline applied to a generator
+type timeline struct {
+	// the config without any step applied
+	cfg Config
+	// the index of the next step to apply
+	next int
+	// the time of the first event, the origin of the `at` steps
+	start time.Time
+	// now returns the current time, time.Now when nil
+	now func() time.Time
+}
+
+// advance returns the config to apply before generating the event and the steps applied, when new steps are due.
+// The steps are applied in order, each one once its `at_event` or its `at` is reached.
+func (t *timeline) advance(counter uint64) (Config, []TimelineStep, bool) {
+	now := time.Now
+	if t.now != nil {
+		now = t.now
+	}
+
+	if t.start.IsZero() {
+		t.start = now()
+	}
+
+	steps := t.cfg.Timeline()
+	if t.next == len(steps) {
+		return Config{}, nil, false
+	}
+
+	current := now()
+
+	applied := t.next
+	for t.next < len(steps) && steps[t.next].Due(counter, current.Sub(t.start)) {
+		t.next++
+	}
+
+	if t.next == applied {
+		return Config{}, nil, false
+	}
+
+	return t.cfg.WithTimelineSteps(t.next), steps[applied:t.next], true
+}
+
+// validateTimeline binds the config in effect after each step of the timeline,
+// so that an invalid step is reported before the generation starts.
+func validateTimeline(cfg Config, fields Fields, withReturn bool) error {
+	for i := range cfg.Timeline() {
+		if _, err := bindFields(cfg.WithTimelineSteps(i+1), fields, hooks{}, withReturn); err != nil {
+			return fmt.Errorf("timeline step #%d: %w", i, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,574 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+const (
+	tlsVersion12 uint16 = 0x0303
+	tlsVersion13 uint16 = 0x0304
+)
+
+// tlsCipherNames are the IANA names of the cipher suites of the profiles, by code
+var tlsCipherNames = map[uint16]string{
+	4865:  "TLS_AES_128_GCM_SHA256",
+	4866:  "TLS_AES_256_GCM_SHA384",
+	4867:  "TLS_CHACHA20_POLY1305_SHA256",
+	47:    "TLS_RSA_WITH_AES_128_CBC_SHA",
+	53:    "TLS_RSA_WITH_AES_256_CBC_SHA",
+	60:    "TLS_RSA_WITH_AES_128_CBC_SHA256",
+	61:    "TLS_RSA_WITH_AES_256_CBC_SHA256",
+	156:   "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	157:   "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	49161: "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	49162: "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	49171: "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	49172: "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	49187: "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256",
+	49188: "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384",
+	49191: "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256",
+	49192: "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384",
+	49195: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	49196: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	49199: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	49200: "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	52392: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	52393: "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+}
+
+// tlsCurveNames are the names of the supported groups of the profiles, by code
+var tlsCurveNames = map[uint16]string{
+	23:  "secp256r1",
+	24:  "secp384r1",
+	25:  "secp521r1",
+	29:  "x25519",
+	30:  "x448",
+	256: "ffdhe2048",
+	257: "ffdhe3072",
+}
+
+// tlsClient is a client profile of the `tls` generator: the highest TLS version it supports, and the content of its
+// ClientHello the JA3 fingerprint is computed from
+type tlsClient struct {
+	maxVersion   uint16
+	ciphers      []uint16
+	extensions   []uint16
+	curves       []uint16
+	pointFormats []uint16
+}
+
+// tlsClients are the client profiles, by name, without the GREASE values of the browsers
+var tlsClients = map[string]tlsClient{
+	"chrome": {
+		maxVersion:   tlsVersion13,
+		ciphers:      []uint16{4865, 4866, 4867, 49195, 49199, 49196, 49200, 52393, 52392, 49171, 49172, 156, 157, 47, 53},
+		extensions:   []uint16{0, 23, 65281, 10, 11, 35, 16, 5, 13, 18, 51, 45, 43, 27, 17513, 21},
+		curves:       []uint16{29, 23, 24},
+		pointFormats: []uint16{0},
+	},
+	"firefox": {
+		maxVersion:   tlsVersion13,
+		ciphers:      []uint16{4865, 4867, 4866, 49195, 49199, 52393, 52392, 49196, 49200, 49162, 49161, 49171, 49172, 156, 157, 47, 53},
+		extensions:   []uint16{0, 23, 65281, 10, 11, 35, 16, 5, 34, 51, 43, 13, 45, 28, 21},
+		curves:       []uint16{29, 23, 24, 25, 256, 257},
+		pointFormats: []uint16{0},
+	},
+	"safari": {
+		maxVersion:   tlsVersion13,
+		ciphers:      []uint16{4865, 4866, 4867, 49196, 49195, 52393, 49200, 49199, 52392, 49162, 49161, 49172, 49171, 157, 156, 53, 47},
+		extensions:   []uint16{0, 23, 65281, 10, 11, 16, 5, 13, 18, 51, 45, 43, 27, 21},
+		curves:       []uint16{29, 23, 24, 25},
+		pointFormats: []uint16{0},
+	},
+	"curl": {
+		maxVersion:   tlsVersion13,
+		ciphers:      []uint16{4866, 4867, 4865, 49196, 49200, 52393, 52392, 49195, 49199, 49188, 49192, 49162, 49172, 157, 156, 61, 60, 53, 47},
+		extensions:   []uint16{0, 11, 10, 35, 22, 23, 13, 43, 45, 51},
+		curves:       []uint16{29, 23, 30, 25, 24},
+		pointFormats: []uint16{0, 1, 2},
+	},
+	"python": {
+		maxVersion:   tlsVersion13,
+		ciphers:      []uint16{4866, 4867, 4865, 49196, 49200, 49195, 49199, 52393, 52392, 49188, 49192, 49187, 49191, 49162, 49172, 49161, 49171, 157, 156, 61, 60, 53, 47},
+		extensions:   []uint16{0, 11, 10, 16, 22, 23, 49, 13, 43, 45, 51},
+		curves:       []uint16{29, 23, 30, 25, 24},
+		pointFormats: []uint16{0, 1, 2},
+	},
+	"go": {
+		maxVersion:   tlsVersion13,
+		ciphers:      []uint16{49195, 49199, 49196, 49200, 52393, 52392, 49161, 49171, 49162, 49172, 156, 157, 47, 53, 4865, 4866, 4867},
+		extensions:   []uint16{0, 5, 10, 11, 13, 65281, 18, 43, 51},
+		curves:       []uint16{29, 23, 24, 25},
+		pointFormats: []uint16{0},
+	},
+	"java": {
+		maxVersion:   tlsVersion12,
+		ciphers:      []uint16{49196, 49195, 49200, 49199, 157, 156, 49188, 49192, 61, 49187, 49191, 60, 49162, 49172, 53, 49161, 49171, 47},
+		extensions:   []uint16{10, 11, 13, 23, 65281},
+		curves:       []uint16{23, 24, 25},
+		pointFormats: []uint16{0},
+	},
+}
+
+// defaultTLSClients are the approximate shares of the clients of the TLS traffic
+var defaultTLSClients = map[string]float64{"chrome": 50, "safari": 20, "firefox": 8, "curl": 7, "python": 7, "go": 5, "java": 3}
+
+// tlsServer is a server profile of the `tls` generator: the highest TLS version it supports, the cipher suites and
+// the curves in order of preference, and the extensions of its ServerHello, by version, the JA3S fingerprint is
+// computed from
+type tlsServer struct {
+	maxVersion uint16
+	ciphers    []uint16
+	curves     []uint16
+	extensions map[uint16][]uint16
+}
+
+// tlsServers are the server profiles, each server name using the one its hash picks
+var tlsServers = []tlsServer{
+	// nginx with OpenSSL
+	{
+		maxVersion: tlsVersion13,
+		ciphers:    []uint16{4866, 4867, 4865, 49199, 49200, 52392, 49171, 49172, 156, 157, 47, 53},
+		curves:     []uint16{29, 23, 30, 25, 24},
+		extensions: map[uint16][]uint16{tlsVersion13: {43, 51}, tlsVersion12: {65281, 0, 11, 35, 23}},
+	},
+	// a CDN edge
+	{
+		maxVersion: tlsVersion13,
+		ciphers:    []uint16{4865, 4867, 4866, 49199, 52392, 49200, 49171, 49172, 156, 157, 47, 53},
+		curves:     []uint16{29, 23, 24},
+		extensions: map[uint16][]uint16{tlsVersion13: {51, 43}, tlsVersion12: {0, 65281, 11, 35, 16, 23}},
+	},
+	// IIS on Windows Server 2016
+	{
+		maxVersion: tlsVersion12,
+		ciphers:    []uint16{49200, 49199, 49192, 49191, 49172, 49171, 157, 156, 61, 60, 53, 47},
+		curves:     []uint16{29, 23, 24},
+		extensions: map[uint16][]uint16{tlsVersion12: {65281, 23}},
+	},
+}
+
+// tlsCA is a certificate authority of the pool of the `tls` generator, with the validity of its certificates
+type tlsCA struct {
+	issuer   string
+	validity time.Duration
+}
+
+// tlsCAs are the certificate authorities the certificates of the servers are issued by
+var tlsCAs = []tlsCA{
+	{issuer: "CN=R3,O=Let's Encrypt,C=US", validity: 90 * 24 * time.Hour},
+	{issuer: "CN=GTS CA 1C3,O=Google Trust Services LLC,C=US", validity: 90 * 24 * time.Hour},
+	{issuer: "CN=DigiCert Global G2 TLS RSA SHA256 2020 CA1,O=DigiCert Inc,C=US", validity: 365 * 24 * time.Hour},
+	{issuer: "CN=Amazon RSA 2048 M01,O=Amazon,C=US", validity: 395 * 24 * time.Hour},
+	{issuer: "CN=Sectigo RSA Domain Validation Secure Server CA,O=Sectigo Limited,L=Salford,ST=Greater Manchester,C=GB", validity: 365 * 24 * time.Hour},
+}
+
+// tlsHandshake is the handshake of an event, with all the parts of the `tls` generator
+type tlsHandshake struct {
+	version    uint16
+	cipher     uint16
+	curve      uint16
+	ja3        string
+	ja3s       string
+	serverName string
+	issuer     string
+	notBefore  time.Time
+	notAfter   time.Time
+	sha256     string
+}
+
+// tlsHandshakeCache is the handshake of the last event the `tls` fields were generated in
+type tlsHandshakeCache struct {
+	initialised bool
+	counter     uint64
+	current     tlsHandshake
+}
+
+// tlsModel are the settings of the handshakes, shared by all the `tls` fields
+type tlsModel struct {
+	clients     map[string]float64
+	serverNames []string
+	timestamp   string
+}
+
+// tlsPartOf returns the part of the handshake of the field: the one of its config, or else the one of the ECS field
+// with the same name, like `client.ja3` for `tls.client.ja3`
+func tlsPartOf(fieldCfg ConfigField, field Field) string {
+	if fieldCfg.TLS != nil && len(fieldCfg.TLS.Part) > 0 {
+		return fieldCfg.TLS.Part
+	}
+
+	for _, part := range config.TLSParts {
+		if field.Name == "tls."+part || strings.HasSuffix(field.Name, ".tls."+part) {
+			return part
+		}
+	}
+
+	return ""
+}
+
+// bindTLSFields replaces the emit functions of the fields with `generator: tls`, so that the version, the cipher,
+// the JA3 and JA3S fingerprints and the certificate of the server of an event are those of the same handshake,
+// negotiated between a client and a server profile, like the fingerprint-based detections expect.
+func bindTLSFields(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	fieldsByName := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		fieldsByName[field.Name] = field
+	}
+
+	var tlsFields []Field
+	var model tlsModel
+	// the fields each setting of the handshake was first set on: the settings can be set on any of the fields, but
+	// they must agree
+	settingFields := make(map[string]string)
+	checkSetting := func(setting, fieldName string, equal bool) error {
+		other, ok := settingFields[setting]
+		if !ok {
+			settingFields[setting] = fieldName
+			return nil
+		}
+
+		if equal {
+			return nil
+		}
+
+		return fmt.Errorf("field %s: `tls.%s` differs from the one of field %s, the handshake is shared by all the `tls` fields", fieldName, setting, other)
+	}
+
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok || fieldCfg.Generator != config.GeneratorTLS {
+			continue
+		}
+
+		tlsFields = append(tlsFields, field)
+		if fieldCfg.TLS == nil {
+			continue
+		}
+
+		if fieldCfg.TLS.Clients != nil {
+			if err := checkSetting("clients", field.Name, model.clients == nil || reflect.DeepEqual(model.clients, fieldCfg.TLS.Clients)); err != nil {
+				return err
+			}
+
+			model.clients = fieldCfg.TLS.Clients
+		}
+
+		if fieldCfg.TLS.ServerNames != nil {
+			if err := checkSetting("server_names", field.Name, model.serverNames == nil || reflect.DeepEqual(model.serverNames, fieldCfg.TLS.ServerNames)); err != nil {
+				return err
+			}
+
+			model.serverNames = fieldCfg.TLS.ServerNames
+		}
+
+		if len(fieldCfg.TLS.Timestamp) > 0 {
+			if err := checkSetting("timestamp", field.Name, len(model.timestamp) == 0 || model.timestamp == fieldCfg.TLS.Timestamp); err != nil {
+				return err
+			}
+
+			model.timestamp = fieldCfg.TLS.Timestamp
+		}
+	}
+
+	if len(tlsFields) == 0 {
+		return nil
+	}
+
+	handshakeF, err := makeTLSHandshakeFunc(model, tlsFields[0].Name, fieldsByName, fieldMap, withReturn)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range tlsFields {
+		fieldCfg, _ := cfg.GetField(field.Name)
+
+		part := tlsPartOf(fieldCfg, field)
+		if len(part) == 0 {
+			return fmt.Errorf("field %s: the `tls` generator requires `tls.part`, one of %s, for a field not named after it", field.Name, strings.Join(config.TLSParts, ", "))
+		}
+
+		isDate := part == config.TLSPartServerNotBefore || part == config.TLSPartServerNotAfter
+		if isDate && field.Type != FieldTypeDate {
+			return fmt.Errorf("field %s: the `tls` part %s requires the %s field type", field.Name, part, FieldTypeDate)
+		}
+
+		if !isDate && !isLocaleFieldType(field.Type) {
+			return fmt.Errorf("field %s: the `tls` part %s is not supported for field type %s", field.Name, part, field.Type)
+		}
+
+		partF := func(state *genState) (any, error) {
+			handshake, err := handshakeF(state)
+			if err != nil {
+				return nil, err
+			}
+
+			return handshake.part(part), nil
+		}
+
+		if withReturn {
+			var emitF emitF
+			emitF = func(state *genState) any {
+				value, err := partF(state)
+				if err != nil {
+					panic(err)
+				}
+
+				return value
+			}
+
+			fieldMap[field.Name] = emitF
+		} else {
+			var emitFNotReturn emitFNotReturn
+			emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+				value, err := partF(state)
+				if err != nil {
+					return err
+				}
+
+				if t, ok := value.(time.Time); ok {
+					buf.WriteString(t.Format(FieldTypeTimeLayout))
+				} else {
+					buf.WriteString(value.(string))
+				}
+
+				return nil
+			}
+
+			fieldMap[field.Name] = emitFNotReturn
+		}
+	}
+
+	return nil
+}
+
+// makeTLSHandshakeFunc returns the function drawing the handshake of the current event, once per event: the client
+// profile is picked according to the weights of the model and the server name uniformly, and the server profile and
+// the certificate of the server are the ones of its name.
+func makeTLSHandshakeFunc(model tlsModel, fieldName string, fieldsByName map[string]Field, fieldMap map[string]any, withReturn bool) (func(state *genState) (tlsHandshake, error), error) {
+	clients := defaultTLSClients
+	if model.clients != nil {
+		clients = model.clients
+	}
+
+	serverNames := defaultURLDomains
+	if model.serverNames != nil {
+		serverNames = model.serverNames
+	}
+
+	names := make([]string, 0, len(clients))
+	cumulative := make([]float64, 0, len(clients))
+	var total float64
+	for _, name := range sortedKeys(clients) {
+		if _, ok := tlsClients[name]; !ok {
+			return nil, fmt.Errorf("field %s: unknown `tls` client %s, must be one of %s", fieldName, name, strings.Join(sortedKeys(tlsClients), ", "))
+		}
+
+		if clients[name] <= 0 {
+			continue
+		}
+
+		total += clients[name]
+		names = append(names, name)
+		cumulative = append(cumulative, total)
+	}
+
+	ja3s := make(map[string]string, len(names))
+	for _, name := range names {
+		ja3s[name] = tlsClients[name].ja3()
+	}
+
+	timestampF := func(state *genState) (time.Time, error) {
+		return timeNowToBind, nil
+	}
+
+	if len(model.timestamp) > 0 {
+		timestampField, ok := fieldsByName[model.timestamp]
+		if _, bound := fieldMap[model.timestamp]; !ok || !bound {
+			return nil, fmt.Errorf("field %s: timestamp field %s not present in fields definition", fieldName, model.timestamp)
+		}
+
+		if timestampField.Type != FieldTypeDate {
+			return nil, fmt.Errorf("field %s: timestamp field %s must have the %s type", fieldName, timestampField.Name, FieldTypeDate)
+		}
+
+		valueF, err := bindEventRawValue(model.timestamp, fieldMap, withReturn)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", fieldName, err)
+		}
+
+		timestampF = func(state *genState) (time.Time, error) {
+			return eventTime(state, valueF)
+		}
+	}
+
+	return func(state *genState) (tlsHandshake, error) {
+		cache := &state.prevCacheTLS
+		if cache.initialised && cache.counter == state.counter {
+			return cache.current, nil
+		}
+
+		t, err := timestampF(state)
+		if err != nil {
+			return tlsHandshake{}, err
+		}
+
+		client := names[sort.SearchFloat64s(cumulative, state.rand.Float64()*total)]
+		serverName := serverNames[state.rand.Intn(len(serverNames))]
+
+		handshake := negotiateTLS(tlsClients[client], serverName, t)
+		handshake.ja3 = ja3s[client]
+
+		cache.initialised = true
+		cache.counter = state.counter
+		cache.current = handshake
+		return handshake, nil
+	}, nil
+}
+
+// negotiateTLS returns the handshake of the client with the server named serverName at t: the version is the
+// highest both support, the cipher suite and the curve the first of the preferences of the server the client
+// offers, and the certificate is the one the server has at t.
+func negotiateTLS(client tlsClient, serverName string, t time.Time) tlsHandshake {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(serverName))
+	sum := h.Sum64()
+
+	server := tlsServers[sum%uint64(len(tlsServers))]
+
+	handshake := tlsHandshake{version: tlsVersion12, serverName: serverName}
+	if client.maxVersion == tlsVersion13 && server.maxVersion == tlsVersion13 {
+		handshake.version = tlsVersion13
+	}
+
+	for _, cipher := range server.ciphers {
+		if isTLS13Cipher(cipher) == (handshake.version == tlsVersion13) && containsCode(client.ciphers, cipher) {
+			handshake.cipher = cipher
+			break
+		}
+	}
+
+	// the curves are only negotiated for the ephemeral key exchanges
+	if handshake.version == tlsVersion13 || strings.Contains(tlsCipherNames[handshake.cipher], "_ECDHE_") {
+		for _, curve := range server.curves {
+			if containsCode(client.curves, curve) {
+				handshake.curve = curve
+				break
+			}
+		}
+	}
+
+	// the legacy version of the ServerHello is TLS 1.2 for TLS 1.3 too, the actual version being in its extensions
+	handshake.ja3s = md5Hex(fmt.Sprintf("%d,%d,%s", tlsVersion12, handshake.cipher, joinCodes(server.extensions[handshake.version])))
+
+	// the certificates are renewed after two thirds of their validity, at times that differ by server name
+	ca := tlsCAs[(sum>>16)%uint64(len(tlsCAs))]
+	renewal := ca.validity * 2 / 3
+	offset := time.Duration((sum>>32)%uint64(renewal/time.Second)) * time.Second
+	elapsed := (t.Sub(time.Unix(0, 0)) - offset) % renewal
+	if elapsed < 0 {
+		elapsed += renewal
+	}
+
+	handshake.issuer = ca.issuer
+	handshake.notBefore = t.Add(-elapsed).UTC()
+	handshake.notAfter = handshake.notBefore.Add(ca.validity)
+
+	fingerprint := sha256.Sum256([]byte(handshake.subject() + "\n" + handshake.issuer + "\n" + handshake.notBefore.Format(time.RFC3339)))
+	handshake.sha256 = strings.ToUpper(hex.EncodeToString(fingerprint[:]))
+
+	return handshake
+}
+
+// part returns the value of the part of the handshake, a time.Time for the validity of the certificate
+func (h tlsHandshake) part(part string) any {
+	switch part {
+	case config.TLSPartVersion:
+		if h.version == tlsVersion13 {
+			return "1.3"
+		}
+
+		return "1.2"
+	case config.TLSPartVersionProtocol:
+		return "tls"
+	case config.TLSPartCipher:
+		return tlsCipherNames[h.cipher]
+	case config.TLSPartCurve:
+		return tlsCurveNames[h.curve]
+	case config.TLSPartClientJA3:
+		return h.ja3
+	case config.TLSPartClientServerName:
+		return h.serverName
+	case config.TLSPartServerJA3S:
+		return h.ja3s
+	case config.TLSPartServerSubject:
+		return h.subject()
+	case config.TLSPartServerIssuer:
+		return h.issuer
+	case config.TLSPartServerNotBefore:
+		return h.notBefore
+	case config.TLSPartServerNotAfter:
+		return h.notAfter
+	case config.TLSPartServerSHA256:
+		return h.sha256
+	default:
+		return ""
+	}
+}
+
+// subject returns the distinguished name of the subject of the certificate of the server
+func (h tlsHandshake) subject() string {
+	return "CN=" + h.serverName
+}
+
+// ja3 returns the JA3 fingerprint of the ClientHello of the client: the MD5 of its version, cipher suites,
+// extensions, curves and point formats
+func (c tlsClient) ja3() string {
+	return md5Hex(fmt.Sprintf("%d,%s,%s,%s,%s", tlsVersion12, joinCodes(c.ciphers), joinCodes(c.extensions), joinCodes(c.curves), joinCodes(c.pointFormats)))
+}
+
+// isTLS13Cipher reports whether the cipher suite is one of TLS 1.3, that only TLS 1.3 negotiates
+func isTLS13Cipher(cipher uint16) bool {
+	return cipher>>8 == 0x13
+}
+
+func containsCode(codes []uint16, code uint16) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// joinCodes joins the codes with dashes, like in the JA3 strings
+func joinCodes(codes []uint16) string {
+	values := make([]string, len(codes))
+	for i, code := range codes {
+		values[i] = strconv.Itoa(int(code))
+	}
+
+	return strings.Join(values, "-")
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
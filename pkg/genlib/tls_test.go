@@ -0,0 +1,69 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNegotiateTLS(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for name, client := range tlsClients {
+		for _, serverName := range defaultURLDomains {
+			handshake := negotiateTLS(client, serverName, now)
+
+			if _, ok := tlsCipherNames[handshake.cipher]; !ok {
+				t.Fatalf("Expected %s and %s to agree on a cipher, got %d", name, serverName, handshake.cipher)
+			}
+
+			if isTLS13Cipher(handshake.cipher) != (handshake.version == tlsVersion13) {
+				t.Errorf("Expected the cipher %s to be of the version %x", tlsCipherNames[handshake.cipher], handshake.version)
+			}
+
+			if client.maxVersion == tlsVersion12 && handshake.version != tlsVersion12 {
+				t.Errorf("Expected %s to negotiate TLS 1.2, got %x", name, handshake.version)
+			}
+
+			if _, ok := tlsCurveNames[handshake.curve]; !ok {
+				t.Errorf("Expected %s and %s to agree on a curve, got %d", name, serverName, handshake.curve)
+			}
+
+			if now.Before(handshake.notBefore) || !now.Before(handshake.notAfter) {
+				t.Errorf("Expected the certificate of %s to be valid at %s, got from %s to %s", serverName, now, handshake.notBefore, handshake.notAfter)
+			}
+
+			// the certificate of the server is the same until it's renewed
+			later := negotiateTLS(client, serverName, now.Add(time.Hour))
+			if later.sha256 != handshake.sha256 && !later.notBefore.After(now) {
+				t.Errorf("Expected the certificate of %s not to change within an hour, got %s and %s", serverName, handshake.sha256, later.sha256)
+			}
+		}
+	}
+}
+
+func TestTLSClientJA3(t *testing.T) {
+	client := tlsClient{
+		maxVersion:   tlsVersion12,
+		ciphers:      []uint16{49199, 47},
+		extensions:   []uint16{0, 10, 11},
+		curves:       []uint16{29, 23},
+		pointFormats: []uint16{0},
+	}
+
+	if expected, got := md5Hex("771,49199-47,0-10-11,29-23,0"), client.ja3(); got != expected {
+		t.Errorf("Expected JA3 %s, got %s", expected, got)
+	}
+
+	seen := make(map[string]string)
+	for name, client := range tlsClients {
+		if other, ok := seen[client.ja3()]; ok {
+			t.Errorf("Expected %s and %s to have different JA3 fingerprints", name, other)
+		}
+
+		seen[client.ja3()] = name
+	}
+}
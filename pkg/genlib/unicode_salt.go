@@ -0,0 +1,113 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// unicodeSalts are the edge-case sequences injected in the string values salted by `unicode_salt`
+var unicodeSalts = []string{
+	"\U0001f600",                     // emoji, 4-byte UTF-8 sequence
+	"\U0001f469\u200d\U0001f4bb",     // emojis joined by a zero-width joiner
+	"\U0001f1ee\U0001f1f9",           // flag, pair of regional indicators
+	"\U0001f44d\U0001f3fd",           // emoji with a skin tone modifier
+	"\u200d",                         // zero-width joiner
+	"\u200b",                         // zero-width space
+	"\u200f",                         // right-to-left mark
+	"\u202eabc\u202c",                // right-to-left override
+	"\ufeff",                         // byte order mark
+	"e\u0301",                        // combining acute accent
+	"\U0001d518\U0001d52b",           // mathematical fraktur, outside the BMP
+	"\u0645\u0631\u062d\u0628\u0627", // right-to-left text
+}
+
+// saltString injects one of the unicodeSalts at a random rune boundary of value
+func saltString(state *genState, value string) string {
+	salt := unicodeSalts[state.rand.Intn(len(unicodeSalts))]
+
+	at := state.rand.Intn(utf8.RuneCountInString(value) + 1)
+	for i := range value {
+		if at == 0 {
+			return value[:i] + salt + value[i:]
+		}
+
+		at--
+	}
+
+	return value + salt
+}
+
+// bindUnicodeSalt wraps the emit functions of the string fields with `unicode_salt`, so that the given fraction
+// of their values gets an edge-case unicode sequence injected, to test ingest pipelines and rendering against them.
+func bindUnicodeSalt(cfg Config, fields Fields, fieldMap map[string]any, withReturn bool) error {
+	for _, field := range fields {
+		fieldCfg, ok := cfg.GetField(field.Name)
+		if !ok {
+			continue
+		}
+
+		if err := fieldCfg.ValidUnicodeSalt(); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		if fieldCfg.UnicodeSalt == 0 {
+			continue
+		}
+
+		if !isLocaleFieldType(field.Type) {
+			return fmt.Errorf("field %s: `unicode_salt` is not supported for field type %s", field.Name, field.Type)
+		}
+
+		boundF, ok := fieldMap[field.Name]
+		if !ok || fieldCfg.ArrayLength != nil {
+			continue
+		}
+
+		fraction := fieldCfg.UnicodeSalt
+		if withReturn {
+			boundFWithReturn := boundF.(emitF)
+
+			var emitF emitF
+			emitF = func(state *genState) any {
+				value := boundFWithReturn(state)
+				if v, ok := value.(string); ok && state.rand.Float64() < fraction {
+					return saltString(state, v)
+				}
+
+				return value
+			}
+
+			fieldMap[field.Name] = emitF
+			continue
+		}
+
+		boundFNotReturn := boundF.(emitFNotReturn)
+
+		var emitFNotReturn emitFNotReturn
+		emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+			offset := buf.Len()
+			if err := boundFNotReturn(state, buf); err != nil {
+				return err
+			}
+
+			if state.rand.Float64() >= fraction {
+				return nil
+			}
+
+			salted := saltString(state, string(buf.Bytes()[offset:]))
+			buf.Truncate(offset)
+			buf.WriteString(salted)
+
+			return nil
+		}
+
+		fieldMap[field.Name] = emitFNotReturn
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+var defaultURLSchemes = []string{"https", "http"}
+var defaultURLDomains = []string{"example.com", "www.example.com", "api.example.com", "shop.example.org", "cdn.example.net", "docs.example.io"}
+var defaultURLPathDepth = config.CountRange{Min: 1, Max: 3}
+var defaultURLQueryParams = config.CountRange{Min: 0, Max: 2}
+
+// urlPathSegments are the words the segments of the paths are picked among, when they are not ids
+var urlPathSegments = []string{
+	"api", "v1", "v2", "users", "orders", "products", "search", "static", "images", "assets", "login", "logout",
+	"account", "cart", "checkout", "docs", "blog", "admin", "items", "reports", "settings", "health", "metrics",
+}
+
+// urlQueryKeys are the keys the parameters of the queries are picked among
+var urlQueryKeys = []string{"id", "q", "page", "limit", "offset", "sort", "lang", "ref", "utm_source", "session", "filter", "format"}
+
+// urlIDSegmentProbability is the probability of a segment of a path to be a numeric id, like `/users/1234`
+const urlIDSegmentProbability = 0.2
+
+// urlPartSuffixes are the parts of the URL generated by default for the ECS fields with these suffixes
+var urlPartSuffixes = map[string]string{
+	".domain": config.URLPartDomain,
+	".path":   config.URLPartPath,
+	".query":  config.URLPartQuery,
+}
+
+// makeURLFunc returns the function generating URLs valid according to RFC 3986, or the part of them of the config,
+// so that the URL processors of the ingest pipelines parse them
+func makeURLFunc(fieldCfg ConfigField, field Field) (func(state *genState) string, error) {
+	if !isLocaleFieldType(field.Type) {
+		return nil, fmt.Errorf("the `url` generator is not supported for field type %s", field.Type)
+	}
+
+	if err := fieldCfg.ValidGenerator(); err != nil {
+		return nil, err
+	}
+
+	shape := config.URL{Schemes: defaultURLSchemes, Domains: defaultURLDomains, PathDepth: &defaultURLPathDepth, QueryParams: &defaultURLQueryParams}
+	if fieldCfg.URL != nil {
+		if fieldCfg.URL.Schemes != nil {
+			shape.Schemes = fieldCfg.URL.Schemes
+		}
+
+		if fieldCfg.URL.Domains != nil {
+			shape.Domains = fieldCfg.URL.Domains
+		}
+
+		if fieldCfg.URL.PathDepth != nil {
+			shape.PathDepth = fieldCfg.URL.PathDepth
+		}
+
+		if fieldCfg.URL.QueryParams != nil {
+			shape.QueryParams = fieldCfg.URL.QueryParams
+		}
+
+		shape.Part = fieldCfg.URL.Part
+	}
+
+	if len(shape.Part) == 0 {
+		shape.Part = config.URLPartOriginal
+		for suffix, part := range urlPartSuffixes {
+			if strings.HasSuffix(field.Name, suffix) {
+				shape.Part = part
+			}
+		}
+	}
+
+	for _, scheme := range shape.Schemes {
+		if u, err := url.Parse(scheme + "://x"); err != nil || u.Scheme != strings.ToLower(scheme) {
+			return nil, fmt.Errorf("`url` scheme %s is not valid", scheme)
+		}
+	}
+
+	for _, domain := range shape.Domains {
+		if u, err := url.Parse("https://" + domain); err != nil || u.Host != domain || len(u.Path) > 0 {
+			return nil, fmt.Errorf("`url` domain %s is not valid", domain)
+		}
+	}
+
+	if shape.QueryParams.Max > len(urlQueryKeys) {
+		return nil, fmt.Errorf("`url` query_params max must be at most %d", len(urlQueryKeys))
+	}
+
+	pathF := func(state *genState) string {
+		depth := randCount(state, *shape.PathDepth)
+		segments := make([]string, depth)
+		for i := range segments {
+			if state.rand.Float64() < urlIDSegmentProbability {
+				segments[i] = strconv.Itoa(1 + state.rand.Intn(99999))
+				continue
+			}
+
+			segments[i] = urlPathSegments[state.rand.Intn(len(urlPathSegments))]
+		}
+
+		return "/" + strings.Join(segments, "/")
+	}
+
+	queryF := func(state *genState) string {
+		params := randCount(state, *shape.QueryParams)
+		keys := state.rand.Perm(len(urlQueryKeys))[:params]
+
+		query := make([]string, 0, params)
+		for _, key := range keys {
+			query = append(query, urlQueryKeys[key]+"="+url.QueryEscape(randURLQueryValue(state)))
+		}
+
+		return strings.Join(query, "&")
+	}
+
+	switch shape.Part {
+	case config.URLPartDomain:
+		return func(state *genState) string {
+			return shape.Domains[state.rand.Intn(len(shape.Domains))]
+		}, nil
+	case config.URLPartPath:
+		return pathF, nil
+	case config.URLPartQuery:
+		return queryF, nil
+	}
+
+	return func(state *genState) string {
+		u := url.URL{
+			Scheme:   shape.Schemes[state.rand.Intn(len(shape.Schemes))],
+			Host:     shape.Domains[state.rand.Intn(len(shape.Domains))],
+			Path:     pathF(state),
+			RawQuery: queryF(state),
+		}
+
+		return u.String()
+	}, nil
+}
+
+// randCount returns a random count in the range, both included
+func randCount(state *genState, countRange config.CountRange) int {
+	return countRange.Min + state.rand.Intn(countRange.Max-countRange.Min+1)
+}
+
+// randURLQueryValue returns the value of a query parameter: either a number or a short lowercase token
+func randURLQueryValue(state *genState) string {
+	if state.rand.Intn(2) == 0 {
+		return strconv.Itoa(state.rand.Intn(1000))
+	}
+
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	value := make([]byte, 3+state.rand.Intn(6))
+	for i := range value {
+		value[i] = letters[state.rand.Intn(len(letters))]
+	}
+
+	return string(value)
+}
+
+func bindURL(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	urlF, err := makeURLFunc(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		buf.WriteString(urlF(state))
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
+
+func bindURLWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	urlF, err := makeURLFunc(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	var emitF emitF
+	emitF = func(state *genState) any {
+		return urlF(state)
+	}
+
+	fieldMap[field.Name] = emitF
+	return nil
+}
@@ -0,0 +1,238 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package genlib
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/elastic/elastic-integration-corpus-generator-tool/pkg/genlib/config"
+)
+
+// userAgentFieldName is the ECS field generated by the `user_agent` generator when it has no other value config
+const userAgentFieldName = "user_agent.original"
+
+// userAgentFormats are the formats of the user agents of a browser on an operating system, by browser and by
+// operating system, with the version of the browser as their `%[1]s` verb: a browser not available on an
+// operating system has no formats for it
+var userAgentFormats = map[string]map[string][]string{
+	"chrome": {
+		"windows": {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%[1]s Safari/537.36"},
+		"macos":   {"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%[1]s Safari/537.36"},
+		"linux":   {"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%[1]s Safari/537.36"},
+		"android": {
+			"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%[1]s Mobile Safari/537.36",
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%[1]s Mobile Safari/537.36",
+			"Mozilla/5.0 (Linux; Android 13; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%[1]s Mobile Safari/537.36",
+		},
+		"ios": {"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/%[1]s Mobile/15E148 Safari/604.1"},
+	},
+	"safari": {
+		"macos": {"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/%[1]s Safari/605.1.15"},
+		"ios": {
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/%[1]s Mobile/15E148 Safari/604.1",
+			"Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/%[1]s Mobile/15E148 Safari/604.1",
+		},
+	},
+	"edge": {
+		"windows": {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%[1]s Safari/537.36 Edg/%[1]s"},
+		"macos":   {"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%[1]s Safari/537.36 Edg/%[1]s"},
+		"android": {"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%[1]s Mobile Safari/537.36 EdgA/%[1]s"},
+		"ios":     {"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 EdgiOS/%[1]s Mobile/15E148 Safari/605.1.15"},
+	},
+	"firefox": {
+		"windows": {"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:%[1]s) Gecko/20100101 Firefox/%[1]s"},
+		"macos":   {"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:%[1]s) Gecko/20100101 Firefox/%[1]s"},
+		"linux": {
+			"Mozilla/5.0 (X11; Linux x86_64; rv:%[1]s) Gecko/20100101 Firefox/%[1]s",
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:%[1]s) Gecko/20100101 Firefox/%[1]s",
+		},
+		"android": {"Mozilla/5.0 (Android 14; Mobile; rv:%[1]s) Gecko/%[1]s Firefox/%[1]s"},
+		"ios":     {"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/%[1]s Mobile/15E148 Safari/605.1.15"},
+	},
+	"opera": {
+		"windows": {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36 OPR/%[1]s"},
+		"macos":   {"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36 OPR/%[1]s"},
+		"linux":   {"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36 OPR/%[1]s"},
+		"android": {"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Mobile Safari/537.36 OPR/%[1]s"},
+	},
+}
+
+// userAgentVersions are the recent versions of each browser
+var userAgentVersions = map[string][]string{
+	"chrome":  {"124.0.0.0", "123.0.0.0", "122.0.0.0", "121.0.0.0", "120.0.0.0"},
+	"safari":  {"17.4.1", "17.4", "17.3", "16.6"},
+	"edge":    {"124.0.2478.80", "123.0.2420.97", "122.0.2365.92"},
+	"firefox": {"125.0", "124.0", "123.0", "115.0"},
+	"opera":   {"109.0.0.0", "108.0.0.0"},
+}
+
+// defaultUserAgentBrowsers and defaultUserAgentOS are the approximate market shares of the browsers and of the
+// operating systems of the web traffic
+var defaultUserAgentBrowsers = map[string]float64{"chrome": 65, "safari": 19, "edge": 5, "firefox": 3, "opera": 2}
+var defaultUserAgentOS = map[string]float64{"android": 40, "windows": 30, "ios": 18, "macos": 8, "linux": 4}
+
+// userAgentCombination is a browser on an operating system, picked with the product of their weights
+type userAgentCombination struct {
+	browser string
+	os      string
+}
+
+// isUserAgentField reports whether the values of the field are generated by the `user_agent` generator: either it's
+// set in its config, or the field is `user_agent.original` without an `enum`, a `locale` or a `content`
+func isUserAgentField(fieldCfg ConfigField, field Field) bool {
+	if fieldCfg.Generator == config.GeneratorUserAgent {
+		return true
+	}
+
+	return len(fieldCfg.Generator) == 0 && field.Name == userAgentFieldName && len(fieldCfg.Enum) == 0 &&
+		len(fieldCfg.Locale) == 0 && len(fieldCfg.Content) == 0 && (field.Type == FieldTypeKeyword || field.Type == FieldTypeWildcard)
+}
+
+// makeUserAgentFunc returns the function generating realistic user agents, the browsers and the operating systems
+// being picked according to the weights of the config, so that the user agent processors of the ingest pipelines
+// parse a realistic mix of them
+func makeUserAgentFunc(fieldCfg ConfigField, field Field) (func(state *genState) string, error) {
+	if !isLocaleFieldType(field.Type) {
+		return nil, fmt.Errorf("the `user_agent` generator is not supported for field type %s", field.Type)
+	}
+
+	if err := fieldCfg.ValidGenerator(); err != nil {
+		return nil, err
+	}
+
+	browsers, oses := defaultUserAgentBrowsers, defaultUserAgentOS
+	if fieldCfg.UserAgent != nil && fieldCfg.UserAgent.Browsers != nil {
+		browsers = fieldCfg.UserAgent.Browsers
+	}
+
+	if fieldCfg.UserAgent != nil && fieldCfg.UserAgent.OS != nil {
+		oses = fieldCfg.UserAgent.OS
+	}
+
+	if err := validUserAgentNames(browsers, oses); err != nil {
+		return nil, err
+	}
+
+	combinations, cumulative := userAgentCombinations(browsers, oses)
+	if len(combinations) == 0 {
+		return nil, fmt.Errorf("`user_agent` has no browser available on the operating systems with a positive weight")
+	}
+
+	total := cumulative[len(cumulative)-1]
+	return func(state *genState) string {
+		x := state.rand.Float64() * total
+		c := combinations[sort.SearchFloat64s(cumulative, x)]
+
+		formats := userAgentFormats[c.browser][c.os]
+		versions := userAgentVersions[c.browser]
+		return fmt.Sprintf(formats[state.rand.Intn(len(formats))], versions[state.rand.Intn(len(versions))])
+	}, nil
+}
+
+// validUserAgentNames returns an error when a browser or an operating system of the weights is unknown
+func validUserAgentNames(browsers, oses map[string]float64) error {
+	knownOS := make(map[string]struct{})
+	for _, formats := range userAgentFormats {
+		for os := range formats {
+			knownOS[os] = struct{}{}
+		}
+	}
+
+	for browser := range browsers {
+		if _, ok := userAgentFormats[browser]; !ok {
+			return fmt.Errorf("unknown `user_agent` browser %s, must be one of %s", browser, strings.Join(sortedKeys(userAgentFormats), ", "))
+		}
+	}
+
+	for os := range oses {
+		if _, ok := knownOS[os]; !ok {
+			return fmt.Errorf("unknown `user_agent` operating system %s, must be one of %s", os, strings.Join(sortedKeys(knownOS), ", "))
+		}
+	}
+
+	return nil
+}
+
+// userAgentCombinations returns the browsers available on each operating system, in a stable order, and the
+// cumulative weights they are picked with: the weights of the browsers are normalised among the ones available on
+// each operating system, so that the operating systems keep their share
+func userAgentCombinations(browsers, oses map[string]float64) ([]userAgentCombination, []float64) {
+	var combinations []userAgentCombination
+	var cumulative []float64
+	var total float64
+	for _, os := range sortedKeys(oses) {
+		if oses[os] <= 0 {
+			continue
+		}
+
+		var available float64
+		for browser, weight := range browsers {
+			if _, ok := userAgentFormats[browser][os]; ok {
+				available += weight
+			}
+		}
+
+		if available == 0 {
+			continue
+		}
+
+		for _, browser := range sortedKeys(browsers) {
+			if _, ok := userAgentFormats[browser][os]; !ok || browsers[browser] <= 0 {
+				continue
+			}
+
+			total += oses[os] * browsers[browser] / available
+			combinations = append(combinations, userAgentCombination{browser: browser, os: os})
+			cumulative = append(cumulative, total)
+		}
+	}
+
+	return combinations, cumulative
+}
+
+// sortedKeys returns the keys of the map, sorted
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func bindUserAgent(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	userAgentF, err := makeUserAgentFunc(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	var emitFNotReturn emitFNotReturn
+	emitFNotReturn = func(state *genState, buf *bytes.Buffer) error {
+		buf.WriteString(userAgentF(state))
+		return nil
+	}
+
+	fieldMap[field.Name] = emitFNotReturn
+	return nil
+}
+
+func bindUserAgentWithReturn(fieldCfg ConfigField, field Field, fieldMap map[string]any) error {
+	userAgentF, err := makeUserAgentFunc(fieldCfg, field)
+	if err != nil {
+		return err
+	}
+
+	var emitF emitF
+	emitF = func(state *genState) any {
+		return userAgentF(state)
+	}
+
+	fieldMap[field.Name] = emitF
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package sinks is the API to plug custom sinks the generated events are sent to, instead of a corpus file, like
+// internal queues or test harnesses. A sink registered with Register, usually in the init function of its package,
+// can be selected with the `--sink` flag of the commands, so that a project embedding the commands doesn't have to
+// fork the output layer.
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var ErrNotRegistered = errors.New("sink not registered")
+
+// Stats are the statistics of a sink, since it was opened
+type Stats struct {
+	// Events is the number of events written
+	Events uint64
+	// Bytes is the size of the events written, without the newlines
+	Bytes uint64
+	// Batches is the number of batches written
+	Batches uint64
+	// Retries is the number of events written again, like when rejected by a busy destination
+	Retries uint64
+}
+
+// Sink receives the generated events in batches. The methods are called by a single goroutine: Open once before
+// any other, WriteBatch for each batch, Flush at the end of the generation, and Close once as the last, even when
+// the generation fails.
+type Sink interface {
+	// Open prepares the sink for the batches, like connecting to the destination
+	Open(ctx context.Context) error
+	// WriteBatch writes the events of a batch, each one without the trailing newline. The events must not be
+	// retained after WriteBatch returns. A *PartialError tells which events are not delivered when the others are.
+	WriteBatch(ctx context.Context, events [][]byte) error
+	// Flush returns once the events written are delivered to the destination. When it misses its deadline the
+	// Writer spills the last batch written, the one still pending.
+	Flush(ctx context.Context) error
+	// Close releases the resources of the sink
+	Close() error
+	// Stats returns the statistics of the sink
+	Stats() Stats
+}
+
+// PartialError is returned by WriteBatch when some events of the batch are delivered and the others are not, like
+// when a batch is sent to several destinations and one of them fails: the Writer spills the undelivered events
+// only, so that the ones delivered are not delivered twice.
+type PartialError struct {
+	// Undelivered are the events of the batch not delivered
+	Undelivered [][]byte
+	// Err is the failure of the undelivered events
+	Err error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d events not delivered: %v", len(e.Undelivered), e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// Loss is the accounting of the events a sink sends without acknowledgement, like datagrams, so that a load test can
+// tell the limits of the generator from the losses of the network
+type Loss struct {
+	// Intended is the number of events the sink tried to send
+	Intended uint64
+	// Sent is the number of events handed to the network
+	Sent uint64
+	// BufferDrops is the number of events dropped by the send buffers of the sockets, an estimate when the counter
+	// is not per socket
+	BufferDrops uint64
+}
+
+// Rate returns the estimated fraction of the intended events lost, between 0 and 1
+func (l Loss) Rate() float64 {
+	if l.Intended == 0 {
+		return 0
+	}
+
+	lost := l.Intended - l.Sent + l.BufferDrops
+	if lost > l.Intended {
+		lost = l.Intended
+	}
+
+	return float64(lost) / float64(l.Intended)
+}
+
+// LossReporter is implemented by the sinks accounting for the events lost on the way, like the fire-and-forget ones
+type LossReporter interface {
+	// Loss returns the accounting of the losses, since the sink was opened
+	Loss() Loss
+}
+
+// Factory returns a new sink configured with options, the `key=value` pairs of the `--sink-option` flags
+type Factory func(options map[string]string) (Sink, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a sink available by name. It panics if called twice with the same name, or if the name is empty
+// or the factory is nil, like database/sql.Register.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(name) == 0 {
+		panic("sinks: Register with an empty name")
+	}
+
+	if factory == nil {
+		panic("sinks: Register with a nil factory for sink " + name)
+	}
+
+	if _, ok := factories[name]; ok {
+		panic("sinks: Register called twice for sink " + name)
+	}
+
+	factories[name] = factory
+}
+
+// New returns a new sink of the one registered by name, configured with options
+func New(name string, options map[string]string) (Sink, error) {
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotRegistered, name)
+	}
+
+	s, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("sink %s: %w", name, err)
+	}
+
+	return s, nil
+}
+
+// Names returns the names of the registered sinks, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,293 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySink keeps the batches written, and the calls of its methods
+type memorySink struct {
+	calls   []string
+	batches [][]string
+	stats   Stats
+	fail    error
+	// timeouts is the number of the next batches not delivered before the deadline of their context
+	timeouts int
+	// partials is the number of the next batches whose first event only is delivered before the deadline
+	partials int
+	// flushTimeouts is the number of the next flushes not done before the deadline of their context
+	flushTimeouts int
+}
+
+func (s *memorySink) Open(context.Context) error {
+	s.calls = append(s.calls, "open")
+	return nil
+}
+
+func (s *memorySink) WriteBatch(ctx context.Context, events [][]byte) error {
+	s.calls = append(s.calls, "write")
+	if s.fail != nil {
+		return s.fail
+	}
+
+	if s.timeouts > 0 {
+		s.timeouts--
+		<-ctx.Done()
+		return fmt.Errorf("cannot write batch: %w", ctx.Err())
+	}
+
+	var undelivered [][]byte
+	if s.partials > 0 {
+		s.partials--
+		events, undelivered = events[:1], events[1:]
+	}
+
+	batch := make([]string, 0, len(events))
+	for _, event := range events {
+		batch = append(batch, string(event))
+		s.stats.Events++
+		s.stats.Bytes += uint64(len(event))
+	}
+
+	s.batches = append(s.batches, batch)
+	s.stats.Batches++
+
+	if len(undelivered) > 0 {
+		<-ctx.Done()
+		return &PartialError{Undelivered: undelivered, Err: ctx.Err()}
+	}
+
+	return nil
+}
+
+func (s *memorySink) Flush(ctx context.Context) error {
+	s.calls = append(s.calls, "flush")
+	if s.flushTimeouts > 0 {
+		s.flushTimeouts--
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.calls = append(s.calls, "close")
+	return nil
+}
+
+func (s *memorySink) Stats() Stats {
+	return s.stats
+}
+
+func TestRegister(t *testing.T) {
+	var options map[string]string
+	Register("test-register", func(o map[string]string) (Sink, error) {
+		options = o
+		return &memorySink{}, nil
+	})
+
+	Register("test-register-failing", func(map[string]string) (Sink, error) {
+		return nil, errors.New("missing option")
+	})
+
+	assert.Contains(t, Names(), "test-register")
+
+	s, err := New("test-register", map[string]string{"topic": "logs"})
+	require.NoError(t, err)
+	assert.IsType(t, &memorySink{}, s)
+	assert.Equal(t, map[string]string{"topic": "logs"}, options)
+
+	_, err = New("test-register-failing", nil)
+	assert.ErrorContains(t, err, "sink test-register-failing: missing option")
+
+	_, err = New("not-registered", nil)
+	assert.ErrorIs(t, err, ErrNotRegistered)
+
+	assert.Panics(t, func() {
+		Register("test-register", func(map[string]string) (Sink, error) { return nil, nil })
+	})
+
+	assert.Panics(t, func() {
+		Register("", func(map[string]string) (Sink, error) { return nil, nil })
+	})
+
+	assert.Panics(t, func() {
+		Register("test-register-nil", nil)
+	})
+}
+
+func TestWriter(t *testing.T) {
+	s := &memorySink{}
+	w, err := NewWriter(context.Background(), s, 2)
+	require.NoError(t, err)
+
+	for _, event := range []string{"a\n", "b\n", "\n", "c"} {
+		n, err := w.Write([]byte(event))
+		require.NoError(t, err)
+		assert.Equal(t, len(event), n)
+	}
+
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, s.batches)
+	assert.Equal(t, []string{"open", "write", "write", "flush", "close"}, s.calls)
+	assert.Equal(t, Stats{Events: 3, Bytes: 3, Batches: 2}, s.Stats())
+}
+
+func TestWriter_sendTimestamp(t *testing.T) {
+	s := &memorySink{}
+	w, err := NewWriter(context.Background(), s, 10, WithSendTimestamp("corpus.sent_at"))
+	require.NoError(t, err)
+
+	before := time.Now()
+	for _, event := range []string{`{"a":1}`, ` { }`, "{\"create\":{}}\n{\"b\":2}", "plain text", `["a"]`} {
+		_, err := w.Write([]byte(event))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+	require.Len(t, s.batches, 1)
+
+	events := s.batches[0]
+	var stamped struct {
+		SentAt time.Time `json:"corpus.sent_at"`
+		A      int       `json:"a"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(events[0]), &stamped))
+	assert.Equal(t, 1, stamped.A)
+	assert.False(t, stamped.SentAt.Before(before.Truncate(time.Microsecond)))
+
+	sentAt := stamped.SentAt.Format(time.RFC3339Nano)
+	assert.Equal(t, ` {"corpus.sent_at":"`+sentAt+`" }`, events[1])
+	assert.Equal(t, "{\"create\":{}}\n{\"corpus.sent_at\":\""+sentAt+"\",\"b\":2}", events[2])
+	// the events that are not JSON objects are not stamped
+	assert.Equal(t, []string{"plain text", `["a"]`}, events[3:])
+}
+
+func TestWriter_failure(t *testing.T) {
+	s := &memorySink{fail: errors.New("queue full")}
+	w, err := NewWriter(context.Background(), s, 2)
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("a\n"))
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("b\n"))
+	assert.ErrorContains(t, err, "queue full")
+
+	// the sink is closed even if the last batch fails
+	_, err = w.Write([]byte("c\n"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, w.Close(), "queue full")
+	assert.Equal(t, []string{"open", "write", "write", "close"}, s.calls)
+}
+
+func TestWriter_spill(t *testing.T) {
+	s := &memorySink{timeouts: 2}
+	dir := t.TempDir()
+	w, err := NewWriter(context.Background(), s, 2, WithBatchTimeout(10*time.Millisecond), WithSpillDir(dir))
+	require.NoError(t, err)
+
+	for _, event := range []string{"a\n", "b\n", "c\n", "d\n", "e\n"} {
+		_, err := w.Write([]byte(event))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+
+	// the batches spilled are delivered at the end, in order
+	assert.Equal(t, [][]string{{"e"}, {"a", "b"}, {"c", "d"}}, s.batches)
+	assert.Equal(t, SpillStats{Batches: 2, Events: 4, Delivered: 2}, w.SpillStats())
+
+	spilled, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, spilled)
+}
+
+func TestWriter_undelivered(t *testing.T) {
+	s := &memorySink{timeouts: 4}
+	dir := t.TempDir()
+	w, err := NewWriter(context.Background(), s, 2, WithBatchTimeout(10*time.Millisecond), WithSpillDir(dir))
+	require.NoError(t, err)
+
+	for _, event := range []string{"a\n", "b\n", "c\n"} {
+		_, err := w.Write([]byte(event))
+		require.NoError(t, err)
+	}
+
+	assert.ErrorIs(t, w.Close(), ErrUndelivered)
+	assert.Equal(t, SpillStats{Batches: 2, Events: 3}, w.SpillStats())
+	assert.Empty(t, s.batches)
+
+	spilled, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, spilled, 2)
+
+	content, err := os.ReadFile(dir + "/" + spilled[0].Name())
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\n", string(content))
+}
+
+func TestWriter_deadline(t *testing.T) {
+	s := &memorySink{timeouts: 1}
+	w, err := NewWriter(context.Background(), s, 1, WithBatchTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+
+	// without a spill directory the batch not delivered fails the write
+	_, err = w.Write([]byte("a\n"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NoError(t, w.Close())
+}
+
+func TestWriter_partial(t *testing.T) {
+	s := &memorySink{partials: 1}
+	w, err := NewWriter(context.Background(), s, 3, WithBatchTimeout(10*time.Millisecond), WithSpillDir(t.TempDir()))
+	require.NoError(t, err)
+
+	for _, event := range []string{"a\n", "b\n", "c\n"} {
+		_, err := w.Write([]byte(event))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+
+	// only the events not delivered are spilled, and delivered at the end
+	assert.Equal(t, [][]string{{"a"}, {"b", "c"}}, s.batches)
+	assert.Equal(t, SpillStats{Batches: 1, Events: 2, Delivered: 1}, w.SpillStats())
+}
+
+func TestWriter_flushDeadline(t *testing.T) {
+	s := &memorySink{flushTimeouts: 1}
+	dir := t.TempDir()
+	w, err := NewWriter(context.Background(), s, 2, WithBatchTimeout(10*time.Millisecond), WithSpillDir(dir))
+	require.NoError(t, err)
+
+	for _, event := range []string{"a\n", "b\n", "c\n"} {
+		_, err := w.Write([]byte(event))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+
+	// the last batch, pending when the flush misses its deadline, is spilled and written again
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}, {"c"}}, s.batches)
+	assert.Equal(t, SpillStats{Batches: 1, Events: 1, Delivered: 1}, w.SpillStats())
+	assert.Equal(t, []string{"open", "write", "write", "flush", "write", "flush", "close"}, s.calls)
+
+	spilled, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, spilled)
+}
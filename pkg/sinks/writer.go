@@ -0,0 +1,316 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultBatchSize is the default number of events of each batch written to a sink
+const DefaultBatchSize = 1000
+
+var ErrUndelivered = errors.New("batches not delivered before their deadline")
+
+// WriterOption configures a Writer
+type WriterOption func(w *Writer)
+
+// WithBatchTimeout sets the deadline of each WriteBatch, and of Flush, to timeout after they are called; zero means
+// none but the one of the context of the Writer
+func WithBatchTimeout(timeout time.Duration) WriterOption {
+	return func(w *Writer) {
+		w.batchTimeout = timeout
+	}
+}
+
+// WithSpillDir makes the Writer persist to dir, instead of failing, the batches that cannot be delivered before
+// their deadline, and retry them once when it's closed
+func WithSpillDir(dir string) WriterOption {
+	return func(w *Writer) {
+		w.spillDir = dir
+	}
+}
+
+// WithSendTimestamp makes the Writer embed in each event the time its batch is written to the sink, in RFC 3339 with
+// nanoseconds, as the top level field named field, to measure the latency from the generation to the destination.
+// Only the events that are JSON objects are stamped, in their last line for the events of several lines, like a bulk
+// action and its document.
+func WithSendTimestamp(field string) WriterOption {
+	return func(w *Writer) {
+		key, _ := json.Marshal(field)
+		w.sendTimestampKey = append(key, ':')
+	}
+}
+
+// SpillStats are the statistics of the batches spilled by a Writer
+type SpillStats struct {
+	// Batches and Events are the batches, and their events, persisted to the spill directory
+	Batches uint64
+	Events  uint64
+	// Delivered are the batches delivered when retried at the end, the others being left in the spill directory
+	Delivered uint64
+}
+
+// Writer is an io.WriteCloser writing each written event, one per call, to a sink in batches, so that a sink can
+// be used wherever the events are written as a corpus file
+type Writer struct {
+	ctx       context.Context
+	sink      Sink
+	batchSize int
+	batch     [][]byte
+	// pending is the last batch written and not spilled, the one spilled when the flush misses its deadline
+	pending [][]byte
+
+	batchTimeout time.Duration
+	spillDir     string
+	// sendTimestampKey is the key, with the colon, of the field of the time each batch is written to the sink
+	sendTimestampKey []byte
+	// spilled are the files of the batches spilled, in order
+	spilled    []string
+	spillStats SpillStats
+}
+
+// NewWriter opens s and returns a Writer writing to it batches of batchSize events, DefaultBatchSize when not
+// positive
+func NewWriter(ctx context.Context, s Sink, batchSize int, opts ...WriterOption) (*Writer, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	w := &Writer{ctx: ctx, sink: s, batchSize: batchSize}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if len(w.spillDir) > 0 {
+		if err := os.MkdirAll(w.spillDir, 0770); err != nil {
+			_ = s.Close()
+			return nil, fmt.Errorf("cannot create spill directory: %w", err)
+		}
+	}
+
+	if err := s.Open(ctx); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write adds the event p to the current batch, writing the batch when it's full
+func (w *Writer) Write(p []byte) (int, error) {
+	event := bytes.TrimRight(p, "\n")
+	if len(event) == 0 {
+		return len(p), nil
+	}
+
+	w.batch = append(w.batch, append([]byte(nil), event...))
+	if len(w.batch) >= w.batchSize {
+		if err := w.writeBatch(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close writes the last batch, flushes the sink, retries the batches spilled, if any, and closes the sink. If the
+// flush misses its deadline the last batch written is spilled and retried too. It returns ErrUndelivered if some
+// batches are still not delivered, left in the spill directory.
+func (w *Writer) Close() error {
+	err := w.writeBatch()
+	if err == nil {
+		ctx, cancel := w.batchContext()
+		err = w.sink.Flush(ctx)
+		cancel()
+
+		if err != nil && len(w.spillDir) > 0 && isDeadlineExceeded(err) {
+			err = w.spill(w.pending)
+		}
+	}
+
+	if err == nil {
+		err = w.retrySpilled()
+	}
+
+	if closeErr := w.sink.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// BatchSize returns the number of events of each batch written to the sink
+func (w *Writer) BatchSize() int {
+	return w.batchSize
+}
+
+// SpillStats returns the statistics of the batches spilled
+func (w *Writer) SpillStats() SpillStats {
+	return w.spillStats
+}
+
+// batchContext returns the context of a call to the sink, with the deadline of WithBatchTimeout if any
+func (w *Writer) batchContext() (context.Context, context.CancelFunc) {
+	if w.batchTimeout <= 0 {
+		return w.ctx, func() {}
+	}
+
+	return context.WithTimeout(w.ctx, w.batchTimeout)
+}
+
+func (w *Writer) writeBatch() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+
+	if len(w.sendTimestampKey) > 0 {
+		sentAt := time.Now().UTC().AppendFormat([]byte{'"'}, time.RFC3339Nano)
+		sentAt = append(sentAt, '"')
+		for i, event := range w.batch {
+			w.batch[i] = stampEvent(event, w.sendTimestampKey, sentAt)
+		}
+	}
+
+	ctx, cancel := w.batchContext()
+	err := w.sink.WriteBatch(ctx, w.batch)
+	cancel()
+
+	// the events of the batch delivered are not spilled with the others
+	undelivered := w.batch
+	var partial *PartialError
+	if errors.As(err, &partial) {
+		undelivered = partial.Undelivered
+	}
+
+	if err != nil && len(w.spillDir) > 0 && isDeadlineExceeded(err) {
+		err = w.spill(undelivered)
+		w.pending, w.batch = w.pending[:0], w.batch[:0]
+		return err
+	}
+
+	w.pending, w.batch = w.batch, w.pending[:0]
+	return err
+}
+
+// stampEvent returns event with the field of key set to value, as the first field of its last line when it's a JSON
+// object, or event itself otherwise
+func stampEvent(event, key, value []byte) []byte {
+	start := bytes.LastIndexByte(event, '\n') + 1
+	line := bytes.TrimLeft(event[start:], " \t")
+	if len(line) == 0 || line[0] != '{' {
+		return event
+	}
+
+	open := len(event) - len(line) + 1
+	stamped := make([]byte, 0, len(event)+len(key)+len(value)+1)
+	stamped = append(stamped, event[:open]...)
+	stamped = append(stamped, key...)
+	stamped = append(stamped, value...)
+	if rest := bytes.TrimLeft(event[open:], " \t"); len(rest) > 0 && rest[0] != '}' {
+		stamped = append(stamped, ',')
+	}
+
+	return append(stamped, event[open:]...)
+}
+
+// isDeadlineExceeded reports whether err is caused by a deadline, either of a context or of a connection
+func isDeadlineExceeded(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// spill persists the events of batch to a file of the spill directory, one per line
+func (w *Writer) spill(batch [][]byte) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	name := filepath.Join(w.spillDir, fmt.Sprintf("%d-%d.ndjson", time.Now().UnixNano(), len(w.spilled)))
+	if err := writeSpilled(name, batch); err != nil {
+		return err
+	}
+
+	w.spilled = append(w.spilled, name)
+	w.spillStats.Batches++
+	w.spillStats.Events += uint64(len(batch))
+	return nil
+}
+
+// writeSpilled writes the events of batch to the file name, one per line
+func writeSpilled(name string, batch [][]byte) error {
+	var buf bytes.Buffer
+	for _, event := range batch {
+		buf.Write(event)
+		buf.WriteByte('\n')
+	}
+
+	if err := os.WriteFile(name, buf.Bytes(), 0660); err != nil {
+		return fmt.Errorf("cannot spill batch: %w", err)
+	}
+
+	return nil
+}
+
+// retrySpilled writes again, once, the batches spilled, removing the files of the ones delivered
+func (w *Writer) retrySpilled() error {
+	var undelivered []string
+	for _, name := range w.spilled {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("cannot read spilled batch: %w", err)
+		}
+
+		batch := bytes.Split(bytes.TrimSuffix(content, []byte("\n")), []byte("\n"))
+
+		ctx, cancel := w.batchContext()
+		err = w.sink.WriteBatch(ctx, batch)
+		cancel()
+
+		if err != nil && !isDeadlineExceeded(err) {
+			return err
+		}
+
+		if err != nil {
+			// the events delivered are not left in the spill directory
+			var partial *PartialError
+			if errors.As(err, &partial) {
+				if err := writeSpilled(name, partial.Undelivered); err != nil {
+					return err
+				}
+			}
+
+			undelivered = append(undelivered, name)
+			continue
+		}
+
+		w.spillStats.Delivered++
+		if err := os.Remove(name); err != nil {
+			return fmt.Errorf("cannot remove spilled batch: %w", err)
+		}
+	}
+
+	w.spilled = undelivered
+	if w.spillStats.Delivered > 0 {
+		ctx, cancel := w.batchContext()
+		err := w.sink.Flush(ctx)
+		cancel()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(undelivered) > 0 {
+		return fmt.Errorf("%w: %d batches left in %s", ErrUndelivered, len(undelivered), w.spillDir)
+	}
+
+	return nil
+}